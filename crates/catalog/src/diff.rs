@@ -0,0 +1,225 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Schema diff
+//!
+//! This module compares two versions of a schema's tables: the tables added
+//! and removed, and for the tables in both, the columns added, removed and
+//! altered. Tables are matched by qualified name and columns by name, so a
+//! rename shows as a removal and an addition.
+//!
+//! ## Usage
+//!
+//! ```rust,ignore
+//! use unified_sql_lsp_catalog::SchemaDiff;
+//!
+//! let diff = SchemaDiff::between(&old.tables, &new.tables);
+//! if !diff.is_empty() {
+//!     println!("{} tables added", diff.added_tables.len());
+//! }
+//! ```
+
+use std::collections::{BTreeSet, HashMap};
+
+use crate::metadata::{ColumnMetadata, TableMetadata};
+
+/// Column changes of a table present in both versions of a schema
+#[derive(Debug, Clone, PartialEq, Eq, Default)]
+pub struct TableDiff {
+    /// Qualified name of the table
+    pub table: String,
+    /// Columns only in the new version
+    pub added_columns: Vec<String>,
+    /// Columns only in the old version
+    pub removed_columns: Vec<String>,
+    /// Columns whose type, nullability, default or keys changed
+    pub altered_columns: Vec<String>,
+}
+
+impl TableDiff {
+    /// Check whether the table's columns are unchanged
+    pub fn is_empty(&self) -> bool {
+        self.added_columns.is_empty()
+            && self.removed_columns.is_empty()
+            && self.altered_columns.is_empty()
+    }
+}
+
+/// Differences between two versions of a schema's tables
+#[derive(Debug, Clone, PartialEq, Eq, Default)]
+pub struct SchemaDiff {
+    /// Qualified names of the tables only in the new version
+    pub added_tables: Vec<String>,
+    /// Qualified names of the tables only in the old version
+    pub removed_tables: Vec<String>,
+    /// Tables in both versions whose columns changed
+    pub changed_tables: Vec<TableDiff>,
+}
+
+impl SchemaDiff {
+    /// Compare two versions of a schema's tables
+    ///
+    /// # Arguments
+    ///
+    /// * `old` - Tables of the previous version
+    /// * `new` - Tables of the current version
+    ///
+    /// # Returns
+    ///
+    /// The differences, in the order of the tables and columns of the version
+    /// they come from
+    pub fn between(old: &[TableMetadata], new: &[TableMetadata]) -> Self {
+        let old_tables: HashMap<String, &TableMetadata> = old
+            .iter()
+            .map(|table| (table.qualified_name(), table))
+            .collect();
+        let new_names: BTreeSet<String> = new.iter().map(TableMetadata::qualified_name).collect();
+
+        let mut diff = SchemaDiff {
+            removed_tables: old
+                .iter()
+                .map(TableMetadata::qualified_name)
+                .filter(|name| !new_names.contains(name))
+                .collect(),
+            ..Default::default()
+        };
+        for table in new {
+            let name = table.qualified_name();
+            match old_tables.get(&name) {
+                Some(previous) => {
+                    let changes = column_changes(name, &previous.columns, &table.columns);
+                    if !changes.is_empty() {
+                        diff.changed_tables.push(changes);
+                    }
+                }
+                None => diff.added_tables.push(name),
+            }
+        }
+        diff
+    }
+
+    /// Check whether both versions have the same tables and columns
+    pub fn is_empty(&self) -> bool {
+        self.added_tables.is_empty()
+            && self.removed_tables.is_empty()
+            && self.changed_tables.is_empty()
+    }
+
+    /// Schemas of the added, removed and changed tables, sorted
+    pub fn schemas(&self) -> Vec<String> {
+        let names = self
+            .added_tables
+            .iter()
+            .chain(&self.removed_tables)
+            .chain(self.changed_tables.iter().map(|table| &table.table));
+        let schemas: BTreeSet<String> = names
+            .filter_map(|name| name.rsplit_once('.'))
+            .map(|(schema, _)| schema.to_string())
+            .collect();
+        schemas.into_iter().collect()
+    }
+}
+
+fn column_changes(table: String, old: &[ColumnMetadata], new: &[ColumnMetadata]) -> TableDiff {
+    let old_columns: HashMap<&str, &ColumnMetadata> = old
+        .iter()
+        .map(|column| (column.name.as_str(), column))
+        .collect();
+
+    let mut diff = TableDiff {
+        table,
+        removed_columns: old
+            .iter()
+            .filter(|column| !new.iter().any(|other| other.name == column.name))
+            .map(|column| column.name.clone())
+            .collect(),
+        ..Default::default()
+    };
+    for column in new {
+        match old_columns.get(column.name.as_str()) {
+            Some(previous) if altered(previous, column) => {
+                diff.altered_columns.push(column.name.clone())
+            }
+            Some(_) => {}
+            None => diff.added_columns.push(column.name.clone()),
+        }
+    }
+    diff
+}
+
+/// Check whether a column's definition changed; comments are not part of it
+fn altered(old: &ColumnMetadata, new: &ColumnMetadata) -> bool {
+    old.data_type != new.data_type
+        || old.nullable != new.nullable
+        || old.default_value != new.default_value
+        || old.is_primary_key != new.is_primary_key
+        || old.references != new.references
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::metadata::DataType;
+
+    fn schema() -> Vec<TableMetadata> {
+        vec![
+            TableMetadata::new("users", "public").with_columns(vec![
+                ColumnMetadata::new("id", DataType::Integer).with_primary_key(),
+                ColumnMetadata::new("email", DataType::Text),
+            ]),
+            TableMetadata::new("orders", "public").with_columns(vec![
+                ColumnMetadata::new("id", DataType::Integer),
+                ColumnMetadata::new("total", DataType::Integer),
+            ]),
+            TableMetadata::new("events", "audit"),
+        ]
+    }
+
+    #[test]
+    fn test_identical_schemas() {
+        let diff = SchemaDiff::between(&schema(), &schema());
+        assert!(diff.is_empty());
+        assert!(diff.schemas().is_empty());
+
+        // Comments are not definitions
+        let mut commented = schema();
+        commented[0].columns[1] = commented[0].columns[1].clone().with_comment("Login");
+        assert!(SchemaDiff::between(&schema(), &commented).is_empty());
+    }
+
+    #[test]
+    fn test_tables_and_columns_changes() {
+        let mut new = schema();
+        new.remove(2);
+        new.push(TableMetadata::new("payments", "public"));
+        new[1].columns = vec![
+            ColumnMetadata::new("id", DataType::Integer),
+            ColumnMetadata::new("total", DataType::BigInt),
+            ColumnMetadata::new("paid_at", DataType::Timestamp),
+        ];
+        new[0].columns.remove(1);
+
+        let diff = SchemaDiff::between(&schema(), &new);
+        assert_eq!(diff.added_tables, vec!["public.payments"]);
+        assert_eq!(diff.removed_tables, vec!["audit.events"]);
+        assert_eq!(
+            diff.changed_tables,
+            vec![
+                TableDiff {
+                    table: "public.users".to_string(),
+                    removed_columns: vec!["email".to_string()],
+                    ..Default::default()
+                },
+                TableDiff {
+                    table: "public.orders".to_string(),
+                    added_columns: vec!["paid_at".to_string()],
+                    altered_columns: vec!["total".to_string()],
+                    ..Default::default()
+                },
+            ]
+        );
+        assert_eq!(diff.schemas(), vec!["audit", "public"]);
+    }
+}
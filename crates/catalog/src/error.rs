@@ -20,6 +20,18 @@ pub enum CatalogError {
     #[error("Failed to connect to database: {0}")]
     ConnectionFailed(String),
 
+    /// No database connection is configured or available
+    #[error("No database connection available")]
+    NoConnection,
+
+    /// The connection failed its health checks and is considered down
+    #[error("Database connection is down: {0}")]
+    ConnectionDown(String),
+
+    /// The schema is still being loaded in the background
+    #[error("Database schema is still loading")]
+    SchemaLoading,
+
     /// Query execution failed
     #[error("Query execution failed: {0}")]
     QueryFailed(String),
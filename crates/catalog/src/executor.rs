@@ -0,0 +1,136 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Query execution
+//!
+//! This module defines the [`QueryExecutor`] trait used by editor commands
+//! (e.g. showing a query plan) that run SQL against the configured database,
+//! as opposed to the schema queries of the [`Catalog`](crate::Catalog) trait.
+//!
+//! Results are returned as plain text cells so that callers can render them
+//! without knowing the database's type system.
+//!
+//! Statements that must share a connection (e.g. several statements run in
+//! one transaction) go through a [`QuerySession`]. Dropping a transactional
+//! session without committing rolls the transaction back, so a cancelled
+//! request never leaves partial changes behind.
+
+use crate::error::{CatalogError, CatalogResult};
+
+/// Result of a single executed statement
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct QueryResult {
+    /// Column names, in result order
+    pub columns: Vec<String>,
+
+    /// Rows of text cells; `None` is SQL NULL
+    pub rows: Vec<Vec<Option<String>>>,
+
+    /// More rows were available than the requested maximum
+    pub truncated: bool,
+}
+
+impl QueryResult {
+    /// Create a new query result
+    pub fn new(columns: Vec<String>, rows: Vec<Vec<Option<String>>>) -> Self {
+        Self {
+            columns,
+            rows,
+            truncated: false,
+        }
+    }
+
+    /// Mark the result as truncated
+    pub fn with_truncated(mut self, truncated: bool) -> Self {
+        self.truncated = truncated;
+        self
+    }
+}
+
+/// Executor for arbitrary SQL statements
+///
+/// Implementations must execute exactly one statement per call and reject
+/// input containing several statements where the driver allows it.
+///
+/// # Examples
+///
+/// ```rust,ignore
+/// let result = executor.execute("EXPLAIN SELECT * FROM users").await?;
+/// for row in result.rows {
+///     println!("{:?}", row);
+/// }
+/// ```
+#[async_trait::async_trait]
+pub trait QueryExecutor: Send + Sync {
+    /// Execute a single statement and return its rows
+    ///
+    /// # Arguments
+    ///
+    /// * `sql` - The statement to execute
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::ConnectionFailed` if no connection is available.
+    /// Returns `CatalogError::QueryFailed` with the database message if the
+    /// statement fails.
+    async fn execute(&self, sql: &str) -> CatalogResult<QueryResult>;
+
+    /// Check that the database is reachable
+    ///
+    /// The default implementation runs `SELECT 1`.
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::ConnectionFailed` if the database cannot be reached.
+    async fn ping(&self) -> CatalogResult<()> {
+        self.execute("SELECT 1").await.map(|_| ())
+    }
+
+    /// Open a session running statements on a single connection
+    ///
+    /// # Arguments
+    ///
+    /// * `transactional` - Run the session's statements in a transaction;
+    ///   otherwise every statement commits on its own (autocommit)
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::NotSupported` if the executor has no sessions.
+    async fn session(&self, transactional: bool) -> CatalogResult<Box<dyn QuerySession>> {
+        let _ = transactional;
+        Err(CatalogError::NotSupported(
+            "Query sessions are not supported by this executor".to_string(),
+        ))
+    }
+}
+
+/// Statements sharing one connection
+///
+/// A transactional session that is dropped without [`QuerySession::commit`]
+/// rolls back.
+#[async_trait::async_trait]
+pub trait QuerySession: Send {
+    /// Run a statement returning rows, keeping at most `max_rows` of them
+    ///
+    /// Rows are streamed from the database; reading stops after `max_rows`
+    /// rows and the result is marked as truncated if more were available.
+    async fn fetch(&mut self, sql: &str, max_rows: usize) -> CatalogResult<QueryResult>;
+
+    /// Run a statement not returning rows
+    ///
+    /// # Returns
+    ///
+    /// The number of affected rows
+    async fn execute(&mut self, sql: &str) -> CatalogResult<u64>;
+
+    /// Whether the session runs in a transaction
+    fn is_transactional(&self) -> bool;
+
+    /// Commit the transaction (no-op in autocommit sessions)
+    async fn commit(self: Box<Self>) -> CatalogResult<()>;
+
+    /// Roll back the transaction (no-op in autocommit sessions)
+    async fn rollback(self: Box<Self>) -> CatalogResult<()>;
+}
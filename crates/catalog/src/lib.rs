@@ -11,6 +11,12 @@
 //! - **Live Catalogs**: Direct database connections (MySQL, PostgreSQL, TiDB)
 //! - **Static Catalogs**: Schema definitions from files (YAML/JSON)
 //! - **Cached Catalogs**: Wrapper with LRU cache and TTL
+//! - **Offline Catalog**: Empty stand-in used when no database connection is available
+//! - **Snapshot Catalog**: Schema dumped to a versioned JSON file, served without a connection
+//! - **Multi Catalog**: Tables of several databases of one server, referenced as `db.schema.table`
+//! - **Incremental Refresh**: Re-introspection of changed tables only, for catalogs that detect changes
+//! - **Schema Diff**: Tables and columns added, removed or altered between two versions of a schema
+//! - **Redaction**: Passwords removed from connection strings before they reach logs or errors
 //!
 //! ## Architecture
 //!
@@ -67,20 +73,38 @@
 //! }
 //! ```
 
+pub mod diff;
 pub mod error;
+pub mod executor;
 pub mod live_mysql;
 pub mod live_postgres;
 pub mod metadata;
+pub mod multi;
+pub mod offline;
+pub mod pool;
+pub mod redact;
+pub mod refresh;
+pub mod snapshot;
 pub mod r#static;
+pub mod tls;
 pub mod r#trait;
 
 // Re-exports
+pub use diff::{SchemaDiff, TableDiff};
 pub use error::{CatalogError, CatalogResult};
+pub use executor::{QueryExecutor, QueryResult};
 pub use live_mysql::LiveMySQLCatalog;
 pub use live_postgres::LivePostgreSQLCatalog;
 pub use metadata::{
     ColumnMetadata, DataType, FunctionMetadata, FunctionParameter, FunctionType, TableMetadata,
-    TableReference, TableType, format_data_type,
+    TableReference, TableType, comment_text, format_data_type,
 };
+pub use multi::{CatalogFilter, MultiCatalog};
+pub use offline::OfflineCatalog;
+pub use pool::PoolOptions;
+pub use redact::{RedactedDsn, redact_credentials};
+pub use refresh::{TableChanges, TableVersion, VersionedSnapshot};
+pub use snapshot::{SCHEMA_SNAPSHOT_VERSION, SchemaSnapshot, SnapshotCatalog};
 pub use r#static::StaticCatalog;
+pub use tls::TlsOptions;
 pub use r#trait::Catalog;
@@ -10,10 +10,11 @@
 //!
 //! ## Features
 //!
-//! - Connection pooling with configurable size (default: 10 connections)
+//! - Connection pooling with configurable size and lifetimes (default: 4 connections)
 //! - Query timeout support (default: 5 seconds)
 //! - Health checks for connection validation
 //! - Real-time schema queries from information_schema
+//! - Tables of other databases of the server, selected with a [`CatalogFilter`]
 //!
 //! ## Usage
 //!
@@ -37,22 +38,27 @@
 //! ```
 
 use crate::error::{CatalogError, CatalogResult};
+use crate::executor::{QueryExecutor, QueryResult, QuerySession};
 use crate::metadata::{ColumnMetadata, DataType, FunctionMetadata, FunctionType, TableMetadata};
+use crate::multi::CatalogFilter;
+use crate::pool::PoolOptions;
+use crate::tls::TlsOptions;
 use crate::r#trait::Catalog;
 
 use async_trait::async_trait;
+use std::collections::HashMap;
 
 #[cfg(feature = "mysql")]
-use crate::metadata::TableType;
+use crate::metadata::{TableType, comment_text};
 
 #[cfg(feature = "mysql")]
-use sqlx::{MySql, Pool};
-
-/// Default connection pool size
-const DEFAULT_POOL_SIZE: u32 = 10;
-
-/// Default query timeout in seconds
-const DEFAULT_TIMEOUT_SECS: u64 = 5;
+use futures_util::TryStreamExt;
+#[cfg(feature = "mysql")]
+use sqlx::mysql::{MySqlConnectOptions, MySqlRow};
+#[cfg(feature = "mysql")]
+use sqlx::{Column, Connection, MySql, Pool, Row, ValueRef};
+#[cfg(feature = "mysql")]
+use std::str::FromStr;
 
 /// Live MySQL Catalog implementation
 ///
@@ -62,10 +68,12 @@ const DEFAULT_TIMEOUT_SECS: u64 = 5;
 pub struct LiveMySQLCatalog {
     /// MySQL connection string
     connection_string: String,
-    /// Connection pool size
-    pool_size: u32,
-    /// Query timeout in seconds
-    timeout_secs: u64,
+    /// Connection pool settings
+    options: PoolOptions,
+    /// TLS settings
+    tls: Option<TlsOptions>,
+    /// Other databases to list tables of
+    databases: Option<CatalogFilter>,
     /// Connection pool
     pool: Option<Pool<MySql>>,
 }
@@ -75,10 +83,12 @@ pub struct LiveMySQLCatalog {
 pub struct LiveMySQLCatalog {
     /// MySQL connection string
     connection_string: String,
-    /// Connection pool size
-    pool_size: u32,
-    /// Query timeout in seconds
-    timeout_secs: u64,
+    /// Connection pool settings
+    options: PoolOptions,
+    /// TLS settings
+    tls: Option<TlsOptions>,
+    /// Other databases to list tables of
+    databases: Option<CatalogFilter>,
 }
 
 impl LiveMySQLCatalog {
@@ -101,30 +111,7 @@ impl LiveMySQLCatalog {
     /// ).await?;
     /// ```
     pub async fn new(connection_string: impl Into<String>) -> CatalogResult<Self> {
-        let conn_str = connection_string.into();
-        Self::validate_connection_string(&conn_str)?;
-
-        #[cfg(feature = "mysql")]
-        {
-            let pool = Some(Pool::<MySql>::connect(&conn_str).await.map_err(|e| {
-                CatalogError::ConnectionFailed(format!("Failed to connect to MySQL: {}", e))
-            })?);
-            Ok(Self {
-                connection_string: conn_str,
-                pool_size: DEFAULT_POOL_SIZE,
-                timeout_secs: DEFAULT_TIMEOUT_SECS,
-                pool,
-            })
-        }
-
-        #[cfg(not(feature = "mysql"))]
-        {
-            Ok(Self {
-                connection_string: conn_str,
-                pool_size: DEFAULT_POOL_SIZE,
-                timeout_secs: DEFAULT_TIMEOUT_SECS,
-            })
-        }
+        Self::with_pool_options(connection_string, PoolOptions::default()).await
     }
 
     /// Create a new LiveMySQLCatalog with custom configuration
@@ -132,7 +119,7 @@ impl LiveMySQLCatalog {
     /// # Arguments
     ///
     /// * `connection_string` - MySQL connection string
-    /// * `pool_size` - Connection pool size (default: 10)
+    /// * `pool_size` - Connection pool size (default: 4)
     /// * `timeout_secs` - Query timeout in seconds (default: 5)
     ///
     /// # Examples
@@ -149,30 +136,84 @@ impl LiveMySQLCatalog {
         pool_size: u32,
         timeout_secs: u64,
     ) -> CatalogResult<Self> {
-        let conn_str = connection_string.into();
-        Self::validate_connection_string(&conn_str)?;
+        let options = PoolOptions {
+            max_connections: pool_size,
+            statement_timeout_secs: timeout_secs,
+            ..Default::default()
+        };
+        Self::with_pool_options(connection_string, options).await
+    }
 
-        if pool_size == 0 {
-            return Err(CatalogError::ConfigurationError(
-                "pool_size must be greater than 0".to_string(),
-            ));
-        }
+    /// Create a new LiveMySQLCatalog with custom pool settings
+    ///
+    /// # Arguments
+    ///
+    /// * `connection_string` - MySQL connection string
+    /// * `options` - Connection pool settings
+    ///
+    /// # Returns
+    ///
+    /// Returns `Err(CatalogError::ConfigurationError)` if the connection string
+    /// or the pool settings are invalid.
+    pub async fn with_pool_options(
+        connection_string: impl Into<String>,
+        options: PoolOptions,
+    ) -> CatalogResult<Self> {
+        Self::with_connect_options(connection_string, options, None).await
+    }
 
-        if timeout_secs == 0 {
-            return Err(CatalogError::ConfigurationError(
-                "timeout_secs must be greater than 0".to_string(),
-            ));
+    /// Create a new LiveMySQLCatalog with custom pool and TLS settings
+    ///
+    /// # Arguments
+    ///
+    /// * `connection_string` - MySQL connection string
+    /// * `options` - Connection pool settings
+    /// * `tls` - TLS settings (`None` keeps the connection string's settings)
+    ///
+    /// # Returns
+    ///
+    /// Returns `Err(CatalogError::ConfigurationError)` if the connection string,
+    /// the pool settings or the TLS settings are invalid.
+    pub async fn with_connect_options(
+        connection_string: impl Into<String>,
+        options: PoolOptions,
+        tls: Option<TlsOptions>,
+    ) -> CatalogResult<Self> {
+        let conn_str = connection_string.into();
+        Self::validate_connection_string(&conn_str)?;
+        options.validate()?;
+        if let Some(tls) = &tls {
+            tls.validate()?;
         }
 
         #[cfg(feature = "mysql")]
         {
-            let pool = Some(Pool::<MySql>::connect(&conn_str).await.map_err(|e| {
-                CatalogError::ConnectionFailed(format!("Failed to connect to MySQL: {}", e))
-            })?);
+            let mut connect_options = MySqlConnectOptions::from_str(&conn_str).map_err(|e| {
+                CatalogError::ConfigurationError(format!(
+                    "Invalid MySQL connection string: {}",
+                    crate::redact::redact_credentials(&e.to_string())
+                ))
+            })?;
+            if let Some(tls) = &tls {
+                connect_options = tls.apply_mysql(connect_options);
+            }
+            let pool = Some(
+                options
+                    .to_sqlx::<MySql>()
+                    .connect_with(connect_options)
+                    .await
+                    .map_err(|e| {
+                        CatalogError::ConnectionFailed(format!(
+                            "Failed to connect to MySQL: {}",
+                            crate::redact::redact_credentials(&e.to_string())
+                        ))
+                    })?,
+            );
             Ok(Self {
                 connection_string: conn_str,
-                pool_size,
-                timeout_secs,
+                options,
+                tls,
+                databases: None,
                 pool,
             })
         }
@@ -181,8 +222,9 @@ impl LiveMySQLCatalog {
         {
             Ok(Self {
                 connection_string: conn_str,
-                pool_size,
-                timeout_secs,
+                options,
+                tls,
+                databases: None,
             })
         }
     }
@@ -216,12 +258,40 @@ impl LiveMySQLCatalog {
 
     /// Get the pool size
     pub fn pool_size(&self) -> u32 {
-        self.pool_size
+        self.options.max_connections
     }
 
     /// Get the timeout in seconds
     pub fn timeout_secs(&self) -> u64 {
-        self.timeout_secs
+        self.options.statement_timeout_secs
+    }
+
+    /// Get the connection pool settings
+    pub fn pool_options(&self) -> &PoolOptions {
+        &self.options
+    }
+
+    /// Get the TLS settings
+    pub fn tls_options(&self) -> Option<&TlsOptions> {
+        self.tls.as_ref()
+    }
+
+    /// Builder method: also list tables of other databases of the server
+    ///
+    /// Tables of the connected database are always listed. Tables of other
+    /// databases are referenced as `db.table`.
+    ///
+    /// # Arguments
+    ///
+    /// * `filter` - Databases to list (`None` lists the connected database only)
+    pub fn with_catalog_filter(mut self, filter: Option<CatalogFilter>) -> Self {
+        self.databases = filter;
+        self
+    }
+
+    /// Get the filter of other databases to list
+    pub fn catalog_filter(&self) -> Option<&CatalogFilter> {
+        self.databases.as_ref()
     }
 
     /// Parse MySQL data type to unified DataType
@@ -314,38 +384,69 @@ impl Catalog for LiveMySQLCatalog {
     /// List all tables in the database
     ///
     /// Queries information_schema.tables to get all tables, views, and materialized views.
+    /// With a catalog filter, tables of the selected other databases follow the
+    /// tables of the connected database.
     async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
         #[cfg(feature = "mysql")]
         if let Some(pool) = &self.pool {
             let query = r#"
                 SELECT
-                    CAST(TABLE_NAME AS CHAR) as table_name,
-                    CAST(TABLE_SCHEMA AS CHAR) as table_schema,
-                    CAST(TABLE_TYPE AS CHAR) as table_type,
-                    CAST(TABLE_COMMENT AS CHAR) as table_comment
-                FROM information_schema.TABLES
-                WHERE TABLE_SCHEMA = DATABASE()
-                  AND TABLE_TYPE IN ('BASE TABLE', 'VIEW')
-                ORDER BY TABLE_NAME
+                    CAST(t.TABLE_NAME AS CHAR) as table_name,
+                    CAST(t.TABLE_SCHEMA AS CHAR) as table_schema,
+                    CAST(t.TABLE_TYPE AS CHAR) as table_type,
+                    CAST(t.TABLE_COMMENT AS CHAR) as table_comment,
+                    CAST(t.TABLE_SCHEMA = DATABASE() AS SIGNED) as is_current,
+                    CAST(v.VIEW_DEFINITION AS CHAR) as view_definition
+                FROM information_schema.TABLES t
+                LEFT JOIN information_schema.VIEWS v
+                  ON v.TABLE_SCHEMA = t.TABLE_SCHEMA AND v.TABLE_NAME = t.TABLE_NAME
+                WHERE (t.TABLE_SCHEMA = DATABASE()
+                       OR (? AND t.TABLE_SCHEMA NOT IN
+                           ('information_schema', 'mysql', 'performance_schema', 'sys')))
+                  AND t.TABLE_TYPE IN ('BASE TABLE', 'VIEW')
+                ORDER BY is_current DESC, t.TABLE_SCHEMA, t.TABLE_NAME
             "#;
 
-            let rows = sqlx::query_as::<_, (String, String, String, Option<String>)>(query)
-                .fetch_all(pool)
-                .await
-                .map_err(|e| CatalogError::QueryFailed(format!("Failed to list tables: {}", e)))?;
+            // Name, schema, type, comment, current database flag, view query
+            type TableRow = (String, String, String, Option<String>, i64, Option<String>);
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<_, TableRow>(query)
+                        .bind(self.databases.is_some())
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| {
+                            CatalogError::QueryFailed(format!("Failed to list tables: {}", e))
+                        })
+                })
+                .await?;
 
             let tables = rows
                 .into_iter()
-                .map(|(name, schema, db_table_type, comment)| {
+                .filter(|(_, schema, _, _, is_current, _)| {
+                    *is_current == 1
+                        || self
+                            .databases
+                            .as_ref()
+                            .is_some_and(|filter| filter.matches(schema))
+                })
+                .map(|(name, schema, db_table_type, comment, _, definition)| {
                     let table_type = match db_table_type.as_str() {
                         "BASE TABLE" => TableType::Table,
                         "VIEW" => TableType::View,
                         _ => TableType::Other(db_table_type),
                     };
 
-                    TableMetadata::new(&name, &schema)
-                        .with_type(table_type)
-                        .with_comment(comment.unwrap_or_default())
+                    // Views report "VIEW" as their comment
+                    let comment = match table_type {
+                        TableType::View => None,
+                        _ => comment_text(comment),
+                    };
+                    let mut table = TableMetadata::new(&name, &schema).with_type(table_type);
+                    table.comment = comment;
+                    table.definition = definition;
+                    table
                 })
                 .collect();
 
@@ -367,63 +468,113 @@ impl Catalog for LiveMySQLCatalog {
 
     /// Get column metadata for a specific table
     ///
-    /// Queries information_schema.columns to get column information.
+    /// Queries information_schema.columns to get column information, and
+    /// information_schema.key_column_usage for foreign key references.
+    /// Tables of other databases are looked up as `db.table`.
     async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
         #[cfg(feature = "mysql")]
         if let Some(pool) = &self.pool {
+            let (database, name) = match table.split_once('.') {
+                Some((database, name)) => (Some(database), name),
+                None => (None, table),
+            };
+
             let query = r#"
                 SELECT
-                    CAST(COLUMN_NAME AS CHAR) as column_name,
-                    CAST(COLUMN_TYPE AS CHAR) as column_type,
-                    CAST(IS_NULLABLE AS CHAR) as is_nullable,
-                    CAST(COLUMN_DEFAULT AS CHAR) as column_default,
-                    CAST(COLUMN_COMMENT AS CHAR) as column_comment,
-                    CAST(COLUMN_KEY AS CHAR) as column_key
-                FROM information_schema.COLUMNS
-                WHERE TABLE_SCHEMA = DATABASE()
-                  AND TABLE_NAME = ?
-                ORDER BY ORDINAL_POSITION
+                    CAST(c.COLUMN_NAME AS CHAR) as column_name,
+                    CAST(c.COLUMN_TYPE AS CHAR) as column_type,
+                    CAST(c.IS_NULLABLE AS CHAR) as is_nullable,
+                    CAST(c.COLUMN_DEFAULT AS CHAR) as column_default,
+                    CAST(c.COLUMN_COMMENT AS CHAR) as column_comment,
+                    CAST(c.COLUMN_KEY AS CHAR) as column_key,
+                    CAST(fk.CONSTRAINT_NAME AS CHAR) as fk_constraint,
+                    CAST(fk.REFERENCED_TABLE_NAME AS CHAR) as fk_table,
+                    CAST(fk.REFERENCED_COLUMN_NAME AS CHAR) as fk_column
+                FROM information_schema.COLUMNS c
+                LEFT JOIN information_schema.KEY_COLUMN_USAGE fk
+                    ON fk.TABLE_SCHEMA = c.TABLE_SCHEMA
+                    AND fk.TABLE_NAME = c.TABLE_NAME
+                    AND fk.COLUMN_NAME = c.COLUMN_NAME
+                    AND fk.REFERENCED_TABLE_NAME IS NOT NULL
+                    AND fk.CONSTRAINT_NAME = (
+                        SELECT MIN(k.CONSTRAINT_NAME)
+                        FROM information_schema.KEY_COLUMN_USAGE k
+                        WHERE k.TABLE_SCHEMA = c.TABLE_SCHEMA
+                          AND k.TABLE_NAME = c.TABLE_NAME
+                          AND k.COLUMN_NAME = c.COLUMN_NAME
+                          AND k.REFERENCED_TABLE_NAME IS NOT NULL
+                    )
+                WHERE c.TABLE_SCHEMA = COALESCE(?, DATABASE())
+                  AND c.TABLE_NAME = ?
+                ORDER BY c.ORDINAL_POSITION
             "#;
 
-            let rows = sqlx::query_as::<
-                _,
-                (
-                    String,
-                    String,
-                    String,
-                    Option<String>,
-                    Option<String>,
-                    String,
-                ),
-            >(query)
-            .bind(table)
-            .fetch_all(pool)
-            .await
-            .map_err(|e| {
-                CatalogError::QueryFailed(format!(
-                    "Failed to get columns for table '{}': {}",
-                    table, e
-                ))
-            })?;
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<
+                        _,
+                        (
+                            String,
+                            String,
+                            String,
+                            Option<String>,
+                            Option<String>,
+                            String,
+                            Option<String>,
+                            Option<String>,
+                            Option<String>,
+                        ),
+                    >(query)
+                    .bind(database)
+                    .bind(name)
+                    .fetch_all(pool)
+                    .await
+                    .map_err(|e| {
+                        CatalogError::QueryFailed(format!(
+                            "Failed to get columns for table '{}': {}",
+                            table, e
+                        ))
+                    })
+                })
+                .await?;
 
             let columns: Vec<ColumnMetadata> = rows
                 .into_iter()
                 .map(
-                    |(name, column_type, is_nullable, _default, comment, column_key)| {
+                    |(
+                        name,
+                        column_type,
+                        is_nullable,
+                        _default,
+                        comment,
+                        column_key,
+                        fk_constraint,
+                        fk_table,
+                        fk_column,
+                    )| {
                         let dt = Self::parse_mysql_type(&column_type);
                         let nullable = is_nullable == "YES";
                         let is_pk = column_key == "PRI";
                         let is_fk = column_key == "MUL";
 
-                        let mut col = ColumnMetadata::new(name, dt)
-                            .with_nullable(nullable)
-                            .with_comment(comment.unwrap_or_default());
+                        let mut col = ColumnMetadata::new(name, dt).with_nullable(nullable);
+                        col.comment = comment_text(comment);
 
                         if is_pk {
                             col = col.with_primary_key();
                         }
-                        if is_fk {
-                            col = col.with_foreign_key("", "");
+                        match (fk_constraint, fk_table, fk_column) {
+                            // Declared foreign key constraint
+                            (Some(constraint), Some(ref_table), Some(ref_column)) => {
+                                col = col
+                                    .with_foreign_key_constraint(constraint, ref_table, ref_column);
+                            }
+                            // Indexed column without a declared constraint
+                            _ if is_fk => {
+                                col = col.with_foreign_key("", "");
+                            }
+                            _ => {}
                         }
 
                         col
@@ -448,6 +599,170 @@ impl Catalog for LiveMySQLCatalog {
         unreachable!()
     }
 
+    /// Get column metadata for many tables at once
+    ///
+    /// Reads the columns of all selected tables with one query and their
+    /// foreign keys with a second one, whatever the number of tables. Tables
+    /// are selected by `db.table` name; without a selection, tables of the
+    /// databases listed by [`Self::list_tables`] are read.
+    async fn list_columns(
+        &self,
+        tables: Option<&[String]>,
+    ) -> CatalogResult<HashMap<String, Vec<ColumnMetadata>>> {
+        #[cfg(feature = "mysql")]
+        if let Some(pool) = &self.pool {
+            // MySQL has no array parameters
+            let selection = match tables {
+                Some([]) => return Ok(HashMap::new()),
+                Some(tables) => format!(
+                    "AND CONCAT(c.TABLE_SCHEMA, '.', c.TABLE_NAME) IN ({})",
+                    vec!["?"; tables.len()].join(", ")
+                ),
+                None => String::new(),
+            };
+
+            let columns_query = format!(
+                r#"
+                SELECT
+                    CAST(c.TABLE_SCHEMA AS CHAR) as table_schema,
+                    CAST(c.TABLE_NAME AS CHAR) as table_name,
+                    CAST(c.COLUMN_NAME AS CHAR) as column_name,
+                    CAST(c.COLUMN_TYPE AS CHAR) as column_type,
+                    CAST(c.IS_NULLABLE AS CHAR) as is_nullable,
+                    CAST(c.COLUMN_COMMENT AS CHAR) as column_comment,
+                    CAST(c.COLUMN_KEY AS CHAR) as column_key
+                FROM information_schema.COLUMNS c
+                WHERE (c.TABLE_SCHEMA = DATABASE()
+                       OR (? AND c.TABLE_SCHEMA NOT IN
+                           ('information_schema', 'mysql', 'performance_schema', 'sys')))
+                  {}
+                ORDER BY c.TABLE_SCHEMA, c.TABLE_NAME, c.ORDINAL_POSITION
+            "#,
+                selection
+            );
+            let keys_query = format!(
+                r#"
+                SELECT
+                    CAST(c.TABLE_SCHEMA AS CHAR) as table_schema,
+                    CAST(c.TABLE_NAME AS CHAR) as table_name,
+                    CAST(c.COLUMN_NAME AS CHAR) as column_name,
+                    CAST(c.CONSTRAINT_NAME AS CHAR) as fk_constraint,
+                    CAST(c.REFERENCED_TABLE_NAME AS CHAR) as fk_table,
+                    CAST(c.REFERENCED_COLUMN_NAME AS CHAR) as fk_column
+                FROM information_schema.KEY_COLUMN_USAGE c
+                WHERE c.REFERENCED_TABLE_NAME IS NOT NULL
+                  AND (c.TABLE_SCHEMA = DATABASE()
+                       OR (? AND c.TABLE_SCHEMA NOT IN
+                           ('information_schema', 'mysql', 'performance_schema', 'sys')))
+                  {}
+                ORDER BY c.CONSTRAINT_NAME
+            "#,
+                selection
+            );
+
+            // Schema, table, name, type, nullable, comment, key
+            type ColumnRow = (
+                String,
+                String,
+                String,
+                String,
+                String,
+                Option<String>,
+                String,
+            );
+            let mut columns_query =
+                sqlx::query_as::<_, ColumnRow>(&columns_query).bind(self.databases.is_some());
+            // Schema, table, column, constraint, referenced table and column
+            type KeyRow = (String, String, String, String, String, String);
+            let mut keys_query =
+                sqlx::query_as::<_, KeyRow>(&keys_query).bind(self.databases.is_some());
+            for table in tables.unwrap_or_default() {
+                columns_query = columns_query.bind(table);
+                keys_query = keys_query.bind(table);
+            }
+
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    columns_query.fetch_all(pool).await.map_err(|e| {
+                        CatalogError::QueryFailed(format!("Failed to list columns: {}", e))
+                    })
+                })
+                .await?;
+            let keys = self
+                .options
+                .with_statement_timeout(async {
+                    keys_query.fetch_all(pool).await.map_err(|e| {
+                        CatalogError::QueryFailed(format!("Failed to list foreign keys: {}", e))
+                    })
+                })
+                .await?;
+
+            // Same databases as `list_tables`
+            let listed = |schema: &str| {
+                tables.is_some()
+                    || self
+                        .databases
+                        .as_ref()
+                        .is_none_or(|filter| filter.matches(schema))
+            };
+
+            let mut columns: HashMap<String, Vec<ColumnMetadata>> = HashMap::new();
+            for (schema, table, name, column_type, is_nullable, comment, column_key) in rows {
+                if !listed(&schema) {
+                    continue;
+                }
+                let mut column = ColumnMetadata::new(name, Self::parse_mysql_type(&column_type))
+                    .with_nullable(is_nullable == "YES");
+                column.comment = comment_text(comment);
+                match column_key.as_str() {
+                    "PRI" => column = column.with_primary_key(),
+                    // Indexed column, until a declared constraint is found
+                    "MUL" => column = column.with_foreign_key("", ""),
+                    _ => {}
+                }
+                columns
+                    .entry(format!("{}.{}", schema, table))
+                    .or_default()
+                    .push(column);
+            }
+
+            for (schema, table, name, constraint, ref_table, ref_column) in keys {
+                let Some(column) = columns
+                    .get_mut(&format!("{}.{}", schema, table))
+                    .and_then(|columns| columns.iter_mut().find(|c| c.name == name))
+                else {
+                    continue;
+                };
+                // A column in several foreign keys keeps the first by name
+                if column
+                    .references
+                    .as_ref()
+                    .is_none_or(|reference| reference.constraint.is_none())
+                {
+                    *column = column
+                        .clone()
+                        .with_foreign_key_constraint(constraint, ref_table, ref_column);
+                }
+            }
+
+            return Ok(columns);
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "mysql"))]
+        return Err(CatalogError::NotSupported(format!(
+            "list_columns requires 'mysql' feature enabled ({} tables)",
+            tables.map_or(0, <[String]>::len)
+        )));
+
+        #[cfg(all(feature = "mysql", not(feature = "mysql")))]
+        unreachable!()
+    }
+
     /// List all available functions
     ///
     /// Returns a list of built-in MySQL functions and custom stored procedures/functions.
@@ -469,24 +784,46 @@ impl Catalog for LiveMySQLCatalog {
                     name as function_name,
                     param_list as parameters,
                     returns as return_type,
-                    db as schema_name
+                    db as schema_name,
+                    CAST(comment AS CHAR) as function_comment,
+                    CAST(body AS CHAR) as function_body
                 FROM mysql.proc
                 WHERE db = DATABASE()
                   AND type IN ('FUNCTION', 'PROCEDURE')
             "#;
 
-            let custom_funcs: Vec<FunctionMetadata> =
-                sqlx::query_as::<_, (String, String, String, String)>(custom_query)
-                    .fetch_all(pool)
-                    .await
-                    .unwrap_or(vec![]) // Don't fail if mysql.proc not accessible
-                    .into_iter()
-                    .map(|(name, _params, ret, schema)| {
-                        FunctionMetadata::new(&name, Self::parse_mysql_type(&ret))
-                            .with_type(FunctionType::Scalar)
-                            .with_description(format!("Custom function from {}", schema))
-                    })
-                    .collect();
+            // Name, parameters, return type, schema, comment, body
+            type FunctionRow = (
+                String,
+                String,
+                String,
+                String,
+                Option<String>,
+                Option<String>,
+            );
+
+            let custom_funcs: Vec<FunctionMetadata> = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<_, FunctionRow>(custom_query)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| CatalogError::QueryFailed(e.to_string()))
+                })
+                .await
+                .unwrap_or(vec![]) // Don't fail if mysql.proc not accessible
+                .into_iter()
+                .map(|(name, _params, ret, schema, comment, body)| {
+                    let mut function = FunctionMetadata::new(&name, Self::parse_mysql_type(&ret))
+                        .with_type(FunctionType::Scalar)
+                        .with_description(
+                            comment_text(comment)
+                                .unwrap_or_else(|| format!("Custom function from {}", schema)),
+                        );
+                    function.definition = body;
+                    function
+                })
+                .collect();
 
             all_functions.extend(custom_funcs);
         }
@@ -495,6 +832,228 @@ impl Catalog for LiveMySQLCatalog {
     }
 }
 
+#[async_trait]
+impl QueryExecutor for LiveMySQLCatalog {
+    /// Execute a single statement as a prepared statement
+    ///
+    /// MySQL refuses to prepare input containing several statements, so this
+    /// never runs more than one statement.
+    async fn execute(&self, sql: &str) -> CatalogResult<QueryResult> {
+        #[cfg(feature = "mysql")]
+        if let Some(pool) = &self.pool {
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query(sql)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| CatalogError::QueryFailed(e.to_string()))
+                })
+                .await?;
+
+            let columns = rows
+                .first()
+                .map(|row| row.columns().iter().map(|c| c.name().to_string()).collect())
+                .unwrap_or_default();
+            let rows = rows
+                .iter()
+                .map(|row| (0..row.len()).map(|i| Self::cell_text(row, i)).collect())
+                .collect();
+
+            return Ok(QueryResult::new(columns, rows));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "mysql"))]
+        return Err(CatalogError::NotSupported(format!(
+            "executing '{}' requires 'mysql' feature enabled",
+            sql
+        )));
+
+        #[cfg(all(feature = "mysql", not(feature = "mysql")))]
+        unreachable!()
+    }
+
+    /// Ping a pooled connection
+    async fn ping(&self) -> CatalogResult<()> {
+        #[cfg(feature = "mysql")]
+        if let Some(pool) = &self.pool {
+            let mut connection = pool
+                .acquire()
+                .await
+                .map_err(|e| CatalogError::ConnectionFailed(e.to_string()))?;
+            return connection
+                .ping()
+                .await
+                .map_err(|e| CatalogError::ConnectionFailed(e.to_string()));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "mysql"))]
+        return Err(CatalogError::NotSupported(
+            "ping requires 'mysql' feature enabled".to_string(),
+        ));
+
+        #[cfg(all(feature = "mysql", not(feature = "mysql")))]
+        unreachable!()
+    }
+
+    /// Open a session on a pooled connection
+    ///
+    /// Transactional sessions begin a transaction, which is rolled back if the
+    /// session is dropped without committing.
+    async fn session(&self, transactional: bool) -> CatalogResult<Box<dyn QuerySession>> {
+        #[cfg(feature = "mysql")]
+        if let Some(pool) = &self.pool {
+            let connection = if transactional {
+                MySqlSessionConnection::Transaction(pool.begin().await.map_err(|e| {
+                    CatalogError::ConnectionFailed(format!("Failed to begin transaction: {}", e))
+                })?)
+            } else {
+                MySqlSessionConnection::Autocommit(pool.acquire().await.map_err(|e| {
+                    CatalogError::ConnectionFailed(format!("Failed to acquire connection: {}", e))
+                })?)
+            };
+            return Ok(Box::new(MySqlSession { connection }));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "mysql"))]
+        return Err(CatalogError::NotSupported(format!(
+            "{} sessions require 'mysql' feature enabled",
+            if transactional {
+                "transactional"
+            } else {
+                "autocommit"
+            }
+        )));
+
+        #[cfg(all(feature = "mysql", not(feature = "mysql")))]
+        unreachable!()
+    }
+}
+
+/// Connection held by a MySQL query session
+#[cfg(feature = "mysql")]
+enum MySqlSessionConnection {
+    /// Statements run in a transaction, rolled back on drop
+    Transaction(sqlx::Transaction<'static, MySql>),
+    /// Every statement commits on its own
+    Autocommit(sqlx::pool::PoolConnection<MySql>),
+}
+
+/// Query session on a pooled MySQL connection
+#[cfg(feature = "mysql")]
+struct MySqlSession {
+    connection: MySqlSessionConnection,
+}
+
+#[cfg(feature = "mysql")]
+impl MySqlSession {
+    /// The underlying connection
+    fn connection(&mut self) -> &mut sqlx::MySqlConnection {
+        match &mut self.connection {
+            MySqlSessionConnection::Transaction(tx) => &mut **tx,
+            MySqlSessionConnection::Autocommit(conn) => &mut **conn,
+        }
+    }
+}
+
+#[cfg(feature = "mysql")]
+#[async_trait]
+impl QuerySession for MySqlSession {
+    async fn fetch(&mut self, sql: &str, max_rows: usize) -> CatalogResult<QueryResult> {
+        let mut stream = sqlx::query(sql).fetch(self.connection());
+        let mut columns = Vec::new();
+        let mut rows = Vec::new();
+        let mut truncated = false;
+
+        while let Some(row) = stream
+            .try_next()
+            .await
+            .map_err(|e| CatalogError::QueryFailed(e.to_string()))?
+        {
+            if rows.len() == max_rows {
+                truncated = true;
+                break;
+            }
+            if columns.is_empty() {
+                columns = row.columns().iter().map(|c| c.name().to_string()).collect();
+            }
+            rows.push(
+                (0..row.len())
+                    .map(|i| LiveMySQLCatalog::cell_text(&row, i))
+                    .collect(),
+            );
+        }
+
+        Ok(QueryResult::new(columns, rows).with_truncated(truncated))
+    }
+
+    async fn execute(&mut self, sql: &str) -> CatalogResult<u64> {
+        sqlx::query(sql)
+            .execute(self.connection())
+            .await
+            .map(|result| result.rows_affected())
+            .map_err(|e| CatalogError::QueryFailed(e.to_string()))
+    }
+
+    fn is_transactional(&self) -> bool {
+        matches!(self.connection, MySqlSessionConnection::Transaction(_))
+    }
+
+    async fn commit(self: Box<Self>) -> CatalogResult<()> {
+        match self.connection {
+            MySqlSessionConnection::Transaction(tx) => tx
+                .commit()
+                .await
+                .map_err(|e| CatalogError::QueryFailed(format!("Failed to commit: {}", e))),
+            MySqlSessionConnection::Autocommit(_) => Ok(()),
+        }
+    }
+
+    async fn rollback(self: Box<Self>) -> CatalogResult<()> {
+        match self.connection {
+            MySqlSessionConnection::Transaction(tx) => tx
+                .rollback()
+                .await
+                .map_err(|e| CatalogError::QueryFailed(format!("Failed to roll back: {}", e))),
+            MySqlSessionConnection::Autocommit(_) => Ok(()),
+        }
+    }
+}
+
+#[cfg(feature = "mysql")]
+impl LiveMySQLCatalog {
+    /// Render a result cell as text, trying the common MySQL types in turn
+    fn cell_text(row: &MySqlRow, index: usize) -> Option<String> {
+        if row.try_get_raw(index).map_or(true, |v| v.is_null()) {
+            return None;
+        }
+
+        row.try_get::<String, _>(index)
+            .ok()
+            .or_else(|| row.try_get::<i64, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<u64, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<f64, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| {
+                row.try_get::<Vec<u8>, _>(index)
+                    .ok()
+                    .map(|v| String::from_utf8_lossy(&v).into_owned())
+            })
+            .or_else(|| Some(format!("<{}>", row.columns()[index].type_info())))
+    }
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
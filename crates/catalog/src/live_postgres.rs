@@ -10,10 +10,11 @@
 //!
 //! ## Features
 //!
-//! - Connection pooling with configurable size (default: 10 connections)
+//! - Connection pooling with configurable size and lifetimes (default: 4 connections)
 //! - Query timeout support (default: 5 seconds)
 //! - Health checks for connection validation
 //! - Real-time schema queries from information_schema
+//! - Table versions from pg_catalog for incremental schema refresh
 //!
 //! ## Usage
 //!
@@ -37,22 +38,28 @@
 //! ```
 
 use crate::error::{CatalogError, CatalogResult};
+use crate::executor::{QueryExecutor, QueryResult, QuerySession};
 use crate::metadata::{ColumnMetadata, DataType, FunctionMetadata, FunctionType, TableMetadata};
+use crate::pool::PoolOptions;
+use crate::redact::RedactedDsn;
+use crate::refresh::TableVersion;
+use crate::tls::TlsOptions;
 use crate::r#trait::Catalog;
 
 use async_trait::async_trait;
+use std::collections::HashMap;
 
 #[cfg(feature = "postgresql")]
-use crate::metadata::TableType;
+use crate::metadata::{TableType, comment_text};
 
 #[cfg(feature = "postgresql")]
-use sqlx::{Pool, Postgres};
-
-/// Default connection pool size
-const DEFAULT_POOL_SIZE: u32 = 10;
-
-/// Default query timeout in seconds
-const DEFAULT_TIMEOUT_SECS: u64 = 5;
+use futures_util::TryStreamExt;
+#[cfg(feature = "postgresql")]
+use sqlx::postgres::{PgConnectOptions, PgRow};
+#[cfg(feature = "postgresql")]
+use sqlx::{Column, Connection, Pool, Postgres, Row, ValueRef};
+#[cfg(feature = "postgresql")]
+use std::str::FromStr;
 
 /// Live PostgreSQL Catalog implementation
 ///
@@ -62,10 +69,10 @@ const DEFAULT_TIMEOUT_SECS: u64 = 5;
 pub struct LivePostgreSQLCatalog {
     /// PostgreSQL connection string
     connection_string: String,
-    /// Connection pool size
-    pool_size: u32,
-    /// Query timeout in seconds
-    timeout_secs: u64,
+    /// Connection pool settings
+    options: PoolOptions,
+    /// TLS settings
+    tls: Option<TlsOptions>,
     /// Connection pool
     pool: Option<Pool<Postgres>>,
 }
@@ -75,10 +82,10 @@ pub struct LivePostgreSQLCatalog {
 pub struct LivePostgreSQLCatalog {
     /// PostgreSQL connection string
     connection_string: String,
-    /// Connection pool size
-    pool_size: u32,
-    /// Query timeout in seconds
-    timeout_secs: u64,
+    /// Connection pool settings
+    options: PoolOptions,
+    /// TLS settings
+    tls: Option<TlsOptions>,
 }
 
 impl LivePostgreSQLCatalog {
@@ -101,43 +108,7 @@ impl LivePostgreSQLCatalog {
     /// ).await?;
     /// ```
     pub async fn new(connection_string: impl Into<String>) -> CatalogResult<Self> {
-        let conn_str = connection_string.into();
-        tracing::info!("!!! LivePostgreSQLCatalog::new() called with: {}", conn_str);
-        eprintln!("!!! LivePostgreSQLCatalog::new() called with: {}", conn_str);
-        Self::validate_connection_string(&conn_str)?;
-
-        #[cfg(feature = "postgresql")]
-        {
-            tracing::debug!("!!! Creating PostgreSQL connection pool...");
-            eprintln!("!!! Creating PostgreSQL connection pool...");
-            let pool = Pool::<Postgres>::connect(&conn_str).await.map_err(|e| {
-                let err_msg = format!("!!! Failed to connect to PostgreSQL: {}", e);
-                eprintln!("{}", err_msg);
-                tracing::error!("{}", err_msg);
-                CatalogError::ConnectionFailed(format!("Failed to connect to PostgreSQL: {}", e))
-            })?;
-            let success_msg = "!!! PostgreSQL connection pool created successfully";
-            eprintln!("{}", success_msg);
-            tracing::info!("{}", success_msg);
-            Ok(Self {
-                connection_string: conn_str,
-                pool_size: DEFAULT_POOL_SIZE,
-                timeout_secs: DEFAULT_TIMEOUT_SECS,
-                pool: Some(pool),
-            })
-        }
-
-        #[cfg(not(feature = "postgresql"))]
-        {
-            let warn_msg = "!!! postgresql feature NOT enabled, returning stub";
-            eprintln!("{}", warn_msg);
-            tracing::warn!("{}", warn_msg);
-            Ok(Self {
-                connection_string: conn_str,
-                pool_size: DEFAULT_POOL_SIZE,
-                timeout_secs: DEFAULT_TIMEOUT_SECS,
-            })
-        }
+        Self::with_pool_options(connection_string, PoolOptions::default()).await
     }
 
     /// Create a new LivePostgreSQLCatalog with custom configuration
@@ -145,7 +116,7 @@ impl LivePostgreSQLCatalog {
     /// # Arguments
     ///
     /// * `connection_string` - PostgreSQL connection string
-    /// * `pool_size` - Connection pool size (default: 10)
+    /// * `pool_size` - Connection pool size (default: 4)
     /// * `timeout_secs` - Query timeout in seconds (default: 5)
     ///
     /// # Examples
@@ -162,40 +133,111 @@ impl LivePostgreSQLCatalog {
         pool_size: u32,
         timeout_secs: u64,
     ) -> CatalogResult<Self> {
-        let conn_str = connection_string.into();
-        Self::validate_connection_string(&conn_str)?;
+        let options = PoolOptions {
+            max_connections: pool_size,
+            statement_timeout_secs: timeout_secs,
+            ..Default::default()
+        };
+        Self::with_pool_options(connection_string, options).await
+    }
 
-        if pool_size == 0 {
-            return Err(CatalogError::ConfigurationError(
-                "pool_size must be greater than 0".to_string(),
-            ));
-        }
+    /// Create a new LivePostgreSQLCatalog with custom pool settings
+    ///
+    /// # Arguments
+    ///
+    /// * `connection_string` - PostgreSQL connection string
+    /// * `options` - Connection pool settings
+    ///
+    /// # Returns
+    ///
+    /// Returns `Err(CatalogError::ConfigurationError)` if the connection string
+    /// or the pool settings are invalid.
+    pub async fn with_pool_options(
+        connection_string: impl Into<String>,
+        options: PoolOptions,
+    ) -> CatalogResult<Self> {
+        Self::with_connect_options(connection_string, options, None).await
+    }
 
-        if timeout_secs == 0 {
-            return Err(CatalogError::ConfigurationError(
-                "timeout_secs must be greater than 0".to_string(),
-            ));
+    /// Create a new LivePostgreSQLCatalog with custom pool and TLS settings
+    ///
+    /// # Arguments
+    ///
+    /// * `connection_string` - PostgreSQL connection string
+    /// * `options` - Connection pool settings
+    /// * `tls` - TLS settings (`None` keeps the connection string's settings)
+    ///
+    /// # Returns
+    ///
+    /// Returns `Err(CatalogError::ConfigurationError)` if the connection string,
+    /// the pool settings or the TLS settings are invalid.
+    pub async fn with_connect_options(
+        connection_string: impl Into<String>,
+        options: PoolOptions,
+        tls: Option<TlsOptions>,
+    ) -> CatalogResult<Self> {
+        let conn_str = connection_string.into();
+        tracing::info!(
+            "!!! LivePostgreSQLCatalog::new() called with: {}",
+            RedactedDsn(&conn_str)
+        );
+        eprintln!(
+            "!!! LivePostgreSQLCatalog::new() called with: {}",
+            RedactedDsn(&conn_str)
+        );
+        Self::validate_connection_string(&conn_str)?;
+        options.validate()?;
+        if let Some(tls) = &tls {
+            tls.validate()?;
         }
 
         #[cfg(feature = "postgresql")]
         {
-            let pool = Some(Pool::<Postgres>::connect(&conn_str).await.map_err(|e| {
-                CatalogError::ConnectionFailed(format!("Failed to connect to PostgreSQL: {}", e))
-            })?);
+            tracing::debug!("!!! Creating PostgreSQL connection pool...");
+            eprintln!("!!! Creating PostgreSQL connection pool...");
+            let mut connect_options = PgConnectOptions::from_str(&conn_str).map_err(|e| {
+                CatalogError::ConfigurationError(format!(
+                    "Invalid PostgreSQL connection string: {}",
+                    crate::redact::redact_credentials(&e.to_string())
+                ))
+            })?;
+            if let Some(tls) = &tls {
+                connect_options = tls.apply_postgres(connect_options);
+            }
+            let pool = options
+                .to_sqlx::<Postgres>()
+                .connect_with(connect_options)
+                .await
+                .map_err(|e| {
+                    let error = crate::redact::redact_credentials(&e.to_string());
+                    let err_msg = format!("!!! Failed to connect to PostgreSQL: {}", error);
+                    eprintln!("{}", err_msg);
+                    tracing::error!("{}", err_msg);
+                    CatalogError::ConnectionFailed(format!(
+                        "Failed to connect to PostgreSQL: {}",
+                        error
+                    ))
+                })?;
+            let success_msg = "!!! PostgreSQL connection pool created successfully";
+            eprintln!("{}", success_msg);
+            tracing::info!("{}", success_msg);
             Ok(Self {
                 connection_string: conn_str,
-                pool_size,
-                timeout_secs,
-                pool,
+                options,
+                tls,
+                pool: Some(pool),
             })
         }
 
         #[cfg(not(feature = "postgresql"))]
         {
+            let warn_msg = "!!! postgresql feature NOT enabled, returning stub";
+            eprintln!("{}", warn_msg);
+            tracing::warn!("{}", warn_msg);
             Ok(Self {
                 connection_string: conn_str,
-                pool_size,
-                timeout_secs,
+                options,
+                tls,
             })
         }
     }
@@ -229,12 +271,71 @@ impl LivePostgreSQLCatalog {
 
     /// Get the pool size
     pub fn pool_size(&self) -> u32 {
-        self.pool_size
+        self.options.max_connections
     }
 
     /// Get the timeout in seconds
     pub fn timeout_secs(&self) -> u64 {
-        self.timeout_secs
+        self.options.statement_timeout_secs
+    }
+
+    /// Get the connection pool settings
+    pub fn pool_options(&self) -> &PoolOptions {
+        &self.options
+    }
+
+    /// Get the TLS settings
+    pub fn tls_options(&self) -> Option<&TlsOptions> {
+        self.tls.as_ref()
+    }
+
+    /// List the other databases of the server the user may connect to
+    ///
+    /// Template databases and databases that refuse connections are skipped.
+    /// Combine the catalogs of these databases with a
+    /// [`MultiCatalog`](crate::MultiCatalog) to reference their tables as
+    /// `db.schema.table`.
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::QueryFailed` if the query fails.
+    pub async fn list_databases(&self) -> CatalogResult<Vec<String>> {
+        #[cfg(feature = "postgresql")]
+        if let Some(pool) = &self.pool {
+            let query = r#"
+                SELECT datname
+                FROM pg_catalog.pg_database
+                WHERE datallowconn
+                  AND NOT datistemplate
+                  AND datname <> current_database()
+                  AND has_database_privilege(datname, 'CONNECT')
+                ORDER BY datname
+            "#;
+
+            return self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_scalar::<_, String>(query)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| {
+                            CatalogError::QueryFailed(format!("Failed to list databases: {}", e))
+                        })
+                })
+                .await;
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "postgresql"))]
+        return Err(CatalogError::NotSupported(
+            "list_databases requires 'postgresql' feature enabled".to_string(),
+        ));
+
+        #[cfg(all(feature = "postgresql", not(feature = "postgresql")))]
+        unreachable!()
     }
 
     /// Parse PostgreSQL data type to unified DataType
@@ -380,29 +481,41 @@ impl Catalog for LivePostgreSQLCatalog {
                             WHEN t.table_type = 'MATERIALIZED VIEW' THEN 'materialized'
                             ELSE 'other'
                         END as table_type,
-                        obj_description((t.table_schema||'.'||t.table_name)::regclass, 'pg_class') as table_comment
+                        obj_description((t.table_schema||'.'||t.table_name)::regclass, 'pg_class') as table_comment,
+                        COALESCE(v.definition, m.definition) as view_definition
                     FROM information_schema.tables t
+                    LEFT JOIN pg_catalog.pg_views v
+                      ON v.schemaname = t.table_schema AND v.viewname = t.table_name
+                    LEFT JOIN pg_catalog.pg_matviews m
+                      ON m.schemaname = t.table_schema AND m.matviewname = t.table_name
                     WHERE t.table_schema NOT IN ('pg_catalog', 'information_schema')
                       AND t.table_type IN ('BASE TABLE', 'VIEW', 'MATERIALIZED VIEW')
                     ORDER BY t.table_schema, t.table_name
                 "#;
 
-                let rows = sqlx::query_as::<_, (String, String, String, Option<String>)>(query)
-                    .fetch_all(pool)
-                    .await
-                    .map_err(|e| {
-                        let err_msg = format!("!!! Failed to list tables: {}", e);
-                        eprintln!("{}", err_msg);
-                        tracing::error!("{}", err_msg);
-                        CatalogError::QueryFailed(format!("Failed to list tables: {}", e))
-                    })?;
+                // Name, schema, type, comment, view query
+                type TableRow = (String, String, String, Option<String>, Option<String>);
+                let rows = self
+                    .options
+                    .with_statement_timeout(async {
+                        sqlx::query_as::<_, TableRow>(query)
+                            .fetch_all(pool)
+                            .await
+                            .map_err(|e| {
+                                let err_msg = format!("!!! Failed to list tables: {}", e);
+                                eprintln!("{}", err_msg);
+                                tracing::error!("{}", err_msg);
+                                CatalogError::QueryFailed(format!("Failed to list tables: {}", e))
+                            })
+                    })
+                    .await?;
 
                 eprintln!("!!! Query returned {} rows", rows.len());
                 tracing::debug!("!!! Query returned {} rows", rows.len());
 
                 let tables: Vec<TableMetadata> = rows
                     .into_iter()
-                    .map(|(name, schema, db_table_type, comment)| {
+                    .map(|(name, schema, db_table_type, comment, definition)| {
                         eprintln!(
                             "!!! Found table: {}.{} (type: {})",
                             schema, name, db_table_type
@@ -420,9 +533,10 @@ impl Catalog for LivePostgreSQLCatalog {
                             _ => TableType::Other(db_table_type),
                         };
 
-                        TableMetadata::new(&name, &schema)
-                            .with_type(table_type)
-                            .with_comment(comment.unwrap_or_default())
+                        let mut table = TableMetadata::new(&name, &schema).with_type(table_type);
+                        table.comment = comment_text(comment);
+                        table.definition = definition;
+                        table
                     })
                     .collect();
 
@@ -456,6 +570,7 @@ impl Catalog for LivePostgreSQLCatalog {
     /// Get column metadata for a specific table
     ///
     /// Queries information_schema.columns and pg_catalog to get column information.
+    /// The table may be qualified as `schema.table`.
     async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
         tracing::debug!(
             "!!! LivePostgreSQLCatalog::get_columns() called for table: {}",
@@ -464,6 +579,11 @@ impl Catalog for LivePostgreSQLCatalog {
 
         #[cfg(feature = "postgresql")]
         if let Some(pool) = &self.pool {
+            let (schema, name) = match table.split_once('.') {
+                Some((schema, name)) => (Some(schema), name),
+                None => (None, table),
+            };
+
             tracing::debug!("!!! Pool is available, executing get_columns query");
             let query = r#"
                 SELECT
@@ -486,35 +606,42 @@ impl Catalog for LivePostgreSQLCatalog {
                     JOIN information_schema.key_column_usage ku
                         ON tc.constraint_name = ku.constraint_name
                     WHERE tc.constraint_type = 'PRIMARY KEY'
-                        AND tc.table_schema = 'public'
+                        AND tc.table_schema = COALESCE($2, 'public')
                         AND tc.table_name = $1
                 ) pk ON pk.column_name = c.column_name
                 WHERE c.table_schema NOT IN ('pg_catalog', 'information_schema')
+                  AND ($2::text IS NULL OR c.table_schema = $2)
                   AND c.table_name = $1
                 ORDER BY c.ordinal_position
             "#;
 
-            let rows = sqlx::query_as::<
-                _,
-                (
-                    String,
-                    String,
-                    String,
-                    Option<String>,
-                    Option<String>,
-                    String,
-                ),
-            >(query)
-            .bind(table)
-            .fetch_all(pool)
-            .await
-            .map_err(|e| {
-                tracing::error!("!!! Failed to get columns: {}", e);
-                CatalogError::QueryFailed(format!(
-                    "Failed to get columns for table '{}': {}",
-                    table, e
-                ))
-            })?;
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<
+                        _,
+                        (
+                            String,
+                            String,
+                            String,
+                            Option<String>,
+                            Option<String>,
+                            String,
+                        ),
+                    >(query)
+                    .bind(name)
+                    .bind(schema)
+                    .fetch_all(pool)
+                    .await
+                    .map_err(|e| {
+                        tracing::error!("!!! Failed to get columns: {}", e);
+                        CatalogError::QueryFailed(format!(
+                            "Failed to get columns for table '{}': {}",
+                            table, e
+                        ))
+                    })
+                })
+                .await?;
 
             tracing::debug!("!!! get_columns query returned {} rows", rows.len());
 
@@ -526,9 +653,8 @@ impl Catalog for LivePostgreSQLCatalog {
                     let nullable = is_nullable == "YES";
                     let is_pk = is_pk == "YES";
 
-                    let mut col = ColumnMetadata::new(name, dt)
-                        .with_nullable(nullable)
-                        .with_comment(comment.unwrap_or_default());
+                    let mut col = ColumnMetadata::new(name, dt).with_nullable(nullable);
+                    col.comment = comment_text(comment);
 
                     if is_pk {
                         col = col.with_primary_key();
@@ -555,6 +681,147 @@ impl Catalog for LivePostgreSQLCatalog {
         unreachable!()
     }
 
+    /// Get column metadata for many tables at once
+    ///
+    /// Reads the columns of all selected tables with one query and their
+    /// primary and foreign keys with a second one, whatever the number of
+    /// tables. Tables are selected by `schema.table` name.
+    async fn list_columns(
+        &self,
+        tables: Option<&[String]>,
+    ) -> CatalogResult<HashMap<String, Vec<ColumnMetadata>>> {
+        #[cfg(feature = "postgresql")]
+        if let Some(pool) = &self.pool {
+            let tables = tables.map(<[String]>::to_vec);
+
+            let columns_query = r#"
+                SELECT
+                    c.table_schema,
+                    c.table_name,
+                    c.column_name,
+                    c.data_type,
+                    c.is_nullable,
+                    col_description(
+                        format('%I.%I', c.table_schema, c.table_name)::regclass,
+                        c.ordinal_position
+                    ) as column_comment
+                FROM information_schema.columns c
+                WHERE c.table_schema NOT IN ('pg_catalog', 'information_schema')
+                  AND ($1::text[] IS NULL OR c.table_schema || '.' || c.table_name = ANY($1))
+                ORDER BY c.table_schema, c.table_name, c.ordinal_position
+            "#;
+
+            // Schema, table, name, type, nullable, comment
+            type ColumnRow = (String, String, String, String, String, Option<String>);
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<_, ColumnRow>(columns_query)
+                        .bind(&tables)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| {
+                            CatalogError::QueryFailed(format!("Failed to list columns: {}", e))
+                        })
+                })
+                .await?;
+
+            // Multi-column keys pair each column with its referenced column
+            let keys_query = r#"
+                SELECT
+                    n.nspname::text AS table_schema,
+                    c.relname::text AS table_name,
+                    a.attname::text AS column_name,
+                    con.contype::text AS constraint_type,
+                    con.conname::text AS constraint_name,
+                    rc.relname::text AS ref_table,
+                    ra.attname::text AS ref_column
+                FROM pg_catalog.pg_constraint con
+                JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+                JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+                CROSS JOIN LATERAL unnest(con.conkey, COALESCE(con.confkey, con.conkey))
+                    AS k(attnum, ref_attnum)
+                JOIN pg_catalog.pg_attribute a
+                    ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+                LEFT JOIN pg_catalog.pg_class rc ON rc.oid = con.confrelid
+                LEFT JOIN pg_catalog.pg_attribute ra
+                    ON ra.attrelid = con.confrelid AND ra.attnum = k.ref_attnum
+                WHERE con.contype IN ('p', 'f')
+                  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+                  AND ($1::text[] IS NULL OR n.nspname || '.' || c.relname = ANY($1))
+                ORDER BY con.conname
+            "#;
+
+            // Schema, table, column, `p` or `f`, constraint, referenced table and column
+            type KeyRow = (
+                String,
+                String,
+                String,
+                String,
+                String,
+                Option<String>,
+                Option<String>,
+            );
+            let keys = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<_, KeyRow>(keys_query)
+                        .bind(&tables)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| {
+                            CatalogError::QueryFailed(format!("Failed to list keys: {}", e))
+                        })
+                })
+                .await?;
+
+            let mut columns: HashMap<String, Vec<ColumnMetadata>> = HashMap::new();
+            for (schema, table, name, data_type, is_nullable, comment) in rows {
+                let mut column = ColumnMetadata::new(name, Self::parse_postgres_type(&data_type))
+                    .with_nullable(is_nullable == "YES");
+                column.comment = comment_text(comment);
+                columns
+                    .entry(format!("{}.{}", schema, table))
+                    .or_default()
+                    .push(column);
+            }
+
+            for (schema, table, name, kind, constraint, ref_table, ref_column) in keys {
+                let Some(column) = columns
+                    .get_mut(&format!("{}.{}", schema, table))
+                    .and_then(|columns| columns.iter_mut().find(|c| c.name == name))
+                else {
+                    continue;
+                };
+                match (kind.as_str(), ref_table, ref_column) {
+                    ("p", _, _) => column.is_primary_key = true,
+                    // A column in several foreign keys keeps the first by name
+                    ("f", Some(ref_table), Some(ref_column)) if !column.is_foreign_key => {
+                        *column = column
+                            .clone()
+                            .with_foreign_key_constraint(constraint, ref_table, ref_column);
+                    }
+                    _ => {}
+                }
+            }
+
+            return Ok(columns);
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "postgresql"))]
+        return Err(CatalogError::NotSupported(format!(
+            "list_columns requires 'postgresql' feature enabled ({} tables)",
+            tables.map_or(0, <[String]>::len)
+        )));
+
+        #[cfg(all(feature = "postgresql", not(feature = "postgresql")))]
+        unreachable!()
+    }
+
     /// List all available functions
     ///
     /// Returns a list of built-in PostgreSQL functions and custom functions.
@@ -576,30 +843,342 @@ impl Catalog for LivePostgreSQLCatalog {
                     p.proname as function_name,
                     pg_get_function_result(p.oid) as return_type,
                     pg_get_function_arguments(p.oid) as arguments,
-                    n.nspname as schema_name
+                    n.nspname as schema_name,
+                    obj_description(p.oid, 'pg_proc') as function_comment,
+                    CASE WHEN l.lanname IN ('sql', 'plpgsql') THEN p.prosrc END as function_body
                 FROM pg_catalog.pg_proc p
                 JOIN pg_catalog.pg_namespace n ON p.pronamespace = n.oid
+                JOIN pg_catalog.pg_language l ON p.prolang = l.oid
                 WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
             "#;
 
-            let custom_funcs: Vec<FunctionMetadata> =
-                sqlx::query_as::<_, (String, String, String, String)>(custom_query)
-                    .fetch_all(pool)
-                    .await
-                    .unwrap_or(vec![]) // Don't fail if pg_proc not accessible
-                    .into_iter()
-                    .map(|(name, ret, _args, schema)| {
+            // Name, return type, arguments, schema, comment, body
+            type FunctionRow = (
+                String,
+                String,
+                String,
+                String,
+                Option<String>,
+                Option<String>,
+            );
+
+            let custom_funcs: Vec<FunctionMetadata> = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<_, FunctionRow>(custom_query)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| CatalogError::QueryFailed(e.to_string()))
+                })
+                .await
+                .unwrap_or(vec![]) // Don't fail if pg_proc not accessible
+                .into_iter()
+                .map(|(name, ret, _args, schema, comment, body)| {
+                    let mut function =
                         FunctionMetadata::new(&name, Self::parse_postgres_type(&ret))
                             .with_type(FunctionType::Scalar)
-                            .with_description(format!("Custom function from {}", schema))
-                    })
-                    .collect();
+                            .with_description(
+                                comment_text(comment)
+                                    .unwrap_or_else(|| format!("Custom function from {}", schema)),
+                            );
+                    function.definition = body;
+                    function
+                })
+                .collect();
 
             all_functions.extend(custom_funcs);
         }
 
         Ok(all_functions)
     }
+
+    /// Get the version of every table
+    ///
+    /// The stamp combines the `xmin` of the table's `pg_class` row, which
+    /// changes on renames and most `ALTER TABLE`s, with the latest `xmin` of
+    /// its `pg_attribute` rows, which changes when a column is added, renamed
+    /// or altered. The table's OID identifies it across renames.
+    async fn table_versions(&self) -> CatalogResult<Option<Vec<TableVersion>>> {
+        #[cfg(feature = "postgresql")]
+        if let Some(pool) = &self.pool {
+            let query = r#"
+                SELECT
+                    c.oid::int8 AS id,
+                    n.nspname AS schema_name,
+                    c.relname AS table_name,
+                    c.xmin::text || '/' || COALESCE((
+                        SELECT MAX(a.xmin::text::int8)
+                        FROM pg_catalog.pg_attribute a
+                        WHERE a.attrelid = c.oid AND a.attnum > 0
+                    ), 0)::text AS stamp
+                FROM pg_catalog.pg_class c
+                JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+                WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f')
+                  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+                  AND n.nspname NOT LIKE 'pg_toast%'
+            "#;
+
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query_as::<_, (i64, String, String, String)>(query)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| {
+                            CatalogError::QueryFailed(format!(
+                                "Failed to read table versions: {}",
+                                e
+                            ))
+                        })
+                })
+                .await?;
+
+            return Ok(Some(
+                rows.into_iter()
+                    .map(|(id, schema, name, stamp)| TableVersion {
+                        id,
+                        schema,
+                        name,
+                        stamp,
+                    })
+                    .collect(),
+            ));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "postgresql"))]
+        return Ok(None);
+
+        #[cfg(all(feature = "postgresql", not(feature = "postgresql")))]
+        unreachable!()
+    }
+}
+
+#[async_trait]
+impl QueryExecutor for LivePostgreSQLCatalog {
+    /// Execute a single statement through the extended query protocol
+    ///
+    /// PostgreSQL refuses to prepare input containing several statements, so
+    /// this never runs more than one statement.
+    async fn execute(&self, sql: &str) -> CatalogResult<QueryResult> {
+        #[cfg(feature = "postgresql")]
+        if let Some(pool) = &self.pool {
+            let rows = self
+                .options
+                .with_statement_timeout(async {
+                    sqlx::query(sql)
+                        .fetch_all(pool)
+                        .await
+                        .map_err(|e| CatalogError::QueryFailed(e.to_string()))
+                })
+                .await?;
+
+            let columns = rows
+                .first()
+                .map(|row| row.columns().iter().map(|c| c.name().to_string()).collect())
+                .unwrap_or_default();
+            let rows = rows
+                .iter()
+                .map(|row| (0..row.len()).map(|i| Self::cell_text(row, i)).collect())
+                .collect();
+
+            return Ok(QueryResult::new(columns, rows));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "postgresql"))]
+        return Err(CatalogError::NotSupported(format!(
+            "executing '{}' requires 'postgresql' feature enabled",
+            sql
+        )));
+
+        #[cfg(all(feature = "postgresql", not(feature = "postgresql")))]
+        unreachable!()
+    }
+
+    /// Ping a pooled connection
+    async fn ping(&self) -> CatalogResult<()> {
+        #[cfg(feature = "postgresql")]
+        if let Some(pool) = &self.pool {
+            let mut connection = pool
+                .acquire()
+                .await
+                .map_err(|e| CatalogError::ConnectionFailed(e.to_string()))?;
+            return connection
+                .ping()
+                .await
+                .map_err(|e| CatalogError::ConnectionFailed(e.to_string()));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "postgresql"))]
+        return Err(CatalogError::NotSupported(
+            "ping requires 'postgresql' feature enabled".to_string(),
+        ));
+
+        #[cfg(all(feature = "postgresql", not(feature = "postgresql")))]
+        unreachable!()
+    }
+
+    /// Open a session on a pooled connection
+    ///
+    /// Transactional sessions begin a transaction, which is rolled back if the
+    /// session is dropped without committing.
+    async fn session(&self, transactional: bool) -> CatalogResult<Box<dyn QuerySession>> {
+        #[cfg(feature = "postgresql")]
+        if let Some(pool) = &self.pool {
+            let connection = if transactional {
+                PgSessionConnection::Transaction(pool.begin().await.map_err(|e| {
+                    CatalogError::ConnectionFailed(format!("Failed to begin transaction: {}", e))
+                })?)
+            } else {
+                PgSessionConnection::Autocommit(pool.acquire().await.map_err(|e| {
+                    CatalogError::ConnectionFailed(format!("Failed to acquire connection: {}", e))
+                })?)
+            };
+            return Ok(Box::new(PgSession { connection }));
+        } else {
+            return Err(CatalogError::ConnectionFailed(
+                "Database pool not initialized".to_string(),
+            ));
+        }
+
+        #[cfg(not(feature = "postgresql"))]
+        return Err(CatalogError::NotSupported(format!(
+            "{} sessions require 'postgresql' feature enabled",
+            if transactional {
+                "transactional"
+            } else {
+                "autocommit"
+            }
+        )));
+
+        #[cfg(all(feature = "postgresql", not(feature = "postgresql")))]
+        unreachable!()
+    }
+}
+
+/// Connection held by a PostgreSQL query session
+#[cfg(feature = "postgresql")]
+enum PgSessionConnection {
+    /// Statements run in a transaction, rolled back on drop
+    Transaction(sqlx::Transaction<'static, Postgres>),
+    /// Every statement commits on its own
+    Autocommit(sqlx::pool::PoolConnection<Postgres>),
+}
+
+/// Query session on a pooled PostgreSQL connection
+#[cfg(feature = "postgresql")]
+struct PgSession {
+    connection: PgSessionConnection,
+}
+
+#[cfg(feature = "postgresql")]
+impl PgSession {
+    /// The underlying connection
+    fn connection(&mut self) -> &mut sqlx::PgConnection {
+        match &mut self.connection {
+            PgSessionConnection::Transaction(tx) => &mut **tx,
+            PgSessionConnection::Autocommit(conn) => &mut **conn,
+        }
+    }
+}
+
+#[cfg(feature = "postgresql")]
+#[async_trait]
+impl QuerySession for PgSession {
+    async fn fetch(&mut self, sql: &str, max_rows: usize) -> CatalogResult<QueryResult> {
+        let mut stream = sqlx::query(sql).fetch(self.connection());
+        let mut columns = Vec::new();
+        let mut rows = Vec::new();
+        let mut truncated = false;
+
+        while let Some(row) = stream
+            .try_next()
+            .await
+            .map_err(|e| CatalogError::QueryFailed(e.to_string()))?
+        {
+            if rows.len() == max_rows {
+                truncated = true;
+                break;
+            }
+            if columns.is_empty() {
+                columns = row.columns().iter().map(|c| c.name().to_string()).collect();
+            }
+            rows.push(
+                (0..row.len())
+                    .map(|i| LivePostgreSQLCatalog::cell_text(&row, i))
+                    .collect(),
+            );
+        }
+
+        Ok(QueryResult::new(columns, rows).with_truncated(truncated))
+    }
+
+    async fn execute(&mut self, sql: &str) -> CatalogResult<u64> {
+        sqlx::query(sql)
+            .execute(self.connection())
+            .await
+            .map(|result| result.rows_affected())
+            .map_err(|e| CatalogError::QueryFailed(e.to_string()))
+    }
+
+    fn is_transactional(&self) -> bool {
+        matches!(self.connection, PgSessionConnection::Transaction(_))
+    }
+
+    async fn commit(self: Box<Self>) -> CatalogResult<()> {
+        match self.connection {
+            PgSessionConnection::Transaction(tx) => tx
+                .commit()
+                .await
+                .map_err(|e| CatalogError::QueryFailed(format!("Failed to commit: {}", e))),
+            PgSessionConnection::Autocommit(_) => Ok(()),
+        }
+    }
+
+    async fn rollback(self: Box<Self>) -> CatalogResult<()> {
+        match self.connection {
+            PgSessionConnection::Transaction(tx) => tx
+                .rollback()
+                .await
+                .map_err(|e| CatalogError::QueryFailed(format!("Failed to roll back: {}", e))),
+            PgSessionConnection::Autocommit(_) => Ok(()),
+        }
+    }
+}
+
+#[cfg(feature = "postgresql")]
+impl LivePostgreSQLCatalog {
+    /// Render a result cell as text, trying the common PostgreSQL types in turn
+    ///
+    /// Text-like types without a dedicated decoder (e.g. the `json` plan of
+    /// `EXPLAIN (FORMAT JSON)`) are read as UTF-8 without a type check.
+    fn cell_text(row: &PgRow, index: usize) -> Option<String> {
+        if row.try_get_raw(index).map_or(true, |v| v.is_null()) {
+            return None;
+        }
+
+        row.try_get::<String, _>(index)
+            .ok()
+            .or_else(|| row.try_get::<i64, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<i32, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<i16, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<f64, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<f32, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get::<bool, _>(index).ok().map(|v| v.to_string()))
+            .or_else(|| row.try_get_unchecked::<String, _>(index).ok())
+            .or_else(|| Some(format!("<{}>", row.columns()[index].type_info())))
+    }
 }
 
 #[cfg(test)]
@@ -76,3 +76,23 @@ pub fn format_data_type(data_type: &DataType) -> String {
         _ => "Unknown".to_string(),
     }
 }
+
+/// Normalize a comment read from the database
+///
+/// Databases report an object without a comment as `NULL` or as an empty
+/// string. Both become `None`, so no empty documentation is rendered.
+///
+/// # Examples
+///
+/// ```
+/// use unified_sql_lsp_catalog::comment_text;
+///
+/// assert_eq!(comment_text(Some(" Order lines ".to_string())), Some("Order lines".to_string()));
+/// assert_eq!(comment_text(Some("  ".to_string())), None);
+/// assert_eq!(comment_text(None), None);
+/// ```
+pub fn comment_text(comment: Option<String>) -> Option<String> {
+    comment
+        .map(|comment| comment.trim().to_string())
+        .filter(|comment| !comment.is_empty())
+}
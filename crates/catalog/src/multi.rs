@@ -0,0 +1,358 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Multiple databases
+//!
+//! This module lets a single connection expose tables of several databases
+//! (catalogs) of the same server.
+//!
+//! Servers can host hundreds of databases, so the databases to load are
+//! selected with a [`CatalogFilter`] of include/exclude globs.
+//!
+//! - **MySQL** databases are schemas: a single connection sees all of them and
+//!   tables are referenced as `db.table`. [`LiveMySQLCatalog`] applies the
+//!   filter itself.
+//! - **PostgreSQL** connections are bound to one database: a [`MultiCatalog`]
+//!   combines one catalog per database and routes `db.schema.table`
+//!   references to the right one.
+//!
+//! [`LiveMySQLCatalog`]: crate::LiveMySQLCatalog
+
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::sync::Arc;
+use tracing::warn;
+
+use crate::metadata::{ColumnMetadata, FunctionMetadata, TableMetadata};
+use crate::{Catalog, CatalogError, CatalogResult};
+
+/// Selects the databases to load, by name
+///
+/// Patterns are globs (`*` matches any run of characters, `?` a single
+/// character) matched case-insensitively. A database is selected if it
+/// matches any `include` pattern (or `include` is empty) and no `exclude`
+/// pattern.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase", default)]
+pub struct CatalogFilter {
+    /// Patterns of databases to load
+    pub include: Vec<String>,
+
+    /// Patterns of databases to skip
+    pub exclude: Vec<String>,
+}
+
+impl CatalogFilter {
+    /// Check whether a database is selected
+    pub fn matches(&self, database: &str) -> bool {
+        (self.include.is_empty() || self.include.iter().any(|p| glob_match(p, database)))
+            && !self.exclude.iter().any(|p| glob_match(p, database))
+    }
+}
+
+/// Match a name against a glob pattern, ignoring ASCII case
+fn glob_match(pattern: &str, name: &str) -> bool {
+    let pattern: Vec<char> = pattern.to_ascii_lowercase().chars().collect();
+    let name: Vec<char> = name.to_ascii_lowercase().chars().collect();
+
+    // Greedy matching, backtracking to the last `*`
+    let (mut p, mut n) = (0, 0);
+    let mut star: Option<(usize, usize)> = None;
+    while n < name.len() {
+        match pattern.get(p) {
+            Some('*') => {
+                star = Some((p, n));
+                p += 1;
+            }
+            Some(&c) if c == '?' || c == name[n] => {
+                p += 1;
+                n += 1;
+            }
+            _ => match star {
+                Some((star_p, star_n)) => {
+                    p = star_p + 1;
+                    n = star_n + 1;
+                    star = Some((star_p, star_n + 1));
+                }
+                None => return false,
+            },
+        }
+    }
+
+    pattern[p..].iter().all(|&c| c == '*')
+}
+
+/// Catalog combining the connected database with other databases of the
+/// same server
+///
+/// Tables of the connected database are listed unchanged; tables of other
+/// databases carry their database name in [`TableMetadata::catalog`].
+/// Unqualified and `schema.table` references resolve in the connected
+/// database, so a table name present in several databases always refers to
+/// the connected one unless qualified as `db.schema.table`.
+pub struct MultiCatalog {
+    /// Catalog of the connected database
+    default: Arc<dyn Catalog>,
+
+    /// Catalogs of other databases, by database name
+    others: Vec<(String, Arc<dyn Catalog>)>,
+}
+
+impl MultiCatalog {
+    /// Create a catalog serving the connected database only
+    pub fn new(default: Arc<dyn Catalog>) -> Self {
+        Self {
+            default,
+            others: Vec::new(),
+        }
+    }
+
+    /// Builder method: add another database
+    pub fn with_database(mut self, name: impl Into<String>, catalog: Arc<dyn Catalog>) -> Self {
+        self.others.push((name.into(), catalog));
+        self
+    }
+
+    /// Names of the other databases
+    pub fn databases(&self) -> impl Iterator<Item = &str> {
+        self.others.iter().map(|(name, _)| name.as_str())
+    }
+
+    /// Find the catalog of a table reference
+    ///
+    /// # Returns
+    ///
+    /// The catalog and the reference without its database part, or `None`
+    /// if the reference names an unknown database
+    fn route<'a>(&self, table: &'a str) -> Option<(&dyn Catalog, &'a str)> {
+        let mut parts = table.splitn(3, '.');
+        let (Some(database), Some(_), Some(_)) = (parts.next(), parts.next(), parts.next()) else {
+            return Some((self.default.as_ref(), table));
+        };
+
+        let rest = &table[database.len() + 1..];
+        self.others
+            .iter()
+            .find(|(name, _)| name.eq_ignore_ascii_case(database))
+            .map(|(_, catalog)| (catalog.as_ref(), rest))
+    }
+}
+
+#[async_trait]
+impl Catalog for MultiCatalog {
+    /// List tables of all databases
+    ///
+    /// A database that fails to list its tables is skipped with a warning, so
+    /// one unreachable database does not break completion for the others.
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        let mut tables = self.default.list_tables().await?;
+
+        for (name, catalog) in &self.others {
+            match catalog.list_tables().await {
+                Ok(other) => tables.extend(other.into_iter().map(|t| t.with_catalog(name))),
+                Err(e) => warn!("Skipping tables of database {}: {}", name, e),
+            }
+        }
+
+        Ok(tables)
+    }
+
+    /// Get columns of a table, optionally qualified as `schema.table` or
+    /// `db.schema.table`
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        let Some((catalog, rest)) = self.route(table) else {
+            let (schema, name) = table.rsplit_once('.').unwrap_or_default();
+            return Err(CatalogError::TableNotFound(
+                name.to_string(),
+                schema.to_string(),
+            ));
+        };
+
+        catalog.get_columns(rest).await
+    }
+
+    /// Get columns of tables of all databases, in one batch per database
+    ///
+    /// Like [`Self::list_tables`], databases that fail are skipped with a
+    /// warning.
+    async fn list_columns(
+        &self,
+        tables: Option<&[String]>,
+    ) -> CatalogResult<HashMap<String, Vec<ColumnMetadata>>> {
+        let mut columns = self.default.list_columns(tables).await?;
+
+        for (name, catalog) in &self.others {
+            // `db.schema.table` names select tables of `db`
+            let prefix = format!("{}.", name);
+            let selected: Option<Vec<String>> = tables.map(|tables| {
+                tables
+                    .iter()
+                    .filter_map(|table| table.strip_prefix(&prefix))
+                    .map(str::to_string)
+                    .collect()
+            });
+            if selected.as_ref().is_some_and(Vec::is_empty) {
+                continue;
+            }
+
+            match catalog.list_columns(selected.as_deref()).await {
+                Ok(other) => {
+                    columns.extend(other.into_iter().map(|(table, table_columns)| {
+                        (format!("{}{}", prefix, table), table_columns)
+                    }))
+                }
+                Err(e) => warn!("Skipping columns of database {}: {}", name, e),
+            }
+        }
+
+        Ok(columns)
+    }
+
+    /// List functions of the connected database
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        self.default.list_functions().await
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::metadata::DataType;
+    use crate::snapshot::{SchemaSnapshot, SnapshotCatalog};
+
+    fn database(tables: Vec<(&str, &str, &str)>) -> Arc<dyn Catalog> {
+        let tables = tables
+            .into_iter()
+            .map(|(schema, name, column)| {
+                TableMetadata::new(name, schema)
+                    .with_columns(vec![ColumnMetadata::new(column, DataType::Integer)])
+            })
+            .collect();
+        Arc::new(SnapshotCatalog::new(SchemaSnapshot::new(tables, vec![])))
+    }
+
+    /// `shop` is connected; `analytics` also has an `orders` table
+    fn shop_and_analytics() -> MultiCatalog {
+        MultiCatalog::new(database(vec![
+            ("public", "orders", "order_id"),
+            ("public", "users", "user_id"),
+        ]))
+        .with_database(
+            "analytics",
+            database(vec![
+                ("public", "orders", "event_id"),
+                ("reports", "daily", "day"),
+            ]),
+        )
+    }
+
+    async fn column_names(catalog: &MultiCatalog, table: &str) -> Vec<String> {
+        let columns = catalog.get_columns(table).await.unwrap();
+        columns.into_iter().map(|c| c.name).collect::<Vec<_>>()
+    }
+
+    #[test]
+    fn test_catalog_filter() {
+        let filter = CatalogFilter {
+            include: vec!["app_*".to_string(), "analytics".to_string()],
+            exclude: vec!["*_test".to_string()],
+        };
+
+        assert!(filter.matches("app_shop"));
+        assert!(filter.matches("APP_Billing"));
+        assert!(filter.matches("analytics"));
+        assert!(!filter.matches("app_shop_test"));
+        assert!(!filter.matches("postgres"));
+
+        assert!(CatalogFilter::default().matches("anything"));
+    }
+
+    #[test]
+    fn test_glob_match() {
+        assert!(glob_match("*", ""));
+        assert!(glob_match("a*c", "abbbc"));
+        assert!(glob_match("a?c", "abc"));
+        assert!(glob_match("*b*", "abc"));
+        assert!(!glob_match("a?c", "ac"));
+        assert!(!glob_match("a*c", "abd"));
+    }
+
+    #[tokio::test]
+    async fn test_lists_tables_of_all_databases() {
+        let catalog = shop_and_analytics();
+        let tables = catalog.list_tables().await.unwrap();
+
+        let names: Vec<String> = tables
+            .iter()
+            .map(|t| match &t.catalog {
+                Some(db) => format!("{}.{}.{}", db, t.schema, t.name),
+                None => format!("{}.{}", t.schema, t.name),
+            })
+            .collect();
+        assert_eq!(
+            names,
+            vec![
+                "public.orders",
+                "public.users",
+                "analytics.public.orders",
+                "analytics.reports.daily"
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_name_collisions_resolve_to_connected_database() {
+        let catalog = shop_and_analytics();
+
+        assert_eq!(column_names(&catalog, "orders").await, vec!["order_id"]);
+        assert_eq!(
+            column_names(&catalog, "public.orders").await,
+            vec!["order_id"]
+        );
+        assert_eq!(
+            column_names(&catalog, "analytics.public.orders").await,
+            vec!["event_id"]
+        );
+        assert_eq!(
+            column_names(&catalog, "ANALYTICS.reports.daily").await,
+            vec!["day"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_list_columns_of_selected_tables() {
+        let catalog = shop_and_analytics();
+
+        let all = catalog.list_columns(None).await.unwrap();
+        assert_eq!(all.len(), 4);
+        assert_eq!(all["analytics.reports.daily"][0].name, "day");
+
+        let selected = [
+            "public.users".to_string(),
+            "analytics.public.orders".to_string(),
+        ];
+        let columns = catalog.list_columns(Some(&selected)).await.unwrap();
+        let mut names: Vec<&str> = columns.keys().map(String::as_str).collect();
+        names.sort();
+        assert_eq!(names, vec!["analytics.public.orders", "public.users"]);
+        assert_eq!(columns["analytics.public.orders"][0].name, "event_id");
+    }
+
+    #[tokio::test]
+    async fn test_unknown_database_or_table() {
+        let catalog = shop_and_analytics();
+
+        assert!(matches!(
+            catalog.get_columns("billing.public.orders").await,
+            Err(CatalogError::TableNotFound(table, schema)) if table == "orders" && schema == "billing.public"
+        ));
+        assert!(matches!(
+            catalog.get_columns("daily").await,
+            Err(CatalogError::TableNotFound(_, _))
+        ));
+        assert_eq!(catalog.databases().collect::<Vec<_>>(), vec!["analytics"]);
+    }
+}
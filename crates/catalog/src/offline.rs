@@ -0,0 +1,76 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Offline Catalog
+//!
+//! This module provides a catalog used when no database connection is
+//! available.
+//!
+//! The offline catalog knows no tables or functions. Column lookups fail
+//! with [`CatalogError::NoConnection`] so that callers can distinguish
+//! "table does not exist" from "schema is unknown" and degrade gracefully
+//! (e.g. still offer keyword completion, suppress schema-based diagnostics).
+//!
+//! ## Usage
+//!
+//! ```rust,ignore
+//! use unified_sql_lsp_catalog::OfflineCatalog;
+//!
+//! let catalog = OfflineCatalog::new();
+//! assert!(catalog.list_tables().await?.is_empty());
+//! ```
+
+use async_trait::async_trait;
+
+use crate::metadata::{ColumnMetadata, FunctionMetadata, TableMetadata};
+use crate::{Catalog, CatalogError, CatalogResult};
+
+/// Catalog without a database connection
+///
+/// Used as a stand-in when no connection string is configured or the
+/// connection could not be established.
+#[derive(Debug, Clone, Copy, Default)]
+pub struct OfflineCatalog;
+
+impl OfflineCatalog {
+    /// Create a new offline catalog
+    pub fn new() -> Self {
+        Self
+    }
+}
+
+#[async_trait]
+impl Catalog for OfflineCatalog {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        Ok(Vec::new())
+    }
+
+    async fn get_columns(&self, _table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        Err(CatalogError::NoConnection)
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        Ok(Vec::new())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_offline_catalog_is_empty() {
+        let catalog = OfflineCatalog::new();
+        assert!(catalog.list_tables().await.unwrap().is_empty());
+        assert!(catalog.list_functions().await.unwrap().is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_offline_catalog_get_columns_no_connection() {
+        let catalog = OfflineCatalog::new();
+        let result = catalog.get_columns("users").await;
+        assert!(matches!(result, Err(CatalogError::NoConnection)));
+    }
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Connection pool options
+//!
+//! This module defines the pool settings of live catalogs.
+//!
+//! The server is an editor tool, not an application server, so the defaults
+//! are conservative: a few connections that are closed when idle and recycled
+//! periodically. Every schema query and executed statement is bounded by the
+//! statement timeout.
+
+use crate::error::{CatalogError, CatalogResult};
+
+#[cfg(any(feature = "mysql", feature = "postgresql"))]
+use std::future::Future;
+#[cfg(any(feature = "mysql", feature = "postgresql"))]
+use std::time::Duration;
+
+/// Default maximum number of open connections
+pub const DEFAULT_MAX_CONNECTIONS: u32 = 4;
+
+/// Default time to wait for a free connection in seconds
+pub const DEFAULT_ACQUIRE_TIMEOUT_SECS: u64 = 10;
+
+/// Default time after which an idle connection is closed in seconds
+pub const DEFAULT_IDLE_TIMEOUT_SECS: u64 = 300;
+
+/// Default maximum lifetime of a connection in seconds
+pub const DEFAULT_MAX_LIFETIME_SECS: u64 = 1800;
+
+/// Default statement timeout in seconds
+pub const DEFAULT_STATEMENT_TIMEOUT_SECS: u64 = 5;
+
+/// Pool settings of a live catalog
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct PoolOptions {
+    /// Maximum number of open connections
+    pub max_connections: u32,
+
+    /// Number of idle connections kept open
+    pub min_connections: u32,
+
+    /// Time to wait for a free connection (seconds)
+    pub acquire_timeout_secs: u64,
+
+    /// Time after which an idle connection is closed (seconds, 0 keeps it open)
+    pub idle_timeout_secs: u64,
+
+    /// Time after which a connection is replaced (seconds, 0 keeps it forever)
+    pub max_lifetime_secs: u64,
+
+    /// Maximum execution time of a single query (seconds)
+    pub statement_timeout_secs: u64,
+}
+
+impl Default for PoolOptions {
+    fn default() -> Self {
+        Self {
+            max_connections: DEFAULT_MAX_CONNECTIONS,
+            min_connections: 0,
+            acquire_timeout_secs: DEFAULT_ACQUIRE_TIMEOUT_SECS,
+            idle_timeout_secs: DEFAULT_IDLE_TIMEOUT_SECS,
+            max_lifetime_secs: DEFAULT_MAX_LIFETIME_SECS,
+            statement_timeout_secs: DEFAULT_STATEMENT_TIMEOUT_SECS,
+        }
+    }
+}
+
+impl PoolOptions {
+    /// Validate the options
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::ConfigurationError` if:
+    /// - `max_connections`, `acquire_timeout_secs` or `statement_timeout_secs` is 0
+    /// - `min_connections` exceeds `max_connections`
+    /// - `idle_timeout_secs` exceeds a non-zero `max_lifetime_secs`
+    pub fn validate(&self) -> CatalogResult<()> {
+        let error = |message: &str| Err(CatalogError::ConfigurationError(message.to_string()));
+
+        if self.max_connections == 0 {
+            return error("max_connections must be greater than 0");
+        }
+        if self.min_connections > self.max_connections {
+            return error("min_connections cannot exceed max_connections");
+        }
+        if self.acquire_timeout_secs == 0 {
+            return error("acquire_timeout_secs must be greater than 0");
+        }
+        if self.statement_timeout_secs == 0 {
+            return error("statement_timeout_secs must be greater than 0");
+        }
+        if self.max_lifetime_secs > 0 && self.idle_timeout_secs > self.max_lifetime_secs {
+            return error("idle_timeout_secs cannot exceed max_lifetime_secs");
+        }
+
+        Ok(())
+    }
+
+    /// Build the sqlx pool options
+    #[cfg(any(feature = "mysql", feature = "postgresql"))]
+    pub(crate) fn to_sqlx<DB: sqlx::Database>(&self) -> sqlx::pool::PoolOptions<DB> {
+        let limit = |secs: u64| (secs > 0).then(|| Duration::from_secs(secs));
+
+        sqlx::pool::PoolOptions::<DB>::new()
+            .max_connections(self.max_connections)
+            .min_connections(self.min_connections)
+            .acquire_timeout(Duration::from_secs(self.acquire_timeout_secs))
+            .idle_timeout(limit(self.idle_timeout_secs))
+            .max_lifetime(limit(self.max_lifetime_secs))
+    }
+
+    /// Run a query, failing with `CatalogError::QueryTimeout` if it exceeds
+    /// the statement timeout
+    ///
+    /// The query future is dropped on timeout, which cancels it on the
+    /// connection.
+    #[cfg(any(feature = "mysql", feature = "postgresql"))]
+    pub(crate) async fn with_statement_timeout<T>(
+        &self,
+        query: impl Future<Output = CatalogResult<T>>,
+    ) -> CatalogResult<T> {
+        let timeout = Duration::from_secs(self.statement_timeout_secs);
+
+        tokio::time::timeout(timeout, query)
+            .await
+            .unwrap_or(Err(CatalogError::QueryTimeout(self.statement_timeout_secs)))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_default_options_are_valid() {
+        let options = PoolOptions::default();
+        assert!(options.validate().is_ok());
+        assert_eq!(options.max_connections, 4);
+        assert_eq!(options.min_connections, 0);
+    }
+
+    #[test]
+    fn test_validate_rejects_nonsensical_values() {
+        let invalid = [
+            PoolOptions {
+                max_connections: 0,
+                ..Default::default()
+            },
+            PoolOptions {
+                min_connections: 5,
+                ..Default::default()
+            },
+            PoolOptions {
+                acquire_timeout_secs: 0,
+                ..Default::default()
+            },
+            PoolOptions {
+                statement_timeout_secs: 0,
+                ..Default::default()
+            },
+            PoolOptions {
+                idle_timeout_secs: 600,
+                max_lifetime_secs: 60,
+                ..Default::default()
+            },
+        ];
+
+        for options in invalid {
+            assert!(
+                matches!(options.validate(), Err(CatalogError::ConfigurationError(_))),
+                "{:?} should be rejected",
+                options
+            );
+        }
+    }
+
+    #[test]
+    fn test_zero_timeouts_disable_recycling() {
+        let options = PoolOptions {
+            idle_timeout_secs: 0,
+            max_lifetime_secs: 0,
+            ..Default::default()
+        };
+        assert!(options.validate().is_ok());
+    }
+
+    #[cfg(feature = "mysql")]
+    #[test]
+    fn test_sqlx_pool_options() {
+        let options = PoolOptions {
+            max_connections: 2,
+            min_connections: 1,
+            acquire_timeout_secs: 3,
+            idle_timeout_secs: 0,
+            max_lifetime_secs: 120,
+            statement_timeout_secs: 5,
+        };
+        let pool = options.to_sqlx::<sqlx::MySql>();
+
+        assert_eq!(pool.get_max_connections(), 2);
+        assert_eq!(pool.get_min_connections(), 1);
+        assert_eq!(pool.get_acquire_timeout(), Duration::from_secs(3));
+        assert_eq!(pool.get_idle_timeout(), None);
+        assert_eq!(pool.get_max_lifetime(), Some(Duration::from_secs(120)));
+    }
+
+    #[cfg(feature = "mysql")]
+    #[tokio::test]
+    async fn test_statement_timeout() {
+        let options = PoolOptions {
+            statement_timeout_secs: 1,
+            ..Default::default()
+        };
+        let result = options
+            .with_statement_timeout(std::future::pending::<CatalogResult<()>>())
+            .await;
+
+        assert!(matches!(result, Err(CatalogError::QueryTimeout(1))));
+    }
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Incremental schema refresh
+//!
+//! This module refreshes a cached schema by re-introspecting only the tables
+//! that changed since it was captured.
+//!
+//! Catalogs that detect changes report a [`TableVersion`] per table through
+//! [`Catalog::table_versions`]: an identifier that survives renames and a
+//! stamp that changes with the table's definition. Comparing the versions of
+//! the cached schema with the current ones yields the [`TableChanges`] to
+//! merge. Catalogs without change detection are refreshed in full.
+//!
+//! ## Usage
+//!
+//! ```rust,ignore
+//! use unified_sql_lsp_catalog::VersionedSnapshot;
+//!
+//! let cached = VersionedSnapshot::capture(&live_catalog).await?;
+//! // ... later, when the cached schema expires
+//! let cached = cached.refresh(&live_catalog).await?;
+//! ```
+
+use serde::{Deserialize, Serialize};
+use std::collections::{HashMap, HashSet};
+use tracing::debug;
+
+use crate::metadata::TableMetadata;
+use crate::snapshot::SchemaSnapshot;
+use crate::{Catalog, CatalogResult};
+
+/// Version of a table's definition
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TableVersion {
+    /// Identifier of the table, stable across renames
+    pub id: i64,
+
+    /// Schema name
+    pub schema: String,
+
+    /// Table name
+    pub name: String,
+
+    /// Stamp that changes whenever the table's definition changes
+    pub stamp: String,
+}
+
+impl TableVersion {
+    /// Check whether this version names the given table
+    fn names(&self, table: &TableMetadata) -> bool {
+        self.schema == table.schema && self.name == table.name
+    }
+}
+
+/// Differences between two versions of a schema
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct TableChanges {
+    /// Tables to re-introspect: added, altered, or renamed (new name)
+    pub changed: Vec<TableVersion>,
+
+    /// Tables to remove: dropped, or renamed (old name)
+    pub removed: Vec<TableVersion>,
+}
+
+impl TableChanges {
+    /// Compare the table versions of a cached schema with the current ones
+    pub fn between(cached: &[TableVersion], current: &[TableVersion]) -> Self {
+        let cached_by_id: HashMap<i64, &TableVersion> = cached.iter().map(|v| (v.id, v)).collect();
+        let current_by_id: HashMap<i64, &TableVersion> =
+            current.iter().map(|v| (v.id, v)).collect();
+
+        let changed = current
+            .iter()
+            .filter(|v| cached_by_id.get(&v.id) != Some(v))
+            .cloned()
+            .collect();
+        let removed = cached
+            .iter()
+            .filter(|v| {
+                current_by_id
+                    .get(&v.id)
+                    .is_none_or(|current| current.schema != v.schema || current.name != v.name)
+            })
+            .cloned()
+            .collect();
+
+        Self { changed, removed }
+    }
+
+    /// Check whether the schema is unchanged
+    pub fn is_empty(&self) -> bool {
+        self.changed.is_empty() && self.removed.is_empty()
+    }
+
+    /// Renamed tables, as old and new version
+    pub fn renamed(&self) -> impl Iterator<Item = (&TableVersion, &TableVersion)> {
+        self.removed.iter().filter_map(|old| {
+            self.changed
+                .iter()
+                .find(|new| new.id == old.id)
+                .map(|new| (old, new))
+        })
+    }
+}
+
+impl SchemaSnapshot {
+    /// Merge re-introspected tables into the snapshot
+    ///
+    /// # Arguments
+    ///
+    /// * `removed` - Tables to remove (dropped, or old names of renamed tables)
+    /// * `refreshed` - Current definitions of changed tables, replacing cached
+    ///   tables of the same name
+    pub fn apply_changes(&mut self, removed: &[TableVersion], refreshed: Vec<TableMetadata>) {
+        let replaced: HashSet<(&str, &str)> = refreshed
+            .iter()
+            .map(|t| (t.schema.as_str(), t.name.as_str()))
+            .collect();
+
+        self.tables.retain(|table| {
+            !replaced.contains(&(table.schema.as_str(), table.name.as_str()))
+                && !removed.iter().any(|version| version.names(table))
+        });
+        self.tables.extend(refreshed);
+        self.tables
+            .sort_by(|a, b| (&a.schema, &a.name).cmp(&(&b.schema, &b.name)));
+    }
+}
+
+/// A schema snapshot with the table versions it was captured at
+#[derive(Debug, Clone)]
+pub struct VersionedSnapshot {
+    /// Captured schema
+    pub snapshot: SchemaSnapshot,
+
+    /// Table versions at capture time, `None` without change detection
+    pub versions: Option<Vec<TableVersion>>,
+}
+
+impl VersionedSnapshot {
+    /// Capture the schema of a catalog with its table versions
+    ///
+    /// Versions are read before the schema, so a change made during the
+    /// capture is picked up again by the next refresh.
+    pub async fn capture(catalog: &dyn Catalog) -> CatalogResult<Self> {
+        let versions = catalog.table_versions().await?;
+        let snapshot = SchemaSnapshot::capture(catalog).await?;
+
+        Ok(Self { snapshot, versions })
+    }
+
+    /// Bring the schema up to date
+    ///
+    /// Only changed tables are re-introspected when both the cached schema
+    /// and the catalog have table versions; otherwise the schema is captured
+    /// in full.
+    ///
+    /// # Errors
+    ///
+    /// Returns the first error reported by the catalog.
+    pub async fn refresh(&self, catalog: &dyn Catalog) -> CatalogResult<Self> {
+        let (Some(cached), Some(current)) = (&self.versions, catalog.table_versions().await?)
+        else {
+            debug!("No change detection, refreshing the full schema");
+            return Self::capture(catalog).await;
+        };
+
+        let changes = TableChanges::between(cached, &current);
+        debug!(
+            "Schema refresh: {} changed, {} removed, {} renamed tables",
+            changes.changed.len(),
+            changes.removed.len(),
+            changes.renamed().count()
+        );
+
+        let mut snapshot = self.snapshot.clone();
+        if !changes.is_empty() {
+            let listed = catalog.list_tables().await?;
+            let names: Vec<String> = changes
+                .changed
+                .iter()
+                .map(|version| format!("{}.{}", version.schema, version.name))
+                .collect();
+            let mut columns = catalog.list_columns(Some(&names)).await?;
+
+            let mut refreshed = Vec::with_capacity(changes.changed.len());
+            for version in &changes.changed {
+                // A table dropped since its version was read is not listed
+                let Some(table) = listed.iter().find(|t| version.names(t)) else {
+                    continue;
+                };
+                let mut table = table.clone();
+                table.columns = columns.remove(&table.qualified_name()).unwrap_or_default();
+                refreshed.push(table);
+            }
+            snapshot.apply_changes(&changes.removed, refreshed);
+        }
+        snapshot.functions = catalog.list_functions().await?;
+
+        Ok(Self {
+            snapshot,
+            versions: Some(current),
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::metadata::{ColumnMetadata, DataType};
+
+    fn version(id: i64, name: &str, stamp: &str) -> TableVersion {
+        TableVersion {
+            id,
+            schema: "public".to_string(),
+            name: name.to_string(),
+            stamp: stamp.to_string(),
+        }
+    }
+
+    fn table(name: &str, columns: &[&str]) -> TableMetadata {
+        TableMetadata::new(name, "public").with_columns(
+            columns
+                .iter()
+                .map(|c| ColumnMetadata::new(*c, DataType::Integer))
+                .collect(),
+        )
+    }
+
+    fn snapshot() -> SchemaSnapshot {
+        SchemaSnapshot::new(
+            vec![
+                table("orders", &["id"]),
+                table("users", &["id"]),
+                table("logs", &["at"]),
+            ],
+            vec![],
+        )
+    }
+
+    fn cached_versions() -> Vec<TableVersion> {
+        vec![
+            version(1, "orders", "10"),
+            version(2, "users", "11"),
+            version(3, "logs", "12"),
+        ]
+    }
+
+    fn table_names(snapshot: &SchemaSnapshot) -> Vec<&str> {
+        snapshot.tables.iter().map(|t| t.name.as_str()).collect()
+    }
+
+    #[test]
+    fn test_unchanged_schema() {
+        let changes = TableChanges::between(&cached_versions(), &cached_versions());
+        assert!(changes.is_empty());
+    }
+
+    #[test]
+    fn test_added_altered_and_dropped_tables() {
+        let current = vec![
+            version(1, "orders", "20"),
+            version(2, "users", "11"),
+            version(4, "payments", "21"),
+        ];
+        let changes = TableChanges::between(&cached_versions(), &current);
+
+        let changed: Vec<&str> = changes.changed.iter().map(|v| v.name.as_str()).collect();
+        assert_eq!(changed, vec!["orders", "payments"]);
+        assert_eq!(changes.removed, vec![version(3, "logs", "12")]);
+        assert_eq!(changes.renamed().count(), 0);
+
+        let mut merged = snapshot();
+        merged.apply_changes(
+            &changes.removed,
+            vec![
+                table("orders", &["id", "total"]),
+                table("payments", &["id"]),
+            ],
+        );
+        assert_eq!(table_names(&merged), vec!["orders", "payments", "users"]);
+        assert_eq!(merged.tables[0].columns.len(), 2);
+    }
+
+    #[test]
+    fn test_renamed_table() {
+        let current = vec![
+            version(1, "orders", "10"),
+            version(2, "customers", "30"),
+            version(3, "logs", "12"),
+        ];
+        let changes = TableChanges::between(&cached_versions(), &current);
+
+        let renamed: Vec<(&str, &str)> = changes
+            .renamed()
+            .map(|(old, new)| (old.name.as_str(), new.name.as_str()))
+            .collect();
+        assert_eq!(renamed, vec![("users", "customers")]);
+
+        let mut merged = snapshot();
+        merged.apply_changes(&changes.removed, vec![table("customers", &["id"])]);
+        assert_eq!(table_names(&merged), vec!["customers", "logs", "orders"]);
+    }
+
+    #[test]
+    fn test_swapped_table_names() {
+        let current = vec![
+            version(1, "users", "40"),
+            version(2, "orders", "41"),
+            version(3, "logs", "12"),
+        ];
+        let changes = TableChanges::between(&cached_versions(), &current);
+        assert_eq!(changes.renamed().count(), 2);
+
+        let mut merged = snapshot();
+        merged.apply_changes(
+            &changes.removed,
+            vec![table("users", &["order_id"]), table("orders", &["user_id"])],
+        );
+        assert_eq!(table_names(&merged), vec!["logs", "orders", "users"]);
+        assert_eq!(merged.tables[1].columns[0].name, "user_id");
+        assert_eq!(merged.tables[2].columns[0].name, "order_id");
+    }
+
+    #[tokio::test]
+    async fn test_refresh_without_change_detection_is_full() {
+        let catalog = crate::SnapshotCatalog::new(snapshot());
+        let cached = VersionedSnapshot {
+            snapshot: SchemaSnapshot::new(vec![], vec![]),
+            versions: Some(cached_versions()),
+        };
+
+        let refreshed = cached.refresh(&catalog).await.unwrap();
+        assert_eq!(refreshed.snapshot.tables.len(), 3);
+        assert!(refreshed.versions.is_none());
+    }
+}
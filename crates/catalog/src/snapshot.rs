@@ -0,0 +1,266 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Schema snapshots
+//!
+//! This module saves a catalog's schema to a versioned JSON file and serves
+//! it back without a database connection.
+//!
+//! Snapshots let users work offline, or against production databases they
+//! cannot query repeatedly: the schema is dumped once (`unified-sql-lsp schema
+//! dump`) and loaded by a [`SnapshotCatalog`] for completion and hover.
+//!
+//! ## Usage
+//!
+//! ```rust,ignore
+//! use unified_sql_lsp_catalog::{SchemaSnapshot, SnapshotCatalog};
+//!
+//! let snapshot = SchemaSnapshot::capture(&live_catalog).await?;
+//! snapshot.save("schema.json")?;
+//!
+//! let catalog = SnapshotCatalog::load("schema.json")?;
+//! let columns = catalog.get_columns("users").await?;
+//! ```
+
+use async_trait::async_trait;
+use serde::{Deserialize, Serialize};
+use std::path::Path;
+
+use crate::metadata::{ColumnMetadata, FunctionMetadata, TableMetadata};
+use crate::{Catalog, CatalogError, CatalogResult};
+
+/// Version of the snapshot file format
+pub const SCHEMA_SNAPSHOT_VERSION: u32 = 1;
+
+/// Schema of a database at one point in time
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct SchemaSnapshot {
+    /// File format version
+    pub version: u32,
+
+    /// Tables, including their columns
+    pub tables: Vec<TableMetadata>,
+
+    /// Functions
+    pub functions: Vec<FunctionMetadata>,
+}
+
+impl SchemaSnapshot {
+    /// Create a snapshot from tables (with columns) and functions
+    pub fn new(tables: Vec<TableMetadata>, functions: Vec<FunctionMetadata>) -> Self {
+        Self {
+            version: SCHEMA_SNAPSHOT_VERSION,
+            tables,
+            functions,
+        }
+    }
+
+    /// Capture the schema of a catalog
+    ///
+    /// Columns of every table listed by the catalog are fetched in one batch
+    /// (see [`Catalog::list_columns`]).
+    ///
+    /// # Errors
+    ///
+    /// Returns the first error reported by the catalog.
+    pub async fn capture(catalog: &dyn Catalog) -> CatalogResult<Self> {
+        let mut tables = catalog.list_tables().await?;
+        let mut columns = catalog.list_columns(None).await?;
+        for table in &mut tables {
+            table.columns = columns.remove(&table.qualified_name()).unwrap_or_default();
+        }
+        let functions = catalog.list_functions().await?;
+
+        Ok(Self::new(tables, functions))
+    }
+
+    /// Serialize the snapshot to pretty-printed JSON
+    pub fn to_json(&self) -> CatalogResult<String> {
+        serde_json::to_string_pretty(self)
+            .map_err(|e| CatalogError::SerializationError(e.to_string()))
+    }
+
+    /// Parse a snapshot from JSON
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::SerializationError` if the JSON is invalid or was
+    /// written by a newer, unsupported format version.
+    pub fn from_json(json: &str) -> CatalogResult<Self> {
+        let snapshot: Self = serde_json::from_str(json)
+            .map_err(|e| CatalogError::SerializationError(e.to_string()))?;
+
+        if snapshot.version > SCHEMA_SNAPSHOT_VERSION {
+            return Err(CatalogError::SerializationError(format!(
+                "Unsupported schema snapshot version {} (supported: {})",
+                snapshot.version, SCHEMA_SNAPSHOT_VERSION
+            )));
+        }
+
+        Ok(snapshot)
+    }
+
+    /// Write the snapshot to a file
+    pub fn save(&self, path: impl AsRef<Path>) -> CatalogResult<()> {
+        let path = path.as_ref();
+        std::fs::write(path, self.to_json()?).map_err(|e| {
+            CatalogError::SerializationError(format!("Failed to write {}: {}", path.display(), e))
+        })
+    }
+
+    /// Read a snapshot from a file
+    pub fn load(path: impl AsRef<Path>) -> CatalogResult<Self> {
+        let path = path.as_ref();
+        let json = std::fs::read_to_string(path).map_err(|e| {
+            CatalogError::SerializationError(format!("Failed to read {}: {}", path.display(), e))
+        })?;
+        Self::from_json(&json)
+    }
+}
+
+/// Catalog serving a schema snapshot
+///
+/// Never opens a database connection.
+#[derive(Debug, Clone)]
+pub struct SnapshotCatalog {
+    snapshot: SchemaSnapshot,
+}
+
+impl SnapshotCatalog {
+    /// Create a catalog serving the given snapshot
+    pub fn new(snapshot: SchemaSnapshot) -> Self {
+        Self { snapshot }
+    }
+
+    /// Create a catalog serving a snapshot file
+    pub fn load(path: impl AsRef<Path>) -> CatalogResult<Self> {
+        SchemaSnapshot::load(path).map(Self::new)
+    }
+
+    /// The served snapshot
+    pub fn snapshot(&self) -> &SchemaSnapshot {
+        &self.snapshot
+    }
+}
+
+#[async_trait]
+impl Catalog for SnapshotCatalog {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        Ok(self.snapshot.tables.clone())
+    }
+
+    /// Look up a table by name, optionally qualified as `schema.table`
+    ///
+    /// Names are matched case-insensitively. Tables of other databases are
+    /// only found when qualified as `db.schema.table`.
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        let (schema, name) = match table.rsplit_once('.') {
+            Some((schema, name)) => (Some(schema), name),
+            None => (None, table),
+        };
+
+        self.snapshot
+            .tables
+            .iter()
+            .find(|t| {
+                t.name.eq_ignore_ascii_case(name)
+                    && match (schema, &t.catalog) {
+                        (None, catalog) => catalog.is_none(),
+                        (Some(schema), None) => t.schema.eq_ignore_ascii_case(schema),
+                        (Some(schema), Some(catalog)) => {
+                            format!("{}.{}", catalog, t.schema).eq_ignore_ascii_case(schema)
+                        }
+                    }
+            })
+            .map(|t| t.columns.clone())
+            .ok_or_else(|| {
+                CatalogError::TableNotFound(
+                    name.to_string(),
+                    schema.unwrap_or_default().to_string(),
+                )
+            })
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        Ok(self.snapshot.functions.clone())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::StaticCatalog;
+    use crate::metadata::{DataType, FunctionType};
+
+    #[tokio::test]
+    async fn test_dump_load_round_trip() {
+        let catalog = StaticCatalog::new();
+        let snapshot = SchemaSnapshot::capture(&catalog).await.unwrap();
+        assert!(!snapshot.tables.is_empty());
+        assert!(snapshot.tables.iter().all(|t| !t.columns.is_empty()));
+
+        let path = std::env::temp_dir().join(format!(
+            "unified-sql-lsp-snapshot-{}.json",
+            std::process::id()
+        ));
+        snapshot.save(&path).unwrap();
+        let loaded = SchemaSnapshot::load(&path).unwrap();
+        std::fs::remove_file(&path).unwrap();
+
+        assert_eq!(loaded, snapshot);
+    }
+
+    #[tokio::test]
+    async fn test_snapshot_catalog_lookups() {
+        let users = TableMetadata::new("users", "shop").with_columns(vec![
+            ColumnMetadata::new("id", DataType::Integer).with_primary_key(),
+        ]);
+        let function =
+            FunctionMetadata::new("calc_tax", DataType::Decimal).with_type(FunctionType::Scalar);
+        let catalog = SnapshotCatalog::new(SchemaSnapshot::new(vec![users], vec![function]));
+
+        assert_eq!(catalog.get_columns("USERS").await.unwrap().len(), 1);
+        assert_eq!(catalog.get_columns("shop.users").await.unwrap().len(), 1);
+        assert!(matches!(
+            catalog.get_columns("other.users").await,
+            Err(CatalogError::TableNotFound(_, _))
+        ));
+        assert_eq!(catalog.list_functions().await.unwrap().len(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_snapshot_of_other_databases() {
+        let orders = |column: &str| {
+            TableMetadata::new("orders", "public")
+                .with_columns(vec![ColumnMetadata::new(column, DataType::Integer)])
+        };
+        let catalog = SnapshotCatalog::new(SchemaSnapshot::new(
+            vec![
+                orders("event_id").with_catalog("analytics"),
+                orders("order_id"),
+            ],
+            vec![],
+        ));
+
+        let columns = catalog.get_columns("orders").await.unwrap();
+        assert_eq!(columns[0].name, "order_id");
+        let columns = catalog.get_columns("public.orders").await.unwrap();
+        assert_eq!(columns[0].name, "order_id");
+        let columns = catalog
+            .get_columns("Analytics.public.orders")
+            .await
+            .unwrap();
+        assert_eq!(columns[0].name, "event_id");
+    }
+
+    #[test]
+    fn test_newer_version_rejected() {
+        let json = r#"{ "version": 99, "tables": [], "functions": [] }"#;
+        assert!(matches!(
+            SchemaSnapshot::from_json(json),
+            Err(CatalogError::SerializationError(_))
+        ));
+    }
+}
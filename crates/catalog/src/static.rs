@@ -39,8 +39,10 @@ impl StaticCatalog {
             "users".to_string(),
             TableMetadata {
                 schema: "playground".to_string(),
+                catalog: None,
                 name: "users".to_string(),
                 table_type: TableType::Table,
+                definition: None,
                 columns: vec![
                     ColumnMetadata {
                         name: "id".to_string(),
@@ -93,8 +95,10 @@ impl StaticCatalog {
             "orders".to_string(),
             TableMetadata {
                 schema: "playground".to_string(),
+                catalog: None,
                 name: "orders".to_string(),
                 table_type: TableType::Table,
+                definition: None,
                 columns: vec![
                     ColumnMetadata {
                         name: "id".to_string(),
@@ -117,6 +121,7 @@ impl StaticCatalog {
                         references: Some(unified_sql_lsp_ir::TableReference {
                             table: "users".to_string(),
                             column: "id".to_string(),
+                            constraint: None,
                         }),
                     },
                     ColumnMetadata {
@@ -160,8 +165,10 @@ impl StaticCatalog {
             "order_items".to_string(),
             TableMetadata {
                 schema: "playground".to_string(),
+                catalog: None,
                 name: "order_items".to_string(),
                 table_type: TableType::Table,
+                definition: None,
                 columns: vec![
                     ColumnMetadata {
                         name: "id".to_string(),
@@ -184,6 +191,7 @@ impl StaticCatalog {
                         references: Some(unified_sql_lsp_ir::TableReference {
                             table: "orders".to_string(),
                             column: "id".to_string(),
+                            constraint: None,
                         }),
                     },
                     ColumnMetadata {
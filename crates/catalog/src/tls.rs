@@ -0,0 +1,148 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # TLS options
+//!
+//! This module defines the TLS settings of live catalogs.
+//!
+//! When TLS options are given, the connection is always encrypted. The server
+//! certificate is verified against `ca_cert` (or the system roots) unless
+//! `skip_verify` is set.
+
+use serde::{Deserialize, Serialize};
+use std::path::PathBuf;
+
+use crate::error::{CatalogError, CatalogResult};
+
+#[cfg(feature = "mysql")]
+use sqlx::mysql::{MySqlConnectOptions, MySqlSslMode};
+#[cfg(feature = "postgresql")]
+use sqlx::postgres::{PgConnectOptions, PgSslMode};
+
+/// TLS settings of a database connection
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase", default)]
+pub struct TlsOptions {
+    /// CA certificate used to verify the server (PEM)
+    pub ca_cert: Option<PathBuf>,
+
+    /// Client certificate for mutual TLS (PEM)
+    pub client_cert: Option<PathBuf>,
+
+    /// Private key of the client certificate (PEM)
+    pub client_key: Option<PathBuf>,
+
+    /// Encrypt without verifying the server certificate
+    pub skip_verify: bool,
+}
+
+impl TlsOptions {
+    /// Validate the options
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::ConfigurationError` if only one of the client
+    /// certificate and key is set, or if a CA certificate is combined with
+    /// `skip_verify`.
+    pub fn validate(&self) -> CatalogResult<()> {
+        if self.client_cert.is_some() != self.client_key.is_some() {
+            return Err(CatalogError::ConfigurationError(
+                "client_cert and client_key must be set together".to_string(),
+            ));
+        }
+        if self.skip_verify && self.ca_cert.is_some() {
+            return Err(CatalogError::ConfigurationError(
+                "ca_cert has no effect when skip_verify is set".to_string(),
+            ));
+        }
+
+        Ok(())
+    }
+
+    /// Apply the options to MySQL connect options
+    #[cfg(feature = "mysql")]
+    pub(crate) fn apply_mysql(&self, options: MySqlConnectOptions) -> MySqlConnectOptions {
+        let mut options = options.ssl_mode(if self.skip_verify {
+            MySqlSslMode::Required
+        } else {
+            MySqlSslMode::VerifyIdentity
+        });
+
+        if let Some(ca_cert) = &self.ca_cert {
+            options = options.ssl_ca(ca_cert);
+        }
+        if let Some(client_cert) = &self.client_cert {
+            options = options.ssl_client_cert(client_cert);
+        }
+        if let Some(client_key) = &self.client_key {
+            options = options.ssl_client_key(client_key);
+        }
+
+        options
+    }
+
+    /// Apply the options to PostgreSQL connect options
+    #[cfg(feature = "postgresql")]
+    pub(crate) fn apply_postgres(&self, options: PgConnectOptions) -> PgConnectOptions {
+        let mut options = options.ssl_mode(if self.skip_verify {
+            PgSslMode::Require
+        } else {
+            PgSslMode::VerifyFull
+        });
+
+        if let Some(ca_cert) = &self.ca_cert {
+            options = options.ssl_root_cert(ca_cert);
+        }
+        if let Some(client_cert) = &self.client_cert {
+            options = options.ssl_client_cert(client_cert);
+        }
+        if let Some(client_key) = &self.client_key {
+            options = options.ssl_client_key(client_key);
+        }
+
+        options
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_validate() {
+        assert!(TlsOptions::default().validate().is_ok());
+
+        let mutual = TlsOptions {
+            ca_cert: Some("ca.pem".into()),
+            client_cert: Some("client.pem".into()),
+            client_key: Some("client.key".into()),
+            skip_verify: false,
+        };
+        assert!(mutual.validate().is_ok());
+
+        let missing_key = TlsOptions {
+            client_key: None,
+            ..mutual.clone()
+        };
+        assert!(missing_key.validate().is_err());
+
+        let contradictory = TlsOptions {
+            skip_verify: true,
+            ..mutual
+        };
+        assert!(contradictory.validate().is_err());
+    }
+
+    #[test]
+    fn test_deserialize_settings() {
+        let options: TlsOptions = serde_json::from_value(serde_json::json!({
+            "caCert": "/etc/ssl/db-ca.pem"
+        }))
+        .unwrap();
+
+        assert_eq!(options.ca_cert, Some(PathBuf::from("/etc/ssl/db-ca.pem")));
+        assert!(!options.skip_verify);
+    }
+}
@@ -7,8 +7,11 @@
 //!
 //! This module defines the async Catalog trait used for querying database schema information.
 
+use std::collections::HashMap;
+
 use crate::error::CatalogResult;
 use crate::metadata::{ColumnMetadata, FunctionMetadata, TableMetadata};
+use crate::refresh::TableVersion;
 
 /// Catalog trait for database schema abstraction
 ///
@@ -79,6 +82,46 @@ pub trait Catalog: Send + Sync {
     /// ```
     async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>>;
 
+    /// Get column metadata for many tables at once
+    ///
+    /// Live catalogs introspect the columns of the whole schema with a fixed
+    /// number of queries, instead of one query per table.
+    ///
+    /// # Arguments
+    ///
+    /// * `tables` - Qualified names of the tables (see
+    ///   [`TableMetadata::qualified_name`]), or `None` for all listed tables
+    ///
+    /// # Returns
+    ///
+    /// Columns by qualified table name. Tables without columns may be missing.
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::QueryFailed` if the columns cannot be read.
+    ///
+    /// The default implementation calls [`Self::get_columns`] for every
+    /// selected table.
+    async fn list_columns(
+        &self,
+        tables: Option<&[String]>,
+    ) -> CatalogResult<HashMap<String, Vec<ColumnMetadata>>> {
+        let mut columns = HashMap::new();
+        for table in self.list_tables().await? {
+            let name = table.qualified_name();
+            if tables.is_some_and(|tables| !tables.contains(&name)) {
+                continue;
+            }
+            // Tables of other databases are looked up by their qualified name
+            let lookup = match table.catalog {
+                Some(_) => name.clone(),
+                None => table.name,
+            };
+            columns.insert(name, self.get_columns(&lookup).await?);
+        }
+        Ok(columns)
+    }
+
     /// List all available functions
     ///
     /// Returns metadata for all functions available in the database,
@@ -102,4 +145,21 @@ pub trait Catalog: Send + Sync {
     ///     .collect();
     /// ```
     async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>>;
+
+    /// Get the version of every table, for incremental schema refresh
+    ///
+    /// Catalogs that detect schema changes return one [`TableVersion`] per
+    /// listed table, and accept `schema.table` names in [`Self::get_columns`].
+    ///
+    /// # Returns
+    ///
+    /// `None` if the catalog cannot detect changes (the default), in which
+    /// case cached schemas are refreshed in full.
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::QueryFailed` if the versions cannot be read.
+    async fn table_versions(&self) -> CatalogResult<Option<Vec<TableVersion>>> {
+        Ok(None)
+    }
 }
@@ -5,8 +5,11 @@
 
 //! Integration tests for the catalog crate
 
+use std::collections::HashMap;
+use std::sync::atomic::{AtomicUsize, Ordering};
 use unified_sql_lsp_catalog::{
-    Catalog, ColumnMetadata, DataType, FunctionMetadata, FunctionType, TableMetadata, TableType,
+    Catalog, CatalogResult, ColumnMetadata, DataType, FunctionMetadata, FunctionType,
+    SchemaSnapshot, TableMetadata, TableType, TableVersion, VersionedSnapshot,
 };
 
 // Mock catalog implementation for integration testing
@@ -276,3 +279,132 @@ async fn test_complete_metadata_workflow() {
         .any(|f| matches!(f.function_type, FunctionType::Aggregate));
     assert!(has_aggregate);
 }
+
+/// Database of generated tables counting the queries it answers
+///
+/// Like the live catalogs, it answers [`Catalog::list_columns`] with a single
+/// query, whatever the number of tables.
+struct CountingDatabase {
+    snapshot: SchemaSnapshot,
+    versions: Vec<TableVersion>,
+    queries: AtomicUsize,
+}
+
+impl CountingDatabase {
+    fn new(tables: usize, stamp: &str) -> Self {
+        let tables: Vec<TableMetadata> = (0..tables)
+            .map(|i| {
+                TableMetadata::new(format!("t{}", i), "public")
+                    .with_columns(vec![ColumnMetadata::new("id", DataType::BigInt)])
+            })
+            .collect();
+        let versions = tables
+            .iter()
+            .enumerate()
+            .map(|(id, table)| TableVersion {
+                id: id as i64,
+                schema: table.schema.clone(),
+                name: table.name.clone(),
+                stamp: stamp.to_string(),
+            })
+            .collect();
+
+        Self {
+            snapshot: SchemaSnapshot::new(tables, vec![]),
+            versions,
+            queries: AtomicUsize::new(0),
+        }
+    }
+
+    fn query(&self) {
+        self.queries.fetch_add(1, Ordering::Relaxed);
+    }
+
+    fn queries(&self) -> usize {
+        self.queries.load(Ordering::Relaxed)
+    }
+}
+
+#[async_trait::async_trait]
+impl Catalog for CountingDatabase {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        self.query();
+        Ok(self
+            .snapshot
+            .tables
+            .iter()
+            .map(|table| TableMetadata::new(&table.name, &table.schema))
+            .collect())
+    }
+
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        self.query();
+        let table = table.rsplit('.').next().unwrap_or(table);
+        Ok(self
+            .snapshot
+            .tables
+            .iter()
+            .find(|t| t.name == table)
+            .map(|t| t.columns.clone())
+            .unwrap_or_default())
+    }
+
+    async fn list_columns(
+        &self,
+        tables: Option<&[String]>,
+    ) -> CatalogResult<HashMap<String, Vec<ColumnMetadata>>> {
+        self.query();
+        Ok(self
+            .snapshot
+            .tables
+            .iter()
+            .map(|table| (table.qualified_name(), table.columns.clone()))
+            .filter(|(name, _)| tables.is_none_or(|tables| tables.contains(name)))
+            .collect())
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        self.query();
+        Ok(vec![])
+    }
+
+    async fn table_versions(&self) -> CatalogResult<Option<Vec<TableVersion>>> {
+        self.query();
+        Ok(Some(self.versions.clone()))
+    }
+}
+
+#[tokio::test]
+async fn test_capture_queries_do_not_grow_with_table_count() {
+    for tables in [10, 2000] {
+        let database = CountingDatabase::new(tables, "1");
+        let snapshot = SchemaSnapshot::capture(&database).await.unwrap();
+
+        assert_eq!(snapshot.tables.len(), tables);
+        assert!(snapshot.tables.iter().all(|t| t.columns.len() == 1));
+        // Tables, columns and functions
+        assert_eq!(database.queries(), 3, "{} tables", tables);
+    }
+}
+
+#[tokio::test]
+async fn test_refresh_reads_changed_columns_in_one_query() {
+    let cached = VersionedSnapshot::capture(&CountingDatabase::new(2000, "1"))
+        .await
+        .unwrap();
+
+    // Every table changed
+    let database = CountingDatabase::new(2000, "2");
+    let refreshed = cached.refresh(&database).await.unwrap();
+
+    assert_eq!(refreshed.snapshot.tables.len(), 2000);
+    assert!(
+        refreshed
+            .snapshot
+            .tables
+            .iter()
+            .all(|t| t.columns.len() == 1)
+    );
+    // Versions, tables, columns and functions
+    assert_eq!(database.queries(), 4);
+}
@@ -26,6 +26,8 @@ pub struct ColumnHoverInfo {
     pub data_type: DataType,
     pub is_primary_key: bool,
     pub is_foreign_key: bool,
+    /// Column comment from the database
+    pub comment: Option<String>,
 }
 
 /// Hover information provider for SQL completion
@@ -100,7 +102,7 @@ impl HoverInfoProvider {
     ///
     /// # Returns
     ///
-    /// Markdown-formatted hover text with column type
+    /// Markdown-formatted hover text with column type and comment
     pub fn get_column_hover(&self, column_info: &ColumnHoverInfo) -> String {
         let mut detail = format!(
             "```sql\n{}\n```\n\nColumn type: {}",
@@ -108,6 +110,8 @@ impl HoverInfoProvider {
             self.format_data_type(&column_info.data_type)
         );
 
+        push_comment(&mut detail, column_info.comment.as_deref());
+
         if column_info.is_primary_key {
             detail.push_str("\n\n**Primary Key**");
         }
@@ -185,12 +189,15 @@ impl HoverInfoProvider {
     /// # Arguments
     ///
     /// * `table_name` - Table name
+    /// * `comment` - Table comment from the database, if any
     ///
     /// # Returns
     ///
     /// Markdown-formatted hover text indicating it's a table
-    pub fn get_table_hover(&self, table_name: &str) -> String {
-        format!("```sql\n{}\n```\n\nTable", table_name)
+    pub fn get_table_hover(&self, table_name: &str, comment: Option<&str>) -> String {
+        let mut detail = format!("```sql\n{}\n```\n\nTable", table_name);
+        push_comment(&mut detail, comment);
+        detail
     }
 
     /// Check if a word is likely a SQL function
@@ -209,6 +216,14 @@ impl HoverInfoProvider {
     }
 }
 
+/// Append a database comment as its own paragraph, unless it is blank
+fn push_comment(detail: &mut String, comment: Option<&str>) {
+    if let Some(comment) = comment.map(str::trim).filter(|c| !c.is_empty()) {
+        detail.push_str("\n\n");
+        detail.push_str(comment);
+    }
+}
+
 impl Default for HoverInfoProvider {
     fn default() -> Self {
         Self::new()
@@ -262,10 +277,37 @@ mod tests {
             data_type: DataType::Integer,
             is_primary_key: true,
             is_foreign_key: false,
+            comment: None,
         };
         let info = provider.get_column_hover(&column_info);
         assert!(info.contains("id"));
         assert!(info.contains("INT"));
         assert!(info.contains("Primary Key"));
     }
+
+    #[test]
+    fn test_comments_in_hover() {
+        let provider = HoverInfoProvider::new();
+        let column_info = ColumnHoverInfo {
+            name: "total".to_string(),
+            data_type: DataType::Decimal,
+            is_primary_key: false,
+            is_foreign_key: false,
+            comment: Some("Order total, tax included".to_string()),
+        };
+        assert_eq!(
+            provider.get_column_hover(&column_info),
+            "```sql\ntotal\n```\n\nColumn type: DECIMAL\n\nOrder total, tax included"
+        );
+
+        assert_eq!(
+            provider.get_table_hover("orders", Some("Customer orders")),
+            "```sql\norders\n```\n\nTable\n\nCustomer orders"
+        );
+        // Blank comments add no empty paragraph
+        assert_eq!(
+            provider.get_table_hover("orders", Some("  ")),
+            "```sql\norders\n```\n\nTable"
+        );
+    }
 }
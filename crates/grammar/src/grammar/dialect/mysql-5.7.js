@@ -37,9 +37,10 @@ module.exports = {
     'INTO',
     $.table_name,
     optional($.column_list),
-    'VALUES',
-    $.value_list,
-    repeat(seq(',', $.value_list))
+    choice(
+      seq('VALUES', $.value_list, repeat(seq(',', $.value_list))),
+      $.select_statement
+    )
   ),
 
   // =============================================================================
@@ -150,9 +150,10 @@ module.exports = grammar({
       'INTO',
       $.table_name,
       optional($.column_list),
-      'VALUES',
-      $.value_list,
-      repeat(seq(',', $.value_list))
+      choice(
+        seq('VALUES', $.value_list, repeat(seq(',', $.value_list))),
+        $.select_statement
+      )
     ),
 
     column_list: $ => seq(
@@ -164,11 +165,19 @@ module.exports = grammar({
 
     value_list: $ => seq(
       '(',
-      $.expression,
-      repeat(seq(',', $.expression)),
+      $._value,
+      repeat(seq(',', $._value)),
       ')'
     ),
 
+    _value: $ => choice(
+      $.expression,
+      $.default_value
+    ),
+
+    // DEFAULT in a VALUES row or a SET assignment
+    default_value: $ => 'DEFAULT',
+
     // =============================================================================
     // UPDATE Statement
     // =============================================================================
@@ -185,7 +194,7 @@ module.exports = grammar({
     assignment: $ => seq(
       $.column_name,
       '=',
-      $.expression
+      $._value
     ),
 
     // =============================================================================
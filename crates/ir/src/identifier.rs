@@ -0,0 +1,297 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Identifier rules
+//!
+//! This module defines how a dialect compares identifiers written in a query
+//! with the names reported by the database.
+//!
+//! - **PostgreSQL** folds unquoted identifiers to lowercase; quoted
+//!   identifiers keep their case and match it exactly (`"Users"` is not
+//!   `users`).
+//! - **MySQL** keeps identifiers as written and compares column names without
+//!   case, quoted or not. Whether table names compare with case depends on the
+//!   server's `lower_case_table_names` (`0` on Linux compares with case).
+//!
+//! Unquoted identifiers match names of any case: catalogs report the names as
+//! stored, and a schema with mixed-case names should not make every unquoted
+//! reference unknown.
+
+use serde::{Deserialize, Serialize};
+
+use crate::dialect::{Dialect, DialectFamily};
+
+/// Case unquoted identifiers are folded to
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum IdentifierCase {
+    Lower,
+    Upper,
+    /// Identifiers are kept as written
+    Preserve,
+}
+
+/// Identifier folding and quoting rules of a dialect
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DialectRules {
+    /// Case unquoted identifiers are folded to
+    pub fold: IdentifierCase,
+
+    /// Character quoting identifiers
+    pub quote: char,
+
+    /// Whether quoted identifiers compare with case
+    pub quoted_case_sensitive: bool,
+
+    /// Whether table names compare with case, quoted or not
+    pub case_sensitive_tables: bool,
+}
+
+impl Default for DialectRules {
+    /// Rules comparing every identifier without case
+    fn default() -> Self {
+        Self {
+            fold: IdentifierCase::Preserve,
+            quote: '"',
+            quoted_case_sensitive: false,
+            case_sensitive_tables: false,
+        }
+    }
+}
+
+impl Dialect {
+    /// Default identifier rules of the dialect
+    ///
+    /// MySQL table names compare without case, as with
+    /// `lower_case_table_names` set to `1` or `2`.
+    pub fn rules(&self) -> DialectRules {
+        match self.family() {
+            DialectFamily::PostgreSQL => DialectRules {
+                fold: IdentifierCase::Lower,
+                quote: '"',
+                quoted_case_sensitive: true,
+                case_sensitive_tables: false,
+            },
+            DialectFamily::MySQL => DialectRules {
+                fold: IdentifierCase::Preserve,
+                quote: '`',
+                quoted_case_sensitive: false,
+                case_sensitive_tables: false,
+            },
+        }
+    }
+}
+
+impl DialectRules {
+    /// Fold an identifier as written in a query
+    ///
+    /// # Arguments
+    ///
+    /// * `text` - Identifier, possibly quoted
+    ///
+    /// # Returns
+    ///
+    /// The name the database looks up: quoted identifiers without their
+    /// quotes, unquoted ones folded
+    pub fn normalize(&self, text: &str) -> String {
+        let (name, quoted) = unquote(text);
+        if quoted {
+            return name.to_string();
+        }
+        match self.fold {
+            IdentifierCase::Lower => name.to_lowercase(),
+            IdentifierCase::Upper => name.to_uppercase(),
+            IdentifierCase::Preserve => name.to_string(),
+        }
+    }
+
+    /// Quote a name with the dialect's quote character
+    pub fn quote(&self, name: &str) -> String {
+        let quote = self.quote.to_string();
+        format!(
+            "{}{}{}",
+            quote,
+            name.replace(&quote, &quote.repeat(2)),
+            quote
+        )
+    }
+
+    /// Check whether a column or alias name written in a query names another
+    ///
+    /// # Arguments
+    ///
+    /// * `name` - Name as written, without quotes
+    /// * `quoted` - Whether the name was quoted
+    /// * `other` - Name to compare with, such as a column reported by the
+    ///   database
+    pub fn matches(&self, name: &str, quoted: bool, other: &str) -> bool {
+        if quoted && self.quoted_case_sensitive {
+            name == other
+        } else {
+            name.eq_ignore_ascii_case(other)
+        }
+    }
+
+    /// Check whether a table name written in a query names another
+    ///
+    /// Like [`Self::matches`], with the table name rules of the dialect.
+    pub fn matches_table(&self, name: &str, quoted: bool, other: &str) -> bool {
+        if self.case_sensitive_tables {
+            name == other
+        } else {
+            self.matches(name, quoted, other)
+        }
+    }
+
+    /// Key under which equal names are deduplicated
+    ///
+    /// Names written differently but naming the same object get the same key.
+    pub fn key(&self, name: &str, quoted: bool) -> String {
+        if quoted && self.quoted_case_sensitive {
+            name.to_string()
+        } else {
+            name.to_ascii_lowercase()
+        }
+    }
+
+    /// Key under which equal table names are deduplicated
+    ///
+    /// Like [`Self::key`], with the table name rules of the dialect.
+    pub fn table_key(&self, name: &str, quoted: bool) -> String {
+        if self.case_sensitive_tables {
+            name.to_string()
+        } else {
+            self.key(name, quoted)
+        }
+    }
+}
+
+/// Strip identifier quotes (`"name"`, `` `name` ``, `[name]`)
+///
+/// # Returns
+///
+/// The identifier without its quotes, and whether it was quoted. Doubled
+/// quote characters are kept as written.
+pub fn unquote(text: &str) -> (&str, bool) {
+    let text = text.trim();
+    let quoted = text.len() >= 2
+        && matches!(
+            (text.chars().next(), text.chars().last()),
+            (Some('"'), Some('"')) | (Some('`'), Some('`')) | (Some('['), Some(']'))
+        );
+    if quoted {
+        (&text[1..text.len() - 1], true)
+    } else {
+        (text, false)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_normalize() {
+        let cases = [
+            (Dialect::PostgreSQL, "Users", "users"),
+            (Dialect::PostgreSQL, "\"Users\"", "Users"),
+            (Dialect::CockroachDB, "ID", "id"),
+            (Dialect::MySQL, "Users", "Users"),
+            (Dialect::MySQL, "`Users`", "Users"),
+            (Dialect::TiDB, " ID ", "ID"),
+        ];
+        for (dialect, text, expected) in cases {
+            assert_eq!(
+                dialect.rules().normalize(text),
+                expected,
+                "{:?} {}",
+                dialect,
+                text
+            );
+        }
+    }
+
+    #[test]
+    fn test_column_comparison() {
+        // Dialect, name as written, name in the catalog, expected match
+        let cases = [
+            (Dialect::PostgreSQL, "ID", "id", true),
+            (Dialect::PostgreSQL, "id", "Id", true),
+            (Dialect::PostgreSQL, "\"id\"", "id", true),
+            (Dialect::PostgreSQL, "\"ID\"", "id", false),
+            (Dialect::PostgreSQL, "\"Id\"", "Id", true),
+            (Dialect::MySQL, "ID", "id", true),
+            (Dialect::MySQL, "`ID`", "id", true),
+            (Dialect::MariaDB, "`Id`", "iD", true),
+            (Dialect::MySQL, "name", "id", false),
+        ];
+        for (dialect, text, other, expected) in cases {
+            let (name, quoted) = unquote(text);
+            assert_eq!(
+                dialect.rules().matches(name, quoted, other),
+                expected,
+                "{:?} {} = {}",
+                dialect,
+                text,
+                other
+            );
+        }
+    }
+
+    #[test]
+    fn test_table_comparison() {
+        let case_sensitive = DialectRules {
+            case_sensitive_tables: true,
+            ..Dialect::MySQL.rules()
+        };
+
+        // Rules, name as written, name in the catalog, expected match
+        let cases = [
+            (Dialect::PostgreSQL.rules(), "Users", "users", true),
+            (Dialect::PostgreSQL.rules(), "\"Users\"", "users", false),
+            (Dialect::MySQL.rules(), "Users", "users", true),
+            (Dialect::MySQL.rules(), "`Users`", "users", true),
+            (case_sensitive, "Users", "users", false),
+            (case_sensitive, "`users`", "users", true),
+            // Without dialect rules, nothing compares with case
+            (DialectRules::default(), "\"Users\"", "users", true),
+        ];
+        for (rules, text, other, expected) in cases {
+            let (name, quoted) = unquote(text);
+            assert_eq!(
+                rules.matches_table(name, quoted, other),
+                expected,
+                "{:?} {} = {}",
+                rules,
+                text,
+                other
+            );
+        }
+        assert!(case_sensitive.matches("ID", false, "id"));
+    }
+
+    #[test]
+    fn test_keys_and_quoting() {
+        let postgres = Dialect::PostgreSQL.rules();
+        assert_eq!(postgres.key("Users", false), postgres.key("users", false));
+        assert_ne!(postgres.key("Users", true), postgres.key("users", true));
+        let mysql = Dialect::MySQL.rules();
+        assert_eq!(mysql.key("Users", true), mysql.key("users", false));
+        let case_sensitive = DialectRules {
+            case_sensitive_tables: true,
+            ..mysql
+        };
+        assert_ne!(
+            case_sensitive.table_key("Users", false),
+            case_sensitive.table_key("users", false)
+        );
+
+        assert_eq!(postgres.quote("Order \"Items\""), "\"Order \"\"Items\"\"\"");
+        assert_eq!(mysql.quote("order"), "`order`");
+        assert_eq!(unquote("[id]"), ("id", true));
+        assert_eq!(unquote("plain"), ("plain", false));
+    }
+}
@@ -14,6 +14,7 @@
 
 pub mod dialect;
 pub mod expr;
+pub mod identifier;
 pub mod metadata;
 pub mod query;
 
@@ -21,6 +22,7 @@ pub mod query;
 pub use dialect::{Dialect, DialectExtensions};
 pub use expr::{BinaryOp, ColumnRef, Expr, Literal, UnaryOp};
 pub use expr::{WindowFrame, WindowFrameBound, WindowFrameUnits, WindowSpec};
+pub use identifier::{DialectRules, IdentifierCase};
 pub use metadata::{
     ColumnMetadata, DataType, FunctionMetadata, FunctionParameter, FunctionType, TableMetadata,
     TableReference, TableType,
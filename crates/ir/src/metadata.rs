@@ -70,6 +70,11 @@ pub enum TableType {
 pub struct TableReference {
     pub table: String,
     pub column: String,
+    /// Foreign key constraint name, if known
+    ///
+    /// Columns sharing a constraint name form a composite foreign key.
+    #[serde(default)]
+    pub constraint: Option<String>,
 }
 
 /// Metadata for a database column
@@ -138,6 +143,23 @@ impl ColumnMetadata {
         self.references = Some(TableReference {
             table: table.into(),
             column: column.into(),
+            constraint: None,
+        });
+        self
+    }
+
+    /// Builder method: set foreign key reference with its constraint name
+    pub fn with_foreign_key_constraint(
+        mut self,
+        constraint: impl Into<String>,
+        table: impl Into<String>,
+        column: impl Into<String>,
+    ) -> Self {
+        self.is_foreign_key = true;
+        self.references = Some(TableReference {
+            table: table.into(),
+            column: column.into(),
+            constraint: Some(constraint.into()),
         });
         self
     }
@@ -150,6 +172,12 @@ pub struct TableMetadata {
     pub name: String,
     /// Schema/database name
     pub schema: String,
+    /// Database (catalog) name, if the table is not in the connected database
+    ///
+    /// Set for PostgreSQL tables loaded from other databases of the server;
+    /// such tables are referenced as `catalog.schema.table`.
+    #[serde(default)]
+    pub catalog: Option<String>,
     /// Column definitions
     pub columns: Vec<ColumnMetadata>,
     /// Estimated row count (for query planning)
@@ -158,6 +186,9 @@ pub struct TableMetadata {
     pub comment: Option<String>,
     /// Table type (TABLE, VIEW, MATERIALIZED VIEW, etc.)
     pub table_type: TableType,
+    /// Query of a view or materialized view, as reported by the database
+    #[serde(default)]
+    pub definition: Option<String>,
 }
 
 impl TableMetadata {
@@ -166,13 +197,21 @@ impl TableMetadata {
         Self {
             name: name.into(),
             schema: schema.into(),
+            catalog: None,
             columns: Vec::new(),
             row_count_estimate: None,
             comment: None,
             table_type: TableType::Table,
+            definition: None,
         }
     }
 
+    /// Builder method: set the database (catalog) name
+    pub fn with_catalog(mut self, catalog: impl Into<String>) -> Self {
+        self.catalog = Some(catalog.into());
+        self
+    }
+
     /// Builder method: add columns
     pub fn with_columns(mut self, columns: Vec<ColumnMetadata>) -> Self {
         self.columns = columns;
@@ -197,6 +236,21 @@ impl TableMetadata {
         self
     }
 
+    /// Builder method: set the query of a view
+    pub fn with_definition(mut self, definition: impl Into<String>) -> Self {
+        self.definition = Some(definition.into());
+        self
+    }
+
+    /// Name qualified with the schema, and with the database if set
+    /// (`schema.table` or `db.schema.table`)
+    pub fn qualified_name(&self) -> String {
+        match &self.catalog {
+            Some(catalog) => format!("{}.{}.{}", catalog, self.schema, self.name),
+            None => format!("{}.{}", self.schema, self.name),
+        }
+    }
+
     /// Get column by name
     pub fn get_column(&self, name: &str) -> Option<&ColumnMetadata> {
         self.columns.iter().find(|c| c.name == name)
@@ -247,6 +301,9 @@ pub struct FunctionMetadata {
     pub example: Option<String>,
     /// Whether this is a built-in function
     pub is_builtin: bool,
+    /// Body of a user-defined function, as reported by the database
+    #[serde(default)]
+    pub definition: Option<String>,
 }
 
 impl FunctionMetadata {
@@ -260,6 +317,7 @@ impl FunctionMetadata {
             description: None,
             example: None,
             is_builtin: true,
+            definition: None,
         }
     }
 
@@ -287,6 +345,12 @@ impl FunctionMetadata {
         self
     }
 
+    /// Builder method: set the body of a user-defined function
+    pub fn with_definition(mut self, definition: impl Into<String>) -> Self {
+        self.definition = Some(definition.into());
+        self
+    }
+
     /// Get function signature (for display in completion)
     pub fn signature(&self) -> String {
         let params: Vec<String> = self
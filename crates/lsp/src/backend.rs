@@ -52,103 +52,964 @@
 //! }
 //! ```
 
+use crate::call_hierarchy;
 use crate::catalog_manager::CatalogManager;
-use crate::completion::CompletionEngine;
+use crate::client_capabilities::ClientFeatures;
+use crate::commands;
+use crate::completion::cache::CompletionCache;
+use crate::completion::{self, CompletionEngine};
 use crate::config::EngineConfig;
-use crate::diagnostic::{DiagnosticCollector, publish_diagnostics_for_document};
+use crate::connection_health::DEFAULT_HEALTH_CHECK_INTERVAL_SECS;
+use crate::diagnostic::{
+    DiagnosticCollector, DiagnosticSources, DocumentDiagnostics, collect_document_diagnostics,
+    diagnostics_to_publish,
+};
+use crate::diagnostic_scheduler::{DiagnosticScheduler, FocusDocumentParams};
 use crate::document::{Document, DocumentError, DocumentStore, ParseMetadata};
+use crate::document_link;
+use crate::embedded;
+use crate::formatting;
+use crate::highlight;
+use crate::lint;
+use crate::logging;
+use crate::migrations::{self, MigrationCatalog, MigrationOverlay};
+use crate::outbound::{DEFAULT_QUEUE_CAPACITY, OutboundSender};
+use crate::parameters::{find_parameters, suppress_parameter_diagnostics};
+use crate::parent_process;
 use crate::request_context::RequestContext;
+use crate::request_log::RequestLogger;
+use crate::schema_cache;
+use crate::schema_dependencies::SchemaDependencies;
+use crate::selection_range;
+use crate::server_version::{self, ServerVersion, VersionMatch};
+use crate::signature_help;
 use crate::symbols::{SymbolBuilder, SymbolCatalogFetcher, SymbolError, SymbolRenderer};
 use crate::sync::DocumentSync;
-use std::sync::Arc;
+use crate::workspace_index::{self, WorkspaceIndex};
+use std::collections::HashMap;
+use std::path::PathBuf;
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::{Arc, Mutex, OnceLock, RwLock as StdRwLock};
+use std::time::Duration;
 use tokio::sync::RwLock;
+use tokio::task::JoinHandle;
 use tower_lsp::jsonrpc::Result;
+use tower_lsp::lsp_types::request::WorkDoneProgressCreate;
 use tower_lsp::lsp_types::*;
 use tower_lsp::{Client, LanguageServer};
 use tracing::{debug, error, info, warn};
+use unified_sql_lsp_catalog::{Catalog, CatalogError};
+use unified_sql_lsp_ir::DialectRules;
 
 /// LSP backend implementation
 ///
 /// Main entry point for all LSP protocol operations.
 pub struct LspBackend {
     client: Client,
+    /// Queue of the diagnostics, progress and log messages to the client
+    outbound: OutboundSender,
     documents: Arc<DocumentStore>,
     config: Arc<RwLock<Option<EngineConfig>>>,
     doc_sync: Arc<DocumentSync>,
     request_context: RequestContext,
     diagnostic_collector: DiagnosticCollector,
+    request_logger: Arc<RequestLogger>,
+    /// Client features, known once initialized
+    client_features: OnceLock<ClientFeatures>,
+    /// Background tasks, stopped on shutdown
+    background: Arc<BackgroundTasks>,
+    /// Diagnostics last published per document
+    published_diagnostics: tokio::sync::Mutex<HashMap<Url, DocumentDiagnostics>>,
+    /// Turns of the diagnostic runs of each document
+    diagnostic_runs: Mutex<HashMap<Url, Arc<tokio::sync::Mutex<()>>>>,
+    /// Bounds and coalesces the diagnostic runs of all documents
+    diagnostic_scheduler: DiagnosticScheduler,
+    /// Workspace folders, known once initialized
+    workspace_folders: OnceLock<Vec<PathBuf>>,
+    /// Identifiers of the workspace's SQL files
+    workspace_index: Arc<StdRwLock<WorkspaceIndex>>,
+    /// Schema changes of the migration files
+    migrations: StdRwLock<Arc<MigrationOverlay>>,
+    /// Candidates of the last completion, for re-queries while typing
+    completion_cache: Arc<CompletionCache>,
+    /// Whether on-type formatting is registered with the client
+    on_type_formatting_registered: AtomicBool,
+    /// Whether to exit when the editor process is gone
+    exit_with_parent: bool,
 }
 
+/// Custom request returning the request statistics of the server
+pub const STATS_METHOD: &str = "sql/stats";
+
+/// Registration id of the SQL file watcher
+const SQL_FILE_WATCHER_ID: &str = "unified-sql-lsp/sql-files";
+
+/// Registration id of on-type formatting
+const ON_TYPE_FORMATTING_ID: &str = "unified-sql-lsp/on-type-formatting";
+
+/// Event running the diagnostics of a document
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum DiagnosticTrigger {
+    /// The document was edited
+    Edit,
+    /// The document was opened or saved
+    Save,
+}
+
+/// Background tasks of the server
+///
+/// Shared with the editor process watchdog, which stops them before
+/// exiting like `shutdown` does.
+#[derive(Default)]
+struct BackgroundTasks {
+    /// Running schema prefetch
+    schema_prefetch: Mutex<Option<JoinHandle<()>>>,
+    /// Running connection health checks
+    health_checks: Mutex<Option<JoinHandle<()>>>,
+    /// Running workspace indexing
+    workspace_indexing: Mutex<Option<JoinHandle<()>>>,
+}
+
+impl BackgroundTasks {
+    /// Run a task in a slot, aborting the task it replaces
+    fn start(slot: &Mutex<Option<JoinHandle<()>>>, task: JoinHandle<()>) {
+        if let Some(previous) = slot.lock().unwrap().replace(task) {
+            previous.abort();
+        }
+    }
+
+    /// Abort the task of a slot, if any
+    fn cancel(slot: &Mutex<Option<JoinHandle<()>>>) {
+        if let Some(task) = slot.lock().unwrap().take() {
+            task.abort();
+        }
+    }
+
+    /// Abort all running tasks
+    fn cancel_all(&self) {
+        Self::cancel(&self.health_checks);
+        Self::cancel(&self.schema_prefetch);
+        Self::cancel(&self.workspace_indexing);
+    }
+}
+
+/// Counter making server-initiated progress tokens unique
+static PROGRESS_TOKEN_COUNTER: AtomicU64 = AtomicU64::new(0);
+
 impl LspBackend {
     pub fn new(client: Client) -> Self {
+        Self::with_catalog_manager(client, CatalogManager::new())
+    }
+
+    /// Create a backend resolving catalogs through the given manager
+    ///
+    /// # Arguments
+    ///
+    /// * `client` - The LSP client
+    /// * `catalog_manager` - Catalog manager, e.g. with registered catalogs
+    ///   (see [`CatalogManager::register_catalog`])
+    pub fn with_catalog_manager(client: Client, catalog_manager: CatalogManager) -> Self {
         debug!("!!! LSP: LspBackend::new() called");
         let config = Arc::new(RwLock::new(None));
         let doc_sync = Arc::new(DocumentSync::new(config.clone()));
-        let catalog_manager = Arc::new(RwLock::new(CatalogManager::new()));
+        let catalog_manager = Arc::new(RwLock::new(catalog_manager));
         let request_context = RequestContext::new(config.clone(), catalog_manager.clone());
 
         debug!("!!! LSP: LspBackend created successfully");
         Self {
+            outbound: OutboundSender::spawn(client.clone(), DEFAULT_QUEUE_CAPACITY),
             client,
             documents: Arc::new(DocumentStore::new()),
             config,
             doc_sync,
             request_context,
             diagnostic_collector: DiagnosticCollector::new(),
+            request_logger: Arc::new(RequestLogger::default()),
+            client_features: OnceLock::new(),
+            background: Arc::new(BackgroundTasks::default()),
+            published_diagnostics: tokio::sync::Mutex::new(HashMap::new()),
+            diagnostic_runs: Mutex::new(HashMap::new()),
+            diagnostic_scheduler: DiagnosticScheduler::default(),
+            workspace_folders: OnceLock::new(),
+            workspace_index: Arc::new(StdRwLock::new(WorkspaceIndex::new())),
+            migrations: StdRwLock::new(Arc::new(MigrationOverlay::default())),
+            completion_cache: Arc::new(CompletionCache::new()),
+            on_type_formatting_registered: AtomicBool::new(false),
+            exit_with_parent: false,
         }
     }
 
+    /// Builder method: exit the process when the editor that started the
+    /// server is gone
+    ///
+    /// The editor process is the `processId` sent to `initialize`. Only for
+    /// servers owned by one editor (stdio); a TCP server outlives its
+    /// clients.
+    pub fn with_parent_watchdog(mut self) -> Self {
+        self.exit_with_parent = true;
+        self
+    }
+
     pub fn documents(&self) -> &DocumentStore {
         &self.documents
     }
 
+    /// Client features, or those of a client without capabilities before
+    /// `initialize`
+    pub fn client_features(&self) -> ClientFeatures {
+        self.client_features.get().cloned().unwrap_or_default()
+    }
+
     pub async fn get_config(&self) -> Option<EngineConfig> {
         self.config.read().await.clone()
     }
 
     pub async fn set_config(&self, config: EngineConfig) {
         info!("Engine configuration updated: dialect={:?}", config.dialect);
+        self.request_logger
+            .set_slow_threshold(Duration::from_millis(config.slow_request_threshold_ms));
         *self.config.write().await = Some(config);
+        self.request_context.invalidate_schemas();
+        self.completion_cache.clear();
+        // Connections may be reopened, ending their sessions
+        self.request_context.session_objects().clear();
+    }
+
+    /// Handle a `sql/stats` request
+    ///
+    /// # Returns
+    ///
+    /// The request statistics per LSP method since the last `sql.resetStats`,
+    /// and the state of the diagnostics and outbound notification queues
+    pub async fn stats(&self) -> Result<serde_json::Value> {
+        Ok(serde_json::json!({
+            "requests": self.request_logger.stats(),
+            "diagnostics": self.diagnostic_scheduler.stats(),
+            "outbound": self.outbound.stats(),
+        }))
+    }
+
+    /// Handle a `sql/focusDocument` notification
+    ///
+    /// Diagnostics of the focused document run before those of other
+    /// documents.
+    pub async fn focus_document(&self, params: FocusDocumentParams) {
+        self.diagnostic_scheduler.set_focus(&params.uri);
     }
 
     async fn log_message(&self, message: &str, message_type: MessageType) {
-        self.client.log_message(message_type, message).await;
+        self.outbound.log_message(message_type, message);
     }
 
     async fn show_message(&self, message: &str, message_type: MessageType) {
-        self.client.show_message(message_type, message).await;
+        self.outbound.show_message(message_type, message);
     }
 
-    #[allow(dead_code)]
-    async fn publish_diagnostics(&self, uri: Url, diagnostics: Vec<Diagnostic>) {
-        self.client
-            .publish_diagnostics(uri, diagnostics, None)
-            .await;
+    /// Publish diagnostics for a document
+    ///
+    /// Shared helper for publishing diagnostics after parsing. The trigger
+    /// selects the diagnostic sources that run (see
+    /// [`DiagnosticTriggers`](crate::config::DiagnosticTriggers)); the other
+    /// sources keep their last results.
+    ///
+    /// Runs go through the diagnostic scheduler: an edit-triggered run still
+    /// queued when the document is edited again is dropped.
+    async fn publish_document_diagnostics(&self, uri: &Url, trigger: DiagnosticTrigger) {
+        let run = || self.run_document_diagnostics(uri, trigger);
+        match trigger {
+            DiagnosticTrigger::Edit => {
+                if !self.diagnostic_scheduler.run_latest(uri, run).await {
+                    debug!("Diagnostics superseded by a newer edit: {}", uri);
+                }
+            }
+            DiagnosticTrigger::Save => self.diagnostic_scheduler.run(uri, run).await,
+        }
     }
 
-    /// Publish diagnostics for a document
+    /// Compute and publish the diagnostics of a document
     ///
-    /// Shared helper for publishing diagnostics after parsing.
-    async fn publish_document_diagnostics(&self, uri: &Url) {
+    /// Runs of one document take turns, so an older run never publishes
+    /// over a newer one; runs of different documents overlap. The published
+    /// diagnostics are only locked to merge the result.
+    async fn run_document_diagnostics(&self, uri: &Url, trigger: DiagnosticTrigger) {
+        let turn = self
+            .diagnostic_runs
+            .lock()
+            .unwrap()
+            .entry(uri.clone())
+            .or_default()
+            .clone();
+        let _turn = turn.lock().await;
+
         let updated_document = self.documents.get_document(uri).await;
         if let Some(doc) = updated_document {
             let source = doc.get_content();
             let tree_ref = doc.tree();
-            publish_diagnostics_for_document(
+            let config = self.get_config().await;
+            let triggers = config
+                .as_ref()
+                .map(|config| config.diagnostics)
+                .unwrap_or_default();
+            let sources = match trigger {
+                DiagnosticTrigger::Edit => triggers.on_type,
+                DiagnosticTrigger::Save => triggers.on_save,
+            };
+            let schema_catalog = if sources.schema {
+                self.schema_diagnostics_catalog().await
+            } else {
+                None
+            };
+            let severities = config
+                .as_ref()
+                .map(|config| config.diagnostic_severities.clone())
+                .unwrap_or_default();
+            let parameters = config
+                .as_ref()
+                .map(|config| config.parameter_styles(uri.path()))
+                .unwrap_or_else(|| EngineConfig::default().parameter_styles(uri.path()));
+            let lint_config = config.map(|config| config.lint);
+            let fresh = collect_document_diagnostics(
                 &self.diagnostic_collector,
-                &self.client,
-                uri.clone(),
+                uri,
                 &tree_ref,
                 &source,
+                schema_catalog
+                    .as_ref()
+                    .map(|(catalog, severity, rules)| (catalog.as_ref(), *severity, *rules)),
+                lint_config.as_ref(),
+                sources,
+            )
+            .await;
+
+            // Queued under the lock, so a concurrent publish of the
+            // document (a migration reload) is not overwritten by this one
+            let mut published = self.published_diagnostics.lock().await;
+            let document = published.entry(uri.clone()).or_default();
+            document.update(fresh, sources);
+            let diagnostics = diagnostics_to_publish(
+                document,
+                uri,
+                &source,
+                parameters,
+                &severities,
+                &self.client_features(),
+            );
+            if !diagnostics.is_empty() {
+                info!("Publishing {} diagnostics for {}", diagnostics.len(), uri);
+            }
+            self.outbound
+                .publish_diagnostics(uri.clone(), diagnostics, None);
+        }
+
+        // Forget the turns of a document no other run waits for
+        let mut runs = self.diagnostic_runs.lock().unwrap();
+        if Arc::strong_count(&turn) == 2 {
+            runs.remove(uri);
+        }
+    }
+
+    /// Resolve the catalog used for schema diagnostics
+    ///
+    /// Returns `None` (schema diagnostics suppressed) when they are disabled,
+    /// no database connection is configured, or the catalog is unavailable.
+    /// Otherwise returns the catalog with the severity of the diagnostics and
+    /// the rules names are compared with.
+    async fn schema_diagnostics_catalog(
+        &self,
+    ) -> Option<(Arc<dyn Catalog>, DiagnosticSeverity, DialectRules)> {
+        let config = self.get_config().await?;
+        let severity = config.schema_diagnostics_severity?;
+        if !config.has_connection() {
+            return None;
+        }
+
+        match self.request_context.catalog_for_config(&config).await {
+            Ok(catalog) => Some((
+                self.with_migrations(catalog),
+                severity,
+                config.identifier_rules(),
+            )),
+            Err(e) => {
+                debug!("Schema diagnostics suppressed, catalog unavailable: {}", e);
+                None
+            }
+        }
+    }
+
+    /// Start the periodic connection health checks
+    ///
+    /// Every interval, all open connections are pinged and state changes are
+    /// pushed to the client as `sql/connectionStatus` notifications. The
+    /// interval is re-read from the config on every round, so enabling or
+    /// disabling checks takes effect without a restart. The checks run
+    /// until the server shuts down; running checks are replaced.
+    fn spawn_health_checks(&self) {
+        let config = self.config.clone();
+        let context = self.request_context.clone();
+        let outbound = self.outbound.clone();
+
+        let checks = tokio::spawn(async move {
+            loop {
+                let (interval, timeout) = match config.read().await.as_ref() {
+                    Some(config) => (config.health_check_interval_secs, config.query_timeout_secs),
+                    None => (
+                        DEFAULT_HEALTH_CHECK_INTERVAL_SECS,
+                        EngineConfig::default().query_timeout_secs,
+                    ),
+                };
+                if interval == 0 {
+                    tokio::time::sleep(Duration::from_secs(DEFAULT_HEALTH_CHECK_INTERVAL_SECS))
+                        .await;
+                    continue;
+                }
+
+                tokio::time::sleep(Duration::from_secs(interval)).await;
+                for status in context
+                    .check_connection_health(Duration::from_secs(timeout))
+                    .await
+                {
+                    outbound.connection_status(status);
+                }
+            }
+        });
+        BackgroundTasks::start(&self.background.health_checks, checks);
+    }
+
+    /// Push schema changes found by background refreshes to the client
+    ///
+    /// Refreshes that leave the tables and columns unchanged send nothing.
+    /// Changes drop the cached completions.
+    fn watch_schema_changes(&self) {
+        let outbound = self.outbound.clone();
+        let completion_cache = self.completion_cache.clone();
+        self.request_context.on_schema_changed(move |change| {
+            info!(
+                "Schema of {} changed in {:?}",
+                change.connection, change.schemas
+            );
+            completion_cache.clear();
+            outbound.schema_changed(change);
+        });
+    }
+
+    /// Start prefetching the configured schemas in the background
+    ///
+    /// A running prefetch is cancelled first. Progress is reported through
+    /// `window/workDoneProgress` when the client supports it; otherwise the
+    /// prefetch runs silently. Cached completions are dropped once the
+    /// schemas are loaded.
+    async fn spawn_schema_prefetch(&self) {
+        let Some(config) = self.get_config().await else {
+            return;
+        };
+        let context = self.request_context.clone();
+        let client = self.client.clone();
+        let outbound = self.outbound.clone();
+        let completion_cache = self.completion_cache.clone();
+        let report_progress = self.client_features().work_done_progress;
+
+        let prefetch = tokio::spawn(async move {
+            let token = match report_progress {
+                true => create_progress_token(&client).await,
+                false => None,
+            };
+            let progress = commands::ClientProgress::new(outbound, token);
+            schema_cache::prefetch_schemas(&context, &[config], &progress).await;
+            completion_cache.clear();
+        });
+        BackgroundTasks::start(&self.background.schema_prefetch, prefetch);
+    }
+
+    /// Detect the database server version in the background
+    ///
+    /// The supported version best matching the server replaces the
+    /// configured version. A server without a supported version is reported
+    /// with `window/showMessage`; the closest supported version is used
+    /// unless `strictVersion` is set.
+    async fn spawn_version_detection(&self) {
+        let Some(configured) = self.get_config().await else {
+            return;
+        };
+        if !configured.has_connection() || configured.schema_file.is_some() {
+            return;
+        }
+        let context = self.request_context.clone();
+        let config = self.config.clone();
+        let outbound = self.outbound.clone();
+
+        tokio::spawn(async move {
+            let text = match context.server_version(&configured).await {
+                Ok(text) => text,
+                Err(e) => {
+                    debug!("Server version not detected: {}", e);
+                    return;
+                }
+            };
+            let Some(server) = ServerVersion::parse(&text) else {
+                debug!("Unrecognized server version: {}", text);
+                return;
+            };
+            let Some(matched) = server_version::negotiate(configured.dialect, &server) else {
+                return;
+            };
+
+            let version = match matched {
+                VersionMatch::Exact(version) | VersionMatch::Major(version) => version,
+                VersionMatch::Closest(_) if configured.strict_version => {
+                    let message = format!(
+                        "{:?} {} is not supported; keeping the configured version {:?}",
+                        configured.dialect, server, configured.version
+                    );
+                    outbound.show_message(MessageType::WARNING, message);
+                    return;
+                }
+                VersionMatch::Closest(closest) => {
+                    let message = format!(
+                        "{:?} {} is not supported; using {:?}",
+                        configured.dialect, server, closest
+                    );
+                    outbound.show_message(MessageType::WARNING, message);
+                    closest
+                }
+            };
+
+            if version != configured.version {
+                info!(
+                    "Server runs {:?} {}, using {:?}",
+                    configured.dialect, server, version
+                );
+            }
+            if let Some(current) = config.write().await.as_mut()
+                && current.connection_string == configured.connection_string
+                && current.dialect == configured.dialect
+            {
+                current.version = version;
+            }
+        });
+    }
+
+    /// Workspace folders of the session
+    fn workspace_folders(&self) -> Vec<PathBuf> {
+        self.workspace_folders.get().cloned().unwrap_or_default()
+    }
+
+    /// Index the SQL files of the workspace folders in the background
+    ///
+    /// A running indexing is aborted and the index rebuilt from scratch.
+    async fn spawn_workspace_indexing(&self) {
+        let config = self.get_config().await.unwrap_or_default();
+        let index = self.workspace_index.clone();
+        let roots = self.workspace_folders();
+        let client = self.client.clone();
+        let outbound = self.outbound.clone();
+        let report_progress = self.client_features().work_done_progress;
+
+        let indexing = tokio::spawn(async move {
+            let token = match report_progress {
+                true => create_progress_token(&client).await,
+                false => None,
+            };
+            let progress = commands::ClientProgress::new(outbound, token);
+            let indexed = workspace_index::index_workspace(
+                &index,
+                roots,
+                config.workspace_index,
+                config.dialect,
+                &progress,
             )
             .await;
+            info!("Indexed {} workspace SQL files", indexed);
+        });
+        BackgroundTasks::start(&self.background.workspace_indexing, indexing);
+    }
+
+    /// Ask the client to report changes of SQL files on disk
+    async fn register_file_watcher(&self) {
+        if !self.client_features().watched_files_registration {
+            debug!("Client cannot register file watchers, workspace index follows open files only");
+            return;
+        }
+
+        let options = DidChangeWatchedFilesRegistrationOptions {
+            watchers: vec![FileSystemWatcher {
+                glob_pattern: GlobPattern::String("**/*.sql".to_string()),
+                kind: None,
+            }],
+        };
+        let registration = Registration {
+            id: SQL_FILE_WATCHER_ID.to_string(),
+            method: "workspace/didChangeWatchedFiles".to_string(),
+            register_options: serde_json::to_value(options).ok(),
+        };
+        if let Err(e) = self.client.register_capability(vec![registration]).await {
+            warn!("Failed to register the SQL file watcher: {}", e);
+        }
+    }
+
+    /// Register or unregister on-type formatting to follow the configuration
+    ///
+    /// The trigger characters are only advertised while `onTypeFormatting`
+    /// is enabled. The configuration arrives after `initialize`, so this
+    /// needs dynamic registration.
+    async fn update_on_type_formatting(&self, enabled: bool) {
+        if enabled == self.on_type_formatting_registered.load(Ordering::SeqCst) {
+            return;
+        }
+        if !self.client_features().on_type_formatting_registration {
+            if enabled {
+                debug!("Client cannot register on-type formatting, leaving it off");
+            }
+            return;
+        }
+
+        let method = "textDocument/onTypeFormatting".to_string();
+        let result = if enabled {
+            let options = DocumentOnTypeFormattingOptions {
+                first_trigger_character: formatting::ON_TYPE_FIRST_TRIGGER.to_string(),
+                more_trigger_character: Some(
+                    formatting::ON_TYPE_MORE_TRIGGERS
+                        .iter()
+                        .map(|c| c.to_string())
+                        .collect(),
+                ),
+            };
+            let registration = Registration {
+                id: ON_TYPE_FORMATTING_ID.to_string(),
+                method,
+                register_options: serde_json::to_value(options).ok(),
+            };
+            self.client.register_capability(vec![registration]).await
+        } else {
+            let unregistration = Unregistration {
+                id: ON_TYPE_FORMATTING_ID.to_string(),
+                method,
+            };
+            self.client
+                .unregister_capability(vec![unregistration])
+                .await
+        };
+
+        match result {
+            Ok(()) => self
+                .on_type_formatting_registered
+                .store(enabled, Ordering::SeqCst),
+            Err(e) => warn!(
+                "Failed to update the on-type formatting registration: {}",
+                e
+            ),
         }
     }
 
+    /// Index the current content of an open document
+    async fn index_open_document(&self, uri: &Url) {
+        let Some(document) = self.documents.get_document(uri).await else {
+            return;
+        };
+        let Some(tree) = document.tree() else {
+            return;
+        };
+        let Ok(tree) = tree.try_lock() else {
+            return;
+        };
+        self.workspace_index.write().unwrap().index_tree(
+            uri.clone(),
+            &tree,
+            &document.get_content(),
+        );
+    }
+
+    /// Re-index a file from disk, or remove it if it is no longer indexable
+    async fn index_file_on_disk(&self, uri: &Url) {
+        let config = self.get_config().await.unwrap_or_default();
+        let indexable = config.workspace_index.enabled
+            && uri.to_file_path().is_ok_and(|path| {
+                self.workspace_folders()
+                    .iter()
+                    .any(|root| workspace_index::is_indexable(root, &path, &config.workspace_index))
+            });
+        let source = match indexable {
+            true => uri
+                .to_file_path()
+                .ok()
+                .and_then(|path| std::fs::read_to_string(path).ok()),
+            false => None,
+        };
+
+        let mut index = self.workspace_index.write().unwrap();
+        match source {
+            Some(source) => index.index_source(uri.clone(), &source, config.dialect),
+            None => {
+                index.remove(uri);
+            }
+        }
+    }
+
+    /// Apply the migration overlay, if any, to a catalog
+    fn with_migrations(&self, catalog: Arc<dyn Catalog>) -> Arc<dyn Catalog> {
+        let overlay = self.migrations.read().unwrap().clone();
+        match overlay.is_empty() {
+            true => catalog,
+            false => Arc::new(MigrationCatalog::new(catalog, overlay)),
+        }
+    }
+
+    /// Check whether a file is a migration file of the workspace
+    async fn is_migration_file(&self, uri: &Url) -> bool {
+        let Some(pattern) = self.get_config().await.and_then(|config| config.migrations) else {
+            return false;
+        };
+        uri.to_file_path().is_ok_and(|path| {
+            self.workspace_folders()
+                .iter()
+                .any(|root| migrations::is_migration(root, &path, &pattern))
+        })
+    }
+
+    /// Reload the migration files and publish their conflicts
+    ///
+    /// Conflicts are only reported against a database schema: without a
+    /// connection or schema file, every change to an existing table would
+    /// conflict.
+    async fn reload_migrations(&self) {
+        let config = self.get_config().await.unwrap_or_default();
+        let overlay = match config.migrations.clone() {
+            Some(pattern) => {
+                let roots = self.workspace_folders();
+                tokio::task::spawn_blocking(move || {
+                    MigrationOverlay::load(&migrations::discover_migrations(&roots, &pattern))
+                })
+                .await
+                .unwrap_or_else(|e| {
+                    warn!("Failed to load migrations: {}", e);
+                    MigrationOverlay::default()
+                })
+            }
+            None => MigrationOverlay::default(),
+        };
+        let overlay = Arc::new(overlay);
+        *self.migrations.write().unwrap() = overlay.clone();
+        // Cached completions may list tables or columns that changed
+        self.completion_cache.clear();
+
+        let mut conflicts: HashMap<Url, Vec<_>> = HashMap::new();
+        if !overlay.is_empty() && (config.has_connection() || config.schema_file.is_some()) {
+            match self.request_context.catalog_for_config(&config).await {
+                Ok(catalog) => match MigrationCatalog::new(catalog, overlay).resolve().await {
+                    Ok((_, found)) => {
+                        for conflict in found {
+                            conflicts
+                                .entry(conflict.uri.clone())
+                                .or_default()
+                                .push(conflict.to_diagnostic());
+                        }
+                    }
+                    Err(e) => debug!("Migration conflicts not checked: {}", e),
+                },
+                Err(e) => debug!("Migration conflicts not checked: {}", e),
+            }
+        }
+
+        let stale: Vec<Url> = self
+            .published_diagnostics
+            .lock()
+            .await
+            .iter()
+            .filter(|(uri, diagnostics)| {
+                !conflicts.contains_key(*uri) && diagnostics.has_workspace()
+            })
+            .map(|(uri, _)| uri.clone())
+            .collect();
+        // Read before locking, for the suppression comments of the documents
+        let mut changed = Vec::new();
+        for (uri, diagnostics) in stale
+            .into_iter()
+            .map(|uri| (uri, Vec::new()))
+            .chain(conflicts)
+        {
+            let source = self.document_source(&uri).await;
+            changed.push((uri, diagnostics, source));
+        }
+
+        let features = self.client_features();
+        let mut published = self.published_diagnostics.lock().await;
+        for (uri, diagnostics, source) in changed {
+            let document = published.entry(uri.clone()).or_default();
+            document.set_workspace(diagnostics);
+            let diagnostics = diagnostics_to_publish(
+                document,
+                &uri,
+                &source,
+                config.parameter_styles(uri.path()),
+                &config.diagnostic_severities,
+                &features,
+            );
+            if document.is_empty() {
+                published.remove(&uri);
+            }
+            self.outbound.publish_diagnostics(uri, diagnostics, None);
+        }
+    }
+
+    /// Text of a document: the editor content when it is open, the file on
+    /// disk otherwise
+    async fn document_source(&self, uri: &Url) -> String {
+        if let Some(document) = self.documents.get_document(uri).await {
+            return document.get_content();
+        }
+        uri.to_file_path()
+            .ok()
+            .and_then(|path| std::fs::read_to_string(path).ok())
+            .unwrap_or_default()
+    }
+
+    /// Run the diagnostics of every open document again
+    ///
+    /// Called after a configuration change, so new severities, lint rules
+    /// and diagnostic sources apply without editing the documents.
+    async fn republish_open_documents(&self) {
+        for uri in self.documents.list_uris().await {
+            let Some(document) = self.documents.get_document(&uri).await else {
+                continue;
+            };
+            if self.is_host_document(&document).await {
+                self.refresh_sub_documents(&uri, &document, DiagnosticTrigger::Save)
+                    .await;
+            } else {
+                self.publish_document_diagnostics(&uri, DiagnosticTrigger::Save)
+                    .await;
+            }
+        }
+    }
+
+    /// Dependencies between the views, functions and tables of the schema
+    ///
+    /// Returns `None` (no call hierarchy) when no config is set or the
+    /// catalog is unavailable.
+    async fn schema_dependencies(&self) -> Option<Arc<SchemaDependencies>> {
+        let config = self.get_config().await?;
+        match self.request_context.schema_dependencies(&config).await {
+            Ok(dependencies) => Some(dependencies),
+            Err(e) => {
+                debug!("Call hierarchy unavailable, catalog unavailable: {}", e);
+                None
+            }
+        }
+    }
+
+    /// References to the alias at a position, from the document's parse tree
+    async fn alias_locations(&self, uri: &Url, position: Position) -> Option<Vec<Location>> {
+        let document = self.documents.get_document(uri).await?;
+        let tree = document.tree()?;
+        let tree = tree.try_lock().ok()?;
+        highlight::alias_locations(&tree, &document.get_content(), uri, position)
+    }
+
+    /// Check whether a document is a host-language document with embedded SQL
+    async fn is_host_document(&self, document: &Document) -> bool {
+        self.get_config()
+            .await
+            .is_some_and(|config| config.embedded_sql.is_host_language(document.language_id()))
+    }
+
+    /// Extract, parse and diagnose the SQL embedded in a host document
+    ///
+    /// The diagnostics of all sub-documents are published on the host
+    /// document, at host positions.
+    async fn refresh_sub_documents(&self, uri: &Url, host: &Document, trigger: DiagnosticTrigger) {
+        let config = self.get_config().await.unwrap_or_default();
+        let mut sub_documents = embedded::sub_documents(host, &config.embedded_sql);
+        for sub_document in &mut sub_documents {
+            let dialect = self.doc_sync.resolve_dialect(&sub_document.document);
+            let parsed = match self.doc_sync.on_document_open(&sub_document.document) {
+                crate::parsing::ParseResult::Success { tree, parse_time } => tree.map(|tree| {
+                    let parse_time_ms = parse_time.as_millis() as u64;
+                    (tree, ParseMetadata::new(parse_time_ms, dialect, false, 0))
+                }),
+                crate::parsing::ParseResult::Partial { tree, errors } => {
+                    tree.map(|tree| (tree, ParseMetadata::new(0, dialect, true, errors.len())))
+                }
+                crate::parsing::ParseResult::Failed { error } => {
+                    warn!("Failed to parse embedded SQL: {}", error);
+                    None
+                }
+            };
+            if let Some((tree, metadata)) = parsed {
+                sub_document.document.set_tree(tree, metadata);
+            }
+        }
+
+        let sources = match trigger {
+            DiagnosticTrigger::Edit => config.diagnostics.on_type,
+            DiagnosticTrigger::Save => config.diagnostics.on_save,
+        };
+        let schema_catalog = if sources.schema {
+            self.schema_diagnostics_catalog().await
+        } else {
+            None
+        };
+        let styles = config.parameter_styles(uri.path());
+        let (mut syntax, mut schema, mut lint) = (Vec::new(), Vec::new(), Vec::new());
+        for sub_document in &sub_documents {
+            let tree = sub_document.document.tree();
+            let source = sub_document.document.get_content();
+            let sub_uri = sub_document.document.uri();
+            let parameters = find_parameters(&source, styles);
+            let to_host = |diagnostic| embedded::diagnostic_to_host(diagnostic, sub_document, uri);
+
+            if sources.syntax {
+                let found = self
+                    .diagnostic_collector
+                    .collect_from_arc(&tree, &source, sub_uri);
+                let found = suppress_parameter_diagnostics(found, &parameters);
+                syntax.extend(found.into_iter().map(to_host));
+            }
+            if sources.lint {
+                let found = self.diagnostic_collector.collect_lint_diagnostics(
+                    &tree,
+                    &source,
+                    sub_uri,
+                    &config.lint,
+                );
+                lint.extend(found.into_iter().map(to_host));
+            }
+            if let Some((catalog, severity, rules)) = &schema_catalog {
+                let found = self
+                    .diagnostic_collector
+                    .collect_schema_diagnostics(&tree, &source, catalog.as_ref(), *severity, *rules)
+                    .await;
+                let found = suppress_parameter_diagnostics(found, &parameters);
+                schema.extend(found.into_iter().map(to_host));
+            }
+        }
+        self.documents.set_sub_documents(uri, sub_documents).await;
+
+        let features = self.client_features();
+        let mut published = self.published_diagnostics.lock().await;
+        let document = published.entry(uri.clone()).or_default();
+        document.update(DocumentDiagnostics::new(syntax, schema, lint), sources);
+        let diagnostics = config
+            .diagnostic_severities
+            .apply(document.to_vec(), uri)
+            .into_iter()
+            .map(|d| features.adapt_diagnostic(d.to_lsp()))
+            .collect();
+        self.outbound
+            .publish_diagnostics(uri.clone(), diagnostics, None);
+    }
+
     /// Parse document and update its tree in the store
     ///
-    /// Shared helper for did_open and did_change handlers.
-    async fn parse_and_update_tree(&self, uri: &Url, document: &Document) {
+    /// Shared helper for did_open and did_save handlers.
+    async fn parse_and_update_tree(
+        &self,
+        uri: &Url,
+        document: &Document,
+        trigger: DiagnosticTrigger,
+    ) {
+        if self.is_host_document(document).await {
+            self.refresh_sub_documents(uri, document, trigger).await;
+            return;
+        }
         let dialect = self.doc_sync.resolve_dialect(document);
 
         match self.doc_sync.on_document_open(document) {
@@ -163,7 +1024,7 @@ impl LspBackend {
                 {
                     error!("Failed to update document tree: {}", e);
                 }
-                self.publish_document_diagnostics(uri).await;
+                self.publish_document_diagnostics(uri, trigger).await;
             }
             crate::parsing::ParseResult::Partial { tree, errors } => {
                 warn!("Document parsed with {} errors", errors.len());
@@ -176,14 +1037,13 @@ impl LspBackend {
                 {
                     error!("Failed to update document tree: {}", e);
                 }
-                self.publish_document_diagnostics(uri).await;
+                self.publish_document_diagnostics(uri, trigger).await;
             }
             crate::parsing::ParseResult::Failed { error } => {
                 error!("Failed to parse document: {}", error);
                 // Clear diagnostics on parse failure
-                self.client
-                    .publish_diagnostics(uri.clone(), Vec::new(), None)
-                    .await;
+                self.outbound
+                    .publish_diagnostics(uri.clone(), Vec::new(), None);
             }
         }
     }
@@ -198,6 +1058,11 @@ impl LspBackend {
         old_tree: Option<&tree_sitter::Tree>,
         changes: &[TextDocumentContentChangeEvent],
     ) {
+        if self.is_host_document(document).await {
+            self.refresh_sub_documents(uri, document, DiagnosticTrigger::Edit)
+                .await;
+            return;
+        }
         let dialect = self.doc_sync.resolve_dialect(document);
 
         match self
@@ -215,7 +1080,8 @@ impl LspBackend {
                 {
                     error!("Failed to update document tree: {}", e);
                 }
-                self.publish_document_diagnostics(uri).await;
+                self.publish_document_diagnostics(uri, DiagnosticTrigger::Edit)
+                    .await;
             }
             crate::parsing::ParseResult::Partial { tree, errors } => {
                 warn!("Document reparsed with {} errors", errors.len());
@@ -228,7 +1094,8 @@ impl LspBackend {
                 {
                     error!("Failed to update document tree: {}", e);
                 }
-                self.publish_document_diagnostics(uri).await;
+                self.publish_document_diagnostics(uri, DiagnosticTrigger::Edit)
+                    .await;
             }
             crate::parsing::ParseResult::Failed { error } => {
                 error!("Failed to reparse document: {}", error);
@@ -236,14 +1103,37 @@ impl LspBackend {
                     error!("Failed to clear document tree: {}", e);
                 }
                 // Clear diagnostics on parse failure
-                self.client
-                    .publish_diagnostics(uri.clone(), Vec::new(), None)
-                    .await;
+                self.outbound
+                    .publish_diagnostics(uri.clone(), Vec::new(), None);
             }
         }
     }
 }
 
+/// Ask the client to create a work-done progress token
+///
+/// Returns `None` if the client refuses, in which case progress is not
+/// reported.
+async fn create_progress_token(client: &Client) -> Option<ProgressToken> {
+    let token = ProgressToken::String(format!(
+        "unified-sql-lsp/{}",
+        PROGRESS_TOKEN_COUNTER.fetch_add(1, Ordering::Relaxed)
+    ));
+
+    match client
+        .send_request::<WorkDoneProgressCreate>(WorkDoneProgressCreateParams {
+            token: token.clone(),
+        })
+        .await
+    {
+        Ok(()) => Some(token),
+        Err(e) => {
+            warn!("Client refused a progress token: {}", e);
+            None
+        }
+    }
+}
+
 #[tower_lsp::async_trait]
 impl LanguageServer for LspBackend {
     /// Initialize the LSP server
@@ -256,6 +1146,39 @@ impl LanguageServer for LspBackend {
         info!("Client info: {:?}", params.client_info);
 
         // Log client capabilities
+        let features = ClientFeatures::from_capabilities(&params.capabilities);
+        info!("Client features: {:?}", features);
+        let position_encoding = features.position_encoding.clone();
+        let will_save_wait_until = features.will_save_wait_until;
+        if self.client_features.set(features).is_err() {
+            warn!("Client sent initialize more than once");
+        }
+        #[allow(deprecated)]
+        let folders: Vec<PathBuf> = match (&params.workspace_folders, &params.root_uri) {
+            (Some(folders), _) => folders.iter().map(|folder| &folder.uri).collect(),
+            (None, Some(root)) => vec![root],
+            (None, None) => Vec::new(),
+        }
+        .into_iter()
+        .filter_map(|uri| uri.to_file_path().ok())
+        .collect();
+        info!("Workspace folders: {:?}", folders);
+        let _ = self.workspace_folders.set(folders);
+        if self.exit_with_parent
+            && let Some(pid) = params.process_id
+        {
+            let background = self.background.clone();
+            let outbound = self.outbound.clone();
+            tokio::spawn(async move {
+                parent_process::wait_for_exit(pid, parent_process::POLL_INTERVAL).await;
+                warn!("Editor process {} is gone, exiting", pid);
+                tokio::time::sleep(parent_process::GRACE_PERIOD).await;
+                // Tear down as `shutdown` would, then write what is queued
+                background.cancel_all();
+                outbound.close(parent_process::GRACE_PERIOD).await;
+                std::process::exit(0);
+            });
+        }
         if let Some(capabilities) = params.capabilities.text_document {
             info!(
                 "Text document capabilities: sync={:?}",
@@ -270,15 +1193,31 @@ impl LanguageServer for LspBackend {
         // Return server capabilities
         Ok(InitializeResult {
             capabilities: ServerCapabilities {
-                // Text synchronization
-                text_document_sync: Some(TextDocumentSyncCapability::Kind(
-                    TextDocumentSyncKind::INCREMENTAL,
+                // Positions are always UTF-16 offsets
+                position_encoding: Some(position_encoding),
+
+                // Text synchronization; saves include the text and run the
+                // on-save diagnostics
+                text_document_sync: Some(TextDocumentSyncCapability::Options(
+                    TextDocumentSyncOptions {
+                        open_close: Some(true),
+                        change: Some(TextDocumentSyncKind::INCREMENTAL),
+                        will_save: None,
+                        will_save_wait_until: Some(will_save_wait_until),
+                        save: Some(TextDocumentSyncSaveOptions::SaveOptions(SaveOptions {
+                            include_text: Some(true),
+                        })),
+                    },
                 )),
 
                 // Completion (will be implemented in LSP-003)
                 completion_provider: Some(CompletionOptions {
                     resolve_provider: Some(false),
-                    trigger_characters: Some(vec![".".to_string(), " ".to_string()]),
+                    trigger_characters: Some(vec![
+                        ".".to_string(),
+                        " ".to_string(),
+                        "(".to_string(),
+                    ]),
                     work_done_progress_options: WorkDoneProgressOptions {
                         work_done_progress: Some(false),
                     },
@@ -289,6 +1228,15 @@ impl LanguageServer for LspBackend {
                 // Hover (will be implemented in HOVER-001)
                 hover_provider: Some(HoverProviderCapability::Simple(true)),
 
+                // Signature help for function calls
+                signature_help_provider: Some(SignatureHelpOptions {
+                    trigger_characters: Some(vec!["(".to_string(), ",".to_string()]),
+                    retrigger_characters: None,
+                    work_done_progress_options: WorkDoneProgressOptions {
+                        work_done_progress: Some(false),
+                    },
+                }),
+
                 // Diagnostics (will be implemented in DIAG-001)
                 diagnostic_provider: Some(DiagnosticServerCapabilities::Options(
                     DiagnosticOptions {
@@ -304,12 +1252,49 @@ impl LanguageServer for LspBackend {
                 // Definition (future feature)
                 definition_provider: Some(OneOf::Left(true)),
 
-                // Document formatting (will be implemented in FORMAT-001)
+                // References across the workspace index
+                references_provider: Some(OneOf::Left(true)),
+
+                // Occurrences of the identifier under the cursor in its statement
+                document_highlight_provider: Some(OneOf::Left(true)),
+
+                // Structural expand-selection from the parse tree
+                selection_range_provider: Some(SelectionRangeProviderCapability::Simple(true)),
+
+                // Live renaming of table aliases across their statement
+                linked_editing_range_provider: Some(LinkedEditingRangeServerCapabilities::Simple(
+                    true,
+                )),
+
+                // Views and functions depending on tables, from the schema
+                call_hierarchy_provider: Some(CallHierarchyServerCapability::Simple(true)),
+
+                // Ctrl-click on files referenced by includes, COPY and LOAD DATA
+                document_link_provider: Some(DocumentLinkOptions {
+                    resolve_provider: Some(false),
+                    work_done_progress_options: Default::default(),
+                }),
+
+                // Document formatting (whitespace only until FORMAT-001)
                 document_formatting_provider: Some(OneOf::Left(true)),
 
                 // Document symbols (future feature)
                 document_symbol_provider: Some(OneOf::Left(true)),
 
+                // Code actions (lint quick fixes)
+                code_action_provider: Some(CodeActionProviderCapability::Options(
+                    CodeActionOptions {
+                        code_action_kinds: Some(vec![CodeActionKind::QUICKFIX]),
+                        ..Default::default()
+                    },
+                )),
+
+                // Workspace commands (query plans, statement execution)
+                execute_command_provider: Some(ExecuteCommandOptions {
+                    commands: commands::command_names(),
+                    ..Default::default()
+                }),
+
                 // Other capabilities
                 workspace: Some(WorkspaceServerCapabilities {
                     workspace_folders: Some(WorkspaceFoldersServerCapabilities {
@@ -344,6 +1329,13 @@ impl LanguageServer for LspBackend {
         // TODO: (CONFIG-001) Load configuration from client settings
         // For now, we'll wait for the client to send configuration
         // through workspace/didChangeConfiguration
+
+        self.spawn_health_checks();
+        self.watch_schema_changes();
+        self.spawn_schema_prefetch().await;
+        self.spawn_workspace_indexing().await;
+        self.register_file_watcher().await;
+        self.reload_migrations().await;
     }
 
     /// Shutdown the LSP server
@@ -353,7 +1345,7 @@ impl LanguageServer for LspBackend {
         info!("Shutting down LSP server");
 
         // Clean up resources
-        // (None to clean up yet, but this will be important for catalog connections)
+        self.background.cancel_all();
 
         Ok(())
     }
@@ -390,8 +1382,10 @@ impl LanguageServer for LspBackend {
 
                 // Trigger parsing using shared helper
                 if let Some(document) = self.documents.get_document(&uri).await {
-                    self.parse_and_update_tree(&uri, &document).await;
+                    self.parse_and_update_tree(&uri, &document, DiagnosticTrigger::Save)
+                        .await;
                 }
+                self.index_open_document(&uri).await;
             }
             Err(e) => {
                 error!("Failed to open document: {}", e);
@@ -412,6 +1406,9 @@ impl LanguageServer for LspBackend {
         let uri = identifier.uri.clone();
         let changes = params.content_changes;
 
+        // The document edited last is the one the user is looking at
+        self.diagnostic_scheduler.set_focus(&uri);
+
         info!(
             "Document changed: uri={}, version={}, changes={}",
             uri,
@@ -440,6 +1437,7 @@ impl LanguageServer for LspBackend {
                     )
                     .await;
                 }
+                self.index_open_document(&uri).await;
             }
             Err(DocumentError::DocumentNotFound(uri)) => {
                 warn!("Document not found for change: {}", uri);
@@ -455,23 +1453,159 @@ impl LanguageServer for LspBackend {
         }
     }
 
-    /// Document closed notification
+    /// Document saved notification
     ///
-    /// Called when the client closes a document.
-    async fn did_close(&self, params: DidCloseTextDocumentParams) {
+    /// Runs the on-save diagnostics, which may include sources too slow to
+    /// run on every edit. The saved text, when included, replaces the stored
+    /// content.
+    async fn did_save(&self, params: DidSaveTextDocumentParams) {
         let uri = params.text_document.uri;
 
-        info!("Document closed: uri={}", uri);
+        info!("Document saved: uri={}", uri);
 
-        // Remove from document store
-        if self.documents.close_document(&uri).await {
-            // Clear parse data
+        let Some(document) = self.documents.get_document(&uri).await else {
+            warn!("Document not found for save: {}", uri);
+            return;
+        };
+
+        match params.text {
+            Some(text) if text != document.get_content() => {
+                let identifier = VersionedTextDocumentIdentifier {
+                    uri: uri.clone(),
+                    version: document.version(),
+                };
+                let change = TextDocumentContentChangeEvent {
+                    range: None,
+                    range_length: None,
+                    text,
+                };
+                if let Err(e) = self.documents.update_document(&identifier, &[change]).await {
+                    error!("Failed to update saved document: {}", e);
+                    return;
+                }
+                if let Some(document) = self.documents.get_document(&uri).await {
+                    self.parse_and_update_tree(&uri, &document, DiagnosticTrigger::Save)
+                        .await;
+                }
+            }
+            _ => {
+                self.publish_document_diagnostics(&uri, DiagnosticTrigger::Save)
+                    .await
+            }
+        }
+        self.index_open_document(&uri).await;
+    }
+
+    /// Will-save request
+    ///
+    /// Returns the formatting edits applied before saving when
+    /// `formatOnSave` is enabled.
+    async fn will_save_wait_until(
+        &self,
+        params: WillSaveTextDocumentParams,
+    ) -> Result<Option<Vec<TextEdit>>> {
+        let uri = params.text_document.uri;
+        let format_on_save = self
+            .get_config()
+            .await
+            .is_some_and(|config| config.format_on_save);
+        if !format_on_save {
+            return Ok(None);
+        }
+
+        let Some(document) = self.documents.get_document(&uri).await else {
+            return Ok(None);
+        };
+        let edits = formatting::whitespace_edits(&document.get_content());
+        debug!("Formatting on save: uri={}, edits={}", uri, edits.len());
+
+        Ok(Some(edits))
+    }
+
+    /// On-type formatting request
+    ///
+    /// Upper-cases the keyword completed by a space or newline and aligns new
+    /// lines of SELECT lists and ON conditions, when `onTypeFormatting` is
+    /// enabled.
+    async fn on_type_formatting(
+        &self,
+        params: DocumentOnTypeFormattingParams,
+    ) -> Result<Option<Vec<TextEdit>>> {
+        let uri = params.text_document_position.text_document.uri.clone();
+        self.request_logger
+            .track("textDocument/onTypeFormatting", &uri, async move {
+                let Some(config) = self
+                    .get_config()
+                    .await
+                    .map(|config| config.on_type_formatting)
+                    .filter(|config| config.enabled)
+                else {
+                    return Ok(None);
+                };
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    return Ok(None);
+                };
+
+                let dialect = self.doc_sync.resolve_dialect(&document);
+                let edits = formatting::on_type_edits(
+                    &document.get_content(),
+                    params.text_document_position.position,
+                    &params.ch,
+                    dialect,
+                    &config,
+                );
+                debug!("Formatting on type: uri={}, edits={}", uri, edits.len());
+
+                Ok(Some(edits))
+            })
+            .await
+    }
+
+    /// Document closed notification
+    ///
+    /// Called when the client closes a document.
+    async fn did_close(&self, params: DidCloseTextDocumentParams) {
+        let uri = params.text_document.uri;
+
+        info!("Document closed: uri={}", uri);
+
+        // Remove from document store
+        if self.documents.close_document(&uri).await {
+            // Clear parse data
             self.doc_sync.on_document_close(&uri);
 
-            // Clear diagnostics
-            self.client
-                .publish_diagnostics(uri.clone(), Vec::new(), None)
-                .await;
+            // Clear diagnostics, keeping the conflicts of migration files
+            let severities = self
+                .get_config()
+                .await
+                .map(|config| config.diagnostic_severities)
+                .unwrap_or_default();
+            let remaining = {
+                let mut published = self.published_diagnostics.lock().await;
+                match published.get_mut(&uri) {
+                    Some(document) => {
+                        document.close();
+                        let remaining = severities.apply(document.to_vec(), &uri);
+                        if document.is_empty() {
+                            published.remove(&uri);
+                        }
+                        remaining
+                    }
+                    None => Vec::new(),
+                }
+            };
+            let features = self.client_features();
+            self.outbound.publish_diagnostics(
+                uri.clone(),
+                remaining
+                    .into_iter()
+                    .map(|d| features.adapt_diagnostic(d.to_lsp()))
+                    .collect(),
+                None,
+            );
+
+            // Unsaved edits are discarded, the file on disk is indexed again
+            self.index_file_on_disk(&uri).await;
 
             self.log_message(&format!("Document closed: {}", uri), MessageType::INFO)
                 .await;
@@ -480,81 +1614,401 @@ impl LanguageServer for LspBackend {
         }
     }
 
+    /// Watched files changed notification
+    ///
+    /// Keeps the workspace index in sync with SQL files changed on disk.
+    /// Open documents are indexed from their editor content instead.
+    /// Changes to migration files reload the migration overlay.
+    async fn did_change_watched_files(&self, params: DidChangeWatchedFilesParams) {
+        debug!("Watched files changed: {} events", params.changes.len());
+
+        let mut migrations_changed = false;
+        for change in &params.changes {
+            migrations_changed |= self.is_migration_file(&change.uri).await;
+        }
+        if migrations_changed {
+            self.reload_migrations().await;
+        }
+
+        for change in params.changes {
+            if self.documents.has_document(&change.uri).await {
+                continue;
+            }
+            if change.typ == FileChangeType::DELETED {
+                self.workspace_index.write().unwrap().remove(&change.uri);
+            } else {
+                self.index_file_on_disk(&change.uri).await;
+            }
+        }
+    }
+
+    /// References request
+    ///
+    /// Finds the table or column under the cursor in all indexed workspace
+    /// files, open or not.
+    async fn references(&self, params: ReferenceParams) -> Result<Option<Vec<Location>>> {
+        let uri = params.text_document_position.text_document.uri.clone();
+        self.request_logger
+            .track("textDocument/references", &uri, async move {
+                let position = params.text_document_position.position;
+
+                let identifier = self
+                    .workspace_index
+                    .read()
+                    .unwrap()
+                    .identifier_at(&uri, position);
+                let Some((kind, name)) = identifier else {
+                    // Aliases are not indexed, their references are in the statement
+                    let locations = self.alias_locations(&uri, position).await;
+                    if locations.is_none() {
+                        debug!("No table, column or alias at {:?} in {}", position, uri);
+                    }
+                    return Ok(locations);
+                };
+                let index = self.workspace_index.read().unwrap();
+                let locations = index.references(kind, &name);
+                info!(
+                    "Found {} references to {:?} {} in {} files",
+                    locations.len(),
+                    kind,
+                    name,
+                    index.files_mentioning(kind, &name).len()
+                );
+
+                Ok(Some(locations))
+            })
+            .await
+    }
+
+    /// Document highlight request
+    ///
+    /// Highlights the occurrences of the table, alias or column under the
+    /// cursor in its statement.
+    async fn document_highlight(
+        &self,
+        params: DocumentHighlightParams,
+    ) -> Result<Option<Vec<DocumentHighlight>>> {
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+        self.request_logger
+            .track("textDocument/documentHighlight", &uri, async move {
+                let position = params.text_document_position_params.position;
+
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    warn!("Document not found for highlights: {}", uri);
+                    return Ok(None);
+                };
+                let Some(tree) = document.tree() else {
+                    debug!("Document not parsed: {}", uri);
+                    return Ok(None);
+                };
+                let Ok(tree) = tree.try_lock() else {
+                    error!("Failed to acquire tree lock for document highlights");
+                    return Ok(None);
+                };
+
+                let highlights =
+                    highlight::document_highlights(&tree, &document.get_content(), position);
+                debug!(
+                    "Found {} highlights at {:?} in {}",
+                    highlights.as_ref().map_or(0, Vec::len),
+                    position,
+                    uri
+                );
+                Ok(highlights)
+            })
+            .await
+    }
+
+    /// Call hierarchy preparation request
+    ///
+    /// Resolves the table, view or function named at the cursor to an item
+    /// of the schema's dependency graph.
+    async fn prepare_call_hierarchy(
+        &self,
+        params: CallHierarchyPrepareParams,
+    ) -> Result<Option<Vec<CallHierarchyItem>>> {
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+        self.request_logger
+            .track("textDocument/prepareCallHierarchy", &uri, async move {
+                let position = params.text_document_position_params.position;
+
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    warn!("Document not found for call hierarchy: {}", uri);
+                    return Ok(None);
+                };
+                let Some(dependencies) = self.schema_dependencies().await else {
+                    return Ok(None);
+                };
+
+                Ok(call_hierarchy::prepare(
+                    &dependencies,
+                    &document.get_content(),
+                    position,
+                ))
+            })
+            .await
+    }
+
+    /// Incoming calls request
+    ///
+    /// Lists the views and functions that read a table or view.
+    async fn incoming_calls(
+        &self,
+        params: CallHierarchyIncomingCallsParams,
+    ) -> Result<Option<Vec<CallHierarchyIncomingCall>>> {
+        let uri = params.item.uri.clone();
+        self.request_logger
+            .track("callHierarchy/incomingCalls", &uri, async move {
+                let Some(dependencies) = self.schema_dependencies().await else {
+                    return Ok(None);
+                };
+                Ok(Some(call_hierarchy::incoming_calls(
+                    &dependencies,
+                    &params.item,
+                )))
+            })
+            .await
+    }
+
+    /// Outgoing calls request
+    ///
+    /// Lists the tables and views a view or function reads.
+    async fn outgoing_calls(
+        &self,
+        params: CallHierarchyOutgoingCallsParams,
+    ) -> Result<Option<Vec<CallHierarchyOutgoingCall>>> {
+        let uri = params.item.uri.clone();
+        self.request_logger
+            .track("callHierarchy/outgoingCalls", &uri, async move {
+                let Some(dependencies) = self.schema_dependencies().await else {
+                    return Ok(None);
+                };
+                Ok(Some(call_hierarchy::outgoing_calls(
+                    &dependencies,
+                    &params.item,
+                )))
+            })
+            .await
+    }
+
+    /// Document link request
+    ///
+    /// Links the files referenced by psql includes, `COPY` and `LOAD DATA`
+    /// statements, resolved against the document's directory.
+    async fn document_link(&self, params: DocumentLinkParams) -> Result<Option<Vec<DocumentLink>>> {
+        let uri = params.text_document.uri.clone();
+        self.request_logger
+            .track("textDocument/documentLink", &uri, async move {
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    warn!("Document not found for document links: {}", uri);
+                    return Ok(None);
+                };
+
+                let dialect = self.doc_sync.resolve_dialect(&document);
+                let links = document_link::document_links(&document.get_content(), &uri, dialect);
+                debug!("Found {} document links in {}", links.len(), uri);
+                Ok(Some(links))
+            })
+            .await
+    }
+
+    /// Linked editing range request
+    ///
+    /// Returns the definition and usages of the table alias under the
+    /// cursor, so clients can rename them together while typing. Tables and
+    /// columns get no ranges.
+    async fn linked_editing_range(
+        &self,
+        params: LinkedEditingRangeParams,
+    ) -> Result<Option<LinkedEditingRanges>> {
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+        self.request_logger
+            .track("textDocument/linkedEditingRange", &uri, async move {
+                let position = params.text_document_position_params.position;
+
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    warn!("Document not found for linked editing: {}", uri);
+                    return Ok(None);
+                };
+                let Some(tree) = document.tree() else {
+                    debug!("Document not parsed: {}", uri);
+                    return Ok(None);
+                };
+                let Ok(tree) = tree.try_lock() else {
+                    error!("Failed to acquire tree lock for linked editing ranges");
+                    return Ok(None);
+                };
+
+                Ok(highlight::linked_editing_ranges(
+                    &tree,
+                    &document.get_content(),
+                    position,
+                ))
+            })
+            .await
+    }
+
+    /// Selection range request
+    ///
+    /// Returns one chain of enclosing ranges per requested position, for
+    /// expand-selection: token, expression, clause, statement, document.
+    async fn selection_range(
+        &self,
+        params: SelectionRangeParams,
+    ) -> Result<Option<Vec<SelectionRange>>> {
+        let uri = params.text_document.uri.clone();
+        self.request_logger
+            .track("textDocument/selectionRange", &uri, async move {
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    warn!("Document not found for selection ranges: {}", uri);
+                    return Ok(None);
+                };
+
+                let source = document.get_content();
+                let ranges = match document.tree() {
+                    Some(tree) => {
+                        let tree = tree.lock().await;
+                        selection_range::selection_ranges(Some(&*tree), &source, &params.positions)
+                    }
+                    None => {
+                        debug!(
+                            "Document not parsed, using lexical selection ranges: {}",
+                            uri
+                        );
+                        selection_range::selection_ranges(None, &source, &params.positions)
+                    }
+                };
+                Ok(Some(ranges))
+            })
+            .await
+    }
+
     /// Completion request
     ///
     /// Called when the user requests completion (e.g., Ctrl+Space).
     /// Implements COMPLETION-001: SELECT clause column completion.
     async fn completion(&self, params: CompletionParams) -> Result<Option<CompletionResponse>> {
-        let uri = params.text_document_position.text_document.uri;
-        let position = params.text_document_position.position;
+        let uri = params.text_document_position.text_document.uri.clone();
+        self.request_logger
+            .track("textDocument/completion", &uri, async move {
+                let uri = params.text_document_position.text_document.uri;
+                let position = params.text_document_position.position;
 
-        debug!(
-            "!!! LSP: Completion requested: uri={}, line={}, col={}",
-            uri, position.line, position.character
-        );
+                debug!(
+                    "!!! LSP: Completion requested: uri={}, line={}, col={}",
+                    uri, position.line, position.character
+                );
 
-        info!(
-            "Completion requested: uri={}, line={}, col={}",
-            uri, position.line, position.character
-        );
+                info!(
+                    "Completion requested: uri={}, line={}, col={}",
+                    uri, position.line, position.character
+                );
 
-        // Get document
-        let document = match self.documents.get_document(&uri).await {
-            Some(doc) => doc,
-            None => {
-                warn!("Document not found for completion: {}", uri);
-                return Ok(None);
-            }
-        };
+                // Get document
+                let document = match self.documents.get_document(&uri).await {
+                    Some(doc) => doc,
+                    None => {
+                        warn!("Document not found for completion: {}", uri);
+                        return Ok(None);
+                    }
+                };
 
-        let (config, catalog) = match self.request_context.config_and_catalog().await {
-            Ok(result) => result,
-            Err(e) => {
-                debug!("!!! LSP: Failed to get catalog: {}", e);
-                error!("Failed to get catalog: {}", e);
-                self.log_message(
-                    &format!("Failed to connect to database: {}", e),
-                    MessageType::ERROR,
-                )
-                .await;
-                return Ok(None);
-            }
-        };
-        debug!("!!! LSP: Config dialect={:?}", config.dialect);
-
-        // Create completion engine and perform completion
-        debug!("!!! LSP: Creating completion engine");
-        let engine = CompletionEngine::new(catalog);
-        debug!("!!! LSP: Calling complete with position {:?}", position);
-        match engine.complete(&document, position).await {
-            Ok(Some(items)) => {
-                debug!("!!! LSP: Completion returned {} items", items.len());
-                for (i, item) in items.iter().take(5).enumerate() {
-                    debug!(
-                        "!!! LSP:   Item {}: label={}, kind={:?}",
-                        i, item.label, item.kind
-                    );
+                // SQL embedded in a host document is completed in its sub-document
+                let is_host = self.is_host_document(&document).await;
+                let (document, position, mapper) =
+                    match self.documents.sub_document_at(&uri, position).await {
+                        Some((sub_document, position)) => {
+                            (sub_document.document, position, Some(sub_document.mapper))
+                        }
+                        None if is_host => return Ok(None),
+                        None => (document, position, None),
+                    };
+
+                // Without a catalog we still offer schema-independent completions (keywords)
+                let (config, catalog, catalog_error) =
+                    self.request_context.config_and_catalog_or_offline().await;
+                if let Some(CatalogError::SchemaLoading) = catalog_error {
+                    debug!("Schema is loading, completing keywords only");
+                } else if let Some(e) = catalog_error {
+                    debug!("!!! LSP: Failed to get catalog: {}", e);
+                    error!("Failed to get catalog: {}", e);
+                    self.log_message(
+                        &format!("Failed to connect to database: {}", e),
+                        MessageType::ERROR,
+                    )
+                    .await;
                 }
-                info!("Completion returned {} items", items.len());
-                Ok(Some(CompletionResponse::Array(items)))
-            }
-            Ok(None) => {
-                // No completion available (wrong context)
-                debug!("!!! LSP: Completion returned None (wrong context)");
-                Ok(None)
-            }
-            Err(e) => {
-                error!("Completion error: {}", e);
-                if e.should_return_empty() {
-                    Ok(None)
-                } else {
-                    // Show error to user
-                    self.log_message(&format!("Completion error: {}", e), MessageType::ERROR)
-                        .await;
-                    Ok(None)
+                debug!("!!! LSP: Config dialect={:?}", config.dialect);
+
+                // Create completion engine and perform completion
+                debug!("!!! LSP: Creating completion engine");
+                let engine = CompletionEngine::new(self.with_migrations(catalog))
+                    .with_snippets(self.client_features().snippets)
+                    .with_dialect(config.dialect)
+                    .with_parameters(config.parameter_styles(uri.path()))
+                    .with_keyword_case(config.keyword_case)
+                    .with_cache(self.completion_cache.clone());
+                debug!("!!! LSP: Calling complete with position {:?}", position);
+                match engine
+                    .complete_within(&document, position, config.request_budgets.completion)
+                    .await
+                {
+                    Ok(Some(mut list)) => {
+                        if let Some(mapper) = mapper {
+                            list.items = list
+                                .items
+                                .into_iter()
+                                .map(|item| embedded::completion_to_host(item, &mapper))
+                                .collect();
+                        }
+                        debug!("!!! LSP: Completion returned {} items", list.items.len());
+                        for (i, item) in list.items.iter().take(5).enumerate() {
+                            debug!(
+                                "!!! LSP:   Item {}: label={}, kind={:?}",
+                                i, item.label, item.kind
+                            );
+                        }
+                        info!(
+                            "Completion returned {} items (incomplete: {})",
+                            list.items.len(),
+                            list.is_incomplete
+                        );
+                        Ok(Some(CompletionResponse::List(list)))
+                    }
+                    Ok(None) => {
+                        // No completion available (wrong context)
+                        debug!("!!! LSP: Completion returned None (wrong context)");
+                        Ok(None)
+                    }
+                    Err(e) => {
+                        error!("Completion error: {}", e);
+                        if e.should_return_empty() {
+                            Ok(None)
+                        } else {
+                            // Show error to user
+                            self.log_message(
+                                &format!("Completion error: {}", e),
+                                MessageType::ERROR,
+                            )
+                            .await;
+                            Ok(None)
+                        }
+                    }
                 }
-            }
-        }
+            })
+            .await
     }
 
     /// Hover request
@@ -562,54 +2016,118 @@ impl LanguageServer for LspBackend {
     /// Called when the user hovers over a symbol.
     /// Uses HoverEngine for CST-based hover information.
     async fn hover(&self, params: HoverParams) -> Result<Option<Hover>> {
-        let uri = params.text_document_position_params.text_document.uri;
-        let position = params.text_document_position_params.position;
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+        self.request_logger
+            .track("textDocument/hover", &uri, async move {
+                let uri = params.text_document_position_params.text_document.uri;
+                let position = params.text_document_position_params.position;
 
-        debug!(
-            "!!! LSP: Hover requested: uri={}, line={}, col={}",
-            uri, position.line, position.character
-        );
+                debug!(
+                    "!!! LSP: Hover requested: uri={}, line={}, col={}",
+                    uri, position.line, position.character
+                );
 
-        info!(
-            "Hover requested: uri={}, line={}, col={}",
-            uri, position.line, position.character
-        );
+                info!(
+                    "Hover requested: uri={}, line={}, col={}",
+                    uri, position.line, position.character
+                );
 
-        // Get document
-        let document = match self.documents.get_document(&uri).await {
-            Some(doc) => doc,
-            None => {
-                warn!("Document not found for hover: {}", uri);
-                return Ok(None);
-            }
-        };
+                // Get document
+                let document = match self.documents.get_document(&uri).await {
+                    Some(doc) => doc,
+                    None => {
+                        warn!("Document not found for hover: {}", uri);
+                        return Ok(None);
+                    }
+                };
 
-        let (config, catalog) = match self.request_context.config_and_catalog().await {
-            Ok(result) => result,
-            Err(e) => {
-                debug!("!!! LSP: Failed to get catalog for hover: {}", e);
-                error!("Failed to get catalog for hover: {}", e);
-                return Ok(None);
-            }
-        };
+                let (config, catalog) = match self.request_context.config_and_catalog().await {
+                    Ok(result) => result,
+                    Err(e) => {
+                        debug!("!!! LSP: Failed to get catalog for hover: {}", e);
+                        error!("Failed to get catalog for hover: {}", e);
+                        return Ok(None);
+                    }
+                };
+
+                // Use HoverEngine for CST-based hover
+                use crate::hover::HoverEngine;
+                let engine = HoverEngine::new(self.with_migrations(catalog), config.dialect);
+
+                if let Some(text) = engine.get_hover(&document, position).await {
+                    debug!("!!! LSP: Returning hover info: {}", text);
+                    Ok(Some(Hover {
+                        contents: HoverContents::Markup(MarkupContent {
+                            kind: MarkupKind::Markdown,
+                            value: text,
+                        }),
+                        range: None,
+                    }))
+                } else {
+                    debug!("!!! LSP: No hover info found");
+                    Ok(None)
+                }
+            })
+            .await
+    }
 
-        // Use HoverEngine for CST-based hover
-        use crate::hover::HoverEngine;
-        let engine = HoverEngine::new(catalog, config.dialect);
+    /// Signature help request
+    ///
+    /// Shows the signature of the function call surrounding the cursor. The
+    /// catalog's functions take precedence; functions the schema does not
+    /// know fall back to the dialect's built-ins.
+    async fn signature_help(&self, params: SignatureHelpParams) -> Result<Option<SignatureHelp>> {
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+        self.request_logger
+            .track("textDocument/signatureHelp", &uri, async move {
+                let uri = params.text_document_position_params.text_document.uri;
+                let position = params.text_document_position_params.position;
+
+                let Some(document) = self.documents.get_document(&uri).await else {
+                    return Ok(None);
+                };
 
-        if let Some(text) = engine.get_hover(&document, position).await {
-            debug!("!!! LSP: Returning hover info: {}", text);
-            Ok(Some(Hover {
-                contents: HoverContents::Markup(MarkupContent {
-                    kind: MarkupKind::Markdown,
-                    value: text,
-                }),
-                range: None,
-            }))
-        } else {
-            debug!("!!! LSP: No hover info found");
-            Ok(None)
-        }
+                // SQL embedded in a host document is looked up in its sub-document
+                let is_host = self.is_host_document(&document).await;
+                let (document, position) =
+                    match self.documents.sub_document_at(&uri, position).await {
+                        Some((sub_document, position)) => (sub_document.document, position),
+                        None if is_host => return Ok(None),
+                        None => (document, position),
+                    };
+
+                let Some(call) = signature_help::call_at(&document.get_content(), position) else {
+                    return Ok(None);
+                };
+
+                let (config, catalog, _) =
+                    self.request_context.config_and_catalog_or_offline().await;
+                let functions = self
+                    .with_migrations(catalog)
+                    .list_functions()
+                    .await
+                    .unwrap_or_default();
+                let function = functions
+                    .into_iter()
+                    .find(|f| f.name.eq_ignore_ascii_case(&call.name))
+                    .or_else(|| {
+                        completion::builtin_functions()
+                            .get_function(config.dialect, &call.name)
+                            .cloned()
+                    });
+
+                debug!(name = %call.name, found = function.is_some(), "Signature help");
+                Ok(function.map(|f| signature_help::signature_help(&f, call.active_parameter)))
+            })
+            .await
     }
 
     /// Definition request
@@ -628,95 +2146,133 @@ impl LanguageServer for LspBackend {
         &self,
         params: GotoDefinitionParams,
     ) -> Result<Option<GotoDefinitionResponse>> {
-        use unified_sql_lsp_context::{Definition, DefinitionFinder, Position as ContextPosition};
+        let uri = params
+            .text_document_position_params
+            .text_document
+            .uri
+            .clone();
+        self.request_logger
+            .track("textDocument/definition", &uri, async move {
+                use unified_sql_lsp_context::{
+                    Definition, DefinitionFinder, Position as ContextPosition,
+                };
 
-        let uri = params.text_document_position_params.text_document.uri;
-        let position = params.text_document_position_params.position;
+                let uri = params.text_document_position_params.text_document.uri;
+                let position = params.text_document_position_params.position;
 
-        info!(
-            "Go to definition requested: uri={}, pos={:?}",
-            uri, position
-        );
+                info!(
+                    "Go to definition requested: uri={}, pos={:?}",
+                    uri, position
+                );
 
-        // 1. Get document from store
-        let document = match self.documents.get_document(&uri).await {
-            Some(doc) => doc,
-            None => {
-                warn!("Document not found: {}", uri);
-                return Ok(None);
-            }
-        };
+                // 1. Get document from store
+                let document = match self.documents.get_document(&uri).await {
+                    Some(doc) => doc,
+                    None => {
+                        warn!("Document not found: {}", uri);
+                        return Ok(None);
+                    }
+                };
 
-        // 2. Get parse tree
-        let tree = match document.tree() {
-            Some(t) => t,
-            None => {
-                info!("Document not parsed: {}", uri);
-                return Ok(None); // Graceful degradation
-            }
-        };
+                // 2. Get parse tree
+                let tree = match document.tree() {
+                    Some(t) => t,
+                    None => {
+                        info!("Document not parsed: {}", uri);
+                        return Ok(None); // Graceful degradation
+                    }
+                };
 
-        // 3. Find definition using DefinitionFinder
-        let tree_lock = match tree.try_lock() {
-            Ok(lock) => lock,
-            Err(_) => {
-                warn!("Failed to acquire tree lock for go-to-definition");
-                return Ok(None);
-            }
-        };
-        let root_node = tree_lock.root_node();
-        let source = document.get_content();
-
-        let ctx_pos = ContextPosition::new(position.line, position.character);
-        match DefinitionFinder::find_at_position(&root_node, source.as_str(), ctx_pos) {
-            Ok(Some(definition)) => {
-                let location = match definition {
-                    Definition::Table(def) => Location {
-                        uri: uri.clone(),
-                        range: Range {
-                            start: Position::new(def.range.start.line, def.range.start.character),
-                            end: Position::new(def.range.end.line, def.range.end.character),
-                        },
-                    },
-                    Definition::Column(def) => Location {
-                        uri: uri.clone(),
-                        range: Range {
-                            start: Position::new(def.range.start.line, def.range.start.character),
-                            end: Position::new(def.range.end.line, def.range.end.character),
-                        },
-                    },
+                // 3. Find definition using DefinitionFinder
+                let tree_lock = match tree.try_lock() {
+                    Ok(lock) => lock,
+                    Err(_) => {
+                        warn!("Failed to acquire tree lock for go-to-definition");
+                        return Ok(None);
+                    }
                 };
-                info!("Definition found: {:?}", location);
-                Ok(Some(GotoDefinitionResponse::Scalar(location)))
-            }
-            Ok(None) => {
-                info!("No definition found at position");
-                Ok(None)
-            }
-            Err(e) => {
-                warn!("Error finding definition: {:?}", e);
-                Ok(None) // Graceful degradation
-            }
-        }
+                let root_node = tree_lock.root_node();
+                let source = document.get_content();
+
+                let ctx_pos = ContextPosition::new(position.line, position.character);
+                match DefinitionFinder::find_at_position(&root_node, source.as_str(), ctx_pos) {
+                    Ok(Some(definition)) => {
+                        let location = match definition {
+                            Definition::Table(def) => Location {
+                                uri: uri.clone(),
+                                range: Range {
+                                    start: Position::new(
+                                        def.range.start.line,
+                                        def.range.start.character,
+                                    ),
+                                    end: Position::new(def.range.end.line, def.range.end.character),
+                                },
+                            },
+                            Definition::Column(def) => Location {
+                                uri: uri.clone(),
+                                range: Range {
+                                    start: Position::new(
+                                        def.range.start.line,
+                                        def.range.start.character,
+                                    ),
+                                    end: Position::new(def.range.end.line, def.range.end.character),
+                                },
+                            },
+                        };
+                        info!("Definition found: {:?}", location);
+                        Ok(Some(GotoDefinitionResponse::Scalar(location)))
+                    }
+                    Ok(None) => {
+                        info!("No definition found at position");
+                        Ok(None)
+                    }
+                    Err(e) => {
+                        warn!("Error finding definition: {:?}", e);
+                        Ok(None) // Graceful degradation
+                    }
+                }
+            })
+            .await
     }
 
     /// Document formatting request
     ///
     /// Called when the user formats a document.
-    /// This is a stub implementation - full implementation will be in FORMAT-001.
+    /// Only whitespace is formatted - statement layout will be in FORMAT-001.
     async fn formatting(&self, params: DocumentFormattingParams) -> Result<Option<Vec<TextEdit>>> {
         let uri = params.text_document.uri;
 
         info!("Document formatting requested: uri={}", uri);
 
         // TODO: (FORMAT-001) Implement SQL formatting
-        self.log_message(
-            "Document formatting is not yet implemented (FORMAT-001)",
-            MessageType::INFO,
-        )
-        .await;
+        let Some(document) = self.documents.get_document(&uri).await else {
+            return Ok(None);
+        };
 
-        Ok(None)
+        Ok(Some(formatting::whitespace_edits(&document.get_content())))
+    }
+
+    /// Code action request
+    ///
+    /// Called when the client asks for fixes for a range. Offers quick fixes
+    /// for lint diagnostics (insert WHERE, suppression comment).
+    async fn code_action(&self, params: CodeActionParams) -> Result<Option<CodeActionResponse>> {
+        let uri = params.text_document.uri;
+
+        debug!("Code actions requested: uri={}", uri);
+
+        let Some(document) = self.documents.get_document(&uri).await else {
+            warn!("Document not found: {}", uri);
+            return Ok(None);
+        };
+
+        let actions =
+            lint::code_actions(&uri, &document.get_content(), &params.context.diagnostics);
+        if actions.is_empty() {
+            Ok(None)
+        } else {
+            Ok(Some(actions))
+        }
     }
 
     /// Document symbols request
@@ -726,85 +2282,147 @@ impl LanguageServer for LspBackend {
         &self,
         params: DocumentSymbolParams,
     ) -> Result<Option<DocumentSymbolResponse>> {
-        let uri = params.text_document.uri;
+        let uri = params.text_document.uri.clone();
+        self.request_logger
+            .track("textDocument/documentSymbol", &uri, async move {
+                let uri = params.text_document.uri;
+
+                info!("Document symbols requested: uri={}", uri);
+
+                // 1. Get document from store
+                let document = match self.documents.get_document(&uri).await {
+                    Some(doc) => doc,
+                    None => {
+                        warn!("Document not found: {}", uri);
+                        return Ok(None);
+                    }
+                };
 
-        info!("Document symbols requested: uri={}", uri);
+                // 2. Get parse tree
+                let tree = match document.tree() {
+                    Some(t) => t,
+                    None => {
+                        warn!("Document not parsed: {}", uri);
+                        return Ok(None); // Graceful degradation
+                    }
+                };
 
-        // 1. Get document from store
-        let document = match self.documents.get_document(&uri).await {
-            Some(doc) => doc,
-            None => {
-                warn!("Document not found: {}", uri);
-                return Ok(None);
-            }
-        };
+                // 3. Get catalog (optional for graceful degradation)
+                let catalog = match self.get_config().await {
+                    Some(config) => match self.request_context.catalog_for_config(&config).await {
+                        Ok(cat) => Some(cat),
+                        Err(e) => {
+                            warn!("Catalog unavailable for symbols: {}", e);
+                            None
+                        }
+                    },
+                    None => None,
+                };
 
-        // 2. Get parse tree
-        let tree = match document.tree() {
-            Some(t) => t,
-            None => {
-                warn!("Document not parsed: {}", uri);
-                return Ok(None); // Graceful degradation
-            }
-        };
+                // 4. Lock parse tree and extract source
+                let tree_lock = match tree.try_lock() {
+                    Ok(lock) => lock,
+                    Err(_) => {
+                        error!("Failed to acquire tree lock for document symbols");
+                        return Ok(None);
+                    }
+                };
 
-        // 3. Get catalog (optional for graceful degradation)
-        let catalog = match self.get_config().await {
-            Some(config) => match self.request_context.catalog_for_config(&config).await {
-                Ok(cat) => Some(cat),
-                Err(e) => {
-                    warn!("Catalog unavailable for symbols: {}", e);
-                    None
+                let root_node = tree_lock.root_node();
+                let source = document.get_content();
+
+                // 5. Build symbols from CST
+                let mut queries = match SymbolBuilder::build_from_cst(&root_node, source.as_str()) {
+                    Ok(queries) => queries,
+                    Err(SymbolError::InvalidSyntax(e)) => {
+                        warn!("Symbol extraction failed due to syntax error: {}", e);
+                        return Ok(None);
+                    }
+                    Err(e) => {
+                        error!("Symbol extraction failed: {}", e);
+                        return Ok(None);
+                    }
+                };
+
+                // 6. Enrich with catalog metadata (if available)
+                if let Some(cat) = catalog {
+                    for query in &mut queries {
+                        let fetcher = SymbolCatalogFetcher::new(cat.clone());
+                        if let Err(e) = fetcher.populate_columns(&mut query.tables).await {
+                            // Log warning but continue with partial results
+                            warn!("Failed to populate columns for some tables: {}", e);
+                        }
+                    }
                 }
-            },
-            None => None,
-        };
 
-        // 4. Lock parse tree and extract source
-        let tree_lock = match tree.try_lock() {
-            Ok(lock) => lock,
-            Err(_) => {
-                error!("Failed to acquire tree lock for document symbols");
-                return Ok(None);
-            }
-        };
+                // 7. Render to LSP format
+                let document_symbols = SymbolRenderer::render_document(queries);
 
-        let root_node = tree_lock.root_node();
-        let source = document.get_content();
+                info!(
+                    "Document symbols returned: {} symbols",
+                    document_symbols.len()
+                );
 
-        // 5. Build symbols from CST
-        let mut queries = match SymbolBuilder::build_from_cst(&root_node, source.as_str()) {
-            Ok(queries) => queries,
-            Err(SymbolError::InvalidSyntax(e)) => {
-                warn!("Symbol extraction failed due to syntax error: {}", e);
-                return Ok(None);
+                Ok(Some(DocumentSymbolResponse::Nested(document_symbols)))
+            })
+            .await
+    }
+
+    /// Execute command request
+    ///
+    /// Called when the client runs one of the commands advertised in the
+    /// server capabilities (e.g. `sql.explain`). Commands are cancelled by
+    /// `$/cancelRequest`, which drops the running handler.
+    async fn execute_command(
+        &self,
+        params: ExecuteCommandParams,
+    ) -> Result<Option<serde_json::Value>> {
+        debug!("Execute command: {}", params.command);
+
+        match params.command.as_str() {
+            commands::EXECUTE_STATEMENT_COMMAND => {
+                let progress = commands::ClientProgress::new(
+                    self.outbound.clone(),
+                    params.work_done_progress_params.work_done_token.clone(),
+                );
+                let result = commands::execute::handle(
+                    &params.arguments,
+                    &self.documents,
+                    &self.request_context,
+                    &progress,
+                )
+                .await;
+                // Cached completions may miss temporary tables
+                self.completion_cache.clear();
+                Ok(Some(result))
             }
-            Err(e) => {
-                error!("Symbol extraction failed: {}", e);
-                return Ok(None);
+            commands::CONNECTION_STATUS_COMMAND => Ok(Some(commands::connection_status(
+                self.request_context.health(),
+            ))),
+            commands::REFRESH_SCHEMA_COMMAND => {
+                let result = commands::refresh_schema(&self.request_context).await;
+                // Cached completions list the tables of the dropped schemas
+                self.completion_cache.clear();
+                self.spawn_schema_prefetch().await;
+                Ok(Some(result))
             }
-        };
-
-        // 6. Enrich with catalog metadata (if available)
-        if let Some(cat) = catalog {
-            for query in &mut queries {
-                let fetcher = SymbolCatalogFetcher::new(cat.clone());
-                if let Err(e) = fetcher.populate_columns(&mut query.tables).await {
-                    // Log warning but continue with partial results
-                    warn!("Failed to populate columns for some tables: {}", e);
-                }
+            commands::RESET_STATS_COMMAND => {
+                self.request_logger.reset_stats();
+                Ok(Some(serde_json::json!({ "ok": true })))
             }
+            commands::EXPLAIN_COMMAND => Ok(Some(
+                commands::explain::handle(
+                    &params.arguments,
+                    &self.documents,
+                    &self.request_context,
+                )
+                .await,
+            )),
+            other => Err(tower_lsp::jsonrpc::Error::invalid_params(format!(
+                "Unknown command: {}",
+                other
+            ))),
         }
-
-        // 7. Render to LSP format
-        let document_symbols = SymbolRenderer::render_document(queries);
-
-        info!(
-            "Document symbols returned: {} symbols",
-            document_symbols.len()
-        );
-
-        Ok(Some(DocumentSymbolResponse::Nested(document_symbols)))
     }
 
     /// Configuration change notification
@@ -820,12 +2438,56 @@ impl LanguageServer for LspBackend {
 
         // Parse configuration from client settings
         match EngineConfig::from_lsp_settings(&params.settings) {
-            Some(config) => {
+            Some(mut config) => {
                 debug!(
                     "!!! LSP: Successfully parsed config: dialect={:?}",
                     config.dialect
                 );
+                if let Err(e) = config.validate_pool() {
+                    self.log_message(
+                        &format!("{}; using the default pool settings", e),
+                        MessageType::WARNING,
+                    )
+                    .await;
+                    let defaults = EngineConfig::default();
+                    config.pool_config = defaults.pool_config;
+                    config.query_timeout_secs = defaults.query_timeout_secs;
+                }
+                if config.is_compatibility_fallback()
+                    && let Some(catalog_dialect) = config.catalog_dialect()
+                {
+                    self.log_message(
+                        &format!(
+                            "Dialect {:?} uses the {:?} catalog in compatibility mode",
+                            config.dialect, catalog_dialect
+                        ),
+                        MessageType::INFO,
+                    )
+                    .await;
+                }
+                if let Some(level) = &config.log_level
+                    && let Err(e) = logging::set_level(level)
+                {
+                    self.log_message(
+                        &format!("Ignoring log.level '{}': {}", level, e),
+                        MessageType::WARNING,
+                    )
+                    .await;
+                }
+                let on_type_formatting = config.on_type_formatting.enabled;
+                let reindex = self.get_config().await.is_none_or(|previous| {
+                    previous.workspace_index != config.workspace_index
+                        || previous.dialect != config.dialect
+                });
                 self.set_config(config).await;
+                self.update_on_type_formatting(on_type_formatting).await;
+                self.spawn_version_detection().await;
+                self.spawn_schema_prefetch().await;
+                if reindex {
+                    self.spawn_workspace_indexing().await;
+                }
+                self.reload_migrations().await;
+                self.republish_open_documents().await;
                 debug!("!!! LSP: Engine configuration updated from client settings");
             }
             None => {
@@ -872,3 +2534,119 @@ pub mod test_utils {
         }
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::diagnostic_scheduler::DEFAULT_MAX_CONCURRENT_RUNS;
+    use std::sync::atomic::AtomicUsize;
+    use tokio::sync::{Notify, watch};
+    use tower_lsp::LspService;
+    use unified_sql_lsp_catalog::{CatalogResult, ColumnMetadata, FunctionMetadata, TableMetadata};
+
+    /// Catalog holding its lookups until opened, counting those in flight
+    struct GatedCatalog {
+        in_flight: AtomicUsize,
+        max_in_flight: AtomicUsize,
+        /// Notified whenever a lookup starts waiting
+        entered: Notify,
+        open: watch::Sender<bool>,
+    }
+
+    impl GatedCatalog {
+        fn new() -> Self {
+            Self {
+                in_flight: AtomicUsize::new(0),
+                max_in_flight: AtomicUsize::new(0),
+                entered: Notify::new(),
+                open: watch::Sender::new(false),
+            }
+        }
+
+        async fn lookup(&self) {
+            let in_flight = self.in_flight.fetch_add(1, Ordering::SeqCst) + 1;
+            self.max_in_flight.fetch_max(in_flight, Ordering::SeqCst);
+            self.entered.notify_one();
+            let mut open = self.open.subscribe();
+            open.wait_for(|open| *open).await.unwrap();
+            self.in_flight.fetch_sub(1, Ordering::SeqCst);
+        }
+
+        /// Wait until the given number of lookups is in flight
+        async fn wait_for_lookups(&self, count: usize) {
+            while self.in_flight.load(Ordering::SeqCst) < count {
+                self.entered.notified().await;
+            }
+        }
+    }
+
+    #[async_trait::async_trait]
+    impl Catalog for GatedCatalog {
+        async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+            self.lookup().await;
+            Ok(Vec::new())
+        }
+
+        async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+            self.lookup().await;
+            Err(CatalogError::TableNotFound(
+                table.to_string(),
+                "gated".to_string(),
+            ))
+        }
+
+        async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+            Ok(Vec::new())
+        }
+    }
+
+    #[tokio::test]
+    async fn test_diagnostic_runs_of_documents_overlap() {
+        let connection_string = "mysql://app@localhost:3306/shop";
+        let catalog = Arc::new(GatedCatalog::new());
+        let mut catalogs = CatalogManager::new();
+        catalogs.register_catalog(connection_string, catalog.clone());
+        let (service, _socket) =
+            LspService::new(|client| LspBackend::with_catalog_manager(client, catalogs));
+        let backend = service.inner();
+        backend
+            .set_config(EngineConfig {
+                connection_string: connection_string.to_string(),
+                cache_enabled: false,
+                schema_diagnostics_severity: Some(DiagnosticSeverity::ERROR),
+                ..Default::default()
+            })
+            .await;
+
+        let documents = 2 * DEFAULT_MAX_CONCURRENT_RUNS;
+        let opens = (0..documents).map(|i| {
+            let uri = Url::parse(&format!("file:///overlap/{}.sql", i)).unwrap();
+            backend.did_open(DidOpenTextDocumentParams {
+                text_document: TextDocumentItem::new(
+                    uri,
+                    "mysql".to_string(),
+                    1,
+                    "SELECT id FROM users".to_string(),
+                ),
+            })
+        });
+        let release = async {
+            // Every slot looks the schema up at once, while the other runs
+            // wait in the queue
+            catalog.wait_for_lookups(DEFAULT_MAX_CONCURRENT_RUNS).await;
+            let stats = backend.diagnostic_scheduler.stats();
+            assert_eq!(stats.running, DEFAULT_MAX_CONCURRENT_RUNS);
+            assert_eq!(stats.queued, documents - DEFAULT_MAX_CONCURRENT_RUNS);
+            catalog.open.send_replace(true);
+        };
+        tokio::join!(futures_util::future::join_all(opens), release);
+
+        assert_eq!(
+            catalog.max_in_flight.load(Ordering::SeqCst),
+            DEFAULT_MAX_CONCURRENT_RUNS
+        );
+        let stats = backend.diagnostic_scheduler.stats();
+        assert_eq!(stats.max_queued, documents - DEFAULT_MAX_CONCURRENT_RUNS);
+        assert_eq!((stats.running, stats.queued), (0, 0));
+    }
+}
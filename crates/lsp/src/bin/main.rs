@@ -1,11 +1,86 @@
 use std::env;
+use std::path::PathBuf;
+use std::sync::Arc;
+
+use unified_sql_lsp_catalog::{
+    Catalog, CatalogError, LiveMySQLCatalog, LivePostgreSQLCatalog, SchemaSnapshot,
+    redact_credentials,
+};
+use unified_sql_lsp_lsp::config_check::ConfigCheck;
+use unified_sql_lsp_lsp::logging::{self, DEFAULT_MAX_BACKUPS, DEFAULT_MAX_SIZE_MB, LogOptions};
+use unified_sql_lsp_lsp::recording::{self, Direction, RecordConfig, Recorded, SessionRecorder};
+use unified_sql_lsp_lsp::tcp::{TcpServer, TcpServerOptions};
+use unified_sql_lsp_lsp::transport::{TransportKind, generate_token};
+
+const SCHEMA_USAGE: &str = "Usage: unified-sql-lsp schema dump --connection <url> --out <file>";
+
+const CONFIG_USAGE: &str = "Usage: unified-sql-lsp config check --config <settings.json>";
+
+const REPLAY_USAGE: &str = "Usage: unified-sql-lsp replay <session.jsonl>";
+
+const HEALTH_USAGE: &str =
+    "Usage: unified-sql-lsp --health [--config <settings.json>] [--check-connections]";
+
+/// Exit code of a failed command: invalid configuration, unreachable
+/// database, server error
+const EXIT_FAILURE: i32 = 1;
+
+/// Exit code of an invalid command line
+///
+/// The exit codes are listed in README.md; keep the table in sync.
+const EXIT_USAGE: i32 = 2;
+
+/// Exit code of a TCP server that could not listen on its port
+const EXIT_BIND_FAILURE: i32 = 3;
+
+/// Environment variable holding the token TCP clients must present
+const AUTH_TOKEN_VAR: &str = "UNIFIED_SQL_LSP_AUTH_TOKEN";
 
 #[tokio::main]
 async fn main() {
-    eprintln!("!!! LSP SERVER: Starting up");
+    let args: Vec<String> = env::args().skip(1).collect();
+
+    // Exit right away, rather than wait for background tasks of the server
+    // nobody is listening to anymore
+    std::process::exit(run(&args).await);
+}
+
+/// Run the command line
+///
+/// # Arguments
+///
+/// * `args` - The arguments, without the program name
+///
+/// # Returns
+///
+/// The process exit code
+async fn run(args: &[String]) -> i32 {
+    if args.iter().any(|arg| arg == "--version") {
+        println!("{}", version());
+        return 0;
+    }
+
+    // `--health` checks the setup instead of running the server
+    if args.iter().any(|arg| arg == "--health") {
+        return run_health_command(args).await;
+    }
 
-    // Parse command-line arguments
-    let args: Vec<String> = env::args().collect();
+    match args.first().map(String::as_str) {
+        // `schema dump` runs instead of the server
+        Some("schema") => run_schema_command(&args[1..]).await,
+        // `config check` runs instead of the server
+        Some("config") => run_config_command(&args[1..]),
+        // `replay` runs a recorded session instead of the server
+        Some("replay") => run_replay_command(&args[1..]).await,
+        _ => run_server(args).await,
+    }
+}
+
+/// Run the server, on stdio or over TCP with `--tcp <port>`
+///
+/// Returns the process exit code once the session (stdio) ends.
+async fn run_server(args: &[String]) -> i32 {
+    eprintln!("!!! LSP SERVER: Starting up");
 
     // Check for --tcp flag
     let tcp_port = args
@@ -20,6 +95,52 @@ async fn main() {
         .position(|arg| arg == "--catalog")
         .and_then(|idx| args.get(idx + 1));
 
+    // Check for --transport and --ws-path flags (TCP mode only)
+    let option = |name: &str| {
+        args.iter()
+            .position(|arg| arg == name)
+            .and_then(|idx| args.get(idx + 1))
+    };
+    let transport = match option("--transport").map(|kind| kind.parse::<TransportKind>()) {
+        Some(Ok(transport)) => transport,
+        Some(Err(e)) => {
+            eprintln!("{}", e);
+            return EXIT_USAGE;
+        }
+        None => TransportKind::default(),
+    };
+    let ws_path = option("--ws-path").cloned();
+
+    // The token comes from the environment, so it does not show up in the
+    // process list; `--auth` without one generates it
+    let auth_token = match env::var(AUTH_TOKEN_VAR) {
+        Ok(token) if !token.is_empty() => Some(token),
+        _ if args.iter().any(|arg| arg == "--auth") => {
+            let token = match generate_token() {
+                Ok(token) => token,
+                Err(e) => {
+                    eprintln!("Failed to generate an authentication token: {}", e);
+                    return EXIT_FAILURE;
+                }
+            };
+            eprintln!("!!! LSP SERVER: Authentication token: {}", token);
+            Some(token)
+        }
+        _ => None,
+    };
+
+    let log_options = match log_options(args, tcp_port.is_some()) {
+        Ok(options) => options,
+        Err(e) => {
+            eprintln!("{}", e);
+            return EXIT_USAGE;
+        }
+    };
+    if let Err(e) = logging::init(&log_options) {
+        eprintln!("Failed to set up logging: {}", e);
+        return EXIT_FAILURE;
+    }
+
     if let Some(port) = tcp_port {
         // Run in TCP mode
         eprintln!("!!! LSP SERVER: Running in TCP mode on port {}", port);
@@ -29,19 +150,28 @@ async fn main() {
             eprintln!("!!! LSP SERVER: Using catalog: {}", catalog);
         }
 
-        // Initialize stderr logging for TCP mode (safe since stdout not used)
-        tracing_subscriber::fmt()
-            .with_env_filter("unified_sql_lsp=debug,tower_lsp=debug")
-            .with_writer(std::io::stderr)
-            .init();
-
         // Load static catalog
         let catalog = std::sync::Arc::new(unified_sql_lsp_catalog::StaticCatalog::new());
-        let server = unified_sql_lsp_lsp::tcp::TcpServer::new(port, catalog)
-            .await
-            .expect("Failed to start TCP server");
+        let options = TcpServerOptions {
+            transport,
+            path: ws_path,
+            auth_token,
+        };
+        let server = match TcpServer::with_options(port, catalog, options).await {
+            Ok(server) => server,
+            Err(e) => {
+                eprintln!("Failed to listen on port {}: {}", port, e);
+                return EXIT_BIND_FAILURE;
+            }
+        };
 
-        server.serve().await.expect("TCP server error");
+        match server.serve().await {
+            Ok(()) => 0,
+            Err(e) => {
+                eprintln!("TCP server error: {}", e);
+                EXIT_FAILURE
+            }
+        }
     } else {
         // Run in stdio mode (default)
         eprintln!("!!! LSP SERVER: Running in stdio mode");
@@ -51,10 +181,8 @@ async fn main() {
             eprintln!("!!! LSP SERVER: Using catalog: {}", catalog);
         }
 
-        // DO NOT initialize logging - it interferes with JSON-RPC protocol on stdout
-        // The LSP protocol requires stdout to be used exclusively for JSON-RPC messages
-        // Logs should only go to stderr, but tracing_subscriber doesn't respect this in all cases
-        // So we completely disable logging when running as LSP server
+        // Stdout carries the JSON-RPC messages, so logs only go to the log
+        // file and, with `--log-stderr`, to stderr
 
         use tower_lsp::{LspService, Server};
 
@@ -62,10 +190,408 @@ async fn main() {
         let stdin = tokio::io::stdin();
         let stdout = tokio::io::stdout();
 
-        // Create the LSP service
-        let (service, socket) = LspService::new(unified_sql_lsp_lsp::backend::LspBackend::new);
+        // Create the LSP service, with the server's custom requests; the
+        // server exits with the editor that started it
+        use unified_sql_lsp_lsp::backend::{LspBackend, STATS_METHOD};
+        use unified_sql_lsp_lsp::diagnostic_scheduler::FOCUS_DOCUMENT_METHOD;
+        let (service, socket) =
+            LspService::build(|client| LspBackend::new(client).with_parent_watchdog())
+                .custom_method(STATS_METHOD, LspBackend::stats)
+                .custom_method(FOCUS_DOCUMENT_METHOD, LspBackend::focus_document)
+                .finish();
+
+        // Run the server using Server::new, recording the session if asked
+        let recorder = option("--record-dir").map(|dir| {
+            SessionRecorder::create(RecordConfig {
+                redact_documents: args.iter().any(|arg| arg == "--record-redact"),
+                ..RecordConfig::new(dir)
+            })
+        });
+        let recorder = match recorder.transpose() {
+            Ok(recorder) => recorder.map(Arc::new),
+            Err(e) => {
+                eprintln!("Failed to create the session file: {}", e);
+                return EXIT_FAILURE;
+            }
+        };
+        match recorder {
+            Some(recorder) => {
+                eprintln!("!!! LSP SERVER: Recording to {}", recorder.path().display());
+                let stdin = Recorded::new(stdin, Direction::In, recorder.clone());
+                let stdout = Recorded::new(stdout, Direction::Out, recorder);
+                Server::new(stdin, stdout, socket).serve(service).await;
+            }
+            None => Server::new(stdin, stdout, socket).serve(service).await,
+        }
+
+        // The server returns on `exit`, or once stdin is closed because the
+        // editor died without sending it
+        eprintln!("!!! LSP SERVER: Session ended, exiting");
+        0
+    }
+}
+
+/// Parse the `--log-*` flags
+///
+/// Logs go to stderr in TCP mode. A stdio server logs to stderr only with
+/// `--log-stderr`, editors often swallowing it; `--log-file` is the way to
+/// keep its logs.
+fn log_options(args: &[String], tcp: bool) -> Result<LogOptions, String> {
+    let option = |name: &str| {
+        args.iter()
+            .position(|arg| arg == name)
+            .and_then(|idx| args.get(idx + 1))
+    };
+    let number = |name: &str, default: u64| match option(name) {
+        Some(value) => value
+            .parse::<u64>()
+            .map_err(|_| format!("{} expects a number, got '{}'", name, value)),
+        None => Ok(default),
+    };
+
+    Ok(LogOptions {
+        file: option("--log-file").map(PathBuf::from),
+        max_size_mb: number("--log-max-size-mb", DEFAULT_MAX_SIZE_MB)?,
+        max_backups: number("--log-max-backups", DEFAULT_MAX_BACKUPS as u64)? as usize,
+        format: option("--log-format")
+            .map(|format| format.parse())
+            .transpose()?
+            .unwrap_or_default(),
+        level: option("--log-level").cloned(),
+        stderr: tcp || args.iter().any(|arg| arg == "--log-stderr"),
+    })
+}
+
+/// Version, commit and build date of the binary
+///
+/// Release builds set the commit and date through the
+/// `UNIFIED_SQL_LSP_COMMIT` and `UNIFIED_SQL_LSP_BUILD_DATE` environment
+/// variables.
+fn version() -> String {
+    format!(
+        "unified-sql-lsp {} (commit {}, built {})",
+        env!("CARGO_PKG_VERSION"),
+        option_env!("UNIFIED_SQL_LSP_COMMIT").unwrap_or("unknown"),
+        option_env!("UNIFIED_SQL_LSP_BUILD_DATE").unwrap_or("unknown"),
+    )
+}
+
+/// Run `--health [--config <file>] [--check-connections]`
+///
+/// Prints the version, then checks the settings file like `config check`
+/// and, with `--check-connections`, connects to the configured database.
+/// Returns the process exit code: 0 if every check passed.
+async fn run_health_command(args: &[String]) -> i32 {
+    let path = args
+        .iter()
+        .position(|arg| arg == "--config")
+        .map(|idx| args.get(idx + 1));
+    let check_connections = args.iter().any(|arg| arg == "--check-connections");
+
+    println!("{}", version());
+    let path = match path {
+        Some(Some(path)) => path,
+        Some(None) => {
+            eprintln!("{}", HEALTH_USAGE);
+            return EXIT_USAGE;
+        }
+        None if check_connections => {
+            eprintln!("--check-connections needs --config\n{}", HEALTH_USAGE);
+            return EXIT_USAGE;
+        }
+        None => {
+            println!("config: not given");
+            return 0;
+        }
+    };
+
+    let settings = match read_settings(path) {
+        Ok(settings) => settings,
+        Err(e) => {
+            println!("config: failed to read {}: {}", path, e);
+            return EXIT_FAILURE;
+        }
+    };
+    let check = ConfigCheck::run(&settings);
+    let config = match (check.is_valid(), &check.config) {
+        (true, Some(config)) => config,
+        _ => {
+            print!("config: invalid\n{}", check.report());
+            return EXIT_FAILURE;
+        }
+    };
+    println!("config: ok");
+
+    if !check_connections {
+        return 0;
+    }
+    if !config.has_connection() {
+        println!("connection: none configured");
+        return 0;
+    }
+    let result = async {
+        let catalog = connect_catalog(&config.connection_string).await?;
+        catalog.list_tables().await
+    }
+    .await;
+    match result {
+        Ok(tables) => {
+            println!("connection: ok ({} tables)", tables.len());
+            0
+        }
+        Err(e) => {
+            println!("connection: failed: {}", redact_credentials(&e.to_string()));
+            EXIT_FAILURE
+        }
+    }
+}
+
+/// Run `schema dump --connection <url> --out <file>`
+///
+/// Returns the process exit code.
+async fn run_schema_command(args: &[String]) -> i32 {
+    let option = |name: &str| {
+        args.iter()
+            .position(|arg| arg == name)
+            .and_then(|idx| args.get(idx + 1))
+    };
+
+    let (Some("dump"), Some(connection_string), Some(out)) = (
+        args.first().map(String::as_str),
+        option("--connection"),
+        option("--out"),
+    ) else {
+        eprintln!("{}", SCHEMA_USAGE);
+        return EXIT_USAGE;
+    };
+
+    let result = async {
+        let catalog = connect_catalog(connection_string).await?;
+        let snapshot = SchemaSnapshot::capture(catalog.as_ref()).await?;
+        snapshot.save(out)?;
+        Ok::<_, CatalogError>(snapshot.tables.len())
+    }
+    .await;
+
+    match result {
+        Ok(tables) => {
+            eprintln!("Wrote {} tables to {}", tables, out);
+            0
+        }
+        Err(e) => {
+            eprintln!("Schema dump failed: {}", e);
+            EXIT_FAILURE
+        }
+    }
+}
+
+/// Run `config check --config <file>`
+///
+/// The file holds the client settings (`{ "unifiedSqlLsp": { ... } }`).
+/// Returns the process exit code.
+fn run_config_command(args: &[String]) -> i32 {
+    let path = args
+        .iter()
+        .position(|arg| arg == "--config")
+        .and_then(|idx| args.get(idx + 1));
+    let (Some("check"), Some(path)) = (args.first().map(String::as_str), path) else {
+        eprintln!("{}", CONFIG_USAGE);
+        return EXIT_USAGE;
+    };
+
+    let settings = match read_settings(path) {
+        Ok(settings) => settings,
+        Err(e) => {
+            eprintln!("Failed to read {}: {}", path, e);
+            return EXIT_FAILURE;
+        }
+    };
+
+    let check = ConfigCheck::run(&settings);
+    print!("{}", check.report());
+    if check.is_valid() { 0 } else { EXIT_FAILURE }
+}
+
+/// Read a settings file (`{ "unifiedSqlLsp": { ... } }`)
+fn read_settings(path: &str) -> Result<serde_json::Value, String> {
+    std::fs::read_to_string(path)
+        .map_err(|e| e.to_string())
+        .and_then(|text| serde_json::from_str(&text).map_err(|e| e.to_string()))
+}
+
+/// Run `replay <file>`
+///
+/// Returns the process exit code: 0 if the server answered as recorded.
+async fn run_replay_command(args: &[String]) -> i32 {
+    let Some(path) = args.first() else {
+        eprintln!("{}", REPLAY_USAGE);
+        return EXIT_USAGE;
+    };
+
+    let report = match recording::replay(std::path::Path::new(path)).await {
+        Ok(report) => report,
+        Err(e) => {
+            eprintln!("Failed to read {}: {}", path, e);
+            return EXIT_FAILURE;
+        }
+    };
+
+    println!(
+        "Replayed {} requests and {} notifications",
+        report.requests, report.notifications
+    );
+    for divergence in &report.divergences {
+        println!(
+            "Divergence in {} (id {}):\n  recorded: {}\n  replayed: {}",
+            divergence.method,
+            divergence.id,
+            divergence.recorded,
+            divergence
+                .replayed
+                .as_ref()
+                .map_or("no response".to_string(), |replayed| replayed.to_string())
+        );
+    }
+    if let Some(panic) = &report.panic {
+        println!("Server panicked: {}", panic);
+    }
+    if report.is_success() { 0 } else { EXIT_FAILURE }
+}
+
+/// Open a live catalog for a connection string, picking the driver by scheme
+async fn connect_catalog(connection_string: &str) -> Result<Arc<dyn Catalog>, CatalogError> {
+    if connection_string.starts_with("mysql://") {
+        Ok(Arc::new(LiveMySQLCatalog::new(connection_string).await?))
+    } else if connection_string.starts_with("postgres://")
+        || connection_string.starts_with("postgresql://")
+    {
+        Ok(Arc::new(
+            LivePostgreSQLCatalog::new(connection_string).await?,
+        ))
+    } else {
+        Err(CatalogError::ConfigurationError(
+            "connection string must start with mysql:// or postgresql://".to_string(),
+        ))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn args(args: &[&str]) -> Vec<String> {
+        args.iter().map(|arg| arg.to_string()).collect()
+    }
+
+    #[tokio::test]
+    async fn test_version_and_usage_exit_codes() {
+        assert_eq!(run(&args(&["--version"])).await, 0);
+        assert_eq!(run(&args(&["config"])).await, EXIT_USAGE);
+        assert_eq!(run(&args(&["schema", "dump"])).await, EXIT_USAGE);
+        assert_eq!(run(&args(&["replay"])).await, EXIT_USAGE);
+        assert_eq!(run(&args(&["--health", "--config"])).await, EXIT_USAGE);
+        assert_eq!(
+            run(&args(&["--health", "--check-connections"])).await,
+            EXIT_USAGE
+        );
+        assert_eq!(
+            run(&args(&["--tcp", "0", "--transport", "pigeon"])).await,
+            EXIT_USAGE
+        );
+    }
+
+    #[tokio::test]
+    async fn test_health_exit_codes() {
+        let dir =
+            std::env::temp_dir().join(format!("unified-sql-lsp-health-{}", std::process::id()));
+        std::fs::create_dir_all(&dir).unwrap();
+        let schema = dir.join("schema.json");
+        SchemaSnapshot::new(vec![], vec![]).save(&schema).unwrap();
+        let write = |name: &str, settings: serde_json::Value| {
+            let path = dir.join(name);
+            std::fs::write(&path, settings.to_string()).unwrap();
+            path.to_string_lossy().into_owned()
+        };
+        let offline = write(
+            "offline.json",
+            json!({ "unifiedSqlLsp": { "dialect": "mysql", "schemaFile": schema } }),
+        );
+        let invalid = write(
+            "invalid.json",
+            json!({ "unifiedSqlLsp": { "dialect": "oracle" } }),
+        );
+        let missing = dir.join("missing.json").to_string_lossy().into_owned();
+
+        assert_eq!(run(&args(&["--health"])).await, 0);
+        assert_eq!(run(&args(&["--health", "--config", &offline])).await, 0);
+        // Without a connection string, there is no connection to check
+        assert_eq!(
+            run(&args(&[
+                "--health",
+                "--config",
+                &offline,
+                "--check-connections"
+            ]))
+            .await,
+            0
+        );
+        assert_eq!(
+            run(&args(&["--health", "--config", &invalid])).await,
+            EXIT_FAILURE
+        );
+        assert_eq!(
+            run(&args(&["--health", "--config", &missing])).await,
+            EXIT_FAILURE
+        );
+
+        std::fs::remove_dir_all(&dir).unwrap();
+    }
+
+    #[test]
+    fn test_log_options() {
+        let options = log_options(
+            &args(&["--log-file", "/tmp/lsp.log", "--log-format", "json"]),
+            false,
+        )
+        .unwrap();
+        assert_eq!(options.file, Some(PathBuf::from("/tmp/lsp.log")));
+        assert_eq!(options.format, logging::LogFormat::Json);
+        assert_eq!(options.max_size_mb, DEFAULT_MAX_SIZE_MB);
+        assert_eq!(options.level, None);
+        assert!(!options.stderr);
+
+        let options = log_options(
+            &args(&["--log-level", "info", "--log-max-backups", "5"]),
+            true,
+        )
+        .unwrap();
+        assert_eq!(options.level.as_deref(), Some("info"));
+        assert_eq!(options.max_backups, 5);
+        assert!(options.stderr);
+
+        assert!(log_options(&args(&["--log-max-size-mb", "ten"]), false).is_err());
+        assert!(log_options(&args(&["--log-format", "xml"]), false).is_err());
+    }
+
+    #[tokio::test]
+    async fn test_unwritable_record_dir_exit_code() {
+        // A file where the session directory should be
+        let path =
+            std::env::temp_dir().join(format!("unified-sql-lsp-record-{}", std::process::id()));
+        std::fs::write(&path, "").unwrap();
+
+        let dir = path.to_string_lossy();
+        assert_eq!(run(&args(&["--record-dir", &dir])).await, EXIT_FAILURE);
+
+        std::fs::remove_file(&path).unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_port_in_use_exit_code() {
+        let listener = std::net::TcpListener::bind("0.0.0.0:0").unwrap();
+        let port = listener.local_addr().unwrap().port().to_string();
 
-        // Run the server using Server::new
-        Server::new(stdin, stdout, socket).serve(service).await;
+        assert_eq!(run(&args(&["--tcp", &port])).await, EXIT_BIND_FAILURE);
     }
 }
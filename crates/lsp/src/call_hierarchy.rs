@@ -0,0 +1,218 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Call hierarchy
+//!
+//! This module maps the call hierarchy requests onto schema dependencies
+//! (see [`SchemaDependencies`]):
+//!
+//! - `textDocument/prepareCallHierarchy` resolves the table, view or function
+//!   named at the cursor
+//! - `callHierarchy/incomingCalls` of a table or view lists the views and
+//!   functions reading it ("which views depend on this table")
+//! - `callHierarchy/outgoingCalls` of a view or function lists the tables and
+//!   views it reads
+//!
+//! Schema objects have no source file, so items point at synthetic URIs:
+//!
+//! ```text
+//! sql-schema:///table/shop/orders
+//! sql-schema:///view/shop/big_orders
+//! sql-schema:///function/order_count
+//! ```
+//!
+//! The object of an item travels in its `data`, which clients send back with
+//! the incoming and outgoing calls requests.
+
+use tower_lsp::lsp_types::{
+    CallHierarchyIncomingCall, CallHierarchyItem, CallHierarchyOutgoingCall, Position, Range,
+    SymbolKind, Url,
+};
+
+use crate::migrations::{TokenKind, tokenize};
+use crate::schema_dependencies::{SchemaDependencies, SchemaObject, SchemaObjectKind};
+
+/// Scheme of the synthetic URIs of schema objects
+pub const SCHEMA_URI_SCHEME: &str = "sql-schema";
+
+/// Resolve the schema object named at a position
+///
+/// # Arguments
+///
+/// * `dependencies` - Dependencies of the schema
+/// * `source` - Document text
+/// * `position` - Cursor position
+///
+/// # Returns
+///
+/// The item of the table, view or function, or None when the cursor is not
+/// on a name of the schema
+pub fn prepare(
+    dependencies: &SchemaDependencies,
+    source: &str,
+    position: Position,
+) -> Option<Vec<CallHierarchyItem>> {
+    let name = name_at(source, position)?;
+    let object = dependencies.find(&name)?;
+    Some(vec![item(object)])
+}
+
+/// Views and functions reading the object of an item
+pub fn incoming_calls(
+    dependencies: &SchemaDependencies,
+    item: &CallHierarchyItem,
+) -> Vec<CallHierarchyIncomingCall> {
+    let Some(object) = item_object(item) else {
+        return Vec::new();
+    };
+    dependencies
+        .readers(&object)
+        .into_iter()
+        .map(|reader| CallHierarchyIncomingCall {
+            from: self::item(reader),
+            from_ranges: Vec::new(),
+        })
+        .collect()
+}
+
+/// Tables and views read by the object of an item
+pub fn outgoing_calls(
+    dependencies: &SchemaDependencies,
+    item: &CallHierarchyItem,
+) -> Vec<CallHierarchyOutgoingCall> {
+    let Some(object) = item_object(item) else {
+        return Vec::new();
+    };
+    dependencies
+        .reads(&object)
+        .into_iter()
+        .map(|read| CallHierarchyOutgoingCall {
+            to: self::item(read),
+            from_ranges: Vec::new(),
+        })
+        .collect()
+}
+
+/// Synthetic URI of a schema object
+pub fn schema_object_uri(object: &SchemaObject) -> Url {
+    let mut uri = Url::parse(&format!("{}:///", SCHEMA_URI_SCHEME)).expect("valid base URI");
+    if let Ok(mut segments) = uri.path_segments_mut() {
+        segments.clear().push(object.kind.as_str());
+        if !object.schema.is_empty() {
+            segments.push(&object.schema);
+        }
+        segments.push(&object.name);
+    }
+    uri
+}
+
+fn item(object: &SchemaObject) -> CallHierarchyItem {
+    CallHierarchyItem {
+        name: object.name.clone(),
+        kind: match object.kind {
+            SchemaObjectKind::Table | SchemaObjectKind::View => SymbolKind::OBJECT,
+            SchemaObjectKind::Function => SymbolKind::FUNCTION,
+        },
+        tags: None,
+        detail: Some(format!(
+            "{} {}",
+            object.kind.as_str(),
+            object.qualified_name()
+        )),
+        uri: schema_object_uri(object),
+        range: Range::default(),
+        selection_range: Range::default(),
+        data: serde_json::to_value(object).ok(),
+    }
+}
+
+fn item_object(item: &CallHierarchyItem) -> Option<SchemaObject> {
+    serde_json::from_value(item.data.clone()?).ok()
+}
+
+/// Possibly qualified name ending with the identifier at a position
+/// (`orders`, `shop.orders`)
+fn name_at(source: &str, position: Position) -> Option<String> {
+    let tokens = tokenize(source);
+    // Between two adjacent tokens, the cursor is at the start of the second
+    let index = tokens.iter().rposition(|token| {
+        token.is_identifier() && token.range.start <= position && position <= token.range.end
+    })?;
+
+    let mut parts = vec![tokens[index].text.as_str()];
+    let mut first = index;
+    while first >= 2
+        && tokens[first - 1].kind == TokenKind::Symbol('.')
+        && tokens[first - 2].is_identifier()
+    {
+        parts.insert(0, tokens[first - 2].text.as_str());
+        first -= 2;
+    }
+    Some(parts.join("."))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{TableMetadata, TableType};
+    use unified_sql_lsp_ir::Dialect;
+
+    /// `orders` ◄ `big_orders` ◄ `top_customers`
+    fn view_chain() -> SchemaDependencies {
+        let tables = vec![
+            TableMetadata::new("orders", "shop"),
+            TableMetadata::new("big_orders", "shop")
+                .with_type(TableType::View)
+                .with_definition("SELECT id, customer_id FROM orders WHERE total > 100"),
+            TableMetadata::new("top_customers", "shop")
+                .with_type(TableType::View)
+                .with_definition("SELECT customer_id FROM big_orders"),
+        ];
+        SchemaDependencies::build(Dialect::PostgreSQL, &tables, &[])
+    }
+
+    fn prepared(source: &str, character: u32) -> Option<CallHierarchyItem> {
+        prepare(&view_chain(), source, Position::new(0, character))
+            .map(|items| items.into_iter().next().unwrap())
+    }
+
+    #[test]
+    fn test_prepare_on_table_name() {
+        let item = prepared("SELECT * FROM shop.big_orders", 22).unwrap();
+        assert_eq!(item.name, "big_orders");
+        assert_eq!(item.detail.as_deref(), Some("view shop.big_orders"));
+        assert_eq!(item.uri.as_str(), "sql-schema:///view/shop/big_orders");
+
+        assert!(prepared("SELECT * FROM shop.big_orders", 3).is_none());
+        assert!(prepared("SELECT * FROM customers", 16).is_none());
+    }
+
+    #[test]
+    fn test_calls_follow_the_view_chain() {
+        let dependencies = view_chain();
+        let orders = prepared("SELECT 1 FROM orders", 15).unwrap();
+
+        // orders ◄ big_orders ◄ top_customers
+        let incoming = incoming_calls(&dependencies, &orders);
+        assert_eq!(incoming.len(), 1);
+        assert_eq!(incoming[0].from.name, "big_orders");
+        let incoming = incoming_calls(&dependencies, &incoming[0].from);
+        assert_eq!(incoming.len(), 1);
+        assert_eq!(incoming[0].from.name, "top_customers");
+        assert!(incoming_calls(&dependencies, &incoming[0].from).is_empty());
+
+        // top_customers → big_orders → orders
+        let outgoing = outgoing_calls(&dependencies, &incoming[0].from);
+        assert_eq!(outgoing.len(), 1);
+        assert_eq!(outgoing[0].to.name, "big_orders");
+        let outgoing = outgoing_calls(&dependencies, &outgoing[0].to);
+        assert_eq!(outgoing.len(), 1);
+        assert_eq!(
+            outgoing[0].to.uri.as_str(),
+            "sql-schema:///table/shop/orders"
+        );
+        assert!(outgoing_calls(&dependencies, &outgoing[0].to).is_empty());
+    }
+}
@@ -10,15 +10,27 @@
 //! The catalog manager is responsible for:
 //! - Creating catalog instances based on engine configuration
 //! - Reusing catalog connections across multiple completion requests
+//! - Reopening connection pools when their settings change
+//! - Reaching databases through SSH tunnels
+//! - Serving schema snapshot files without a connection
+//! - Loading tables of other databases of the server (`databases` setting)
 //! - Managing catalog lifecycle
+//! - Falling back to the dialect family's catalog for compatible dialects
+//!   (TiDB/MariaDB → MySQL, CockroachDB → PostgreSQL) unless strict mode is set
 
 use std::collections::HashMap;
+use std::path::{Path, PathBuf};
 use std::sync::Arc;
+use std::time::Duration;
+use tracing::{info, warn};
 use unified_sql_lsp_catalog::{
-    Catalog, CatalogError, CatalogResult, LiveMySQLCatalog, LivePostgreSQLCatalog,
+    Catalog, CatalogError, CatalogFilter, CatalogResult, LiveMySQLCatalog, LivePostgreSQLCatalog,
+    MultiCatalog, QueryExecutor, SnapshotCatalog, redact_credentials,
 };
+use unified_sql_lsp_ir::Dialect;
 
 use crate::config::EngineConfig;
+use crate::ssh_tunnel::{SshTunnel, tunnel_target, tunneled_connection_string};
 
 /// Catalog manager
 ///
@@ -29,6 +41,18 @@ pub struct CatalogManager {
 
     /// PostgreSQL catalog instances (keyed by connection string)
     postgres_catalogs: HashMap<String, Arc<LivePostgreSQLCatalog>>,
+
+    /// PostgreSQL catalogs spanning several databases (keyed by connection string)
+    postgres_databases: HashMap<String, PostgresDatabases>,
+
+    /// SSH tunnels (keyed by connection string)
+    tunnels: HashMap<String, SshTunnel>,
+
+    /// Schema snapshot catalogs (keyed by file path); never expire
+    snapshots: HashMap<PathBuf, Arc<SnapshotCatalog>>,
+
+    /// Catalogs provided by the embedder (keyed by connection string)
+    registered: HashMap<String, Arc<dyn Catalog>>,
 }
 
 impl CatalogManager {
@@ -37,9 +61,28 @@ impl CatalogManager {
         Self {
             mysql_catalogs: HashMap::new(),
             postgres_catalogs: HashMap::new(),
+            postgres_databases: HashMap::new(),
+            tunnels: HashMap::new(),
+            snapshots: HashMap::new(),
+            registered: HashMap::new(),
         }
     }
 
+    /// Serve a connection string from the given catalog instead of a live
+    /// database connection
+    ///
+    /// # Arguments
+    ///
+    /// * `connection_string` - Connection string of the configurations served
+    /// * `catalog` - The catalog serving them
+    pub fn register_catalog(
+        &mut self,
+        connection_string: impl Into<String>,
+        catalog: Arc<dyn Catalog>,
+    ) {
+        self.registered.insert(connection_string.into(), catalog);
+    }
+
     /// Get or create a catalog for the given configuration
     ///
     /// # Arguments
@@ -57,18 +100,73 @@ impl CatalogManager {
     /// let columns = catalog.get_columns("users").await?;
     /// ```
     pub async fn get_catalog(&mut self, config: &EngineConfig) -> CatalogResult<Arc<dyn Catalog>> {
-        match config.dialect {
-            unified_sql_lsp_ir::Dialect::MySQL => self
+        if let Some(catalog) = self.registered.get(&config.connection_string) {
+            return Ok(catalog.clone());
+        }
+
+        let Some(catalog_dialect) = config.catalog_dialect() else {
+            return Err(CatalogError::NotSupported(format!(
+                "Dialect {:?} has no dedicated catalog and strict dialect mode is enabled",
+                config.dialect
+            )));
+        };
+
+        if catalog_dialect != config.dialect {
+            info!(
+                "Dialect {:?} has no dedicated catalog, using {:?} catalog for compatibility",
+                config.dialect, catalog_dialect
+            );
+        }
+
+        match catalog_dialect {
+            Dialect::MySQL => self
                 .get_mysql_catalog(config)
                 .await
                 .map(|c| c as Arc<dyn Catalog>),
-            unified_sql_lsp_ir::Dialect::PostgreSQL => self
+            Dialect::PostgreSQL => match &config.databases {
+                Some(filter) => self
+                    .get_postgres_multi_catalog(config, filter)
+                    .await
+                    .map(|c| c as Arc<dyn Catalog>),
+                None => self
+                    .get_postgres_catalog(config)
+                    .await
+                    .map(|c| c as Arc<dyn Catalog>),
+            },
+            _ => Err(CatalogError::NotSupported(format!(
+                "Dialect {:?} is not supported yet",
+                catalog_dialect
+            ))),
+        }
+    }
+
+    /// Get or create a query executor for the given configuration
+    ///
+    /// The executor shares the connection pool of the catalog returned by
+    /// [`Self::get_catalog`] for the same configuration.
+    ///
+    /// # Arguments
+    ///
+    /// * `config` - The engine configuration
+    ///
+    /// # Returns
+    ///
+    /// An Arc to the executor instance
+    pub async fn get_executor(
+        &mut self,
+        config: &EngineConfig,
+    ) -> CatalogResult<Arc<dyn QueryExecutor>> {
+        match config.catalog_dialect() {
+            Some(Dialect::MySQL) => self
+                .get_mysql_catalog(config)
+                .await
+                .map(|c| c as Arc<dyn QueryExecutor>),
+            Some(Dialect::PostgreSQL) => self
                 .get_postgres_catalog(config)
                 .await
-                .map(|c| c as Arc<dyn Catalog>),
-            // TODO: (DIALECT-TIDB-001) Add support for additional dialects (TiDB, MariaDB, CockroachDB)
+                .map(|c| c as Arc<dyn QueryExecutor>),
             _ => Err(CatalogError::NotSupported(format!(
-                "Dialect {:?} is not supported yet",
+                "Executing statements is not supported for dialect {:?}",
                 config.dialect
             ))),
         }
@@ -79,15 +177,30 @@ impl CatalogManager {
         &mut self,
         config: &EngineConfig,
     ) -> CatalogResult<Arc<LiveMySQLCatalog>> {
-        // Check if we already have a catalog for this connection
+        let connection_string = self.connect_string(config).await?;
+        let options = config.pool_options();
+
+        // Reuse the catalog for this connection unless its settings changed
         if let Some(catalog) = self.mysql_catalogs.get(&config.connection_string) {
-            return Ok(catalog.clone());
+            if catalog.connection_string() == connection_string
+                && catalog.pool_options() == &options
+                && catalog.tls_options() == config.tls.as_ref()
+                && catalog.catalog_filter() == config.databases.as_ref()
+            {
+                return Ok(catalog.clone());
+            }
+            info!("Connection settings changed, reopening connection pool");
         }
 
         // Create new catalog
-        let catalog = LiveMySQLCatalog::new(&config.connection_string)
-            .await
-            .map_err(|e| CatalogError::ConnectionFailed(e.to_string()))?;
+        let catalog =
+            LiveMySQLCatalog::with_connect_options(connection_string, options, config.tls.clone())
+                .await
+                .map_err(|e| match e {
+                    CatalogError::ConfigurationError(_) => e,
+                    other => CatalogError::ConnectionFailed(redact_credentials(&other.to_string())),
+                })?
+                .with_catalog_filter(config.databases.clone());
 
         let catalog = Arc::new(catalog);
         self.mysql_catalogs
@@ -101,15 +214,31 @@ impl CatalogManager {
         &mut self,
         config: &EngineConfig,
     ) -> CatalogResult<Arc<LivePostgreSQLCatalog>> {
-        // Check if we already have a catalog for this connection
+        let connection_string = self.connect_string(config).await?;
+        let options = config.pool_options();
+
+        // Reuse the catalog for this connection unless its settings changed
         if let Some(catalog) = self.postgres_catalogs.get(&config.connection_string) {
-            return Ok(catalog.clone());
+            if catalog.connection_string() == connection_string
+                && catalog.pool_options() == &options
+                && catalog.tls_options() == config.tls.as_ref()
+            {
+                return Ok(catalog.clone());
+            }
+            info!("Connection settings changed, reopening connection pool");
         }
 
         // Create new catalog
-        let catalog = LivePostgreSQLCatalog::new(&config.connection_string)
-            .await
-            .map_err(|e| CatalogError::ConnectionFailed(e.to_string()))?;
+        let catalog = LivePostgreSQLCatalog::with_connect_options(
+            connection_string,
+            options,
+            config.tls.clone(),
+        )
+        .await
+        .map_err(|e| match e {
+            CatalogError::ConfigurationError(_) => e,
+            other => CatalogError::ConnectionFailed(redact_credentials(&other.to_string())),
+        })?;
 
         let catalog = Arc::new(catalog);
         self.postgres_catalogs
@@ -118,12 +247,175 @@ impl CatalogManager {
         Ok(catalog)
     }
 
+    /// Get or create a PostgreSQL catalog spanning the databases selected by
+    /// `filter`
+    ///
+    /// The connected database is served by the catalog of
+    /// [`Self::get_postgres_catalog`]; every other selected database gets its
+    /// own connection pool. Databases that cannot be listed or connected to
+    /// are skipped with a warning.
+    async fn get_postgres_multi_catalog(
+        &mut self,
+        config: &EngineConfig,
+        filter: &CatalogFilter,
+    ) -> CatalogResult<Arc<MultiCatalog>> {
+        let default = self.get_postgres_catalog(config).await?;
+
+        // Reuse unless the connected catalog was reopened or the filter changed
+        if let Some(databases) = self.postgres_databases.get(&config.connection_string)
+            && Arc::ptr_eq(&databases.default, &default)
+            && &databases.filter == filter
+        {
+            return Ok(databases.catalog.clone());
+        }
+
+        let names = default.list_databases().await.unwrap_or_else(|e| {
+            warn!("Failed to list databases: {}", e);
+            Vec::new()
+        });
+        let mut catalog = MultiCatalog::new(default.clone());
+        for name in names.into_iter().filter(|name| filter.matches(name)) {
+            let Some(connection_string) =
+                database_connection_string(default.connection_string(), &name)
+            else {
+                continue;
+            };
+            match LivePostgreSQLCatalog::with_connect_options(
+                connection_string,
+                config.pool_options(),
+                config.tls.clone(),
+            )
+            .await
+            {
+                Ok(other) => catalog = catalog.with_database(name, Arc::new(other)),
+                Err(e) => warn!("Skipping database {}: {}", name, e),
+            }
+        }
+        info!(
+            "Loaded {} other database(s): {:?}",
+            catalog.databases().count(),
+            catalog.databases().collect::<Vec<_>>()
+        );
+
+        let catalog = Arc::new(catalog);
+        self.postgres_databases.insert(
+            config.connection_string.clone(),
+            PostgresDatabases {
+                default,
+                filter: filter.clone(),
+                catalog: catalog.clone(),
+            },
+        );
+
+        Ok(catalog)
+    }
+
+    /// Get or load the catalog of a schema snapshot file
+    ///
+    /// Snapshots are loaded once and kept until [`Self::reload_snapshots`].
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::SerializationError` if the file cannot be read or
+    /// parsed.
+    pub fn get_snapshot_catalog(&mut self, path: &Path) -> CatalogResult<Arc<dyn Catalog>> {
+        if let Some(catalog) = self.snapshots.get(path) {
+            return Ok(catalog.clone());
+        }
+
+        info!("Loading schema snapshot {}", path.display());
+        let catalog = Arc::new(SnapshotCatalog::load(path)?);
+        self.snapshots.insert(path.to_path_buf(), catalog.clone());
+
+        Ok(catalog)
+    }
+
+    /// Drop loaded schema snapshots so they are read again on next use
+    ///
+    /// # Returns
+    ///
+    /// The number of dropped snapshots
+    pub fn reload_snapshots(&mut self) -> usize {
+        let count = self.snapshots.len();
+        self.snapshots.clear();
+        count
+    }
+
+    /// Get the connection string to connect with
+    ///
+    /// Without SSH settings this is the configured connection string. With SSH
+    /// settings, a tunnel is started (or reused) and the connection string is
+    /// rewritten to its local port.
+    ///
+    /// # Errors
+    ///
+    /// Returns `CatalogError::ConnectionDown` if the tunnel is not up.
+    async fn connect_string(&mut self, config: &EngineConfig) -> CatalogResult<String> {
+        let Some(ssh) = &config.ssh else {
+            self.tunnels.remove(&config.connection_string);
+            return Ok(config.connection_string.clone());
+        };
+
+        if self
+            .tunnels
+            .get(&config.connection_string)
+            .is_none_or(|tunnel| tunnel.config() != ssh)
+        {
+            let (host, port) = tunnel_target(&config.connection_string).ok_or_else(|| {
+                CatalogError::ConfigurationError(
+                    "SSH tunnels require a connection string with a host".to_string(),
+                )
+            })?;
+            let tunnel = SshTunnel::start(ssh.clone(), &host, port).map_err(|e| {
+                CatalogError::ConnectionFailed(format!("Failed to start SSH tunnel: {}", e))
+            })?;
+            self.tunnels
+                .insert(config.connection_string.clone(), tunnel);
+        }
+
+        let timeout = Duration::from_secs(config.pool_config.connection_timeout_secs);
+        let local_port = self.tunnels[&config.connection_string]
+            .wait_ready(timeout)
+            .await
+            .map_err(|e| {
+                CatalogError::ConnectionDown(format!(
+                    "SSH tunnel via {}@{}:{} is down: {}",
+                    ssh.user, ssh.host, ssh.port, e
+                ))
+            })?;
+
+        tunneled_connection_string(&config.connection_string, local_port).ok_or_else(|| {
+            CatalogError::ConfigurationError(
+                "SSH tunnels require a connection string with a host".to_string(),
+            )
+        })
+    }
+
+    /// Executors of all open connections, keyed by connection string
+    ///
+    /// Used by health checks to ping every connection without holding the
+    /// manager lock.
+    pub fn executors(&self) -> Vec<(String, Arc<dyn QueryExecutor>)> {
+        let mysql = self
+            .mysql_catalogs
+            .iter()
+            .map(|(key, c)| (key.clone(), c.clone() as Arc<dyn QueryExecutor>));
+        let postgres = self
+            .postgres_catalogs
+            .iter()
+            .map(|(key, c)| (key.clone(), c.clone() as Arc<dyn QueryExecutor>));
+        mysql.chain(postgres).collect()
+    }
+
     /// Close all catalog connections
     ///
     /// This should be called when shutting down the server.
     pub async fn close_all(&mut self) {
         self.mysql_catalogs.clear();
         self.postgres_catalogs.clear();
+        self.postgres_databases.clear();
+        self.tunnels.clear();
+        self.snapshots.clear();
     }
 }
 
@@ -133,10 +425,50 @@ impl Default for CatalogManager {
     }
 }
 
+/// PostgreSQL catalog spanning several databases, with what it was built from
+struct PostgresDatabases {
+    /// Catalog of the connected database
+    default: Arc<LivePostgreSQLCatalog>,
+
+    /// Filter that selected the other databases
+    filter: CatalogFilter,
+
+    /// Combined catalog
+    catalog: Arc<MultiCatalog>,
+}
+
+/// Replace the database of a connection string
+///
+/// `postgresql://u:p@host:5432/shop?sslmode=require` becomes
+/// `postgresql://u:p@host:5432/analytics?sslmode=require`.
+///
+/// # Returns
+///
+/// `None` if the connection string is not a URL
+fn database_connection_string(connection_string: &str, database: &str) -> Option<String> {
+    let (scheme, rest) = connection_string.split_once("://")?;
+    let (rest, query) = match rest.split_once('?') {
+        Some((rest, query)) => (rest, Some(query)),
+        None => (rest, None),
+    };
+    let authority = rest
+        .split_once('/')
+        .map_or(rest, |(authority, _)| authority);
+
+    let mut result = format!("{}://{}/{}", scheme, authority, database);
+    if let Some(query) = query {
+        result.push('?');
+        result.push_str(query);
+    }
+    Some(result)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
-    use unified_sql_lsp_ir::Dialect;
+    use crate::config::{ConfigError, ConnectionPoolConfig};
+    use crate::ssh_tunnel::SshTunnelConfig;
+    use unified_sql_lsp_catalog::{SchemaSnapshot, TableMetadata};
 
     #[tokio::test]
     async fn test_catalog_manager_new() {
@@ -153,17 +485,189 @@ mod tests {
     }
 
     #[tokio::test]
-    async fn test_catalog_manager_unsupported_dialect() {
+    async fn test_catalog_manager_strict_dialect_rejects_fallback() {
         let mut manager = CatalogManager::new();
 
-        // TODO: (DIALECT-TIDB-001) Remove this test once TiDB catalog support is implemented
         let config = EngineConfig {
-            dialect: Dialect::TiDB, // Not supported yet
+            dialect: Dialect::TiDB,
+            strict_dialect: true,
             ..Default::default()
         };
 
         let result = manager.get_catalog(&config).await;
         assert!(matches!(result, Err(CatalogError::NotSupported(_))));
+
+        let result = manager.get_executor(&config).await;
+        assert!(matches!(result, Err(CatalogError::NotSupported(_))));
+    }
+
+    #[tokio::test]
+    async fn test_invalid_pool_settings_rejected_before_connecting() {
+        let mut manager = CatalogManager::new();
+
+        let config = EngineConfig {
+            connection_string: "mysql://localhost:1/db".to_string(),
+            pool_config: ConnectionPoolConfig {
+                max_connections: 0,
+                ..Default::default()
+            },
+            ..Default::default()
+        };
+
+        let result = manager.get_catalog(&config).await;
+        assert!(matches!(result, Err(CatalogError::ConfigurationError(_))));
+        assert!(manager.executors().is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_snapshot_catalog_cached_until_reload() {
+        let mut manager = CatalogManager::new();
+        let path = std::env::temp_dir().join(format!(
+            "unified-sql-lsp-manager-snapshot-{}.json",
+            std::process::id()
+        ));
+        let snapshot = SchemaSnapshot::new(vec![TableMetadata::new("users", "shop")], vec![]);
+        snapshot.save(&path).unwrap();
+
+        let catalog = manager.get_snapshot_catalog(&path).unwrap();
+        assert_eq!(catalog.list_tables().await.unwrap().len(), 1);
+
+        // Cached: later file changes are ignored until reloaded
+        SchemaSnapshot::new(vec![], vec![]).save(&path).unwrap();
+        let catalog = manager.get_snapshot_catalog(&path).unwrap();
+        assert_eq!(catalog.list_tables().await.unwrap().len(), 1);
+
+        assert_eq!(manager.reload_snapshots(), 1);
+        let catalog = manager.get_snapshot_catalog(&path).unwrap();
+        assert!(catalog.list_tables().await.unwrap().is_empty());
+
+        std::fs::remove_file(&path).unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_ssh_tunnel_requires_host() {
+        let mut manager = CatalogManager::new();
+
+        let config = EngineConfig {
+            connection_string: "mysql:///db".to_string(),
+            ssh: Some(SshTunnelConfig {
+                host: "bastion.example.com".to_string(),
+                port: 22,
+                user: "deploy".to_string(),
+                key_file: None,
+            }),
+            ..Default::default()
+        };
+
+        let result = manager.get_catalog(&config).await;
+        assert!(matches!(result, Err(CatalogError::ConfigurationError(_))));
+        assert!(manager.tunnels.is_empty());
+    }
+
+    #[test]
+    fn test_database_connection_string() {
+        assert_eq!(
+            database_connection_string(
+                "postgresql://app:secret@db:5432/shop?sslmode=require",
+                "analytics"
+            )
+            .as_deref(),
+            Some("postgresql://app:secret@db:5432/analytics?sslmode=require")
+        );
+        assert_eq!(
+            database_connection_string("postgresql://localhost", "analytics").as_deref(),
+            Some("postgresql://localhost/analytics")
+        );
+        assert_eq!(database_connection_string("host=db", "analytics"), None);
+    }
+
+    #[test]
+    fn test_database_filter_from_lsp_settings() {
+        let settings = serde_json::json!({
+            "unifiedSqlLsp": {
+                "dialect": "postgresql",
+                "connectionString": "postgresql://localhost/shop",
+                "databases": { "include": ["app_*"], "exclude": ["*_test"] }
+            }
+        });
+        let config = EngineConfig::from_lsp_settings(&settings).unwrap();
+        let filter = config.databases.unwrap();
+
+        assert!(filter.matches("app_billing"));
+        assert!(!filter.matches("app_billing_test"));
+        assert!(!filter.matches("postgres"));
+    }
+
+    #[test]
+    fn test_pool_settings_from_lsp_settings() {
+        let settings = serde_json::json!({
+            "unifiedSqlLsp": {
+                "dialect": "postgresql",
+                "connectionString": "postgresql://localhost/db",
+                "pool": { "maxConnections": 2, "idleTimeoutSecs": 60, "maxLifetimeSecs": 600 },
+                "queryTimeoutSecs": 15
+            }
+        });
+        let config = EngineConfig::from_lsp_settings(&settings).unwrap();
+        let options = config.pool_options();
+
+        assert_eq!(options.max_connections, 2);
+        assert_eq!(options.min_connections, 0);
+        assert_eq!(options.idle_timeout_secs, 60);
+        assert_eq!(options.max_lifetime_secs, 600);
+        assert_eq!(options.statement_timeout_secs, 15);
+        assert!(config.validate_pool().is_ok());
+
+        let mut config = config;
+        config.pool_config.idle_timeout_secs = 3600;
+        assert!(matches!(
+            config.validate_pool(),
+            Err(ConfigError::InvalidPoolConfig { .. })
+        ));
+    }
+
+    #[test]
+    fn test_catalog_dialect_exact_match_preferred() {
+        for dialect in [Dialect::MySQL, Dialect::PostgreSQL] {
+            let config = EngineConfig {
+                dialect,
+                ..Default::default()
+            };
+            assert_eq!(config.catalog_dialect(), Some(dialect));
+            assert!(!config.is_compatibility_fallback());
+
+            // Strict mode does not affect dialects with a dedicated catalog
+            let strict = EngineConfig {
+                strict_dialect: true,
+                ..config
+            };
+            assert_eq!(strict.catalog_dialect(), Some(dialect));
+        }
+    }
+
+    #[test]
+    fn test_catalog_dialect_compatibility_fallback() {
+        let cases = [
+            (Dialect::TiDB, Dialect::MySQL),
+            (Dialect::MariaDB, Dialect::MySQL),
+            (Dialect::CockroachDB, Dialect::PostgreSQL),
+        ];
+
+        for (dialect, expected) in cases {
+            let config = EngineConfig {
+                dialect,
+                ..Default::default()
+            };
+            assert_eq!(config.catalog_dialect(), Some(expected));
+            assert!(config.is_compatibility_fallback());
+
+            let strict = EngineConfig {
+                strict_dialect: true,
+                ..config
+            };
+            assert_eq!(strict.catalog_dialect(), None);
+            assert!(!strict.is_compatibility_fallback());
+        }
     }
 
     #[tokio::test]
@@ -172,6 +676,7 @@ mod tests {
         manager.close_all().await;
         assert!(manager.mysql_catalogs.is_empty());
         assert!(manager.postgres_catalogs.is_empty());
+        assert!(manager.executors().is_empty());
     }
 
     // Note: Tests with actual database connections require
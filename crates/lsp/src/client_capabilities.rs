@@ -0,0 +1,218 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Client capabilities
+//!
+//! This module extracts the client capabilities the server adapts to from
+//! the `initialize` request.
+//!
+//! Features the client does not advertise are not used:
+//! - completion items are plain text unless snippets are supported
+//! - diagnostics drop their related information unless it is supported
+//! - server-initiated work-done progress is only reported when supported
+//! - format-on-save requires `willSaveWaitUntil`
+//! - the SQL file watcher is only registered with dynamic registration
+//!
+//! Positions are exchanged in UTF-16, the encoding every client supports and
+//! the only one the server computes.
+
+use tower_lsp::lsp_types::{ClientCapabilities, Diagnostic, PositionEncodingKind};
+
+/// Position encodings computed by the server, in order of preference
+const SUPPORTED_POSITION_ENCODINGS: [PositionEncodingKind; 1] = [PositionEncodingKind::UTF16];
+
+/// Client features the server adapts to
+#[derive(Debug, Clone, PartialEq)]
+pub struct ClientFeatures {
+    /// `textDocument.completion.completionItem.snippetSupport`
+    pub snippets: bool,
+
+    /// `textDocument.publishDiagnostics.relatedInformation`
+    pub related_information: bool,
+
+    /// `window.workDoneProgress`
+    pub work_done_progress: bool,
+
+    /// `textDocument.synchronization.willSaveWaitUntil`
+    pub will_save_wait_until: bool,
+
+    /// `workspace.didChangeWatchedFiles.dynamicRegistration`
+    pub watched_files_registration: bool,
+
+    /// `textDocument.onTypeFormatting.dynamicRegistration`
+    pub on_type_formatting_registration: bool,
+
+    /// Position encoding negotiated from `general.positionEncodings`
+    pub position_encoding: PositionEncodingKind,
+}
+
+impl Default for ClientFeatures {
+    /// Features of a client that advertised no capabilities
+    fn default() -> Self {
+        Self {
+            snippets: false,
+            related_information: false,
+            work_done_progress: false,
+            will_save_wait_until: false,
+            watched_files_registration: false,
+            on_type_formatting_registration: false,
+            position_encoding: PositionEncodingKind::UTF16,
+        }
+    }
+}
+
+impl ClientFeatures {
+    /// Extract the features from the capabilities sent with `initialize`
+    pub fn from_capabilities(capabilities: &ClientCapabilities) -> Self {
+        let text_document = capabilities.text_document.as_ref();
+
+        Self {
+            snippets: text_document
+                .and_then(|t| t.completion.as_ref())
+                .and_then(|c| c.completion_item.as_ref())
+                .and_then(|item| item.snippet_support)
+                .unwrap_or(false),
+            related_information: text_document
+                .and_then(|t| t.publish_diagnostics.as_ref())
+                .and_then(|p| p.related_information)
+                .unwrap_or(false),
+            work_done_progress: capabilities
+                .window
+                .as_ref()
+                .and_then(|window| window.work_done_progress)
+                .unwrap_or(false),
+            will_save_wait_until: text_document
+                .and_then(|t| t.synchronization.as_ref())
+                .and_then(|sync| sync.will_save_wait_until)
+                .unwrap_or(false),
+            watched_files_registration: capabilities
+                .workspace
+                .as_ref()
+                .and_then(|workspace| workspace.did_change_watched_files.as_ref())
+                .and_then(|watched| watched.dynamic_registration)
+                .unwrap_or(false),
+            on_type_formatting_registration: text_document
+                .and_then(|t| t.on_type_formatting.as_ref())
+                .and_then(|on_type| on_type.dynamic_registration)
+                .unwrap_or(false),
+            position_encoding: negotiate_position_encoding(
+                capabilities
+                    .general
+                    .as_ref()
+                    .and_then(|general| general.position_encodings.as_deref()),
+            ),
+        }
+    }
+
+    /// Remove the parts of a diagnostic the client cannot show
+    pub fn adapt_diagnostic(&self, mut diagnostic: Diagnostic) -> Diagnostic {
+        if !self.related_information {
+            diagnostic.related_information = None;
+        }
+        diagnostic
+    }
+}
+
+/// Pick the position encoding of the session
+///
+/// # Arguments
+///
+/// * `offered` - Encodings offered by the client, in its order of preference
+///
+/// # Returns
+///
+/// The client's most preferred encoding the server supports, or UTF-16,
+/// which every client must support
+pub fn negotiate_position_encoding(
+    offered: Option<&[PositionEncodingKind]>,
+) -> PositionEncodingKind {
+    offered
+        .unwrap_or_default()
+        .iter()
+        .find(|encoding| SUPPORTED_POSITION_ENCODINGS.contains(encoding))
+        .cloned()
+        .unwrap_or(PositionEncodingKind::UTF16)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+    use tower_lsp::lsp_types::{DiagnosticRelatedInformation, Location, Position, Range, Url};
+
+    fn capabilities(value: serde_json::Value) -> ClientCapabilities {
+        serde_json::from_value(value).unwrap()
+    }
+
+    #[test]
+    fn test_minimal_client() {
+        let features = ClientFeatures::from_capabilities(&capabilities(json!({})));
+        assert_eq!(features, ClientFeatures::default());
+    }
+
+    #[test]
+    fn test_full_client() {
+        let features = ClientFeatures::from_capabilities(&capabilities(json!({
+            "textDocument": {
+                "completion": { "completionItem": { "snippetSupport": true } },
+                "publishDiagnostics": { "relatedInformation": true },
+                "synchronization": { "willSaveWaitUntil": true },
+                "onTypeFormatting": { "dynamicRegistration": true }
+            },
+            "window": { "workDoneProgress": true },
+            "workspace": { "didChangeWatchedFiles": { "dynamicRegistration": true } },
+            "general": { "positionEncodings": ["utf-8", "utf-16"] }
+        })));
+
+        assert!(features.snippets);
+        assert!(features.related_information);
+        assert!(features.work_done_progress);
+        assert!(features.will_save_wait_until);
+        assert!(features.watched_files_registration);
+        assert!(features.on_type_formatting_registration);
+        assert_eq!(features.position_encoding, PositionEncodingKind::UTF16);
+    }
+
+    #[test]
+    fn test_negotiate_position_encoding() {
+        assert_eq!(
+            negotiate_position_encoding(None),
+            PositionEncodingKind::UTF16
+        );
+        assert_eq!(
+            negotiate_position_encoding(Some(&[PositionEncodingKind::UTF8])),
+            PositionEncodingKind::UTF16
+        );
+    }
+
+    #[test]
+    fn test_related_information_is_dropped_when_unsupported() {
+        let range = Range::new(Position::new(0, 0), Position::new(0, 1));
+        let diagnostic = Diagnostic {
+            related_information: Some(vec![DiagnosticRelatedInformation {
+                location: Location::new(Url::parse("file:///a.sql").unwrap(), range),
+                message: "declared here".to_string(),
+            }]),
+            ..Diagnostic::new_simple(range, "unknown table".to_string())
+        };
+
+        let supported = ClientFeatures {
+            related_information: true,
+            ..Default::default()
+        };
+        assert!(
+            supported
+                .adapt_diagnostic(diagnostic.clone())
+                .related_information
+                .is_some()
+        );
+        assert!(
+            ClientFeatures::default()
+                .adapt_diagnostic(diagnostic)
+                .related_information
+                .is_none()
+        );
+    }
+}
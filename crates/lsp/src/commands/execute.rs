@@ -0,0 +1,783 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Execute-statement command
+//!
+//! This module implements `sql.executeStatement`, which runs the statements
+//! at a range and returns their rows as a compact JSON table.
+//!
+//! ## Arguments
+//!
+//! ```json
+//! {
+//!   "uri": "file:///query.sql",
+//!   "range": { "start": {...}, "end": {...} },
+//!   "confirmWrite": false
+//! }
+//! ```
+//!
+//! ## Behavior
+//!
+//! - Statements that may modify data require `execution.allowWrites` in the
+//!   config and `confirmWrite` from the client (after asking the user).
+//! - Several statements run sequentially in one transaction that is rolled
+//!   back on the first error, unless `execution.autocommit` is set.
+//! - Each statement is bounded by the query timeout and at most
+//!   `execution.maxRows` rows are returned per statement.
+//! - Progress is reported per statement. A `$/cancelRequest` drops the
+//!   running command, which rolls back its transaction.
+//! - Temporary tables created by the statements are served by completion
+//!   until the connection is reset (see [`crate::session_objects`]).
+//!
+//! ## Result
+//!
+//! ```json
+//! {
+//!   "ok": true,
+//!   "transactional": false,
+//!   "results": [
+//!     { "statement": "SELECT id FROM users", "columns": ["id"], "rows": [["1"]] },
+//!     { "statement": "DELETE FROM t WHERE id = 1", "rowsAffected": 1 }
+//!   ]
+//! }
+//! ```
+
+use serde::{Deserialize, Serialize};
+use serde_json::{Value, json};
+use std::time::Duration;
+use tower_lsp::lsp_types::{Range, Url};
+use tracing::{debug, warn};
+use unified_sql_lsp_catalog::{QueryExecutor, QuerySession};
+
+use super::statement::{is_read_only, returns_rows, statements_in_range};
+use super::{CommandError, CommandProgress, parse_arguments};
+use crate::document::DocumentStore;
+use crate::request_context::RequestContext;
+
+/// Default maximum number of rows returned per statement
+pub const DEFAULT_MAX_ROWS: usize = 500;
+
+/// Execute-statement configuration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ExecutionConfig {
+    /// Allow statements that modify data or schema
+    pub allow_writes: bool,
+
+    /// Maximum number of rows returned per statement
+    pub max_rows: usize,
+
+    /// Commit every statement on its own instead of running several
+    /// statements in one transaction
+    pub autocommit: bool,
+}
+
+impl Default for ExecutionConfig {
+    fn default() -> Self {
+        Self {
+            allow_writes: false,
+            max_rows: DEFAULT_MAX_ROWS,
+            autocommit: false,
+        }
+    }
+}
+
+impl ExecutionConfig {
+    /// Parse execution configuration from the `execution` settings object
+    ///
+    /// A `maxRows` of zero is ignored.
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut config = Self::default();
+
+        if let Some(allow_writes) = settings.get("allowWrites").and_then(Value::as_bool) {
+            config.allow_writes = allow_writes;
+        }
+        if let Some(max_rows) = settings
+            .get("maxRows")
+            .and_then(Value::as_u64)
+            .filter(|rows| *rows > 0)
+        {
+            config.max_rows = max_rows as usize;
+        }
+        if let Some(autocommit) = settings.get("autocommit").and_then(Value::as_bool) {
+            config.autocommit = autocommit;
+        }
+
+        config
+    }
+}
+
+/// Arguments of the `sql.executeStatement` command
+#[derive(Debug, Clone, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExecuteArguments {
+    /// Document containing the statements
+    pub uri: Url,
+
+    /// Range of the statements (an empty range selects the statement at the cursor)
+    pub range: Range,
+
+    /// The user confirmed running statements that modify data
+    #[serde(default)]
+    pub confirm_write: bool,
+}
+
+/// Output of one executed statement
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct StatementOutput {
+    /// The executed statement
+    pub statement: String,
+
+    /// Column names (statements returning rows)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub columns: Option<Vec<String>>,
+
+    /// Rows of text cells, `null` for SQL NULL (statements returning rows)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub rows: Option<Vec<Vec<Option<String>>>>,
+
+    /// More rows were available than the row cap
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub truncated: bool,
+
+    /// Number of affected rows (statements not returning rows)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub rows_affected: Option<u64>,
+}
+
+/// Output of an executed selection
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+pub struct ExecutionOutput {
+    /// Outputs of the statements, in order
+    pub results: Vec<StatementOutput>,
+
+    /// Whether the statements ran in one transaction
+    pub transactional: bool,
+}
+
+impl ExecutionOutput {
+    /// The executed statements, in order
+    pub fn executed_statements(&self) -> Vec<String> {
+        self.results
+            .iter()
+            .map(|result| result.statement.clone())
+            .collect()
+    }
+
+    /// Render the output as a command result
+    pub fn to_result(&self) -> Value {
+        json!({
+            "ok": true,
+            "transactional": self.transactional,
+            "results": self.results,
+        })
+    }
+}
+
+/// Handle a `sql.executeStatement` command
+///
+/// # Arguments
+///
+/// * `arguments` - The command arguments
+/// * `documents` - The open documents
+/// * `context` - Request context resolving the config and executor
+/// * `progress` - Progress reporter of the request
+///
+/// # Returns
+///
+/// The statement outputs, or a structured error, as a command result
+pub async fn handle(
+    arguments: &[Value],
+    documents: &DocumentStore,
+    context: &RequestContext,
+    progress: &dyn CommandProgress,
+) -> Value {
+    let result = async {
+        let args: ExecuteArguments = parse_arguments(arguments)?;
+        let document = documents
+            .get_document(&args.uri)
+            .await
+            .ok_or_else(|| CommandError::DocumentNotFound(args.uri.clone()))?;
+
+        let config = context.config_or_fallback().await;
+        let executor = context
+            .executor_for_config(&config)
+            .await
+            .map_err(CommandError::from)?;
+
+        let statements = statements_in_range(&document.get_content(), args.range);
+
+        progress.begin("Executing SQL").await;
+        let result = execute_statements(
+            executor.as_ref(),
+            statements,
+            &args,
+            &config.execution,
+            Duration::from_secs(config.query_timeout_secs),
+            progress,
+        )
+        .await;
+        let summary = match &result {
+            Ok(output) => format!("Executed {} statement(s)", output.results.len()),
+            Err(e) => e.to_string(),
+        };
+        if let Ok(output) = &result {
+            context.record_session_objects(&config, &output.executed_statements());
+        }
+        progress.end(summary).await;
+
+        result
+    }
+    .await;
+
+    match result {
+        Ok(output) => output.to_result(),
+        Err(e) => {
+            debug!("sql.executeStatement failed: {}", e);
+            e.to_result()
+        }
+    }
+}
+
+/// Execute statements sequentially
+///
+/// Writes are checked against the config and the client's confirmation
+/// before anything runs. Several statements share one transaction unless
+/// autocommit is configured; the transaction is rolled back on the first
+/// error, and also when the returned future is dropped (request cancelled).
+///
+/// # Arguments
+///
+/// * `executor` - Executor opening the session
+/// * `statements` - The statements to execute
+/// * `args` - The command arguments
+/// * `config` - Execution configuration
+/// * `timeout` - Maximum time per statement
+/// * `progress` - Progress reporter
+///
+/// # Returns
+///
+/// The output of every statement
+pub async fn execute_statements(
+    executor: &dyn QueryExecutor,
+    statements: Vec<String>,
+    args: &ExecuteArguments,
+    config: &ExecutionConfig,
+    timeout: Duration,
+    progress: &dyn CommandProgress,
+) -> Result<ExecutionOutput, CommandError> {
+    if statements.is_empty() {
+        return Err(CommandError::NoStatement);
+    }
+
+    if !statements.iter().all(|s| is_read_only(s)) {
+        if !config.allow_writes {
+            return Err(CommandError::WritesDisabled);
+        }
+        if !args.confirm_write {
+            return Err(CommandError::WriteNotConfirmed);
+        }
+    }
+
+    let total = statements.len();
+    let mut session = executor.session(total > 1 && !config.autocommit).await?;
+    let transactional = session.is_transactional();
+    let mut results = Vec::with_capacity(total);
+
+    for (index, statement) in statements.into_iter().enumerate() {
+        progress
+            .report(
+                format!("Executing statement {} of {}", index + 1, total),
+                (index * 100 / total) as u32,
+            )
+            .await;
+
+        match execute_statement(session.as_mut(), statement, config.max_rows, timeout).await {
+            Ok(output) => results.push(output),
+            Err(e) => {
+                if let Err(rollback_error) = session.rollback().await {
+                    warn!("Failed to roll back after error: {}", rollback_error);
+                }
+                return Err(match e {
+                    CommandError::Database(message) if total > 1 => CommandError::Database(
+                        format!("Statement {} of {}: {}", index + 1, total, message),
+                    ),
+                    other => other,
+                });
+            }
+        }
+    }
+
+    session.commit().await?;
+
+    Ok(ExecutionOutput {
+        results,
+        transactional,
+    })
+}
+
+/// Execute one statement in a session
+async fn execute_statement(
+    session: &mut dyn QuerySession,
+    statement: String,
+    max_rows: usize,
+    timeout: Duration,
+) -> Result<StatementOutput, CommandError> {
+    let timed_out = |_| CommandError::Timeout(timeout.as_millis() as u64);
+
+    if returns_rows(&statement) {
+        let result = tokio::time::timeout(timeout, session.fetch(&statement, max_rows))
+            .await
+            .map_err(timed_out)??;
+
+        Ok(StatementOutput {
+            statement,
+            columns: Some(result.columns),
+            rows: Some(result.rows),
+            truncated: result.truncated,
+            rows_affected: None,
+        })
+    } else {
+        let rows_affected = tokio::time::timeout(timeout, session.execute(&statement))
+            .await
+            .map_err(timed_out)??;
+
+        Ok(StatementOutput {
+            statement,
+            columns: None,
+            rows: None,
+            truncated: false,
+            rows_affected: Some(rows_affected),
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::{Arc, Mutex};
+    use tower_lsp::lsp_types::Position;
+    use unified_sql_lsp_catalog::{CatalogError, CatalogResult, QueryResult};
+
+    type Log = Arc<Mutex<Vec<String>>>;
+
+    /// Executor whose sessions log what happens to them
+    ///
+    /// Statements containing `fail` fail, statements containing `hang` never
+    /// finish; queries return `available_rows` rows.
+    #[derive(Default)]
+    struct MockExecutor {
+        log: Log,
+        available_rows: usize,
+    }
+
+    struct MockSession {
+        log: Log,
+        available_rows: usize,
+        transactional: bool,
+        finished: bool,
+    }
+
+    impl MockSession {
+        fn run(&self, sql: &str) -> CatalogResult<()> {
+            self.log.lock().unwrap().push(sql.to_string());
+            if sql.contains("fail") {
+                return Err(CatalogError::QueryFailed("syntax error".to_string()));
+            }
+            Ok(())
+        }
+
+        async fn maybe_hang(sql: &str) {
+            if sql.contains("hang") {
+                std::future::pending::<()>().await;
+            }
+        }
+    }
+
+    impl Drop for MockSession {
+        fn drop(&mut self) {
+            if !self.finished && self.transactional {
+                self.log
+                    .lock()
+                    .unwrap()
+                    .push("ROLLBACK (dropped)".to_string());
+            }
+        }
+    }
+
+    #[async_trait::async_trait]
+    impl QueryExecutor for MockExecutor {
+        async fn execute(&self, _sql: &str) -> CatalogResult<QueryResult> {
+            unreachable!("statements run through sessions")
+        }
+
+        async fn session(&self, transactional: bool) -> CatalogResult<Box<dyn QuerySession>> {
+            if transactional {
+                self.log.lock().unwrap().push("BEGIN".to_string());
+            }
+            Ok(Box::new(MockSession {
+                log: self.log.clone(),
+                available_rows: self.available_rows,
+                transactional,
+                finished: false,
+            }))
+        }
+    }
+
+    #[async_trait::async_trait]
+    impl QuerySession for MockSession {
+        async fn fetch(&mut self, sql: &str, max_rows: usize) -> CatalogResult<QueryResult> {
+            self.run(sql)?;
+            Self::maybe_hang(sql).await;
+            let rows = (0..self.available_rows.min(max_rows))
+                .map(|i| vec![Some(i.to_string()), None])
+                .collect();
+            Ok(
+                QueryResult::new(vec!["id".to_string(), "note".to_string()], rows)
+                    .with_truncated(self.available_rows > max_rows),
+            )
+        }
+
+        async fn execute(&mut self, sql: &str) -> CatalogResult<u64> {
+            self.run(sql)?;
+            Self::maybe_hang(sql).await;
+            Ok(1)
+        }
+
+        fn is_transactional(&self) -> bool {
+            self.transactional
+        }
+
+        async fn commit(self: Box<Self>) -> CatalogResult<()> {
+            let mut session = self;
+            session.finished = true;
+            if session.transactional {
+                session.log.lock().unwrap().push("COMMIT".to_string());
+            }
+            Ok(())
+        }
+
+        async fn rollback(self: Box<Self>) -> CatalogResult<()> {
+            let mut session = self;
+            session.finished = true;
+            if session.transactional {
+                session.log.lock().unwrap().push("ROLLBACK".to_string());
+            }
+            Ok(())
+        }
+    }
+
+    /// Progress reporter recording reported messages
+    #[derive(Default)]
+    struct RecordedProgress(Mutex<Vec<String>>);
+
+    #[async_trait::async_trait]
+    impl CommandProgress for RecordedProgress {
+        async fn begin(&self, title: &str) {
+            self.0.lock().unwrap().push(format!("begin: {}", title));
+        }
+
+        async fn report(&self, message: String, percentage: u32) {
+            self.0
+                .lock()
+                .unwrap()
+                .push(format!("{} ({}%)", message, percentage));
+        }
+
+        async fn end(&self, message: String) {
+            self.0.lock().unwrap().push(format!("end: {}", message));
+        }
+    }
+
+    const TIMEOUT: Duration = Duration::from_secs(5);
+
+    fn args(confirm_write: bool) -> ExecuteArguments {
+        ExecuteArguments {
+            uri: Url::parse("file:///query.sql").unwrap(),
+            range: Range::new(Position::new(0, 0), Position::new(0, 0)),
+            confirm_write,
+        }
+    }
+
+    fn writes_allowed() -> ExecutionConfig {
+        ExecutionConfig {
+            allow_writes: true,
+            ..Default::default()
+        }
+    }
+
+    fn statements(sql: &[&str]) -> Vec<String> {
+        sql.iter().map(|s| s.to_string()).collect()
+    }
+
+    fn log_of(executor: &MockExecutor) -> Vec<String> {
+        executor.log.lock().unwrap().clone()
+    }
+
+    #[tokio::test]
+    async fn test_single_select_with_row_cap() {
+        let executor = MockExecutor {
+            available_rows: 3,
+            ..Default::default()
+        };
+        let config = ExecutionConfig {
+            max_rows: 2,
+            ..Default::default()
+        };
+
+        let output = execute_statements(
+            &executor,
+            statements(&["SELECT id, note FROM users"]),
+            &args(false),
+            &config,
+            TIMEOUT,
+            &RecordedProgress::default(),
+        )
+        .await
+        .unwrap();
+
+        assert!(!output.transactional);
+        let result = &output.results[0];
+        assert_eq!(result.rows.as_ref().unwrap().len(), 2);
+        assert!(result.truncated);
+
+        let json = output.to_result();
+        assert_eq!(json["results"][0]["columns"][0], "id");
+        assert_eq!(json["results"][0]["rows"][1][0], "1");
+        assert!(json["results"][0]["rows"][1][1].is_null());
+        assert_eq!(json["results"][0]["truncated"], true);
+    }
+
+    #[tokio::test]
+    async fn test_writes_require_config_and_confirmation() {
+        let executor = MockExecutor::default();
+        let progress = RecordedProgress::default();
+        let update = statements(&["UPDATE users SET name = 'a' WHERE id = 1"]);
+
+        let result = execute_statements(
+            &executor,
+            update.clone(),
+            &args(true),
+            &ExecutionConfig::default(),
+            TIMEOUT,
+            &progress,
+        )
+        .await;
+        assert!(matches!(result, Err(CommandError::WritesDisabled)));
+
+        let result = execute_statements(
+            &executor,
+            update.clone(),
+            &args(false),
+            &writes_allowed(),
+            TIMEOUT,
+            &progress,
+        )
+        .await;
+        assert!(matches!(result, Err(CommandError::WriteNotConfirmed)));
+        assert!(log_of(&executor).is_empty());
+
+        let output = execute_statements(
+            &executor,
+            update,
+            &args(true),
+            &writes_allowed(),
+            TIMEOUT,
+            &progress,
+        )
+        .await
+        .unwrap();
+        assert_eq!(output.results[0].rows_affected, Some(1));
+    }
+
+    #[tokio::test]
+    async fn test_multiple_statements_commit_in_transaction() {
+        let executor = MockExecutor::default();
+        let progress = RecordedProgress::default();
+
+        let output = execute_statements(
+            &executor,
+            statements(&["INSERT INTO t VALUES (1)", "SELECT * FROM t"]),
+            &args(true),
+            &writes_allowed(),
+            TIMEOUT,
+            &progress,
+        )
+        .await
+        .unwrap();
+
+        assert!(output.transactional);
+        assert_eq!(
+            log_of(&executor),
+            vec![
+                "BEGIN",
+                "INSERT INTO t VALUES (1)",
+                "SELECT * FROM t",
+                "COMMIT"
+            ]
+        );
+        assert_eq!(
+            *progress.0.lock().unwrap(),
+            vec![
+                "Executing statement 1 of 2 (0%)",
+                "Executing statement 2 of 2 (50%)"
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_error_rolls_back_transaction() {
+        let executor = MockExecutor::default();
+
+        let result = execute_statements(
+            &executor,
+            statements(&[
+                "INSERT INTO t VALUES (1)",
+                "INSERT INTO t VALUES (fail)",
+                "INSERT INTO t VALUES (3)",
+            ]),
+            &args(true),
+            &writes_allowed(),
+            TIMEOUT,
+            &RecordedProgress::default(),
+        )
+        .await;
+
+        match result {
+            Err(CommandError::Database(message)) => {
+                assert_eq!(message, "Statement 2 of 3: syntax error")
+            }
+            other => panic!("unexpected result {:?}", other),
+        }
+        assert_eq!(
+            log_of(&executor),
+            vec![
+                "BEGIN",
+                "INSERT INTO t VALUES (1)",
+                "INSERT INTO t VALUES (fail)",
+                "ROLLBACK"
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_autocommit_runs_without_transaction() {
+        let executor = MockExecutor::default();
+        let config = ExecutionConfig {
+            autocommit: true,
+            ..writes_allowed()
+        };
+
+        let result = execute_statements(
+            &executor,
+            statements(&["INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (fail)"]),
+            &args(true),
+            &config,
+            TIMEOUT,
+            &RecordedProgress::default(),
+        )
+        .await;
+
+        assert!(result.is_err());
+        assert_eq!(
+            log_of(&executor),
+            vec!["INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (fail)"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_timeout_rolls_back() {
+        let executor = MockExecutor::default();
+
+        let result = execute_statements(
+            &executor,
+            statements(&["INSERT INTO t VALUES (1)", "SELECT hang FROM t"]),
+            &args(true),
+            &writes_allowed(),
+            Duration::from_millis(20),
+            &RecordedProgress::default(),
+        )
+        .await;
+
+        assert!(matches!(result, Err(CommandError::Timeout(20))));
+        assert_eq!(
+            log_of(&executor),
+            vec![
+                "BEGIN",
+                "INSERT INTO t VALUES (1)",
+                "SELECT hang FROM t",
+                "ROLLBACK"
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_cancellation_mid_stream_rolls_back() {
+        let executor = MockExecutor {
+            available_rows: 10,
+            ..Default::default()
+        };
+        let args = args(true);
+        let config = writes_allowed();
+        let progress = RecordedProgress::default();
+
+        // Cancelling a request drops its future while a statement is running
+        let execution = execute_statements(
+            &executor,
+            statements(&[
+                "DELETE FROM t WHERE id = 1",
+                "SELECT hang FROM t",
+                "DELETE FROM t WHERE id = 2",
+            ]),
+            &args,
+            &config,
+            TIMEOUT,
+            &progress,
+        );
+        let cancelled = tokio::time::timeout(Duration::from_millis(20), execution).await;
+
+        assert!(cancelled.is_err());
+        assert_eq!(
+            log_of(&executor),
+            vec![
+                "BEGIN",
+                "DELETE FROM t WHERE id = 1",
+                "SELECT hang FROM t",
+                "ROLLBACK (dropped)"
+            ]
+        );
+        assert_eq!(progress.0.lock().unwrap().len(), 2);
+    }
+
+    #[tokio::test]
+    async fn test_no_statement() {
+        let result = execute_statements(
+            &MockExecutor::default(),
+            Vec::new(),
+            &args(false),
+            &ExecutionConfig::default(),
+            TIMEOUT,
+            &RecordedProgress::default(),
+        )
+        .await;
+
+        assert!(matches!(result, Err(CommandError::NoStatement)));
+    }
+
+    #[test]
+    fn test_execution_config_from_settings() {
+        let config = ExecutionConfig::from_settings(&json!({
+            "allowWrites": true,
+            "maxRows": 50,
+            "autocommit": true
+        }));
+        assert!(config.allow_writes);
+        assert_eq!(config.max_rows, 50);
+        assert!(config.autocommit);
+
+        let config = ExecutionConfig::from_settings(&json!({ "maxRows": 0 }));
+        assert_eq!(config, ExecutionConfig::default());
+    }
+}
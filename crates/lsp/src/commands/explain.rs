@@ -0,0 +1,574 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Query plan command
+//!
+//! This module implements `sql.explain`, which runs `EXPLAIN` for the
+//! statement at a range and returns the plan as text the client can open as
+//! a virtual document.
+//!
+//! ## Arguments
+//!
+//! ```json
+//! {
+//!   "uri": "file:///query.sql",
+//!   "range": { "start": {...}, "end": {...} },
+//!   "format": "text" | "json",
+//!   "analyze": false,
+//!   "confirmAnalyze": false
+//! }
+//! ```
+//!
+//! ## Safety
+//!
+//! Plain `EXPLAIN` never executes the statement. `EXPLAIN ANALYZE` does, so
+//! it is only run when the client also sets `confirmAnalyze` (after asking
+//! the user). The range must contain exactly one statement, and the executor
+//! prepares the query, so trailing statements can never ride along.
+
+use serde::{Deserialize, Serialize};
+use serde_json::{Value, json};
+use std::time::Duration;
+use tower_lsp::lsp_types::{Range, Url};
+use tracing::debug;
+use unified_sql_lsp_catalog::{QueryExecutor, QueryResult};
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_ir::dialect::DialectFamily;
+
+use super::statement::statements_in_range;
+use super::{CommandError, parse_arguments};
+use crate::document::DocumentStore;
+use crate::request_context::RequestContext;
+
+/// Output format of a query plan
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum PlanFormat {
+    /// The database's human-readable plan
+    #[default]
+    Text,
+
+    /// A JSON plan document
+    Json,
+}
+
+/// Arguments of the `sql.explain` command
+#[derive(Debug, Clone, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExplainArguments {
+    /// Document containing the statement
+    pub uri: Url,
+
+    /// Range of the statement (an empty range selects the statement at the cursor)
+    pub range: Range,
+
+    /// Requested plan format
+    #[serde(default)]
+    pub format: PlanFormat,
+
+    /// Run EXPLAIN ANALYZE instead of EXPLAIN
+    #[serde(default)]
+    pub analyze: bool,
+
+    /// The user confirmed that EXPLAIN ANALYZE may execute the statement
+    #[serde(default)]
+    pub confirm_analyze: bool,
+}
+
+/// A query plan returned to the client
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct QueryPlan {
+    /// The explained statement
+    pub statement: String,
+
+    /// The EXPLAIN statement sent to the database
+    pub sql: String,
+
+    /// Format of `plan`
+    pub format: PlanFormat,
+
+    /// Whether the statement was executed (EXPLAIN ANALYZE)
+    pub analyze: bool,
+
+    /// The plan text
+    pub plan: String,
+}
+
+impl QueryPlan {
+    /// Render the plan as a command result
+    ///
+    /// `languageId` tells the client how to highlight the virtual document.
+    pub fn to_result(&self) -> Value {
+        let language_id = match self.format {
+            PlanFormat::Text => "plaintext",
+            PlanFormat::Json => "json",
+        };
+
+        json!({
+            "ok": true,
+            "statement": self.statement,
+            "sql": self.sql,
+            "format": self.format,
+            "analyze": self.analyze,
+            "languageId": language_id,
+            "plan": self.plan,
+        })
+    }
+}
+
+/// Handle a `sql.explain` command
+///
+/// # Arguments
+///
+/// * `arguments` - The command arguments
+/// * `documents` - The open documents
+/// * `context` - Request context resolving the config and executor
+///
+/// # Returns
+///
+/// The plan, or a structured error, as a command result
+pub async fn handle(
+    arguments: &[Value],
+    documents: &DocumentStore,
+    context: &RequestContext,
+) -> Value {
+    let result = async {
+        let args: ExplainArguments = parse_arguments(arguments)?;
+        let document = documents
+            .get_document(&args.uri)
+            .await
+            .ok_or_else(|| CommandError::DocumentNotFound(args.uri.clone()))?;
+
+        let config = context.config_or_fallback().await;
+        let dialect = config.catalog_dialect().ok_or_else(|| {
+            CommandError::NotSupported(format!(
+                "EXPLAIN is not supported for dialect {:?} in strict mode",
+                config.dialect
+            ))
+        })?;
+        let executor = context
+            .executor_for_config(&config)
+            .await
+            .map_err(CommandError::from)?;
+
+        explain(
+            executor.as_ref(),
+            dialect,
+            &document.get_content(),
+            &args,
+            Duration::from_secs(config.query_timeout_secs),
+        )
+        .await
+    }
+    .await;
+
+    match result {
+        Ok(plan) => plan.to_result(),
+        Err(e) => {
+            debug!("sql.explain failed: {}", e);
+            e.to_result()
+        }
+    }
+}
+
+/// Explain the statement selected by the arguments
+///
+/// # Arguments
+///
+/// * `executor` - Executor running the EXPLAIN statement
+/// * `dialect` - Dialect of the database
+/// * `source` - Text of the document
+/// * `args` - The command arguments
+/// * `timeout` - Maximum time to wait for the plan
+///
+/// # Returns
+///
+/// The query plan
+pub async fn explain(
+    executor: &dyn QueryExecutor,
+    dialect: Dialect,
+    source: &str,
+    args: &ExplainArguments,
+    timeout: Duration,
+) -> Result<QueryPlan, CommandError> {
+    if args.analyze && !args.confirm_analyze {
+        return Err(CommandError::AnalyzeNotConfirmed);
+    }
+
+    let mut statements = statements_in_range(source, args.range);
+    let statement = match statements.len() {
+        0 => return Err(CommandError::NoStatement),
+        1 => statements.remove(0),
+        n => return Err(CommandError::MultipleStatements(n)),
+    };
+
+    let sql = explain_sql(dialect, &statement, args.format, args.analyze)?;
+    debug!("Running {}", sql);
+
+    let result = tokio::time::timeout(timeout, executor.execute(&sql))
+        .await
+        .map_err(|_| CommandError::Timeout(timeout.as_millis() as u64))??;
+
+    Ok(QueryPlan {
+        statement,
+        sql,
+        format: args.format,
+        analyze: args.analyze,
+        plan: plan_text(&result),
+    })
+}
+
+/// Build the EXPLAIN statement for a dialect
+///
+/// # Arguments
+///
+/// * `dialect` - Dialect of the database
+/// * `statement` - The statement to explain
+/// * `format` - Requested plan format
+/// * `analyze` - Whether to run EXPLAIN ANALYZE
+///
+/// # Returns
+///
+/// The EXPLAIN statement, or an error if the dialect cannot produce the
+/// requested plan
+pub fn explain_sql(
+    dialect: Dialect,
+    statement: &str,
+    format: PlanFormat,
+    analyze: bool,
+) -> Result<String, CommandError> {
+    let prefix = match (dialect.family(), format, analyze) {
+        (DialectFamily::PostgreSQL, PlanFormat::Text, false) => "EXPLAIN",
+        (DialectFamily::PostgreSQL, PlanFormat::Text, true) => "EXPLAIN (ANALYZE)",
+        (DialectFamily::PostgreSQL, PlanFormat::Json, false) => "EXPLAIN (FORMAT JSON)",
+        (DialectFamily::PostgreSQL, PlanFormat::Json, true) => "EXPLAIN (ANALYZE, FORMAT JSON)",
+        (DialectFamily::MySQL, PlanFormat::Text, false) => "EXPLAIN",
+        (DialectFamily::MySQL, PlanFormat::Text, true) => "EXPLAIN ANALYZE",
+        (DialectFamily::MySQL, PlanFormat::Json, false) => "EXPLAIN FORMAT=JSON",
+        (DialectFamily::MySQL, PlanFormat::Json, true) => {
+            return Err(CommandError::NotSupported(format!(
+                "{:?} does not support EXPLAIN ANALYZE with JSON output",
+                dialect
+            )));
+        }
+    };
+
+    Ok(format!("{} {}", prefix, statement))
+}
+
+/// Render an EXPLAIN result as plan text
+///
+/// Single-column results (PostgreSQL plans, MySQL JSON and tree plans) are
+/// joined line by line; tabular results (MySQL's traditional EXPLAIN) are
+/// rendered as an aligned table.
+pub fn plan_text(result: &QueryResult) -> String {
+    let cell = |value: &Option<String>| value.clone().unwrap_or_else(|| "NULL".to_string());
+
+    if result.columns.len() <= 1 {
+        return result
+            .rows
+            .iter()
+            .filter_map(|row| row.first().map(cell))
+            .collect::<Vec<_>>()
+            .join("\n");
+    }
+
+    let rows: Vec<Vec<String>> = result
+        .rows
+        .iter()
+        .map(|row| row.iter().map(cell).collect())
+        .collect();
+    let widths: Vec<usize> = result
+        .columns
+        .iter()
+        .enumerate()
+        .map(|(i, column)| {
+            rows.iter()
+                .filter_map(|row| row.get(i))
+                .map(|value| value.chars().count())
+                .chain(std::iter::once(column.chars().count()))
+                .max()
+                .unwrap_or(0)
+        })
+        .collect();
+
+    let format_row = |values: &[String]| {
+        values
+            .iter()
+            .zip(&widths)
+            .map(|(value, width)| format!("{:<width$}", value, width = width))
+            .collect::<Vec<_>>()
+            .join(" | ")
+            .trim_end()
+            .to_string()
+    };
+
+    let mut lines = vec![format_row(&result.columns)];
+    lines.push(
+        widths
+            .iter()
+            .map(|width| "-".repeat(*width))
+            .collect::<Vec<_>>()
+            .join("-+-"),
+    );
+    lines.extend(rows.iter().map(|row| format_row(row)));
+    lines.join("\n")
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::Mutex;
+    use tower_lsp::lsp_types::Position;
+    use unified_sql_lsp_catalog::CatalogError;
+
+    /// Executor recording the statements it receives
+    #[derive(Default)]
+    struct MockExecutor {
+        executed: Mutex<Vec<String>>,
+        result: QueryResult,
+        error: Option<CatalogError>,
+        delay: Option<Duration>,
+    }
+
+    #[async_trait::async_trait]
+    impl QueryExecutor for MockExecutor {
+        async fn execute(&self, sql: &str) -> Result<QueryResult, CatalogError> {
+            self.executed.lock().unwrap().push(sql.to_string());
+            if let Some(delay) = self.delay {
+                tokio::time::sleep(delay).await;
+            }
+            match &self.error {
+                Some(error) => Err(error.clone()),
+                None => Ok(self.result.clone()),
+            }
+        }
+    }
+
+    fn args(range: Range) -> ExplainArguments {
+        ExplainArguments {
+            uri: Url::parse("file:///query.sql").unwrap(),
+            range,
+            format: PlanFormat::Text,
+            analyze: false,
+            confirm_analyze: false,
+        }
+    }
+
+    fn cursor(line: u32, character: u32) -> Range {
+        Range::new(
+            Position::new(line, character),
+            Position::new(line, character),
+        )
+    }
+
+    fn single_column(lines: &[&str]) -> QueryResult {
+        QueryResult::new(
+            vec!["QUERY PLAN".to_string()],
+            lines.iter().map(|l| vec![Some(l.to_string())]).collect(),
+        )
+    }
+
+    const TIMEOUT: Duration = Duration::from_secs(5);
+
+    #[tokio::test]
+    async fn test_explain_statement_at_cursor() {
+        let executor = MockExecutor {
+            result: single_column(&["Seq Scan on users", "  Filter: (id = 1)"]),
+            ..Default::default()
+        };
+        let source = "SELECT 1;\nSELECT * FROM users WHERE id = 1;\n";
+
+        let plan = explain(
+            &executor,
+            Dialect::PostgreSQL,
+            source,
+            &args(cursor(1, 5)),
+            TIMEOUT,
+        )
+        .await
+        .unwrap();
+
+        assert_eq!(plan.statement, "SELECT * FROM users WHERE id = 1");
+        assert_eq!(plan.plan, "Seq Scan on users\n  Filter: (id = 1)");
+        assert_eq!(
+            *executor.executed.lock().unwrap(),
+            vec!["EXPLAIN SELECT * FROM users WHERE id = 1"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_explain_rejects_multiple_statements() {
+        let executor = MockExecutor::default();
+        let source = "SELECT * FROM users; DELETE FROM users";
+        let range = Range::new(Position::new(0, 0), Position::new(0, 38));
+
+        let result = explain(&executor, Dialect::MySQL, source, &args(range), TIMEOUT).await;
+
+        assert!(matches!(result, Err(CommandError::MultipleStatements(2))));
+        assert!(executor.executed.lock().unwrap().is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_explain_without_statement() {
+        let executor = MockExecutor::default();
+        let result = explain(
+            &executor,
+            Dialect::MySQL,
+            "-- nothing\n",
+            &args(cursor(0, 3)),
+            TIMEOUT,
+        )
+        .await;
+
+        assert!(matches!(result, Err(CommandError::NoStatement)));
+        assert!(executor.executed.lock().unwrap().is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_analyze_requires_confirmation() {
+        let executor = MockExecutor::default();
+        let source = "DELETE FROM users WHERE id = 1";
+        let mut analyze = args(cursor(0, 0));
+        analyze.analyze = true;
+
+        let result = explain(&executor, Dialect::PostgreSQL, source, &analyze, TIMEOUT).await;
+        assert!(matches!(result, Err(CommandError::AnalyzeNotConfirmed)));
+        assert!(executor.executed.lock().unwrap().is_empty());
+
+        analyze.confirm_analyze = true;
+        let plan = explain(&executor, Dialect::PostgreSQL, source, &analyze, TIMEOUT)
+            .await
+            .unwrap();
+        assert!(plan.analyze);
+        assert_eq!(
+            *executor.executed.lock().unwrap(),
+            vec!["EXPLAIN (ANALYZE) DELETE FROM users WHERE id = 1"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_plain_explain_never_analyzes() {
+        let executor = MockExecutor::default();
+        let mut plain = args(cursor(0, 0));
+        plain.confirm_analyze = true;
+
+        explain(
+            &executor,
+            Dialect::MySQL,
+            "DELETE FROM users",
+            &plain,
+            TIMEOUT,
+        )
+        .await
+        .unwrap();
+
+        let executed = executor.executed.lock().unwrap();
+        assert_eq!(*executed, vec!["EXPLAIN DELETE FROM users"]);
+    }
+
+    #[tokio::test]
+    async fn test_database_error_is_structured() {
+        let executor = MockExecutor {
+            error: Some(CatalogError::QueryFailed(
+                "relation \"nope\" does not exist".to_string(),
+            )),
+            ..Default::default()
+        };
+
+        let error = explain(
+            &executor,
+            Dialect::PostgreSQL,
+            "SELECT * FROM nope",
+            &args(cursor(0, 0)),
+            TIMEOUT,
+        )
+        .await
+        .unwrap_err();
+
+        let result = error.to_result();
+        assert_eq!(result["ok"], false);
+        assert_eq!(result["error"]["code"], "database-error");
+        assert_eq!(
+            result["error"]["message"],
+            "relation \"nope\" does not exist"
+        );
+    }
+
+    #[tokio::test]
+    async fn test_explain_timeout() {
+        let executor = MockExecutor {
+            delay: Some(Duration::from_secs(10)),
+            ..Default::default()
+        };
+
+        let result = explain(
+            &executor,
+            Dialect::MySQL,
+            "SELECT SLEEP(10)",
+            &args(cursor(0, 0)),
+            Duration::from_millis(20),
+        )
+        .await;
+
+        assert!(matches!(result, Err(CommandError::Timeout(20))));
+    }
+
+    #[test]
+    fn test_explain_sql_per_dialect() {
+        let sql = |dialect, format, analyze| explain_sql(dialect, "SELECT 1", format, analyze);
+
+        assert_eq!(
+            sql(Dialect::PostgreSQL, PlanFormat::Json, false).unwrap(),
+            "EXPLAIN (FORMAT JSON) SELECT 1"
+        );
+        assert_eq!(
+            sql(Dialect::CockroachDB, PlanFormat::Json, true).unwrap(),
+            "EXPLAIN (ANALYZE, FORMAT JSON) SELECT 1"
+        );
+        assert_eq!(
+            sql(Dialect::MySQL, PlanFormat::Json, false).unwrap(),
+            "EXPLAIN FORMAT=JSON SELECT 1"
+        );
+        assert_eq!(
+            sql(Dialect::TiDB, PlanFormat::Text, true).unwrap(),
+            "EXPLAIN ANALYZE SELECT 1"
+        );
+        assert!(matches!(
+            sql(Dialect::MySQL, PlanFormat::Json, true),
+            Err(CommandError::NotSupported(_))
+        ));
+    }
+
+    #[test]
+    fn test_plan_text_table() {
+        let result = QueryResult::new(
+            vec!["id".to_string(), "table".to_string(), "key".to_string()],
+            vec![vec![Some("1".to_string()), Some("users".to_string()), None]],
+        );
+
+        assert_eq!(
+            plan_text(&result),
+            "id | table | key\n---+-------+-----\n1  | users | NULL"
+        );
+    }
+
+    #[test]
+    fn test_plan_result_language() {
+        let plan = QueryPlan {
+            statement: "SELECT 1".to_string(),
+            sql: "EXPLAIN FORMAT=JSON SELECT 1".to_string(),
+            format: PlanFormat::Json,
+            analyze: false,
+            plan: "{}".to_string(),
+        };
+
+        let result = plan.to_result();
+        assert_eq!(result["ok"], true);
+        assert_eq!(result["format"], "json");
+        assert_eq!(result["languageId"], "json");
+    }
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Workspace commands
+//!
+//! This module implements the `workspace/executeCommand` commands offered by
+//! the server.
+//!
+//! ## Commands
+//!
+//! - `sql.explain`: show the query plan of the statement at a range
+//! - `sql.executeStatement`: run the statements at a range and return their rows
+//! - `sql.connectionStatus`: report the health of the database connections
+//! - `sql.refreshSchema`: reload cached schemas and schema snapshot files
+//! - `sql.resetStats`: reset the request statistics served by `sql/stats`
+//!
+//! ## Results
+//!
+//! Commands never fail with a JSON-RPC error once their arguments are
+//! understood. Failures, including database errors, are returned as a
+//! structured result so the client can show them next to the statement:
+//!
+//! ```json
+//! { "ok": false, "error": { "code": "database-error", "message": "..." } }
+//! ```
+
+pub mod execute;
+pub mod explain;
+pub mod statement;
+
+use serde::de::DeserializeOwned;
+use serde_json::{Value, json};
+use thiserror::Error;
+use tower_lsp::lsp_types::{
+    ProgressParams, ProgressParamsValue, ProgressToken, Url, WorkDoneProgress,
+    WorkDoneProgressBegin, WorkDoneProgressEnd, WorkDoneProgressReport,
+};
+use unified_sql_lsp_catalog::CatalogError;
+
+use crate::connection_health::ConnectionHealthMonitor;
+use crate::outbound::OutboundSender;
+use crate::request_context::RequestContext;
+
+/// Command name for showing a query plan
+pub const EXPLAIN_COMMAND: &str = "sql.explain";
+
+/// Command name for executing statements
+pub const EXECUTE_STATEMENT_COMMAND: &str = "sql.executeStatement";
+
+/// Command name for reporting connection health
+pub const CONNECTION_STATUS_COMMAND: &str = "sql.connectionStatus";
+
+/// Command name for reloading cached schemas
+pub const REFRESH_SCHEMA_COMMAND: &str = "sql.refreshSchema";
+
+/// Command name for resetting the request statistics
+pub const RESET_STATS_COMMAND: &str = "sql.resetStats";
+
+/// Names of all commands supported by the server
+pub fn command_names() -> Vec<String> {
+    vec![
+        EXPLAIN_COMMAND.to_string(),
+        EXECUTE_STATEMENT_COMMAND.to_string(),
+        CONNECTION_STATUS_COMMAND.to_string(),
+        REFRESH_SCHEMA_COMMAND.to_string(),
+        RESET_STATS_COMMAND.to_string(),
+    ]
+}
+
+/// Handle a `sql.connectionStatus` command
+///
+/// # Returns
+///
+/// The status of every connection checked so far
+pub fn connection_status(health: &ConnectionHealthMonitor) -> Value {
+    json!({
+        "ok": true,
+        "connections": health.statuses(),
+    })
+}
+
+/// Handle a `sql.refreshSchema` command
+///
+/// Cached live schemas and schema snapshot files are dropped and loaded
+/// again on next use.
+pub async fn refresh_schema(context: &RequestContext) -> Value {
+    json!({
+        "ok": true,
+        "reloadedSchemas": context.invalidate_schemas(),
+        "reloadedSnapshots": context.reload_schema_snapshots().await,
+    })
+}
+
+/// Command errors
+///
+/// These are reported to the client as structured command results.
+#[derive(Debug, Error)]
+pub enum CommandError {
+    /// Arguments are missing or malformed
+    #[error("Invalid command arguments: {0}")]
+    InvalidArguments(String),
+
+    /// The target document is not open
+    #[error("Document not found: {0}")]
+    DocumentNotFound(Url),
+
+    /// The range contains no statement
+    #[error("No statement found in the given range")]
+    NoStatement,
+
+    /// The range contains several statements where one is expected
+    #[error("Expected a single statement, found {0}")]
+    MultipleStatements(usize),
+
+    /// EXPLAIN ANALYZE was requested without confirmation
+    #[error("EXPLAIN ANALYZE executes the statement and must be confirmed")]
+    AnalyzeNotConfirmed,
+
+    /// A writing statement was requested but writes are disabled in the config
+    #[error("Executing statements that modify data is disabled (execution.allowWrites)")]
+    WritesDisabled,
+
+    /// A writing statement was requested without confirmation
+    #[error("The statements modify data and must be confirmed")]
+    WriteNotConfirmed,
+
+    /// The command is not supported for the configured dialect
+    #[error("{0}")]
+    NotSupported(String),
+
+    /// No database connection is available
+    #[error("{0}")]
+    Connection(String),
+
+    /// The database rejected the statement
+    #[error("{0}")]
+    Database(String),
+
+    /// The statement did not finish within the timeout
+    #[error("Query timed out after {0} ms")]
+    Timeout(u64),
+}
+
+impl CommandError {
+    /// Stable error code reported to the client
+    pub fn code(&self) -> &'static str {
+        match self {
+            CommandError::InvalidArguments(_) => "invalid-arguments",
+            CommandError::DocumentNotFound(_) => "document-not-found",
+            CommandError::NoStatement => "no-statement",
+            CommandError::MultipleStatements(_) => "multiple-statements",
+            CommandError::AnalyzeNotConfirmed => "analyze-not-confirmed",
+            CommandError::WritesDisabled => "writes-disabled",
+            CommandError::WriteNotConfirmed => "write-not-confirmed",
+            CommandError::NotSupported(_) => "not-supported",
+            CommandError::Connection(_) => "connection-error",
+            CommandError::Database(_) => "database-error",
+            CommandError::Timeout(_) => "timeout",
+        }
+    }
+
+    /// Render the error as a command result
+    pub fn to_result(&self) -> Value {
+        json!({
+            "ok": false,
+            "error": {
+                "code": self.code(),
+                "message": self.to_string(),
+            }
+        })
+    }
+}
+
+impl From<CatalogError> for CommandError {
+    fn from(error: CatalogError) -> Self {
+        match error {
+            CatalogError::QueryFailed(message) => CommandError::Database(message),
+            CatalogError::QueryTimeout(secs) => CommandError::Timeout(secs * 1000),
+            CatalogError::NotSupported(message) => CommandError::NotSupported(message),
+            other => CommandError::Connection(other.to_string()),
+        }
+    }
+}
+
+/// Parse the first command argument
+///
+/// # Arguments
+///
+/// * `arguments` - The arguments of the `workspace/executeCommand` request
+///
+/// # Returns
+///
+/// The deserialized argument object
+pub fn parse_arguments<T: DeserializeOwned>(arguments: &[Value]) -> Result<T, CommandError> {
+    let argument = arguments
+        .first()
+        .ok_or_else(|| CommandError::InvalidArguments("missing argument object".to_string()))?;
+
+    serde_json::from_value(argument.clone())
+        .map_err(|e| CommandError::InvalidArguments(e.to_string()))
+}
+
+/// Progress of a long-running command or background task
+#[async_trait::async_trait]
+pub trait CommandProgress: Send + Sync {
+    /// Start reporting progress
+    async fn begin(&self, title: &str);
+
+    /// Report an intermediate step
+    async fn report(&self, message: String, percentage: u32);
+
+    /// Finish reporting progress
+    async fn end(&self, message: String);
+}
+
+/// Work-done progress reported to the client through `$/progress`
+///
+/// Progress is only reported when the client supplied a work-done token with
+/// the request. Reports are queued, and dropped while the client lags behind
+/// (see [`crate::outbound`]).
+pub struct ClientProgress {
+    outbound: OutboundSender,
+    token: Option<ProgressToken>,
+}
+
+impl ClientProgress {
+    /// Create a progress reporter for a request's work-done token
+    pub fn new(outbound: OutboundSender, token: Option<ProgressToken>) -> Self {
+        Self { outbound, token }
+    }
+
+    async fn notify(&self, value: WorkDoneProgress) {
+        if let Some(token) = &self.token {
+            self.outbound.progress(ProgressParams {
+                token: token.clone(),
+                value: ProgressParamsValue::WorkDone(value),
+            });
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl CommandProgress for ClientProgress {
+    async fn begin(&self, title: &str) {
+        self.notify(WorkDoneProgress::Begin(WorkDoneProgressBegin {
+            title: title.to_string(),
+            cancellable: Some(false),
+            message: None,
+            percentage: Some(0),
+        }))
+        .await;
+    }
+
+    async fn report(&self, message: String, percentage: u32) {
+        self.notify(WorkDoneProgress::Report(WorkDoneProgressReport {
+            cancellable: Some(false),
+            message: Some(message),
+            percentage: Some(percentage),
+        }))
+        .await;
+    }
+
+    async fn end(&self, message: String) {
+        self.notify(WorkDoneProgress::End(WorkDoneProgressEnd {
+            message: Some(message),
+        }))
+        .await;
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde::Deserialize;
+
+    #[derive(Debug, Deserialize)]
+    struct Args {
+        uri: Url,
+    }
+
+    #[test]
+    fn test_parse_arguments() {
+        let args: Args = parse_arguments(&[json!({ "uri": "file:///a.sql" })]).unwrap();
+        assert_eq!(args.uri.as_str(), "file:///a.sql");
+
+        assert!(matches!(
+            parse_arguments::<Args>(&[]),
+            Err(CommandError::InvalidArguments(_))
+        ));
+        assert!(matches!(
+            parse_arguments::<Args>(&[json!({ "uri": 1 })]),
+            Err(CommandError::InvalidArguments(_))
+        ));
+    }
+
+    #[test]
+    fn test_connection_status_result() {
+        let health = ConnectionHealthMonitor::new();
+        health.record("mysql://u:p@localhost/db", Err("refused".to_string()));
+
+        let result = connection_status(&health);
+        assert_eq!(result["ok"], true);
+        assert_eq!(
+            result["connections"][0]["connection"],
+            "mysql://u@localhost/db"
+        );
+        assert_eq!(result["connections"][0]["state"], "degraded");
+    }
+
+    #[test]
+    fn test_error_result() {
+        let result = CommandError::Timeout(500).to_result();
+        assert_eq!(result["ok"], false);
+        assert_eq!(result["error"]["code"], "timeout");
+        assert_eq!(result["error"]["message"], "Query timed out after 500 ms");
+    }
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Completion aggregation
+//!
+//! This module merges the completion items of every source (schema objects,
+//! keywords, built-in functions, ...) into the list returned to the client.
+//!
+//! ## Flow
+//!
+//! ```text
+//! 1. Sources add their items
+//!    ↓
+//! 2. Items are ranked (see `ranking`)
+//!    ↓
+//! 3. Duplicates are merged into the best-ranked item
+//! ```
+//!
+//! Two items are duplicates when they share their label, kind and
+//! qualifier, e.g. a table listed by the catalog and by a CTE scan. The
+//! best-ranked item survives; it takes the documentation and the text edit
+//! of a duplicate when it has none (or a narrower edit) of its own.
+//!
+//! ## Quoting
+//!
+//! With a dialect, tables and columns whose names cannot be written bare are
+//! inserted quoted, keeping the bare name as label:
+//!
+//! ```text
+//! order       → "order"      (reserved word, PostgreSQL)
+//! order       → `order`      (reserved word, MySQL)
+//! OrderItems  → "OrderItems" (PostgreSQL folds unquoted names to lowercase)
+//! line item   → "line item"
+//! ```
+
+use std::collections::HashMap;
+use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind, CompletionTextEdit};
+use unified_sql_lsp_ir::{Dialect, IdentifierCase};
+
+use crate::completion::keyword_grammar::KeywordGrammar;
+use crate::completion::prefix::is_schema_object;
+use crate::completion::ranking;
+
+/// Merges the completion items of several sources
+#[derive(Debug, Default)]
+pub struct CompletionAggregator {
+    items: Vec<CompletionItem>,
+    /// Dialect whose quoting rules apply to inserted names
+    dialect: Option<Dialect>,
+}
+
+impl CompletionAggregator {
+    /// Create an empty aggregator
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Builder method: quote inserted names as the dialect requires
+    pub fn with_dialect(mut self, dialect: Dialect) -> Self {
+        self.dialect = Some(dialect);
+        self
+    }
+
+    /// Add the items of a source
+    pub fn add(&mut self, items: impl IntoIterator<Item = CompletionItem>) {
+        self.items.extend(items);
+    }
+
+    /// Rank the items and merge duplicates
+    ///
+    /// # Returns
+    ///
+    /// The items in ranking order, without duplicates, with names quoted
+    /// where the dialect requires it
+    pub fn finish(mut self) -> Vec<CompletionItem> {
+        ranking::rank(&mut self.items);
+
+        let mut merged: Vec<CompletionItem> = Vec::with_capacity(self.items.len());
+        let mut seen: HashMap<DedupKey, usize> = HashMap::new();
+        for item in self.items {
+            match seen.get(&DedupKey::of(&item)) {
+                Some(&index) => merge(&mut merged[index], item),
+                None => {
+                    seen.insert(DedupKey::of(&item), merged.len());
+                    merged.push(item);
+                }
+            }
+        }
+
+        if let Some(dialect) = self.dialect {
+            for item in merged.iter_mut().filter(|item| is_schema_object(item)) {
+                let text = item.insert_text.as_deref().unwrap_or(&item.label);
+                let quoted = quote_name(text, dialect);
+                if quoted != text {
+                    item.insert_text = Some(quoted);
+                }
+            }
+        }
+        merged
+    }
+}
+
+/// Quote the parts of a possibly qualified name that cannot be written bare
+fn quote_name(name: &str, dialect: Dialect) -> String {
+    let rules = dialect.rules();
+    name.split('.')
+        .map(|part| {
+            if needs_quotes(part, dialect) {
+                rules.quote(part)
+            } else {
+                part.to_string()
+            }
+        })
+        .collect::<Vec<_>>()
+        .join(".")
+}
+
+/// Check whether a name must be quoted to be read back as written
+///
+/// Reserved words, names with characters other than letters, digits, `_`
+/// and `$` (or starting with a digit), and names the dialect would fold to
+/// another case need quotes.
+fn needs_quotes(name: &str, dialect: Dialect) -> bool {
+    let Some(first) = name.chars().next() else {
+        return false;
+    };
+    if name == "*" || matches!(first, '"' | '`' | '[') {
+        return false;
+    }
+
+    let bare = (first.is_alphabetic() || first == '_')
+        && name
+            .chars()
+            .all(|c| c.is_alphanumeric() || c == '_' || c == '$');
+    let folded = match dialect.rules().fold {
+        IdentifierCase::Lower => name.chars().any(char::is_uppercase),
+        IdentifierCase::Upper => name.chars().any(char::is_lowercase),
+        IdentifierCase::Preserve => false,
+    };
+    !bare || folded || KeywordGrammar::builtin().is_reserved(dialect, name)
+}
+
+/// Identity of a completion item for deduplication
+#[derive(Debug, PartialEq, Eq, Hash)]
+struct DedupKey {
+    label: String,
+    kind: Option<CompletionItemKind>,
+    qualifier: Option<String>,
+}
+
+impl DedupKey {
+    fn of(item: &CompletionItem) -> Self {
+        // The qualifier is what the inserted text prefixes the name with
+        let text = item.insert_text.as_deref().unwrap_or(&item.label);
+        let qualifier = text
+            .rsplit_once('.')
+            .map(|(qualifier, _)| qualifier.to_lowercase());
+
+        Self {
+            label: item.label.clone(),
+            kind: item.kind,
+            qualifier,
+        }
+    }
+}
+
+/// Merge the metadata of a lower-ranked duplicate into the survivor
+fn merge(survivor: &mut CompletionItem, duplicate: CompletionItem) {
+    if survivor.documentation.is_none() {
+        survivor.documentation = duplicate.documentation;
+    }
+    if survivor.detail.is_none() {
+        survivor.detail = duplicate.detail;
+    }
+    if edit_strength(duplicate.text_edit.as_ref()) > edit_strength(survivor.text_edit.as_ref()) {
+        survivor.text_edit = duplicate.text_edit;
+    }
+}
+
+/// Strength of a text edit: insert-and-replace edits beat plain edits, and
+/// wider edits beat narrower ones
+fn edit_strength(edit: Option<&CompletionTextEdit>) -> (u8, u32, u32) {
+    let width = |range: &tower_lsp::lsp_types::Range| {
+        (
+            range.end.line.saturating_sub(range.start.line),
+            range.end.character.saturating_sub(range.start.character),
+        )
+    };
+
+    match edit {
+        None => (0, 0, 0),
+        Some(CompletionTextEdit::Edit(edit)) => {
+            let (lines, characters) = width(&edit.range);
+            (1, lines, characters)
+        }
+        Some(CompletionTextEdit::InsertAndReplace(edit)) => {
+            let (lines, characters) = width(&edit.replace);
+            (2, lines, characters)
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tower_lsp::lsp_types::{Documentation, Position, Range, TextEdit};
+
+    fn table(detail: &str, sort_text: &str) -> CompletionItem {
+        CompletionItem {
+            label: "users".to_string(),
+            kind: Some(CompletionItemKind::CLASS),
+            detail: Some(detail.to_string()),
+            sort_text: Some(sort_text.to_string()),
+            ..Default::default()
+        }
+    }
+
+    fn edit(start: u32, end: u32) -> Option<CompletionTextEdit> {
+        Some(CompletionTextEdit::Edit(TextEdit::new(
+            Range::new(Position::new(0, start), Position::new(0, end)),
+            "users".to_string(),
+        )))
+    }
+
+    #[test]
+    fn test_best_ranked_duplicate_survives() {
+        let catalog = table("TABLE public", "a_users");
+        let scan = CompletionItem {
+            documentation: Some(Documentation::String("Users of the shop".to_string())),
+            ..table("Table: users", "b_users")
+        };
+
+        let mut aggregator = CompletionAggregator::new();
+        aggregator.add(vec![scan]);
+        aggregator.add(vec![catalog]);
+        let items = aggregator.finish();
+
+        assert_eq!(items.len(), 1);
+        assert_eq!(items[0].detail.as_deref(), Some("TABLE public"));
+        assert_eq!(
+            items[0].documentation,
+            Some(Documentation::String("Users of the shop".to_string()))
+        );
+    }
+
+    #[test]
+    fn test_stronger_text_edit_is_kept() {
+        let narrow = CompletionItem {
+            text_edit: edit(7, 8),
+            ..table("TABLE public", "a_users")
+        };
+        let wide = CompletionItem {
+            text_edit: edit(5, 8),
+            ..table("Table: users", "b_users")
+        };
+
+        let mut aggregator = CompletionAggregator::new();
+        aggregator.add(vec![narrow, wide]);
+        let items = aggregator.finish();
+
+        assert_eq!(items.len(), 1);
+        assert_eq!(items[0].text_edit, edit(5, 8));
+    }
+
+    #[test]
+    fn test_names_needing_quotes_are_inserted_quoted() {
+        let item = |label: &str, kind: CompletionItemKind| CompletionItem {
+            label: label.to_string(),
+            kind: Some(kind),
+            insert_text: Some(label.to_string()),
+            ..Default::default()
+        };
+        let inserted = |dialect: Dialect, label: &str, kind: CompletionItemKind| {
+            let mut aggregator = CompletionAggregator::new().with_dialect(dialect);
+            aggregator.add(vec![item(label, kind)]);
+            let items = aggregator.finish();
+            assert_eq!(items[0].label, label);
+            items[0].insert_text.clone().unwrap()
+        };
+
+        // Dialect, label, expected insert text
+        let cases = [
+            (Dialect::PostgreSQL, "order", "\"order\""),
+            (Dialect::MySQL, "order", "`order`"),
+            (Dialect::PostgreSQL, "user", "\"user\""),
+            (Dialect::MySQL, "user", "user"),
+            (Dialect::PostgreSQL, "OrderItems", "\"OrderItems\""),
+            (Dialect::MySQL, "OrderItems", "OrderItems"),
+            (Dialect::PostgreSQL, "line item", "\"line item\""),
+            (Dialect::MySQL, "2fa_codes", "`2fa_codes`"),
+            (Dialect::PostgreSQL, "users", "users"),
+            (Dialect::PostgreSQL, "o.Total", "o.\"Total\""),
+            (Dialect::PostgreSQL, "public.order", "public.\"order\""),
+        ];
+        for (dialect, label, expected) in cases {
+            assert_eq!(
+                inserted(dialect, label, CompletionItemKind::FIELD),
+                expected,
+                "{:?} {}",
+                dialect,
+                label
+            );
+        }
+
+        // Keywords are not names
+        assert_eq!(
+            inserted(Dialect::PostgreSQL, "ORDER", CompletionItemKind::KEYWORD),
+            "ORDER"
+        );
+    }
+
+    #[test]
+    fn test_different_kind_or_qualifier_is_not_a_duplicate() {
+        let column = |insert_text: &str| CompletionItem {
+            label: "id".to_string(),
+            kind: Some(CompletionItemKind::FIELD),
+            insert_text: Some(insert_text.to_string()),
+            ..Default::default()
+        };
+        let keyword = CompletionItem {
+            label: "id".to_string(),
+            kind: Some(CompletionItemKind::KEYWORD),
+            ..Default::default()
+        };
+
+        let mut aggregator = CompletionAggregator::new();
+        aggregator.add(vec![column("users.id"), column("orders.id"), keyword]);
+
+        assert_eq!(aggregator.finish().len(), 3);
+    }
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Completion result cache
+//!
+//! This module keeps the last complete candidate set so that re-queries
+//! while the user keeps typing the same identifier are answered without
+//! catalog lookups:
+//!
+//! ```text,ignore
+//! SELECT * FROM u|     → full completion, candidates cached
+//! SELECT * FROM us|    → cached candidates filtered by "us"
+//! SELECT * FROM us |   → edit outside the identifier, cache missed
+//! ```
+//!
+//! An entry matches when the document is the same, the identifier starts at
+//! the same position and the text outside the identifier is unchanged. Any
+//! other edit invalidates it.
+
+use std::sync::Mutex;
+use tower_lsp::lsp_types::{CompletionItem, Position, Url};
+
+use crate::completion::prefix::TypedPrefix;
+use crate::document::Document;
+
+/// Cache of the last complete completion result
+#[derive(Debug, Default)]
+pub struct CompletionCache {
+    entry: Mutex<Option<CacheEntry>>,
+}
+
+/// Candidates of one completion request
+#[derive(Debug)]
+struct CacheEntry {
+    /// Document completed in
+    uri: Url,
+
+    /// Start of the identifier being typed
+    anchor: Position,
+
+    /// Document text without the identifier being typed
+    surrounding: String,
+
+    /// Candidates, before filtering by the typed prefix
+    items: Vec<CompletionItem>,
+}
+
+impl CompletionCache {
+    /// Create an empty cache
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Look up the candidates for a re-query
+    ///
+    /// # Arguments
+    ///
+    /// * `document` - The document to complete in
+    /// * `position` - The cursor position
+    /// * `typed` - The identifier typed at the cursor
+    ///
+    /// # Returns
+    ///
+    /// The cached candidates matching the typed prefix, or `None` when the
+    /// text outside the identifier changed since they were cached
+    pub fn lookup(
+        &self,
+        document: &Document,
+        position: Position,
+        typed: &TypedPrefix,
+    ) -> Option<Vec<CompletionItem>> {
+        let entry = self.entry.lock().unwrap();
+        let entry = entry.as_ref()?;
+        if entry.uri != *document.uri() || entry.anchor != typed.range.start {
+            return None;
+        }
+        if surrounding_text(document, typed.range.start, position)? != entry.surrounding {
+            return None;
+        }
+
+        Some(
+            entry
+                .items
+                .iter()
+                .filter(|item| matches_prefix(item, &typed.prefix))
+                .cloned()
+                .collect(),
+        )
+    }
+
+    /// Cache the candidates of a complete result
+    ///
+    /// # Arguments
+    ///
+    /// * `document` - The document completed in
+    /// * `position` - The cursor position
+    /// * `typed` - The identifier typed at the cursor
+    /// * `items` - The candidates, before filtering and text edits
+    pub fn store(
+        &self,
+        document: &Document,
+        position: Position,
+        typed: &TypedPrefix,
+        items: &[CompletionItem],
+    ) {
+        let entry =
+            surrounding_text(document, typed.range.start, position).map(|surrounding| CacheEntry {
+                uri: document.uri().clone(),
+                anchor: typed.range.start,
+                surrounding,
+                items: items.to_vec(),
+            });
+        *self.entry.lock().unwrap() = entry;
+    }
+
+    /// Drop the cached result (e.g. after the schema or settings changed)
+    pub fn clear(&self) {
+        *self.entry.lock().unwrap() = None;
+    }
+}
+
+/// Check whether an item can complete the typed prefix
+///
+/// The characters of the prefix must appear in order in the item's filter
+/// text, ignoring case, which is how clients filter the list themselves.
+fn matches_prefix(item: &CompletionItem, prefix: &str) -> bool {
+    let text = item.filter_text.as_deref().unwrap_or(&item.label);
+    let mut candidate = text.chars().flat_map(char::to_lowercase);
+    prefix
+        .chars()
+        .flat_map(char::to_lowercase)
+        .all(|wanted| candidate.any(|c| c == wanted))
+}
+
+/// Text of the document without the span from `start` to `end`
+fn surrounding_text(document: &Document, start: Position, end: Position) -> Option<String> {
+    let content = document.get_content();
+    let start = byte_offset(&content, start)?;
+    let end = byte_offset(&content, end)?;
+    if start > end {
+        return None;
+    }
+    Some(format!("{}{}", &content[..start], &content[end..]))
+}
+
+/// Byte offset of an LSP position (UTF-16 character offset)
+fn byte_offset(content: &str, position: Position) -> Option<usize> {
+    let mut offset = 0;
+    for (line, text) in content.split_inclusive('\n').enumerate() {
+        if line as u32 == position.line {
+            let mut utf16 = 0;
+            for (byte, c) in text.char_indices() {
+                if utf16 >= position.character || c == '\n' {
+                    return Some(offset + byte);
+                }
+                utf16 += c.len_utf16() as u32;
+            }
+            return Some(offset + text.len());
+        }
+        offset += text.len();
+    }
+    (position.line as usize == content.split_inclusive('\n').count()).then_some(offset)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_ir::DialectRules;
+
+    fn document(text: &str) -> Document {
+        Document::new(
+            Url::parse("file:///test.sql").unwrap(),
+            text.to_string(),
+            1,
+            "mysql".to_string(),
+        )
+    }
+
+    fn item(label: &str) -> CompletionItem {
+        CompletionItem {
+            label: label.to_string(),
+            ..Default::default()
+        }
+    }
+
+    fn typed(text: &str, position: Position) -> TypedPrefix {
+        TypedPrefix::at(
+            text.lines().nth(position.line as usize).unwrap(),
+            position,
+            &DialectRules::default(),
+        )
+    }
+
+    #[test]
+    fn test_narrowing_query_is_served_from_cache() {
+        let cache = CompletionCache::new();
+        let first = "SELECT * FROM u WHERE id = 1";
+        let position = Position::new(0, 15);
+        cache.store(
+            &document(first),
+            position,
+            &typed(first, position),
+            &[item("users"), item("orders"), item("user_roles")],
+        );
+
+        let second = "SELECT * FROM use WHERE id = 1";
+        let position = Position::new(0, 17);
+        let items = cache
+            .lookup(&document(second), position, &typed(second, position))
+            .unwrap();
+
+        let labels: Vec<&str> = items.iter().map(|i| i.label.as_str()).collect();
+        assert_eq!(labels, vec!["users", "user_roles"]);
+    }
+
+    #[test]
+    fn test_edit_outside_identifier_invalidates() {
+        let cache = CompletionCache::new();
+        let first = "SELECT * FROM u";
+        let position = Position::new(0, 15);
+        cache.store(
+            &document(first),
+            position,
+            &typed(first, position),
+            &[item("users")],
+        );
+
+        let second = "SELECT a FROM us";
+        let position = Position::new(0, 16);
+        assert!(
+            cache
+                .lookup(&document(second), position, &typed(second, position))
+                .is_none()
+        );
+
+        // A new identifier starts elsewhere
+        let third = "SELECT * FROM u, o";
+        let position = Position::new(0, 18);
+        assert!(
+            cache
+                .lookup(&document(third), position, &typed(third, position))
+                .is_none()
+        );
+    }
+
+    #[test]
+    fn test_clear() {
+        let cache = CompletionCache::new();
+        let text = "SELECT * FROM u";
+        let position = Position::new(0, 15);
+        cache.store(
+            &document(text),
+            position,
+            &typed(text, position),
+            &[item("users")],
+        );
+        cache.clear();
+
+        assert!(
+            cache
+                .lookup(&document(text), position, &typed(text, position))
+                .is_none()
+        );
+    }
+
+    #[test]
+    fn test_matches_prefix() {
+        assert!(matches_prefix(&item("user_roles"), "UR"));
+        assert!(matches_prefix(&item("users"), ""));
+        assert!(!matches_prefix(&item("orders"), "us"));
+    }
+}
@@ -12,7 +12,9 @@ use tracing::debug;
 
 use crate::completion::error::CompletionError;
 use std::sync::Arc;
-use unified_sql_lsp_catalog::{Catalog, ColumnMetadata, FunctionMetadata, TableMetadata};
+use unified_sql_lsp_catalog::{
+    Catalog, CatalogError, ColumnMetadata, FunctionMetadata, TableMetadata,
+};
 use unified_sql_lsp_semantic::{ColumnSymbol, TableSymbol};
 
 /// Catalog fetcher for completion
@@ -84,7 +86,8 @@ impl CatalogCompletionFetcher {
     ///
     /// # Returns
     ///
-    /// Ok(()) if successful, Err if catalog query fails
+    /// Ok(()) if successful, Err if catalog query fails. A catalog without
+    /// a database connection leaves the table with no columns.
     ///
     /// # Examples
     ///
@@ -98,11 +101,18 @@ impl CatalogCompletionFetcher {
         table: &mut TableSymbol,
     ) -> Result<(), CompletionError> {
         // Query catalog for columns
-        let columns_metadata = self
-            .catalog
-            .get_columns(&table.table_name)
-            .await
-            .map_err(CompletionError::Catalog)?;
+        let columns_metadata = match self.catalog.get_columns(&table.table_name).await {
+            Ok(columns) => columns,
+            Err(CatalogError::NoConnection) => {
+                // Schema is unknown without a connection; keep the table with no columns
+                debug!(
+                    "No database connection, skipping columns for table '{}'",
+                    table.table_name
+                );
+                Vec::new()
+            }
+            Err(e) => return Err(CompletionError::Catalog(e)),
+        };
 
         // Convert ColumnMetadata to ColumnSymbol
         let columns: Vec<ColumnSymbol> = columns_metadata
@@ -190,6 +200,9 @@ impl CatalogCompletionFetcher {
         if meta.is_foreign_key {
             symbol = symbol.with_foreign_key();
         }
+        symbol = symbol
+            .with_references(meta.references.clone())
+            .with_comment(meta.comment.clone());
 
         symbol
     }
@@ -417,4 +430,15 @@ mod tests {
         assert_eq!(table_symbols[0].columns.len(), 1);
         assert_eq!(table_symbols[1].columns.len(), 0);
     }
+
+    #[tokio::test]
+    async fn test_populate_single_table_without_connection() {
+        let catalog = Arc::new(unified_sql_lsp_catalog::OfflineCatalog::new());
+        let fetcher = CatalogCompletionFetcher::new(catalog);
+
+        // No connection is not an error: the table is known, its columns are not
+        let table = fetcher.populate_single_table("users").await.unwrap();
+        assert_eq!(table.table_name, "users");
+        assert!(table.columns.is_empty());
+    }
 }
@@ -0,0 +1,138 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Keyword casing
+//!
+//! This module applies the user's keyword casing preference to keyword
+//! completion items. Keywords are rendered upper case by the keyword
+//! provider; other items (columns, tables, functions) keep their names.
+//!
+//! ## Examples
+//!
+//! ```text,ignore
+//! upper:    sel| → SELECT
+//! lower:    SEL| → select
+//! preserve: sel| → select, SEL| → SELECT, Sel| → SELECT
+//! ```
+
+use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind};
+
+/// Casing of inserted keywords
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum KeywordCase {
+    /// Always upper case (`SELECT`)
+    #[default]
+    Upper,
+
+    /// Always lower case (`select`)
+    Lower,
+
+    /// Follow the casing of the typed prefix; lower case when the typed
+    /// prefix is all lower case, upper case otherwise
+    Preserve,
+}
+
+impl KeywordCase {
+    /// Parse the `keywordCase` setting ("upper", "lower" or "preserve")
+    ///
+    /// # Returns
+    ///
+    /// `None` for unknown values
+    pub fn from_setting(value: &str) -> Option<Self> {
+        match value.to_ascii_lowercase().as_str() {
+            "upper" => Some(Self::Upper),
+            "lower" => Some(Self::Lower),
+            "preserve" => Some(Self::Preserve),
+            _ => None,
+        }
+    }
+
+    /// Apply the casing to a keyword
+    ///
+    /// # Arguments
+    ///
+    /// * `keyword` - The keyword as rendered by the keyword provider
+    /// * `typed` - The prefix typed at the cursor
+    pub fn apply(&self, keyword: &str, typed: &str) -> String {
+        let lower = match self {
+            Self::Upper => false,
+            Self::Lower => true,
+            Self::Preserve => {
+                typed.chars().any(|c| c.is_alphabetic()) && !typed.chars().any(|c| c.is_uppercase())
+            }
+        };
+
+        if lower {
+            keyword.to_lowercase()
+        } else {
+            keyword.to_uppercase()
+        }
+    }
+
+    /// Apply the casing to the keyword items of a completion list
+    ///
+    /// Items of other kinds are left unchanged.
+    pub fn apply_to_items(&self, items: &mut [CompletionItem], typed: &str) {
+        for item in items
+            .iter_mut()
+            .filter(|item| item.kind == Some(CompletionItemKind::KEYWORD))
+        {
+            item.label = self.apply(&item.label, typed);
+            if let Some(insert_text) = item.insert_text.as_mut() {
+                *insert_text = self.apply(insert_text, typed);
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn keyword(label: &str) -> CompletionItem {
+        CompletionItem {
+            label: label.to_string(),
+            kind: Some(CompletionItemKind::KEYWORD),
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_from_setting() {
+        assert_eq!(KeywordCase::from_setting("upper"), Some(KeywordCase::Upper));
+        assert_eq!(KeywordCase::from_setting("Lower"), Some(KeywordCase::Lower));
+        assert_eq!(
+            KeywordCase::from_setting("preserve"),
+            Some(KeywordCase::Preserve)
+        );
+        assert_eq!(KeywordCase::from_setting("camel"), None);
+    }
+
+    #[test]
+    fn test_apply() {
+        assert_eq!(KeywordCase::Upper.apply("order by", "or"), "ORDER BY");
+        assert_eq!(KeywordCase::Lower.apply("ORDER BY", "OR"), "order by");
+        assert_eq!(KeywordCase::Preserve.apply("ORDER BY", "or"), "order by");
+        assert_eq!(KeywordCase::Preserve.apply("ORDER BY", "Or"), "ORDER BY");
+        assert_eq!(KeywordCase::Preserve.apply("ORDER BY", ""), "ORDER BY");
+    }
+
+    #[test]
+    fn test_apply_to_items_only_changes_keywords() {
+        let mut items = vec![
+            keyword("SELECT"),
+            CompletionItem {
+                label: "Users".to_string(),
+                kind: Some(CompletionItemKind::CLASS),
+                ..Default::default()
+            },
+        ];
+
+        KeywordCase::Lower.apply_to_items(&mut items, "");
+
+        assert_eq!(items[0].label, "select");
+        assert_eq!(items[1].label, "Users");
+    }
+}
@@ -0,0 +1,536 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Keyword completion grammar
+//!
+//! This module narrows keyword completion to the keywords that may come
+//! next where the cursor is:
+//!
+//! ```text,ignore
+//! SELECT * FROM orders WHERE |        → NOT, EXISTS, CASE, ... (no GROUP BY)
+//! SELECT * FROM orders o JOIN users u | → ON, USING, AS
+//! ```
+//!
+//! The allowed keywords are data: a table per dialect family, embedded from
+//! `keyword_grammar.yaml`, mapping a [`KeywordPosition`] (statement type,
+//! clause and the token before the cursor) to the keywords allowed there.
+//! Positions the table does not cover keep every keyword.
+
+use serde::Deserialize;
+use std::collections::{HashMap, HashSet};
+use std::sync::OnceLock;
+use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind, Position};
+use unified_sql_lsp_context::{KeywordProvider, SqlKeyword};
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_ir::dialect::DialectFamily;
+
+use crate::completion::render::CompletionRenderer;
+use crate::migrations::{Token, TokenKind, tokenize};
+
+/// Grammar table shipped with the server
+const BUILTIN_GRAMMAR: &str = include_str!("keyword_grammar.yaml");
+
+/// Statement types that start a statement
+const STATEMENTS: &[&str] = &["SELECT", "INSERT", "UPDATE", "DELETE", "WITH"];
+
+/// Keyword grammar of every dialect family
+#[derive(Debug, Deserialize)]
+pub struct KeywordGrammar {
+    common: DialectGrammar,
+    #[serde(default)]
+    mysql: DialectGrammar,
+    #[serde(default)]
+    postgresql: DialectGrammar,
+}
+
+/// Rules of one dialect section
+#[derive(Debug, Default, Deserialize)]
+struct DialectGrammar {
+    #[serde(default)]
+    reserved: Vec<String>,
+    /// Rules keyed by statement type
+    #[serde(default)]
+    statements: HashMap<String, Vec<KeywordRule>>,
+}
+
+/// Keywords allowed at the positions of a statement
+#[derive(Debug, Deserialize)]
+struct KeywordRule {
+    /// Clauses the rule applies in
+    #[serde(rename = "in")]
+    clauses: Vec<String>,
+    /// Keywords or token classes (`name`, `operator`, `comma`) the rule
+    /// applies after
+    after: Vec<String>,
+    allow: Vec<String>,
+}
+
+/// Token classes of the `after` lists, which are not keywords
+const TOKEN_CLASSES: [&str; 3] = ["name", "operator", "comma"];
+
+/// Token before the cursor
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum PreviousToken {
+    /// Reserved word, upper-cased
+    Keyword(String),
+    /// Identifier, number, literal, `)` or `*`
+    Name,
+    /// Comparison or arithmetic operator
+    Operator,
+    /// `,`
+    Comma,
+}
+
+impl PreviousToken {
+    /// Name of the token in the `after` lists of the grammar
+    fn as_str(&self) -> &str {
+        match self {
+            Self::Keyword(keyword) => keyword,
+            Self::Name => "name",
+            Self::Operator => "operator",
+            Self::Comma => "comma",
+        }
+    }
+}
+
+/// Position of the cursor in a statement, as the grammar sees it
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct KeywordPosition {
+    /// Statement type (`SELECT`, `INSERT`, ...)
+    pub statement: String,
+    /// Last clause keyword at the cursor's parenthesis depth
+    pub clause: String,
+    pub previous: PreviousToken,
+}
+
+/// Statement and clause of one parenthesis depth or CASE expression
+#[derive(Debug, Default)]
+struct Frame {
+    statement: Option<String>,
+    clause: Option<String>,
+    case: bool,
+}
+
+impl KeywordGrammar {
+    /// Parse a grammar table
+    pub fn from_yaml(yaml: &str) -> Result<Self, serde_yaml::Error> {
+        serde_yaml::from_str(yaml)
+    }
+
+    /// Grammar shipped with the server, parsed once
+    pub fn builtin() -> &'static Self {
+        static GRAMMAR: OnceLock<KeywordGrammar> = OnceLock::new();
+        GRAMMAR.get_or_init(|| {
+            Self::from_yaml(BUILTIN_GRAMMAR).expect("built-in keyword grammar is valid")
+        })
+    }
+
+    /// Common and dialect sections applying to a dialect
+    fn sections(&self, dialect: Dialect) -> [&DialectGrammar; 2] {
+        match dialect.family() {
+            DialectFamily::MySQL => [&self.common, &self.mysql],
+            DialectFamily::PostgreSQL => [&self.common, &self.postgresql],
+        }
+    }
+
+    /// Check whether a word is a keyword rather than a name
+    pub fn is_reserved(&self, dialect: Dialect, word: &str) -> bool {
+        self.sections(dialect).iter().any(|section| {
+            section
+                .reserved
+                .iter()
+                .chain(
+                    section
+                        .statements
+                        .values()
+                        .flatten()
+                        .flat_map(|rule| &rule.after)
+                        .filter(|after| !TOKEN_CLASSES.contains(&after.as_str())),
+                )
+                .any(|reserved| reserved.eq_ignore_ascii_case(word))
+        })
+    }
+
+    /// Find the position of the cursor
+    ///
+    /// # Arguments
+    ///
+    /// * `source` - Text of the document
+    /// * `cursor` - Cursor position; an identifier ending there is the
+    ///   typed prefix and is ignored
+    /// * `dialect` - Dialect whose reserved words are keywords
+    ///
+    /// # Returns
+    ///
+    /// `None` outside a statement, or where no statement type is known
+    /// (e.g. in function arguments or a CASE expression)
+    pub fn position_at(
+        &self,
+        source: &str,
+        cursor: Position,
+        dialect: Dialect,
+    ) -> Option<KeywordPosition> {
+        let tokens: Vec<Token> = tokenize(source)
+            .into_iter()
+            .take_while(|token| token.range.end <= cursor)
+            .filter(|token| !(token.is_identifier() && token.range.end == cursor))
+            .collect();
+        let start = tokens
+            .iter()
+            .rposition(|token| token.kind == TokenKind::Symbol(';'))
+            .map_or(0, |index| index + 1);
+        let tokens = &tokens[start..];
+
+        let mut frames = vec![Frame::default()];
+        for token in tokens {
+            match token.kind {
+                TokenKind::Symbol('(') => frames.push(Frame::default()),
+                TokenKind::Symbol(')') if frames.len() > 1 => {
+                    frames.pop();
+                }
+                TokenKind::Word if token.is_keyword("CASE") => frames.push(Frame {
+                    case: true,
+                    ..Frame::default()
+                }),
+                TokenKind::Word if token.is_keyword("END") && frames.len() > 1 => {
+                    if frames.last().is_some_and(|frame| frame.case) {
+                        frames.pop();
+                    }
+                }
+                TokenKind::Word => {
+                    let frame = frames.last_mut()?;
+                    if frame.case {
+                        continue;
+                    }
+                    let word = token.text.to_ascii_uppercase();
+                    enter_statement(frame, &word);
+                    if let Some(clause) = clause_of(&word) {
+                        frame.clause = Some(clause.to_string());
+                    }
+                }
+                _ => {}
+            }
+        }
+
+        let frame = frames.pop()?;
+        let statement = frame.statement?;
+        let previous = self.previous_token(tokens, dialect)?;
+
+        Some(KeywordPosition {
+            statement,
+            clause: frame.clause?,
+            previous,
+        })
+    }
+
+    /// Classify the last of the tokens before the cursor
+    fn previous_token(&self, tokens: &[Token], dialect: Dialect) -> Option<PreviousToken> {
+        let is_name = |token: &Token| match token.kind {
+            TokenKind::Word => !self.is_reserved(dialect, &token.text),
+            TokenKind::Quoted | TokenKind::Literal | TokenKind::Symbol(')') => true,
+            _ => false,
+        };
+
+        let (last, rest) = tokens.split_last()?;
+        Some(match last.kind {
+            TokenKind::Word if !is_name(last) => {
+                PreviousToken::Keyword(last.text.to_ascii_uppercase())
+            }
+            _ if is_name(last) => PreviousToken::Name,
+            TokenKind::Symbol(',') => PreviousToken::Comma,
+            // `SELECT *` and `t.*` end a name; `price *` is a product
+            TokenKind::Symbol('*') => match rest.last() {
+                Some(before) if is_name(before) => PreviousToken::Operator,
+                _ => PreviousToken::Name,
+            },
+            // Qualified names complete columns, not keywords
+            TokenKind::Symbol('.') => return None,
+            _ => PreviousToken::Operator,
+        })
+    }
+
+    /// Keywords allowed at a position
+    ///
+    /// # Returns
+    ///
+    /// The upper-cased keywords in the order of the grammar, or `None` if no
+    /// rule covers the position
+    pub fn allowed_keywords(
+        &self,
+        dialect: Dialect,
+        position: &KeywordPosition,
+    ) -> Option<Vec<String>> {
+        let mut allowed: Vec<String> = Vec::new();
+        let mut matched = false;
+        for section in self.sections(dialect) {
+            let Some(rules) = section.statements.get(&position.statement) else {
+                continue;
+            };
+            for rule in rules {
+                let applies = rule.clauses.contains(&position.clause)
+                    && rule
+                        .after
+                        .iter()
+                        .any(|after| after.eq_ignore_ascii_case(position.previous.as_str()));
+                if !applies {
+                    continue;
+                }
+                matched = true;
+                for keyword in &rule.allow {
+                    let keyword = keyword.to_ascii_uppercase();
+                    if !allowed.contains(&keyword) {
+                        allowed.push(keyword);
+                    }
+                }
+            }
+        }
+        matched.then_some(allowed)
+    }
+
+    /// Filter and order the keyword items of a completion by the grammar
+    ///
+    /// Keywords the grammar does not allow at the cursor are removed, and
+    /// allowed keywords missing from the items are added, in grammar order.
+    /// Items of other kinds are kept as they are.
+    ///
+    /// # Arguments
+    ///
+    /// * `items` - Completion items
+    /// * `source` - Text of the document
+    /// * `cursor` - Cursor position
+    /// * `dialect` - Dialect of the document
+    pub fn apply(
+        &self,
+        items: &mut Vec<CompletionItem>,
+        source: &str,
+        cursor: Position,
+        dialect: Dialect,
+    ) {
+        let Some(position) = self.position_at(source, cursor, dialect) else {
+            return;
+        };
+        let Some(allowed) = self.allowed_keywords(dialect, &position) else {
+            return;
+        };
+
+        let is_keyword = |item: &CompletionItem| item.kind == Some(CompletionItemKind::KEYWORD);
+        items.retain(|item| !is_keyword(item) || allowed.contains(&item.label.to_uppercase()));
+
+        let present: HashSet<String> = items
+            .iter()
+            .filter(|item| is_keyword(item))
+            .map(|item| item.label.to_uppercase())
+            .collect();
+        // Described as in the keyword sets of the provider
+        let provider = KeywordProvider::new(dialect);
+        let described: Vec<SqlKeyword> = [
+            provider.select_clause_keywords(),
+            provider.join_type_keywords(),
+            provider.expression_keywords(),
+            provider.sort_direction_keywords(),
+            provider.union_keywords(),
+            provider.insert_keywords(),
+            provider.update_keywords(),
+            provider.delete_keywords(),
+        ]
+        .into_iter()
+        .flat_map(|set| set.keywords)
+        .chain(provider.keywords_after_clause("JOIN"))
+        .collect();
+        let missing: Vec<SqlKeyword> = allowed
+            .iter()
+            .enumerate()
+            .filter(|(_, keyword)| !present.contains(*keyword))
+            .map(|(index, keyword)| {
+                let description = described
+                    .iter()
+                    .find(|described| described.label == *keyword)
+                    .and_then(|described| described.description.as_deref());
+                SqlKeyword::new(keyword, description, index as i32)
+            })
+            .collect();
+        items.extend(CompletionRenderer::render_keywords(&missing));
+
+        // Allowed keywords are listed in grammar order
+        for item in items.iter_mut().filter(|item| is_keyword(item)) {
+            if let Some(index) = allowed
+                .iter()
+                .position(|k| item.label.eq_ignore_ascii_case(k))
+            {
+                item.sort_text = Some(format!("{:05}_{}", index, item.label));
+            }
+        }
+    }
+}
+
+/// Update the statement type of a frame with a word
+fn enter_statement(frame: &mut Frame, word: &str) {
+    match frame.statement.as_deref() {
+        // The first word names the statement
+        None => frame.statement = Some(word.to_string()),
+        // `WITH ... SELECT`, `INSERT ... SELECT`, `CREATE TABLE ... AS SELECT`
+        Some(_) if word == "SELECT" => frame.statement = Some(word.to_string()),
+        // `WITH ... UPDATE`
+        Some("WITH") if STATEMENTS.contains(&word) => frame.statement = Some(word.to_string()),
+        Some(_) => {}
+    }
+}
+
+/// Clause started by a keyword
+fn clause_of(word: &str) -> Option<&'static str> {
+    Some(match word {
+        "SELECT" => "SELECT",
+        "FROM" => "FROM",
+        "JOIN" => "JOIN",
+        "ON" => "ON",
+        "USING" => "USING",
+        "WHERE" => "WHERE",
+        "GROUP" => "GROUP BY",
+        "HAVING" => "HAVING",
+        "ORDER" => "ORDER BY",
+        "LIMIT" => "LIMIT",
+        "OFFSET" => "OFFSET",
+        "UNION" => "UNION",
+        "INTERSECT" => "INTERSECT",
+        "EXCEPT" => "EXCEPT",
+        "INSERT" => "INSERT",
+        "INTO" => "INTO",
+        "VALUES" => "VALUES",
+        "UPDATE" => "UPDATE",
+        "SET" => "SET",
+        "DELETE" => "DELETE",
+        "RETURNING" => "RETURNING",
+        _ => return None,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Position at the end of a single-line statement
+    fn end(sql: &str) -> Position {
+        Position::new(0, sql.len() as u32)
+    }
+
+    fn allowed(sql: &str, dialect: Dialect) -> Option<Vec<String>> {
+        let grammar = KeywordGrammar::builtin();
+        let position = grammar.position_at(sql, end(sql), dialect)?;
+        grammar.allowed_keywords(dialect, &position)
+    }
+
+    fn keyword(label: &str) -> CompletionItem {
+        CompletionItem {
+            label: label.to_string(),
+            kind: Some(CompletionItemKind::KEYWORD),
+            ..Default::default()
+        }
+    }
+
+    #[test]
+    fn test_builtin_grammar_parses() {
+        let grammar = KeywordGrammar::builtin();
+        assert!(grammar.common.statements.contains_key("SELECT"));
+        assert!(!grammar.postgresql.statements.is_empty());
+    }
+
+    #[test]
+    fn test_position_at_cursor() {
+        let grammar = KeywordGrammar::builtin();
+        let position = |sql: &str| grammar.position_at(sql, end(sql), Dialect::PostgreSQL);
+
+        let at = position("SELECT * FROM orders o JOIN users u ").unwrap();
+        assert_eq!(at.statement, "SELECT");
+        assert_eq!(at.clause, "JOIN");
+        assert_eq!(at.previous, PreviousToken::Name);
+
+        // The typed prefix is not the token before the cursor
+        let at = position("SELECT * FROM t WHERE us").unwrap();
+        assert_eq!(at.clause, "WHERE");
+        assert_eq!(at.previous, PreviousToken::Keyword("WHERE".to_string()));
+
+        // Columns named like a token class are names
+        let at = position("SELECT name ").unwrap();
+        assert_eq!(at.previous, PreviousToken::Name);
+
+        // Subqueries have their own clause; closed ones end a name
+        let at = position("SELECT * FROM t WHERE id IN (SELECT id FROM u) ").unwrap();
+        assert_eq!(at.clause, "WHERE");
+        assert_eq!(at.previous, PreviousToken::Name);
+        let at = position("WITH recent AS (SELECT 1) DELETE FROM t WHERE ").unwrap();
+        assert_eq!(at.statement, "DELETE");
+
+        // Only the statement at the cursor counts
+        let at = position("DELETE FROM t; UPDATE t SET a = ").unwrap();
+        assert_eq!(at.statement, "UPDATE");
+        assert_eq!(at.clause, "SET");
+        assert_eq!(at.previous, PreviousToken::Operator);
+
+        // Function arguments and CASE expressions are left alone
+        assert_eq!(position("SELECT count("), None);
+        assert_eq!(position("SELECT CASE WHEN a "), None);
+    }
+
+    #[test]
+    fn test_group_by_is_not_offered_inside_where() {
+        for sql in ["SELECT * FROM t WHERE ", "SELECT * FROM t WHERE a = 1 AND "] {
+            let keywords = allowed(sql, Dialect::MySQL).unwrap();
+            assert!(!keywords.contains(&"GROUP BY".to_string()), "{}", sql);
+            assert!(keywords.contains(&"NOT".to_string()), "{}", sql);
+        }
+
+        // After a complete condition the next clauses may follow
+        let keywords = allowed("SELECT * FROM t WHERE a = 1 ", Dialect::MySQL).unwrap();
+        assert!(keywords.contains(&"AND".to_string()));
+        assert!(keywords.contains(&"GROUP BY".to_string()));
+    }
+
+    #[test]
+    fn test_on_is_offered_after_join() {
+        let keywords = allowed("SELECT * FROM orders JOIN users ", Dialect::MySQL).unwrap();
+        assert_eq!(keywords[0], "ON");
+        assert!(!keywords.contains(&"GROUP BY".to_string()));
+
+        let keywords = allowed("SELECT * FROM orders ", Dialect::MySQL).unwrap();
+        assert!(keywords.contains(&"WHERE".to_string()));
+        assert!(keywords.contains(&"LEFT JOIN".to_string()));
+        assert!(!keywords.contains(&"ON".to_string()));
+    }
+
+    #[test]
+    fn test_dialects_differ() {
+        let mysql = allowed("INSERT INTO t (a) VALUES (1) ", Dialect::MySQL).unwrap();
+        assert_eq!(mysql, ["ON DUPLICATE KEY UPDATE"]);
+        let postgres = allowed("INSERT INTO t (a) VALUES (1) ", Dialect::PostgreSQL).unwrap();
+        assert_eq!(postgres, ["ON CONFLICT", "RETURNING"]);
+
+        // Positions no rule covers keep every keyword
+        assert_eq!(allowed("CREATE TABLE t (a ", Dialect::MySQL), None);
+    }
+
+    #[test]
+    fn test_apply_filters_and_orders_keywords() {
+        let sql = "SELECT * FROM orders JOIN users ";
+        let mut items = vec![
+            keyword("WHERE"),
+            keyword("AS"),
+            CompletionItem {
+                label: "orders".to_string(),
+                kind: Some(CompletionItemKind::CLASS),
+                ..Default::default()
+            },
+        ];
+        KeywordGrammar::builtin().apply(&mut items, sql, end(sql), Dialect::MySQL);
+
+        let mut keywords: Vec<&CompletionItem> = items
+            .iter()
+            .filter(|item| item.kind == Some(CompletionItemKind::KEYWORD))
+            .collect();
+        keywords.sort_by(|a, b| a.sort_text.cmp(&b.sort_text));
+        let labels: Vec<&str> = keywords.iter().map(|item| item.label.as_str()).collect();
+        assert_eq!(labels, ["ON", "USING", "AS"]);
+        assert!(items.iter().any(|item| item.label == "orders"));
+    }
+}
@@ -14,6 +14,13 @@
 //! - `scopes`: Builds semantic scopes from CST nodes
 //! - `catalog_integration`: Fetches schema information from the catalog
 //! - `render`: Converts semantic symbols to LSP completion items
+//! - `prefix`: Finds the typed identifier and attaches replacing text edits
+//! - `relations`: Infers the columns of the CTEs and derived tables of a statement
+//! - `keyword_case`: Applies the user's keyword casing preference
+//! - `keyword_grammar`: Narrows keywords to those allowed at the cursor
+//! - `ranking`: Assigns the sort text of every item
+//! - `aggregator`: Merges the items of every source and removes duplicates
+//! - `cache`: Serves re-queries for the same identifier from the last result
 //! - `error`: Error types for completion operations
 //!
 //! ## Flow
@@ -32,18 +39,29 @@
 //! 6. Return CompletionResponse to client
 //! ```
 
+pub mod aggregator;
+pub mod cache;
 pub mod catalog_integration;
 pub mod error;
+pub mod keyword_case;
+pub mod keyword_grammar;
+pub mod prefix;
+pub mod ranking;
+pub mod relations;
 pub mod render;
 
 // Note: alias_resolution and scopes modules are now provided by semantic and context crates
 // Note: context and keywords modules are now provided by unified_sql-lsp-context crate
 
 use std::collections::HashSet;
-use std::sync::Arc;
-use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind, InsertTextFormat, Position};
+use std::sync::{Arc, OnceLock};
+use std::time::Duration;
+use tower_lsp::lsp_types::{
+    CompletionItem, CompletionItemKind, CompletionList, InsertTextFormat, Position,
+};
 use tracing::{debug, instrument};
-use unified_sql_lsp_catalog::{Catalog, FunctionType};
+use unified_sql_lsp_catalog::{Catalog, FunctionMetadata, FunctionType, OfflineCatalog};
+use unified_sql_lsp_function_registry::FunctionRegistry;
 use unified_sql_lsp_ir::Dialect;
 
 // Import from semantic crate (moved from LSP)
@@ -52,10 +70,17 @@ use unified_sql_lsp_semantic::{CompletionService, CompletionTextHeuristics};
 // Import from context crate (moved from LSP)
 use unified_sql_lsp_context::ScopeBuilder;
 
+use crate::completion::aggregator::CompletionAggregator;
+use crate::completion::cache::CompletionCache;
 use crate::completion::catalog_integration::CatalogCompletionFetcher;
 use crate::completion::error::CompletionError;
+use crate::completion::keyword_case::KeywordCase;
+use crate::completion::keyword_grammar::KeywordGrammar;
+use crate::completion::prefix::TypedPrefix;
+use crate::completion::relations::{RelationKind, StatementRelations};
 use crate::completion::render::CompletionRenderer;
 use crate::document::Document;
+use crate::parameters::{ParameterStyles, complete_parameters};
 
 // Use context crate for keywords
 use unified_sql_lsp_context::KeywordProvider;
@@ -69,12 +94,39 @@ fn to_context_pos(pos: Position) -> unified_sql_lsp_context::Position {
     unified_sql_lsp_context::Position::new(pos.line, pos.character)
 }
 
+/// Names of the visible CTEs among the tables of a context
+fn cte_names(tables: &[String], relations: &StatementRelations) -> Vec<String> {
+    tables
+        .iter()
+        .filter(|table| {
+            relations
+                .find(table)
+                .is_some_and(|relation| relation.kind == RelationKind::Cte)
+        })
+        .cloned()
+        .collect()
+}
+
+/// Built-in functions of every dialect, loaded once
+pub(crate) fn builtin_functions() -> &'static FunctionRegistry {
+    static REGISTRY: OnceLock<FunctionRegistry> = OnceLock::new();
+    REGISTRY.get_or_init(FunctionRegistry::new)
+}
+
 /// Completion engine
 ///
 /// Orchestrates the completion flow from context detection to rendering.
 pub struct CompletionEngine {
     catalog_fetcher: Arc<CatalogCompletionFetcher>,
     dialect: Dialect,
+    /// Whether the client renders snippets
+    snippets: bool,
+    /// Casing of inserted keywords
+    keyword_case: KeywordCase,
+    /// Candidates of the previous request, shared across requests
+    cache: Option<Arc<CompletionCache>>,
+    /// Placeholder styles; `None` uses the styles of the dialect
+    parameters: Option<ParameterStyles>,
 }
 
 impl CompletionEngine {
@@ -88,9 +140,51 @@ impl CompletionEngine {
         Self {
             catalog_fetcher: Arc::new(CatalogCompletionFetcher::new(catalog)),
             dialect,
+            snippets: false,
+            keyword_case: KeywordCase::default(),
+            cache: None,
+            parameters: None,
         }
     }
 
+    /// Builder method: dialect used when a document has no parse metadata
+    ///
+    /// Also selects the built-in functions offered next to the catalog's.
+    pub fn with_dialect(mut self, dialect: Dialect) -> Self {
+        self.dialect = dialect;
+        self
+    }
+
+    /// Builder method: casing of inserted keywords
+    pub fn with_keyword_case(mut self, keyword_case: KeywordCase) -> Self {
+        self.keyword_case = keyword_case;
+        self
+    }
+
+    /// Builder method: serve re-queries from a cache of the previous result
+    pub fn with_cache(mut self, cache: Arc<CompletionCache>) -> Self {
+        self.cache = Some(cache);
+        self
+    }
+
+    /// Builder method: bind parameter placeholders of the document
+    ///
+    /// Named placeholders (`:name`, `@name`) are completed with the others
+    /// of the document.
+    pub fn with_parameters(mut self, styles: ParameterStyles) -> Self {
+        self.parameters = Some(styles);
+        self
+    }
+
+    /// Builder method: render snippets (e.g. function arguments)
+    ///
+    /// Only enable this for clients advertising snippet support; without it,
+    /// items are plain text.
+    pub fn with_snippets(mut self, snippets: bool) -> Self {
+        self.snippets = snippets;
+        self
+    }
+
     /// Perform completion at the given position
     ///
     /// # Arguments
@@ -125,6 +219,149 @@ impl CompletionEngine {
         &self,
         document: &Document,
         position: Position,
+    ) -> Result<Option<Vec<CompletionItem>>, CompletionError> {
+        let dialect = document
+            .parse_metadata()
+            .map(|m| m.dialect)
+            .unwrap_or(self.dialect);
+        let typed = document
+            .get_line(position.line as usize)
+            .map(|line| TypedPrefix::at(&line, position, &dialect.rules()));
+
+        // Named placeholders complete to the others of the document
+        let styles = self
+            .parameters
+            .unwrap_or_else(|| ParameterStyles::for_dialect(dialect));
+        if let Some(mut items) = complete_parameters(&document.get_content(), position, styles) {
+            if let Some(typed) = &typed {
+                typed.apply_text_edits(&mut items);
+            }
+            return Ok(Some(items));
+        }
+
+        // Re-queries while typing the same identifier narrow the cached candidates
+        let cached = match (&self.cache, &typed) {
+            (Some(cache), Some(typed)) => cache.lookup(document, position, typed),
+            _ => None,
+        };
+        let mut items = match cached {
+            Some(items) => {
+                debug!(item_count = items.len(), "Completion served from cache");
+                items
+            }
+            None => {
+                let Some(items) = self.candidates(document, position, typed.as_ref()).await? else {
+                    return Ok(None);
+                };
+                if let (Some(cache), Some(typed)) = (&self.cache, &typed) {
+                    cache.store(document, position, typed, &items);
+                }
+                items
+            }
+        };
+
+        if let Some(typed) = &typed {
+            self.keyword_case.apply_to_items(&mut items, &typed.prefix);
+
+            // Replace exactly what the user typed (including any qualifier or quote)
+            typed.apply_text_edits(&mut items);
+        }
+
+        Ok(Some(items))
+    }
+
+    /// Compute the ranked, deduplicated candidates at the given position
+    ///
+    /// Candidates carry neither keyword casing nor text edits, which depend
+    /// on the typed prefix.
+    async fn candidates(
+        &self,
+        document: &Document,
+        position: Position,
+        typed: Option<&TypedPrefix>,
+    ) -> Result<Option<Vec<CompletionItem>>, CompletionError> {
+        let Some(mut items) = self.complete_items(document, position, typed).await? else {
+            return Ok(None);
+        };
+
+        let dialect = document
+            .parse_metadata()
+            .map(|m| m.dialect)
+            .unwrap_or(self.dialect);
+
+        // Keywords never follow a qualifier (`users.|`)
+        if typed.is_some_and(|typed| typed.qualifier.is_some()) {
+            items.retain(|item| item.kind != Some(CompletionItemKind::KEYWORD));
+        } else {
+            // Only the keywords that may come next at the cursor
+            KeywordGrammar::builtin().apply(&mut items, &document.get_content(), position, dialect);
+        }
+
+        let mut aggregator = CompletionAggregator::new().with_dialect(dialect);
+        aggregator.add(items);
+        Ok(Some(aggregator.finish()))
+    }
+
+    /// Perform completion within a time budget
+    ///
+    /// When the budget expires (e.g. on a slow schema lookup), the pending
+    /// completion is dropped, which cancels its catalog calls, and the
+    /// schema-independent items (keywords, built-in functions) are returned
+    /// as an incomplete list so the client asks again.
+    ///
+    /// # Arguments
+    ///
+    /// * `document` - The document to complete in
+    /// * `position` - The cursor position
+    /// * `budget` - Time allowed for the complete result
+    ///
+    /// # Returns
+    ///
+    /// Same as [`CompletionEngine::complete`], as a completion list
+    pub async fn complete_within(
+        &self,
+        document: &Document,
+        position: Position,
+        budget: Duration,
+    ) -> Result<Option<CompletionList>, CompletionError> {
+        if let Ok(result) = tokio::time::timeout(budget, self.complete(document, position)).await {
+            return Ok(result?.map(|items| CompletionList {
+                is_incomplete: false,
+                items,
+            }));
+        }
+
+        debug!(
+            budget_ms = budget.as_millis() as u64,
+            "Completion budget expired, returning schema-independent items"
+        );
+        let offline = Self {
+            catalog_fetcher: Arc::new(CatalogCompletionFetcher::new(Arc::new(
+                OfflineCatalog::new(),
+            ))),
+            dialect: self.dialect,
+            snippets: self.snippets,
+            keyword_case: self.keyword_case,
+            // Partial results are not cached
+            cache: None,
+        };
+        Ok(offline
+            .complete(document, position)
+            .await?
+            .map(|items| CompletionList {
+                is_incomplete: true,
+                items,
+            }))
+    }
+
+    /// Compute completion items for the context at the given position
+    ///
+    /// Items returned here carry no text edits; see [`TypedPrefix`].
+    async fn complete_items(
+        &self,
+        document: &Document,
+        position: Position,
+        typed: Option<&TypedPrefix>,
     ) -> Result<Option<Vec<CompletionItem>>, CompletionError> {
         // Clone source to avoid holding document reference
         let source = document.get_content().to_string();
@@ -176,7 +413,123 @@ impl CompletionEngine {
             "Context detection complete"
         );
 
-        // Now handle async operations with only owned data
+        // CTEs and derived tables of the statement complete like tables
+        let dialect = document
+            .parse_metadata()
+            .map(|m| m.dialect)
+            .unwrap_or(self.dialect);
+        let relations = StatementRelations::infer(
+            &source,
+            position,
+            dialect,
+            self.catalog_fetcher.catalog().as_ref(),
+        )
+        .await;
+        if relations.is_empty() {
+            return self
+                .complete_context(ctx, scope_manager, &source, document, position)
+                .await;
+        }
+
+        // A qualifier naming a relation (`recent.|`) completes its columns
+        let names_tables = !matches!(
+            ctx,
+            CompletionContext::FromClause { .. } | CompletionContext::CteDefinition { .. }
+        );
+        let qualifier = typed.and_then(|typed| typed.qualifier.as_deref());
+        if names_tables && let Some(relation) = qualifier.and_then(|q| relations.find(q)) {
+            debug!(relation = %relation.name, "Completing columns of a statement relation");
+            let mut items = CompletionRenderer::render_columns(&[relation.to_symbol()], true);
+            items.retain(|item| item.label != "*");
+            return Ok(Some(items));
+        }
+
+        self.complete_with_relations(ctx, scope_manager, &relations, &source, document, position)
+            .await
+    }
+
+    /// Complete in a statement defining its own relations
+    ///
+    /// The context is completed with the relations served as tables, and
+    /// the CTEs it selects from are offered as a whole (`name.*`).
+    async fn complete_with_relations(
+        &self,
+        ctx: CompletionContext,
+        scope_manager: Option<unified_sql_lsp_semantic::ScopeManager>,
+        relations: &StatementRelations,
+        source: &str,
+        document: &Document,
+        position: Position,
+    ) -> Result<Option<Vec<CompletionItem>>, CompletionError> {
+        // Derived tables are missing from the tables found in the text
+        let add_derived = |tables: &mut Vec<String>| {
+            for relation in relations.visible() {
+                if relation.kind != RelationKind::Cte
+                    && !tables
+                        .iter()
+                        .any(|t| t.eq_ignore_ascii_case(&relation.name))
+                {
+                    tables.push(relation.name.clone());
+                }
+            }
+        };
+        let mut ctes = Vec::new();
+        let ctx = match ctx {
+            CompletionContext::SelectProjection {
+                mut tables,
+                qualifier,
+            } => {
+                if qualifier.is_none() {
+                    ctes = cte_names(&tables, relations);
+                }
+                add_derived(&mut tables);
+                CompletionContext::SelectProjection { tables, qualifier }
+            }
+            CompletionContext::WhereClause {
+                mut tables,
+                qualifier,
+            } => {
+                if qualifier.is_none() {
+                    ctes = cte_names(&tables, relations);
+                }
+                add_derived(&mut tables);
+                CompletionContext::WhereClause { tables, qualifier }
+            }
+            ctx => ctx,
+        };
+
+        let scoped = Self {
+            catalog_fetcher: Arc::new(CatalogCompletionFetcher::new(
+                relations.catalog(self.catalog_fetcher.catalog()),
+            )),
+            dialect: self.dialect,
+            snippets: self.snippets,
+            keyword_case: self.keyword_case,
+            cache: None,
+        };
+        let mut items = scoped
+            .complete_context(ctx, scope_manager, source, document, position)
+            .await?;
+
+        if let Some(items) = items.as_mut() {
+            for name in ctes {
+                if !items.iter().any(|item| item.label == name) {
+                    items.push(CompletionRenderer::cte_item(&name));
+                }
+            }
+        }
+        Ok(items)
+    }
+
+    /// Compute completion items for a detected context
+    async fn complete_context(
+        &self,
+        ctx: CompletionContext,
+        scope_manager: Option<unified_sql_lsp_semantic::ScopeManager>,
+        source: &str,
+        document: &Document,
+        position: Position,
+    ) -> Result<Option<Vec<CompletionItem>>, CompletionError> {
         match ctx {
             CompletionContext::SelectProjection { tables, qualifier } => {
                 eprintln!(
@@ -184,14 +537,8 @@ impl CompletionEngine {
                     tables, qualifier
                 );
                 debug!(?tables, ?qualifier, "Matched SelectProjection context");
-                self.complete_select_projection(
-                    &scope_manager,
-                    tables,
-                    qualifier,
-                    &source,
-                    document,
-                )
-                .await
+                self.complete_select_projection(&scope_manager, tables, qualifier, source, document)
+                    .await
             }
             CompletionContext::FromClause { exclude_tables } => {
                 self.complete_from_clause(document, position, exclude_tables)
@@ -239,25 +586,43 @@ impl CompletionEngine {
 
                 // Determine if we should force qualification.
                 let force_qualifier = CompletionTextHeuristics::should_force_join_qualifier(
-                    &source,
+                    source,
                     tables_with_columns.len(),
                 );
 
                 // Fetch functions from catalog (scalar functions only for JOINs)
-                let functions = self.catalog_fetcher.list_functions().await?;
+                let functions = self.list_functions().await?;
 
                 debug!(
                     tables_count = tables_to_render.len(),
                     "Filtered tables for rendering"
                 );
 
+                // Complete conditions derived from foreign keys rank first (unless a
+                // qualifier restricts completion to a single table's columns)
+                let mut items = if let (None, Some(joined)) = (&qualifier, &right_table) {
+                    let joins = CompletionService::foreign_key_joins(&tables_with_columns, joined);
+                    debug!(
+                        join_count = joins.len(),
+                        "Derived foreign key JOIN conditions"
+                    );
+                    CompletionRenderer::render_join_conditions(&joins)
+                } else {
+                    Vec::new()
+                };
+
                 // Render with PK/FK prioritization
-                let mut items =
-                    CompletionRenderer::render_join_columns(&tables_to_render, force_qualifier);
+                items.extend(CompletionRenderer::render_join_columns(
+                    &tables_to_render,
+                    force_qualifier,
+                ));
 
                 // Add function completion items (scalar functions only for JOINs)
-                let function_items =
-                    CompletionRenderer::render_functions(&functions, Some(FunctionType::Scalar));
+                let function_items = CompletionRenderer::render_functions(
+                    &functions,
+                    Some(FunctionType::Scalar),
+                    self.snippets,
+                );
                 items.extend(function_items);
 
                 debug!(
@@ -400,6 +765,22 @@ impl CompletionEngine {
         }
     }
 
+    /// List the catalog's functions followed by the dialect's built-ins
+    ///
+    /// Built-ins keep completion useful without a schema; functions the
+    /// catalog already lists are not repeated.
+    async fn list_functions(&self) -> Result<Vec<FunctionMetadata>, CompletionError> {
+        let mut functions = self.catalog_fetcher.list_functions().await?;
+        let known: HashSet<String> = functions.iter().map(|f| f.name.to_uppercase()).collect();
+        functions.extend(
+            builtin_functions()
+                .get_functions(self.dialect)
+                .into_iter()
+                .filter(|f| !known.contains(&f.name.to_uppercase())),
+        );
+        Ok(functions)
+    }
+
     /// Complete SELECT projection with columns, functions, and SELECT modifiers
     ///
     /// This is specialized for SELECT clause completion.
@@ -605,7 +986,7 @@ impl CompletionEngine {
             let tables_to_render = resolution.tables_to_render;
 
             // Fetch functions from catalog
-            let functions = self.catalog_fetcher.list_functions().await?;
+            let functions = self.list_functions().await?;
 
             debug!(tables_count = tables_to_render.len(), "Tables to render");
 
@@ -637,15 +1018,7 @@ impl CompletionEngine {
                     cte_names
                 );
                 for cte_name in cte_names {
-                    // Create a simple completion item for the CTE
-                    items.push(CompletionItem {
-                        label: cte_name.clone(),
-                        kind: Some(CompletionItemKind::CLASS),
-                        detail: Some(format!("CTE: {}", cte_name)),
-                        insert_text: Some(format!("{}.*", cte_name)),
-                        insert_text_format: Some(InsertTextFormat::PLAIN_TEXT),
-                        ..Default::default()
-                    });
+                    items.push(CompletionRenderer::cte_item(cte_name));
                 }
             }
 
@@ -655,7 +1028,8 @@ impl CompletionEngine {
             }
 
             // Add function completion items
-            let function_items = CompletionRenderer::render_functions(&functions, function_filter);
+            let function_items =
+                CompletionRenderer::render_functions(&functions, function_filter, self.snippets);
             items.extend(function_items);
 
             debug!(
@@ -675,7 +1049,7 @@ impl CompletionEngine {
         let completion_service = CompletionService::new(self.catalog_fetcher.catalog());
 
         // Fetch functions from catalog
-        let functions = self.catalog_fetcher.list_functions().await?;
+        let functions = self.list_functions().await?;
 
         // Resolve qualifier if present to filter tables
         let tables_to_render = match completion_service
@@ -699,7 +1073,8 @@ impl CompletionEngine {
         }
 
         // Add function completion items
-        let function_items = CompletionRenderer::render_functions(&functions, function_filter);
+        let function_items =
+            CompletionRenderer::render_functions(&functions, function_filter, self.snippets);
         items.extend(function_items);
 
         Ok(Some(items))
@@ -1067,16 +1442,32 @@ impl CompletionEngine {
             tables.retain(|t| !exclude_lower.contains(&t.name.to_lowercase()));
         }
 
-        // Filter by prefix if present
+        // Filter by prefix if present; a qualified prefix (`db.schema.ta`)
+        // matches the qualified table name
+        let qualified_prefix = prefix.as_ref().is_some_and(|p| p.contains('.'));
         if let Some(ref p) = prefix
             && !p.is_empty()
         {
-            tables.retain(|t| t.name.to_lowercase().starts_with(&p.to_lowercase()));
+            let p = p.to_lowercase();
+            tables.retain(|t| {
+                if qualified_prefix {
+                    t.qualified_name().to_lowercase().starts_with(&p)
+                } else {
+                    t.name.to_lowercase().starts_with(&p)
+                }
+            });
         }
 
-        // Show schema qualifier if multiple schemas
-        let schemas: HashSet<&str> = tables.iter().map(|t| t.schema.as_str()).collect();
-        let items = CompletionRenderer::render_tables(&tables, schemas.len() > 1);
+        // Show schema qualifier if multiple schemas in the connected database,
+        // or if the user typed one. Tables of other databases are always
+        // qualified.
+        let schemas: HashSet<&str> = tables
+            .iter()
+            .filter(|t| t.catalog.is_none())
+            .map(|t| t.schema.as_str())
+            .collect();
+        let items =
+            CompletionRenderer::render_tables(&tables, schemas.len() > 1 || qualified_prefix);
 
         Ok(Some(items))
     }
@@ -1111,6 +1502,7 @@ mod tests {
     use crate::parsing::{ParseResult, ParserManager};
     use std::sync::Arc;
     use tower_lsp::lsp_types::{Position, Url};
+    use unified_sql_lsp_catalog::{CatalogResult, ColumnMetadata, TableMetadata};
     use unified_sql_lsp_ir::Dialect;
 
     /// Helper function to create a parsed document for testing
@@ -1219,6 +1611,48 @@ mod tests {
         assert!(items.iter().any(|i| i.label == "u.name"));
     }
 
+    #[tokio::test]
+    async fn test_qualified_completion_text_edit_replaces_typed_prefix() {
+        use tower_lsp::lsp_types::{CompletionTextEdit, Range};
+        use unified_sql_lsp_catalog::DataType;
+        use unified_sql_lsp_catalog::TableMetadata;
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog = MockCatalogBuilder::new()
+            .with_table(TableMetadata::new("users", "public").with_columns(vec![
+                unified_sql_lsp_catalog::ColumnMetadata::new("id", DataType::Integer),
+                unified_sql_lsp_catalog::ColumnMetadata::new("name", DataType::Text),
+            ]))
+            .build();
+
+        let engine = CompletionEngine::new(Arc::new(catalog));
+
+        // Cursor after "u.na": accepting "u.name" must replace "u.na", not append to it
+        let source = r#"SELECT u.na FROM users AS u;"#;
+        let document = create_test_document(source, "mysql").await;
+
+        let items = engine
+            .complete(&document, Position::new(0, 11))
+            .await
+            .unwrap()
+            .expect("Should return completion items");
+
+        let name = items
+            .iter()
+            .find(|i| i.label == "u.name")
+            .expect("Should offer u.name");
+        match &name.text_edit {
+            Some(CompletionTextEdit::Edit(edit)) => {
+                assert_eq!(
+                    edit.range,
+                    Range::new(Position::new(0, 7), Position::new(0, 11))
+                );
+                assert_eq!(edit.new_text, "u.name");
+            }
+            other => panic!("Expected a text edit, got {:?}", other),
+        }
+    }
+
     #[tokio::test]
     async fn test_qualified_column_completion_invalid_qualifier() {
         use unified_sql_lsp_catalog::DataType;
@@ -1277,6 +1711,45 @@ mod tests {
         assert!(items.iter().any(|i| i.label == "name"));
     }
 
+    #[tokio::test]
+    async fn test_function_completion_depends_on_snippet_support() {
+        use unified_sql_lsp_catalog::{DataType, FunctionMetadata, TableMetadata};
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog: Arc<dyn Catalog> = Arc::new(
+            MockCatalogBuilder::new()
+                .with_table(TableMetadata::new("users", "public").with_columns(vec![
+                    unified_sql_lsp_catalog::ColumnMetadata::new("id", DataType::Integer),
+                ]))
+                .with_function(
+                    FunctionMetadata::new("count", DataType::BigInt)
+                        .with_type(FunctionType::Aggregate),
+                )
+                .build(),
+        );
+        let document = create_test_document("SELECT  FROM users;", "mysql").await;
+
+        let mut rendered = Vec::new();
+        for snippets in [false, true] {
+            let engine = CompletionEngine::new(catalog.clone()).with_snippets(snippets);
+            let items = engine
+                .complete(&document, Position::new(0, 8))
+                .await
+                .unwrap()
+                .unwrap();
+            let count = items.into_iter().find(|i| i.label == "count").unwrap();
+            rendered.push((count.insert_text.unwrap(), count.insert_text_format));
+        }
+
+        assert_eq!(
+            rendered,
+            vec![
+                ("count(".to_string(), Some(InsertTextFormat::PLAIN_TEXT)),
+                ("count($1)$0".to_string(), Some(InsertTextFormat::SNIPPET)),
+            ]
+        );
+    }
+
     #[tokio::test]
     async fn test_where_clause_unqualified_completion() {
         use unified_sql_lsp_catalog::DataType;
@@ -1440,4 +1913,251 @@ mod tests {
         assert!(id_items.iter().any(|i| i.label == "users.id"));
         assert!(id_items.iter().any(|i| i.label == "orders.id"));
     }
+
+    #[tokio::test]
+    async fn test_no_keywords_after_qualifier() {
+        use unified_sql_lsp_catalog::{DataType, TableMetadata};
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog = MockCatalogBuilder::new()
+            .with_table(TableMetadata::new("users", "public").with_columns(vec![
+                unified_sql_lsp_catalog::ColumnMetadata::new("id", DataType::Integer),
+            ]))
+            .build();
+        let engine = CompletionEngine::new(Arc::new(catalog));
+
+        // Test: SELECT users.| FROM users;
+        let document = create_test_document("SELECT users. FROM users;", "mysql").await;
+        let items = engine
+            .complete(&document, Position::new(0, 13))
+            .await
+            .unwrap()
+            .unwrap_or_default();
+
+        assert!(items.iter().any(|i| i.label == "users.id"));
+        assert!(
+            !items
+                .iter()
+                .any(|i| i.kind == Some(CompletionItemKind::KEYWORD))
+        );
+    }
+
+    #[tokio::test]
+    async fn test_keywords_follow_clause_grammar() {
+        use unified_sql_lsp_catalog::{DataType, TableMetadata};
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog = MockCatalogBuilder::new()
+            .with_table(TableMetadata::new("users", "public").with_columns(vec![
+                unified_sql_lsp_catalog::ColumnMetadata::new("id", DataType::Integer),
+            ]))
+            .build();
+        let engine = CompletionEngine::new(Arc::new(catalog));
+
+        // Test: SELECT * FROM users WHERE |
+        let document = create_test_document("SELECT * FROM users WHERE ", "mysql").await;
+        let items = engine
+            .complete(&document, Position::new(0, 26))
+            .await
+            .unwrap()
+            .unwrap();
+
+        assert!(items.iter().any(|i| i.label == "id"));
+        assert!(items.iter().any(|i| i.label == "NOT"));
+        assert!(!items.iter().any(|i| i.label == "GROUP BY"));
+        assert!(!items.iter().any(|i| i.label == "AND"));
+    }
+
+    #[tokio::test]
+    async fn test_keyword_case_and_builtin_functions() {
+        use unified_sql_lsp_catalog::{DataType, TableMetadata};
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog = MockCatalogBuilder::new()
+            .with_table(TableMetadata::new("users", "public").with_columns(vec![
+                unified_sql_lsp_catalog::ColumnMetadata::new("id", DataType::Integer),
+            ]))
+            .build();
+        let engine = CompletionEngine::new(Arc::new(catalog))
+            .with_dialect(Dialect::MySQL)
+            .with_keyword_case(KeywordCase::Lower);
+
+        // Test: SELECT * FROM users WHERE |
+        let document = create_test_document("SELECT * FROM users WHERE ", "mysql").await;
+        let items = engine
+            .complete(&document, Position::new(0, 26))
+            .await
+            .unwrap()
+            .unwrap();
+
+        // Keywords follow the casing preference, columns keep their names
+        assert!(items.iter().any(|i| i.label == "not"));
+        assert!(!items.iter().any(|i| i.label == "NOT"));
+        assert!(items.iter().any(|i| i.label == "id"));
+
+        // Built-in functions are offered without catalog functions
+        assert!(items.iter().any(|i| i.label == "COUNT"));
+    }
+
+    /// Catalog whose lookups never finish
+    struct HangingCatalog;
+
+    #[async_trait::async_trait]
+    impl Catalog for HangingCatalog {
+        async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+            std::future::pending().await
+        }
+
+        async fn get_columns(&self, _table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+            std::future::pending().await
+        }
+
+        async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+            std::future::pending().await
+        }
+    }
+
+    #[tokio::test]
+    async fn test_hanging_catalog_returns_keywords_within_budget() {
+        let engine = CompletionEngine::new(Arc::new(HangingCatalog));
+        let document = create_test_document("SELECT  FROM users", "mysql").await;
+
+        let start = std::time::Instant::now();
+        let list = engine
+            .complete_within(&document, Position::new(0, 7), Duration::from_millis(50))
+            .await
+            .unwrap()
+            .unwrap();
+
+        assert!(start.elapsed() < Duration::from_secs(5));
+        assert!(list.is_incomplete);
+        assert!(
+            list.items
+                .iter()
+                .any(|i| i.kind == Some(CompletionItemKind::KEYWORD))
+        );
+    }
+
+    #[tokio::test]
+    async fn test_fast_catalog_returns_complete_list() {
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let engine = CompletionEngine::new(Arc::new(MockCatalogBuilder::new().build()));
+        let document = create_test_document("", "mysql").await;
+
+        let list = engine
+            .complete_within(&document, Position::new(0, 0), Duration::from_secs(5))
+            .await
+            .unwrap()
+            .unwrap();
+
+        assert!(!list.is_incomplete);
+        assert!(list.items.iter().any(|i| i.label == "SELECT"));
+    }
+
+    /// Catalog counting its table listings
+    struct CountingCatalog {
+        inner: Arc<dyn Catalog>,
+        list_tables_calls: std::sync::atomic::AtomicUsize,
+    }
+
+    #[async_trait::async_trait]
+    impl Catalog for CountingCatalog {
+        async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+            self.list_tables_calls
+                .fetch_add(1, std::sync::atomic::Ordering::SeqCst);
+            self.inner.list_tables().await
+        }
+
+        async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+            self.inner.get_columns(table).await
+        }
+
+        async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+            self.inner.list_functions().await
+        }
+    }
+
+    #[tokio::test]
+    async fn test_narrowing_query_is_served_from_cache() {
+        use std::sync::atomic::{AtomicUsize, Ordering};
+        use tower_lsp::lsp_types::CompletionTextEdit;
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog = Arc::new(CountingCatalog {
+            inner: Arc::new(
+                MockCatalogBuilder::new()
+                    .with_table(TableMetadata::new("users", "public"))
+                    .with_table(TableMetadata::new("orders", "public"))
+                    .build(),
+            ),
+            list_tables_calls: AtomicUsize::new(0),
+        });
+        let cache = Arc::new(CompletionCache::new());
+        let engine = CompletionEngine::new(catalog.clone()).with_cache(cache);
+
+        let document = create_test_document("SELECT * FROM u", "mysql").await;
+        let first = engine
+            .complete(&document, Position::new(0, 15))
+            .await
+            .unwrap()
+            .unwrap();
+        assert!(first.iter().any(|i| i.label == "orders"));
+        let calls = catalog.list_tables_calls.load(Ordering::SeqCst);
+
+        let document = create_test_document("SELECT * FROM us", "mysql").await;
+        let second = engine
+            .complete(&document, Position::new(0, 16))
+            .await
+            .unwrap()
+            .unwrap();
+
+        assert_eq!(catalog.list_tables_calls.load(Ordering::SeqCst), calls);
+        let users = second.iter().find(|i| i.label == "users").unwrap();
+        assert!(!second.iter().any(|i| i.label == "orders"));
+        match &users.text_edit {
+            Some(CompletionTextEdit::Edit(edit)) => assert_eq!(edit.range.start.character, 14),
+            other => panic!("unexpected text edit: {:?}", other),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_cte_and_derived_table_columns() {
+        use unified_sql_lsp_catalog::{ColumnMetadata, DataType, TableMetadata};
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let catalog = MockCatalogBuilder::new()
+            .with_table(TableMetadata::new("orders", "public").with_columns(vec![
+                ColumnMetadata::new("id", DataType::Integer),
+                ColumnMetadata::new("total", DataType::Decimal),
+                ColumnMetadata::new("created_at", DataType::Timestamp),
+            ]))
+            .build();
+        let engine = CompletionEngine::new(Arc::new(catalog));
+
+        let source =
+            "WITH recent AS (SELECT id, created_at FROM orders) SELECT recent. FROM recent";
+        let document = create_test_document(source, "mysql").await;
+        let items = engine
+            .complete(&document, Position::new(0, 65))
+            .await
+            .unwrap()
+            .unwrap();
+        let labels: Vec<&str> = items.iter().map(|i| i.label.as_str()).collect();
+        assert!(labels.contains(&"recent.id"));
+        assert!(labels.contains(&"recent.created_at"));
+        assert!(!labels.contains(&"recent.total"));
+
+        let source = "SELECT x. FROM (SELECT id, total * 2 AS doubled FROM orders) AS x";
+        let document = create_test_document(source, "mysql").await;
+        let items = engine
+            .complete(&document, Position::new(0, 9))
+            .await
+            .unwrap()
+            .unwrap();
+        let labels: Vec<&str> = items.iter().map(|i| i.label.as_str()).collect();
+        assert!(labels.contains(&"x.id"));
+        assert!(labels.contains(&"x.doubled"));
+        assert!(!labels.contains(&"x.created_at"));
+    }
 }
@@ -0,0 +1,516 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Typed prefix extraction
+//!
+//! This module finds the partially typed identifier before the cursor and
+//! attaches text edits to completion items so that accepting an item replaces
+//! exactly what the user typed.
+//!
+//! ## Examples
+//!
+//! ```text,ignore
+//! SELECT users.na|     → qualifier "users", prefix "na"
+//! SELECT "User Na|     → quote '"', prefix "User Na"
+//! SELECT `orders`.to|  → qualifier "orders", prefix "to" (MySQL)
+//! FROM shop.public.or|  → database "shop", qualifier "public", prefix "or"
+//! SELECT "a""b|        → quote '"', prefix 'a"b'
+//! ```
+//!
+//! Only the quote character of the dialect opens a quoted identifier, and
+//! quotes inside string literals are not counted.
+//!
+//! Positions are LSP positions, i.e. the character offset counts UTF-16 code
+//! units.
+
+use tower_lsp::lsp_types::{
+    CompletionItem, CompletionItemKind, CompletionTextEdit, Position, Range, TextEdit,
+};
+use unified_sql_lsp_ir::DialectRules;
+
+/// The identifier being typed at the cursor
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct TypedPrefix {
+    /// Table qualifier before the dot (unquoted), e.g. "users" in `users.na`
+    pub qualifier: Option<String>,
+
+    /// Typed part of the identifier without any opening quote, e.g. "na";
+    /// doubled quotes are unescaped
+    pub prefix: String,
+
+    /// Opening quote character if the identifier is quoted
+    pub quote: Option<char>,
+
+    /// Range of the typed identifier, including the opening quote
+    pub range: Range,
+
+    /// Range covering the qualifier, the dot and the typed identifier
+    pub qualified_range: Range,
+
+    /// Unquoted identifier before the qualifier, e.g. "shop" in `shop.public.or`
+    pub database: Option<String>,
+
+    /// Range covering the database, the qualifier and the typed identifier
+    pub database_range: Range,
+
+    /// Identifier rules the line was read with
+    pub rules: DialectRules,
+}
+
+impl TypedPrefix {
+    /// Extract the typed prefix at `position` from the given line of text
+    ///
+    /// # Arguments
+    ///
+    /// * `line_text` - The text of the line the cursor is on
+    /// * `position` - The cursor position (UTF-16 character offset)
+    /// * `rules` - Identifier rules of the dialect, giving the quote character
+    ///
+    /// # Returns
+    ///
+    /// The typed prefix; its `prefix` is empty when the cursor is not inside
+    /// or directly after an identifier.
+    pub fn at(line_text: &str, position: Position, rules: &DialectRules) -> Self {
+        // Collect characters up to the cursor, counting UTF-16 code units
+        let mut before: Vec<char> = Vec::new();
+        let mut utf16_offset = 0u32;
+        for c in line_text.chars() {
+            if c == '\n' || c == '\r' || utf16_offset >= position.character {
+                break;
+            }
+            utf16_offset += c.len_utf16() as u32;
+            before.push(c);
+        }
+
+        let cursor = utf16_offset;
+        let mut start = before.len();
+
+        let quoted = QuotedIdentifiers::scan(&before, rules.quote);
+
+        let (prefix, quote) = match quoted.open {
+            Some((open, text)) => {
+                start = open;
+                (text, Some(rules.quote))
+            }
+            None => {
+                while start > 0 && is_identifier_char(before[start - 1]) {
+                    start -= 1;
+                }
+                (before[start..].iter().collect(), None)
+            }
+        };
+
+        let range_start = utf16_len(&before[..start]);
+        let range = Range::new(
+            Position::new(position.line, range_start),
+            Position::new(position.line, cursor),
+        );
+
+        // Qualifier: identifier (optionally quoted) directly before a dot
+        let mut qualifier = None;
+        let mut qualified_start = range_start;
+        let mut qualifier_index = start;
+        if start > 0 && before[start - 1] == '.' {
+            let dot = start - 1;
+            let mut q_start = dot;
+            let q_text: Option<String> = match quoted.closed.iter().find(|q| q.1 + 1 == dot) {
+                Some((open, _, text)) => {
+                    q_start = *open;
+                    Some(text.clone())
+                }
+                None => {
+                    while q_start > 0 && is_identifier_char(before[q_start - 1]) {
+                        q_start -= 1;
+                    }
+                    (q_start < dot).then(|| before[q_start..dot].iter().collect())
+                }
+            };
+            if let Some(q) = q_text {
+                qualifier = Some(q);
+                qualified_start = utf16_len(&before[..q_start]);
+                qualifier_index = q_start;
+            }
+        }
+
+        let qualified_range = Range::new(
+            Position::new(position.line, qualified_start),
+            Position::new(position.line, cursor),
+        );
+
+        // Database: unquoted identifier directly before the qualifier's dot
+        let mut database = None;
+        let mut database_start = qualified_start;
+        if qualifier.is_some() && qualifier_index > 0 && before[qualifier_index - 1] == '.' {
+            let dot = qualifier_index - 1;
+            let mut d_start = dot;
+            while d_start > 0 && is_identifier_char(before[d_start - 1]) {
+                d_start -= 1;
+            }
+            if d_start < dot {
+                database = Some(before[d_start..dot].iter().collect());
+                database_start = utf16_len(&before[..d_start]);
+            }
+        }
+        let database_range = Range::new(
+            Position::new(position.line, database_start),
+            Position::new(position.line, cursor),
+        );
+
+        Self {
+            qualifier,
+            prefix,
+            quote,
+            range,
+            qualified_range,
+            database,
+            database_range,
+            rules: *rules,
+        }
+    }
+
+    /// Attach text edits replacing the typed prefix to completion items
+    ///
+    /// Items whose insert text carries the typed qualifier (e.g. `u.name`
+    /// after typing `u.na`) replace the qualified range; other items replace
+    /// only the typed identifier. Inside a quoted identifier, only schema
+    /// object items are edited and their label is quoted with the same quote
+    /// (doubling embedded quotes), so names the aggregator quoted are not
+    /// quoted twice.
+    pub fn apply_text_edits(&self, items: &mut [CompletionItem]) {
+        for item in items.iter_mut() {
+            if item.text_edit.is_some() {
+                continue;
+            }
+
+            let new_text = item
+                .insert_text
+                .clone()
+                .unwrap_or_else(|| item.label.clone());
+
+            if self.quote.is_some() {
+                if !is_schema_object(item) {
+                    continue;
+                }
+                let quoted = self.rules.quote(&item.label);
+                item.filter_text = Some(quoted.clone());
+                item.text_edit = Some(CompletionTextEdit::Edit(TextEdit::new(self.range, quoted)));
+                continue;
+            }
+
+            let range = match (&self.database, &self.qualifier) {
+                (Some(database), Some(qualifier))
+                    if carries_qualifier(
+                        &new_text,
+                        &format!("{database}.{qualifier}"),
+                        self.rules.quote,
+                    ) =>
+                {
+                    item.filter_text = Some(new_text.clone());
+                    self.database_range
+                }
+                (_, Some(qualifier))
+                    if carries_qualifier(&new_text, qualifier, self.rules.quote) =>
+                {
+                    item.filter_text = Some(new_text.clone());
+                    self.qualified_range
+                }
+                _ => self.range,
+            };
+
+            item.text_edit = Some(CompletionTextEdit::Edit(TextEdit::new(range, new_text)));
+        }
+    }
+}
+
+/// Quoted identifiers of the text before the cursor
+#[derive(Debug, Default)]
+struct QuotedIdentifiers {
+    /// Closed identifiers: opening and closing quote index, unescaped name
+    closed: Vec<(usize, usize, String)>,
+
+    /// Identifier still open at the cursor: opening quote index, unescaped
+    /// typed text
+    open: Option<(usize, String)>,
+}
+
+impl QuotedIdentifiers {
+    /// Scan characters for identifiers quoted with `quote`
+    ///
+    /// String literals are skipped, and a doubled quote inside a quoted
+    /// identifier is read as one quote character.
+    fn scan(chars: &[char], quote: char) -> Self {
+        let mut quoted = Self::default();
+        let mut in_string = false;
+        let mut i = 0;
+        while i < chars.len() {
+            let c = chars[i];
+            if let Some((open, text)) = quoted.open.as_mut() {
+                if c != quote {
+                    text.push(c);
+                } else if chars.get(i + 1) == Some(&quote) {
+                    text.push(quote);
+                    i += 1;
+                } else {
+                    let name = std::mem::take(text);
+                    quoted.closed.push((*open, i, name));
+                    quoted.open = None;
+                }
+            } else if in_string {
+                // A doubled quote ('') closes and reopens the literal
+                in_string = c != '\'';
+            } else if c == '\'' {
+                in_string = true;
+            } else if c == quote {
+                quoted.open = Some((i, String::new()));
+            }
+            i += 1;
+        }
+        quoted
+    }
+}
+
+/// Check whether a character can be part of an unquoted identifier
+fn is_identifier_char(c: char) -> bool {
+    c.is_alphanumeric() || c == '_' || c == '$'
+}
+
+/// Length of a character slice in UTF-16 code units
+fn utf16_len(chars: &[char]) -> u32 {
+    chars.iter().map(|c| c.len_utf16() as u32).sum()
+}
+
+/// Check whether `text` starts with `qualifier.` (case-insensitive, `quote`
+/// characters ignored)
+fn carries_qualifier(text: &str, qualifier: &str, quote: char) -> bool {
+    let text: String = text.chars().filter(|c| *c != quote).collect();
+    text.get(..qualifier.len())
+        .is_some_and(|head| head.eq_ignore_ascii_case(qualifier))
+        && text[qualifier.len()..].starts_with('.')
+}
+
+/// Check whether a completion item names a schema object (column, table, ...)
+pub(crate) fn is_schema_object(item: &CompletionItem) -> bool {
+    matches!(
+        item.kind,
+        Some(CompletionItemKind::FIELD) | Some(CompletionItemKind::CLASS)
+    ) && item.label != "*"
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_ir::Dialect;
+
+    fn prefix_at(line_text: &str, character: u32) -> TypedPrefix {
+        TypedPrefix::at(
+            line_text,
+            Position::new(0, character),
+            &DialectRules::default(),
+        )
+    }
+
+    fn range(start: u32, end: u32) -> Range {
+        Range::new(Position::new(0, start), Position::new(0, end))
+    }
+
+    fn item(label: &str, kind: CompletionItemKind) -> CompletionItem {
+        CompletionItem {
+            label: label.to_string(),
+            kind: Some(kind),
+            insert_text: Some(label.to_string()),
+            ..Default::default()
+        }
+    }
+
+    fn edit_of(item: &CompletionItem) -> &TextEdit {
+        match item.text_edit.as_ref().expect("text edit") {
+            CompletionTextEdit::Edit(edit) => edit,
+            other => panic!("unexpected edit {:?}", other),
+        }
+    }
+
+    #[test]
+    fn test_prefix_after_space() {
+        let typed = prefix_at("SELECT ", 7);
+        assert_eq!(typed.prefix, "");
+        assert_eq!(typed.qualifier, None);
+        assert_eq!(typed.range, range(7, 7));
+    }
+
+    #[test]
+    fn test_prefix_plain_identifier() {
+        let typed = prefix_at("SELECT na", 9);
+        assert_eq!(typed.prefix, "na");
+        assert_eq!(typed.qualifier, None);
+        assert_eq!(typed.range, range(7, 9));
+    }
+
+    #[test]
+    fn test_prefix_after_alias_dot() {
+        let typed = prefix_at("SELECT u.na FROM users u", 11);
+        assert_eq!(typed.qualifier.as_deref(), Some("u"));
+        assert_eq!(typed.prefix, "na");
+        assert_eq!(typed.range, range(9, 11));
+        assert_eq!(typed.qualified_range, range(7, 11));
+    }
+
+    #[test]
+    fn test_prefix_directly_after_dot() {
+        let typed = prefix_at("SELECT users.", 13);
+        assert_eq!(typed.qualifier.as_deref(), Some("users"));
+        assert_eq!(typed.prefix, "");
+        assert_eq!(typed.range, range(13, 13));
+        assert_eq!(typed.qualified_range, range(7, 13));
+    }
+
+    #[test]
+    fn test_prefix_mid_identifier() {
+        // Cursor between "us" and "ers": only the part before the cursor is the prefix
+        let typed = prefix_at("SELECT * FROM users", 16);
+        assert_eq!(typed.prefix, "us");
+        assert_eq!(typed.range, range(14, 16));
+    }
+
+    #[test]
+    fn test_prefix_quoted_identifier() {
+        let typed = prefix_at("SELECT \"User Na", 15);
+        assert_eq!(typed.quote, Some('"'));
+        assert_eq!(typed.prefix, "User Na");
+        assert_eq!(typed.range, range(7, 15));
+    }
+
+    #[test]
+    fn test_prefix_quoted_qualifier() {
+        let typed = prefix_at("SELECT \"User Name\".i", 20);
+        assert_eq!(typed.qualifier.as_deref(), Some("User Name"));
+        assert_eq!(typed.prefix, "i");
+        assert_eq!(typed.quote, None);
+        assert_eq!(typed.qualified_range, range(7, 20));
+
+        let mysql = Dialect::MySQL.rules();
+        let typed = TypedPrefix::at("SELECT `orders`.to", Position::new(0, 18), &mysql);
+        assert_eq!(typed.qualifier.as_deref(), Some("orders"));
+        assert_eq!(typed.prefix, "to");
+    }
+
+    #[test]
+    fn test_prefix_skips_quotes_in_string_literals() {
+        let typed = prefix_at("SELECT * FROM t WHERE note = 'say \"hi' AND na", 45);
+        assert_eq!(typed.quote, None);
+        assert_eq!(typed.prefix, "na");
+        assert_eq!(typed.range, range(43, 45));
+
+        let typed = prefix_at("SELECT 'it''s \"', \"Ord", 22);
+        assert_eq!(typed.quote, Some('"'));
+        assert_eq!(typed.prefix, "Ord");
+        assert_eq!(typed.range, range(18, 22));
+    }
+
+    #[test]
+    fn test_prefix_doubled_quotes() {
+        let typed = prefix_at("SELECT \"a\"\"b", 12);
+        assert_eq!(typed.quote, Some('"'));
+        assert_eq!(typed.prefix, "a\"b");
+        assert_eq!(typed.range, range(7, 12));
+
+        let typed = prefix_at("SELECT \"a\"\"b\".c", 15);
+        assert_eq!(typed.qualifier.as_deref(), Some("a\"b"));
+        assert_eq!(typed.qualified_range, range(7, 15));
+    }
+
+    #[test]
+    fn test_prefix_utf16_offsets() {
+        // "é" is one UTF-16 unit, "😀" is two
+        let typed = prefix_at("SELECT '😀', é", 14);
+        assert_eq!(typed.prefix, "é");
+        assert_eq!(typed.range, range(13, 14));
+    }
+
+    #[test]
+    fn test_apply_text_edits_replaces_prefix() {
+        let typed = prefix_at("SELECT na", 9);
+        let mut items = vec![item("name", CompletionItemKind::FIELD)];
+        typed.apply_text_edits(&mut items);
+
+        let edit = edit_of(&items[0]);
+        assert_eq!(edit.range, range(7, 9));
+        assert_eq!(edit.new_text, "name");
+    }
+
+    #[test]
+    fn test_apply_text_edits_qualified_item() {
+        let typed = prefix_at("SELECT u.na", 11);
+        let mut items = vec![item("u.name", CompletionItemKind::FIELD)];
+        typed.apply_text_edits(&mut items);
+
+        let edit = edit_of(&items[0]);
+        assert_eq!(edit.range, range(7, 11));
+        assert_eq!(edit.new_text, "u.name");
+        assert_eq!(items[0].filter_text.as_deref(), Some("u.name"));
+    }
+
+    #[test]
+    fn test_apply_text_edits_database_qualified_item() {
+        let typed = prefix_at("SELECT * FROM analytics.public.or", 33);
+        assert_eq!(typed.database.as_deref(), Some("analytics"));
+        assert_eq!(typed.qualifier.as_deref(), Some("public"));
+        assert_eq!(typed.database_range, range(14, 33));
+
+        let mut items = vec![
+            item("analytics.public.orders", CompletionItemKind::CLASS),
+            item("public.orders", CompletionItemKind::CLASS),
+        ];
+        typed.apply_text_edits(&mut items);
+
+        assert_eq!(edit_of(&items[0]).range, range(14, 33));
+        assert_eq!(edit_of(&items[1]).range, range(24, 33));
+    }
+
+    #[test]
+    fn test_apply_text_edits_quoted_prefix() {
+        let typed = prefix_at("SELECT \"na", 10);
+        let mut items = vec![
+            item("name", CompletionItemKind::FIELD),
+            item("DISTINCT", CompletionItemKind::KEYWORD),
+        ];
+        typed.apply_text_edits(&mut items);
+
+        let edit = edit_of(&items[0]);
+        assert_eq!(edit.range, range(7, 10));
+        assert_eq!(edit.new_text, "\"name\"");
+        assert!(items[1].text_edit.is_none());
+    }
+
+    #[test]
+    fn test_apply_text_edits_escapes_embedded_quotes() {
+        let typed = prefix_at("SELECT \"sa", 10);
+        let mut items = vec![item("say \"hi\"", CompletionItemKind::FIELD)];
+        typed.apply_text_edits(&mut items);
+
+        assert_eq!(edit_of(&items[0]).new_text, "\"say \"\"hi\"\"\"");
+    }
+
+    #[test]
+    fn test_apply_text_edits_names_quoted_by_the_aggregator() {
+        let quoted = |label: &str, insert_text: &str| CompletionItem {
+            insert_text: Some(insert_text.to_string()),
+            ..item(label, CompletionItemKind::FIELD)
+        };
+
+        // Already inside quotes: the label is quoted once
+        let typed = prefix_at("SELECT \"Ord", 11);
+        let mut items = vec![quoted("OrderId", "\"OrderId\"")];
+        typed.apply_text_edits(&mut items);
+        assert_eq!(edit_of(&items[0]).new_text, "\"OrderId\"");
+
+        // Qualified by a quoted table name
+        let typed = prefix_at("SELECT \"Line Items\".qu", 22);
+        let mut items = vec![quoted("Line Items.qty", "\"Line Items\".qty")];
+        typed.apply_text_edits(&mut items);
+        let edit = edit_of(&items[0]);
+        assert_eq!(edit.range, range(7, 22));
+        assert_eq!(edit.new_text, "\"Line Items\".qty");
+    }
+}
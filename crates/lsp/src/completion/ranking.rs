@@ -0,0 +1,186 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Completion ranking
+//!
+//! This module implements the single sort text scheme applied to every
+//! completion item before it is returned:
+//!
+//! ```text
+//! <two-digit bucket>_<normalized key>
+//! 10_00_pk_id           column (primary key)
+//! 20_public_users       table
+//! 30_00_aggregate_count function
+//! 40_00001_and          keyword
+//! ```
+//!
+//! The key is the sort text set by the renderer (which orders items within
+//! their bucket, e.g. primary keys before other columns) or the label,
+//! lower-cased so that ties break alphabetically regardless of case. Items
+//! are also returned in that order, so clients ignoring `sortText` see the
+//! same ranking.
+
+use tower_lsp::lsp_types::CompletionItem;
+use tower_lsp::lsp_types::CompletionItemKind;
+
+/// Priority bucket of a completion item, lowest first
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord)]
+pub enum SortBucket {
+    /// Columns of the tables in scope, JOIN conditions and `*`
+    Column = 10,
+
+    /// Tables, views and CTEs
+    Table = 20,
+
+    /// Functions and other schema objects
+    SchemaObject = 30,
+
+    /// SQL keywords
+    Keyword = 40,
+
+    /// Snippets
+    Snippet = 50,
+
+    /// Items of any other kind
+    Custom = 60,
+}
+
+impl SortBucket {
+    /// Classify a completion item
+    pub fn of(item: &CompletionItem) -> Self {
+        match item.kind {
+            Some(CompletionItemKind::FIELD) | Some(CompletionItemKind::REFERENCE) => Self::Column,
+            // Functions are rendered as CLASS items; their detail is the signature
+            Some(CompletionItemKind::CLASS) if is_function(item) => Self::SchemaObject,
+            Some(CompletionItemKind::CLASS)
+            | Some(CompletionItemKind::STRUCT)
+            | Some(CompletionItemKind::VARIABLE) => Self::Table,
+            Some(CompletionItemKind::FUNCTION)
+            | Some(CompletionItemKind::METHOD)
+            | Some(CompletionItemKind::MODULE) => Self::SchemaObject,
+            Some(CompletionItemKind::KEYWORD) | Some(CompletionItemKind::OPERATOR) => Self::Keyword,
+            Some(CompletionItemKind::SNIPPET) => Self::Snippet,
+            _ => Self::Custom,
+        }
+    }
+
+    /// Sort text of an item in this bucket
+    ///
+    /// # Arguments
+    ///
+    /// * `key` - Order of the item within the bucket
+    pub fn sort_text(self, key: &str) -> String {
+        format!("{:02}_{}", self as u8, key.to_lowercase())
+    }
+}
+
+/// Assign the sort text of every item and order the items by it
+///
+/// Ranking is idempotent: items already ranked keep their sort text.
+pub fn rank(items: &mut [CompletionItem]) {
+    for item in items.iter_mut() {
+        let bucket = SortBucket::of(item);
+        let key = item.sort_text.as_deref().unwrap_or(&item.label);
+        let prefix = bucket.sort_text("");
+        if !key.starts_with(&prefix) {
+            item.sort_text = Some(bucket.sort_text(key));
+        }
+    }
+
+    items.sort_by(|a, b| {
+        a.sort_text
+            .cmp(&b.sort_text)
+            .then_with(|| a.label.cmp(&b.label))
+    });
+}
+
+/// Check whether a CLASS item is a function (`detail` is its signature)
+fn is_function(item: &CompletionItem) -> bool {
+    item.detail
+        .as_deref()
+        .and_then(|detail| detail.strip_prefix(item.label.as_str()))
+        .is_some_and(|rest| rest.starts_with('('))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::completion::render::CompletionRenderer;
+    use unified_sql_lsp_catalog::{DataType, FunctionMetadata, FunctionType, TableMetadata};
+    use unified_sql_lsp_context::SqlKeyword;
+    use unified_sql_lsp_semantic::{ColumnSymbol, TableSymbol};
+
+    fn labels(items: &[CompletionItem]) -> Vec<&str> {
+        items.iter().map(|item| item.label.as_str()).collect()
+    }
+
+    #[test]
+    fn test_select_projection_golden_order() {
+        let users = TableSymbol::new("users").with_columns(vec![
+            ColumnSymbol::new("name", DataType::Text, "users"),
+            ColumnSymbol::new("id", DataType::Integer, "users"),
+        ]);
+        let functions = vec![
+            FunctionMetadata::new("UPPER", DataType::Text),
+            FunctionMetadata::new("COUNT", DataType::BigInt).with_type(FunctionType::Aggregate),
+        ];
+        let keywords = vec![
+            SqlKeyword::new("FROM", None, 2),
+            SqlKeyword::new("DISTINCT", None, 1),
+        ];
+
+        let mut items = CompletionRenderer::render_keywords(&keywords);
+        items.extend(CompletionRenderer::render_functions(
+            &functions, None, false,
+        ));
+        items.extend(CompletionRenderer::render_columns(&[users], false));
+        items.push(CompletionRenderer::wildcard_item());
+        rank(&mut items);
+
+        assert_eq!(
+            labels(&items),
+            vec!["*", "id", "name", "COUNT", "UPPER", "DISTINCT", "FROM"]
+        );
+    }
+
+    #[test]
+    fn test_from_clause_golden_order() {
+        let tables = vec![
+            TableMetadata::new("orders", "public"),
+            TableMetadata::new("Accounts", "public"),
+        ];
+        let mut items = CompletionRenderer::render_keywords(&[SqlKeyword::new("JOIN", None, 1)]);
+        items.extend(CompletionRenderer::render_tables(&tables, false));
+        rank(&mut items);
+
+        assert_eq!(labels(&items), vec!["Accounts", "orders", "JOIN"]);
+        assert_eq!(items[0].sort_text.as_deref(), Some("20_public_accounts"));
+    }
+
+    #[test]
+    fn test_ties_break_case_insensitively() {
+        let mut items: Vec<CompletionItem> = ["beta", "Alpha", "gamma"]
+            .into_iter()
+            .map(|label| CompletionItem {
+                label: label.to_string(),
+                kind: Some(CompletionItemKind::SNIPPET),
+                ..Default::default()
+            })
+            .collect();
+        rank(&mut items);
+
+        assert_eq!(labels(&items), vec!["Alpha", "beta", "gamma"]);
+    }
+
+    #[test]
+    fn test_rank_is_idempotent() {
+        let mut items = CompletionRenderer::render_keywords(&[SqlKeyword::new("AND", None, 1)]);
+        rank(&mut items);
+        let ranked = items.clone();
+        rank(&mut items);
+
+        assert_eq!(items, ranked);
+    }
+}
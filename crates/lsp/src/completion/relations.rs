@@ -0,0 +1,1291 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Relations defined by a statement
+//!
+//! This module infers the columns of the relations a statement defines for
+//! itself, so they complete like the columns of a table:
+//!
+//! ```text,ignore
+//! WITH recent AS (SELECT id, created_at FROM orders)
+//! SELECT recent.|                                  → id, created_at
+//!
+//! SELECT x.| FROM (SELECT id, total * 2 AS doubled FROM orders) AS x
+//!                                                  → id, doubled
+//!
+//! SELECT v.| FROM (VALUES (1, 'a')) AS v (id, name) → id, name
+//! ```
+//!
+//! Columns are named after the select list: the column referenced, the
+//! alias, or for other expressions the name the database gives them
+//! (`?column?` or the function name in PostgreSQL, `expr_N` elsewhere). `*`
+//! expands to the columns of the sources, which may be tables or relations
+//! defined earlier in the statement. A column list (`recent (a, b) AS ...`)
+//! renames the columns, and set operations take the names of their first
+//! query.
+//!
+//! Inference works on tokens rather than on the syntax tree, so it also
+//! serves statements that are still being typed.
+//!
+//! A CTE is visible after its definition, in the rest of its statement; a
+//! derived table or `VALUES` list in the query whose `FROM` clause defines
+//! it. Neither is visible inside its own definition, except for recursive
+//! CTEs.
+
+use async_trait::async_trait;
+use std::collections::HashMap;
+use std::ops::Range as Span;
+use std::sync::Arc;
+use tower_lsp::lsp_types::{Position, Range};
+use tracing::debug;
+use unified_sql_lsp_catalog::{
+    Catalog, CatalogResult, ColumnMetadata, DataType, FunctionMetadata, TableMetadata, TableVersion,
+};
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_ir::dialect::DialectFamily;
+use unified_sql_lsp_semantic::{ColumnSymbol, TableSymbol};
+
+use crate::migrations::{Token, TokenKind, tokenize, unknown_type};
+
+/// Keywords ending a select list
+const SELECT_LIST_END: [&str; 14] = [
+    "FROM",
+    "INTO",
+    "WHERE",
+    "GROUP",
+    "HAVING",
+    "WINDOW",
+    "ORDER",
+    "LIMIT",
+    "OFFSET",
+    "FETCH",
+    "FOR",
+    "UNION",
+    "INTERSECT",
+    "EXCEPT",
+];
+
+/// Keywords of set operations
+const SET_OPERATORS: [&str; 3] = ["UNION", "INTERSECT", "EXCEPT"];
+
+/// Modifiers between `SELECT` and its select list
+const SELECT_MODIFIERS: [&str; 7] = [
+    "ALL",
+    "DISTINCT",
+    "DISTINCTROW",
+    "HIGH_PRIORITY",
+    "STRAIGHT_JOIN",
+    "SQL_NO_CACHE",
+    "SQL_CALC_FOUND_ROWS",
+];
+
+/// Keywords joining the items of a `FROM` clause
+const JOIN_WORDS: [&str; 10] = [
+    "JOIN",
+    "INNER",
+    "LEFT",
+    "RIGHT",
+    "FULL",
+    "OUTER",
+    "CROSS",
+    "NATURAL",
+    "STRAIGHT_JOIN",
+    "LATERAL",
+];
+
+/// Keywords that are never an alias
+const RESERVED: [&str; 58] = [
+    "SELECT",
+    "WITH",
+    "VALUES",
+    "FROM",
+    "INTO",
+    "WHERE",
+    "GROUP",
+    "HAVING",
+    "WINDOW",
+    "ORDER",
+    "LIMIT",
+    "OFFSET",
+    "FETCH",
+    "FOR",
+    "UNION",
+    "INTERSECT",
+    "EXCEPT",
+    "JOIN",
+    "INNER",
+    "LEFT",
+    "RIGHT",
+    "FULL",
+    "OUTER",
+    "CROSS",
+    "NATURAL",
+    "STRAIGHT_JOIN",
+    "LATERAL",
+    "ON",
+    "USING",
+    "AS",
+    "AND",
+    "OR",
+    "NOT",
+    "IS",
+    "IN",
+    "LIKE",
+    "BETWEEN",
+    "CASE",
+    "WHEN",
+    "THEN",
+    "ELSE",
+    "END",
+    "NULL",
+    "TRUE",
+    "FALSE",
+    "COLLATE",
+    "DISTINCT",
+    "ALL",
+    "USE",
+    "FORCE",
+    "IGNORE",
+    "SET",
+    "RETURNING",
+    "TABLESAMPLE",
+    "ONLY",
+    "BY",
+    "RECURSIVE",
+    "ROW",
+];
+
+/// Kind of relation defined by a statement
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum RelationKind {
+    /// Common table expression (`WITH name AS (...)`)
+    Cte,
+    /// Subquery in a `FROM` clause (`(SELECT ...) AS name`)
+    Derived,
+    /// `VALUES` list in a `FROM` clause (`(VALUES ...) AS name (a, b)`)
+    Values,
+}
+
+/// Relation defined by a statement, with its inferred columns
+#[derive(Debug, Clone, PartialEq)]
+pub struct StatementRelation {
+    /// Name of the relation (CTE name or alias)
+    pub name: String,
+
+    /// Kind of relation
+    pub kind: RelationKind,
+
+    /// Inferred columns, in order
+    pub columns: Vec<ColumnMetadata>,
+
+    /// Text where the relation can be referenced
+    scope: Range,
+
+    /// Text of the definition, where it cannot (`None` for recursive CTEs)
+    body: Option<Range>,
+}
+
+impl StatementRelation {
+    /// Check whether the relation can be referenced at a position
+    pub fn is_visible_at(&self, position: Position) -> bool {
+        let within = |range: &Range| range.start <= position && position <= range.end;
+        within(&self.scope) && !self.body.as_ref().is_some_and(within)
+    }
+
+    /// The relation as a table of the completion scope
+    pub fn to_symbol(&self) -> TableSymbol {
+        TableSymbol::new(&self.name).with_columns(
+            self.columns
+                .iter()
+                .map(|column| {
+                    ColumnSymbol::new(
+                        column.name.clone(),
+                        column.data_type.clone(),
+                        self.name.clone(),
+                    )
+                    .with_primary_key_if(column.is_primary_key)
+                    .with_foreign_key_if(column.is_foreign_key)
+                    .with_references(column.references.clone())
+                    .with_comment(column.comment.clone())
+                })
+                .collect(),
+        )
+    }
+}
+
+/// Relations defined by the statement at a cursor
+#[derive(Debug, Clone, Default)]
+pub struct StatementRelations {
+    relations: Vec<StatementRelation>,
+    cursor: Position,
+}
+
+impl StatementRelations {
+    /// Infer the relations defined by the statement at a cursor
+    ///
+    /// # Arguments
+    ///
+    /// * `source` - Text of the document
+    /// * `cursor` - Position of the cursor
+    /// * `dialect` - Dialect naming unnamed columns
+    /// * `catalog` - Catalog of the tables the relations select from
+    pub async fn infer(
+        source: &str,
+        cursor: Position,
+        dialect: Dialect,
+        catalog: &dyn Catalog,
+    ) -> Self {
+        let tokens = tokenize(source);
+        let (statement, end) = statement_at(&tokens, cursor, source);
+
+        // The first pass finds the tables whose columns are needed
+        let schema = HashMap::new();
+        let first = Inference::run(statement, end, dialect, &schema);
+        if first.relations.is_empty() {
+            return Self::default();
+        }
+
+        let mut schema = HashMap::new();
+        for reference in &first.wanted {
+            match catalog.get_columns(reference).await {
+                Ok(columns) => {
+                    schema.insert(reference.to_lowercase(), columns);
+                }
+                Err(e) => debug!(table = %reference, error = %e, "Table of a relation not found"),
+            }
+        }
+        let relations = match schema.is_empty() {
+            true => first.relations,
+            false => Inference::run(statement, end, dialect, &schema).relations,
+        };
+        debug!(count = relations.len(), "Inferred statement relations");
+
+        Self { relations, cursor }
+    }
+
+    /// Check whether the statement defines no relation
+    pub fn is_empty(&self) -> bool {
+        self.relations.is_empty()
+    }
+
+    /// Relations visible at the cursor
+    pub fn visible(&self) -> impl Iterator<Item = &StatementRelation> {
+        self.relations
+            .iter()
+            .filter(|relation| relation.is_visible_at(self.cursor))
+    }
+
+    /// Find the relation a name refers to at the cursor
+    ///
+    /// An inner relation shadows an outer one of the same name.
+    pub fn find(&self, name: &str) -> Option<&StatementRelation> {
+        self.visible()
+            .filter(|relation| relation.name.eq_ignore_ascii_case(name))
+            .max_by_key(|relation| relation.scope.start)
+    }
+
+    /// Catalog serving the relations visible at the cursor as tables
+    pub fn catalog(&self, base: Arc<dyn Catalog>) -> Arc<dyn Catalog> {
+        Arc::new(RelationCatalog {
+            base,
+            relations: self.visible().cloned().collect(),
+        })
+    }
+}
+
+/// Catalog serving relations of a statement on top of a schema
+///
+/// Relations shadow tables of the same name, as they do in the database,
+/// but are not listed: they are not tables to complete in `FROM` clauses.
+struct RelationCatalog {
+    base: Arc<dyn Catalog>,
+    relations: Vec<StatementRelation>,
+}
+
+#[async_trait]
+impl Catalog for RelationCatalog {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        self.base.list_tables().await
+    }
+
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        let relation = self
+            .relations
+            .iter()
+            .filter(|relation| relation.name.eq_ignore_ascii_case(table))
+            .max_by_key(|relation| relation.scope.start);
+        match relation {
+            Some(relation) => Ok(relation.columns.clone()),
+            None => self.base.get_columns(table).await,
+        }
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        self.base.list_functions().await
+    }
+
+    async fn table_versions(&self) -> CatalogResult<Option<Vec<TableVersion>>> {
+        self.base.table_versions().await
+    }
+}
+
+/// Tokens of the statement at a cursor, and the position where it ends
+fn statement_at<'a>(
+    tokens: &'a [Token],
+    cursor: Position,
+    source: &str,
+) -> (&'a [Token], Position) {
+    let mut start = 0;
+    for (index, token) in tokens.iter().enumerate() {
+        if token.kind == TokenKind::Symbol(';') {
+            if cursor <= token.range.start {
+                return (&tokens[start..index], token.range.start);
+            }
+            start = index + 1;
+        }
+    }
+    (&tokens[start..], end_position(source))
+}
+
+/// Position of the end of a text
+fn end_position(source: &str) -> Position {
+    let line = source.split('\n').count() - 1;
+    let last = source.rsplit('\n').next().unwrap_or_default();
+    Position::new(line as u32, last.encode_utf16().count() as u32)
+}
+
+/// Visible CTEs and their columns, innermost last
+type Ctes = Vec<(String, Vec<ColumnMetadata>)>;
+
+/// Source of the columns of a select list
+struct Source {
+    name: String,
+    columns: Vec<ColumnMetadata>,
+}
+
+/// Recursive-descent inference over the tokens of one statement
+///
+/// Every method takes a span of token indices; spans may run past the end
+/// of an unclosed parenthesis.
+struct Inference<'a> {
+    tokens: &'a [Token],
+    /// Index of the closing parenthesis of each opening one
+    closing: HashMap<usize, usize>,
+    /// Position where the statement ends
+    end: Position,
+    dialect: Dialect,
+    /// Columns of the tables selected from, keyed by lowercase reference
+    schema: &'a HashMap<String, Vec<ColumnMetadata>>,
+    /// Tables selected from that are missing from `schema`
+    wanted: Vec<String>,
+    relations: Vec<StatementRelation>,
+}
+
+impl<'a> Inference<'a> {
+    fn run(
+        tokens: &'a [Token],
+        end: Position,
+        dialect: Dialect,
+        schema: &'a HashMap<String, Vec<ColumnMetadata>>,
+    ) -> Self {
+        let mut closing = HashMap::new();
+        let mut open = Vec::new();
+        for (index, token) in tokens.iter().enumerate() {
+            match token.kind {
+                TokenKind::Symbol('(') => open.push(index),
+                TokenKind::Symbol(')') => {
+                    if let Some(start) = open.pop() {
+                        closing.insert(start, index);
+                    }
+                }
+                _ => {}
+            }
+        }
+
+        let mut inference = Self {
+            tokens,
+            closing,
+            end,
+            dialect,
+            schema,
+            wanted: Vec::new(),
+            relations: Vec::new(),
+        };
+        inference.statement(0..tokens.len());
+        inference
+    }
+
+    fn is_keyword(&self, index: usize, keyword: &str) -> bool {
+        self.tokens
+            .get(index)
+            .is_some_and(|token| token.is_keyword(keyword))
+    }
+
+    fn is_any_keyword(&self, index: usize, keywords: &[&str]) -> bool {
+        keywords
+            .iter()
+            .any(|keyword| self.is_keyword(index, keyword))
+    }
+
+    fn is_symbol(&self, index: usize, symbol: char) -> bool {
+        self.tokens
+            .get(index)
+            .is_some_and(|token| token.kind == TokenKind::Symbol(symbol))
+    }
+
+    /// Index of the parenthesis closing the one at `open`
+    fn close(&self, open: usize) -> usize {
+        self.closing
+            .get(&open)
+            .copied()
+            .unwrap_or(self.tokens.len())
+    }
+
+    /// Index of the token following the one at `index` and its parentheses
+    fn next(&self, index: usize) -> usize {
+        match self.is_symbol(index, '(') {
+            true => self.close(index) + 1,
+            false => index + 1,
+        }
+    }
+
+    /// Indices of the tokens of a span outside parentheses
+    fn top_level(&self, span: Span<usize>) -> Vec<usize> {
+        let mut indices = Vec::new();
+        let mut index = span.start;
+        while index < span.end {
+            indices.push(index);
+            index = self.next(index);
+        }
+        indices
+    }
+
+    /// First top-level keyword of a span among `keywords`
+    fn find_keyword(&self, span: Span<usize>, keywords: &[&str]) -> Option<usize> {
+        self.top_level(span)
+            .into_iter()
+            .find(|&index| self.is_any_keyword(index, keywords))
+    }
+
+    /// Split a span at its top-level commas
+    fn split(&self, span: Span<usize>) -> Vec<Span<usize>> {
+        let mut items = Vec::new();
+        let mut start = span.start;
+        for index in self.top_level(span.clone()) {
+            if self.is_symbol(index, ',') {
+                items.push(start..index);
+                start = index + 1;
+            }
+        }
+        items.push(start..span.end.max(start));
+        items.retain(|item| !item.is_empty());
+        items
+    }
+
+    /// Position where the token at `index` starts
+    fn start_of(&self, index: usize) -> Position {
+        self.tokens
+            .get(index)
+            .map_or(self.end, |token| token.range.start)
+    }
+
+    /// Position where the token at `index` ends
+    fn end_of(&self, index: usize) -> Position {
+        self.tokens
+            .get(index)
+            .map_or(self.end, |token| token.range.end)
+    }
+
+    /// Name at `index`: a quoted identifier or a word that is no keyword
+    fn name_at(&self, index: usize) -> Option<String> {
+        let token = self.tokens.get(index)?;
+        let name = match token.kind {
+            TokenKind::Quoted => true,
+            TokenKind::Word => {
+                !token.text.starts_with(|c: char| c.is_ascii_digit())
+                    && !RESERVED.iter().any(|keyword| token.is_keyword(keyword))
+            }
+            _ => false,
+        };
+        name.then(|| token.text.clone())
+    }
+
+    /// Names of a comma-separated list
+    fn names(&self, span: Span<usize>) -> Vec<String> {
+        self.split(span)
+            .into_iter()
+            .filter_map(|item| self.name_at(item.start))
+            .collect()
+    }
+
+    /// Check whether a query starts at `index`
+    fn starts_query(&self, index: usize) -> bool {
+        self.is_any_keyword(index, &["SELECT", "WITH", "VALUES"])
+            || (self.is_symbol(index, '(') && self.starts_query(index + 1))
+    }
+
+    /// Any statement: queries, and statements embedding them
+    /// (`INSERT ... SELECT`, `CREATE VIEW ... AS`, subqueries)
+    fn statement(&mut self, span: Span<usize>) {
+        if self.starts_query(span.start) {
+            self.query(span, &Vec::new());
+            return;
+        }
+        match self.find_keyword(span.clone(), &["SELECT", "WITH", "VALUES"]) {
+            Some(index) => {
+                self.subqueries(span.start..index, &Vec::new());
+                self.query(index..span.end, &Vec::new());
+            }
+            None => self.subqueries(span, &Vec::new()),
+        }
+    }
+
+    /// Infer the relations of the subqueries of a span
+    fn subqueries(&mut self, span: Span<usize>, ctes: &Ctes) {
+        for index in self.top_level(span.clone()) {
+            if !self.is_symbol(index, '(') {
+                continue;
+            }
+            let close = self.close(index).min(span.end);
+            match self.starts_query(index + 1) {
+                true => {
+                    self.query(index + 1..close, ctes);
+                }
+                false => self.subqueries(index + 1..close, ctes),
+            }
+        }
+    }
+
+    /// `[WITH [RECURSIVE] ctes] body`
+    ///
+    /// # Returns
+    ///
+    /// The columns of the query
+    fn query(&mut self, span: Span<usize>, ctes: &Ctes) -> Vec<ColumnMetadata> {
+        if !self.is_keyword(span.start, "WITH") {
+            return self.body(span, ctes);
+        }
+
+        let mut index = span.start + 1;
+        let recursive = self.is_keyword(index, "RECURSIVE");
+        if recursive {
+            index += 1;
+        }
+
+        let mut ctes = ctes.clone();
+        while index < span.end {
+            // name [(columns)] AS [NOT] [MATERIALIZED] (query)
+            let Some(name) = self.name_at(index) else {
+                break;
+            };
+            index += 1;
+            let mut names = Vec::new();
+            if self.is_symbol(index, '(') {
+                let close = self.close(index);
+                names = self.names(index + 1..close);
+                index = close + 1;
+            }
+            if !self.is_keyword(index, "AS") {
+                break;
+            }
+            index += 1;
+            for modifier in ["NOT", "MATERIALIZED"] {
+                if self.is_keyword(index, modifier) {
+                    index += 1;
+                }
+            }
+            if !self.is_symbol(index, '(') {
+                break;
+            }
+
+            let open = index;
+            let close = self.close(open).min(span.end);
+            let columns = match recursive {
+                true => {
+                    // The recursive reference sees the columns of the column list
+                    let placeholder = names
+                        .iter()
+                        .map(|name| ColumnMetadata::new(name, unknown_type()))
+                        .collect();
+                    let mut inner = ctes.clone();
+                    inner.push((name.clone(), placeholder));
+                    self.query(open + 1..close, &inner)
+                }
+                false => self.query(open + 1..close, &ctes),
+            };
+            let columns = rename(columns, &names);
+
+            self.relations.push(StatementRelation {
+                name: name.clone(),
+                kind: RelationKind::Cte,
+                columns: columns.clone(),
+                scope: Range::new(
+                    match recursive {
+                        true => self.end_of(open),
+                        false => self.end_of(close),
+                    },
+                    self.start_of(span.end),
+                ),
+                body: (!recursive).then(|| Range::new(self.end_of(open), self.start_of(close))),
+            });
+            ctes.push((name, columns));
+
+            index = close + 1;
+            if !self.is_symbol(index, ',') {
+                break;
+            }
+            index += 1;
+        }
+
+        self.body(index.min(span.end)..span.end, &ctes)
+    }
+
+    /// Queries joined by set operations, named after the first one
+    fn body(&mut self, span: Span<usize>, ctes: &Ctes) -> Vec<ColumnMetadata> {
+        let mut branches = Vec::new();
+        let mut start = span.start;
+        for index in self.top_level(span.clone()) {
+            if self.is_any_keyword(index, &SET_OPERATORS) {
+                branches.push(start..index);
+                start = index + 1;
+                if self.is_any_keyword(start, &["ALL", "DISTINCT"]) {
+                    start += 1;
+                }
+            }
+        }
+        branches.push(start.min(span.end)..span.end);
+
+        let mut columns = None;
+        for branch in branches {
+            let branch_columns = self.branch(branch, ctes);
+            columns.get_or_insert(branch_columns);
+        }
+        columns.unwrap_or_default()
+    }
+
+    /// One query of a set operation
+    fn branch(&mut self, span: Span<usize>, ctes: &Ctes) -> Vec<ColumnMetadata> {
+        if span.is_empty() {
+            return Vec::new();
+        }
+        if self.is_symbol(span.start, '(') {
+            let close = self.close(span.start).min(span.end);
+            return self.query(span.start + 1..close, ctes);
+        }
+        if self.is_keyword(span.start, "WITH") {
+            return self.query(span, ctes);
+        }
+        if self.is_keyword(span.start, "VALUES") {
+            return self.values(span.start + 1..span.end);
+        }
+        if self.is_keyword(span.start, "SELECT") {
+            return self.select(span, ctes);
+        }
+        self.subqueries(span, ctes);
+        Vec::new()
+    }
+
+    /// `SELECT items [FROM sources] ...`, starting at `SELECT`
+    fn select(&mut self, span: Span<usize>, ctes: &Ctes) -> Vec<ColumnMetadata> {
+        let mut start = span.start + 1;
+        while self.is_any_keyword(start, &SELECT_MODIFIERS) {
+            start += 1;
+            // DISTINCT ON (expressions)
+            if self.is_keyword(start, "ON") && self.is_symbol(start + 1, '(') {
+                start = self.close(start + 1) + 1;
+            }
+        }
+        let start = start.min(span.end);
+
+        let list_end = self
+            .find_keyword(start..span.end, &SELECT_LIST_END)
+            .unwrap_or(span.end);
+        let from = self.find_keyword(list_end..span.end, &["FROM"]);
+
+        // Sources of the FROM clause are visible in the whole query
+        let scope = Range::new(self.end_of(span.start), self.start_of(span.end));
+        let (sources, rest) = match from {
+            Some(from) => {
+                let from_end = self
+                    .find_keyword(from + 1..span.end, &SELECT_LIST_END[1..])
+                    .unwrap_or(span.end);
+                let sources = self.from_items(from + 1..from_end, scope, ctes);
+                (sources, from_end..span.end)
+            }
+            None => (Vec::new(), list_end..span.end),
+        };
+        self.subqueries(rest, ctes);
+
+        let mut columns = Vec::new();
+        for (position, item) in self.split(start..list_end).into_iter().enumerate() {
+            self.subqueries(item.clone(), ctes);
+            columns.extend(self.select_item(item, position + 1, &sources));
+        }
+        columns
+    }
+
+    /// Items of a `FROM` clause
+    fn from_items(&mut self, span: Span<usize>, scope: Range, ctes: &Ctes) -> Vec<Source> {
+        let mut sources = Vec::new();
+        let mut index = span.start;
+        while index < span.end {
+            if self.is_symbol(index, ',')
+                || self.is_any_keyword(index, &JOIN_WORDS)
+                || self.is_keyword(index, "ONLY")
+            {
+                index += 1;
+                continue;
+            }
+
+            index = self.from_item(index..span.end, scope, ctes, &mut sources);
+
+            // Skip the join condition, up to the next item
+            while index < span.end && !self.is_symbol(index, ',') && !self.starts_join(index) {
+                index = self.next(index);
+            }
+        }
+        sources
+    }
+
+    /// Check whether a join starts at `index` (`LEFT(name, 1)` is a call)
+    fn starts_join(&self, index: usize) -> bool {
+        let call = self.is_any_keyword(index, &["LEFT", "RIGHT"]) && self.is_symbol(index + 1, '(');
+        self.is_any_keyword(index, &JOIN_WORDS) && !call
+    }
+
+    /// One item of a `FROM` clause: a table, a derived table or a join in
+    /// parentheses
+    ///
+    /// # Returns
+    ///
+    /// The index following the item
+    fn from_item(
+        &mut self,
+        span: Span<usize>,
+        scope: Range,
+        ctes: &Ctes,
+        sources: &mut Vec<Source>,
+    ) -> usize {
+        let index = span.start;
+        if self.is_symbol(index, '(') {
+            let close = self.close(index).min(span.end);
+            if !self.starts_query(index + 1) {
+                sources.extend(self.from_items(index + 1..close, scope, ctes));
+                let (_, _, next) = self.alias(close + 1, span.end);
+                return next;
+            }
+
+            let mut first = index + 1;
+            while self.is_symbol(first, '(') {
+                first += 1;
+            }
+            let kind = match self.is_keyword(first, "VALUES") {
+                true => RelationKind::Values,
+                false => RelationKind::Derived,
+            };
+            let columns = self.query(index + 1..close, ctes);
+            let (alias, names, next) = self.alias(close + 1, span.end);
+            if let Some(name) = alias {
+                let columns = rename(columns, &names);
+                self.relations.push(StatementRelation {
+                    name: name.clone(),
+                    kind,
+                    columns: columns.clone(),
+                    scope,
+                    body: Some(Range::new(self.end_of(index), self.start_of(close))),
+                });
+                sources.push(Source { name, columns });
+            }
+            return next;
+        }
+
+        // [schema.]table [AS] alias, or a table function: f(...) [AS] alias [(columns)]
+        let Some(mut reference) = self.name_at(index) else {
+            return index + 1;
+        };
+        let mut last = reference.clone();
+        let mut next = index + 1;
+        while self.is_symbol(next, '.')
+            && let Some(part) = self.name_at(next + 1)
+        {
+            reference = format!("{}.{}", reference, part);
+            last = part;
+            next += 2;
+        }
+        let function = self.is_symbol(next, '(');
+        if function {
+            next = self.close(next) + 1;
+        }
+
+        let (alias, names, next) = self.alias(next, span.end);
+        let columns = match function {
+            true => names
+                .iter()
+                .map(|name| ColumnMetadata::new(name, unknown_type()))
+                .collect(),
+            false => rename(self.table_columns(&reference, ctes), &names),
+        };
+        sources.push(Source {
+            name: alias.unwrap_or(last),
+            columns,
+        });
+        next
+    }
+
+    /// `[AS] alias [(columns)]` at `index`
+    ///
+    /// # Returns
+    ///
+    /// The alias, its column names and the index following it
+    fn alias(&self, index: usize, end: usize) -> (Option<String>, Vec<String>, usize) {
+        let index = match self.is_keyword(index, "AS") {
+            true => index + 1,
+            false => index,
+        };
+        let Some(name) = self.name_at(index).filter(|_| index < end) else {
+            return (None, Vec::new(), index);
+        };
+
+        let mut next = index + 1;
+        let mut names = Vec::new();
+        if next < end && self.is_symbol(next, '(') {
+            let close = self.close(next);
+            names = self.names(next + 1..close);
+            next = close + 1;
+        }
+        (Some(name), names, next)
+    }
+
+    /// Columns of a table or of a CTE defined earlier
+    fn table_columns(&mut self, reference: &str, ctes: &Ctes) -> Vec<ColumnMetadata> {
+        if !reference.contains('.')
+            && let Some((_, columns)) = ctes
+                .iter()
+                .rev()
+                .find(|(name, _)| name.eq_ignore_ascii_case(reference))
+        {
+            return columns.clone();
+        }
+
+        match self.schema.get(&reference.to_lowercase()) {
+            Some(columns) => columns.clone(),
+            None => {
+                if !self.wanted.iter().any(|wanted| wanted == reference) {
+                    self.wanted.push(reference.to_string());
+                }
+                Vec::new()
+            }
+        }
+    }
+
+    /// Columns of one select list item
+    ///
+    /// # Arguments
+    ///
+    /// * `item` - Tokens of the item
+    /// * `position` - 1-based position of the item in the select list
+    /// * `sources` - Sources of the `FROM` clause
+    fn select_item(
+        &self,
+        item: Span<usize>,
+        position: usize,
+        sources: &[Source],
+    ) -> Vec<ColumnMetadata> {
+        // `*`, `t.*`
+        if self.is_symbol(item.end - 1, '*') {
+            if item.len() == 1 {
+                return sources
+                    .iter()
+                    .flat_map(|source| source.columns.iter().cloned())
+                    .collect();
+            }
+            if item.len() >= 3 && self.is_symbol(item.end - 2, '.') {
+                let qualifier = self.name_at(item.end - 3).unwrap_or_default();
+                return sources
+                    .iter()
+                    .rev()
+                    .find(|source| source.name.eq_ignore_ascii_case(&qualifier))
+                    .map(|source| source.columns.clone())
+                    .unwrap_or_default();
+            }
+        }
+
+        let (expression, alias) = self.split_alias(item);
+        let name = alias
+            .or_else(|| self.expression_name(expression.clone()))
+            .unwrap_or_else(|| match self.dialect.family() {
+                DialectFamily::PostgreSQL => "?column?".to_string(),
+                DialectFamily::MySQL => format!("expr_{}", position),
+            });
+        let column = match self.column_reference(expression.clone(), sources) {
+            Some(column) => column.clone(),
+            None => ColumnMetadata::new(&name, self.expression_type(expression)),
+        };
+        vec![ColumnMetadata { name, ..column }]
+    }
+
+    /// Split `expression [AS] alias` into the expression and the alias
+    fn split_alias(&self, item: Span<usize>) -> (Span<usize>, Option<String>) {
+        let last = item.end - 1;
+        if item.len() >= 3
+            && self.is_keyword(last - 1, "AS")
+            && let Some(alias) = self.name_at(last)
+        {
+            return (item.start..last - 1, Some(alias));
+        }
+        if item.len() >= 2
+            && self.ends_expression(last - 1)
+            && let Some(alias) = self.name_at(last)
+        {
+            return (item.start..last, Some(alias));
+        }
+        (item, None)
+    }
+
+    /// Check whether an expression can end with the token at `index`
+    fn ends_expression(&self, index: usize) -> bool {
+        let token = &self.tokens[index];
+        match token.kind {
+            TokenKind::Word => {
+                self.name_at(index).is_some()
+                    || token.text.starts_with(|c: char| c.is_ascii_digit())
+                    || ["END", "NULL", "TRUE", "FALSE"]
+                        .iter()
+                        .any(|keyword| token.is_keyword(keyword))
+            }
+            TokenKind::Quoted | TokenKind::Literal => true,
+            TokenKind::Symbol(c) => c == ')',
+        }
+    }
+
+    /// Parts of a dotted name (`col`, `t.col`, `schema.t.col`)
+    fn dotted_name(&self, span: Span<usize>) -> Option<Vec<String>> {
+        if span.is_empty() || span.len() % 2 == 0 {
+            return None;
+        }
+        span.step_by(2)
+            .enumerate()
+            .map(
+                |(part, index)| match part == 0 || self.is_symbol(index - 1, '.') {
+                    true => self.name_at(index),
+                    false => None,
+                },
+            )
+            .collect()
+    }
+
+    /// Source column an expression references
+    fn column_reference<'s>(
+        &self,
+        expression: Span<usize>,
+        sources: &'s [Source],
+    ) -> Option<&'s ColumnMetadata> {
+        let parts = self.dotted_name(expression)?;
+        let (column, qualifier) = parts.split_last()?;
+        sources
+            .iter()
+            .filter(|source| {
+                qualifier
+                    .last()
+                    .is_none_or(|qualifier| source.name.eq_ignore_ascii_case(qualifier))
+            })
+            .flat_map(|source| &source.columns)
+            .find(|candidate| candidate.name.eq_ignore_ascii_case(column))
+    }
+
+    /// Name the database gives an expression without an alias
+    fn expression_name(&self, expression: Span<usize>) -> Option<String> {
+        if let Some(parts) = self.dotted_name(expression.clone()) {
+            return parts.last().cloned();
+        }
+        if self.dialect.family() != DialectFamily::PostgreSQL {
+            return None;
+        }
+
+        // x::type
+        let cast = self
+            .top_level(expression.clone())
+            .into_iter()
+            .find(|&index| self.is_symbol(index, ':') && self.is_symbol(index + 1, ':'));
+        if let Some(cast) = cast {
+            return self.expression_name(expression.start..cast);
+        }
+
+        let first = &self.tokens[expression.start];
+        if first.is_keyword("CASE") {
+            return Some("case".to_string());
+        }
+        // f(...), CAST(x AS type)
+        let call = first.kind == TokenKind::Word
+            && self.is_symbol(expression.start + 1, '(')
+            && self.close(expression.start + 1) == expression.end - 1;
+        if !call {
+            return None;
+        }
+        if first.is_keyword("CAST") {
+            let close = expression.end - 1;
+            let as_index = self.find_keyword(expression.start + 2..close, &["AS"])?;
+            return self.expression_name(expression.start + 2..as_index);
+        }
+        Some(first.text.to_lowercase())
+    }
+
+    /// Type of an expression, when it is obvious
+    fn expression_type(&self, expression: Span<usize>) -> DataType {
+        let tokens = &self.tokens[expression.clone()];
+        let is_number = |token: &Token| {
+            token.kind == TokenKind::Word && token.text.chars().all(|c| c.is_ascii_digit())
+        };
+        match tokens {
+            [literal] if literal.kind == TokenKind::Literal => DataType::Text,
+            [number] if is_number(number) => DataType::Integer,
+            [whole, dot, fraction]
+                if is_number(whole)
+                    && dot.kind == TokenKind::Symbol('.')
+                    && is_number(fraction) =>
+            {
+                DataType::Decimal
+            }
+            [boolean] if boolean.is_keyword("TRUE") || boolean.is_keyword("FALSE") => {
+                DataType::Boolean
+            }
+            [count, ..]
+                if count.is_keyword("COUNT")
+                    && self.is_symbol(expression.start + 1, '(')
+                    && self.close(expression.start + 1) == expression.end - 1 =>
+            {
+                DataType::BigInt
+            }
+            _ => unknown_type(),
+        }
+    }
+
+    /// Columns of `VALUES [ROW] (a, b), ...`, named after the first row
+    fn values(&self, span: Span<usize>) -> Vec<ColumnMetadata> {
+        let mut open = span.start;
+        if self.is_keyword(open, "ROW") {
+            open += 1;
+        }
+        if open >= span.end || !self.is_symbol(open, '(') {
+            return Vec::new();
+        }
+
+        let close = self.close(open).min(span.end);
+        self.split(open + 1..close)
+            .into_iter()
+            .enumerate()
+            .map(|(index, item)| {
+                let name = match self.dialect.family() {
+                    DialectFamily::PostgreSQL => format!("column{}", index + 1),
+                    DialectFamily::MySQL => format!("column_{}", index),
+                };
+                ColumnMetadata::new(name, self.expression_type(item))
+            })
+            .collect()
+    }
+}
+
+/// Rename columns after a column list; extra names are ignored
+fn rename(mut columns: Vec<ColumnMetadata>, names: &[String]) -> Vec<ColumnMetadata> {
+    for (column, name) in columns.iter_mut().zip(names) {
+        column.name = name.clone();
+    }
+    columns
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{SchemaSnapshot, SnapshotCatalog};
+
+    fn catalog() -> SnapshotCatalog {
+        SnapshotCatalog::new(SchemaSnapshot::new(
+            vec![
+                TableMetadata::new("orders", "shop").with_columns(vec![
+                    ColumnMetadata::new("id", DataType::Integer).with_primary_key(),
+                    ColumnMetadata::new("user_id", DataType::Integer),
+                    ColumnMetadata::new("total", DataType::Decimal),
+                    ColumnMetadata::new("created_at", DataType::Timestamp),
+                ]),
+                TableMetadata::new("users", "shop").with_columns(vec![
+                    ColumnMetadata::new("id", DataType::Integer).with_primary_key(),
+                    ColumnMetadata::new("name", DataType::Text),
+                ]),
+            ],
+            vec![],
+        ))
+    }
+
+    /// Infer the relations at the `|` of a statement
+    async fn infer(sql: &str, dialect: Dialect) -> StatementRelations {
+        let offset = sql.find('|').expect("cursor marker");
+        let source = sql.replace('|', "");
+        StatementRelations::infer(&source, end_position(&sql[..offset]), dialect, &catalog()).await
+    }
+
+    fn column_names(relations: &StatementRelations, name: &str) -> Vec<String> {
+        let relation = relations.find(name).expect("relation is visible");
+        relation.columns.iter().map(|c| c.name.clone()).collect()
+    }
+
+    #[tokio::test]
+    async fn test_cte_columns_follow_the_select_list() {
+        let relations = infer(
+            "WITH recent AS (SELECT id, o.created_at, total * 2 AS doubled, user_id uid \
+             FROM orders o WHERE total > 10) SELECT recent.| FROM recent",
+            Dialect::MySQL,
+        )
+        .await;
+
+        assert_eq!(
+            column_names(&relations, "RECENT"),
+            ["id", "created_at", "doubled", "uid"]
+        );
+        let recent = relations.find("recent").unwrap();
+        assert_eq!(recent.kind, RelationKind::Cte);
+        assert_eq!(recent.columns[0].data_type, DataType::Integer);
+        assert!(recent.columns[0].is_primary_key);
+        assert_eq!(recent.columns[1].data_type, DataType::Timestamp);
+        assert_eq!(recent.columns[2].data_type, unknown_type());
+    }
+
+    #[tokio::test]
+    async fn test_star_expands_to_source_columns() {
+        let relations = infer(
+            "WITH a AS (SELECT * FROM users), \
+             b AS (SELECT o.*, u.name FROM orders o JOIN users u ON u.id = o.user_id) \
+             SELECT | FROM a, b",
+            Dialect::PostgreSQL,
+        )
+        .await;
+
+        assert_eq!(column_names(&relations, "a"), ["id", "name"]);
+        assert_eq!(
+            column_names(&relations, "b"),
+            ["id", "user_id", "total", "created_at", "name"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_unnamed_expressions_follow_the_dialect() {
+        let sql = "WITH t AS (SELECT 1, 'a', count(*), id::text, CAST(total AS int), \
+                   CASE WHEN id > 1 THEN 1 END, total + 1 FROM orders) SELECT | FROM t";
+
+        let relations = infer(sql, Dialect::PostgreSQL).await;
+        assert_eq!(
+            column_names(&relations, "t"),
+            [
+                "?column?", "?column?", "count", "id", "total", "case", "?column?"
+            ]
+        );
+        let columns = &relations.find("t").unwrap().columns;
+        assert_eq!(columns[0].data_type, DataType::Integer);
+        assert_eq!(columns[1].data_type, DataType::Text);
+        assert_eq!(columns[2].data_type, DataType::BigInt);
+
+        let relations = infer(sql, Dialect::MySQL).await;
+        assert_eq!(
+            column_names(&relations, "t"),
+            [
+                "expr_1", "expr_2", "expr_3", "expr_4", "expr_5", "expr_6", "expr_7"
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_nested_ctes_resolve_earlier_ones() {
+        let relations = infer(
+            "WITH base AS (SELECT id AS order_id, total FROM orders), \
+             big (big_id, amount) AS (SELECT * FROM base WHERE total > 100), \
+             top AS (SELECT b.big_id FROM big b) \
+             SELECT | FROM top",
+            Dialect::PostgreSQL,
+        )
+        .await;
+
+        assert_eq!(column_names(&relations, "base"), ["order_id", "total"]);
+        assert_eq!(column_names(&relations, "big"), ["big_id", "amount"]);
+        assert_eq!(column_names(&relations, "top"), ["big_id"]);
+        assert_eq!(
+            relations.find("top").unwrap().columns[0].data_type,
+            DataType::Integer
+        );
+    }
+
+    #[tokio::test]
+    async fn test_derived_tables_and_values() {
+        let relations = infer(
+            "SELECT | FROM (SELECT id, total FROM orders) AS x \
+             JOIN (VALUES (1, 'a'), (2, 'b')) AS v (id, label) ON v.id = x.id, \
+             (SELECT 1 UNION SELECT 2) AS n",
+            Dialect::PostgreSQL,
+        )
+        .await;
+
+        assert_eq!(column_names(&relations, "x"), ["id", "total"]);
+        assert_eq!(column_names(&relations, "v"), ["id", "label"]);
+        assert_eq!(column_names(&relations, "n"), ["?column?"]);
+        assert_eq!(relations.find("x").unwrap().kind, RelationKind::Derived);
+        assert_eq!(relations.find("v").unwrap().kind, RelationKind::Values);
+        assert_eq!(
+            relations.find("v").unwrap().columns[1].data_type,
+            DataType::Text
+        );
+
+        let relations = infer("SELECT | FROM (VALUES ROW(1, 'a')) AS v", Dialect::MySQL).await;
+        assert_eq!(column_names(&relations, "v"), ["column_0", "column_1"]);
+    }
+
+    #[tokio::test]
+    async fn test_visibility() {
+        // Not inside its own definition
+        let relations = infer(
+            "WITH recent AS (SELECT | FROM orders) SELECT * FROM recent",
+            Dialect::MySQL,
+        )
+        .await;
+        assert!(relations.find("recent").is_none());
+        assert!(!relations.is_empty());
+
+        // Not in another statement
+        let relations = infer(
+            "WITH recent AS (SELECT id FROM orders) SELECT * FROM recent;\nSELECT |",
+            Dialect::MySQL,
+        )
+        .await;
+        assert!(relations.find("recent").is_none());
+
+        // Recursive CTEs see themselves
+        let relations = infer(
+            "WITH RECURSIVE n (i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE |)",
+            Dialect::PostgreSQL,
+        )
+        .await;
+        assert_eq!(column_names(&relations, "n"), ["i"]);
+    }
+
+    #[tokio::test]
+    async fn test_inner_relation_shadows_outer() {
+        let relations = infer(
+            "WITH t AS (SELECT id FROM orders) \
+             SELECT * FROM (WITH t AS (SELECT name FROM users) SELECT t.| FROM t) AS x",
+            Dialect::PostgreSQL,
+        )
+        .await;
+        assert_eq!(column_names(&relations, "t"), ["name"]);
+
+        let catalog = relations.catalog(Arc::new(catalog()));
+        let columns = catalog.get_columns("t").await.unwrap();
+        assert_eq!(columns[0].name, "name");
+        assert!(catalog.get_columns("orders").await.is_ok());
+        assert_eq!(catalog.list_tables().await.unwrap().len(), 2);
+    }
+
+    #[tokio::test]
+    async fn test_statement_without_relations() {
+        let relations = infer("SELECT | FROM orders WHERE id = 1", Dialect::MySQL).await;
+        assert!(relations.is_empty());
+    }
+}
@@ -8,15 +8,17 @@
 //! This module provides functionality to render LSP completion items
 //! from semantic symbols.
 
-use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind, Documentation};
+use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind, Documentation, InsertTextFormat};
 use unified_sql_lsp_catalog::{
     FunctionMetadata, FunctionType, TableMetadata, TableType, format_data_type,
 };
-use unified_sql_lsp_semantic::{ColumnSymbol, TableSymbol};
+use unified_sql_lsp_semantic::{ColumnSymbol, ForeignKeyJoin, TableSymbol};
 
 // Import keyword types from context crate
 use unified_sql_lsp_context::SqlKeyword;
 
+use crate::search_path::shadowing_table;
+
 /// Completion renderer
 ///
 /// Converts semantic symbols to LSP CompletionItem representations.
@@ -163,6 +165,61 @@ impl CompletionRenderer {
         items
     }
 
+    /// Render foreign-key derived JOIN condition completion items
+    ///
+    /// # Arguments
+    ///
+    /// * `joins` - JOIN conditions derived from foreign keys
+    ///
+    /// # Returns
+    ///
+    /// Vector of completion items ranked above all column items
+    /// (sort: "000_join_<n>"), the first one preselected
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// # use unified_sql_lsp_lsp::completion::render::CompletionRenderer;
+    /// # use unified_sql_lsp_semantic::ForeignKeyJoin;
+    /// let joins = vec![ForeignKeyJoin {
+    ///     condition: "o.user_id = u.id".to_string(),
+    ///     from_table: "o".to_string(),
+    ///     to_table: "u".to_string(),
+    ///     constraint: None,
+    /// }];
+    /// let items = CompletionRenderer::render_join_conditions(&joins);
+    /// assert_eq!(items[0].label, "o.user_id = u.id");
+    /// ```
+    pub fn render_join_conditions(joins: &[ForeignKeyJoin]) -> Vec<CompletionItem> {
+        joins
+            .iter()
+            .enumerate()
+            .map(|(index, join)| {
+                let detail = match &join.constraint {
+                    Some(constraint) => format!(
+                        "Join {} → {} ({})",
+                        join.from_table, join.to_table, constraint
+                    ),
+                    None => format!("Join {} → {}", join.from_table, join.to_table),
+                };
+
+                CompletionItem {
+                    label: join.condition.clone(),
+                    kind: Some(CompletionItemKind::REFERENCE),
+                    detail: Some(detail),
+                    documentation: Some(Documentation::String(
+                        "JOIN condition derived from a foreign key".to_string(),
+                    )),
+                    preselect: Some(index == 0),
+                    sort_text: Some(format!("000_join_{:03}", index)),
+                    filter_text: Some(join.condition.clone()),
+                    insert_text: Some(join.condition.clone()),
+                    ..Default::default()
+                }
+            })
+            .collect()
+    }
+
     /// Render table completion items
     ///
     /// # Arguments
@@ -183,10 +240,12 @@ impl CompletionRenderer {
     /// #     TableMetadata {
     /// #         name: "users".to_string(),
     /// #         schema: "public".to_string(),
+    /// #         catalog: None,
     /// #         columns: vec![],
     /// #         row_count_estimate: None,
     /// #         comment: None,
     /// #         table_type: TableType::Table,
+    /// #         definition: None,
     /// #     }
     /// # ];
     /// let items = CompletionRenderer::render_tables(&tables, false);
@@ -195,8 +254,12 @@ impl CompletionRenderer {
     pub fn render_tables(tables: &[TableMetadata], show_schema: bool) -> Vec<CompletionItem> {
         let mut items = Vec::new();
 
-        for table in tables {
-            items.push(Self::table_item(table, show_schema));
+        for (index, table) in tables.iter().enumerate() {
+            let item = match shadowing_table(tables, index) {
+                Some(visible) => Self::shadowed_table_item(table, visible),
+                None => Self::table_item(table, show_schema),
+            };
+            items.push(item);
         }
 
         // Sort alphabetically by label
@@ -211,18 +274,20 @@ impl CompletionRenderer {
     ///
     /// * `table` - The table metadata
     /// * `show_schema` - Whether to include schema qualifier in label
+    ///
+    /// Tables of other databases are always fully qualified
+    /// (`db.schema.table`).
     fn table_item(table: &TableMetadata, show_schema: bool) -> CompletionItem {
-        let label = if show_schema {
-            format!("{}.{}", table.schema, table.name)
-        } else {
-            table.name.clone()
+        let label = match &table.catalog {
+            Some(_) => table.qualified_name(),
+            None if show_schema => table.qualified_name(),
+            None => table.name.clone(),
         };
 
         let detail = Self::format_table_detail(table);
         let documentation = Self::format_table_documentation(table);
 
         CompletionItem {
-            label,
             kind: Some(CompletionItemKind::CLASS),
             detail: Some(detail),
             documentation: Some(Documentation::String(documentation)),
@@ -230,15 +295,28 @@ impl CompletionRenderer {
             preselect: Some(false),
             sort_text: Some(Self::table_sort_text(table, show_schema)),
             filter_text: Some(table.name.clone()),
-            insert_text: Some(if show_schema {
-                format!("{}.{}", table.schema, table.name)
-            } else {
-                table.name.clone()
-            }),
+            insert_text: Some(label.clone()),
+            label,
             ..Default::default()
         }
     }
 
+    /// Render a table hidden by a table of the same name earlier on the
+    /// search path
+    ///
+    /// The table is inserted qualified, since its bare name resolves to the
+    /// visible table, and ranks after the tables of the connected database.
+    fn shadowed_table_item(table: &TableMetadata, visible: &TableMetadata) -> CompletionItem {
+        let mut item = Self::table_item(table, true);
+        item.sort_text = Some(format!("~{}_.{}", table.schema, table.name));
+        item.documentation = Some(Documentation::String(format!(
+            "Shadowed by {} on the search path\n\n{}",
+            visible.qualified_name(),
+            Self::format_table_documentation(table)
+        )));
+        item
+    }
+
     /// Format the detail string for a table
     ///
     /// Shows the schema name and table type
@@ -251,7 +329,10 @@ impl CompletionRenderer {
             TableType::System => "SYSTEM",
             TableType::Other(s) => s,
         };
-        format!("{}.{} [{}]", table.schema, table.name, type_str)
+        match &table.catalog {
+            Some(catalog) => format!("{}.{}.{} [{}]", catalog, table.schema, table.name, type_str),
+            None => format!("{}.{} [{}]", table.schema, table.name, type_str),
+        }
     }
 
     /// Format the documentation string for a table
@@ -274,8 +355,8 @@ impl CompletionRenderer {
         }
 
         // Add comment if available
-        if let Some(comment) = &table.comment {
-            parts.push(comment.clone());
+        if let Some(comment) = table.comment.as_deref().filter(|c| !c.trim().is_empty()) {
+            parts.push(comment.to_string());
         }
 
         // Add row count estimate if available
@@ -294,6 +375,10 @@ impl CompletionRenderer {
     ///
     /// Tables are sorted alphabetically by schema.table name
     fn table_sort_text(table: &TableMetadata, show_schema: bool) -> String {
+        // Tables of other databases sort after those of the connected one
+        if let Some(catalog) = &table.catalog {
+            return format!("~{}.{}_.{}", catalog, table.schema, table.name);
+        }
         if show_schema {
             format!("{}_.{}", table.schema, table.name)
         } else {
@@ -321,7 +406,11 @@ impl CompletionRenderer {
             label,
             kind: Some(CompletionItemKind::FIELD),
             detail: Some(detail),
-            documentation: None,
+            documentation: column
+                .comment
+                .as_deref()
+                .filter(|comment| !comment.trim().is_empty())
+                .map(|comment| Documentation::String(comment.to_string())),
             deprecated: Some(false),
             preselect: Some(false),
             sort_text: Some(Self::sort_text(column)),
@@ -349,6 +438,18 @@ impl CompletionRenderer {
         }
     }
 
+    /// Create an item selecting all columns of a CTE (`name.*`)
+    pub fn cte_item(name: &str) -> CompletionItem {
+        CompletionItem {
+            label: name.to_string(),
+            kind: Some(CompletionItemKind::CLASS),
+            detail: Some(format!("CTE: {}", name)),
+            insert_text: Some(format!("{}.*", name)),
+            insert_text_format: Some(InsertTextFormat::PLAIN_TEXT),
+            ..Default::default()
+        }
+    }
+
     /// Format the detail string for a column
     ///
     /// Shows the data type and whether it's nullable
@@ -379,7 +480,7 @@ impl CompletionRenderer {
     /// To show all functions:
     ///
     /// ```text,ignore
-    /// let items = CompletionRenderer::render_functions(&functions, None);
+    /// let items = CompletionRenderer::render_functions(&functions, None, false);
     /// ```
     ///
     /// To show only aggregate functions:
@@ -387,12 +488,14 @@ impl CompletionRenderer {
     /// ```text,ignore
     /// let items = CompletionRenderer::render_functions(
     ///     &functions,
-    ///     Some(FunctionType::Aggregate)
+    ///     Some(FunctionType::Aggregate),
+    ///     false,
     /// );
     /// ```
     pub fn render_functions(
         functions: &[FunctionMetadata],
         filter: Option<FunctionType>,
+        snippets: bool,
     ) -> Vec<CompletionItem> {
         let mut items = Vec::new();
 
@@ -404,7 +507,7 @@ impl CompletionRenderer {
                 continue;
             }
 
-            items.push(Self::function_item(function));
+            items.push(Self::function_item(function, snippets));
         }
 
         // Sort by function type priority, then alphabetically
@@ -422,7 +525,10 @@ impl CompletionRenderer {
     /// # Arguments
     ///
     /// * `function` - The function metadata
-    fn function_item(function: &FunctionMetadata) -> CompletionItem {
+    /// * `snippets` - Whether the client renders snippets; the argument list
+    ///   is then inserted with the cursor between the parentheses, otherwise
+    ///   only the opening parenthesis is inserted
+    fn function_item(function: &FunctionMetadata, snippets: bool) -> CompletionItem {
         let label = function.name.clone();
         let detail = Self::format_function_detail(function);
         let documentation = Self::format_function_documentation(function);
@@ -435,6 +541,15 @@ impl CompletionRenderer {
             FunctionType::Scalar => "03_scalar_",
         };
 
+        let (insert_text, insert_text_format) = if snippets {
+            (
+                format!("{}($1)$0", escape_snippet(&function.name)),
+                InsertTextFormat::SNIPPET,
+            )
+        } else {
+            (format!("{}(", function.name), InsertTextFormat::PLAIN_TEXT)
+        };
+
         CompletionItem {
             label,
             kind: Some(CompletionItemKind::CLASS), // TODO: (COMPLETION-006) Use Function when tower-lsp upgrades to LSP 3.17+
@@ -444,7 +559,8 @@ impl CompletionRenderer {
             preselect: Some(false),
             sort_text: Some(format!("{}{}", sort_prefix, function.name)),
             filter_text: Some(function.name.clone()),
-            insert_text: Some(format!("{}(", function.name)), // Add opening paren
+            insert_text: Some(insert_text),
+            insert_text_format: Some(insert_text_format),
             ..Default::default()
         }
     }
@@ -546,6 +662,18 @@ impl CompletionRenderer {
     }
 }
 
+/// Escape text inserted literally into a snippet
+fn escape_snippet(text: &str) -> String {
+    let mut escaped = String::with_capacity(text.len());
+    for c in text.chars() {
+        if matches!(c, '$' | '}' | '\\') {
+            escaped.push('\\');
+        }
+        escaped.push(c);
+    }
+    escaped
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -690,6 +818,53 @@ mod tests {
         assert!(items.iter().any(|i| i.label == "myapp.users"));
     }
 
+    #[test]
+    fn test_render_tables_shadowed_on_search_path() {
+        // Listed in search path order: app, public
+        let visible = TableMetadata::new("users", "app");
+        let shadowed = TableMetadata::new("users", "public");
+        let orders = TableMetadata::new("orders", "public");
+
+        let items = CompletionRenderer::render_tables(&[visible, shadowed, orders], false);
+
+        let visible = items.iter().find(|i| i.label == "users").unwrap();
+        let shadowed = items.iter().find(|i| i.label == "public.users").unwrap();
+        assert_eq!(shadowed.insert_text.as_deref(), Some("public.users"));
+        assert!(visible.sort_text < shadowed.sort_text);
+        assert!(matches!(
+            &shadowed.documentation,
+            Some(Documentation::String(doc)) if doc.starts_with("Shadowed by app.users")
+        ));
+        assert!(items.iter().any(|i| i.label == "orders"));
+    }
+
+    #[test]
+    fn test_render_tables_of_other_database() {
+        let local = TableMetadata::new("orders", "public");
+        let other = TableMetadata::new("orders", "public").with_catalog("analytics");
+
+        let items = CompletionRenderer::render_tables(&[local, other], false);
+
+        assert_eq!(items.len(), 2);
+        let other = items
+            .iter()
+            .find(|i| i.label == "analytics.public.orders")
+            .unwrap();
+        assert_eq!(
+            other.insert_text.as_deref(),
+            Some("analytics.public.orders")
+        );
+        assert!(
+            other
+                .detail
+                .as_ref()
+                .unwrap()
+                .starts_with("analytics.public.orders")
+        );
+        let local = items.iter().find(|i| i.label == "orders").unwrap();
+        assert!(local.sort_text < other.sort_text);
+    }
+
     #[test]
     fn test_render_tables_with_view() {
         let view = TableMetadata::new("active_users", "public")
@@ -735,6 +910,32 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_render_columns_with_comment() {
+        let table = TableSymbol::new("orders").with_columns(vec![
+            ColumnSymbol::new("total", DataType::Decimal, "orders")
+                .with_comment(Some("Order total, tax included".to_string())),
+            ColumnSymbol::new("notes", DataType::Text, "orders").with_comment(Some(String::new())),
+        ]);
+
+        let items = CompletionRenderer::render_columns(&[table], false);
+        let documentation = |label: &str| {
+            items
+                .iter()
+                .find(|item| item.label == label)
+                .and_then(|item| item.documentation.clone())
+        };
+
+        assert_eq!(
+            documentation("total"),
+            Some(Documentation::String(
+                "Order total, tax included".to_string()
+            ))
+        );
+        // Blank comments add no documentation
+        assert_eq!(documentation("notes"), None);
+    }
+
     #[test]
     fn test_render_tables_few_columns_lists_names() {
         let table = TableMetadata::new("users", "public").with_columns(vec![
@@ -913,6 +1114,37 @@ mod tests {
         );
     }
 
+    #[test]
+    fn test_render_join_conditions() {
+        let joins = vec![
+            ForeignKeyJoin {
+                condition: "o.user_id = u.id".to_string(),
+                from_table: "o".to_string(),
+                to_table: "u".to_string(),
+                constraint: Some("fk_orders_user".to_string()),
+            },
+            ForeignKeyJoin {
+                condition: "o.product_id = p.id".to_string(),
+                from_table: "o".to_string(),
+                to_table: "p".to_string(),
+                constraint: None,
+            },
+        ];
+
+        let items = CompletionRenderer::render_join_conditions(&joins);
+
+        assert_eq!(items.len(), 2);
+        assert_eq!(items[0].insert_text.as_deref(), Some("o.user_id = u.id"));
+        assert_eq!(items[0].preselect, Some(true));
+        assert_eq!(items[1].preselect, Some(false));
+        assert!(items[0].detail.as_ref().unwrap().contains("fk_orders_user"));
+        assert!(
+            items
+                .iter()
+                .all(|i| i.sort_text.as_ref().unwrap().as_str() < "00_pk_")
+        );
+    }
+
     #[test]
     fn test_render_functions_all() {
         use unified_sql_lsp_catalog::FunctionMetadata;
@@ -926,7 +1158,7 @@ mod tests {
                 .with_description("Absolute value"),
         ];
 
-        let items = CompletionRenderer::render_functions(&functions, None);
+        let items = CompletionRenderer::render_functions(&functions, None, false);
 
         assert_eq!(items.len(), 2);
         assert!(items.iter().any(|i| i.label == "count"));
@@ -942,7 +1174,8 @@ mod tests {
             FunctionMetadata::new("abs", DataType::Integer).with_type(FunctionType::Scalar),
         ];
 
-        let items = CompletionRenderer::render_functions(&functions, Some(FunctionType::Aggregate));
+        let items =
+            CompletionRenderer::render_functions(&functions, Some(FunctionType::Aggregate), false);
 
         // Should only show aggregate functions
         assert_eq!(items.len(), 1);
@@ -962,12 +1195,29 @@ mod tests {
             },
         ]);
 
-        let item = CompletionRenderer::function_item(&func);
+        let item = CompletionRenderer::function_item(&func, false);
 
         assert_eq!(item.label, "count");
         assert!(item.detail.as_ref().unwrap().contains("count"));
         assert_eq!(item.kind, Some(CompletionItemKind::CLASS)); // Using CLASS for functions
         assert!(item.insert_text.as_ref().unwrap().ends_with("("));
+        assert_eq!(item.insert_text_format, Some(InsertTextFormat::PLAIN_TEXT));
+    }
+
+    #[test]
+    fn test_function_item_snippet() {
+        use unified_sql_lsp_catalog::FunctionMetadata;
+
+        let item = CompletionRenderer::function_item(
+            &FunctionMetadata::new("count", DataType::BigInt),
+            true,
+        );
+        assert_eq!(item.insert_text.as_deref(), Some("count($1)$0"));
+        assert_eq!(item.insert_text_format, Some(InsertTextFormat::SNIPPET));
+
+        let item =
+            CompletionRenderer::function_item(&FunctionMetadata::new("$fn", DataType::Text), true);
+        assert_eq!(item.insert_text.as_deref(), Some("\\$fn($1)$0"));
     }
 
     #[test]
@@ -980,7 +1230,7 @@ mod tests {
             FunctionMetadata::new("row_number", DataType::BigInt).with_type(FunctionType::Window),
         ];
 
-        let items = CompletionRenderer::render_functions(&functions, None);
+        let items = CompletionRenderer::render_functions(&functions, None, false);
 
         // Aggregates should come first
         assert!(
@@ -1036,7 +1286,7 @@ mod tests {
             ])
             .with_example("SELECT CONCAT(first, ' ', last) FROM users");
 
-        let item = CompletionRenderer::function_item(&func);
+        let item = CompletionRenderer::function_item(&func, false);
 
         assert_eq!(item.label, "concat");
         assert_eq!(item.kind, Some(CompletionItemKind::CLASS)); // Using CLASS for functions
@@ -1060,7 +1310,7 @@ mod tests {
         let func =
             FunctionMetadata::new("count", DataType::BigInt).with_type(FunctionType::Aggregate);
 
-        let item = CompletionRenderer::function_item(&func);
+        let item = CompletionRenderer::function_item(&func, false);
 
         // Insert text should include opening paren for easier typing
         assert_eq!(item.insert_text.as_ref().unwrap(), "count(");
@@ -29,10 +29,28 @@
 //! };
 //! ```
 
+use serde::de::DeserializeOwned;
 use serde_json::Value;
 use std::collections::HashSet;
-use unified_sql_lsp_catalog::CatalogError;
-use unified_sql_lsp_ir::Dialect;
+use std::path::PathBuf;
+use tower_lsp::lsp_types::DiagnosticSeverity;
+use tracing::warn;
+use unified_sql_lsp_catalog::{CatalogError, CatalogFilter, PoolOptions, TlsOptions, pool};
+use unified_sql_lsp_ir::dialect::DialectFamily;
+use unified_sql_lsp_ir::{Dialect, DialectRules, IdentifierCase};
+
+use crate::commands::execute::ExecutionConfig;
+use crate::completion::keyword_case::KeywordCase;
+use crate::connection_health::DEFAULT_HEALTH_CHECK_INTERVAL_SECS;
+use crate::diagnostic::{DiagnosticSources, DiagnosticsConfig};
+use crate::embedded::EmbeddedSqlConfig;
+use crate::formatting::OnTypeFormattingConfig;
+use crate::lint::LintConfig;
+use crate::parameters::{ParameterConfig, ParameterStyles};
+use crate::request_log::{DEFAULT_SLOW_REQUEST_THRESHOLD_MS, RequestBudgets};
+use crate::schema_cache::DEFAULT_SCHEMA_CACHE_TTL_SECS;
+use crate::ssh_tunnel::SshTunnelConfig;
+use crate::workspace_index::WorkspaceIndexConfig;
 
 /// SQL dialect version enumeration
 ///
@@ -60,6 +78,34 @@ pub enum DialectVersion {
 }
 
 impl DialectVersion {
+    /// All supported versions, oldest first within each dialect
+    pub const ALL: [DialectVersion; 9] = [
+        DialectVersion::MySQL57,
+        DialectVersion::MySQL80,
+        DialectVersion::PostgreSQL12,
+        DialectVersion::PostgreSQL14,
+        DialectVersion::PostgreSQL16,
+        DialectVersion::TiDB50,
+        DialectVersion::TiDB60,
+        DialectVersion::TiDB70,
+        DialectVersion::TiDB80,
+    ];
+
+    /// Get the version number components (e.g. `[8, 0]` for MySQL 8.0)
+    pub fn number(&self) -> &'static [u32] {
+        match self {
+            DialectVersion::MySQL57 => &[5, 7],
+            DialectVersion::MySQL80 => &[8, 0],
+            DialectVersion::PostgreSQL12 => &[12],
+            DialectVersion::PostgreSQL14 => &[14],
+            DialectVersion::PostgreSQL16 => &[16],
+            DialectVersion::TiDB50 => &[5, 0],
+            DialectVersion::TiDB60 => &[6, 0],
+            DialectVersion::TiDB70 => &[7, 0],
+            DialectVersion::TiDB80 => &[8, 0],
+        }
+    }
+
     /// Get the dialect for this version
     pub fn dialect(&self) -> Dialect {
         match self {
@@ -151,7 +197,10 @@ impl SchemaFilter {
 }
 
 /// Connection pool configuration
-#[derive(Debug, Clone)]
+///
+/// Defaults are conservative: the server is an editor tool and needs only a
+/// few connections per database.
+#[derive(Debug, Clone, PartialEq, Eq)]
 pub struct ConnectionPoolConfig {
     /// Maximum number of connections in the pool
     pub max_connections: usize,
@@ -162,21 +211,100 @@ pub struct ConnectionPoolConfig {
     /// Connection timeout in seconds
     pub connection_timeout_secs: u64,
 
-    /// Idle timeout in seconds
+    /// Idle timeout in seconds (0 keeps idle connections open)
     pub idle_timeout_secs: u64,
+
+    /// Maximum connection lifetime in seconds (0 keeps connections forever)
+    pub max_lifetime_secs: u64,
 }
 
 impl Default for ConnectionPoolConfig {
     fn default() -> Self {
         Self {
-            max_connections: 10,
-            min_connections: 1,
-            connection_timeout_secs: 30,
-            idle_timeout_secs: 600,
+            max_connections: pool::DEFAULT_MAX_CONNECTIONS as usize,
+            min_connections: 0,
+            connection_timeout_secs: pool::DEFAULT_ACQUIRE_TIMEOUT_SECS,
+            idle_timeout_secs: pool::DEFAULT_IDLE_TIMEOUT_SECS,
+            max_lifetime_secs: pool::DEFAULT_MAX_LIFETIME_SECS,
+        }
+    }
+}
+
+impl ConnectionPoolConfig {
+    /// Parse pool configuration from the `pool` settings object
+    ///
+    /// Missing keys keep their defaults; values are validated by
+    /// [`EngineConfig::validate_pool`].
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut config = Self::default();
+        let get = |key: &str| settings.get(key).and_then(Value::as_u64);
+
+        if let Some(max) = get("maxConnections") {
+            config.max_connections = max as usize;
+        }
+        if let Some(min) = get("minConnections") {
+            config.min_connections = min as usize;
+        }
+        if let Some(timeout) = get("connectionTimeoutSecs") {
+            config.connection_timeout_secs = timeout;
+        }
+        if let Some(timeout) = get("idleTimeoutSecs") {
+            config.idle_timeout_secs = timeout;
+        }
+        if let Some(lifetime) = get("maxLifetimeSecs") {
+            config.max_lifetime_secs = lifetime;
+        }
+
+        config
+    }
+}
+
+/// Diagnostic sources run while typing and on save
+///
+/// Expensive sources (schema validation queries the catalog) can be limited
+/// to saves; their last results stay visible while typing.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct DiagnosticTriggers {
+    /// Sources run when a document changes
+    pub on_type: DiagnosticSources,
+
+    /// Sources run when a document is opened or saved
+    pub on_save: DiagnosticSources,
+}
+
+impl Default for DiagnosticTriggers {
+    fn default() -> Self {
+        Self {
+            on_type: DiagnosticSources::ALL,
+            on_save: DiagnosticSources::ALL,
         }
     }
 }
 
+impl DiagnosticTriggers {
+    /// Parse the triggers from the `diagnostics` settings object
+    ///
+    /// Missing or invalid keys keep running all sources.
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut triggers = Self::default();
+
+        if let Some(sources) = settings
+            .get("onType")
+            .and_then(DiagnosticSources::from_settings)
+        {
+            triggers.on_type = sources;
+        }
+        if let Some(sources) = settings
+            .get("onSave")
+            .and_then(DiagnosticSources::from_settings)
+        {
+            triggers.on_save = sources;
+        }
+
+        triggers
+    }
+}
+
 /// Main engine configuration
 ///
 /// Contains all settings for the LSP engine including dialect,
@@ -205,8 +333,112 @@ pub struct EngineConfig {
     /// Maximum query execution time for catalog queries (seconds)
     pub query_timeout_secs: u64,
 
-    /// Cache enabled (will be used in PERF-001)
+    /// Cache live schemas in memory and prefetch them in the background
     pub cache_enabled: bool,
+
+    /// Age after which a cached schema is refreshed (seconds, 0 never refreshes)
+    pub schema_cache_ttl_secs: u64,
+
+    /// Requests taking longer than this (milliseconds) are logged as slow
+    pub slow_request_threshold_ms: u64,
+
+    /// Time budgets of request handlers
+    pub request_budgets: RequestBudgets,
+
+    /// Disable dialect compatibility fallback
+    ///
+    /// When `false` (the default), dialects without a dedicated catalog
+    /// (TiDB, MariaDB, CockroachDB) use the catalog of their dialect family.
+    /// When `true`, such dialects are rejected instead.
+    pub strict_dialect: bool,
+
+    /// Keep the configured version when the database server runs a version
+    /// without support
+    ///
+    /// When `false` (the default), the version detected from the server
+    /// replaces the configured one, falling back to the closest supported
+    /// version.
+    pub strict_version: bool,
+
+    /// Severity of schema diagnostics (unknown tables/columns)
+    ///
+    /// `None` disables schema diagnostics.
+    pub schema_diagnostics_severity: Option<DiagnosticSeverity>,
+
+    /// Opt-in lint rules for dangerous statements
+    pub lint: LintConfig,
+
+    /// Diagnostic sources run while typing and on save
+    pub diagnostics: DiagnosticTriggers,
+
+    /// Severity overrides of published diagnostics
+    pub diagnostic_severities: DiagnosticsConfig,
+
+    /// Format documents before they are saved
+    ///
+    /// Only applies to clients supporting `willSaveWaitUntil`.
+    pub format_on_save: bool,
+
+    /// Keyword casing and alignment while typing
+    ///
+    /// Off by default. Only applies to clients supporting dynamic
+    /// registration of `textDocument/onTypeFormatting`.
+    pub on_type_formatting: OnTypeFormattingConfig,
+
+    /// Indexing of the SQL files of the workspace folders
+    pub workspace_index: WorkspaceIndexConfig,
+
+    /// Glob of migration files applied on top of the database schema,
+    /// relative to the workspace folders (e.g. `db/migrations/*.sql`)
+    ///
+    /// `None` serves the database schema as is.
+    pub migrations: Option<String>,
+
+    /// SQL embedded in host-language documents (Go, Python)
+    pub embedded_sql: EmbeddedSqlConfig,
+
+    /// Casing of keywords inserted by completion
+    pub keyword_case: KeywordCase,
+
+    /// Settings of the execute-statement command
+    pub execution: ExecutionConfig,
+
+    /// Interval between connection health checks (seconds, 0 disables them)
+    pub health_check_interval_secs: u64,
+
+    /// TLS settings of the database connection
+    pub tls: Option<TlsOptions>,
+
+    /// SSH tunnel through which the database is reached
+    pub ssh: Option<SshTunnelConfig>,
+
+    /// Schema snapshot file served instead of a live database
+    ///
+    /// When set, no database connection is opened.
+    pub schema_file: Option<PathBuf>,
+
+    /// Other databases of the server whose tables are loaded
+    ///
+    /// `None` loads the connected database only.
+    pub databases: Option<CatalogFilter>,
+
+    /// Schemas searched for unqualified table names, in order
+    ///
+    /// Empty (the default) uses the search path of the connection: the
+    /// `search_path` of PostgreSQL, the current database of MySQL.
+    pub search_path: Vec<String>,
+
+    /// Identifier folding and quoting rules replacing those of the dialect
+    ///
+    /// `None` (the default) uses [`Dialect::rules`].
+    pub identifier_rules: Option<DialectRules>,
+
+    /// Bind parameter placeholder styles, by file
+    pub parameters: ParameterConfig,
+
+    /// Log filter (`info`, `unified_sql_lsp=trace`) replacing the default
+    /// one, unless `--log-level` fixed it
+    pub log_level: Option<String>,
 }
 
 impl Default for EngineConfig {
@@ -218,8 +450,33 @@ impl Default for EngineConfig {
             schema_filter: SchemaFilter::default(),
             pool_config: ConnectionPoolConfig::default(),
             log_queries: false,
-            query_timeout_secs: 5,
+            query_timeout_secs: pool::DEFAULT_STATEMENT_TIMEOUT_SECS,
             cache_enabled: true,
+            schema_cache_ttl_secs: DEFAULT_SCHEMA_CACHE_TTL_SECS,
+            slow_request_threshold_ms: DEFAULT_SLOW_REQUEST_THRESHOLD_MS,
+            request_budgets: RequestBudgets::default(),
+            strict_dialect: false,
+            strict_version: false,
+            schema_diagnostics_severity: Some(DiagnosticSeverity::WARNING),
+            lint: LintConfig::default(),
+            diagnostics: DiagnosticTriggers::default(),
+            diagnostic_severities: DiagnosticsConfig::default(),
+            format_on_save: false,
+            on_type_formatting: OnTypeFormattingConfig::default(),
+            workspace_index: WorkspaceIndexConfig::default(),
+            migrations: None,
+            embedded_sql: EmbeddedSqlConfig::default(),
+            keyword_case: KeywordCase::default(),
+            execution: ExecutionConfig::default(),
+            health_check_interval_secs: DEFAULT_HEALTH_CHECK_INTERVAL_SECS,
+            tls: None,
+            ssh: None,
+            schema_file: None,
+            databases: None,
+            search_path: Vec::new(),
+            identifier_rules: None,
+            parameters: ParameterConfig::default(),
+            log_level: None,
         }
     }
 }
@@ -244,7 +501,7 @@ impl EngineConfig {
     /// Checks that:
     /// - Dialect and version are compatible
     /// - Connection string is not empty
-    /// - Pool settings are reasonable
+    /// - Pool and TLS settings are reasonable
     pub fn validate(&self) -> Result<(), ConfigError> {
         // Check dialect/version compatibility
         if self.version.dialect() != self.dialect {
@@ -266,20 +523,31 @@ impl EngineConfig {
             });
         }
 
-        // Check pool settings
-        if self.pool_config.max_connections == 0 {
-            return Err(ConfigError::InvalidPoolConfig {
-                reason: "max_connections must be > 0".to_string(),
-            });
+        if let Some(tls) = &self.tls {
+            tls.validate()?;
         }
 
-        if self.pool_config.min_connections > self.pool_config.max_connections {
-            return Err(ConfigError::InvalidPoolConfig {
-                reason: "min_connections cannot exceed max_connections".to_string(),
-            });
-        }
+        self.validate_pool()
+    }
 
-        Ok(())
+    /// Validate the pool settings and the query timeout
+    pub fn validate_pool(&self) -> Result<(), ConfigError> {
+        self.pool_options().validate().map_err(|e| match e {
+            CatalogError::ConfigurationError(reason) => ConfigError::InvalidPoolConfig { reason },
+            other => ConfigError::CatalogError(other),
+        })
+    }
+
+    /// Get the pool options of live catalogs for this configuration
+    pub fn pool_options(&self) -> PoolOptions {
+        PoolOptions {
+            max_connections: u32::try_from(self.pool_config.max_connections).unwrap_or(u32::MAX),
+            min_connections: u32::try_from(self.pool_config.min_connections).unwrap_or(u32::MAX),
+            acquire_timeout_secs: self.pool_config.connection_timeout_secs,
+            idle_timeout_secs: self.pool_config.idle_timeout_secs,
+            max_lifetime_secs: self.pool_config.max_lifetime_secs,
+            statement_timeout_secs: self.query_timeout_secs,
+        }
     }
 
     /// Create a MySQL configuration
@@ -327,6 +595,54 @@ impl EngineConfig {
         Ok(Self::new(Dialect::TiDB, version, connection_string))
     }
 
+    /// Check whether a database connection is configured
+    pub fn has_connection(&self) -> bool {
+        !self.connection_string.trim().is_empty()
+    }
+
+    /// Get the dialect whose catalog serves this configuration
+    ///
+    /// Returns the configured dialect itself when it has a dedicated catalog,
+    /// otherwise the base dialect of its family (e.g. MariaDB → MySQL,
+    /// CockroachDB → PostgreSQL). Returns `None` when a fallback would be
+    /// required but `strict_dialect` is set.
+    pub fn catalog_dialect(&self) -> Option<Dialect> {
+        match self.dialect {
+            Dialect::MySQL | Dialect::PostgreSQL => Some(self.dialect),
+            _ if self.strict_dialect => None,
+            other => Some(match other.family() {
+                DialectFamily::MySQL => Dialect::MySQL,
+                DialectFamily::PostgreSQL => Dialect::PostgreSQL,
+            }),
+        }
+    }
+
+    /// Check whether this configuration runs on a compatibility catalog
+    ///
+    /// True when the configured dialect is served by its family's catalog
+    /// rather than a dedicated one.
+    pub fn is_compatibility_fallback(&self) -> bool {
+        self.catalog_dialect()
+            .is_some_and(|dialect| dialect != self.dialect)
+    }
+
+    /// Get the rules identifiers are compared with
+    ///
+    /// The configured override, or else the rules of the dialect.
+    pub fn identifier_rules(&self) -> DialectRules {
+        self.identifier_rules
+            .unwrap_or_else(|| self.dialect.rules())
+    }
+
+    /// Get the bind parameter placeholder styles of a document
+    ///
+    /// # Arguments
+    ///
+    /// * `path` - The document path
+    pub fn parameter_styles(&self, path: &str) -> ParameterStyles {
+        self.parameters.styles_for(self.dialect, path)
+    }
+
     /// Parse engine config from LSP client settings payload.
     ///
     /// Expected shape:
@@ -334,7 +650,41 @@ impl EngineConfig {
     ///   "unifiedSqlLsp": {
     ///     "dialect": "mysql" | "postgresql",
     ///     "version": "...",
-    ///     "connectionString": "..."
+    ///     "connectionString": "...",
+    ///     "strictDialect": false,
+    ///     "strictVersion": false,
+    ///     "slowRequestThresholdMs": 500,
+    ///     "requestBudgetsMs": { "completion": 250 },
+    ///     "schemaDiagnostics": "off" | "error" | "warning" | "information" | "hint",
+    ///     "lint": { "enabled": true, "exclude": ["migrations/**"], "rules": {}, "overrides": [] },
+    ///     "diagnostics": { "onType": ["syntax", "lint"], "onSave": ["syntax", "schema", "lint"],
+    ///                      "sources": { "schema": "error" }, "rules": { "SEMANTIC-002": "hint" },
+    ///                      "overrides": [{ "files": "reports/**", "sources": { "lint": "off" } }] },
+    ///     "formatOnSave": false,
+    ///     "onTypeFormatting": { "enabled": false, "uppercaseKeywords": true,
+    ///                           "alignClauses": true },
+    ///     "workspaceIndex": { "enabled": true, "maxFiles": 5000, "maxFileSizeKb": 1024 },
+    ///     "migrations": "db/migrations/*.sql",
+    ///     "embeddedSql": { "languages": ["go", "python"],
+    ///                      "markers": [{ "start": "sql := `", "end": "`" }] },
+    ///     "keywordCase": "upper" | "lower" | "preserve",
+    ///     "execution": { "allowWrites": false, "maxRows": 500, "autocommit": false },
+    ///     "healthCheckIntervalSecs": 30,
+    ///     "pool": { "maxConnections": 4, "minConnections": 0, "connectionTimeoutSecs": 10,
+    ///               "idleTimeoutSecs": 300, "maxLifetimeSecs": 1800 },
+    ///     "queryTimeoutSecs": 5,
+    ///     "tls": { "caCert": "...", "clientCert": "...", "clientKey": "...", "skipVerify": false },
+    ///     "ssh": { "host": "...", "port": 22, "user": "...", "keyFile": "..." },
+    ///     "schemaFile": "schema.json",
+    ///     "databases": { "include": ["app_*"], "exclude": ["*_test"] },
+    ///     "searchPath": ["app", "public"] | "app, public",
+    ///     "identifiers": { "fold": "lower" | "upper" | "preserve", "quote": "\"",
+    ///                      "quotedCaseSensitive": true, "caseSensitiveTables": false },
+    ///     "parameters": { "styles": ["dollar", "question", "colon", "at"],
+    ///                     "overrides": [{ "files": "scripts/**", "styles": ["colon"] }] },
+    ///     "log": { "level": "info" },
+    ///     "schemaCache": true,
+    ///     "schemaCacheTtlSecs": 300
     ///   }
     /// }
     pub fn from_lsp_settings(settings: &Value) -> Option<Self> {
@@ -361,8 +711,134 @@ impl EngineConfig {
             _ => return None,
         };
 
-        let connection_string = lsp_settings.get("connectionString")?.as_str()?.to_string();
-        Some(Self::new(dialect, version, connection_string))
+        // A schema snapshot file replaces the connection
+        let connection_string = match lsp_settings.get("connectionString").and_then(Value::as_str) {
+            Some(connection_string) => connection_string.to_string(),
+            None if lsp_settings.get("schemaFile").is_some() => String::new(),
+            None => return None,
+        };
+        let mut config = Self::new(dialect, version, connection_string);
+        config.strict_dialect = lsp_settings
+            .get("strictDialect")
+            .and_then(Value::as_bool)
+            .unwrap_or(false);
+        config.strict_version = lsp_settings
+            .get("strictVersion")
+            .and_then(Value::as_bool)
+            .unwrap_or(false);
+        if let Some(threshold) = lsp_settings
+            .get("slowRequestThresholdMs")
+            .and_then(Value::as_u64)
+        {
+            config.slow_request_threshold_ms = threshold;
+        }
+        if let Some(budgets) = lsp_settings.get("requestBudgetsMs") {
+            config.request_budgets = RequestBudgets::from_settings(budgets);
+        }
+        if let Some(severity) = lsp_settings
+            .get("schemaDiagnostics")
+            .and_then(Value::as_str)
+        {
+            config.schema_diagnostics_severity = parse_severity_setting(severity);
+        }
+        if let Some(lint) = lsp_settings.get("lint") {
+            config.lint = LintConfig::from_settings(lint);
+        }
+        if let Some(diagnostics) = lsp_settings.get("diagnostics") {
+            config.diagnostics = DiagnosticTriggers::from_settings(diagnostics);
+            match DiagnosticsConfig::from_settings(diagnostics) {
+                Ok(severities) => config.diagnostic_severities = severities,
+                Err(e) => warn!("Ignoring diagnostic severity overrides: {}", e),
+            }
+        }
+        if let Some(format) = lsp_settings.get("formatOnSave").and_then(Value::as_bool) {
+            config.format_on_save = format;
+        }
+        if let Some(on_type) = lsp_settings.get("onTypeFormatting") {
+            config.on_type_formatting = OnTypeFormattingConfig::from_settings(on_type);
+        }
+        if let Some(index) = lsp_settings.get("workspaceIndex") {
+            config.workspace_index = WorkspaceIndexConfig::from_settings(index);
+        }
+        config.migrations = lsp_settings
+            .get("migrations")
+            .and_then(Value::as_str)
+            .filter(|glob| !glob.trim().is_empty())
+            .map(str::to_string);
+        if let Some(embedded) = lsp_settings.get("embeddedSql") {
+            config.embedded_sql = EmbeddedSqlConfig::from_settings(embedded);
+        }
+        if let Some(keyword_case) = lsp_settings
+            .get("keywordCase")
+            .and_then(Value::as_str)
+            .and_then(KeywordCase::from_setting)
+        {
+            config.keyword_case = keyword_case;
+        }
+        if let Some(execution) = lsp_settings.get("execution") {
+            config.execution = ExecutionConfig::from_settings(execution);
+        }
+        if let Some(interval) = lsp_settings
+            .get("healthCheckIntervalSecs")
+            .and_then(Value::as_u64)
+        {
+            config.health_check_interval_secs = interval;
+        }
+        if let Some(pool) = lsp_settings.get("pool") {
+            config.pool_config = ConnectionPoolConfig::from_settings(pool);
+        }
+        if let Some(timeout) = lsp_settings.get("queryTimeoutSecs").and_then(Value::as_u64) {
+            config.query_timeout_secs = timeout;
+        }
+        if let Some(tls) = lsp_settings.get("tls") {
+            config.tls = parse_object_setting("tls", tls);
+        }
+        if let Some(ssh) = lsp_settings.get("ssh") {
+            config.ssh = parse_object_setting("ssh", ssh);
+        }
+        config.schema_file = lsp_settings
+            .get("schemaFile")
+            .and_then(Value::as_str)
+            .filter(|path| !path.is_empty())
+            .map(PathBuf::from);
+        if let Some(databases) = lsp_settings.get("databases") {
+            config.databases = parse_object_setting("databases", databases);
+        }
+        match lsp_settings.get("searchPath") {
+            Some(Value::String(path)) => {
+                config.search_path = path.split(',').map(|s| s.trim().to_string()).collect();
+            }
+            Some(Value::Array(path)) => {
+                config.search_path = path
+                    .iter()
+                    .filter_map(Value::as_str)
+                    .map(str::to_string)
+                    .collect();
+            }
+            _ => {}
+        }
+        config.search_path.retain(|schema| !schema.is_empty());
+        if let Some(identifiers) = lsp_settings.get("identifiers") {
+            config.identifier_rules = Some(parse_identifier_rules(dialect.rules(), identifiers));
+        }
+        if let Some(parameters) = lsp_settings.get("parameters") {
+            config.parameters = ParameterConfig::from_settings(parameters);
+        }
+        config.log_level = lsp_settings
+            .get("log")
+            .and_then(|log| log.get("level"))
+            .and_then(Value::as_str)
+            .map(str::to_string);
+        if let Some(cache) = lsp_settings.get("schemaCache").and_then(Value::as_bool) {
+            config.cache_enabled = cache;
+        }
+        if let Some(ttl) = lsp_settings
+            .get("schemaCacheTtlSecs")
+            .and_then(Value::as_u64)
+        {
+            config.schema_cache_ttl_secs = ttl;
+        }
+        Some(config)
     }
 
     /// Default config used when client settings have not arrived yet.
@@ -375,6 +851,55 @@ impl EngineConfig {
     }
 }
 
+/// Parse a diagnostic severity setting
+///
+/// Accepts "error", "warning", "information" and "hint"; "off" returns `None`.
+/// Unknown values fall back to warning.
+pub fn parse_severity_setting(value: &str) -> Option<DiagnosticSeverity> {
+    match value {
+        "off" => None,
+        "error" => Some(DiagnosticSeverity::ERROR),
+        "information" => Some(DiagnosticSeverity::INFORMATION),
+        "hint" => Some(DiagnosticSeverity::HINT),
+        _ => Some(DiagnosticSeverity::WARNING),
+    }
+}
+
+/// Parse the `identifiers` setting over the rules of the dialect
+///
+/// Missing and malformed fields keep the rule of the dialect.
+fn parse_identifier_rules(mut rules: DialectRules, value: &Value) -> DialectRules {
+    match value.get("fold").and_then(Value::as_str) {
+        Some("lower") => rules.fold = IdentifierCase::Lower,
+        Some("upper") => rules.fold = IdentifierCase::Upper,
+        Some("preserve") => rules.fold = IdentifierCase::Preserve,
+        Some(other) => warn!("Ignoring unknown identifier fold '{}'", other),
+        None => {}
+    }
+    let mut quote = value
+        .get("quote")
+        .and_then(Value::as_str)
+        .unwrap_or_default()
+        .chars();
+    if let (Some(c), None) = (quote.next(), quote.next()) {
+        rules.quote = c;
+    }
+    if let Some(sensitive) = value.get("quotedCaseSensitive").and_then(Value::as_bool) {
+        rules.quoted_case_sensitive = sensitive;
+    }
+    if let Some(sensitive) = value.get("caseSensitiveTables").and_then(Value::as_bool) {
+        rules.case_sensitive_tables = sensitive;
+    }
+    rules
+}
+
+/// Parse an object setting, ignoring it (with a warning) if it is malformed
+fn parse_object_setting<T: DeserializeOwned>(name: &str, value: &Value) -> Option<T> {
+    serde_json::from_value(value.clone())
+        .map_err(|e| warn!("Ignoring invalid '{}' setting: {}", name, e))
+        .ok()
+}
+
 /// Configuration errors
 #[derive(Debug, thiserror::Error)]
 pub enum ConfigError {
@@ -397,6 +922,10 @@ pub enum ConfigError {
     #[error("Invalid pool configuration: {reason}")]
     InvalidPoolConfig { reason: String },
 
+    /// Invalid diagnostic severity overrides
+    #[error("Invalid diagnostics configuration: {reason}")]
+    InvalidDiagnosticsConfig { reason: String },
+
     /// Catalog-related error
     #[error("Catalog error: {0}")]
     CatalogError(#[from] CatalogError),
@@ -42,11 +42,417 @@
 //!     .collect();
 //! ```
 
+use std::collections::HashMap;
 use std::sync::Arc;
 use tokio::sync::Mutex;
 use tower_lsp::lsp_types::*;
-use tracing::{debug, info};
-use unified_sql_lsp_semantic::SyntaxDiagnosticAnalyzer;
+use tracing::{debug, warn};
+use unified_sql_lsp_catalog::Catalog;
+use unified_sql_lsp_ir::DialectRules;
+
+use crate::client_capabilities::ClientFeatures;
+use crate::config::ConfigError;
+use crate::lint::{
+    LINT_DIAGNOSTIC_SOURCE, LintConfig, apply_suppressions, glob_match, lint_document,
+};
+use crate::migrations::MIGRATION_DIAGNOSTIC_SOURCE;
+use crate::parameters::{ParameterStyles, find_parameters, suppress_parameter_diagnostics};
+use unified_sql_lsp_semantic::{
+    SchemaDiagnosticAnalyzer, SchemaDiagnosticKind, SchemaReferences, SyntaxDiagnosticAnalyzer,
+    SyntaxRange,
+};
+
+/// Diagnostic source for schema validation (unknown tables/columns)
+pub const SCHEMA_DIAGNOSTIC_SOURCE: &str = "sql-schema";
+
+/// Diagnostic sources run together
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct DiagnosticSources {
+    /// Syntax errors
+    pub syntax: bool,
+
+    /// Schema validation (unknown tables/columns), queries the catalog
+    pub schema: bool,
+
+    /// Lint rules
+    pub lint: bool,
+}
+
+impl DiagnosticSources {
+    /// All diagnostic sources
+    pub const ALL: Self = Self {
+        syntax: true,
+        schema: true,
+        lint: true,
+    };
+
+    /// Parse a list of source names (`"syntax"`, `"schema"`, `"lint"`)
+    ///
+    /// Unknown names are ignored with a warning. Returns `None` if the
+    /// setting is not a list.
+    pub fn from_settings(settings: &serde_json::Value) -> Option<Self> {
+        let mut sources = Self {
+            syntax: false,
+            schema: false,
+            lint: false,
+        };
+        for name in settings.as_array()? {
+            match name.as_str() {
+                Some("syntax") => sources.syntax = true,
+                Some("schema") => sources.schema = true,
+                Some("lint") => sources.lint = true,
+                _ => warn!("Ignoring unknown diagnostic source {}", name),
+            }
+        }
+        Some(sources)
+    }
+}
+
+/// Names of the diagnostic sources in the `diagnostics` settings
+const SOURCE_NAMES: [&str; 4] = ["syntax", "schema", "lint", "migrations"];
+
+/// Severity override of a diagnostic source or code
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum SeverityOverride {
+    /// Drop the diagnostics
+    Off,
+
+    /// Keep the severity assigned by the source
+    Default,
+
+    /// Publish the diagnostics at this severity
+    Severity(DiagnosticSeverity),
+}
+
+impl SeverityOverride {
+    /// Parse an override setting
+    ///
+    /// Accepts "error", "warning", "information", "hint" and "off", or a
+    /// boolean enabling (`true`, keeping the source's severity) or disabling
+    /// (`false`) the diagnostics.
+    ///
+    /// # Returns
+    ///
+    /// `None` for unknown values
+    pub fn from_setting(value: &serde_json::Value) -> Option<Self> {
+        match value {
+            serde_json::Value::Bool(true) => Some(Self::Default),
+            serde_json::Value::Bool(false) => Some(Self::Off),
+            serde_json::Value::String(name) => match name.as_str() {
+                "off" => Some(Self::Off),
+                "error" => Some(Self::Severity(DiagnosticSeverity::ERROR)),
+                "warning" => Some(Self::Severity(DiagnosticSeverity::WARNING)),
+                "information" => Some(Self::Severity(DiagnosticSeverity::INFORMATION)),
+                "hint" => Some(Self::Severity(DiagnosticSeverity::HINT)),
+                _ => None,
+            },
+            _ => None,
+        }
+    }
+}
+
+/// Severity overrides by source name and diagnostic code
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct SeverityOverrides {
+    /// Overrides by source name (`"syntax"`, `"schema"`, `"lint"`, `"migrations"`)
+    pub sources: HashMap<String, SeverityOverride>,
+
+    /// Overrides by diagnostic code (e.g. `"SEMANTIC-002"`), upper case
+    pub codes: HashMap<String, SeverityOverride>,
+}
+
+impl SeverityOverrides {
+    /// Parse the `sources` and `rules` objects of a settings object
+    fn from_settings(settings: &serde_json::Value) -> Result<Self, ConfigError> {
+        let mut overrides = Self::default();
+
+        for (name, value) in settings_object(settings, "sources")? {
+            if !SOURCE_NAMES.contains(&name.as_str()) {
+                return Err(invalid_diagnostics(format!(
+                    "unknown source '{}' (expected one of {})",
+                    name,
+                    SOURCE_NAMES.join(", ")
+                )));
+            }
+            overrides
+                .sources
+                .insert(name.clone(), parse_override(name, value)?);
+        }
+        for (code, value) in settings_object(settings, "rules")? {
+            overrides
+                .codes
+                .insert(code.to_uppercase(), parse_override(code, value)?);
+        }
+
+        Ok(overrides)
+    }
+
+    /// Apply the overrides of a diagnostic on top of `current`; a code
+    /// override beats a source override
+    fn resolve(&self, source: &str, code: Option<&str>, current: &mut SeverityOverride) {
+        if let Some(overridden) = self.sources.get(source) {
+            *current = *overridden;
+        }
+        if let Some(overridden) = code.and_then(|code| self.codes.get(&code.to_uppercase())) {
+            *current = *overridden;
+        }
+    }
+}
+
+/// Severity overrides applying to the files matching a glob
+#[derive(Debug, Clone, PartialEq)]
+pub struct DiagnosticsOverride {
+    /// Glob pattern of files the override applies to
+    pub files: String,
+
+    /// Overrides for these files
+    pub overrides: SeverityOverrides,
+}
+
+/// Severities of published diagnostics
+///
+/// Overrides apply to every source's diagnostics right before they are
+/// published:
+///
+/// ```json
+/// "diagnostics": {
+///   "sources": { "schema": "error", "lint": false },
+///   "rules": { "SEMANTIC-002": "hint" },
+///   "overrides": [
+///     { "files": "reports/**", "rules": { "SEMANTIC-002": "off" } }
+///   ]
+/// }
+/// ```
+///
+/// Path overrides are applied from the least to the most specific pattern
+/// (the one with the most literal path components, then characters), so the
+/// most specific pattern wins; patterns of equal specificity apply in order.
+/// Within one level, code overrides beat source overrides. Without
+/// overrides, diagnostics keep the severity of their source.
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct DiagnosticsConfig {
+    /// Overrides for every file
+    pub overrides: SeverityOverrides,
+
+    /// Overrides for the files matching a glob
+    pub path_overrides: Vec<DiagnosticsOverride>,
+}
+
+impl DiagnosticsConfig {
+    /// Parse the overrides from the `diagnostics` settings object
+    ///
+    /// # Returns
+    ///
+    /// An error naming the offending key for unknown sources and severities
+    pub fn from_settings(settings: &serde_json::Value) -> Result<Self, ConfigError> {
+        let mut config = Self {
+            overrides: SeverityOverrides::from_settings(settings)?,
+            path_overrides: Vec::new(),
+        };
+
+        let Some(path_overrides) = settings.get("overrides") else {
+            return Ok(config);
+        };
+        let path_overrides = path_overrides
+            .as_array()
+            .ok_or_else(|| invalid_diagnostics("'overrides' must be a list".to_string()))?;
+        for path_override in path_overrides {
+            let files = path_override
+                .get("files")
+                .and_then(serde_json::Value::as_str)
+                .ok_or_else(|| {
+                    invalid_diagnostics("every override needs a 'files' glob".to_string())
+                })?;
+            config.path_overrides.push(DiagnosticsOverride {
+                files: files.to_string(),
+                overrides: SeverityOverrides::from_settings(path_override)?,
+            });
+        }
+
+        Ok(config)
+    }
+
+    /// Get the override of a diagnostic in a document
+    ///
+    /// # Arguments
+    ///
+    /// * `source` - The source name (`"syntax"`, `"schema"`, ...)
+    /// * `code` - The diagnostic code
+    /// * `path` - The document path (matched against the override globs)
+    pub fn resolve(&self, source: &str, code: Option<&str>, path: &str) -> SeverityOverride {
+        let mut matching: Vec<&DiagnosticsOverride> = self
+            .path_overrides
+            .iter()
+            .filter(|o| glob_match(&o.files, path))
+            .collect();
+        matching.sort_by_key(|o| glob_specificity(&o.files));
+
+        let mut resolved = SeverityOverride::Default;
+        self.overrides.resolve(source, code, &mut resolved);
+        for o in matching {
+            o.overrides.resolve(source, code, &mut resolved);
+        }
+        resolved
+    }
+
+    /// Apply the overrides to the diagnostics of a document
+    ///
+    /// # Arguments
+    ///
+    /// * `diagnostics` - The diagnostics to publish
+    /// * `uri` - The document URI
+    ///
+    /// # Returns
+    ///
+    /// The diagnostics with their overridden severity, without those turned off
+    pub fn apply(&self, diagnostics: Vec<SqlDiagnostic>, uri: &Url) -> Vec<SqlDiagnostic> {
+        if *self == Self::default() {
+            return diagnostics;
+        }
+
+        diagnostics
+            .into_iter()
+            .filter_map(|mut diagnostic| {
+                let code = diagnostic.code.as_ref().map(DiagnosticCode::as_str);
+                match self.resolve(source_name(&diagnostic), code.as_deref(), uri.path()) {
+                    SeverityOverride::Off => return None,
+                    SeverityOverride::Default => {}
+                    SeverityOverride::Severity(severity) => diagnostic.severity = severity,
+                }
+                Some(diagnostic)
+            })
+            .collect()
+    }
+}
+
+/// Source name of a diagnostic in the `diagnostics` settings
+fn source_name(diagnostic: &SqlDiagnostic) -> &'static str {
+    match diagnostic.source.as_str() {
+        SCHEMA_DIAGNOSTIC_SOURCE => "schema",
+        LINT_DIAGNOSTIC_SOURCE => "lint",
+        MIGRATION_DIAGNOSTIC_SOURCE => "migrations",
+        _ => "syntax",
+    }
+}
+
+/// Specificity of a glob: literal components, then literal characters
+fn glob_specificity(pattern: &str) -> (usize, usize) {
+    let literal: Vec<&str> = pattern
+        .split('/')
+        .filter(|c| !c.is_empty() && !c.contains(['*', '?']))
+        .collect();
+    let characters = pattern
+        .chars()
+        .filter(|c| !matches!(c, '*' | '?' | '/'))
+        .count();
+    (literal.len(), characters)
+}
+
+/// Entries of an optional object setting
+fn settings_object<'a>(
+    settings: &'a serde_json::Value,
+    key: &str,
+) -> Result<Vec<(&'a String, &'a serde_json::Value)>, ConfigError> {
+    match settings.get(key) {
+        None => Ok(Vec::new()),
+        Some(serde_json::Value::Object(entries)) => Ok(entries.iter().collect()),
+        Some(_) => Err(invalid_diagnostics(format!("'{}' must be an object", key))),
+    }
+}
+
+/// Parse the override of a source or code
+fn parse_override(key: &str, value: &serde_json::Value) -> Result<SeverityOverride, ConfigError> {
+    SeverityOverride::from_setting(value).ok_or_else(|| {
+        invalid_diagnostics(format!(
+            "invalid severity {} for '{}' (expected error, warning, information, hint, off \
+             or a boolean)",
+            value, key
+        ))
+    })
+}
+
+fn invalid_diagnostics(reason: String) -> ConfigError {
+    ConfigError::InvalidDiagnosticsConfig { reason }
+}
+
+/// Diagnostics of a document, by source
+///
+/// Sources that did not run on the last trigger keep their previous results,
+/// so diagnostics computed on save stay visible while typing. Workspace
+/// diagnostics (migration conflicts) are computed from the files on disk and
+/// outlive the open document.
+#[derive(Debug, Clone, Default)]
+pub struct DocumentDiagnostics {
+    syntax: Vec<SqlDiagnostic>,
+    schema: Vec<SqlDiagnostic>,
+    lint: Vec<SqlDiagnostic>,
+    workspace: Vec<SqlDiagnostic>,
+}
+
+impl DocumentDiagnostics {
+    /// Create the diagnostics of one run of the sources
+    pub fn new(
+        syntax: Vec<SqlDiagnostic>,
+        schema: Vec<SqlDiagnostic>,
+        lint: Vec<SqlDiagnostic>,
+    ) -> Self {
+        Self {
+            syntax,
+            schema,
+            lint,
+            workspace: Vec::new(),
+        }
+    }
+
+    /// Replace the diagnostics of the sources that ran
+    pub fn update(&mut self, fresh: Self, ran: DiagnosticSources) {
+        if ran.syntax {
+            self.syntax = fresh.syntax;
+        }
+        if ran.schema {
+            self.schema = fresh.schema;
+        }
+        if ran.lint {
+            self.lint = fresh.lint;
+        }
+    }
+
+    /// Replace the workspace diagnostics
+    pub fn set_workspace(&mut self, diagnostics: Vec<SqlDiagnostic>) {
+        self.workspace = diagnostics;
+    }
+
+    /// Check whether workspace diagnostics are set
+    pub fn has_workspace(&self) -> bool {
+        !self.workspace.is_empty()
+    }
+
+    /// Drop the diagnostics of the document content, once it is closed
+    pub fn close(&mut self) {
+        self.syntax.clear();
+        self.schema.clear();
+        self.lint.clear();
+    }
+
+    /// Check whether no source has diagnostics
+    pub fn is_empty(&self) -> bool {
+        self.syntax.is_empty()
+            && self.schema.is_empty()
+            && self.lint.is_empty()
+            && self.workspace.is_empty()
+    }
+
+    /// Diagnostics of all sources
+    pub fn to_vec(&self) -> Vec<SqlDiagnostic> {
+        self.syntax
+            .iter()
+            .chain(&self.schema)
+            .chain(&self.lint)
+            .chain(&self.workspace)
+            .cloned()
+            .collect()
+    }
+}
 
 /// Diagnostic code identifying the type of diagnostic
 ///
@@ -65,6 +471,15 @@ pub enum DiagnosticCode {
     /// Ambiguous column reference (DIAG-005)
     AmbiguousColumn,
 
+    /// INSERT column list or value count does not match the target table
+    InsertColumnMismatch,
+
+    /// Literal cannot be stored in the column it is inserted into or assigned to
+    TypeMismatch,
+
+    /// Migration changes a table or column that does not exist
+    MigrationConflict,
+
     /// Custom diagnostic code with description
     Custom(String),
 }
@@ -77,6 +492,9 @@ impl DiagnosticCode {
             DiagnosticCode::UndefinedTable => "SEMANTIC-001".to_string(),
             DiagnosticCode::UndefinedColumn => "SEMANTIC-002".to_string(),
             DiagnosticCode::AmbiguousColumn => "SEMANTIC-003".to_string(),
+            DiagnosticCode::InsertColumnMismatch => "SEMANTIC-004".to_string(),
+            DiagnosticCode::TypeMismatch => "SEMANTIC-005".to_string(),
+            DiagnosticCode::MigrationConflict => "MIGRATION-001".to_string(),
             DiagnosticCode::Custom(s) => s.clone(),
         }
     }
@@ -88,6 +506,11 @@ impl DiagnosticCode {
             DiagnosticCode::UndefinedTable => "Undefined table reference".to_string(),
             DiagnosticCode::UndefinedColumn => "Undefined column reference".to_string(),
             DiagnosticCode::AmbiguousColumn => "Ambiguous column reference".to_string(),
+            DiagnosticCode::InsertColumnMismatch => {
+                "INSERT does not match target table".to_string()
+            }
+            DiagnosticCode::TypeMismatch => "Value does not match column type".to_string(),
+            DiagnosticCode::MigrationConflict => "Conflicting migration".to_string(),
             DiagnosticCode::Custom(s) => format!("Custom diagnostic: {}", s),
         }
     }
@@ -117,11 +540,15 @@ pub struct SqlDiagnostic {
     /// Diagnostic code
     pub code: Option<DiagnosticCode>,
 
-    /// Source of the diagnostic (always "unified-sql-lsp")
+    /// Source of the diagnostic ("unified-sql-lsp", or "sql-schema" for
+    /// schema validation)
     pub source: String,
 
     /// Related information (e.g., suggestions, related locations)
     pub related_information: Option<Vec<DiagnosticRelatedInformation>>,
+
+    /// Data preserved for code actions on this diagnostic
+    pub data: Option<serde_json::Value>,
 }
 
 impl SqlDiagnostic {
@@ -140,6 +567,7 @@ impl SqlDiagnostic {
             code: None,
             source: "unified-sql-lsp".to_string(),
             related_information: None,
+            data: None,
         }
     }
 
@@ -149,6 +577,18 @@ impl SqlDiagnostic {
         self
     }
 
+    /// Set the diagnostic source
+    pub fn with_source(mut self, source: impl Into<String>) -> Self {
+        self.source = source.into();
+        self
+    }
+
+    /// Attach data for code actions
+    pub fn with_data(mut self, data: serde_json::Value) -> Self {
+        self.data = Some(data);
+        self
+    }
+
     /// Add related information
     pub fn with_related(mut self, related: Vec<DiagnosticRelatedInformation>) -> Self {
         self.related_information = Some(related);
@@ -166,7 +606,7 @@ impl SqlDiagnostic {
             message: self.message,
             related_information: self.related_information,
             tags: None,
-            data: None,
+            data: self.data,
         }
     }
 
@@ -226,6 +666,7 @@ pub fn node_to_range(node: &tree_sitter::Node) -> Range {
 #[derive(Debug, Clone, Default)]
 pub struct DiagnosticCollector {
     syntax_analyzer: SyntaxDiagnosticAnalyzer,
+    schema_analyzer: SchemaDiagnosticAnalyzer,
 }
 
 impl DiagnosticCollector {
@@ -233,6 +674,7 @@ impl DiagnosticCollector {
     pub fn new() -> Self {
         Self {
             syntax_analyzer: SyntaxDiagnosticAnalyzer::new(),
+            schema_analyzer: SchemaDiagnosticAnalyzer::new(),
         }
     }
 
@@ -269,24 +711,105 @@ impl DiagnosticCollector {
             .collect_diagnostics(tree, source)
             .into_iter()
             .map(|d| {
-                SqlDiagnostic::error(
-                    d.message,
-                    Range {
-                        start: Position {
-                            line: d.range.start_line,
-                            character: d.range.start_character,
-                        },
-                        end: Position {
-                            line: d.range.end_line,
-                            character: d.range.end_character,
-                        },
-                    },
-                )
-                .with_code(DiagnosticCode::SyntaxError)
+                SqlDiagnostic::error(d.message, syntax_range_to_lsp(d.range))
+                    .with_code(DiagnosticCode::SyntaxError)
+            })
+            .collect()
+    }
+
+    /// Collect schema diagnostics (unknown tables/columns, INSERT mismatches)
+    ///
+    /// # Arguments
+    ///
+    /// - `tree`: The Arc<Mutex<Tree>> from the document
+    /// - `source`: The source code text
+    /// - `catalog`: The catalog to validate against
+    /// - `severity`: Severity assigned to every schema diagnostic
+    /// - `rules`: Rules table and column names are compared with
+    ///
+    /// # Returns
+    ///
+    /// A vector of SQL diagnostics with source "sql-schema". Empty when the
+    /// tree is unavailable or the catalog cannot be queried, so stale or
+    /// missing schema information never produces false positives.
+    pub async fn collect_schema_diagnostics(
+        &self,
+        tree: &Option<Arc<Mutex<tree_sitter::Tree>>>,
+        source: &str,
+        catalog: &dyn Catalog,
+        severity: DiagnosticSeverity,
+        rules: DialectRules,
+    ) -> Vec<SqlDiagnostic> {
+        let Some(references) = self.collect_schema_references(tree, source) else {
+            return Vec::new();
+        };
+
+        let analyzer = SchemaDiagnosticAnalyzer::with_rules(rules);
+        let diagnostics = match analyzer.check(&references, catalog).await {
+            Ok(diagnostics) => diagnostics,
+            Err(e) => {
+                debug!("Schema diagnostics suppressed, catalog unavailable: {}", e);
+                return Vec::new();
+            }
+        };
+
+        diagnostics
+            .into_iter()
+            .map(|d| {
+                let code = match d.kind {
+                    SchemaDiagnosticKind::UnknownTable => DiagnosticCode::UndefinedTable,
+                    SchemaDiagnosticKind::UnknownColumn => DiagnosticCode::UndefinedColumn,
+                    SchemaDiagnosticKind::InsertColumnMismatch => {
+                        DiagnosticCode::InsertColumnMismatch
+                    }
+                    SchemaDiagnosticKind::TypeMismatch => DiagnosticCode::TypeMismatch,
+                };
+                SqlDiagnostic::new(d.message, severity, syntax_range_to_lsp(d.range))
+                    .with_code(code)
+                    .with_source(SCHEMA_DIAGNOSTIC_SOURCE)
             })
             .collect()
     }
 
+    /// Collect lint diagnostics (dangerous statements)
+    ///
+    /// # Arguments
+    ///
+    /// - `tree`: The Arc<Mutex<Tree>> from the document
+    /// - `source`: The source code text
+    /// - `uri`: The document URI
+    /// - `config`: The lint configuration
+    ///
+    /// # Returns
+    ///
+    /// A vector of SQL diagnostics with source "sql-lint", or empty if
+    /// linting is disabled or the tree is unavailable
+    pub fn collect_lint_diagnostics(
+        &self,
+        tree: &Option<Arc<Mutex<tree_sitter::Tree>>>,
+        source: &str,
+        uri: &Url,
+        config: &LintConfig,
+    ) -> Vec<SqlDiagnostic> {
+        if !config.enabled {
+            return Vec::new();
+        }
+        let Some(tree_guard) = tree.as_ref().and_then(|t| t.try_lock().ok()) else {
+            return Vec::new();
+        };
+        lint_document(&tree_guard, source, uri, config)
+    }
+
+    /// Extract schema references without holding the tree lock across awaits
+    fn collect_schema_references(
+        &self,
+        tree: &Option<Arc<Mutex<tree_sitter::Tree>>>,
+        source: &str,
+    ) -> Option<SchemaReferences> {
+        let tree_guard = tree.as_ref()?.try_lock().ok()?;
+        Some(self.schema_analyzer.collect_references(&tree_guard, source))
+    }
+
     #[cfg(test)]
     fn is_missing_comma_pattern(&self, text: &str) -> bool {
         self.syntax_analyzer.is_missing_comma_pattern(text)
@@ -343,44 +866,107 @@ impl DiagnosticCollector {
     }
 }
 
-/// Helper to publish diagnostics from a document
+/// Convert an analyzer range to an LSP Range
+fn syntax_range_to_lsp(range: SyntaxRange) -> Range {
+    Range {
+        start: Position {
+            line: range.start_line,
+            character: range.start_character,
+        },
+        end: Position {
+            line: range.end_line,
+            character: range.end_character,
+        },
+    }
+}
+
+/// Run the diagnostic sources of a document
 ///
-/// This function handles the common pattern of:
-/// 1. Getting the document tree
-/// 2. Collecting diagnostics
-/// 3. Converting to LSP format
-/// 4. Publishing to client
+/// Runs without touching the diagnostics published last, so callers only
+/// need to lock those to merge the result (see
+/// [`DocumentDiagnostics::update`]).
 ///
 /// # Arguments
 ///
 /// - `collector`: The diagnostic collector
-/// - `client`: The LSP client
 /// - `uri`: The document URI
 /// - `tree`: The optional tree from document
 /// - `source`: The source code
+/// - `schema`: Catalog and severity for schema diagnostics, or `None` to skip them
+/// - `lint`: Lint configuration, or `None` to skip linting
+/// - `sources`: Diagnostic sources to run
 ///
 /// # Returns
 ///
-/// The number of diagnostics published
-pub async fn publish_diagnostics_for_document(
+/// The diagnostics of the sources that ran
+pub async fn collect_document_diagnostics(
     collector: &DiagnosticCollector,
-    client: &tower_lsp::Client,
-    uri: Url,
+    uri: &Url,
     tree: &Option<Arc<Mutex<tree_sitter::Tree>>>,
     source: &str,
-) -> usize {
-    let sql_diagnostics = collector.collect_from_arc(tree, source, &uri);
+    schema: Option<(&dyn Catalog, DiagnosticSeverity, DialectRules)>,
+    lint: Option<&LintConfig>,
+    sources: DiagnosticSources,
+) -> DocumentDiagnostics {
+    let mut fresh = DocumentDiagnostics::default();
+
+    if sources.syntax {
+        fresh.syntax = collector.collect_from_arc(tree, source, uri);
+    }
 
-    let diagnostics: Vec<Diagnostic> = sql_diagnostics.into_iter().map(|d| d.to_lsp()).collect();
+    if sources.lint
+        && let Some(config) = lint
+    {
+        fresh.lint = collector.collect_lint_diagnostics(tree, source, uri, config);
+    }
 
-    let count = diagnostics.len();
-    if count > 0 {
-        info!("Publishing {} diagnostics for {}", count, uri);
+    if sources.schema
+        && let Some((catalog, severity, rules)) = schema
+    {
+        fresh.schema = collector
+            .collect_schema_diagnostics(tree, source, catalog, severity, rules)
+            .await;
     }
 
-    client.publish_diagnostics(uri, diagnostics, None).await;
+    fresh
+}
 
-    count
+/// Diagnostics of a document as published to the client
+///
+/// Every publish of a document goes through here, so the filters below
+/// apply whatever triggered it (an edit, a save, a migration reload):
+///
+/// - severity overrides are applied, and diagnostics turned off dropped;
+/// - syntax and schema diagnostics on bind parameters are dropped;
+/// - diagnostics suppressed by a `-- sql-lsp: disable-next-line` comment
+///   are dropped;
+/// - diagnostic fields the client does not support are removed.
+///
+/// # Arguments
+///
+/// - `document`: Diagnostics of the document, by source
+/// - `uri`: The document URI
+/// - `source`: The source code, empty for a closed document
+/// - `parameters`: Bind parameter styles of the document
+/// - `severities`: Severity overrides
+/// - `features`: Client features
+pub fn diagnostics_to_publish(
+    document: &DocumentDiagnostics,
+    uri: &Url,
+    source: &str,
+    parameters: ParameterStyles,
+    severities: &DiagnosticsConfig,
+    features: &ClientFeatures,
+) -> Vec<Diagnostic> {
+    let sql_diagnostics = suppress_parameter_diagnostics(
+        severities.apply(document.to_vec(), uri),
+        &find_parameters(source, parameters),
+    );
+
+    apply_suppressions(sql_diagnostics, source)
+        .into_iter()
+        .map(|d| features.adapt_diagnostic(d.to_lsp()))
+        .collect()
 }
 
 #[cfg(test)]
@@ -461,6 +1047,11 @@ mod tests {
         assert_eq!(DiagnosticCode::UndefinedTable.as_str(), "SEMANTIC-001");
         assert_eq!(DiagnosticCode::UndefinedColumn.as_str(), "SEMANTIC-002");
         assert_eq!(DiagnosticCode::AmbiguousColumn.as_str(), "SEMANTIC-003");
+        assert_eq!(
+            DiagnosticCode::InsertColumnMismatch.as_str(),
+            "SEMANTIC-004"
+        );
+        assert_eq!(DiagnosticCode::TypeMismatch.as_str(), "SEMANTIC-005");
         assert_eq!(
             DiagnosticCode::Custom("CUSTOM-123".to_string()).as_str(),
             "CUSTOM-123"
@@ -534,6 +1125,67 @@ mod tests {
         assert!(diagnostics.is_empty());
     }
 
+    #[tokio::test]
+    async fn test_collect_schema_diagnostics() {
+        use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+        let Some(language) =
+            unified_sql_grammar::language_for_dialect(unified_sql_lsp_ir::Dialect::MySQL)
+        else {
+            return;
+        };
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(language).unwrap();
+
+        let sql = "SELECT nickname FROM users";
+        let tree = Some(Arc::new(Mutex::new(parser.parse(sql, None).unwrap())));
+        let catalog = MockCatalogBuilder::new().with_standard_schema().build();
+
+        let collector = DiagnosticCollector::new();
+        let diagnostics = collector
+            .collect_schema_diagnostics(
+                &tree,
+                sql,
+                &catalog,
+                DiagnosticSeverity::WARNING,
+                DialectRules::default(),
+            )
+            .await;
+
+        assert_eq!(diagnostics.len(), 1);
+        assert_eq!(diagnostics[0].source, SCHEMA_DIAGNOSTIC_SOURCE);
+        assert_eq!(diagnostics[0].severity, DiagnosticSeverity::WARNING);
+        assert_eq!(diagnostics[0].code, Some(DiagnosticCode::UndefinedColumn));
+        assert_eq!(diagnostics[0].range, create_test_range(0, 7, 0, 15));
+    }
+
+    #[tokio::test]
+    async fn test_collect_schema_diagnostics_offline_suppressed() {
+        let Some(language) =
+            unified_sql_grammar::language_for_dialect(unified_sql_lsp_ir::Dialect::MySQL)
+        else {
+            return;
+        };
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(language).unwrap();
+
+        let sql = "SELECT id FROM customers";
+        let tree = Some(Arc::new(Mutex::new(parser.parse(sql, None).unwrap())));
+
+        let collector = DiagnosticCollector::new();
+        let diagnostics = collector
+            .collect_schema_diagnostics(
+                &tree,
+                sql,
+                &unified_sql_lsp_catalog::OfflineCatalog::new(),
+                DiagnosticSeverity::WARNING,
+                DialectRules::default(),
+            )
+            .await;
+
+        assert!(diagnostics.is_empty());
+    }
+
     // Tests for pattern detection helpers
 
     #[test]
@@ -682,4 +1334,171 @@ mod tests {
             }
         }
     }
+
+    #[test]
+    fn test_diagnostic_sources_from_settings() {
+        let sources =
+            DiagnosticSources::from_settings(&serde_json::json!(["syntax", "lint", "typo"]))
+                .unwrap();
+
+        assert!(sources.syntax);
+        assert!(!sources.schema);
+        assert!(sources.lint);
+        assert_eq!(
+            DiagnosticSources::from_settings(&serde_json::json!("all")),
+            None
+        );
+    }
+
+    #[test]
+    fn test_sources_that_did_not_run_keep_their_diagnostics() {
+        let range = create_test_range(0, 0, 0, 1);
+        let diagnostics = |message: &str| vec![SqlDiagnostic::error(message.to_string(), range)];
+
+        let mut document = DocumentDiagnostics::default();
+        document.update(
+            DocumentDiagnostics {
+                syntax: diagnostics("syntax on save"),
+                schema: diagnostics("schema on save"),
+                lint: vec![],
+                workspace: vec![],
+            },
+            DiagnosticSources::ALL,
+        );
+
+        // Typing runs syntax checks only
+        let on_type = DiagnosticSources {
+            syntax: true,
+            schema: false,
+            lint: false,
+        };
+        document.update(
+            DocumentDiagnostics {
+                syntax: vec![],
+                ..Default::default()
+            },
+            on_type,
+        );
+
+        let messages: Vec<String> = document.to_vec().into_iter().map(|d| d.message).collect();
+        assert_eq!(messages, vec!["schema on save"]);
+    }
+
+    #[test]
+    fn test_workspace_diagnostics_outlive_the_document() {
+        let range = create_test_range(0, 0, 0, 1);
+        let mut document = DocumentDiagnostics::default();
+        document.update(
+            DocumentDiagnostics {
+                syntax: vec![SqlDiagnostic::error("syntax".to_string(), range)],
+                ..Default::default()
+            },
+            DiagnosticSources::ALL,
+        );
+        document.set_workspace(vec![SqlDiagnostic::error("conflict".to_string(), range)]);
+
+        document.close();
+
+        let messages: Vec<String> = document.to_vec().into_iter().map(|d| d.message).collect();
+        assert_eq!(messages, vec!["conflict"]);
+        document.set_workspace(vec![]);
+        assert!(document.is_empty());
+    }
+
+    fn severities(settings: serde_json::Value) -> DiagnosticsConfig {
+        DiagnosticsConfig::from_settings(&settings).unwrap()
+    }
+
+    fn unknown_column() -> SqlDiagnostic {
+        SqlDiagnostic::warning("Unknown column".to_string(), create_test_range(0, 0, 0, 1))
+            .with_code(DiagnosticCode::UndefinedColumn)
+            .with_source(SCHEMA_DIAGNOSTIC_SOURCE)
+    }
+
+    #[test]
+    fn test_severity_overrides_default_to_current_behaviour() {
+        let uri = Url::parse("file:///proj/q.sql").unwrap();
+        let config =
+            DiagnosticsConfig::from_settings(&serde_json::json!({ "onType": ["syntax"] })).unwrap();
+
+        assert_eq!(config, DiagnosticsConfig::default());
+        let diagnostics = config.apply(vec![unknown_column()], &uri);
+        assert_eq!(diagnostics[0].severity, DiagnosticSeverity::WARNING);
+    }
+
+    #[test]
+    fn test_code_override_beats_source_override() {
+        let config = severities(serde_json::json!({
+            "sources": { "schema": "error", "syntax": false },
+            "rules": { "semantic-002": "hint" }
+        }));
+
+        assert_eq!(
+            config.resolve("schema", Some("SEMANTIC-002"), "/proj/q.sql"),
+            SeverityOverride::Severity(DiagnosticSeverity::HINT)
+        );
+        assert_eq!(
+            config.resolve("schema", Some("SEMANTIC-001"), "/proj/q.sql"),
+            SeverityOverride::Severity(DiagnosticSeverity::ERROR)
+        );
+        assert_eq!(
+            config.resolve("syntax", Some("SYNTAX-001"), "/proj/q.sql"),
+            SeverityOverride::Off
+        );
+        assert_eq!(
+            config.resolve("lint", Some("SQLLINT003"), "/proj/q.sql"),
+            SeverityOverride::Default
+        );
+    }
+
+    #[test]
+    fn test_most_specific_glob_wins() {
+        let overrides = serde_json::json!([
+            { "files": "reports/daily/*.sql", "rules": { "SEMANTIC-002": "error" } },
+            { "files": "**/*.sql", "rules": { "SEMANTIC-002": "information" } },
+            { "files": "reports/**", "rules": { "SEMANTIC-002": "off" } }
+        ]);
+        let config = severities(serde_json::json!({
+            "rules": { "SEMANTIC-002": "hint" },
+            "overrides": overrides
+        }));
+        let resolve = |path| config.resolve("schema", Some("SEMANTIC-002"), path);
+
+        assert_eq!(
+            resolve("/proj/reports/daily/a.sql"),
+            SeverityOverride::Severity(DiagnosticSeverity::ERROR)
+        );
+        assert_eq!(resolve("/proj/reports/weekly/a.sql"), SeverityOverride::Off);
+        assert_eq!(
+            resolve("/proj/queries/a.sql"),
+            SeverityOverride::Severity(DiagnosticSeverity::INFORMATION)
+        );
+        assert_eq!(
+            resolve("/proj/queries/a.txt"),
+            SeverityOverride::Severity(DiagnosticSeverity::HINT)
+        );
+
+        let uri = Url::parse("file:///proj/reports/weekly/a.sql").unwrap();
+        assert!(config.apply(vec![unknown_column()], &uri).is_empty());
+    }
+
+    #[test]
+    fn test_invalid_severity_names_are_rejected() {
+        for settings in [
+            serde_json::json!({ "sources": { "schema": "critical" } }),
+            serde_json::json!({ "sources": { "typo": "error" } }),
+            serde_json::json!({ "rules": { "SEMANTIC-002": 1 } }),
+            serde_json::json!({ "overrides": [{ "rules": {} }] }),
+            serde_json::json!({ "overrides": [{ "files": "a/**", "rules": { "X": "fatal" } }] }),
+        ] {
+            assert!(
+                matches!(
+                    DiagnosticsConfig::from_settings(&settings),
+                    Err(ConfigError::InvalidDiagnosticsConfig { .. })
+                ),
+                "accepted {}",
+                settings
+            );
+        }
+    }
 }
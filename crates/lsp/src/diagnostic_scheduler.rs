@@ -0,0 +1,377 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Diagnostic scheduling
+//!
+//! This module bounds the number of diagnostic runs in flight across all
+//! documents and drops runs made stale by a newer edit:
+//!
+//! ```text,ignore
+//! didChange a.sql ─┐
+//! didChange b.sql ─┼─→ queue: b.sql, a.sql ─→ at most N runs at a time
+//! didChange a.sql ─┘   (first a.sql run dropped)
+//! ```
+//!
+//! A run still waiting for a slot is dropped when a newer edit of the same
+//! document is scheduled; a started run completes. During a burst of edits
+//! (e.g. a project-wide find-and-replace) each document is therefore
+//! diagnosed at most twice. When a slot frees up, the focused document goes
+//! first: the last one edited, or the one named by `sql/focusDocument`.
+
+use serde::{Deserialize, Serialize};
+use std::collections::VecDeque;
+use std::future::Future;
+use std::sync::Mutex;
+use tokio::sync::oneshot;
+use tower_lsp::lsp_types::Url;
+
+/// Default number of diagnostic runs in flight
+pub const DEFAULT_MAX_CONCURRENT_RUNS: usize = 4;
+
+/// Custom notification naming the document the user is looking at
+pub const FOCUS_DOCUMENT_METHOD: &str = "sql/focusDocument";
+
+/// Parameters of `sql/focusDocument`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FocusDocumentParams {
+    /// The focused document
+    pub uri: Url,
+}
+
+/// Scheduler of the diagnostic runs of all documents
+#[derive(Debug)]
+pub struct DiagnosticScheduler {
+    max_concurrent: usize,
+    state: Mutex<SchedulerState>,
+}
+
+#[derive(Debug, Default)]
+struct SchedulerState {
+    /// Runs in flight
+    running: usize,
+
+    /// Runs waiting for a slot, in scheduling order
+    queue: VecDeque<QueuedRun>,
+
+    /// Document whose runs go first
+    focused: Option<Url>,
+
+    /// Longest queue seen
+    max_queued: usize,
+
+    /// Runs dropped in favor of a newer one
+    coalesced: u64,
+}
+
+/// Run waiting for a slot
+#[derive(Debug)]
+struct QueuedRun {
+    uri: Url,
+
+    /// Whether a newer run of the document replaces this one
+    coalesce: bool,
+
+    /// Signals the run to start; dropped when the run is replaced
+    start: oneshot::Sender<()>,
+}
+
+/// Scheduler statistics, as reported by `sql/stats`
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct SchedulerStats {
+    /// Runs in flight
+    pub running: usize,
+
+    /// Runs waiting for a slot
+    pub queued: usize,
+
+    /// Longest queue seen
+    pub max_queued: usize,
+
+    /// Runs dropped in favor of a newer run of the same document
+    pub coalesced: u64,
+}
+
+impl DiagnosticScheduler {
+    /// Create a scheduler
+    ///
+    /// # Arguments
+    ///
+    /// * `max_concurrent` - Maximum number of runs in flight (at least 1)
+    pub fn new(max_concurrent: usize) -> Self {
+        Self {
+            max_concurrent: max_concurrent.max(1),
+            state: Mutex::new(SchedulerState::default()),
+        }
+    }
+
+    /// Give the runs of a document priority over the others
+    pub fn set_focus(&self, uri: &Url) {
+        self.state.lock().unwrap().focused = Some(uri.clone());
+    }
+
+    /// Run diagnostics for an edit, unless a newer edit supersedes them
+    ///
+    /// # Returns
+    ///
+    /// `false` if the run was dropped while waiting for a slot
+    pub async fn run_latest<F, Fut>(&self, uri: &Url, run: F) -> bool
+    where
+        F: FnOnce() -> Fut,
+        Fut: Future<Output = ()>,
+    {
+        self.schedule(uri, true, run).await
+    }
+
+    /// Run diagnostics that must not be dropped (e.g. on save)
+    pub async fn run<F, Fut>(&self, uri: &Url, run: F)
+    where
+        F: FnOnce() -> Fut,
+        Fut: Future<Output = ()>,
+    {
+        self.schedule(uri, false, run).await;
+    }
+
+    /// Current statistics
+    pub fn stats(&self) -> SchedulerStats {
+        let state = self.state.lock().unwrap();
+        SchedulerStats {
+            running: state.running,
+            queued: state.queue.len(),
+            max_queued: state.max_queued,
+            coalesced: state.coalesced,
+        }
+    }
+
+    async fn schedule<F, Fut>(&self, uri: &Url, coalesce: bool, run: F) -> bool
+    where
+        F: FnOnce() -> Fut,
+        Fut: Future<Output = ()>,
+    {
+        let waiting = {
+            let mut state = self.state.lock().unwrap();
+            if coalesce {
+                // Dropping the sender tells the replaced run to give up
+                let queued = state.queue.len();
+                state.queue.retain(|run| !(run.coalesce && run.uri == *uri));
+                state.coalesced += (queued - state.queue.len()) as u64;
+            }
+
+            if state.running < self.max_concurrent && state.queue.is_empty() {
+                state.running += 1;
+                None
+            } else {
+                let (start, started) = oneshot::channel();
+                state.queue.push_back(QueuedRun {
+                    uri: uri.clone(),
+                    coalesce,
+                    start,
+                });
+                state.max_queued = state.max_queued.max(state.queue.len());
+                Some(started)
+            }
+        };
+
+        if let Some(started) = waiting {
+            let mut waiter = Waiter {
+                scheduler: self,
+                started,
+            };
+            if (&mut waiter.started).await.is_err() {
+                return false;
+            }
+        }
+
+        let _slot = Slot(self);
+        run().await;
+        true
+    }
+
+    /// Free a slot and start the next queued runs
+    fn release(&self) {
+        let mut state = self.state.lock().unwrap();
+        state.running -= 1;
+
+        while state.running < self.max_concurrent {
+            let next = state
+                .focused
+                .as_ref()
+                .and_then(|focused| state.queue.iter().position(|run| run.uri == *focused))
+                .unwrap_or(0);
+            let Some(run) = state.queue.remove(next) else {
+                break;
+            };
+            // The waiter may have gone away; its slot goes to the next run.
+            // Once sent, the slot belongs to the waiter (see `Waiter`).
+            if run.start.send(()).is_ok() {
+                state.running += 1;
+            }
+        }
+    }
+}
+
+impl Default for DiagnosticScheduler {
+    fn default() -> Self {
+        Self::new(DEFAULT_MAX_CONCURRENT_RUNS)
+    }
+}
+
+/// Slot held by a running run, freed when the run ends
+struct Slot<'a>(&'a DiagnosticScheduler);
+
+impl Drop for Slot<'_> {
+    fn drop(&mut self) {
+        self.0.release();
+    }
+}
+
+/// Queued run, freeing its slot if dropped after being started
+///
+/// A run is started by `release` but only takes its [`Slot`] once it wakes
+/// up; a run cancelled in between (request cancelled, document closed) would
+/// otherwise keep the slot forever.
+struct Waiter<'a> {
+    scheduler: &'a DiagnosticScheduler,
+    started: oneshot::Receiver<()>,
+}
+
+impl Drop for Waiter<'_> {
+    fn drop(&mut self) {
+        // Closing first makes a later start fail, so it frees the slot itself.
+        // After a normal wake-up the value was consumed and nothing is left.
+        self.started.close();
+        if self.started.try_recv().is_ok() {
+            self.scheduler.release();
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use futures_util::FutureExt;
+    use futures_util::future::join_all;
+    use std::collections::HashMap;
+    use std::sync::atomic::{AtomicUsize, Ordering};
+    use std::time::Duration;
+
+    fn uri(name: &str) -> Url {
+        Url::parse(&format!("file:///{}.sql", name)).unwrap()
+    }
+
+    #[tokio::test]
+    async fn test_burst_is_bounded_and_coalesced() {
+        let scheduler = DiagnosticScheduler::new(4);
+        let in_flight = &AtomicUsize::new(0);
+        let max_in_flight = &AtomicUsize::new(0);
+        let runs = &Mutex::new(HashMap::<Url, usize>::new());
+
+        // Three edits of each of 50 documents, arriving together
+        let documents: Vec<Url> = (0..50).map(|i| uri(&format!("doc{}", i))).collect();
+        let edits = (0..3).flat_map(|_| documents.iter()).map(|uri| {
+            scheduler.run_latest(uri, move || async move {
+                let now = in_flight.fetch_add(1, Ordering::SeqCst) + 1;
+                max_in_flight.fetch_max(now, Ordering::SeqCst);
+                tokio::time::sleep(Duration::from_millis(1)).await;
+                *runs.lock().unwrap().entry(uri.clone()).or_default() += 1;
+                in_flight.fetch_sub(1, Ordering::SeqCst);
+            })
+        });
+        join_all(edits).await;
+
+        assert!(max_in_flight.load(Ordering::SeqCst) <= 4);
+        let runs = runs.lock().unwrap();
+        for document in &documents {
+            let count = runs.get(document).copied().unwrap_or_default();
+            assert!((1..=2).contains(&count), "{} ran {} times", document, count);
+        }
+
+        let stats = scheduler.stats();
+        assert_eq!(stats.running, 0);
+        assert_eq!(stats.queued, 0);
+        assert!(stats.max_queued > 0);
+        assert!(stats.coalesced > 0);
+    }
+
+    #[tokio::test]
+    async fn test_focused_document_goes_first() {
+        let scheduler = DiagnosticScheduler::new(1);
+        let order = &Mutex::new(Vec::new());
+        let (unblock, blocked) = oneshot::channel::<()>();
+
+        let [first, a, b, c] = ["first", "a", "b", "c"].map(uri);
+        let record = |name: &'static str| move || async move { order.lock().unwrap().push(name) };
+        tokio::join!(
+            scheduler.run_latest(&first, move || async move {
+                blocked.await.unwrap();
+            }),
+            scheduler.run_latest(&a, record("a")),
+            scheduler.run_latest(&b, record("b")),
+            scheduler.run_latest(&c, record("c")),
+            async {
+                // Focus the last queued document while the first run blocks
+                tokio::task::yield_now().await;
+                scheduler.set_focus(&c);
+                unblock.send(()).unwrap();
+            },
+        );
+
+        assert_eq!(*order.lock().unwrap(), vec!["c", "a", "b"]);
+    }
+
+    #[tokio::test]
+    async fn test_cancelled_run_frees_its_slot() {
+        let scheduler = DiagnosticScheduler::new(1);
+        let (unblock, blocked) = oneshot::channel::<()>();
+        let [first, cancelled, next] = ["first", "cancelled", "next"].map(uri);
+
+        let mut first = Box::pin(scheduler.run(&first, move || async move {
+            blocked.await.unwrap();
+        }));
+        let mut cancelled = Box::pin(scheduler.run_latest(&cancelled, || async {}));
+        assert!(first.as_mut().now_or_never().is_none());
+        assert!(cancelled.as_mut().now_or_never().is_none());
+        assert_eq!(scheduler.stats().queued, 1);
+
+        // The queued run is started, then dropped before it wakes up
+        unblock.send(()).unwrap();
+        first.await;
+        assert_eq!(scheduler.stats().running, 1);
+        drop(cancelled);
+
+        assert_eq!(scheduler.stats().running, 0);
+        assert!(scheduler.run_latest(&next, || async {}).await);
+    }
+
+    #[tokio::test]
+    async fn test_save_runs_are_not_dropped() {
+        let scheduler = DiagnosticScheduler::new(1);
+        let runs = &AtomicUsize::new(0);
+        let (unblock, blocked) = oneshot::channel::<()>();
+        let document = uri("doc");
+
+        let count = move || async move {
+            runs.fetch_add(1, Ordering::SeqCst);
+        };
+        let (_, _, replaced, latest, _) = tokio::join!(
+            scheduler.run_latest(&document, move || async move {
+                blocked.await.unwrap();
+            }),
+            scheduler.run(&document, count),
+            scheduler.run_latest(&document, count),
+            scheduler.run_latest(&document, count),
+            async {
+                tokio::task::yield_now().await;
+                unblock.send(()).unwrap();
+            },
+        );
+
+        // The save and the last edit run; the edit queued before it is dropped
+        assert!(!replaced);
+        assert!(latest);
+        assert_eq!(runs.load(Ordering::SeqCst), 2);
+        assert_eq!(scheduler.stats().coalesced, 1);
+    }
+}
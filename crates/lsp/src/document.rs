@@ -14,6 +14,8 @@
 //! - Document synchronization (open, change, close)
 //! - Text content management using Ropey for efficient edits
 //! - Document metadata (language ID, version, URI)
+//! - Sub-documents of the SQL embedded in host-language files (see
+//!   [`crate::embedded`])
 //!
 //! ## Architecture
 //!
@@ -49,7 +51,11 @@ use ropey::Rope;
 use std::collections::HashMap;
 use std::sync::Arc;
 use tokio::sync::{Mutex, RwLock};
-use tower_lsp::lsp_types::{TextDocumentContentChangeEvent, Url, VersionedTextDocumentIdentifier};
+use tower_lsp::lsp_types::{
+    Position, TextDocumentContentChangeEvent, Url, VersionedTextDocumentIdentifier,
+};
+
+use crate::embedded::PositionMapper;
 
 /// Parse metadata
 ///
@@ -316,10 +322,22 @@ impl Document {
     }
 }
 
+/// Virtual SQL document embedded in a host-language document
+#[derive(Debug, Clone)]
+pub struct SubDocument {
+    /// The SQL of the region, with its own tree once parsed
+    pub document: Document,
+
+    /// Translates positions between the host and the sub-document
+    pub mapper: PositionMapper,
+}
+
 /// Thread-safe store for all open documents across all client connections.
 #[derive(Debug, Default)]
 pub struct DocumentStore {
     documents: Arc<RwLock<HashMap<Url, Document>>>,
+    /// Sub-documents of host-language documents
+    sub_documents: Arc<RwLock<HashMap<Url, Vec<SubDocument>>>>,
 }
 
 impl DocumentStore {
@@ -362,10 +380,57 @@ impl DocumentStore {
     ///
     /// true if the document was closed, false if it didn't exist
     pub async fn close_document(&self, uri: &Url) -> bool {
+        self.sub_documents.write().await.remove(uri);
         let mut docs = self.documents.write().await;
         docs.remove(uri).is_some()
     }
 
+    /// Replace the sub-documents of a host document
+    ///
+    /// # Arguments
+    ///
+    /// - `uri`: Host document URI
+    /// - `sub_documents`: Sub-documents extracted from the current content
+    pub async fn set_sub_documents(&self, uri: &Url, sub_documents: Vec<SubDocument>) {
+        self.sub_documents
+            .write()
+            .await
+            .insert(uri.clone(), sub_documents);
+    }
+
+    /// Get the sub-documents of a host document
+    pub async fn sub_documents(&self, uri: &Url) -> Vec<SubDocument> {
+        self.sub_documents
+            .read()
+            .await
+            .get(uri)
+            .cloned()
+            .unwrap_or_default()
+    }
+
+    /// Get the sub-document containing a host position
+    ///
+    /// # Arguments
+    ///
+    /// - `uri`: Host document URI
+    /// - `position`: Position in the host document
+    ///
+    /// # Returns
+    ///
+    /// The sub-document and the position translated into it, or None if the
+    /// position is outside every SQL region
+    pub async fn sub_document_at(
+        &self,
+        uri: &Url,
+        position: Position,
+    ) -> Option<(SubDocument, Position)> {
+        let sub_documents = self.sub_documents.read().await;
+        sub_documents.get(uri)?.iter().find_map(|sub_document| {
+            let position = sub_document.mapper.to_virtual(position)?;
+            Some((sub_document.clone(), position))
+        })
+    }
+
     /// Update a document
     ///
     /// # Arguments
@@ -715,4 +780,37 @@ mod tests {
         assert!(uris.contains(&uri1));
         assert!(uris.contains(&uri2));
     }
+
+    #[tokio::test]
+    async fn test_document_store_sub_documents() {
+        use crate::embedded::{EmbeddedSqlConfig, sub_documents};
+
+        let store = DocumentStore::new();
+        let uri = Url::parse("file:///db.go").unwrap();
+        let host = Document::new(
+            uri.clone(),
+            "var q = `SELECT 1`".to_string(),
+            1,
+            "go".to_string(),
+        );
+        store
+            .set_sub_documents(&uri, sub_documents(&host, &EmbeddedSqlConfig::default()))
+            .await;
+
+        let (sub_document, position) = store
+            .sub_document_at(&uri, Position::new(0, 16))
+            .await
+            .unwrap();
+        assert_eq!(sub_document.document.get_content(), "SELECT 1");
+        assert_eq!(position, Position::new(0, 7));
+        assert!(
+            store
+                .sub_document_at(&uri, Position::new(0, 2))
+                .await
+                .is_none()
+        );
+
+        store.close_document(&uri).await;
+        assert!(store.sub_documents(&uri).await.is_empty());
+    }
 }
@@ -0,0 +1,360 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Document links
+//!
+//! This module answers `textDocument/documentLink` with the files a script
+//! references, so they can be opened with ctrl-click:
+//!
+//! ```text
+//! \i ./seed.sql                                         psql (PostgreSQL family)
+//! \ir 'fixtures/large data.sql'                         psql (PostgreSQL family)
+//! COPY users FROM '/data/users.csv';                    PostgreSQL family
+//! LOAD DATA LOCAL INFILE 'users.csv' INTO TABLE users;  MySQL family
+//! ```
+//!
+//! Relative paths are resolved against the directory of the document, so
+//! documents that are not files only get links for absolute paths. Links to
+//! files that do not exist are still returned, with a tooltip saying so.
+
+use std::collections::HashSet;
+use std::path::{Component, Path, PathBuf};
+
+use tower_lsp::lsp_types::{DocumentLink, Position, Range, Url};
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_ir::dialect::DialectFamily;
+
+use crate::migrations::{Token, TokenKind, tokenize};
+
+/// psql meta-commands that run another script
+const PSQL_INCLUDES: [&str; 4] = ["i", "ir", "include", "include_relative"];
+
+/// A file path written in the document
+#[derive(Debug, Clone, PartialEq, Eq)]
+struct FileReference {
+    path: String,
+    /// Range of the path, without its quotes
+    range: Range,
+}
+
+/// Find the links to the files a document references
+///
+/// # Arguments
+///
+/// * `source` - Document content
+/// * `uri` - URI of the document, whose directory relative paths resolve against
+/// * `dialect` - Dialect of the document, which decides the recognized constructs
+///
+/// # Returns
+///
+/// The links in document order
+pub fn document_links(source: &str, uri: &Url, dialect: Dialect) -> Vec<DocumentLink> {
+    let directory = uri
+        .to_file_path()
+        .ok()
+        .and_then(|path| path.parent().map(Path::to_path_buf));
+
+    let tokens = tokenize(source);
+    let mut references = match dialect.family() {
+        DialectFamily::PostgreSQL => {
+            let (mut references, meta_lines) = psql_includes(source);
+            // Meta-commands end at the end of their line, not at a semicolon
+            let tokens: Vec<Token> = tokens
+                .into_iter()
+                .filter(|token| !meta_lines.contains(&token.range.start.line))
+                .collect();
+            references.extend(copy_files(&tokens));
+            references
+        }
+        DialectFamily::MySQL => load_data_files(&tokens),
+    };
+    references.sort_by_key(|reference| reference.range.start);
+
+    references
+        .into_iter()
+        .filter_map(|reference| link(directory.as_deref(), reference))
+        .collect()
+}
+
+/// Paths of the psql include meta-commands, and the lines of all
+/// meta-commands
+fn psql_includes(source: &str) -> (Vec<FileReference>, HashSet<u32>) {
+    let mut references = Vec::new();
+    let mut meta_lines = HashSet::new();
+
+    for (line, text) in source.lines().enumerate() {
+        let line = line as u32;
+        let Some(command) = text.trim_start().strip_prefix('\\') else {
+            continue;
+        };
+        meta_lines.insert(line);
+
+        let name_len = command
+            .find(|c: char| !(c.is_alphanumeric() || c == '_'))
+            .unwrap_or(command.len());
+        if !PSQL_INCLUDES.contains(&&command[..name_len]) {
+            continue;
+        }
+        let argument = command[name_len..].trim_start();
+        if argument.len() == command[name_len..].len() {
+            // `\include_relativefoo` is not an include
+            continue;
+        }
+
+        let start = text.len() - argument.len();
+        let (path, offset) = match argument.strip_prefix('\'') {
+            Some(quoted) => (quoted.split('\'').next().unwrap_or_default(), start + 1),
+            None => (
+                argument.split_whitespace().next().unwrap_or_default(),
+                start,
+            ),
+        };
+        if path.is_empty() {
+            continue;
+        }
+
+        let character = |offset: usize| text[..offset].encode_utf16().count() as u32;
+        references.push(FileReference {
+            path: path.to_string(),
+            range: Range::new(
+                Position::new(line, character(offset)),
+                Position::new(line, character(offset + path.len())),
+            ),
+        });
+    }
+
+    (references, meta_lines)
+}
+
+/// Files of `COPY ... FROM 'file'` and `COPY ... TO 'file'` statements
+fn copy_files(tokens: &[Token]) -> Vec<FileReference> {
+    statements(tokens)
+        .filter(|statement| {
+            statement
+                .first()
+                .is_some_and(|token| token.is_keyword("COPY"))
+        })
+        .filter_map(|statement| {
+            // The FROM of a `COPY (SELECT ... FROM t) TO` query is nested
+            let mut depth = 0usize;
+            let direction = statement.iter().position(|token| {
+                match token.kind {
+                    TokenKind::Symbol('(') => depth += 1,
+                    TokenKind::Symbol(')') => depth = depth.saturating_sub(1),
+                    _ => {}
+                }
+                depth == 0 && (token.is_keyword("FROM") || token.is_keyword("TO"))
+            })?;
+            file_reference(statement.get(direction + 1)?)
+        })
+        .collect()
+}
+
+/// Files of `LOAD DATA [LOW_PRIORITY | CONCURRENT] [LOCAL] INFILE 'file'`
+/// statements
+fn load_data_files(tokens: &[Token]) -> Vec<FileReference> {
+    statements(tokens)
+        .filter(|statement| {
+            statement.len() > 2
+                && statement[0].is_keyword("LOAD")
+                && statement[1].is_keyword("DATA")
+        })
+        .filter_map(|statement| {
+            let infile = statement[2..]
+                .iter()
+                .take(3)
+                .position(|token| token.is_keyword("INFILE"))?;
+            file_reference(statement.get(2 + infile + 1)?)
+        })
+        .collect()
+}
+
+/// Non-empty statements of a token stream
+fn statements(tokens: &[Token]) -> impl Iterator<Item = &[Token]> {
+    tokens
+        .split(|token| token.kind == TokenKind::Symbol(';'))
+        .filter(|statement| !statement.is_empty())
+}
+
+/// Path of a string literal token, without its quotes
+fn file_reference(token: &Token) -> Option<FileReference> {
+    if token.kind != TokenKind::Literal || token.text.is_empty() {
+        return None;
+    }
+
+    let Range { start, end } = token.range;
+    Some(FileReference {
+        path: token.text.clone(),
+        range: Range::new(
+            Position::new(start.line, start.character + 1),
+            Position::new(end.line, end.character.saturating_sub(1)),
+        ),
+    })
+}
+
+/// Resolve a referenced path to a link
+fn link(directory: Option<&Path>, reference: FileReference) -> Option<DocumentLink> {
+    let path = Path::new(&reference.path);
+    let path = if path.is_absolute() {
+        path.to_path_buf()
+    } else {
+        directory?.join(path)
+    };
+    let path: PathBuf = path
+        .components()
+        .filter(|component| *component != Component::CurDir)
+        .collect();
+
+    let target = Url::from_file_path(&path).ok()?;
+    let tooltip = (!path.exists()).then(|| format!("File not found: {}", path.display()));
+
+    Some(DocumentLink {
+        range: reference.range,
+        target: Some(target),
+        tooltip,
+        data: None,
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Scripts directory, removed when dropped
+    struct Fixture(PathBuf);
+
+    impl Fixture {
+        fn new(name: &str) -> Self {
+            let root = std::env::temp_dir().join(format!(
+                "unified-sql-lsp-links-{}-{}",
+                name,
+                std::process::id()
+            ));
+            let _ = std::fs::remove_dir_all(&root);
+
+            let files = [
+                (
+                    "main.sql",
+                    "\\i ./setup/seed.sql\n\\ir 'fixtures/large data.sql'\nSELECT 1;\n",
+                ),
+                ("setup/seed.sql", "\\include_relative schema/tables.sql\n"),
+                ("setup/schema/tables.sql", "CREATE TABLE users (id INT);\n"),
+                ("data/users.csv", "1\n"),
+            ];
+            for (path, content) in files {
+                let path = root.join(path);
+                std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+                std::fs::write(path, content).unwrap();
+            }
+            Self(root)
+        }
+
+        fn links(&self, path: &str, dialect: Dialect) -> Vec<DocumentLink> {
+            let source = std::fs::read_to_string(self.0.join(path)).unwrap();
+            self.links_of(path, &source, dialect)
+        }
+
+        fn links_of(&self, path: &str, source: &str, dialect: Dialect) -> Vec<DocumentLink> {
+            let uri = Url::from_file_path(self.0.join(path)).unwrap();
+            document_links(source, &uri, dialect)
+        }
+
+        /// Target of a link, relative to the fixture root
+        fn target(&self, link: &DocumentLink) -> PathBuf {
+            let path = link.target.as_ref().unwrap().to_file_path().unwrap();
+            path.strip_prefix(&self.0).unwrap().to_path_buf()
+        }
+    }
+
+    impl Drop for Fixture {
+        fn drop(&mut self) {
+            let _ = std::fs::remove_dir_all(&self.0);
+        }
+    }
+
+    #[test]
+    fn test_psql_include_chain() {
+        let fixture = Fixture::new("psql");
+
+        let links = fixture.links("main.sql", Dialect::PostgreSQL);
+        assert_eq!(links.len(), 2);
+        assert_eq!(fixture.target(&links[0]), Path::new("setup/seed.sql"));
+        assert_eq!(
+            links[0].range,
+            Range::new(Position::new(0, 3), Position::new(0, 19))
+        );
+        assert_eq!(links[0].tooltip, None);
+
+        // Quoted paths keep their spaces and exclude their quotes
+        assert_eq!(
+            fixture.target(&links[1]),
+            Path::new("fixtures/large data.sql")
+        );
+        assert_eq!(
+            links[1].range,
+            Range::new(Position::new(1, 5), Position::new(1, 28))
+        );
+        assert!(
+            links[1]
+                .tooltip
+                .as_ref()
+                .unwrap()
+                .starts_with("File not found")
+        );
+
+        // The included script resolves against its own directory
+        let links = fixture.links("setup/seed.sql", Dialect::PostgreSQL);
+        assert_eq!(links.len(), 1);
+        assert_eq!(
+            fixture.target(&links[0]),
+            Path::new("setup/schema/tables.sql")
+        );
+        assert_eq!(links[0].tooltip, None);
+    }
+
+    #[test]
+    fn test_copy_and_load_data() {
+        let fixture = Fixture::new("copy");
+
+        let sql = "COPY users FROM 'data/users.csv';\n\
+                   COPY (SELECT id FROM users) TO 'out dir/ids.csv' WITH (FORMAT csv);\n\
+                   COPY users FROM STDIN;";
+        let links = fixture.links_of("load.sql", sql, Dialect::PostgreSQL);
+        let targets: Vec<PathBuf> = links.iter().map(|link| fixture.target(link)).collect();
+        assert_eq!(
+            targets,
+            vec![
+                PathBuf::from("data/users.csv"),
+                PathBuf::from("out dir/ids.csv")
+            ]
+        );
+        assert_eq!(links[0].tooltip, None);
+        assert!(links[1].tooltip.is_some());
+
+        let sql = "LOAD DATA LOCAL INFILE 'data/users.csv' INTO TABLE users;";
+        let links = fixture.links_of("load.sql", sql, Dialect::MySQL);
+        assert_eq!(links.len(), 1);
+        assert_eq!(fixture.target(&links[0]), Path::new("data/users.csv"));
+        assert_eq!(
+            links[0].range,
+            Range::new(Position::new(0, 24), Position::new(0, 38))
+        );
+    }
+
+    #[test]
+    fn test_constructs_of_other_dialects_are_ignored() {
+        let fixture = Fixture::new("dialects");
+
+        let sql = "LOAD DATA INFILE 'users.csv' INTO TABLE users;";
+        assert!(
+            fixture
+                .links_of("a.sql", sql, Dialect::PostgreSQL)
+                .is_empty()
+        );
+
+        let sql = "\\i seed.sql\nCOPY users FROM 'users.csv';";
+        assert!(fixture.links_of("a.sql", sql, Dialect::MySQL).is_empty());
+    }
+}
@@ -0,0 +1,440 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Embedded SQL
+//!
+//! This module serves SQL embedded in string literals of host-language files
+//! (Go, Python), for editors that forward requests on those files.
+//!
+//! ## Overview
+//!
+//! - Documents whose `languageId` is one of the configured host languages
+//!   are not parsed as SQL; their SQL regions are extracted instead (see
+//!   [`extract_regions`])
+//! - Each region becomes a virtual [`SubDocument`] holding only the SQL,
+//!   parsed and analyzed like a SQL document
+//! - A [`PositionMapper`] translates positions between the host file and the
+//!   sub-document, so diagnostics and edits land in the host file
+//!
+//! Regions are delimited by configured start/end markers (e.g. `` sql := ` ``
+//! and `` ` ``). Without markers, raw strings in backticks are extracted when
+//! their content starts with a SQL statement keyword, which skips Go struct
+//! tags and other non-SQL raw strings.
+
+use serde_json::Value;
+use tower_lsp::lsp_types::{
+    CompletionItem, CompletionTextEdit, DiagnosticRelatedInformation, Position, Range, TextEdit,
+    Url,
+};
+
+use crate::diagnostic::SqlDiagnostic;
+use crate::document::{Document, SubDocument};
+
+/// Host languages served when `embeddedSql` is configured without languages
+pub const DEFAULT_HOST_LANGUAGES: [&str; 2] = ["go", "python"];
+
+/// Keywords starting the SQL of an unmarked raw string
+const STATEMENT_KEYWORDS: [&str; 9] = [
+    "SELECT", "INSERT", "UPDATE", "DELETE", "WITH", "CREATE", "ALTER", "DROP", "REPLACE",
+];
+
+/// Delimiters of embedded SQL regions
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct RegionMarker {
+    /// Text preceding the SQL
+    pub start: String,
+
+    /// Text following the SQL
+    pub end: String,
+}
+
+/// Embedded SQL configuration
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct EmbeddedSqlConfig {
+    /// `languageId`s of host documents; empty disables embedded SQL
+    pub languages: Vec<String>,
+
+    /// Region delimiters; empty extracts raw strings holding SQL
+    pub markers: Vec<RegionMarker>,
+}
+
+impl EmbeddedSqlConfig {
+    /// Parse embedded SQL configuration from the `embeddedSql` settings
+    /// object
+    pub fn from_settings(settings: &Value) -> Self {
+        let languages = match settings.get("languages").and_then(Value::as_array) {
+            Some(languages) => languages
+                .iter()
+                .filter_map(Value::as_str)
+                .map(str::to_string)
+                .collect(),
+            None => DEFAULT_HOST_LANGUAGES.map(str::to_string).to_vec(),
+        };
+        let markers = settings
+            .get("markers")
+            .and_then(Value::as_array)
+            .map(|markers| {
+                markers
+                    .iter()
+                    .filter_map(|marker| {
+                        let start = marker.get("start")?.as_str()?;
+                        let end = marker.get("end")?.as_str()?;
+                        (!start.is_empty() && !end.is_empty()).then(|| RegionMarker {
+                            start: start.to_string(),
+                            end: end.to_string(),
+                        })
+                    })
+                    .collect()
+            })
+            .unwrap_or_default();
+
+        Self { languages, markers }
+    }
+
+    /// Check whether documents of a language hold embedded SQL
+    pub fn is_host_language(&self, language_id: &str) -> bool {
+        self.languages
+            .iter()
+            .any(|language| language.eq_ignore_ascii_case(language_id))
+    }
+}
+
+/// Byte range of an embedded SQL region in its host file
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Region {
+    pub start: usize,
+    pub end: usize,
+}
+
+/// Find the SQL regions of a host file
+///
+/// A region without its end marker extends to the end of the file, so SQL
+/// being typed in an unterminated string is still served.
+///
+/// # Arguments
+///
+/// * `source` - Host file content
+/// * `markers` - Region delimiters, or empty for raw strings holding SQL
+///
+/// # Returns
+///
+/// Non-overlapping regions in file order
+pub fn extract_regions(source: &str, markers: &[RegionMarker]) -> Vec<Region> {
+    if markers.is_empty() {
+        return extract_raw_strings(source);
+    }
+
+    let mut regions = Vec::new();
+    let mut cursor = 0;
+    loop {
+        // Earliest start marker, the longest one on ties
+        let next = markers
+            .iter()
+            .filter_map(|marker| {
+                source[cursor..]
+                    .find(&marker.start)
+                    .map(|offset| (cursor + offset, marker))
+            })
+            .min_by_key(|(offset, marker)| (*offset, usize::MAX - marker.start.len()));
+        let Some((offset, marker)) = next else {
+            break;
+        };
+
+        let start = offset + marker.start.len();
+        let end = source[start..]
+            .find(&marker.end)
+            .map_or(source.len(), |length| start + length);
+        regions.push(Region { start, end });
+        cursor = (end + marker.end.len()).min(source.len());
+    }
+    regions
+}
+
+/// Find the backtick raw strings whose content starts with a statement
+/// keyword
+fn extract_raw_strings(source: &str) -> Vec<Region> {
+    let mut regions = Vec::new();
+    let mut cursor = 0;
+    while let Some(open) = source[cursor..].find('`') {
+        let start = cursor + open + 1;
+        let end = source[start..]
+            .find('`')
+            .map_or(source.len(), |length| start + length);
+        if looks_like_sql(&source[start..end]) {
+            regions.push(Region { start, end });
+        }
+        cursor = (end + 1).min(source.len());
+    }
+    regions
+}
+
+fn looks_like_sql(text: &str) -> bool {
+    let first_word: String = text
+        .trim_start()
+        .chars()
+        .take_while(|c| c.is_ascii_alphabetic())
+        .collect();
+    STATEMENT_KEYWORDS
+        .iter()
+        .any(|keyword| keyword.eq_ignore_ascii_case(&first_word))
+}
+
+/// LSP position (UTF-16 columns) of a byte offset
+fn position_at(source: &str, offset: usize) -> Position {
+    let before = &source[..offset];
+    let line = before.matches('\n').count() as u32;
+    let line_start = before.rfind('\n').map_or(0, |newline| newline + 1);
+    Position::new(line, before[line_start..].encode_utf16().count() as u32)
+}
+
+/// Translates positions between a host file and one of its sub-documents
+///
+/// The sub-document holds the region text verbatim, so its lines are the
+/// host lines of the region: only the first line is shifted by the column
+/// where the region starts.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct PositionMapper {
+    /// Host position of the start of the region
+    origin: Position,
+
+    /// Host position of the end of the region
+    end: Position,
+}
+
+impl PositionMapper {
+    /// Create the mapper of a region of a host file
+    pub fn new(source: &str, region: Region) -> Self {
+        Self {
+            origin: position_at(source, region.start),
+            end: position_at(source, region.end),
+        }
+    }
+
+    /// Range of the region in the host file
+    pub fn host_range(&self) -> Range {
+        Range::new(self.origin, self.end)
+    }
+
+    /// Translate a host position into the sub-document
+    ///
+    /// # Returns
+    ///
+    /// `None` if the position is outside the region; the end of the region
+    /// is inside, so completion works at the end of the SQL
+    pub fn to_virtual(&self, host: Position) -> Option<Position> {
+        if host < self.origin || host > self.end {
+            return None;
+        }
+        let line = host.line - self.origin.line;
+        let character = match line {
+            0 => host.character - self.origin.character,
+            _ => host.character,
+        };
+        Some(Position::new(line, character))
+    }
+
+    /// Translate a sub-document position into the host file
+    pub fn to_host(&self, position: Position) -> Position {
+        match position.line {
+            0 => Position::new(self.origin.line, self.origin.character + position.character),
+            line => Position::new(self.origin.line + line, position.character),
+        }
+    }
+
+    /// Translate a sub-document range into the host file
+    pub fn range_to_host(&self, range: Range) -> Range {
+        Range::new(self.to_host(range.start), self.to_host(range.end))
+    }
+}
+
+/// Split a host document into the sub-documents of its SQL regions
+///
+/// Sub-documents share the host's language and version; their URI is the
+/// host URI with a `sql-<index>` fragment.
+pub fn sub_documents(host: &Document, config: &EmbeddedSqlConfig) -> Vec<SubDocument> {
+    let source = host.get_content();
+    extract_regions(&source, &config.markers)
+        .into_iter()
+        .enumerate()
+        .map(|(index, region)| {
+            let mut uri = host.uri().clone();
+            uri.set_fragment(Some(&format!("sql-{}", index)));
+            SubDocument {
+                document: Document::new(
+                    uri,
+                    source[region.start..region.end].to_string(),
+                    host.version(),
+                    host.language_id().to_string(),
+                ),
+                mapper: PositionMapper::new(&source, region),
+            }
+        })
+        .collect()
+}
+
+/// Move a diagnostic of a sub-document to its host file
+pub fn diagnostic_to_host(
+    mut diagnostic: SqlDiagnostic,
+    sub_document: &SubDocument,
+    host: &Url,
+) -> SqlDiagnostic {
+    let mapper = &sub_document.mapper;
+    diagnostic.range = mapper.range_to_host(diagnostic.range);
+    if let Some(related) = diagnostic.related_information.as_mut() {
+        for DiagnosticRelatedInformation { location, .. } in related {
+            if &location.uri == sub_document.document.uri() {
+                location.uri = host.clone();
+                location.range = mapper.range_to_host(location.range);
+            }
+        }
+    }
+    diagnostic
+}
+
+/// Move the edits of a completion item of a sub-document to its host file
+pub fn completion_to_host(mut item: CompletionItem, mapper: &PositionMapper) -> CompletionItem {
+    let edit_to_host = |edit: &mut TextEdit| edit.range = mapper.range_to_host(edit.range);
+
+    match item.text_edit.as_mut() {
+        Some(CompletionTextEdit::Edit(edit)) => edit_to_host(edit),
+        Some(CompletionTextEdit::InsertAndReplace(edit)) => {
+            edit.insert = mapper.range_to_host(edit.insert);
+            edit.replace = mapper.range_to_host(edit.replace);
+        }
+        None => {}
+    }
+    if let Some(edits) = item.additional_text_edits.as_mut() {
+        edits.iter_mut().for_each(edit_to_host);
+    }
+    item
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn marker(start: &str, end: &str) -> RegionMarker {
+        RegionMarker {
+            start: start.to_string(),
+            end: end.to_string(),
+        }
+    }
+
+    fn texts<'a>(source: &'a str, regions: &[Region]) -> Vec<&'a str> {
+        regions
+            .iter()
+            .map(|region| &source[region.start..region.end])
+            .collect()
+    }
+
+    #[test]
+    fn test_raw_strings_holding_sql_are_extracted() {
+        let source = "type User struct {\n\tID int `json:\"id\"`\n}\n\n\
+                      var q = `SELECT id\n\tFROM users`\n\
+                      var w = `  with t AS (SELECT 1) SELECT * FROM t`\n";
+
+        let regions = extract_regions(source, &[]);
+
+        assert_eq!(
+            texts(source, &regions),
+            [
+                "SELECT id\n\tFROM users",
+                "  with t AS (SELECT 1) SELECT * FROM t"
+            ]
+        );
+    }
+
+    #[test]
+    fn test_marked_regions_are_extracted() {
+        let source = "sql := `SELECT 1`\nother := `SELECT 2`\nquery = \"\"\"SELECT 3\"\"\"";
+        let markers = [marker("sql := `", "`"), marker("query = \"\"\"", "\"\"\"")];
+
+        let regions = extract_regions(source, &markers);
+
+        assert_eq!(texts(source, &regions), ["SELECT 1", "SELECT 3"]);
+    }
+
+    #[test]
+    fn test_unterminated_region_extends_to_the_end() {
+        let source = "sql := `SELECT * FROM ";
+        let regions = extract_regions(source, &[marker("sql := `", "`")]);
+        assert_eq!(texts(source, &regions), ["SELECT * FROM "]);
+    }
+
+    #[test]
+    fn test_positions_map_both_ways() {
+        let source = "package db\n\nvar q = `SELECT id\n  FROM users`\n";
+        let region = extract_regions(source, &[])[0];
+        let mapper = PositionMapper::new(source, region);
+
+        // First line is shifted by the column of the region
+        assert_eq!(
+            mapper.to_virtual(Position::new(2, 16)),
+            Some(Position::new(0, 7))
+        );
+        assert_eq!(mapper.to_host(Position::new(0, 7)), Position::new(2, 16));
+        // Later lines keep their columns
+        assert_eq!(
+            mapper.to_virtual(Position::new(3, 7)),
+            Some(Position::new(1, 7))
+        );
+        assert_eq!(mapper.to_host(Position::new(1, 7)), Position::new(3, 7));
+        // The end of the region is inside, the closing backtick is not
+        assert_eq!(
+            mapper.to_virtual(Position::new(3, 12)),
+            Some(Position::new(1, 12))
+        );
+        assert_eq!(mapper.to_virtual(Position::new(3, 13)), None);
+        // The opening marker is outside
+        assert_eq!(mapper.to_virtual(Position::new(2, 8)), None);
+    }
+
+    #[test]
+    fn test_cursor_outside_a_shrunk_region() {
+        let before = "var q = `SELECT * FROM users WHERE id = 1`\n";
+        let cursor = Position::new(0, 40);
+        let mapper = PositionMapper::new(before, extract_regions(before, &[])[0]);
+        assert!(mapper.to_virtual(cursor).is_some());
+
+        // The region now ends before the cursor
+        let after = "var q = `SELECT * FROM users`          \n";
+        let mapper = PositionMapper::new(after, extract_regions(after, &[])[0]);
+        assert_eq!(mapper.to_virtual(cursor), None);
+    }
+
+    #[test]
+    fn test_utf16_columns() {
+        let source = "s := \"😀\"; q := `SELECT 1`";
+        let mapper = PositionMapper::new(source, extract_regions(source, &[])[0]);
+        assert_eq!(mapper.to_host(Position::new(0, 0)), Position::new(0, 17));
+    }
+
+    #[test]
+    fn test_config_from_settings() {
+        let config = EmbeddedSqlConfig::from_settings(&json!({
+            "markers": [{ "start": "sql := `", "end": "`" }, { "start": "" , "end": "`" }]
+        }));
+
+        assert!(config.is_host_language("go"));
+        assert!(config.is_host_language("python"));
+        assert!(!config.is_host_language("sql"));
+        assert_eq!(config.markers, [marker("sql := `", "`")]);
+        assert!(!EmbeddedSqlConfig::default().is_host_language("go"));
+    }
+
+    #[test]
+    fn test_sub_documents_hold_the_region_text() {
+        let uri = Url::parse("file:///project/db.go").unwrap();
+        let host = Document::new(uri, "var q = `SELECT 1`".to_string(), 3, "go".to_string());
+
+        let subs = sub_documents(&host, &EmbeddedSqlConfig::default());
+
+        assert_eq!(subs.len(), 1);
+        assert_eq!(subs[0].document.get_content(), "SELECT 1");
+        assert_eq!(subs[0].document.uri().fragment(), Some("sql-0"));
+        assert_eq!(subs[0].document.version(), 3);
+    }
+}
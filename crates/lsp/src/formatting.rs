@@ -0,0 +1,561 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Formatting
+//!
+//! This module computes the edits applied by document formatting,
+//! format-on-save and on-type formatting.
+//!
+//! Formatting is limited to whitespace for now:
+//! - trailing whitespace is removed from every line
+//! - the document ends with exactly one newline
+//!
+//! Statement layout (indentation, keyword case) is left untouched until the
+//! SQL formatter lands (FORMAT-001).
+//!
+//! ## On-type formatting
+//!
+//! When enabled (see [`OnTypeFormattingConfig`]), typing a space or a newline
+//! edits the text just before the cursor only:
+//! - the keyword the space or newline completes is upper-cased
+//! - a new line continuing a SELECT list or an ON condition is indented to
+//!   align with the first item of the clause
+//!
+//! ```text
+//! select id,⏎        →  SELECT id,
+//!                              ▏ (aligned with `id`)
+//! ```
+//!
+//! Words in string literals, quoted identifiers and comments are left alone.
+
+use serde_json::Value;
+use tower_lsp::lsp_types::{Position, Range, TextEdit};
+use unified_sql_lsp_ir::Dialect;
+
+use crate::completion::keyword_grammar::KeywordGrammar;
+use crate::migrations::{Token, TokenKind, tokenize};
+
+/// Character that triggers on-type formatting first
+pub const ON_TYPE_FIRST_TRIGGER: &str = " ";
+
+/// Other characters that trigger on-type formatting
+pub const ON_TYPE_MORE_TRIGGERS: [&str; 1] = ["\n"];
+
+/// Indentation of a clause continued on a new line when its first item is
+/// not on the clause's line
+const CONTINUATION_INDENT: u32 = 4;
+
+/// Keywords starting a clause, for finding the clause at the cursor
+const CLAUSE_KEYWORDS: [&str; 17] = [
+    "SELECT",
+    "FROM",
+    "JOIN",
+    "ON",
+    "USING",
+    "WHERE",
+    "GROUP",
+    "HAVING",
+    "ORDER",
+    "LIMIT",
+    "OFFSET",
+    "UNION",
+    "INTERSECT",
+    "EXCEPT",
+    "SET",
+    "VALUES",
+    "RETURNING",
+];
+
+/// On-type formatting configuration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct OnTypeFormattingConfig {
+    /// Format while typing
+    pub enabled: bool,
+
+    /// Upper-case the keyword completed by a space or newline
+    pub uppercase_keywords: bool,
+
+    /// Align new lines of SELECT lists and ON conditions with the clause
+    pub align_clauses: bool,
+}
+
+impl Default for OnTypeFormattingConfig {
+    fn default() -> Self {
+        Self {
+            enabled: false,
+            uppercase_keywords: true,
+            align_clauses: true,
+        }
+    }
+}
+
+impl OnTypeFormattingConfig {
+    /// Parse on-type formatting configuration from the `onTypeFormatting`
+    /// settings object
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut config = Self::default();
+
+        if let Some(enabled) = settings.get("enabled").and_then(Value::as_bool) {
+            config.enabled = enabled;
+        }
+        if let Some(uppercase) = settings.get("uppercaseKeywords").and_then(Value::as_bool) {
+            config.uppercase_keywords = uppercase;
+        }
+        if let Some(align) = settings.get("alignClauses").and_then(Value::as_bool) {
+            config.align_clauses = align;
+        }
+
+        config
+    }
+}
+
+/// Compute the whitespace edits of a document
+///
+/// # Arguments
+///
+/// * `source` - Document content
+///
+/// # Returns
+///
+/// Non-overlapping edits in document order, empty if the document is
+/// already formatted
+pub fn whitespace_edits(source: &str) -> Vec<TextEdit> {
+    let mut edits = Vec::new();
+    let lines: Vec<&str> = source.split('\n').collect();
+
+    for (line, text) in lines.iter().enumerate() {
+        let text = text.strip_suffix('\r').unwrap_or(text);
+        let trimmed = text.trim_end();
+        if trimmed.len() < text.len() {
+            edits.push(TextEdit::new(
+                Range::new(
+                    Position::new(line as u32, utf16_len(trimmed)),
+                    Position::new(line as u32, utf16_len(text)),
+                ),
+                String::new(),
+            ));
+        }
+    }
+
+    // Blank lines at the end of the document collapse into the final newline
+    let content_lines = lines
+        .iter()
+        .rposition(|text| !text.trim().is_empty())
+        .map_or(0, |last| last + 1);
+    if content_lines == 0 {
+        return edits;
+    }
+    let last = content_lines - 1;
+    let end_of_content = Position::new(
+        last as u32,
+        utf16_len(lines[last].trim_end_matches('\r').trim_end()),
+    );
+    let end_of_document =
+        Position::new((lines.len() - 1) as u32, utf16_len(lines[lines.len() - 1]));
+
+    if lines.len() != content_lines + 1 || !lines[lines.len() - 1].is_empty() {
+        // The final newline edit covers the trailing whitespace edits after
+        // the last content line
+        edits.retain(|edit| edit.range.start < end_of_content);
+        let newline = if source.contains("\r\n") {
+            "\r\n"
+        } else {
+            "\n"
+        };
+        edits.push(TextEdit::new(
+            Range::new(end_of_content, end_of_document),
+            newline.to_string(),
+        ));
+    }
+
+    edits
+}
+
+/// Length of a line in UTF-16 code units, the unit of LSP positions
+fn utf16_len(text: &str) -> u32 {
+    text.encode_utf16().count() as u32
+}
+
+/// Compute the edits of a character typed in a document
+///
+/// # Arguments
+///
+/// * `source` - Document content, including the typed character
+/// * `position` - Cursor position after the typed character
+/// * `ch` - The typed character
+/// * `dialect` - Dialect whose reserved words are keywords
+/// * `config` - Edits to make
+///
+/// # Returns
+///
+/// Edits on the line of the completed word and the cursor's line, empty
+/// when there is nothing to change
+pub fn on_type_edits(
+    source: &str,
+    position: Position,
+    ch: &str,
+    dialect: Dialect,
+    config: &OnTypeFormattingConfig,
+) -> Vec<TextEdit> {
+    let lines: Vec<&str> = source.split('\n').collect();
+    let line = position.line as usize;
+    if line >= lines.len() {
+        return Vec::new();
+    }
+
+    // Line and byte offset of the end of the completed word
+    let word_end = match ch {
+        " " => {
+            let end = byte_index(lines[line], position.character.saturating_sub(1));
+            lines[line][end..].starts_with(' ').then_some((line, end))
+        }
+        "\n" if line > 0 => {
+            let previous = lines[line - 1].trim_end_matches('\r');
+            Some((line - 1, previous.len()))
+        }
+        _ => None,
+    };
+
+    let mut edits = Vec::new();
+    if let Some((word_line, end)) = word_end.filter(|_| config.uppercase_keywords) {
+        let line_start = line_offset(&lines, word_line);
+        edits.extend(uppercase_keyword(
+            source,
+            lines[word_line],
+            line_start,
+            word_line,
+            end,
+            dialect,
+        ));
+    }
+    if ch == "\n" && config.align_clauses {
+        let line_start = line_offset(&lines, line);
+        edits.extend(align_continuation(source, lines[line], line_start, line));
+    }
+
+    edits
+}
+
+/// Edit upper-casing the keyword ending at a byte offset of a line
+fn uppercase_keyword(
+    source: &str,
+    text: &str,
+    line_start: usize,
+    line: usize,
+    end: usize,
+    dialect: Dialect,
+) -> Option<TextEdit> {
+    let start = text[..end]
+        .rfind(|c: char| !is_word_char(c))
+        .map_or(0, |index| index + 1);
+    let word = &text[start..end];
+
+    // Qualified names (`t.order`) and variables (`@limit`) are not keywords
+    let qualified = text[..start].ends_with(['.', '@', '$', ':']);
+    if word.is_empty()
+        || qualified
+        || !in_code(source, line_start + start)
+        || word == word.to_ascii_uppercase()
+        || !KeywordGrammar::builtin().is_reserved(dialect, word)
+    {
+        return None;
+    }
+
+    let line = line as u32;
+    Some(TextEdit::new(
+        Range::new(
+            Position::new(line, utf16_len(&text[..start])),
+            Position::new(line, utf16_len(&text[..end])),
+        ),
+        word.to_ascii_uppercase(),
+    ))
+}
+
+/// Edit indenting a new line of a SELECT list or an ON condition
+fn align_continuation(
+    source: &str,
+    text: &str,
+    line_start: usize,
+    line: usize,
+) -> Option<TextEdit> {
+    if !in_code(source, line_start) {
+        return None;
+    }
+
+    let tokens = tokenize(&source[..line_start]);
+    let (clause, anchor) = clause_at_end(&tokens)?;
+    if !(clause.is_keyword("SELECT") || clause.is_keyword("ON")) {
+        return None;
+    }
+
+    let indent = match anchor {
+        Some(anchor) if anchor.range.start.line == clause.range.start.line => {
+            anchor.range.start.character
+        }
+        _ => clause.range.start.character + CONTINUATION_INDENT,
+    };
+
+    let current = &text[..text.len() - text.trim_start_matches([' ', '\t']).len()];
+    let aligned = " ".repeat(indent as usize);
+    if current == aligned {
+        return None;
+    }
+
+    let line = line as u32;
+    Some(TextEdit::new(
+        Range::new(
+            Position::new(line, 0),
+            Position::new(line, utf16_len(current)),
+        ),
+        aligned,
+    ))
+}
+
+/// Clause keyword in effect at the end of the tokens, with the first token
+/// of the clause
+fn clause_at_end(tokens: &[Token]) -> Option<(&Token, Option<&Token>)> {
+    // Index of the last clause keyword of each open parenthesis depth
+    let mut frames: Vec<Option<usize>> = vec![None];
+    for (index, token) in tokens.iter().enumerate() {
+        match token.kind {
+            TokenKind::Symbol('(') => frames.push(None),
+            TokenKind::Symbol(')') if frames.len() > 1 => {
+                frames.pop();
+            }
+            TokenKind::Symbol(';') => frames = vec![None],
+            TokenKind::Word
+                if CLAUSE_KEYWORDS
+                    .iter()
+                    .any(|keyword| token.is_keyword(keyword)) =>
+            {
+                *frames.last_mut()? = Some(index);
+            }
+            _ => {}
+        }
+    }
+
+    let index = (*frames.last()?)?;
+    let anchor = tokens[index + 1..]
+        .iter()
+        .find(|token| !(token.is_keyword("DISTINCT") || token.is_keyword("ALL")));
+    Some((&tokens[index], anchor))
+}
+
+/// Check whether a byte offset is in code rather than in a string literal,
+/// a quoted identifier or a comment
+fn in_code(source: &str, offset: usize) -> bool {
+    enum State {
+        Code,
+        Quoted(char),
+        LineComment,
+        BlockComment,
+    }
+
+    let mut state = State::Code;
+    let mut chars = source[..offset].chars().peekable();
+    while let Some(c) = chars.next() {
+        state = match state {
+            State::Code => match c {
+                '\'' | '"' | '`' => State::Quoted(c),
+                '#' => State::LineComment,
+                '-' if chars.peek() == Some(&'-') => State::LineComment,
+                '/' if chars.peek() == Some(&'*') => {
+                    chars.next();
+                    State::BlockComment
+                }
+                _ => State::Code,
+            },
+            State::Quoted(quote) => match c {
+                '\\' if quote == '\'' => {
+                    chars.next();
+                    State::Quoted(quote)
+                }
+                c if c == quote => State::Code,
+                _ => State::Quoted(quote),
+            },
+            State::LineComment if c == '\n' => State::Code,
+            State::BlockComment if c == '*' && chars.peek() == Some(&'/') => {
+                chars.next();
+                State::Code
+            }
+            state => state,
+        };
+    }
+
+    matches!(state, State::Code)
+}
+
+/// Byte offset of the start of a line
+fn line_offset(lines: &[&str], line: usize) -> usize {
+    lines[..line].iter().map(|text| text.len() + 1).sum()
+}
+
+/// Byte index of a UTF-16 offset in a line, clamped to its end
+fn byte_index(text: &str, character: u32) -> usize {
+    let mut units = 0;
+    for (index, c) in text.char_indices() {
+        if units >= character {
+            return index;
+        }
+        units += c.len_utf16() as u32;
+    }
+    text.len()
+}
+
+fn is_word_char(c: char) -> bool {
+    c.is_ascii_alphanumeric() || c == '_'
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Apply non-overlapping edits in document order
+    fn apply(source: &str, edits: &[TextEdit]) -> String {
+        let offset = |position: Position| {
+            let line_start: usize = source
+                .split_inclusive('\n')
+                .take(position.line as usize)
+                .map(str::len)
+                .sum();
+            let line = &source[line_start..];
+            line_start
+                + line
+                    .char_indices()
+                    .scan(0, |units, (index, c)| {
+                        let start = *units;
+                        *units += c.len_utf16() as u32;
+                        Some((index, start))
+                    })
+                    .find(|(_, units)| *units >= position.character)
+                    .map_or(line.len(), |(index, _)| index)
+        };
+
+        let mut result = source.to_string();
+        for edit in edits.iter().rev() {
+            let range = offset(edit.range.start)..offset(edit.range.end);
+            result.replace_range(range, &edit.new_text);
+        }
+        result
+    }
+
+    #[test]
+    fn test_formatted_document_has_no_edits() {
+        assert!(whitespace_edits("SELECT 1;\n").is_empty());
+        assert!(whitespace_edits("").is_empty());
+    }
+
+    #[test]
+    fn test_trailing_whitespace_is_removed() {
+        let source = "SELECT id,  \n  name\t\nFROM users;\n";
+        let edits = whitespace_edits(source);
+
+        assert_eq!(edits.len(), 2);
+        assert_eq!(apply(source, &edits), "SELECT id,\n  name\nFROM users;\n");
+    }
+
+    #[test]
+    fn test_single_final_newline() {
+        assert_eq!(
+            apply("SELECT 1;", &whitespace_edits("SELECT 1;")),
+            "SELECT 1;\n"
+        );
+        let source = "SELECT 1;  \n\n \n";
+        assert_eq!(apply(source, &whitespace_edits(source)), "SELECT 1;\n");
+    }
+
+    #[test]
+    fn test_crlf_line_endings_are_kept() {
+        let source = "SELECT 1; \r\nSELECT 2;";
+        assert_eq!(
+            apply(source, &whitespace_edits(source)),
+            "SELECT 1;\r\nSELECT 2;\r\n"
+        );
+    }
+
+    #[test]
+    fn test_positions_are_utf16() {
+        let edits = whitespace_edits("SELECT '😀' \n");
+        assert_eq!(edits[0].range.start, Position::new(0, 11));
+    }
+
+    /// Apply the edits of typing before the `|` marker: a newline when the
+    /// cursor line is blank up to the cursor (the client may have indented
+    /// it), a space otherwise
+    fn type_at(source: &str) -> String {
+        let (before, after) = source.split_once('|').expect("cursor marker");
+        let line = before.matches('\n').count() as u32;
+        let indent = before.rsplit('\n').next().unwrap();
+        let character = utf16_len(indent);
+        let ch = if line > 0 && indent.trim().is_empty() {
+            "\n"
+        } else {
+            " "
+        };
+        let source = format!("{}{}", before, after);
+        let config = OnTypeFormattingConfig {
+            enabled: true,
+            ..Default::default()
+        };
+
+        let edits = on_type_edits(
+            &source,
+            Position::new(line, character),
+            ch,
+            Dialect::PostgreSQL,
+            &config,
+        );
+        apply(&source, &edits)
+    }
+
+    #[test]
+    fn test_completed_keyword_is_uppercased() {
+        assert_eq!(type_at("select |"), "SELECT ");
+        assert_eq!(type_at("SELECT id from|"), "SELECT id from");
+        assert_eq!(type_at("SELECT id from |users"), "SELECT id FROM users");
+        assert_eq!(
+            type_at("SELECT * FROM users where\n|"),
+            "SELECT * FROM users WHERE\n"
+        );
+
+        // Names and qualified names stay as typed
+        assert_eq!(type_at("SELECT name |"), "SELECT name ");
+        assert_eq!(type_at("SELECT t.order |"), "SELECT t.order ");
+    }
+
+    #[test]
+    fn test_new_line_aligns_with_clause() {
+        assert_eq!(type_at("select id,\n|"), "select id,\n       ");
+        assert_eq!(
+            type_at("SELECT DISTINCT id,\n  |name"),
+            "SELECT DISTINCT id,\n                name"
+        );
+        assert_eq!(type_at("SELECT\n|"), "SELECT\n    ");
+        assert_eq!(
+            type_at("SELECT * FROM a JOIN b ON a.id = b.id\n|"),
+            "SELECT * FROM a JOIN b ON a.id = b.id\n                          "
+        );
+
+        // Other clauses and subqueries that were closed keep their indentation
+        assert_eq!(type_at("SELECT id FROM users\n|"), "SELECT id FROM users\n");
+        assert_eq!(
+            type_at("SELECT id, (SELECT 1)\n|"),
+            "SELECT id, (SELECT 1)\n       "
+        );
+    }
+
+    #[test]
+    fn test_no_edits_in_literals_and_comments() {
+        assert_eq!(type_at("SELECT 'select |"), "SELECT 'select ");
+        assert_eq!(type_at("SELECT \"from |"), "SELECT \"from ");
+        assert_eq!(type_at("-- select |"), "-- select ");
+        assert_eq!(type_at("SELECT 1 /* from |"), "SELECT 1 /* from ");
+        assert_eq!(type_at("SELECT 'a,\n|"), "SELECT 'a,\n");
+        assert_eq!(
+            type_at("SELECT 1 -- where\n|"),
+            "SELECT 1 -- where\n       "
+        );
+    }
+}
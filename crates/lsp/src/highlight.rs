@@ -0,0 +1,265 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Document highlights
+//!
+//! This module answers `textDocument/documentHighlight`: the occurrences of
+//! the table, alias or column under the cursor in its statement.
+//!
+//! ```text
+//! SELECT u.id FROM users u WHERE u.name = 'x'
+//!        ^               [u]     ^            cursor on the alias
+//! ```
+//!
+//! Symbols are resolved by the semantic [`OccurrenceResolver`], which also
+//! finds local aliases for `textDocument/references` and the ranges of
+//! `textDocument/linkedEditingRange`. Columns assigned by `UPDATE ... SET`
+//! or listed by `INSERT INTO t (...)` are highlighted as writes, every other
+//! occurrence as a read.
+//!
+//! Linked editing is limited to table aliases: renaming an alias at one
+//! occurrence renames it in the whole statement, while tables and columns
+//! name schema objects and are never live-renamed.
+
+use tower_lsp::lsp_types::{
+    DocumentHighlight, DocumentHighlightKind, LinkedEditingRanges, Location, Position, Range, Url,
+};
+use unified_sql_lsp_semantic::{
+    OccurrenceAccess, OccurrenceResolver, ResolvedSymbol, ResolvedSymbolKind,
+    SchemaDiagnosticAnalyzer, SyntaxRange,
+};
+
+/// Resolve the table, alias or column at a position
+///
+/// # Arguments
+///
+/// * `tree` - Parse tree of the document
+/// * `source` - Document text
+/// * `position` - Cursor position
+///
+/// # Returns
+///
+/// The symbol with its occurrences in the statement, or None when the
+/// cursor is not on an identifier
+pub fn symbol_at(
+    tree: &tree_sitter::Tree,
+    source: &str,
+    position: Position,
+) -> Option<ResolvedSymbol> {
+    let references = SchemaDiagnosticAnalyzer::new().collect_references(tree, source);
+    OccurrenceResolver::new(&references).resolve(position.line, position.character)
+}
+
+/// Highlights of the symbol at a position
+///
+/// # Returns
+///
+/// The highlights in document order, or None when the cursor is not on an
+/// identifier
+pub fn document_highlights(
+    tree: &tree_sitter::Tree,
+    source: &str,
+    position: Position,
+) -> Option<Vec<DocumentHighlight>> {
+    let symbol = symbol_at(tree, source, position)?;
+    let highlights = symbol
+        .occurrences
+        .into_iter()
+        .map(|occurrence| DocumentHighlight {
+            range: syntax_range_to_lsp(occurrence.range),
+            kind: Some(match occurrence.access {
+                OccurrenceAccess::Read => DocumentHighlightKind::READ,
+                OccurrenceAccess::Write => DocumentHighlightKind::WRITE,
+            }),
+        })
+        .collect();
+    Some(highlights)
+}
+
+/// Occurrences of the table or output alias at a position
+///
+/// Aliases are local to their statement, so the workspace index does not
+/// record them; references to an alias are its occurrences in the document.
+///
+/// # Returns
+///
+/// The locations in document order, or None when the cursor is not on an
+/// alias
+pub fn alias_locations(
+    tree: &tree_sitter::Tree,
+    source: &str,
+    uri: &Url,
+    position: Position,
+) -> Option<Vec<Location>> {
+    let symbol = symbol_at(tree, source, position)?;
+    if !matches!(
+        symbol.kind,
+        ResolvedSymbolKind::TableAlias | ResolvedSymbolKind::OutputAlias
+    ) {
+        return None;
+    }
+
+    Some(
+        symbol
+            .occurrences
+            .iter()
+            .map(|occurrence| Location::new(uri.clone(), syntax_range_to_lsp(occurrence.range)))
+            .collect(),
+    )
+}
+
+/// Characters an alias may contain while it is edited
+pub const ALIAS_WORD_PATTERN: &str = "[A-Za-z_][A-Za-z0-9_$]*";
+
+/// Linked editing ranges of the table alias at a position
+///
+/// # Arguments
+///
+/// * `tree` - Parse tree of the document
+/// * `source` - Document text
+/// * `position` - Cursor position
+///
+/// # Returns
+///
+/// The definition and usages of the alias in its statement, or None when the
+/// cursor is not on a table alias or the alias is quoted
+pub fn linked_editing_ranges(
+    tree: &tree_sitter::Tree,
+    source: &str,
+    position: Position,
+) -> Option<LinkedEditingRanges> {
+    let symbol = symbol_at(tree, source, position)?;
+    if symbol.kind != ResolvedSymbolKind::TableAlias {
+        return None;
+    }
+
+    // Linked ranges must hold the same text, which a quoted occurrence does not
+    let width = symbol.name.encode_utf16().count() as u32;
+    let plain = symbol.occurrences.iter().all(|occurrence| {
+        let range = occurrence.range;
+        range.start_line == range.end_line && range.end_character - range.start_character == width
+    });
+    if !plain {
+        return None;
+    }
+
+    Some(LinkedEditingRanges {
+        ranges: symbol
+            .occurrences
+            .iter()
+            .map(|occurrence| syntax_range_to_lsp(occurrence.range))
+            .collect(),
+        word_pattern: Some(ALIAS_WORD_PATTERN.to_string()),
+    })
+}
+
+/// Convert a semantic range to an LSP range
+fn syntax_range_to_lsp(range: SyntaxRange) -> Range {
+    Range::new(
+        Position::new(range.start_line, range.start_character),
+        Position::new(range.end_line, range.end_character),
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parsing::{ParseResult, ParserManager};
+    use unified_sql_lsp_ir::Dialect;
+
+    fn parse(sql: &str) -> tree_sitter::Tree {
+        match ParserManager::new().parse_text(Dialect::MySQL, sql) {
+            ParseResult::Success {
+                tree: Some(tree), ..
+            } => tree,
+            other => panic!("Failed to parse {}: {:?}", sql, other),
+        }
+    }
+
+    /// Highlights at the `|` marker of a single-line query, as
+    /// (start character, kind) pairs
+    fn highlights(sql: &str) -> Option<Vec<(u32, DocumentHighlightKind)>> {
+        let character = sql.find('|').expect("cursor marker") as u32;
+        let sql = sql.replace('|', "");
+        let tree = parse(&sql);
+
+        document_highlights(&tree, &sql, Position::new(0, character)).map(|highlights| {
+            highlights
+                .into_iter()
+                .map(|h| (h.range.start.character, h.kind.unwrap()))
+                .collect()
+        })
+    }
+
+    #[test]
+    fn test_alias_highlights_qualified_columns() {
+        let read = DocumentHighlightKind::READ;
+        assert_eq!(
+            highlights("SELECT u.id FROM users |u WHERE u.name = 'x'"),
+            Some(vec![(7, read), (23, read), (31, read)])
+        );
+    }
+
+    #[test]
+    fn test_assigned_column_is_write() {
+        assert_eq!(
+            highlights("UPDATE users SET |name = 'a' WHERE name = 'b'"),
+            Some(vec![
+                (17, DocumentHighlightKind::WRITE),
+                (34, DocumentHighlightKind::READ)
+            ])
+        );
+    }
+
+    #[test]
+    fn test_alias_locations() {
+        let sql = "SELECT o.total FROM orders o ORDER BY o.total";
+        let tree = parse(sql);
+        let uri = Url::parse("file:///tmp/report.sql").unwrap();
+
+        let locations = alias_locations(&tree, sql, &uri, Position::new(0, 27)).unwrap();
+        let starts: Vec<u32> = locations.iter().map(|l| l.range.start.character).collect();
+        assert_eq!(starts, vec![7, 27, 38]);
+
+        // Tables and columns are found through the workspace index
+        assert!(alias_locations(&tree, sql, &uri, Position::new(0, 21)).is_none());
+    }
+
+    /// Start characters of the linked editing ranges at the `|` marker
+    fn linked(sql: &str) -> Option<Vec<u32>> {
+        let character = sql.find('|').expect("cursor marker") as u32;
+        let sql = sql.replace('|', "");
+        let tree = parse(&sql);
+
+        linked_editing_ranges(&tree, &sql, Position::new(0, character)).map(|linked| {
+            assert_eq!(linked.word_pattern.as_deref(), Some(ALIAS_WORD_PATTERN));
+            linked.ranges.iter().map(|r| r.start.character).collect()
+        })
+    }
+
+    #[test]
+    fn test_linked_editing_of_alias() {
+        let sql = "SELECT u.id FROM users |u, (SELECT o.id FROM orders o WHERE o.user_id = u.id) \
+                   AS x WHERE u.id > 0 ORDER BY u.name";
+        // Select list, definition, correlated subquery, WHERE and ORDER BY
+        assert_eq!(linked(sql), Some(vec![7, 23, 71, 88, 106]));
+
+        // Usages link back to the definition
+        let sql = "SELECT u.id FROM users u WHERE |u.name = 'x'";
+        assert_eq!(linked(sql), Some(vec![7, 23, 31]));
+    }
+
+    #[test]
+    fn test_no_linked_editing_of_schema_objects() {
+        assert_eq!(linked("SELECT u.id FROM |users u"), None);
+        assert_eq!(linked("SELECT u.|id FROM users u"), None);
+        assert_eq!(linked("SELECT id FROM |users"), None);
+    }
+
+    #[test]
+    fn test_keyword_has_no_highlights() {
+        assert_eq!(highlights("SELECT id FR|OM users"), None);
+    }
+}
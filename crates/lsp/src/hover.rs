@@ -32,7 +32,7 @@
 use std::sync::Arc;
 use tower_lsp::lsp_types::Position;
 use tree_sitter::Node;
-use unified_sql_lsp_catalog::Catalog;
+use unified_sql_lsp_catalog::{Catalog, TableMetadata};
 use unified_sql_lsp_function_registry::HoverInfoProvider;
 use unified_sql_lsp_function_registry::hover::ColumnHoverInfo;
 use unified_sql_lsp_ir::Dialect;
@@ -116,8 +116,8 @@ impl HoverEngine {
         // Check if we're in a FROM clause (hovering over table name or alias)
         if semantic_hover.is_in_from_clause(&node) {
             // Try to resolve as table name first
-            if let Some(table_name) = semantic_hover.resolve_table_name(&word).await {
-                return Some(self.hover_provider.get_table_hover(&table_name));
+            if let Some(table) = semantic_hover.resolve_table(&word).await {
+                return Some(self.table_hover(&word, &table));
             }
 
             // Try to resolve as table alias.
@@ -164,13 +164,24 @@ impl HoverEngine {
         }
 
         // Fallback: try as table name (for bare table references)
-        if let Some(table_name) = semantic_hover.resolve_table_name(&word).await {
-            return Some(self.hover_provider.get_table_hover(&table_name));
+        if let Some(table) = semantic_hover.resolve_table(&word).await {
+            return Some(self.table_hover(&word, &table));
         }
 
         None
     }
 
+    /// Hover text of a table, named as the hovered word was written
+    fn table_hover(&self, word: &str, table: &TableMetadata) -> String {
+        let name = if word.contains('.') {
+            table.qualified_name()
+        } else {
+            table.name.clone()
+        };
+        self.hover_provider
+            .get_table_hover(&name, table.comment.as_deref())
+    }
+
     fn extract_visible_tables(select_node: &Node<'_>, source: &str) -> Vec<String> {
         ScopeBuilder::build_from_select(select_node, source)
             .ok()
@@ -186,5 +197,6 @@ fn to_column_hover_info(column: &unified_sql_lsp_catalog::ColumnMetadata) -> Col
         data_type: column.data_type.clone(),
         is_primary_key: column.is_primary_key,
         is_foreign_key: column.is_foreign_key,
+        comment: column.comment.clone(),
     }
 }
@@ -62,6 +62,8 @@
 //!
 //! ```rust,no_run
 //! use unified_sql_lsp_lsp::LspBackend;
+//! use unified_sql_lsp_lsp::backend::STATS_METHOD;
+//! use unified_sql_lsp_lsp::diagnostic_scheduler::FOCUS_DOCUMENT_METHOD;
 //! use tower_lsp::{LspService, Server};
 //!
 //! #[tokio::main]
@@ -69,8 +71,11 @@
 //!     let stdin = tokio::io::stdin();
 //!     let stdout = tokio::io::stdout();
 //!
-//!     // Create the LSP service
-//!     let (service, socket) = LspService::new(LspBackend::new);
+//!     // Create the LSP service, with the server's custom methods
+//!     let (service, socket) = LspService::build(LspBackend::new)
+//!         .custom_method(STATS_METHOD, LspBackend::stats)
+//!         .custom_method(FOCUS_DOCUMENT_METHOD, LspBackend::focus_document)
+//!         .finish();
 //!
 //!     // Run the server
 //!     Server::new(stdin, stdout, socket).serve(service).await;
@@ -158,17 +163,46 @@
 //! ```
 
 pub mod backend;
+pub mod call_hierarchy;
 pub mod catalog_manager;
+pub mod client_capabilities;
+pub mod commands;
 pub mod completion;
 pub mod config;
+pub mod config_check;
+pub mod connection_health;
 pub mod diagnostic;
+pub mod diagnostic_scheduler;
 pub mod document;
+pub mod document_link;
+pub mod embedded;
+pub mod formatting;
+pub mod highlight;
 mod hover;
+pub mod lint;
+pub mod log_redaction;
+pub mod logging;
+pub mod migrations;
+pub mod outbound;
+pub mod parameters;
+pub mod parent_process;
 pub mod parsing;
+pub mod recording;
 mod request_context;
+pub mod request_log;
+pub mod schema_cache;
+pub mod schema_dependencies;
+pub mod search_path;
+pub mod selection_range;
+pub mod server_version;
+pub mod session_objects;
+pub mod signature_help;
+pub mod ssh_tunnel;
 mod symbols;
 pub mod sync;
 pub mod tcp;
+pub mod transport;
+pub mod workspace_index;
 
 // profiling module removed in "drop bench" commit
 // TODO: restore if benchmarking is re-added
@@ -0,0 +1,823 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Dangerous-statement lints
+//!
+//! This module provides an opt-in lint pass reporting statements that are
+//! easy to run by accident:
+//!
+//! | Code       | Rule                                   | Default severity |
+//! |------------|----------------------------------------|------------------|
+//! | SQLLINT001 | UPDATE / DELETE without WHERE          | warning          |
+//! | SQLLINT002 | TRUNCATE / DROP TABLE                  | warning          |
+//! | SQLLINT003 | SELECT *                               | information      |
+//!
+//! ## Configuration
+//!
+//! ```json
+//! "lint": {
+//!   "enabled": true,
+//!   "exclude": ["migrations/**"],
+//!   "rules": { "SQLLINT003": "off" },
+//!   "overrides": [
+//!     { "files": "reports/**", "rules": { "SQLLINT003": "hint" } }
+//!   ]
+//! }
+//! ```
+//!
+//! Files matching `exclude` are not linted unless an override re-enables a
+//! rule for them. Overrides are applied in order, later ones winning.
+//! Patterns are matched against the trailing components of the document
+//! path, so `migrations/**` matches any `migrations` directory.
+//!
+//! ## Suppression
+//!
+//! A `-- sql-lsp: disable-next-line` comment suppresses all diagnostics
+//! starting on the following line; listing codes after the marker
+//! (`-- sql-lsp: disable-next-line SQLLINT001, SQLLINT003`) limits it to
+//! those codes.
+
+use serde_json::{Value, json};
+use std::collections::HashMap;
+use tower_lsp::lsp_types::*;
+
+use crate::config::parse_severity_setting;
+use crate::diagnostic::{DiagnosticCode, SqlDiagnostic, node_to_range};
+
+/// Diagnostic source for lint diagnostics
+pub const LINT_DIAGNOSTIC_SOURCE: &str = "sql-lint";
+
+/// Comment marker suppressing diagnostics on the next line
+pub const SUPPRESSION_MARKER: &str = "sql-lsp: disable-next-line";
+
+/// Lint rule
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum LintRule {
+    /// UPDATE or DELETE without a WHERE clause
+    MissingWhere,
+
+    /// TRUNCATE or DROP TABLE
+    DestructiveStatement,
+
+    /// SELECT *
+    SelectStar,
+}
+
+impl LintRule {
+    /// All lint rules
+    pub const ALL: [LintRule; 3] = [
+        LintRule::MissingWhere,
+        LintRule::DestructiveStatement,
+        LintRule::SelectStar,
+    ];
+
+    /// Get the diagnostic code of this rule
+    pub fn code(&self) -> &'static str {
+        match self {
+            LintRule::MissingWhere => "SQLLINT001",
+            LintRule::DestructiveStatement => "SQLLINT002",
+            LintRule::SelectStar => "SQLLINT003",
+        }
+    }
+
+    /// Look up a rule by its diagnostic code
+    pub fn from_code(code: &str) -> Option<Self> {
+        Self::ALL
+            .into_iter()
+            .find(|rule| rule.code().eq_ignore_ascii_case(code))
+    }
+
+    /// Get the severity used when the rule is not configured
+    pub fn default_severity(&self) -> DiagnosticSeverity {
+        match self {
+            LintRule::MissingWhere | LintRule::DestructiveStatement => DiagnosticSeverity::WARNING,
+            LintRule::SelectStar => DiagnosticSeverity::INFORMATION,
+        }
+    }
+}
+
+/// Per-path rule severity override
+#[derive(Debug, Clone)]
+pub struct LintOverride {
+    /// Glob pattern of files the override applies to
+    pub files: String,
+
+    /// Rule severities (`None` disables the rule)
+    pub rules: HashMap<LintRule, Option<DiagnosticSeverity>>,
+}
+
+/// Lint configuration
+#[derive(Debug, Clone)]
+pub struct LintConfig {
+    /// Enable the lint pass (opt-in)
+    pub enabled: bool,
+
+    /// Glob patterns of files that are not linted
+    pub exclude: Vec<String>,
+
+    /// Rule severities (`None` disables the rule)
+    pub rules: HashMap<LintRule, Option<DiagnosticSeverity>>,
+
+    /// Per-path overrides, applied in order
+    pub overrides: Vec<LintOverride>,
+}
+
+impl Default for LintConfig {
+    fn default() -> Self {
+        Self {
+            enabled: false,
+            exclude: vec!["migrations/**".to_string()],
+            rules: HashMap::new(),
+            overrides: Vec::new(),
+        }
+    }
+}
+
+impl LintConfig {
+    /// Parse lint configuration from the `lint` settings object
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut config = Self::default();
+
+        if let Some(enabled) = settings.get("enabled").and_then(Value::as_bool) {
+            config.enabled = enabled;
+        }
+        if let Some(exclude) = settings.get("exclude").and_then(Value::as_array) {
+            config.exclude = exclude
+                .iter()
+                .filter_map(Value::as_str)
+                .map(str::to_string)
+                .collect();
+        }
+        if let Some(rules) = settings.get("rules") {
+            config.rules = parse_rules(rules);
+        }
+        if let Some(overrides) = settings.get("overrides").and_then(Value::as_array) {
+            config.overrides = overrides
+                .iter()
+                .filter_map(|o| {
+                    Some(LintOverride {
+                        files: o.get("files")?.as_str()?.to_string(),
+                        rules: o.get("rules").map(parse_rules).unwrap_or_default(),
+                    })
+                })
+                .collect();
+        }
+
+        config
+    }
+
+    /// Get the effective severity of a rule for a document path
+    ///
+    /// # Returns
+    ///
+    /// `None` when the rule is disabled for this path
+    pub fn severity_for(&self, rule: LintRule, path: &str) -> Option<DiagnosticSeverity> {
+        let excluded = self.exclude.iter().any(|p| glob_match(p, path));
+
+        let mut severity = if excluded {
+            None
+        } else {
+            self.rules
+                .get(&rule)
+                .copied()
+                .unwrap_or(Some(rule.default_severity()))
+        };
+
+        for o in self.overrides.iter().filter(|o| glob_match(&o.files, path)) {
+            if let Some(overridden) = o.rules.get(&rule) {
+                severity = *overridden;
+            }
+        }
+
+        severity
+    }
+}
+
+/// Parse a `{ "SQLLINT001": "warning", ... }` rules object
+fn parse_rules(rules: &Value) -> HashMap<LintRule, Option<DiagnosticSeverity>> {
+    rules
+        .as_object()
+        .map(|rules| {
+            rules
+                .iter()
+                .filter_map(|(code, severity)| {
+                    Some((
+                        LintRule::from_code(code)?,
+                        parse_severity_setting(severity.as_str()?),
+                    ))
+                })
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+/// Run the lint pass over a parsed document
+///
+/// # Arguments
+///
+/// * `tree` - The parsed syntax tree
+/// * `source` - The source code text
+/// * `uri` - The document URI (matched against the configured globs)
+/// * `config` - The lint configuration
+///
+/// # Returns
+///
+/// Lint diagnostics with source "sql-lint"; empty when linting is disabled
+pub fn lint_document(
+    tree: &tree_sitter::Tree,
+    source: &str,
+    uri: &Url,
+    config: &LintConfig,
+) -> Vec<SqlDiagnostic> {
+    if !config.enabled {
+        return Vec::new();
+    }
+
+    let path = uri.path();
+    let mut findings = Vec::new();
+    collect_findings(&tree.root_node(), source, &mut findings, 0);
+    collect_destructive_statements(source, &mut findings);
+
+    findings
+        .into_iter()
+        .filter_map(|finding| {
+            let severity = config.severity_for(finding.rule, path)?;
+            let mut diagnostic = SqlDiagnostic::new(finding.message, severity, finding.range)
+                .with_code(DiagnosticCode::Custom(finding.rule.code().to_string()))
+                .with_source(LINT_DIAGNOSTIC_SOURCE);
+            if let Some(position) = finding.where_insert_position {
+                diagnostic = diagnostic.with_data(json!({ "whereInsertPosition": position }));
+            }
+            Some(diagnostic)
+        })
+        .collect()
+}
+
+/// A rule violation before severity is applied
+struct LintFinding {
+    rule: LintRule,
+    message: String,
+    range: Range,
+    /// Where a WHERE clause can be inserted (MissingWhere only)
+    where_insert_position: Option<Position>,
+}
+
+/// Find UPDATE/DELETE without WHERE and SELECT * in the syntax tree
+fn collect_findings(
+    node: &tree_sitter::Node,
+    source: &str,
+    findings: &mut Vec<LintFinding>,
+    depth: usize,
+) {
+    if depth > 100 {
+        return;
+    }
+
+    match node.kind() {
+        "update_statement" | "delete_statement" => {
+            let has_where = node
+                .children(&mut node.walk())
+                .any(|c| c.kind() == "where_clause");
+            if !has_where && let Some(keyword) = node.child(0) {
+                let statement = if node.kind() == "update_statement" {
+                    "UPDATE"
+                } else {
+                    "DELETE"
+                };
+                findings.push(LintFinding {
+                    rule: LintRule::MissingWhere,
+                    message: format!("{} without WHERE affects every row", statement),
+                    range: node_to_range(&keyword),
+                    where_insert_position: Some(node_to_range(node).end),
+                });
+            }
+        }
+        "projection" if source[node.byte_range()].trim() == "*" => {
+            findings.push(LintFinding {
+                rule: LintRule::SelectStar,
+                message: "SELECT * returns every column; list the columns explicitly".to_string(),
+                range: node_to_range(node),
+                where_insert_position: None,
+            });
+        }
+        _ => {}
+    }
+
+    for child in node.children(&mut node.walk()) {
+        collect_findings(&child, source, findings, depth + 1);
+    }
+}
+
+/// Find TRUNCATE and DROP TABLE statements
+///
+/// These statements are not part of the grammar, so they are found by
+/// scanning the leading keywords of each `;`-separated statement, skipping
+/// comments and quoted text.
+fn collect_destructive_statements(source: &str, findings: &mut Vec<LintFinding>) {
+    for start in statement_starts(source) {
+        let words: Vec<(usize, &str)> = leading_words(source, start, 2);
+        let Some(&(first_offset, first)) = words.first() else {
+            continue;
+        };
+
+        let end = if first.eq_ignore_ascii_case("TRUNCATE") {
+            first_offset + first.len()
+        } else if first.eq_ignore_ascii_case("DROP")
+            && let Some(&(second_offset, second)) = words.get(1)
+            && second.eq_ignore_ascii_case("TABLE")
+        {
+            second_offset + second.len()
+        } else {
+            continue;
+        };
+
+        findings.push(LintFinding {
+            rule: LintRule::DestructiveStatement,
+            message: format!(
+                "{} permanently removes data",
+                source[first_offset..end].to_uppercase()
+            ),
+            range: Range::new(
+                byte_to_position(source, first_offset),
+                byte_to_position(source, end),
+            ),
+            where_insert_position: None,
+        });
+    }
+}
+
+/// Byte offsets where statements start (after skipping whitespace and comments)
+fn statement_starts(source: &str) -> Vec<usize> {
+    let bytes = source.as_bytes();
+    let mut starts = Vec::new();
+    let mut at_statement_start = true;
+    let mut i = 0;
+
+    while i < bytes.len() {
+        match bytes[i] {
+            b'-' if bytes.get(i + 1) == Some(&b'-') => {
+                i = source[i..].find('\n').map_or(bytes.len(), |n| i + n);
+                continue;
+            }
+            b'/' if bytes.get(i + 1) == Some(&b'*') => {
+                i = source[i + 2..]
+                    .find("*/")
+                    .map_or(bytes.len(), |n| i + n + 4);
+                continue;
+            }
+            b';' => at_statement_start = true,
+            c if c.is_ascii_whitespace() => {}
+            quote @ (b'\'' | b'"' | b'`') => {
+                at_statement_start = false;
+                i = source[i + 1..]
+                    .find(quote as char)
+                    .map_or(bytes.len(), |n| i + n + 2);
+                continue;
+            }
+            _ => {
+                if at_statement_start {
+                    starts.push(i);
+                    at_statement_start = false;
+                }
+            }
+        }
+        i += 1;
+    }
+
+    starts
+}
+
+/// The first `count` words starting at `start`, with their byte offsets
+fn leading_words(source: &str, start: usize, count: usize) -> Vec<(usize, &str)> {
+    let mut words = Vec::new();
+    let mut offset = start;
+
+    while words.len() < count {
+        let rest = &source[offset..];
+        let skipped = rest.len() - rest.trim_start().len();
+        offset += skipped;
+        let rest = &source[offset..];
+        let len = rest
+            .find(|c: char| !c.is_ascii_alphanumeric() && c != '_')
+            .unwrap_or(rest.len());
+        if len == 0 {
+            break;
+        }
+        words.push((offset, &rest[..len]));
+        offset += len;
+    }
+
+    words
+}
+
+/// Convert a byte offset to a position (byte column, like tree-sitter ranges)
+fn byte_to_position(source: &str, offset: usize) -> Position {
+    let before = &source[..offset];
+    let line = before.matches('\n').count() as u32;
+    let column = before.rfind('\n').map_or(offset, |n| offset - n - 1) as u32;
+    Position::new(line, column)
+}
+
+/// Drop diagnostics suppressed by a `-- sql-lsp: disable-next-line` comment
+///
+/// # Arguments
+///
+/// * `diagnostics` - The diagnostics to filter
+/// * `source` - The document text
+///
+/// # Returns
+///
+/// The diagnostics not suppressed by a comment on the preceding line
+pub fn apply_suppressions(diagnostics: Vec<SqlDiagnostic>, source: &str) -> Vec<SqlDiagnostic> {
+    let lines: Vec<&str> = source.lines().collect();
+
+    diagnostics
+        .into_iter()
+        .filter(|diagnostic| {
+            let line = diagnostic.range.start.line as usize;
+            let Some(previous) = line.checked_sub(1).and_then(|l| lines.get(l)) else {
+                return true;
+            };
+            let Some(codes) = suppressed_codes(previous) else {
+                return true;
+            };
+
+            let code = diagnostic.code.as_ref().map(DiagnosticCode::as_str);
+            !(codes.is_empty()
+                || code.is_some_and(|code| codes.iter().any(|c| c.eq_ignore_ascii_case(&code))))
+        })
+        .collect()
+}
+
+/// Parse a suppression comment
+///
+/// # Returns
+///
+/// `None` if the line has no suppression comment, otherwise the listed codes
+/// (empty = all codes)
+fn suppressed_codes(line: &str) -> Option<Vec<String>> {
+    let comment = &line[line.find("--")? + 2..];
+    let rest = comment.trim_start().strip_prefix(SUPPRESSION_MARKER)?;
+
+    Some(
+        rest.split(|c: char| c == ',' || c.is_whitespace())
+            .filter(|code| !code.is_empty())
+            .map(str::to_string)
+            .collect(),
+    )
+}
+
+/// Build code actions for lint diagnostics
+///
+/// Offers "Add WHERE clause" for SQLLINT001 and a suppression comment for
+/// every lint diagnostic.
+///
+/// # Arguments
+///
+/// * `uri` - The document URI
+/// * `source` - The document text
+/// * `diagnostics` - Diagnostics from the code action request context
+pub fn code_actions(
+    uri: &Url,
+    source: &str,
+    diagnostics: &[Diagnostic],
+) -> Vec<CodeActionOrCommand> {
+    let lines: Vec<&str> = source.lines().collect();
+    let mut actions = Vec::new();
+
+    for diagnostic in diagnostics
+        .iter()
+        .filter(|d| d.source.as_deref() == Some(LINT_DIAGNOSTIC_SOURCE))
+    {
+        let Some(NumberOrString::String(code)) = &diagnostic.code else {
+            continue;
+        };
+
+        if LintRule::from_code(code) == Some(LintRule::MissingWhere)
+            && let Some(position) = diagnostic
+                .data
+                .as_ref()
+                .and_then(|data| data.get("whereInsertPosition"))
+                .and_then(|p| serde_json::from_value::<Position>(p.clone()).ok())
+        {
+            actions.push(quick_fix(
+                uri,
+                "Add WHERE clause",
+                diagnostic,
+                TextEdit::new(Range::new(position, position), " WHERE ".to_string()),
+                true,
+            ));
+        }
+
+        let line = diagnostic.range.start.line;
+        let indent: String = lines
+            .get(line as usize)
+            .map(|l| l.chars().take_while(|c| c.is_whitespace()).collect())
+            .unwrap_or_default();
+        let start = Position::new(line, 0);
+        actions.push(quick_fix(
+            uri,
+            &format!("Suppress {} for this statement", code),
+            diagnostic,
+            TextEdit::new(
+                Range::new(start, start),
+                format!("{}-- {} {}\n", indent, SUPPRESSION_MARKER, code),
+            ),
+            false,
+        ));
+    }
+
+    actions
+}
+
+/// Build a quick fix applying a single edit
+fn quick_fix(
+    uri: &Url,
+    title: &str,
+    diagnostic: &Diagnostic,
+    edit: TextEdit,
+    is_preferred: bool,
+) -> CodeActionOrCommand {
+    let mut changes = HashMap::new();
+    changes.insert(uri.clone(), vec![edit]);
+
+    CodeActionOrCommand::CodeAction(CodeAction {
+        title: title.to_string(),
+        kind: Some(CodeActionKind::QUICKFIX),
+        diagnostics: Some(vec![diagnostic.clone()]),
+        edit: Some(WorkspaceEdit {
+            changes: Some(changes),
+            ..Default::default()
+        }),
+        is_preferred: Some(is_preferred),
+        ..Default::default()
+    })
+}
+
+/// Match a glob pattern against the trailing components of a path
+///
+/// Supports `*` and `?` within a component and `**` for any number of
+/// components. A pattern matches if it matches the whole path or any
+/// trailing sub-path, so relative patterns work without a workspace root.
+pub fn glob_match(pattern: &str, path: &str) -> bool {
+    let pattern: Vec<&str> = pattern.split('/').filter(|s| !s.is_empty()).collect();
+    let path: Vec<&str> = path.split('/').filter(|s| !s.is_empty()).collect();
+
+    (0..=path.len()).any(|start| match_components(&pattern, &path[start..]))
+}
+
+/// Match a glob pattern against a whole relative path
+///
+/// Unlike [`glob_match`], the pattern must match from the first component.
+pub(crate) fn glob_match_anchored(pattern: &str, path: &str) -> bool {
+    let pattern: Vec<&str> = pattern.split('/').filter(|s| !s.is_empty()).collect();
+    let path: Vec<&str> = path.split('/').filter(|s| !s.is_empty()).collect();
+
+    match_components(&pattern, &path)
+}
+
+fn match_components(pattern: &[&str], path: &[&str]) -> bool {
+    match pattern.split_first() {
+        None => path.is_empty(),
+        Some((&"**", rest)) => (0..=path.len()).any(|skip| match_components(rest, &path[skip..])),
+        Some((first, rest)) => path.split_first().is_some_and(|(head, tail)| {
+            match_component(first, head) && match_components(rest, tail)
+        }),
+    }
+}
+
+fn match_component(pattern: &str, text: &str) -> bool {
+    let pattern: Vec<char> = pattern.chars().collect();
+    let text: Vec<char> = text.chars().collect();
+
+    fn matches(p: &[char], t: &[char]) -> bool {
+        match p.split_first() {
+            None => t.is_empty(),
+            Some(('*', rest)) => (0..=t.len()).any(|skip| matches(rest, &t[skip..])),
+            Some(('?', rest)) => !t.is_empty() && matches(rest, &t[1..]),
+            Some((c, rest)) => t.first() == Some(c) && matches(rest, &t[1..]),
+        }
+    }
+
+    matches(&pattern, &text)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn parse(sql: &str) -> Option<tree_sitter::Tree> {
+        let language =
+            unified_sql_grammar::language_for_dialect(unified_sql_lsp_ir::Dialect::MySQL)?;
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(language).ok()?;
+        parser.parse(sql, None)
+    }
+
+    fn enabled() -> LintConfig {
+        LintConfig {
+            enabled: true,
+            ..Default::default()
+        }
+    }
+
+    fn lint(sql: &str, path: &str, config: &LintConfig) -> Vec<SqlDiagnostic> {
+        let tree = parse(sql).expect("Failed to parse SQL");
+        let uri = Url::parse(&format!("file://{}", path)).unwrap();
+        lint_document(&tree, sql, &uri, config)
+    }
+
+    fn codes(diagnostics: &[SqlDiagnostic]) -> Vec<String> {
+        diagnostics
+            .iter()
+            .filter_map(|d| d.code.as_ref().map(DiagnosticCode::as_str))
+            .collect()
+    }
+
+    #[test]
+    fn test_lint_disabled_by_default() {
+        let diagnostics = lint("DELETE FROM users", "/proj/q.sql", &LintConfig::default());
+        assert!(diagnostics.is_empty());
+    }
+
+    #[test]
+    fn test_delete_and_update_without_where() {
+        let diagnostics = lint(
+            "DELETE FROM users;\nUPDATE users SET name = 'x';\nDELETE FROM users WHERE id = 1;",
+            "/proj/q.sql",
+            &enabled(),
+        );
+
+        assert_eq!(codes(&diagnostics), vec!["SQLLINT001", "SQLLINT001"]);
+        assert_eq!(diagnostics[0].source, LINT_DIAGNOSTIC_SOURCE);
+        assert_eq!(diagnostics[0].range.start, Position::new(0, 0));
+        assert_eq!(
+            diagnostics[0].data,
+            Some(json!({ "whereInsertPosition": { "line": 0, "character": 17 } }))
+        );
+        assert_eq!(diagnostics[1].range.start.line, 1);
+    }
+
+    #[test]
+    fn test_destructive_statements() {
+        let sql =
+            "-- cleanup\nTRUNCATE users;\n  drop table orders;\nSELECT 'DROP TABLE x' FROM users";
+        let mut findings = Vec::new();
+        collect_destructive_statements(sql, &mut findings);
+
+        let messages: Vec<&str> = findings.iter().map(|f| f.message.as_str()).collect();
+        assert_eq!(
+            messages,
+            vec![
+                "TRUNCATE permanently removes data",
+                "DROP TABLE permanently removes data"
+            ]
+        );
+        assert_eq!(findings[1].range.start, Position::new(2, 2));
+        assert_eq!(findings[1].range.end, Position::new(2, 12));
+    }
+
+    #[test]
+    fn test_select_star_severity_and_overrides() {
+        let mut config = enabled();
+        let diagnostics = lint("SELECT * FROM users", "/proj/q.sql", &config);
+        assert_eq!(codes(&diagnostics), vec!["SQLLINT003"]);
+        assert_eq!(diagnostics[0].severity, DiagnosticSeverity::INFORMATION);
+
+        config.rules.insert(LintRule::SelectStar, None);
+        config.overrides.push(LintOverride {
+            files: "reports/**".to_string(),
+            rules: HashMap::from([(LintRule::SelectStar, Some(DiagnosticSeverity::ERROR))]),
+        });
+        assert!(lint("SELECT * FROM users", "/proj/q.sql", &config).is_empty());
+
+        let diagnostics = lint("SELECT * FROM users", "/proj/reports/daily.sql", &config);
+        assert_eq!(diagnostics[0].severity, DiagnosticSeverity::ERROR);
+    }
+
+    #[test]
+    fn test_migrations_excluded_by_default() {
+        let config = enabled();
+        assert_eq!(
+            config.severity_for(
+                LintRule::DestructiveStatement,
+                "/proj/migrations/001_init.sql"
+            ),
+            None
+        );
+        assert_eq!(
+            config.severity_for(LintRule::DestructiveStatement, "/proj/scripts/cleanup.sql"),
+            Some(DiagnosticSeverity::WARNING)
+        );
+    }
+
+    #[test]
+    fn test_config_from_settings() {
+        let config = LintConfig::from_settings(&json!({
+            "enabled": true,
+            "exclude": [],
+            "rules": { "SQLLINT001": "error", "SQLLINT003": "off", "UNKNOWN": "hint" },
+            "overrides": [{ "files": "legacy/*.sql", "rules": { "SQLLINT001": "hint" } }]
+        }));
+
+        assert!(config.enabled);
+        assert!(config.exclude.is_empty());
+        assert_eq!(
+            config.severity_for(LintRule::MissingWhere, "/a/q.sql"),
+            Some(DiagnosticSeverity::ERROR)
+        );
+        assert_eq!(config.severity_for(LintRule::SelectStar, "/a/q.sql"), None);
+        assert_eq!(
+            config.severity_for(LintRule::MissingWhere, "/a/legacy/q.sql"),
+            Some(DiagnosticSeverity::HINT)
+        );
+    }
+
+    #[test]
+    fn test_suppression_all_codes() {
+        let sql = "-- sql-lsp: disable-next-line\nDELETE FROM users;\nDELETE FROM orders;";
+        let diagnostics = apply_suppressions(lint(sql, "/proj/q.sql", &enabled()), sql);
+
+        assert_eq!(diagnostics.len(), 1);
+        assert_eq!(diagnostics[0].range.start.line, 2);
+    }
+
+    #[test]
+    fn test_suppression_only_listed_codes() {
+        let sql = "-- sql-lsp: disable-next-line SQLLINT001\nSELECT * FROM users;\n-- sql-lsp: disable-next-line SQLLINT002, SQLLINT001\nDELETE FROM users;";
+        let diagnostics = apply_suppressions(lint(sql, "/proj/q.sql", &enabled()), sql);
+
+        // SELECT * is not listed on line 0, DELETE is listed on line 2
+        assert_eq!(codes(&diagnostics), vec!["SQLLINT003"]);
+    }
+
+    #[test]
+    fn test_suppression_applies_to_next_line_only() {
+        let sql = "-- sql-lsp: disable-next-line\n\nDELETE FROM users;";
+        let diagnostics = apply_suppressions(lint(sql, "/proj/q.sql", &enabled()), sql);
+        assert_eq!(diagnostics.len(), 1);
+    }
+
+    #[test]
+    fn test_suppressed_codes_parsing() {
+        assert_eq!(suppressed_codes("SELECT 1"), None);
+        assert_eq!(suppressed_codes("-- unrelated comment"), None);
+        assert_eq!(
+            suppressed_codes("  -- sql-lsp: disable-next-line"),
+            Some(vec![])
+        );
+        assert_eq!(
+            suppressed_codes("--sql-lsp: disable-next-line SQLLINT001,SQLLINT002"),
+            Some(vec!["SQLLINT001".to_string(), "SQLLINT002".to_string()])
+        );
+    }
+
+    #[test]
+    fn test_code_actions_for_missing_where() {
+        let sql = "  DELETE FROM users;";
+        let uri = Url::parse("file:///proj/q.sql").unwrap();
+        let diagnostics: Vec<Diagnostic> = lint(sql, "/proj/q.sql", &enabled())
+            .into_iter()
+            .map(|d| d.to_lsp())
+            .collect();
+
+        let actions = code_actions(&uri, sql, &diagnostics);
+        let titles: Vec<String> = actions
+            .iter()
+            .map(|a| match a {
+                CodeActionOrCommand::CodeAction(action) => action.title.clone(),
+                CodeActionOrCommand::Command(command) => command.title.clone(),
+            })
+            .collect();
+        assert_eq!(
+            titles,
+            vec!["Add WHERE clause", "Suppress SQLLINT001 for this statement"]
+        );
+
+        let CodeActionOrCommand::CodeAction(suppress) = &actions[1] else {
+            panic!("expected a code action");
+        };
+        let edits = &suppress.edit.as_ref().unwrap().changes.as_ref().unwrap()[&uri];
+        assert_eq!(
+            edits[0].new_text,
+            "  -- sql-lsp: disable-next-line SQLLINT001\n"
+        );
+        assert_eq!(edits[0].range.start, Position::new(0, 0));
+    }
+
+    #[test]
+    fn test_glob_match() {
+        assert!(glob_match("migrations/**", "/proj/migrations/001.sql"));
+        assert!(glob_match(
+            "migrations/**",
+            "/proj/db/migrations/v1/001.sql"
+        ));
+        assert!(glob_match("**/*.sql", "/proj/q.sql"));
+        assert!(glob_match("legacy/*.sql", "/proj/legacy/old.sql"));
+        assert!(glob_match("q?.sql", "/proj/q1.sql"));
+        assert!(!glob_match("migrations/**", "/proj/src/q.sql"));
+        assert!(!glob_match("legacy/*.sql", "/proj/legacy/nested/old.sql"));
+    }
+}
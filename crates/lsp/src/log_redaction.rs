@@ -0,0 +1,109 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Log redaction
+//!
+//! This module wraps the writer of a `tracing_subscriber` formatter so that
+//! passwords are removed from every log line before it is written:
+//!
+//! ```rust,ignore
+//! tracing_subscriber::fmt()
+//!     .with_writer(RedactingWriter::new(std::io::stderr))
+//!     .init();
+//!
+//! // Logged as "connecting to mysql://app@db/shop"
+//! tracing::info!("connecting to {}", "mysql://app:hunter2@db/shop");
+//! ```
+//!
+//! The formatter writes each event with a single call, so connection
+//! strings are never split across writes.
+
+use std::io::{self, Write};
+use tracing_subscriber::fmt::MakeWriter;
+use unified_sql_lsp_catalog::redact_credentials;
+
+/// Writer factory removing passwords from log output
+#[derive(Debug, Clone)]
+pub struct RedactingWriter<M> {
+    inner: M,
+}
+
+impl<M> RedactingWriter<M> {
+    /// Wrap a writer factory
+    ///
+    /// # Arguments
+    ///
+    /// * `inner` - The writer factory receiving the redacted output
+    pub fn new(inner: M) -> Self {
+        Self { inner }
+    }
+}
+
+impl<'a, M: MakeWriter<'a>> MakeWriter<'a> for RedactingWriter<M> {
+    type Writer = Redacting<M::Writer>;
+
+    fn make_writer(&'a self) -> Self::Writer {
+        Redacting(self.inner.make_writer())
+    }
+}
+
+/// Writer removing passwords from what is written to it
+#[derive(Debug)]
+pub struct Redacting<W>(W);
+
+impl<W: Write> Write for Redacting<W> {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        let text = String::from_utf8_lossy(buf);
+        self.0.write_all(redact_credentials(&text).as_bytes())?;
+        Ok(buf.len())
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        self.0.flush()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::{Arc, Mutex};
+
+    /// Writer capturing formatted log output
+    #[derive(Clone, Default)]
+    struct CapturedLogs(Arc<Mutex<Vec<u8>>>);
+
+    impl Write for CapturedLogs {
+        fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+            self.0.lock().unwrap().extend_from_slice(buf);
+            Ok(buf.len())
+        }
+
+        fn flush(&mut self) -> io::Result<()> {
+            Ok(())
+        }
+    }
+
+    #[test]
+    fn test_log_lines_are_redacted() {
+        let logs = CapturedLogs::default();
+        let captured = logs.clone();
+        let subscriber = tracing_subscriber::fmt()
+            .with_writer(RedactingWriter::new(move || captured.clone()))
+            .with_ansi(false)
+            .finish();
+
+        tracing::subscriber::with_default(subscriber, || {
+            tracing::warn!(
+                dsn = "host=db password=hunter2",
+                "failed to connect: postgres://app:hunter2@db/shop"
+            );
+        });
+
+        let output = String::from_utf8(logs.0.lock().unwrap().clone()).unwrap();
+        assert!(!output.contains("hunter2"), "logs: {}", output);
+        assert!(output.contains("postgres://app@db/shop"));
+        assert!(output.contains("password=***"));
+    }
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Log output
+//!
+//! This module sets up where the logs of the server go: stderr, a file, or
+//! both. A stdio server cannot log to stdout, which carries the protocol,
+//! and editors often swallow stderr, so the logs of long sessions are best
+//! written to a file:
+//!
+//! ```text
+//! $ unified-sql-lsp --log-file /tmp/sql-lsp.log --log-max-size-mb 10 --log-max-backups 3
+//! ```
+//!
+//! The file is rotated once it would grow past its maximum size:
+//! `sql-lsp.log` becomes `sql-lsp.log.1`, `sql-lsp.log.1` becomes
+//! `sql-lsp.log.2`, and so on, the oldest backup being deleted. Lines are
+//! written as text or, with `--log-format json`, as JSON objects, with
+//! passwords removed (see [`crate::log_redaction`]).
+//!
+//! The level is a `tracing` filter (`info`, `unified_sql_lsp=trace`). Given
+//! with `--log-level`, it is fixed; otherwise the `log.level` client setting
+//! changes it while the server runs.
+
+use std::fs::{self, File, OpenOptions};
+use std::io::{self, Write};
+use std::path::{Path, PathBuf};
+use std::str::FromStr;
+use std::sync::{Mutex, OnceLock};
+use tracing_subscriber::fmt::MakeWriter;
+use tracing_subscriber::layer::{Layered, SubscriberExt};
+use tracing_subscriber::util::SubscriberInitExt;
+use tracing_subscriber::{EnvFilter, Layer, Registry, reload};
+
+use crate::log_redaction::RedactingWriter;
+
+/// Filter used without `--log-level` or `log.level`
+pub const DEFAULT_LOG_FILTER: &str = "unified_sql_lsp=debug,tower_lsp=debug";
+
+/// Default size of the log file before it is rotated, in megabytes
+pub const DEFAULT_MAX_SIZE_MB: u64 = 10;
+
+/// Default number of rotated log files kept
+pub const DEFAULT_MAX_BACKUPS: usize = 3;
+
+/// Format of the log lines
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum LogFormat {
+    /// Human-readable text
+    #[default]
+    Console,
+
+    /// One JSON object per line, for log collectors
+    Json,
+}
+
+impl FromStr for LogFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "console" | "text" => Ok(Self::Console),
+            "json" => Ok(Self::Json),
+            other => Err(format!(
+                "unknown log format '{}', expected 'console' or 'json'",
+                other
+            )),
+        }
+    }
+}
+
+/// Where and how the server logs
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct LogOptions {
+    /// Log file, rotated by size; `None` logs to no file
+    pub file: Option<PathBuf>,
+
+    /// Size of the log file before it is rotated, in megabytes
+    pub max_size_mb: u64,
+
+    /// Number of rotated log files kept
+    pub max_backups: usize,
+
+    /// Format of the log lines
+    pub format: LogFormat,
+
+    /// Filter fixed on the command line; `None` uses [`DEFAULT_LOG_FILTER`]
+    /// until the client settings change it
+    pub level: Option<String>,
+
+    /// Whether to log to stderr as well
+    pub stderr: bool,
+}
+
+impl Default for LogOptions {
+    fn default() -> Self {
+        Self {
+            file: None,
+            max_size_mb: DEFAULT_MAX_SIZE_MB,
+            max_backups: DEFAULT_MAX_BACKUPS,
+            format: LogFormat::default(),
+            level: None,
+            stderr: false,
+        }
+    }
+}
+
+/// Subscriber the output layers are stacked on
+type Filtered = Layered<reload::Layer<EnvFilter, Registry>, Registry>;
+
+/// Filter of the installed subscriber
+struct LevelControl {
+    handle: reload::Handle<EnvFilter, Registry>,
+    /// Whether the filter was given on the command line
+    fixed: bool,
+}
+
+static LEVEL: OnceLock<LevelControl> = OnceLock::new();
+
+/// Install the global subscriber
+///
+/// Nothing is installed when the options log neither to a file nor to
+/// stderr.
+///
+/// # Errors
+///
+/// If the log file cannot be opened or a subscriber is already installed
+pub fn init(options: &LogOptions) -> io::Result<()> {
+    let mut layers: Vec<Box<dyn Layer<Filtered> + Send + Sync>> = Vec::new();
+    if let Some(path) = &options.file {
+        let max_bytes = options.max_size_mb.saturating_mul(1024 * 1024);
+        let file = RotatingFile::open(path, max_bytes, options.max_backups)?;
+        layers.push(output_layer(options.format, Mutex::new(file), false));
+    }
+    if options.stderr {
+        layers.push(output_layer(options.format, io::stderr, true));
+    }
+    if layers.is_empty() {
+        return Ok(());
+    }
+
+    let filter = options.level.as_deref().unwrap_or(DEFAULT_LOG_FILTER);
+    let filter = EnvFilter::try_new(filter).map_err(io::Error::other)?;
+    let (filter, handle) = reload::Layer::new(filter);
+    tracing_subscriber::registry()
+        .with(filter)
+        .with(layers)
+        .try_init()
+        .map_err(io::Error::other)?;
+    let _ = LEVEL.set(LevelControl {
+        handle,
+        fixed: options.level.is_some(),
+    });
+    Ok(())
+}
+
+/// Layer writing formatted events, with passwords removed
+fn output_layer<W>(
+    format: LogFormat,
+    writer: W,
+    ansi: bool,
+) -> Box<dyn Layer<Filtered> + Send + Sync>
+where
+    W: for<'a> MakeWriter<'a> + Send + Sync + 'static,
+{
+    let layer = tracing_subscriber::fmt::layer()
+        .with_ansi(ansi)
+        .with_writer(RedactingWriter::new(writer));
+    match format {
+        LogFormat::Console => layer.boxed(),
+        LogFormat::Json => layer.json().boxed(),
+    }
+}
+
+/// Change the log filter while the server runs
+///
+/// Does nothing when no subscriber was installed, or when the filter was
+/// given on the command line.
+///
+/// # Errors
+///
+/// If the filter is invalid
+pub fn set_level(filter: &str) -> Result<(), String> {
+    let Some(control) = LEVEL.get().filter(|control| !control.fixed) else {
+        return Ok(());
+    };
+    let filter = EnvFilter::try_new(filter).map_err(|e| e.to_string())?;
+    control.handle.reload(filter).map_err(|e| e.to_string())
+}
+
+/// Log file rotated when it would grow past a size
+#[derive(Debug)]
+pub struct RotatingFile {
+    path: PathBuf,
+    file: File,
+    /// Bytes in the current file
+    size: u64,
+    max_bytes: u64,
+    max_backups: usize,
+}
+
+impl RotatingFile {
+    /// Open a log file, appending to it
+    ///
+    /// # Arguments
+    ///
+    /// * `path` - The log file; backups are written next to it
+    /// * `max_bytes` - Size of the file before it is rotated
+    /// * `max_backups` - Number of rotated files kept; 0 truncates the file
+    ///   instead
+    pub fn open(path: impl Into<PathBuf>, max_bytes: u64, max_backups: usize) -> io::Result<Self> {
+        let path = path.into();
+        if let Some(dir) = path.parent().filter(|dir| !dir.as_os_str().is_empty()) {
+            fs::create_dir_all(dir)?;
+        }
+        let file = OpenOptions::new().create(true).append(true).open(&path)?;
+        let size = file.metadata()?.len();
+
+        Ok(Self {
+            path,
+            file,
+            size,
+            max_bytes,
+            max_backups,
+        })
+    }
+
+    /// Path of the `n`th backup (`server.log.n`)
+    fn backup_path(&self, n: usize) -> PathBuf {
+        let mut path = self.path.clone().into_os_string();
+        path.push(format!(".{}", n));
+        path.into()
+    }
+
+    /// Shift the backups, move the file to the first one and start a new one
+    fn rotate(&mut self) -> io::Result<()> {
+        self.file.flush()?;
+        if self.max_backups > 0 {
+            remove_if_exists(&self.backup_path(self.max_backups))?;
+            for n in (1..self.max_backups).rev() {
+                let backup = self.backup_path(n);
+                if backup.exists() {
+                    fs::rename(&backup, self.backup_path(n + 1))?;
+                }
+            }
+            fs::rename(&self.path, self.backup_path(1))?;
+        } else {
+            remove_if_exists(&self.path)?;
+        }
+
+        self.file = OpenOptions::new()
+            .create(true)
+            .append(true)
+            .open(&self.path)?;
+        self.size = 0;
+        Ok(())
+    }
+}
+
+impl Write for RotatingFile {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        if self.size > 0 && self.size + buf.len() as u64 > self.max_bytes {
+            self.rotate()?;
+        }
+        let written = self.file.write(buf)?;
+        self.size += written as u64;
+        Ok(written)
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        self.file.flush()
+    }
+}
+
+fn remove_if_exists(path: &Path) -> io::Result<()> {
+    match fs::remove_file(path) {
+        Err(e) if e.kind() != io::ErrorKind::NotFound => Err(e),
+        _ => Ok(()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn temp_dir(name: &str) -> PathBuf {
+        let dir = std::env::temp_dir().join(format!(
+            "unified-sql-lsp-logging-{}-{}",
+            name,
+            std::process::id()
+        ));
+        let _ = fs::remove_dir_all(&dir);
+        dir
+    }
+
+    fn read(path: PathBuf) -> String {
+        fs::read_to_string(path).unwrap_or_default()
+    }
+
+    #[test]
+    fn test_file_rotates_at_max_size() {
+        let dir = temp_dir("rotate");
+        let path = dir.join("server.log");
+        let mut file = RotatingFile::open(&path, 10, 2).unwrap();
+
+        // Lines of 6 bytes: a second one would exceed the 10 bytes
+        file.write_all(b"first\n").unwrap();
+        assert_eq!(read(path.clone()), "first\n");
+        file.write_all(b"secnd\n").unwrap();
+        assert_eq!(read(path.clone()), "secnd\n");
+        assert_eq!(read(dir.join("server.log.1")), "first\n");
+
+        // The oldest backup is dropped
+        file.write_all(b"third\n").unwrap();
+        file.write_all(b"forth\n").unwrap();
+        assert_eq!(read(path.clone()), "forth\n");
+        assert_eq!(read(dir.join("server.log.1")), "third\n");
+        assert_eq!(read(dir.join("server.log.2")), "secnd\n");
+        assert!(!dir.join("server.log.3").exists());
+
+        // Reopening appends, and counts the existing content
+        drop(file);
+        let mut file = RotatingFile::open(&path, 10, 0).unwrap();
+        file.write_all(b"fifth\n").unwrap();
+        assert_eq!(read(path.clone()), "fifth\n");
+        assert_eq!(read(dir.join("server.log.1")), "third\n");
+
+        fs::remove_dir_all(&dir).unwrap();
+    }
+
+    #[test]
+    fn test_log_format_names() {
+        assert_eq!("json".parse(), Ok(LogFormat::Json));
+        assert_eq!("console".parse(), Ok(LogFormat::Console));
+        assert!("xml".parse::<LogFormat>().is_err());
+    }
+}
@@ -0,0 +1,1431 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Migrations
+//!
+//! This module applies the DDL of migration files on top of the database
+//! schema, so completion and schema diagnostics see the tables and columns
+//! of migrations that are not applied yet.
+//!
+//! ## Overview
+//!
+//! - Migration files are the workspace SQL files matching the `migrations`
+//!   glob (see [`discover_migrations`])
+//! - Their DDL is parsed into schema changes (see [`MigrationOverlay`]):
+//!   `CREATE TABLE`, `DROP TABLE`, `RENAME TABLE` and the column actions of
+//!   `ALTER TABLE` (`ADD`, `DROP`, `MODIFY`, `CHANGE`, `RENAME`)
+//! - Files are applied in file name order, so numbered or timestamped
+//!   migrations run in sequence
+//! - [`MigrationCatalog`] serves the schema with the changes applied
+//!
+//! The migration directory usually holds applied migrations too, so
+//! re-creating an existing table or column replaces it silently. Changes to
+//! a table or column that does not exist (e.g. dropping a nonexistent table)
+//! are reported as [`MigrationConflict`]s on the migration file.
+
+use async_trait::async_trait;
+use std::collections::HashSet;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+use tower_lsp::lsp_types::{DiagnosticSeverity, Position, Range, Url};
+use tracing::debug;
+use unified_sql_lsp_catalog::{
+    Catalog, CatalogError, CatalogResult, ColumnMetadata, DataType, FunctionMetadata, TableMetadata,
+};
+
+use crate::diagnostic::{DiagnosticCode, SqlDiagnostic};
+use crate::lint::glob_match_anchored;
+use crate::workspace_index::{WorkspaceIndexConfig, discover_sql_files, relative_path};
+
+/// Diagnostic source of migration conflicts
+pub const MIGRATION_DIAGNOSTIC_SOURCE: &str = "sql-migrations";
+
+/// Keywords starting a constraint rather than a column definition
+const CONSTRAINT_KEYWORDS: [&str; 11] = [
+    "CONSTRAINT",
+    "PRIMARY",
+    "FOREIGN",
+    "UNIQUE",
+    "CHECK",
+    "KEY",
+    "INDEX",
+    "FULLTEXT",
+    "SPATIAL",
+    "EXCLUDE",
+    "LIKE",
+];
+
+/// Schema change made by a DDL statement
+#[derive(Debug, Clone, PartialEq)]
+pub enum SchemaChange {
+    CreateTable {
+        table: String,
+        columns: Vec<ColumnMetadata>,
+        if_not_exists: bool,
+    },
+    DropTable {
+        table: String,
+        if_exists: bool,
+    },
+    RenameTable {
+        table: String,
+        to: String,
+    },
+    AddColumn {
+        table: String,
+        column: ColumnMetadata,
+    },
+    DropColumn {
+        table: String,
+        column: String,
+        if_exists: bool,
+    },
+    /// `MODIFY` or `CHANGE` of a column, possibly renaming it
+    ReplaceColumn {
+        table: String,
+        column: String,
+        definition: ColumnMetadata,
+    },
+    RenameColumn {
+        table: String,
+        column: String,
+        to: String,
+    },
+}
+
+impl SchemaChange {
+    /// Table the change applies to
+    pub fn table(&self) -> &str {
+        match self {
+            SchemaChange::CreateTable { table, .. }
+            | SchemaChange::DropTable { table, .. }
+            | SchemaChange::RenameTable { table, .. }
+            | SchemaChange::AddColumn { table, .. }
+            | SchemaChange::DropColumn { table, .. }
+            | SchemaChange::ReplaceColumn { table, .. }
+            | SchemaChange::RenameColumn { table, .. } => table,
+        }
+    }
+}
+
+/// A schema change and the range of the object it names
+#[derive(Debug, Clone, PartialEq)]
+pub struct MigrationStatement {
+    pub change: SchemaChange,
+    pub range: Range,
+}
+
+/// The schema changes of a migration file
+#[derive(Debug, Clone, PartialEq)]
+pub struct MigrationFile {
+    pub uri: Url,
+    pub statements: Vec<MigrationStatement>,
+}
+
+impl MigrationFile {
+    /// Parse the DDL statements of a migration file
+    ///
+    /// Statements other than table DDL are ignored.
+    pub fn parse(uri: Url, source: &str) -> Self {
+        let tokens = tokenize(source);
+        let statements = tokens
+            .split(|token| token.kind == TokenKind::Symbol(';'))
+            .flat_map(parse_statement)
+            .collect();
+
+        Self { uri, statements }
+    }
+
+    /// File name, the key ordering migrations
+    fn file_name(&self) -> &str {
+        self.uri
+            .path_segments()
+            .and_then(|mut segments| segments.next_back())
+            .unwrap_or_default()
+    }
+}
+
+/// Parse the schema changes of SQL statements
+///
+/// Statements other than table DDL are ignored.
+pub fn parse_schema_changes(source: &str) -> Vec<SchemaChange> {
+    tokenize(source)
+        .split(|token| token.kind == TokenKind::Symbol(';'))
+        .flat_map(parse_statement)
+        .map(|statement| statement.change)
+        .collect()
+}
+
+/// A schema change that cannot be applied
+#[derive(Debug, Clone, PartialEq)]
+pub struct MigrationConflict {
+    pub uri: Url,
+    pub range: Range,
+    pub message: String,
+}
+
+impl MigrationConflict {
+    /// Diagnostic reported on the migration file
+    pub fn to_diagnostic(&self) -> SqlDiagnostic {
+        SqlDiagnostic::new(self.message.clone(), DiagnosticSeverity::ERROR, self.range)
+            .with_code(DiagnosticCode::MigrationConflict)
+            .with_source(MIGRATION_DIAGNOSTIC_SOURCE)
+    }
+}
+
+/// Schema changes of the migration files, in application order
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct MigrationOverlay {
+    files: Vec<MigrationFile>,
+}
+
+impl MigrationOverlay {
+    /// Create an overlay from parsed migration files
+    ///
+    /// Files are sorted by file name, then by URI for files of the same name.
+    pub fn new(mut files: Vec<MigrationFile>) -> Self {
+        files.sort_by(|a, b| {
+            a.file_name()
+                .cmp(b.file_name())
+                .then_with(|| a.uri.as_str().cmp(b.uri.as_str()))
+        });
+        Self { files }
+    }
+
+    /// Read and parse migration files from disk
+    ///
+    /// Unreadable files are skipped.
+    pub fn load(paths: &[PathBuf]) -> Self {
+        let files = paths
+            .iter()
+            .filter_map(|path| {
+                let uri = Url::from_file_path(path).ok()?;
+                match std::fs::read_to_string(path) {
+                    Ok(source) => Some(MigrationFile::parse(uri, &source)),
+                    Err(e) => {
+                        debug!("Skipping migration {}: {}", path.display(), e);
+                        None
+                    }
+                }
+            })
+            .collect();
+        Self::new(files)
+    }
+
+    /// Check whether the overlay changes nothing
+    pub fn is_empty(&self) -> bool {
+        self.files.iter().all(|file| file.statements.is_empty())
+    }
+
+    /// Migration files, in application order
+    pub fn files(&self) -> &[MigrationFile] {
+        &self.files
+    }
+
+    /// Lowercase names of the tables the overlay changes, before and after
+    /// renames
+    fn touched_tables(&self) -> HashSet<String> {
+        let mut touched = HashSet::new();
+        for statement in self.files.iter().flat_map(|file| &file.statements) {
+            touched.insert(table_key(statement.change.table()));
+            if let SchemaChange::RenameTable { to, .. } = &statement.change {
+                touched.insert(table_key(to));
+            }
+        }
+        touched
+    }
+
+    /// Apply the schema changes to the tables of a database
+    ///
+    /// # Arguments
+    ///
+    /// * `tables` - Tables of the database, with the columns of the tables
+    ///   the overlay changes
+    ///
+    /// # Returns
+    ///
+    /// The tables after all migrations, and the changes that could not be
+    /// applied
+    pub fn apply(
+        &self,
+        mut tables: Vec<TableMetadata>,
+    ) -> (Vec<TableMetadata>, Vec<MigrationConflict>) {
+        // Unqualified new tables go to the schema of the database
+        let default_schema = tables
+            .first()
+            .map(|table| table.schema.clone())
+            .unwrap_or_default();
+        let mut conflicts = Vec::new();
+
+        for file in &self.files {
+            for statement in &file.statements {
+                let conflict = |message: String| MigrationConflict {
+                    uri: file.uri.clone(),
+                    range: statement.range,
+                    message,
+                };
+                let change = &statement.change;
+                let position = find_table(&tables, change.table());
+
+                match (change, position) {
+                    (
+                        SchemaChange::CreateTable {
+                            table,
+                            columns,
+                            if_not_exists,
+                        },
+                        position,
+                    ) => {
+                        let (schema, name) = split_reference(table);
+                        let created = TableMetadata::new(
+                            name,
+                            schema.map_or(default_schema.clone(), str::to_string),
+                        )
+                        .with_columns(columns.clone());
+                        match position {
+                            Some(_) if *if_not_exists => {}
+                            Some(position) => tables[position] = created,
+                            None => tables.push(created),
+                        }
+                    }
+                    (
+                        SchemaChange::DropTable {
+                            if_exists: true, ..
+                        },
+                        None,
+                    ) => {}
+                    (_, None) => {
+                        conflicts.push(conflict(format!(
+                            "Table '{}' does not exist",
+                            change.table()
+                        )));
+                    }
+                    (SchemaChange::DropTable { .. }, Some(position)) => {
+                        tables.remove(position);
+                    }
+                    (SchemaChange::RenameTable { to, .. }, Some(position)) => {
+                        let (_, name) = split_reference(to);
+                        tables[position].name = name.to_string();
+                    }
+                    (SchemaChange::AddColumn { column, .. }, Some(position)) => {
+                        let columns = &mut tables[position].columns;
+                        match find_column(columns, &column.name) {
+                            Some(existing) => columns[existing] = column.clone(),
+                            None => columns.push(column.clone()),
+                        }
+                    }
+                    (
+                        SchemaChange::DropColumn {
+                            table,
+                            column,
+                            if_exists,
+                        },
+                        Some(position),
+                    ) => {
+                        let columns = &mut tables[position].columns;
+                        match find_column(columns, column) {
+                            Some(existing) => {
+                                columns.remove(existing);
+                            }
+                            None if *if_exists => {}
+                            None => conflicts.push(conflict(missing_column(column, table))),
+                        }
+                    }
+                    (
+                        SchemaChange::ReplaceColumn {
+                            table,
+                            column,
+                            definition,
+                        },
+                        Some(position),
+                    ) => {
+                        let columns = &mut tables[position].columns;
+                        match find_column(columns, column) {
+                            Some(existing) => columns[existing] = definition.clone(),
+                            None => conflicts.push(conflict(missing_column(column, table))),
+                        }
+                    }
+                    (SchemaChange::RenameColumn { table, column, to }, Some(position)) => {
+                        let columns = &mut tables[position].columns;
+                        match find_column(columns, column) {
+                            Some(existing) => columns[existing].name = to.clone(),
+                            None => conflicts.push(conflict(missing_column(column, table))),
+                        }
+                    }
+                }
+            }
+        }
+
+        (tables, conflicts)
+    }
+}
+
+/// Catalog serving a database schema with the migrations applied
+///
+/// Tables the migrations do not change are served by the base catalog.
+pub struct MigrationCatalog {
+    base: Arc<dyn Catalog>,
+    overlay: Arc<MigrationOverlay>,
+}
+
+impl MigrationCatalog {
+    /// Create a catalog applying an overlay to a base catalog
+    pub fn new(base: Arc<dyn Catalog>, overlay: Arc<MigrationOverlay>) -> Self {
+        Self { base, overlay }
+    }
+
+    /// Apply the migrations to the tables of the base catalog
+    ///
+    /// # Returns
+    ///
+    /// The tables after all migrations, and the changes that could not be
+    /// applied
+    ///
+    /// # Errors
+    ///
+    /// Returns the error of the base catalog if its tables cannot be listed.
+    pub async fn resolve(&self) -> CatalogResult<(Vec<TableMetadata>, Vec<MigrationConflict>)> {
+        let mut tables = self.base.list_tables().await?;
+
+        // Catalogs may list tables without their columns
+        let touched = self.overlay.touched_tables();
+        for table in &mut tables {
+            if table.columns.is_empty() && touched.contains(&table.name.to_lowercase()) {
+                match self.base.get_columns(&table.qualified_name()).await {
+                    Ok(columns) => table.columns = columns,
+                    Err(e) => debug!("Columns of {} unavailable: {}", table.name, e),
+                }
+            }
+        }
+
+        Ok(self.overlay.apply(tables))
+    }
+}
+
+#[async_trait]
+impl Catalog for MigrationCatalog {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        Ok(self.resolve().await?.0)
+    }
+
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        if !self.overlay.touched_tables().contains(&table_key(table)) {
+            return self.base.get_columns(table).await;
+        }
+
+        let (tables, _) = self.resolve().await?;
+        match find_table(&tables, table) {
+            Some(position) => Ok(tables[position].columns.clone()),
+            None => {
+                let (schema, name) = split_reference(table);
+                Err(CatalogError::TableNotFound(
+                    name.to_string(),
+                    schema.unwrap_or_default().to_string(),
+                ))
+            }
+        }
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        self.base.list_functions().await
+    }
+}
+
+/// Find the migration files of workspace folders
+///
+/// # Arguments
+///
+/// * `roots` - Workspace folders
+/// * `pattern` - Glob of migration files, relative to a workspace folder
+///
+/// # Returns
+///
+/// The SQL files matching the pattern that the workspace index would visit
+pub fn discover_migrations(roots: &[PathBuf], pattern: &str) -> Vec<PathBuf> {
+    let limits = WorkspaceIndexConfig::default();
+    roots
+        .iter()
+        .flat_map(|root| {
+            discover_sql_files(root, &limits)
+                .into_iter()
+                .filter(move |path| is_migration(root, path, pattern))
+        })
+        .collect()
+}
+
+/// Check whether a file of a workspace folder matches the migrations glob
+pub fn is_migration(root: &Path, path: &Path, pattern: &str) -> bool {
+    relative_path(root, path).is_some_and(|relative| glob_match_anchored(pattern, &relative))
+}
+
+/// Split `schema.table` into its optional schema and its name
+fn split_reference(reference: &str) -> (Option<&str>, &str) {
+    match reference.rsplit_once('.') {
+        Some((schema, name)) => {
+            // Keep the schema of `db.schema.table`
+            let schema = schema.rsplit('.').next().unwrap_or(schema);
+            (Some(schema), name)
+        }
+        None => (None, reference),
+    }
+}
+
+/// Lowercase name of a table reference
+fn table_key(reference: &str) -> String {
+    split_reference(reference).1.to_lowercase()
+}
+
+/// Find a table by reference, ignoring ASCII case
+fn find_table(tables: &[TableMetadata], reference: &str) -> Option<usize> {
+    let (schema, name) = split_reference(reference);
+    tables.iter().position(|table| {
+        table.name.eq_ignore_ascii_case(name)
+            && schema.is_none_or(|schema| table.schema.eq_ignore_ascii_case(schema))
+    })
+}
+
+/// Find a column by name, ignoring ASCII case
+fn find_column(columns: &[ColumnMetadata], name: &str) -> Option<usize> {
+    columns
+        .iter()
+        .position(|column| column.name.eq_ignore_ascii_case(name))
+}
+
+fn missing_column(column: &str, table: &str) -> String {
+    format!("Column '{}' does not exist in table '{}'", column, table)
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub(crate) enum TokenKind {
+    /// Keyword, identifier or number
+    Word,
+    /// Quoted identifier
+    Quoted,
+    /// String literal
+    Literal,
+    Symbol(char),
+}
+
+#[derive(Debug, Clone)]
+pub(crate) struct Token {
+    pub(crate) kind: TokenKind,
+    /// Text without quotes
+    pub(crate) text: String,
+    pub(crate) range: Range,
+}
+
+impl Token {
+    pub(crate) fn is_keyword(&self, keyword: &str) -> bool {
+        self.kind == TokenKind::Word && self.text.eq_ignore_ascii_case(keyword)
+    }
+
+    pub(crate) fn is_identifier(&self) -> bool {
+        matches!(self.kind, TokenKind::Word | TokenKind::Quoted)
+    }
+}
+
+/// Position-tracking cursor over the characters of a file
+struct Cursor {
+    chars: Vec<char>,
+    index: usize,
+    position: Position,
+}
+
+impl Cursor {
+    fn peek(&self, offset: usize) -> Option<char> {
+        self.chars.get(self.index + offset).copied()
+    }
+
+    fn bump(&mut self) -> Option<char> {
+        let c = self.peek(0)?;
+        self.index += 1;
+        if c == '\n' {
+            self.position = Position::new(self.position.line + 1, 0);
+        } else {
+            self.position.character += c.len_utf16() as u32;
+        }
+        Some(c)
+    }
+
+    fn take_while(&mut self, predicate: impl Fn(char) -> bool) -> String {
+        let mut text = String::new();
+        while let Some(c) = self.peek(0).filter(|&c| predicate(c)) {
+            text.push(c);
+            self.bump();
+        }
+        text
+    }
+
+    /// Read a quoted text up to its closing quote; a doubled closing quote
+    /// is an escaped quote
+    fn quoted(&mut self, close: char) -> String {
+        self.bump();
+        let mut text = String::new();
+        while let Some(c) = self.bump() {
+            if c == close {
+                if self.peek(0) != Some(close) {
+                    break;
+                }
+                self.bump();
+            }
+            text.push(c);
+        }
+        text
+    }
+
+    /// Skip a PostgreSQL dollar-quoted string (`$$...$$`, `$tag$...$tag$`)
+    fn dollar_quoted(&mut self) -> Option<String> {
+        let tag_len = (1..)
+            .map_while(|offset| self.peek(offset))
+            .take_while(|&c| is_word_char(c) && c != '$' && !c.is_ascii_digit())
+            .count();
+        if self.peek(tag_len + 1) != Some('$') {
+            return None;
+        }
+        let tag: Vec<char> = self.chars[self.index..self.index + tag_len + 2].to_vec();
+        for _ in 0..tag.len() {
+            self.bump();
+        }
+
+        let mut text = String::new();
+        while self.peek(0).is_some() {
+            if self.chars[self.index..].starts_with(&tag) {
+                for _ in 0..tag.len() {
+                    self.bump();
+                }
+                break;
+            }
+            text.extend(self.bump());
+        }
+        Some(text)
+    }
+}
+
+fn is_word_char(c: char) -> bool {
+    c.is_alphanumeric() || c == '_' || c == '$'
+}
+
+/// Split SQL into tokens, skipping whitespace and comments
+pub(crate) fn tokenize(source: &str) -> Vec<Token> {
+    let mut cursor = Cursor {
+        chars: source.chars().collect(),
+        index: 0,
+        position: Position::new(0, 0),
+    };
+    let mut tokens = Vec::new();
+
+    while let Some(c) = cursor.peek(0) {
+        let start = cursor.position;
+        let (kind, text) = match c {
+            c if c.is_whitespace() => {
+                cursor.bump();
+                continue;
+            }
+            '-' if cursor.peek(1) == Some('-') => {
+                cursor.take_while(|c| c != '\n');
+                continue;
+            }
+            '#' => {
+                cursor.take_while(|c| c != '\n');
+                continue;
+            }
+            '/' if cursor.peek(1) == Some('*') => {
+                cursor.bump();
+                cursor.bump();
+                while cursor.peek(0).is_some()
+                    && !(cursor.peek(0) == Some('*') && cursor.peek(1) == Some('/'))
+                {
+                    cursor.bump();
+                }
+                cursor.bump();
+                cursor.bump();
+                continue;
+            }
+            '\'' => (TokenKind::Literal, cursor.quoted('\'')),
+            '"' | '`' => (TokenKind::Quoted, cursor.quoted(c)),
+            '$' => match cursor.dollar_quoted() {
+                Some(text) => (TokenKind::Literal, text),
+                None => (TokenKind::Word, cursor.take_while(is_word_char)),
+            },
+            c if is_word_char(c) => (TokenKind::Word, cursor.take_while(is_word_char)),
+            c => {
+                cursor.bump();
+                (TokenKind::Symbol(c), c.to_string())
+            }
+        };
+        tokens.push(Token {
+            kind,
+            text,
+            range: Range::new(start, cursor.position),
+        });
+    }
+
+    tokens
+}
+
+/// Recursive-descent reader over the tokens of one statement
+struct Parser<'a> {
+    tokens: &'a [Token],
+    position: usize,
+}
+
+impl<'a> Parser<'a> {
+    fn new(tokens: &'a [Token]) -> Self {
+        Self {
+            tokens,
+            position: 0,
+        }
+    }
+
+    fn peek(&self) -> Option<&'a Token> {
+        self.tokens.get(self.position)
+    }
+
+    fn next(&mut self) -> Option<&'a Token> {
+        let token = self.peek()?;
+        self.position += 1;
+        Some(token)
+    }
+
+    fn at_keyword(&self, keyword: &str) -> bool {
+        self.peek().is_some_and(|token| token.is_keyword(keyword))
+    }
+
+    fn eat_keyword(&mut self, keyword: &str) -> bool {
+        let matched = self.at_keyword(keyword);
+        if matched {
+            self.position += 1;
+        }
+        matched
+    }
+
+    /// Consume a sequence of keywords, or nothing if it does not match
+    fn eat_keywords(&mut self, keywords: &[&str]) -> bool {
+        let matched = keywords.iter().enumerate().all(|(offset, keyword)| {
+            self.tokens
+                .get(self.position + offset)
+                .is_some_and(|token| token.is_keyword(keyword))
+        });
+        if matched {
+            self.position += keywords.len();
+        }
+        matched
+    }
+
+    fn eat_symbol(&mut self, symbol: char) -> bool {
+        let matched = self
+            .peek()
+            .is_some_and(|token| token.kind == TokenKind::Symbol(symbol));
+        if matched {
+            self.position += 1;
+        }
+        matched
+    }
+
+    fn identifier(&mut self) -> Option<&'a Token> {
+        self.peek().filter(|token| token.is_identifier())?;
+        self.next()
+    }
+
+    /// Read a possibly qualified name (`table`, `schema.table`)
+    fn name(&mut self) -> Option<(String, Range)> {
+        let first = self.identifier()?;
+        let mut name = first.text.clone();
+        let mut range = first.range;
+        while self.eat_symbol('.') {
+            let part = self.identifier()?;
+            name.push('.');
+            name.push_str(&part.text);
+            range.end = part.range.end;
+        }
+        Some((name, range))
+    }
+
+    /// Read a parenthesized list, split at its top-level commas
+    ///
+    /// The opening parenthesis must already be consumed.
+    fn list(&mut self) -> Vec<&'a [Token]> {
+        let tokens = self.tokens;
+        let mut items = Vec::new();
+        let mut depth = 0;
+        let mut start = self.position;
+        while let Some(token) = self.next() {
+            match token.kind {
+                TokenKind::Symbol('(') => depth += 1,
+                TokenKind::Symbol(')') if depth == 0 => {
+                    items.push(&tokens[start..self.position - 1]);
+                    break;
+                }
+                TokenKind::Symbol(')') => depth -= 1,
+                TokenKind::Symbol(',') if depth == 0 => {
+                    items.push(&tokens[start..self.position - 1]);
+                    start = self.position;
+                }
+                _ => {}
+            }
+        }
+        items.retain(|item| !item.is_empty());
+        items
+    }
+
+    /// Read the rest of the statement, split at its top-level commas
+    fn rest(&mut self) -> Vec<&'a [Token]> {
+        let tokens = &self.tokens[self.position..];
+        self.position = self.tokens.len();
+
+        let mut items = Vec::new();
+        let mut depth = 0;
+        let mut start = 0;
+        for (index, token) in tokens.iter().enumerate() {
+            match token.kind {
+                TokenKind::Symbol('(') => depth += 1,
+                TokenKind::Symbol(')') => depth -= 1,
+                TokenKind::Symbol(',') if depth == 0 => {
+                    items.push(&tokens[start..index]);
+                    start = index + 1;
+                }
+                _ => {}
+            }
+        }
+        items.push(&tokens[start..]);
+        items.retain(|item| !item.is_empty());
+        items
+    }
+}
+
+/// Parse the schema changes of one statement
+fn parse_statement(tokens: &[Token]) -> Vec<MigrationStatement> {
+    let mut parser = Parser::new(tokens);
+    if parser.eat_keyword("CREATE") {
+        parse_create_table(&mut parser).into_iter().collect()
+    } else if parser.eat_keyword("DROP") {
+        parse_drop_table(&mut parser)
+    } else if parser.eat_keyword("ALTER") {
+        parse_alter_table(&mut parser)
+    } else if parser.eat_keywords(&["RENAME", "TABLE"]) {
+        parse_rename_tables(&mut parser)
+    } else {
+        Vec::new()
+    }
+}
+
+/// `CREATE [TEMPORARY] TABLE [IF NOT EXISTS] name (elements)` or
+/// `CREATE [TEMPORARY] TABLE [IF NOT EXISTS] name AS SELECT ...`
+///
+/// `CREATE TABLE ... LIKE` is ignored.
+fn parse_create_table(parser: &mut Parser) -> Option<MigrationStatement> {
+    for modifier in ["GLOBAL", "LOCAL", "TEMPORARY", "TEMP", "UNLOGGED"] {
+        parser.eat_keyword(modifier);
+    }
+    if !parser.eat_keyword("TABLE") {
+        return None;
+    }
+    let if_not_exists = parser.eat_keywords(&["IF", "NOT", "EXISTS"]);
+    let (table, range) = parser.name()?;
+    if parser.eat_keyword("AS") {
+        return Some(MigrationStatement {
+            change: SchemaChange::CreateTable {
+                table,
+                columns: select_list_columns(parser),
+                if_not_exists,
+            },
+            range,
+        });
+    }
+    if !parser.eat_symbol('(') {
+        return None;
+    }
+
+    let mut columns = Vec::new();
+    let mut primary_key = Vec::new();
+    for element in parser.list() {
+        match column_definition(element) {
+            Some(column) => columns.push(column),
+            None => primary_key.extend(primary_key_columns(element)),
+        }
+    }
+    for column in &mut columns {
+        if primary_key
+            .iter()
+            .any(|name| name.eq_ignore_ascii_case(&column.name))
+        {
+            column.is_primary_key = true;
+            column.nullable = false;
+        }
+    }
+
+    Some(MigrationStatement {
+        change: SchemaChange::CreateTable {
+            table,
+            columns,
+            if_not_exists,
+        },
+        range,
+    })
+}
+
+/// Columns created by `AS [(] SELECT [DISTINCT] items FROM ...`
+///
+/// Columns are named after the select list items; their types are unknown.
+/// Items without a name (`*`, expressions without an alias) are skipped.
+fn select_list_columns(parser: &mut Parser) -> Vec<ColumnMetadata> {
+    parser.eat_symbol('(');
+    if !parser.eat_keyword("SELECT") {
+        return Vec::new();
+    }
+    for modifier in ["DISTINCT", "ALL"] {
+        parser.eat_keyword(modifier);
+    }
+
+    let mut columns = Vec::new();
+    for item in parser.rest() {
+        // The last item runs into the FROM clause
+        let mut depth = 0;
+        let end = item.iter().position(|token| {
+            match token.kind {
+                TokenKind::Symbol('(') => depth += 1,
+                TokenKind::Symbol(')') => depth -= 1,
+                _ => {}
+            }
+            depth == 0
+                && SELECT_LIST_END
+                    .iter()
+                    .any(|keyword| token.is_keyword(keyword))
+        });
+        let name = select_item_name(&item[..end.unwrap_or(item.len())]);
+        columns.extend(name.map(|name| ColumnMetadata::new(name, unknown_type())));
+        if end.is_some() {
+            break;
+        }
+    }
+    columns
+}
+
+/// Keywords ending a select list
+const SELECT_LIST_END: [&str; 9] = [
+    "FROM", "INTO", "WHERE", "GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "UNION",
+];
+
+/// Output name of a select list item (`col`, `t.col`, `expr AS name`,
+/// `expr name`)
+fn select_item_name(item: &[Token]) -> Option<String> {
+    let last = item.last().filter(|token| {
+        token.is_identifier() && !token.text.starts_with(|c: char| c.is_ascii_digit())
+    })?;
+    let named = match item.len() {
+        1 => true,
+        len => {
+            let previous = &item[len - 2];
+            previous.is_keyword("AS")
+                // `t.col`
+                || (len == 3 && previous.kind == TokenKind::Symbol('.'))
+                // `col alias`, `t.col alias`, `f(x) alias`
+                || (len == 2 && item[0].is_identifier())
+                || (len == 4 && item[1].kind == TokenKind::Symbol('.'))
+                || previous.kind == TokenKind::Symbol(')')
+        }
+    };
+    named.then(|| last.text.clone())
+}
+
+/// Type of a column whose type is not known
+pub(crate) fn unknown_type() -> DataType {
+    DataType::Other("unknown".to_string())
+}
+
+/// `DROP TABLE [IF EXISTS] name [, name ...]`
+fn parse_drop_table(parser: &mut Parser) -> Vec<MigrationStatement> {
+    parser.eat_keyword("TEMPORARY");
+    if !parser.eat_keyword("TABLE") {
+        return Vec::new();
+    }
+    let if_exists = parser.eat_keywords(&["IF", "EXISTS"]);
+
+    let mut statements = Vec::new();
+    while let Some((table, range)) = parser.name() {
+        statements.push(MigrationStatement {
+            change: SchemaChange::DropTable { table, if_exists },
+            range,
+        });
+        if !parser.eat_symbol(',') {
+            break;
+        }
+    }
+    statements
+}
+
+/// `RENAME TABLE a TO b [, c TO d ...]`
+fn parse_rename_tables(parser: &mut Parser) -> Vec<MigrationStatement> {
+    let mut statements = Vec::new();
+    for item in parser.rest() {
+        let mut item = Parser::new(item);
+        if let Some((table, range)) = item.name()
+            && item.eat_keyword("TO")
+            && let Some((to, _)) = item.name()
+        {
+            statements.push(MigrationStatement {
+                change: SchemaChange::RenameTable { table, to },
+                range,
+            });
+        }
+    }
+    statements
+}
+
+/// `ALTER TABLE [IF EXISTS] [ONLY] name action [, action ...]`
+fn parse_alter_table(parser: &mut Parser) -> Vec<MigrationStatement> {
+    if !parser.eat_keyword("TABLE") {
+        return Vec::new();
+    }
+    parser.eat_keywords(&["IF", "EXISTS"]);
+    parser.eat_keyword("ONLY");
+    let Some((table, table_range)) = parser.name() else {
+        return Vec::new();
+    };
+
+    parser
+        .rest()
+        .into_iter()
+        .filter_map(|action| parse_alter_action(&table, table_range, action))
+        .collect()
+}
+
+/// Parse one action of `ALTER TABLE`; constraint and index actions are
+/// ignored
+fn parse_alter_action(
+    table: &str,
+    table_range: Range,
+    tokens: &[Token],
+) -> Option<MigrationStatement> {
+    let mut parser = Parser::new(tokens);
+    let table = table.to_string();
+
+    if parser.eat_keyword("ADD") {
+        parser.eat_keyword("COLUMN");
+        parser.eat_keywords(&["IF", "NOT", "EXISTS"]);
+        let range = parser.peek()?.range;
+        let column = column_definition(&tokens[parser.position..])?;
+        return Some(MigrationStatement {
+            change: SchemaChange::AddColumn { table, column },
+            range,
+        });
+    }
+
+    if parser.eat_keyword("DROP") {
+        let explicit = parser.eat_keyword("COLUMN");
+        if !explicit && parser.peek().is_some_and(is_constraint_keyword) {
+            return None;
+        }
+        let if_exists = parser.eat_keywords(&["IF", "EXISTS"]);
+        let column = parser.identifier()?;
+        return Some(MigrationStatement {
+            change: SchemaChange::DropColumn {
+                table,
+                column: column.text.clone(),
+                if_exists,
+            },
+            range: column.range,
+        });
+    }
+
+    if parser.eat_keyword("MODIFY") {
+        parser.eat_keyword("COLUMN");
+        let range = parser.peek()?.range;
+        let definition = column_definition(&tokens[parser.position..])?;
+        return Some(MigrationStatement {
+            change: SchemaChange::ReplaceColumn {
+                table,
+                column: definition.name.clone(),
+                definition,
+            },
+            range,
+        });
+    }
+
+    if parser.eat_keyword("CHANGE") {
+        parser.eat_keyword("COLUMN");
+        let column = parser.identifier()?;
+        let definition = column_definition(&tokens[parser.position..])?;
+        return Some(MigrationStatement {
+            change: SchemaChange::ReplaceColumn {
+                table,
+                column: column.text.clone(),
+                definition,
+            },
+            range: column.range,
+        });
+    }
+
+    if parser.eat_keyword("RENAME") {
+        if parser.eat_keyword("TO") || parser.eat_keyword("AS") {
+            let (to, _) = parser.name()?;
+            return Some(MigrationStatement {
+                change: SchemaChange::RenameTable { table, to },
+                range: table_range,
+            });
+        }
+        parser.eat_keyword("COLUMN");
+        let column = parser.identifier()?;
+        if !parser.eat_keyword("TO") {
+            return None;
+        }
+        let to = parser.identifier()?;
+        return Some(MigrationStatement {
+            change: SchemaChange::RenameColumn {
+                table,
+                column: column.text.clone(),
+                to: to.text.clone(),
+            },
+            range: column.range,
+        });
+    }
+
+    None
+}
+
+fn is_constraint_keyword(token: &Token) -> bool {
+    CONSTRAINT_KEYWORDS
+        .iter()
+        .any(|keyword| token.is_keyword(keyword))
+}
+
+/// Parse a column definition (`name type [constraints]`)
+///
+/// # Returns
+///
+/// `None` if the tokens define a table constraint
+fn column_definition(tokens: &[Token]) -> Option<ColumnMetadata> {
+    let mut parser = Parser::new(tokens);
+    if parser.peek().is_some_and(is_constraint_keyword) {
+        return None;
+    }
+    let name = parser.identifier()?.text.clone();
+    let mut column = ColumnMetadata::new(name, data_type(&mut parser)?).with_nullable(true);
+
+    while let Some(token) = parser.next() {
+        if token.is_keyword("NOT") && parser.eat_keyword("NULL") {
+            column.nullable = false;
+        } else if token.is_keyword("NULL") {
+            column.nullable = true;
+        } else if token.is_keyword("PRIMARY") && parser.eat_keyword("KEY") {
+            column.is_primary_key = true;
+            column.nullable = false;
+        } else if token.is_keyword("DEFAULT")
+            && let Some(value) = parser.next()
+        {
+            column.default_value = Some(match value.kind {
+                TokenKind::Literal => format!("'{}'", value.text),
+                _ => value.text.clone(),
+            });
+        } else if token.is_keyword("COMMENT")
+            && let Some(comment) = parser.next()
+        {
+            column.comment = Some(comment.text.clone());
+        } else if token.is_keyword("REFERENCES")
+            && let Some((table, _)) = parser.name()
+        {
+            let referenced = match parser.eat_symbol('(') {
+                true => parser.identifier().map(|c| c.text.clone()),
+                false => None,
+            };
+            column = column.with_foreign_key(table, referenced.unwrap_or_default());
+        }
+    }
+
+    Some(column)
+}
+
+/// Column names of a `[CONSTRAINT name] PRIMARY KEY (columns)` element
+fn primary_key_columns(tokens: &[Token]) -> Vec<String> {
+    let mut parser = Parser::new(tokens);
+    if parser.eat_keyword("CONSTRAINT") {
+        parser.identifier();
+    }
+    if !parser.eat_keywords(&["PRIMARY", "KEY"]) || !parser.eat_symbol('(') {
+        return Vec::new();
+    }
+    parser
+        .list()
+        .into_iter()
+        .filter_map(|column| column.first())
+        .map(|column| column.text.clone())
+        .collect()
+}
+
+/// Parse a column type and its length (`varchar(255)`, `character varying`,
+/// `int[]`)
+fn data_type(parser: &mut Parser) -> Option<DataType> {
+    let name = parser.next().filter(|t| t.kind == TokenKind::Word)?;
+    let mut name = name.text.to_lowercase();
+    if matches!(name.as_str(), "character" | "char") && parser.eat_keyword("VARYING") {
+        name = "varchar".to_string();
+    }
+
+    let mut length = None;
+    if parser.eat_symbol('(') {
+        length = parser
+            .list()
+            .first()
+            .and_then(|first| first.first())
+            .and_then(|token| token.text.parse::<usize>().ok());
+    }
+
+    let data_type = match name.as_str() {
+        "int" | "integer" | "int4" | "mediumint" | "serial" => DataType::Integer,
+        "bigint" | "int8" | "bigserial" => DataType::BigInt,
+        "smallint" | "int2" | "smallserial" => DataType::SmallInt,
+        // MySQL uses TINYINT(1) for BOOLEAN
+        "tinyint" if length == Some(1) => DataType::Boolean,
+        "tinyint" => DataType::TinyInt,
+        "decimal" | "numeric" => DataType::Decimal,
+        "float" | "real" | "float4" => DataType::Float,
+        "double" | "float8" => DataType::Double,
+        "varchar" => DataType::Varchar(length),
+        "char" | "character" => DataType::Char(length),
+        "text" | "tinytext" | "mediumtext" | "longtext" => DataType::Text,
+        "binary" => DataType::Binary,
+        "varbinary" => DataType::VarBinary(length),
+        "blob" | "tinyblob" | "mediumblob" | "longblob" | "bytea" => DataType::Blob,
+        "date" => DataType::Date,
+        "time" => DataType::Time,
+        "datetime" => DataType::DateTime,
+        "timestamp" | "timestamptz" => DataType::Timestamp,
+        "bool" | "boolean" => DataType::Boolean,
+        "json" | "jsonb" => DataType::Json,
+        "uuid" => DataType::Uuid,
+        other => DataType::Other(other.to_string()),
+    };
+
+    if parser.eat_symbol('[') && parser.eat_symbol(']') {
+        return Some(DataType::Array(Box::new(data_type)));
+    }
+    Some(data_type)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{SchemaSnapshot, SnapshotCatalog};
+
+    fn uri(name: &str) -> Url {
+        Url::parse(&format!("file:///project/migrations/{}", name)).unwrap()
+    }
+
+    fn changes(source: &str) -> Vec<SchemaChange> {
+        MigrationFile::parse(uri("0001.sql"), source)
+            .statements
+            .into_iter()
+            .map(|statement| statement.change)
+            .collect()
+    }
+
+    fn users() -> TableMetadata {
+        TableMetadata::new("users", "public").with_columns(vec![
+            ColumnMetadata::new("id", DataType::Integer).with_primary_key(),
+            ColumnMetadata::new("name", DataType::Text),
+        ])
+    }
+
+    fn column_names(table: &TableMetadata) -> Vec<&str> {
+        table.columns.iter().map(|c| c.name.as_str()).collect()
+    }
+
+    #[test]
+    fn test_parse_create_table() {
+        let parsed = changes(
+            "-- create orders\n\
+             CREATE TABLE IF NOT EXISTS public.orders (\n\
+               id BIGINT NOT NULL,\n\
+               status VARCHAR(20) DEFAULT 'new',\n\
+               total numeric(10, 2),\n\
+               user_id INT REFERENCES users (id),\n\
+               tags text[],\n\
+               PRIMARY KEY (id),\n\
+               CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id)\n\
+             );",
+        );
+
+        let [
+            SchemaChange::CreateTable {
+                table,
+                columns,
+                if_not_exists,
+            },
+        ] = parsed.as_slice()
+        else {
+            panic!("expected one CREATE TABLE, got {:?}", parsed);
+        };
+        assert_eq!(table, "public.orders");
+        assert!(if_not_exists);
+        assert_eq!(columns.len(), 5);
+        assert!(columns[0].is_primary_key && !columns[0].nullable);
+        assert_eq!(columns[1].data_type, DataType::Varchar(Some(20)));
+        assert_eq!(columns[1].default_value.as_deref(), Some("'new'"));
+        assert_eq!(columns[2].data_type, DataType::Decimal);
+        assert!(columns[3].is_foreign_key);
+        assert_eq!(
+            columns[4].data_type,
+            DataType::Array(Box::new(DataType::Text))
+        );
+    }
+
+    #[test]
+    fn test_parse_create_table_as_select() {
+        let parsed = parse_schema_changes(
+            "CREATE TEMP TABLE recent AS\n\
+             SELECT o.id, o.total AS amount, count(*) items, upper(o.status) status, \
+             o.total * 2, o.*\n\
+             FROM orders o, users u WHERE o.user_id = u.id GROUP BY o.id",
+        );
+
+        let [SchemaChange::CreateTable { table, columns, .. }] = parsed.as_slice() else {
+            panic!("expected one CREATE TABLE, got {:?}", parsed);
+        };
+        assert_eq!(table, "recent");
+        let names: Vec<&str> = columns.iter().map(|c| c.name.as_str()).collect();
+        assert_eq!(names, ["id", "amount", "items", "status"]);
+        assert_eq!(columns[0].data_type, unknown_type());
+    }
+
+    #[test]
+    fn test_parse_alter_and_drop() {
+        let parsed = changes(
+            "ALTER TABLE users ADD COLUMN email varchar(255) NOT NULL, DROP COLUMN name;\n\
+             ALTER TABLE users ADD CONSTRAINT uq_email UNIQUE (email);\n\
+             ALTER TABLE users RENAME COLUMN email TO mail;\n\
+             ALTER TABLE `users` RENAME TO accounts;\n\
+             DROP TABLE IF EXISTS legacy, \"old\";",
+        );
+
+        assert_eq!(parsed.len(), 6);
+        assert!(matches!(
+            &parsed[0],
+            SchemaChange::AddColumn { table, column } if table == "users" && column.name == "email"
+        ));
+        assert!(matches!(
+            &parsed[1],
+            SchemaChange::DropColumn { column, if_exists: false, .. } if column == "name"
+        ));
+        assert!(matches!(
+            &parsed[2],
+            SchemaChange::RenameColumn { column, to, .. } if column == "email" && to == "mail"
+        ));
+        assert!(matches!(
+            &parsed[3],
+            SchemaChange::RenameTable { table, to } if table == "users" && to == "accounts"
+        ));
+        assert!(matches!(
+            &parsed[5],
+            SchemaChange::DropTable { table, if_exists: true } if table == "old"
+        ));
+    }
+
+    #[test]
+    fn test_statements_in_strings_and_functions_are_ignored() {
+        let parsed = changes(
+            "INSERT INTO notes VALUES ('DROP TABLE users;');\n\
+             CREATE FUNCTION f() RETURNS void AS $$ DROP TABLE users; $$ LANGUAGE sql;\n\
+             /* DROP TABLE users; */",
+        );
+        assert!(parsed.is_empty());
+    }
+
+    #[test]
+    fn test_migrations_apply_in_file_name_order() {
+        let overlay = MigrationOverlay::new(vec![
+            MigrationFile::parse(
+                uri("0002_rename.sql"),
+                "ALTER TABLE orders RENAME TO purchases;",
+            ),
+            MigrationFile::parse(uri("0001_create.sql"), "CREATE TABLE orders (id INT);"),
+        ]);
+
+        let (tables, conflicts) = overlay.apply(vec![users()]);
+
+        assert!(conflicts.is_empty(), "{:?}", conflicts);
+        let names: Vec<&str> = tables.iter().map(|t| t.name.as_str()).collect();
+        assert_eq!(names, ["users", "purchases"]);
+        assert_eq!(tables[1].schema, "public");
+    }
+
+    #[test]
+    fn test_columns_are_added_dropped_and_renamed() {
+        let overlay = MigrationOverlay::new(vec![MigrationFile::parse(
+            uri("0001.sql"),
+            "ALTER TABLE users ADD email text, ADD COLUMN name varchar(50);\n\
+             ALTER TABLE users RENAME COLUMN email TO mail;\n\
+             ALTER TABLE USERS DROP COLUMN id;",
+        )]);
+
+        let (tables, conflicts) = overlay.apply(vec![users()]);
+
+        assert!(conflicts.is_empty(), "{:?}", conflicts);
+        assert_eq!(column_names(&tables[0]), ["name", "mail"]);
+        assert_eq!(tables[0].columns[0].data_type, DataType::Varchar(Some(50)));
+    }
+
+    #[test]
+    fn test_dropping_a_nonexistent_table_conflicts() {
+        let overlay = MigrationOverlay::new(vec![MigrationFile::parse(
+            uri("0003_drop.sql"),
+            "DROP TABLE IF EXISTS gone;\nDROP TABLE orders;\nALTER TABLE users DROP COLUMN age;",
+        )]);
+
+        let (_, conflicts) = overlay.apply(vec![users()]);
+
+        assert_eq!(conflicts.len(), 2);
+        assert_eq!(conflicts[0].uri, uri("0003_drop.sql"));
+        assert_eq!(conflicts[0].message, "Table 'orders' does not exist");
+        assert_eq!(
+            conflicts[0].range,
+            Range::new(Position::new(1, 11), Position::new(1, 17))
+        );
+        assert_eq!(
+            conflicts[1].message,
+            "Column 'age' does not exist in table 'users'"
+        );
+    }
+
+    #[tokio::test]
+    async fn test_catalog_serves_migrated_schema() {
+        let base: Arc<dyn Catalog> = Arc::new(SnapshotCatalog::new(SchemaSnapshot::new(
+            vec![users(), TableMetadata::new("logs", "public")],
+            vec![],
+        )));
+        let overlay = MigrationOverlay::new(vec![MigrationFile::parse(
+            uri("0001.sql"),
+            "ALTER TABLE users ADD COLUMN email text;\nDROP TABLE logs;",
+        )]);
+        let catalog = MigrationCatalog::new(base, Arc::new(overlay));
+
+        let columns = catalog.get_columns("users").await.unwrap();
+        assert_eq!(columns.last().unwrap().name, "email");
+        assert!(matches!(
+            catalog.get_columns("logs").await,
+            Err(CatalogError::TableNotFound(..))
+        ));
+        assert_eq!(catalog.list_tables().await.unwrap().len(), 1);
+    }
+
+    #[test]
+    fn test_migration_glob_is_relative_to_the_workspace_folder() {
+        let root = Path::new("/project");
+        assert!(is_migration(
+            root,
+            Path::new("/project/db/migrations/0001.sql"),
+            "db/migrations/*.sql"
+        ));
+        assert!(!is_migration(
+            root,
+            Path::new("/project/queries/0001.sql"),
+            "db/migrations/*.sql"
+        ));
+        assert!(!is_migration(
+            root,
+            Path::new("/other/db/migrations/0001.sql"),
+            "db/migrations/*.sql"
+        ));
+    }
+}
@@ -0,0 +1,544 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Outbound notifications
+//!
+//! This module queues the notifications the server sends on its own
+//! (diagnostics, progress, messages, connection and schema changes) and
+//! writes them from one task per client. The transport buffers a single
+//! message, so without the queue a client that stops reading (a frozen
+//! editor) blocks every handler that publishes, and with an unbounded queue
+//! it grows memory without limit.
+//!
+//! The queue holds a bounded number of messages and never blocks:
+//!
+//! ```text
+//! publishDiagnostics a.sql ─┐
+//! $/progress (report)      ─┼─→ queue ─→ writer task ─→ client
+//! publishDiagnostics a.sql ─┘   (first a.sql publish replaced)
+//! ```
+//!
+//! - A publish replaces the queued publish of the same document, since
+//!   only the latest diagnostics matter; publishes are never dropped.
+//! - A connection status replaces the queued status of the same
+//!   connection, and a schema change is merged into the queued change of
+//!   the same connection; neither is dropped.
+//! - Progress reports, log messages and shown messages are dropped when
+//!   the queue is full.
+//! - Progress begin and end are never dropped, so the client does not
+//!   show a progress that never ends.
+//!
+//! Responses do not go through the queue: the transport writes them in
+//! the order of their requests.
+
+use serde::Serialize;
+use std::collections::VecDeque;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+use tokio::sync::Notify;
+use tokio::task::JoinHandle;
+use tower_lsp::Client;
+use tower_lsp::lsp_types::notification::Progress;
+use tower_lsp::lsp_types::{
+    Diagnostic, LogMessageParams, MessageType, ProgressParams, ProgressParamsValue,
+    PublishDiagnosticsParams, ShowMessageParams, Url, WorkDoneProgress,
+};
+use tracing::warn;
+
+use crate::connection_health::{ConnectionStatus, ConnectionStatusNotification};
+use crate::schema_cache::{SchemaChanged, SchemaChangedNotification};
+
+/// Default number of queued notifications
+pub const DEFAULT_QUEUE_CAPACITY: usize = 256;
+
+/// Notification waiting to be written
+#[derive(Debug, Clone, PartialEq)]
+pub enum Outbound {
+    /// `textDocument/publishDiagnostics`
+    Diagnostics(PublishDiagnosticsParams),
+
+    /// `$/progress`
+    Progress(ProgressParams),
+
+    /// `window/logMessage`
+    Log(LogMessageParams),
+
+    /// `window/showMessage`
+    ShowMessage(ShowMessageParams),
+
+    /// `sql/connectionStatus`
+    ConnectionStatus(ConnectionStatus),
+
+    /// `sql/schemaChanged`
+    SchemaChanged(SchemaChanged),
+}
+
+impl Outbound {
+    /// Check whether the message supersedes a queued one: both publish the
+    /// diagnostics of a document, or report on a connection
+    fn supersedes(&self, queued: &Outbound) -> bool {
+        match (self, queued) {
+            (Self::Diagnostics(new), Self::Diagnostics(old)) => new.uri == old.uri,
+            (Self::ConnectionStatus(new), Self::ConnectionStatus(old)) => {
+                new.connection == old.connection
+            }
+            (Self::SchemaChanged(new), Self::SchemaChanged(old)) => {
+                new.connection == old.connection
+            }
+            _ => false,
+        }
+    }
+
+    /// Take the place of the queued message it supersedes
+    ///
+    /// Schema changes are merged, so the client still learns every schema
+    /// that changed; other messages replace the queued one.
+    fn replace(self, queued: &mut Outbound) {
+        match (self, queued) {
+            (Self::SchemaChanged(new), Self::SchemaChanged(old)) => old.merge(new),
+            (new, queued) => *queued = new,
+        }
+    }
+
+    /// Check whether the message may be dropped when the queue is full
+    fn is_droppable(&self) -> bool {
+        match self {
+            Self::Diagnostics(_) | Self::ConnectionStatus(_) | Self::SchemaChanged(_) => false,
+            Self::Progress(params) => matches!(
+                params.value,
+                ProgressParamsValue::WorkDone(WorkDoneProgress::Report(_))
+            ),
+            Self::Log(_) | Self::ShowMessage(_) => true,
+        }
+    }
+}
+
+/// Queue statistics, as reported by `sql/stats`
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct OutboundStats {
+    /// Messages waiting to be written
+    pub queued: usize,
+
+    /// Longest queue seen
+    pub max_queued: usize,
+
+    /// Messages handed to the writer
+    pub sent: u64,
+
+    /// Messages replaced by, or merged with, a newer one about the same
+    /// document or connection
+    pub coalesced: u64,
+
+    /// Messages dropped because the queue was full
+    pub dropped: u64,
+}
+
+/// Bounded queue of notifications
+#[derive(Debug)]
+pub struct OutboundQueue {
+    capacity: usize,
+    state: Mutex<QueueState>,
+    /// Signals a new message, or the queue closing
+    ready: Notify,
+}
+
+#[derive(Debug, Default)]
+struct QueueState {
+    messages: VecDeque<Outbound>,
+    stats: OutboundStats,
+    closed: bool,
+}
+
+impl OutboundQueue {
+    /// Create a queue
+    ///
+    /// # Arguments
+    ///
+    /// * `capacity` - Number of messages above which droppable messages are
+    ///   dropped (at least 1)
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            capacity: capacity.max(1),
+            state: Mutex::new(QueueState::default()),
+            ready: Notify::new(),
+        }
+    }
+
+    /// Queue a message, without waiting
+    ///
+    /// When the queue is full, a droppable message is dropped instead, or
+    /// the oldest droppable one makes room for a message that must be
+    /// sent. Messages that must be sent are bounded by the open documents,
+    /// connections and running tasks, so they may exceed the capacity.
+    pub fn push(&self, message: Outbound) {
+        let mut state = self.state.lock().unwrap();
+        if state.closed {
+            return;
+        }
+
+        if let Some(queued) = state
+            .messages
+            .iter_mut()
+            .find(|queued| message.supersedes(queued))
+        {
+            message.replace(queued);
+            state.stats.coalesced += 1;
+            return;
+        }
+
+        if state.messages.len() >= self.capacity {
+            if message.is_droppable() {
+                state.stats.dropped += 1;
+                return;
+            }
+            if let Some(index) = state.messages.iter().position(Outbound::is_droppable) {
+                state.messages.remove(index);
+                state.stats.dropped += 1;
+            }
+        }
+        state.messages.push_back(message);
+        state.stats.max_queued = state.stats.max_queued.max(state.messages.len());
+        drop(state);
+        self.ready.notify_one();
+    }
+
+    /// Wait for the next message
+    ///
+    /// # Returns
+    ///
+    /// `None` once the queue is closed and drained
+    pub async fn pop(&self) -> Option<Outbound> {
+        loop {
+            {
+                let mut state = self.state.lock().unwrap();
+                if let Some(message) = state.messages.pop_front() {
+                    state.stats.sent += 1;
+                    return Some(message);
+                }
+                if state.closed {
+                    return None;
+                }
+            }
+            self.ready.notified().await;
+        }
+    }
+
+    /// Stop accepting messages; queued ones are still handed out
+    pub fn close(&self) {
+        self.state.lock().unwrap().closed = true;
+        self.ready.notify_one();
+    }
+
+    /// Current statistics
+    pub fn stats(&self) -> OutboundStats {
+        let state = self.state.lock().unwrap();
+        OutboundStats {
+            queued: state.messages.len(),
+            ..state.stats
+        }
+    }
+}
+
+/// Sends notifications to a client through a queue drained by a writer task
+///
+/// Clones share the queue; the writer task ends once the last clone is
+/// dropped and the queue is drained.
+#[derive(Debug, Clone)]
+pub struct OutboundSender {
+    inner: Arc<SenderInner>,
+}
+
+#[derive(Debug)]
+struct SenderInner {
+    queue: Arc<OutboundQueue>,
+    /// Writer task, until waited for by [`OutboundSender::close`]
+    writer: Mutex<Option<JoinHandle<()>>>,
+}
+
+impl Drop for SenderInner {
+    fn drop(&mut self) {
+        self.queue.close();
+    }
+}
+
+impl OutboundSender {
+    /// Start the writer task of a client
+    ///
+    /// Must be called within a Tokio runtime.
+    ///
+    /// # Arguments
+    ///
+    /// * `client` - The client receiving the notifications
+    /// * `capacity` - Capacity of the queue
+    pub fn spawn(client: Client, capacity: usize) -> Self {
+        let queue = Arc::new(OutboundQueue::new(capacity));
+        let writer = queue.clone();
+        let task = tokio::spawn(async move {
+            while let Some(message) = writer.pop().await {
+                match message {
+                    Outbound::Diagnostics(params) => {
+                        client
+                            .publish_diagnostics(params.uri, params.diagnostics, params.version)
+                            .await
+                    }
+                    Outbound::Progress(params) => {
+                        client.send_notification::<Progress>(params).await
+                    }
+                    Outbound::Log(params) => client.log_message(params.typ, params.message).await,
+                    Outbound::ShowMessage(params) => {
+                        client.show_message(params.typ, params.message).await
+                    }
+                    Outbound::ConnectionStatus(status) => {
+                        client
+                            .send_notification::<ConnectionStatusNotification>(status)
+                            .await
+                    }
+                    Outbound::SchemaChanged(change) => {
+                        client
+                            .send_notification::<SchemaChangedNotification>(change)
+                            .await
+                    }
+                }
+            }
+        });
+
+        Self {
+            inner: Arc::new(SenderInner {
+                queue,
+                writer: Mutex::new(Some(task)),
+            }),
+        }
+    }
+
+    /// Stop accepting notifications and wait until the queued ones are
+    /// written
+    ///
+    /// Gives up after `timeout`, e.g. when the client stopped reading.
+    /// Notifications sent afterwards, by any clone, are discarded.
+    pub async fn close(&self, timeout: Duration) {
+        self.inner.queue.close();
+        let Some(writer) = self.inner.writer.lock().unwrap().take() else {
+            return;
+        };
+        if tokio::time::timeout(timeout, writer).await.is_err() {
+            warn!(
+                "Gave up writing {} queued notifications",
+                self.inner.queue.stats().queued
+            );
+        }
+    }
+
+    /// Publish the diagnostics of a document
+    pub fn publish_diagnostics(
+        &self,
+        uri: Url,
+        diagnostics: Vec<Diagnostic>,
+        version: Option<i32>,
+    ) {
+        self.inner
+            .queue
+            .push(Outbound::Diagnostics(PublishDiagnosticsParams {
+                uri,
+                diagnostics,
+                version,
+            }));
+    }
+
+    /// Report work-done progress
+    pub fn progress(&self, params: ProgressParams) {
+        self.inner.queue.push(Outbound::Progress(params));
+    }
+
+    /// Send a message to the log of the client
+    pub fn log_message(&self, typ: MessageType, message: impl Into<String>) {
+        self.inner.queue.push(Outbound::Log(LogMessageParams {
+            typ,
+            message: message.into(),
+        }));
+    }
+
+    /// Show a message to the user
+    pub fn show_message(&self, typ: MessageType, message: impl Into<String>) {
+        self.inner
+            .queue
+            .push(Outbound::ShowMessage(ShowMessageParams {
+                typ,
+                message: message.into(),
+            }));
+    }
+
+    /// Report a change of the health of a connection
+    pub fn connection_status(&self, status: ConnectionStatus) {
+        self.inner.queue.push(Outbound::ConnectionStatus(status));
+    }
+
+    /// Report the changes found by a schema refresh
+    pub fn schema_changed(&self, change: SchemaChanged) {
+        self.inner.queue.push(Outbound::SchemaChanged(change));
+    }
+
+    /// Current statistics of the queue
+    pub fn stats(&self) -> OutboundStats {
+        self.inner.queue.stats()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use tower_lsp::lsp_types::{
+        NumberOrString, WorkDoneProgressBegin, WorkDoneProgressEnd, WorkDoneProgressReport,
+    };
+
+    fn publish(name: &str, count: usize) -> Outbound {
+        Outbound::Diagnostics(PublishDiagnosticsParams {
+            uri: Url::parse(&format!("file:///{}.sql", name)).unwrap(),
+            diagnostics: vec![Diagnostic::default(); count],
+            version: None,
+        })
+    }
+
+    fn progress(value: WorkDoneProgress) -> Outbound {
+        Outbound::Progress(ProgressParams {
+            token: NumberOrString::Number(1),
+            value: ProgressParamsValue::WorkDone(value),
+        })
+    }
+
+    fn log(message: &str) -> Outbound {
+        Outbound::Log(LogMessageParams {
+            typ: MessageType::INFO,
+            message: message.to_string(),
+        })
+    }
+
+    /// Messages left in the queue, without waiting
+    async fn drain(queue: &OutboundQueue) -> Vec<Outbound> {
+        queue.close();
+        let mut messages = Vec::new();
+        while let Some(message) = queue.pop().await {
+            messages.push(message);
+        }
+        messages
+    }
+
+    #[tokio::test]
+    async fn test_stalled_client_keeps_queue_bounded() {
+        // Nothing pops: the client stopped reading
+        let queue = OutboundQueue::new(8);
+
+        queue.push(progress(WorkDoneProgress::Begin(
+            WorkDoneProgressBegin::default(),
+        )));
+        for i in 0..1000 {
+            queue.push(publish(&format!("doc{}", i % 3), i));
+            queue.push(log(&format!("line {}", i)));
+            queue.push(progress(WorkDoneProgress::Report(
+                WorkDoneProgressReport::default(),
+            )));
+        }
+        queue.push(progress(WorkDoneProgress::End(
+            WorkDoneProgressEnd::default(),
+        )));
+
+        let stats = queue.stats();
+        assert!(stats.max_queued <= 8, "{:?}", stats);
+        assert_eq!(stats.coalesced, 997);
+
+        // The latest publish of each document, and the progress bounds,
+        // survive
+        let messages = drain(&queue).await;
+        for (name, count) in [("doc0", 999), ("doc1", 997), ("doc2", 998)] {
+            assert!(messages.contains(&publish(name, count)), "{}", name);
+        }
+        assert!(messages.contains(&progress(WorkDoneProgress::Begin(
+            WorkDoneProgressBegin::default()
+        ))));
+        assert_eq!(
+            messages.last(),
+            Some(&progress(WorkDoneProgress::End(
+                WorkDoneProgressEnd::default()
+            )))
+        );
+        assert_eq!(
+            stats.dropped as usize + messages.len(),
+            1 + 2000 + 3 + 1,
+            "{:?}",
+            stats
+        );
+    }
+
+    #[tokio::test]
+    async fn test_messages_keep_their_order() {
+        let queue = Arc::new(OutboundQueue::new(DEFAULT_QUEUE_CAPACITY));
+        let reader = {
+            let queue = queue.clone();
+            tokio::spawn(async move {
+                let mut messages = Vec::new();
+                while let Some(message) = queue.pop().await {
+                    messages.push(message);
+                }
+                messages
+            })
+        };
+
+        let sent: Vec<Outbound> = (0..100).map(|i| log(&format!("line {}", i))).collect();
+        for message in &sent {
+            queue.push(message.clone());
+            tokio::task::yield_now().await;
+        }
+        queue.close();
+
+        assert_eq!(reader.await.unwrap(), sent);
+        assert_eq!(queue.stats().sent, 100);
+    }
+
+    #[tokio::test]
+    async fn test_connection_reports_coalesce() {
+        use crate::connection_health::ConnectionState;
+
+        let queue = OutboundQueue::new(DEFAULT_QUEUE_CAPACITY);
+        let status = |state| {
+            Outbound::ConnectionStatus(ConnectionStatus {
+                connection: "mysql://localhost/shop".to_string(),
+                state,
+                last_error: None,
+                consecutive_failures: 0,
+            })
+        };
+        let change = |schema: &str, tables_added| {
+            Outbound::SchemaChanged(SchemaChanged {
+                connection: "mysql://localhost/shop".to_string(),
+                schemas: vec![schema.to_string()],
+                tables_added,
+                tables_removed: 0,
+                tables_changed: 0,
+                columns_added: 0,
+                columns_removed: 0,
+                columns_changed: 0,
+            })
+        };
+
+        queue.push(status(ConnectionState::Degraded));
+        queue.push(change("shop", 1));
+        queue.push(status(ConnectionState::Healthy));
+        queue.push(change("audit", 2));
+
+        // The latest status, and one change covering both refreshes
+        let Outbound::SchemaChanged(mut merged) = change("shop", 3) else {
+            unreachable!()
+        };
+        merged.schemas.push("audit".to_string());
+        assert_eq!(
+            drain(&queue).await,
+            [
+                status(ConnectionState::Healthy),
+                Outbound::SchemaChanged(merged)
+            ]
+        );
+        assert_eq!(queue.stats().coalesced, 2);
+    }
+}
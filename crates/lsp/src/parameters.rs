@@ -0,0 +1,532 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Bind parameters
+//!
+//! This module recognizes the bind parameter placeholders of SQL written for
+//! database drivers:
+//!
+//! ```text
+//! SELECT * FROM users WHERE id = $1          dollar    (PostgreSQL drivers)
+//! SELECT * FROM users WHERE id = ?           question  (JDBC, MySQL drivers)
+//! SELECT * FROM users WHERE id = :user_id    colon     (named, e.g. SQLAlchemy)
+//! SELECT * FROM users WHERE id = @user_id    at        (named, MySQL variables)
+//! ```
+//!
+//! The grammar knows none of them, so syntax and schema diagnostics on a
+//! placeholder are dropped, and named placeholders already written in a
+//! document are offered by completion after `:` or `@`.
+//!
+//! Which styles apply depends on the driver, so they are a setting with
+//! per-file overrides; without it, PostgreSQL documents use `$n`, and MySQL
+//! documents use `?` and `@name`. Recognition is lexical: placeholders in
+//! strings and comments, casts (`::text`) and system variables (`@@version`)
+//! are not parameters.
+
+use serde_json::Value;
+use tower_lsp::lsp_types::{CompletionItem, CompletionItemKind, Position, Range};
+use tracing::warn;
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_ir::dialect::DialectFamily;
+
+use crate::diagnostic::{SCHEMA_DIAGNOSTIC_SOURCE, SqlDiagnostic};
+use crate::lint::{LINT_DIAGNOSTIC_SOURCE, glob_match};
+use crate::migrations::{MIGRATION_DIAGNOSTIC_SOURCE, Token, TokenKind, tokenize};
+
+/// Placeholder styles recognized in a document
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub struct ParameterStyles {
+    /// Positional `$1`, `$2`, ...
+    pub dollar: bool,
+
+    /// Positional `?`
+    pub question: bool,
+
+    /// Named `:name`
+    pub colon: bool,
+
+    /// Named `@name`
+    pub at: bool,
+}
+
+impl ParameterStyles {
+    /// Styles used by the drivers of a dialect
+    pub fn for_dialect(dialect: Dialect) -> Self {
+        match dialect.family() {
+            DialectFamily::PostgreSQL => Self {
+                dollar: true,
+                ..Self::default()
+            },
+            DialectFamily::MySQL => Self {
+                question: true,
+                at: true,
+                ..Self::default()
+            },
+        }
+    }
+
+    /// Parse a list of style names (`"dollar"`, `"question"`, `"colon"`,
+    /// `"at"`)
+    ///
+    /// Unknown names are ignored with a warning. Returns `None` if the
+    /// setting is not a list.
+    pub fn from_settings(settings: &Value) -> Option<Self> {
+        let mut styles = Self::default();
+        for name in settings.as_array()? {
+            match name.as_str() {
+                Some("dollar") => styles.dollar = true,
+                Some("question") => styles.question = true,
+                Some("colon") => styles.colon = true,
+                Some("at") => styles.at = true,
+                _ => warn!("Ignoring unknown parameter style {}", name),
+            }
+        }
+        Some(styles)
+    }
+}
+
+/// Placeholder styles applying to the files matching a glob
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ParameterOverride {
+    /// Glob pattern of files the override applies to
+    pub files: String,
+
+    /// Styles of these files
+    pub styles: ParameterStyles,
+}
+
+/// Placeholder styles of the workspace
+///
+/// Parsed from the `parameters` settings object:
+///
+/// ```json
+/// {
+///   "styles": ["question"],
+///   "overrides": [{ "files": "scripts/**/*.py", "styles": ["colon"] }]
+/// }
+/// ```
+#[derive(Debug, Clone, PartialEq, Eq, Default)]
+pub struct ParameterConfig {
+    /// Styles of every document; `None` uses the styles of the dialect
+    pub styles: Option<ParameterStyles>,
+
+    /// Styles of the files matching a glob, later overrides winning
+    pub overrides: Vec<ParameterOverride>,
+}
+
+impl ParameterConfig {
+    /// Parse the `parameters` settings object
+    pub fn from_settings(settings: &Value) -> Self {
+        let styles = |settings: &Value| {
+            settings
+                .get("styles")
+                .and_then(ParameterStyles::from_settings)
+        };
+
+        Self {
+            styles: styles(settings),
+            overrides: settings
+                .get("overrides")
+                .and_then(Value::as_array)
+                .map(|overrides| {
+                    overrides
+                        .iter()
+                        .filter_map(|o| {
+                            Some(ParameterOverride {
+                                files: o.get("files")?.as_str()?.to_string(),
+                                styles: styles(o)?,
+                            })
+                        })
+                        .collect()
+                })
+                .unwrap_or_default(),
+        }
+    }
+
+    /// Get the styles of a document
+    ///
+    /// # Arguments
+    ///
+    /// * `dialect` - Dialect of the document, whose styles apply by default
+    /// * `path` - The document path (matched against the override globs)
+    pub fn styles_for(&self, dialect: Dialect, path: &str) -> ParameterStyles {
+        self.overrides
+            .iter()
+            .rev()
+            .find(|o| glob_match(&o.files, path))
+            .map(|o| o.styles)
+            .or(self.styles)
+            .unwrap_or_else(|| ParameterStyles::for_dialect(dialect))
+    }
+}
+
+/// A placeholder written in a document
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Parameter {
+    /// Placeholder as written (`$1`, `?`, `:name`, `@name`)
+    pub text: String,
+
+    /// Range of the placeholder
+    pub range: Range,
+}
+
+impl Parameter {
+    /// Sigil and name of a named placeholder (`(':', "name")`)
+    pub fn named(&self) -> Option<(char, &str)> {
+        let sigil = self
+            .text
+            .chars()
+            .next()
+            .filter(|c| matches!(c, ':' | '@'))?;
+        Some((sigil, &self.text[1..]))
+    }
+}
+
+/// Find the placeholders of a document
+///
+/// # Arguments
+///
+/// * `source` - Document text
+/// * `styles` - Placeholder styles to recognize
+///
+/// # Returns
+///
+/// The placeholders in document order
+pub fn find_parameters(source: &str, styles: ParameterStyles) -> Vec<Parameter> {
+    let tokens = tokenize(source);
+    let mut parameters = Vec::new();
+
+    for (index, token) in tokens.iter().enumerate() {
+        let parameter = match token.kind {
+            TokenKind::Word
+                if styles.dollar
+                    && token.text.len() > 1
+                    && token.text.starts_with('$')
+                    && token.text[1..].chars().all(|c| c.is_ascii_digit()) =>
+            {
+                Some(Parameter {
+                    text: token.text.clone(),
+                    range: token.range,
+                })
+            }
+            TokenKind::Symbol('?') if styles.question => Some(Parameter {
+                text: token.text.clone(),
+                range: token.range,
+            }),
+            TokenKind::Symbol(sigil @ (':' | '@'))
+                if (sigil == ':' && styles.colon) || (sigil == '@' && styles.at) =>
+            {
+                named_parameter(&tokens, index)
+            }
+            _ => None,
+        };
+        parameters.extend(parameter);
+    }
+
+    parameters
+}
+
+/// Named placeholder whose sigil is `tokens[index]`
+///
+/// The name must follow the sigil directly, and a doubled sigil (`::text`,
+/// `@@version`) is not a placeholder.
+fn named_parameter(tokens: &[Token], index: usize) -> Option<Parameter> {
+    let sigil = &tokens[index];
+    let doubled = |other: Option<&Token>| {
+        other.is_some_and(|other| {
+            other.kind == sigil.kind
+                && (other.range.end == sigil.range.start || other.range.start == sigil.range.end)
+        })
+    };
+    if doubled(index.checked_sub(1).and_then(|i| tokens.get(i))) || doubled(tokens.get(index + 1)) {
+        return None;
+    }
+
+    let name = tokens.get(index + 1)?;
+    let named = name.kind == TokenKind::Word
+        && name.range.start == sigil.range.end
+        && name
+            .text
+            .starts_with(|c: char| c.is_alphabetic() || c == '_');
+    named.then(|| Parameter {
+        text: format!("{}{}", sigil.text, name.text),
+        range: Range::new(sigil.range.start, name.range.end),
+    })
+}
+
+/// Drop the syntax and schema diagnostics raised by placeholders
+///
+/// # Arguments
+///
+/// * `diagnostics` - Diagnostics of a document
+/// * `parameters` - Placeholders of the document
+///
+/// # Returns
+///
+/// The diagnostics not touching a placeholder; lint and migration
+/// diagnostics are always kept
+pub fn suppress_parameter_diagnostics(
+    diagnostics: Vec<SqlDiagnostic>,
+    parameters: &[Parameter],
+) -> Vec<SqlDiagnostic> {
+    if parameters.is_empty() {
+        return diagnostics;
+    }
+
+    diagnostics
+        .into_iter()
+        .filter(|diagnostic| {
+            matches!(
+                diagnostic.source.as_str(),
+                LINT_DIAGNOSTIC_SOURCE | MIGRATION_DIAGNOSTIC_SOURCE
+            ) || !parameters
+                .iter()
+                .any(|parameter| overlaps(diagnostic.range, parameter.range))
+        })
+        .collect()
+}
+
+/// Check whether two ranges share a character, or an empty range touches
+/// the other
+fn overlaps(a: Range, b: Range) -> bool {
+    if a.start == a.end {
+        return b.start <= a.start && a.start <= b.end;
+    }
+    a.start < b.end && b.start < a.end
+}
+
+/// Complete the named placeholder typed at a position
+///
+/// # Arguments
+///
+/// * `source` - Document text
+/// * `position` - Cursor position
+/// * `styles` - Placeholder styles of the document
+///
+/// # Returns
+///
+/// The other named placeholders of the document with the same sigil, or
+/// `None` when the cursor is not on a named placeholder
+pub fn complete_parameters(
+    source: &str,
+    position: Position,
+    styles: ParameterStyles,
+) -> Option<Vec<CompletionItem>> {
+    let parameters = find_parameters(source, styles);
+
+    // The placeholder being typed, or a sigil just typed
+    let typed = parameters
+        .iter()
+        .find(|p| p.range.start < position && position <= p.range.end)
+        .and_then(Parameter::named)
+        .map(|(sigil, _)| sigil)
+        .or_else(|| typed_sigil(source, position, styles))?;
+
+    let mut names: Vec<&str> = Vec::new();
+    for parameter in &parameters {
+        if parameter.range.start < position && position <= parameter.range.end {
+            continue;
+        }
+        if let Some((sigil, name)) = parameter.named()
+            && sigil == typed
+            && !names.contains(&name)
+        {
+            names.push(name);
+        }
+    }
+
+    Some(
+        names
+            .into_iter()
+            .map(|name| CompletionItem {
+                label: format!("{}{}", typed, name),
+                kind: Some(CompletionItemKind::VARIABLE),
+                detail: Some("Parameter".to_string()),
+                filter_text: Some(name.to_string()),
+                insert_text: Some(name.to_string()),
+                ..Default::default()
+            })
+            .collect(),
+    )
+}
+
+/// Sigil of a named style directly before the cursor, not doubled
+fn typed_sigil(source: &str, position: Position, styles: ParameterStyles) -> Option<char> {
+    let tokens = tokenize(source);
+    let index = tokens
+        .iter()
+        .position(|token| token.range.end == position)?;
+    let sigil = match tokens[index].kind {
+        TokenKind::Symbol(':') if styles.colon => ':',
+        TokenKind::Symbol('@') if styles.at => '@',
+        _ => return None,
+    };
+    let previous = index.checked_sub(1).map(|i| &tokens[i]);
+    let doubled = previous.is_some_and(|previous| {
+        previous.kind == tokens[index].kind && previous.range.end == tokens[index].range.start
+    });
+    (!doubled).then_some(sigil)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::diagnostic::DiagnosticCode;
+    use tower_lsp::lsp_types::DiagnosticSeverity;
+
+    const ALL: ParameterStyles = ParameterStyles {
+        dollar: true,
+        question: true,
+        colon: true,
+        at: true,
+    };
+
+    fn texts(source: &str, styles: ParameterStyles) -> Vec<String> {
+        find_parameters(source, styles)
+            .into_iter()
+            .map(|parameter| parameter.text)
+            .collect()
+    }
+
+    #[test]
+    fn test_every_style_is_recognized() {
+        // Source, styles, expected placeholders
+        let cases = [
+            (
+                "SELECT * FROM t WHERE a = $1 AND b = $12",
+                ALL,
+                vec!["$1", "$12"],
+            ),
+            (
+                "SELECT * FROM t WHERE a = ? AND b IN (?, ?)",
+                ALL,
+                vec!["?", "?", "?"],
+            ),
+            (
+                "SELECT * FROM t WHERE a = :a AND b = :b_2",
+                ALL,
+                vec![":a", ":b_2"],
+            ),
+            ("SELECT * FROM t WHERE a = @a", ALL, vec!["@a"]),
+            ("SELECT :a, @b, ?, $1", ParameterStyles::default(), vec![]),
+            (
+                "SELECT :a, @b, ?, $1",
+                ParameterStyles::for_dialect(Dialect::MySQL),
+                vec!["@b", "?"],
+            ),
+            (
+                "SELECT :a, @b, ?, $1",
+                ParameterStyles::for_dialect(Dialect::PostgreSQL),
+                vec!["$1"],
+            ),
+        ];
+        for (source, styles, expected) in cases {
+            assert_eq!(texts(source, styles), expected, "{}", source);
+        }
+    }
+
+    #[test]
+    fn test_lookalikes_are_not_placeholders() {
+        let sources = [
+            "SELECT id::text, @@version FROM t",
+            "SELECT ':a', '?', \"$1\" FROM t -- WHERE a = ?",
+            "SELECT $$ :a $$, $tag$ ? $tag$",
+            "SELECT : a, @ b, $a",
+        ];
+        for source in sources {
+            assert!(texts(source, ALL).is_empty(), "{}", source);
+        }
+
+        let parameter = &find_parameters("SELECT 1\n  WHERE a = :é", ALL)[0];
+        assert_eq!(parameter.text, ":é");
+        assert_eq!(
+            parameter.range,
+            Range::new(Position::new(1, 12), Position::new(1, 14))
+        );
+    }
+
+    #[test]
+    fn test_styles_of_a_document() {
+        let config = ParameterConfig::from_settings(&serde_json::json!({
+            "styles": ["question", "unknown"],
+            "overrides": [
+                { "files": "scripts/**", "styles": ["colon"] },
+                { "files": "scripts/pg/**", "styles": ["dollar"] }
+            ]
+        }));
+
+        let question = ParameterStyles {
+            question: true,
+            ..Default::default()
+        };
+        assert_eq!(
+            config.styles_for(Dialect::PostgreSQL, "/app/q.sql"),
+            question
+        );
+        assert!(config.styles_for(Dialect::MySQL, "/app/scripts/q.py").colon);
+        assert!(
+            config
+                .styles_for(Dialect::MySQL, "/app/scripts/pg/q.py")
+                .dollar
+        );
+        assert_eq!(
+            ParameterConfig::default().styles_for(Dialect::PostgreSQL, "/app/q.sql"),
+            ParameterStyles::for_dialect(Dialect::PostgreSQL)
+        );
+    }
+
+    #[test]
+    fn test_diagnostics_on_placeholders_are_dropped() {
+        let source = "SELECT * FROM users WHERE id = :id AND name = nickname";
+        let parameters = find_parameters(source, ALL);
+        let diagnostic = |start: u32, end: u32, source: &str| {
+            SqlDiagnostic::new(
+                "problem".to_string(),
+                DiagnosticSeverity::ERROR,
+                Range::new(Position::new(0, start), Position::new(0, end)),
+            )
+            .with_code(DiagnosticCode::UndefinedColumn)
+            .with_source(source.to_string())
+        };
+
+        let diagnostics = vec![
+            // Syntax error on the sigil, unknown column on the name
+            diagnostic(31, 32, "unified-sql-lsp"),
+            diagnostic(32, 34, SCHEMA_DIAGNOSTIC_SOURCE),
+            // Unknown column elsewhere, lint over the whole statement
+            diagnostic(46, 54, SCHEMA_DIAGNOSTIC_SOURCE),
+            diagnostic(0, 54, LINT_DIAGNOSTIC_SOURCE),
+        ];
+        let kept = suppress_parameter_diagnostics(diagnostics, &parameters);
+        let ranges: Vec<u32> = kept.iter().map(|d| d.range.start.character).collect();
+        assert_eq!(ranges, vec![46, 0]);
+    }
+
+    #[test]
+    fn test_named_parameters_are_completed() {
+        let source = "SELECT * FROM t WHERE a = :user_id AND b = :status AND c = :us";
+        let position = Position::new(0, source.len() as u32);
+
+        let items = complete_parameters(source, position, ALL).unwrap();
+        let labels: Vec<&str> = items.iter().map(|item| item.label.as_str()).collect();
+        assert_eq!(labels, vec![":user_id", ":status"]);
+        assert_eq!(items[0].insert_text.as_deref(), Some("user_id"));
+
+        // A sigil just typed, and names of another style
+        let source = "SET @total = 0; SELECT :a, @";
+        let position = Position::new(0, source.len() as u32);
+        let items = complete_parameters(source, position, ALL).unwrap();
+        let labels: Vec<&str> = items.iter().map(|item| item.label.as_str()).collect();
+        assert_eq!(labels, vec!["@total"]);
+
+        // Not on a placeholder, or the style is off
+        assert!(complete_parameters("SELECT us", Position::new(0, 9), ALL).is_none());
+        assert!(complete_parameters("SELECT id::", Position::new(0, 11), ALL).is_none());
+        let source = "SELECT :a, :b";
+        assert!(
+            complete_parameters(source, Position::new(0, 13), ParameterStyles::default()).is_none()
+        );
+    }
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Parent process watchdog
+//!
+//! Editors pass their process id to `initialize` (`processId`), and the LSP
+//! specification asks servers to exit when that process is gone: an editor
+//! that crashed never sends `shutdown` and `exit`. Closed stdin usually
+//! tells the same story, but not when another child of the editor inherited
+//! the pipe.
+//!
+//! Liveness is polled, since a process cannot wait on one it did not spawn:
+//! through `/proc` on Linux, `ps` on other Unix systems and `tasklist` on
+//! Windows. A zombie (an editor that exited, not yet reaped by its own
+//! parent) counts as gone. The commands run on the runtime's process
+//! driver, so a poll never blocks a worker thread.
+
+use std::time::Duration;
+
+/// Interval between two checks of the parent process
+pub const POLL_INTERVAL: Duration = Duration::from_secs(5);
+
+/// Delay between noticing the parent is gone and exiting, leaving time to
+/// the `exit` notification of an editor shutting down normally
+pub const GRACE_PERIOD: Duration = Duration::from_secs(3);
+
+/// Check whether a process is running
+///
+/// When liveness cannot be checked, the process is assumed alive, so the
+/// server never exits on a failed check.
+pub async fn is_alive(pid: u32) -> bool {
+    #[cfg(target_os = "linux")]
+    {
+        // Reads of /proc are served from memory
+        match std::fs::read_to_string(format!("/proc/{}/stat", pid)) {
+            Ok(stat) => !has_exited(&stat),
+            Err(e) => e.kind() != std::io::ErrorKind::NotFound,
+        }
+    }
+
+    #[cfg(all(unix, not(target_os = "linux")))]
+    {
+        // `ps` fails for unknown processes and reports zombies as `Z`
+        let output = tokio::process::Command::new("ps")
+            .args(["-o", "stat=", "-p", &pid.to_string()])
+            .stderr(std::process::Stdio::null())
+            .output()
+            .await;
+        output.map_or(true, |output| {
+            output.status.success() && !String::from_utf8_lossy(&output.stdout).starts_with('Z')
+        })
+    }
+
+    #[cfg(windows)]
+    {
+        tokio::process::Command::new("tasklist")
+            .args(["/FI", &format!("PID eq {}", pid), "/NH"])
+            .output()
+            .await
+            .map_or(true, |output| {
+                String::from_utf8_lossy(&output.stdout).contains(&pid.to_string())
+            })
+    }
+
+    #[cfg(not(any(unix, windows)))]
+    {
+        let _ = pid;
+        true
+    }
+}
+
+/// Check whether a `/proc/<pid>/stat` line describes an exited process
+///
+/// The state follows the command name, which is in parentheses and may
+/// contain parentheses itself.
+#[cfg(any(target_os = "linux", test))]
+fn has_exited(stat: &str) -> bool {
+    stat.rsplit_once(')')
+        .and_then(|(_, rest)| rest.trim_start().chars().next())
+        .is_some_and(|state| matches!(state, 'Z' | 'X' | 'x'))
+}
+
+/// Wait until a process is gone
+///
+/// # Arguments
+///
+/// * `pid` - Id of the process
+/// * `poll_interval` - Interval between two checks
+pub async fn wait_for_exit(pid: u32, poll_interval: Duration) {
+    let mut interval = tokio::time::interval(poll_interval);
+    loop {
+        interval.tick().await;
+        if !is_alive(pid).await {
+            return;
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_exited_process_is_noticed() {
+        assert!(is_alive(std::process::id()).await);
+
+        #[cfg(unix)]
+        let mut child = std::process::Command::new("true").spawn().unwrap();
+        #[cfg(windows)]
+        let mut child = std::process::Command::new("cmd")
+            .args(["/C", "exit"])
+            .spawn()
+            .unwrap();
+        let pid = child.id();
+        child.wait().unwrap();
+
+        assert!(!is_alive(pid).await);
+        tokio::time::timeout(
+            Duration::from_secs(5),
+            wait_for_exit(pid, Duration::from_millis(10)),
+        )
+        .await
+        .expect("The exit of the process was not noticed");
+    }
+
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_zombie_process_is_gone() {
+        // Not waited for: the child stays a zombie once it exits
+        let mut child = std::process::Command::new("true").spawn().unwrap();
+        let pid = child.id();
+
+        tokio::time::timeout(
+            Duration::from_secs(5),
+            wait_for_exit(pid, Duration::from_millis(10)),
+        )
+        .await
+        .expect("The zombie process was reported alive");
+        child.wait().unwrap();
+    }
+
+    #[test]
+    fn test_stat_state() {
+        assert!(!has_exited("42 (sql (lsp)) S 1 42 42 0 -1"));
+        assert!(has_exited("42 (code) Z 1 42 42 0 -1"));
+        assert!(!has_exited("42 (code) R 1 42 42 0 -1"));
+    }
+}
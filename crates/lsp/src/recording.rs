@@ -0,0 +1,740 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Session recording and replay
+//!
+//! This module records the messages of an LSP session, so a bug seen in an
+//! editor can be replayed against the server:
+//!
+//! ```text
+//! unified-sql-lsp --record-dir /tmp/sessions [--record-redact]
+//!   → /tmp/sessions/session-1735689600-4242.jsonl
+//! unified-sql-lsp replay /tmp/sessions/session-1735689600-4242.jsonl
+//! ```
+//!
+//! ## Recording
+//!
+//! The stdin and stdout of the server are wrapped (see [`Recorded`]); every
+//! framed message is appended to a JSONL file as
+//! `{"timeMs": 12, "direction": "in", "message": {...}}`. Passwords of
+//! connection strings are always removed; with `--record-redact`, document
+//! contents are replaced by `x`s of the same shape, so positions stay valid.
+//! A file reaching the size limit is continued in `...-1.jsonl`,
+//! `...-2.jsonl`, and so on. Without `--record-dir` the streams are not
+//! wrapped at all.
+//!
+//! ## Replay
+//!
+//! [`replay`] runs an in-process server and sends it the recorded client
+//! messages in order, without the recorded delays. Requests the server sends
+//! are answered with the recorded answers. Each response is compared with the
+//! recorded one; differences are reported as divergences, and a panic of the
+//! server ends the replay.
+
+use std::collections::HashMap;
+use std::fs::{File, OpenOptions};
+use std::io::{self, BufRead, BufReader, Write};
+use std::path::{Path, PathBuf};
+use std::pin::Pin;
+use std::sync::{Arc, Mutex};
+use std::task::{Context, Poll};
+use std::time::{Duration, Instant, SystemTime, UNIX_EPOCH};
+
+use serde_json::{Value, json};
+use tokio::io::{AsyncRead, AsyncReadExt, AsyncWrite, AsyncWriteExt, DuplexStream, ReadBuf};
+use tokio::io::{ReadHalf, WriteHalf};
+use tokio::sync::mpsc;
+use tokio::task::JoinHandle;
+use tower_lsp::{LspService, Server};
+use tracing::warn;
+use unified_sql_lsp_catalog::redact_credentials;
+
+use crate::backend::{LspBackend, STATS_METHOD};
+use crate::diagnostic_scheduler::FOCUS_DOCUMENT_METHOD;
+
+/// Default size at which a recording continues in a new file (16 MB)
+pub const DEFAULT_MAX_FILE_BYTES: u64 = 16 * 1024 * 1024;
+
+/// Time a replayed request may take before the replay gives up on it
+const REPLAY_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Size of the in-memory pipe between a replay and its server
+const PIPE_CAPACITY: usize = 64 * 1024;
+
+/// Side of the session that sent a message
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Direction {
+    /// From the client to the server
+    In,
+    /// From the server to the client
+    Out,
+}
+
+impl Direction {
+    fn as_str(self) -> &'static str {
+        match self {
+            Direction::In => "in",
+            Direction::Out => "out",
+        }
+    }
+}
+
+/// Recording settings
+#[derive(Debug, Clone)]
+pub struct RecordConfig {
+    /// Directory the session files are written to
+    pub dir: PathBuf,
+
+    /// Size at which a file is continued in the next one
+    pub max_file_bytes: u64,
+
+    /// Whether document contents are replaced by `x`s
+    pub redact_documents: bool,
+}
+
+impl RecordConfig {
+    /// Record to a directory, with the default size limit and no redaction
+    pub fn new(dir: impl Into<PathBuf>) -> Self {
+        Self {
+            dir: dir.into(),
+            max_file_bytes: DEFAULT_MAX_FILE_BYTES,
+            redact_documents: false,
+        }
+    }
+}
+
+/// File currently written to
+#[derive(Debug)]
+struct RecordFile {
+    file: File,
+    path: PathBuf,
+    written: u64,
+    /// Number of files continued so far
+    index: u32,
+}
+
+/// Appends the messages of a session to JSONL files
+#[derive(Debug)]
+pub struct SessionRecorder {
+    config: RecordConfig,
+    /// Path of the first file, without extension
+    stem: PathBuf,
+    started: Instant,
+    file: Mutex<RecordFile>,
+}
+
+impl SessionRecorder {
+    /// Create the directory and the first file of a session
+    pub fn create(config: RecordConfig) -> io::Result<Self> {
+        std::fs::create_dir_all(&config.dir)?;
+        let seconds = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map_or(0, |time| time.as_secs());
+        let stem = config
+            .dir
+            .join(format!("session-{}-{}", seconds, std::process::id()));
+        let path = stem.with_extension("jsonl");
+        let file = OpenOptions::new().create(true).append(true).open(&path)?;
+
+        Ok(Self {
+            config,
+            stem,
+            started: Instant::now(),
+            file: Mutex::new(RecordFile {
+                file,
+                path,
+                written: 0,
+                index: 0,
+            }),
+        })
+    }
+
+    /// Path of the file currently written to
+    pub fn path(&self) -> PathBuf {
+        self.file.lock().unwrap().path.clone()
+    }
+
+    /// Append a message
+    ///
+    /// Failures are logged; recording never interrupts the session.
+    pub fn record(&self, direction: Direction, message: &Value) {
+        let mut message = message.clone();
+        if self.config.redact_documents {
+            redact_documents(&mut message);
+        }
+        let entry = json!({
+            "timeMs": self.started.elapsed().as_millis() as u64,
+            "direction": direction.as_str(),
+            "message": message,
+        });
+        let line = format!("{}\n", redact_credentials(&entry.to_string()));
+
+        let mut file = self.file.lock().unwrap();
+        if let Err(e) = self.append(&mut file, line.as_bytes()) {
+            warn!("Failed to record session message: {}", e);
+        }
+    }
+
+    fn append(&self, file: &mut RecordFile, line: &[u8]) -> io::Result<()> {
+        if file.written > 0 && file.written + line.len() as u64 > self.config.max_file_bytes {
+            let index = file.index + 1;
+            let path = PathBuf::from(format!("{}-{}.jsonl", self.stem.display(), index));
+            *file = RecordFile {
+                file: OpenOptions::new().create(true).append(true).open(&path)?,
+                path,
+                written: 0,
+                index,
+            };
+        }
+        file.file.write_all(line)?;
+        file.written += line.len() as u64;
+        Ok(())
+    }
+}
+
+/// Replace the document contents of a client message by `x`s
+///
+/// Line breaks and UTF-16 lengths are kept, so the positions of later
+/// requests stay valid.
+fn redact_documents(message: &mut Value) {
+    let redact = |text: &mut Value| {
+        if let Some(content) = text.as_str() {
+            *text = Value::String(
+                content
+                    .chars()
+                    .flat_map(|c| match c {
+                        '\n' | '\r' => std::iter::repeat_n(c, 1),
+                        c => std::iter::repeat_n('x', c.len_utf16()),
+                    })
+                    .collect(),
+            );
+        }
+    };
+
+    let Some(params) = message.get_mut("params") else {
+        return;
+    };
+    if let Some(text) = params.pointer_mut("/textDocument/text") {
+        redact(text);
+    }
+    if let Some(changes) = params
+        .get_mut("contentChanges")
+        .and_then(Value::as_array_mut)
+    {
+        for change in changes {
+            if let Some(text) = change.get_mut("text") {
+                redact(text);
+            }
+        }
+    }
+}
+
+/// Splits a byte stream into `Content-Length` framed JSON messages
+#[derive(Debug, Default)]
+struct FrameDecoder {
+    buffer: Vec<u8>,
+}
+
+impl FrameDecoder {
+    /// Add bytes, returning the messages they complete
+    fn push(&mut self, bytes: &[u8]) -> Vec<Value> {
+        self.buffer.extend_from_slice(bytes);
+
+        let mut messages = Vec::new();
+        while let Some(header_end) = find(&self.buffer, b"\r\n\r\n") {
+            let headers = String::from_utf8_lossy(&self.buffer[..header_end]);
+            let length = headers.lines().find_map(|line| {
+                line.strip_prefix("Content-Length:")
+                    .and_then(|length| length.trim().parse::<usize>().ok())
+            });
+            let body_start = header_end + 4;
+            let Some(length) = length else {
+                // Not a frame we understand; skip its headers
+                self.buffer.drain(..body_start);
+                continue;
+            };
+            if self.buffer.len() < body_start + length {
+                break;
+            }
+            let body: Vec<u8> = self
+                .buffer
+                .drain(..body_start + length)
+                .skip(body_start)
+                .collect();
+            match serde_json::from_slice(&body) {
+                Ok(message) => messages.push(message),
+                Err(e) => warn!("Failed to decode a framed message: {}", e),
+            }
+        }
+        messages
+    }
+}
+
+fn find(haystack: &[u8], needle: &[u8]) -> Option<usize> {
+    haystack
+        .windows(needle.len())
+        .position(|window| window == needle)
+}
+
+/// Stream whose framed messages are recorded as they pass through
+///
+/// Wrap the input of the server as [`Direction::In`] and its output as
+/// [`Direction::Out`].
+#[derive(Debug)]
+pub struct Recorded<T> {
+    inner: T,
+    direction: Direction,
+    recorder: Arc<SessionRecorder>,
+    decoder: FrameDecoder,
+}
+
+impl<T> Recorded<T> {
+    /// Wrap a stream
+    ///
+    /// # Arguments
+    ///
+    /// * `inner` - The stream carrying the messages
+    /// * `direction` - Side of the session sending on the stream
+    /// * `recorder` - The recorder messages are appended to
+    pub fn new(inner: T, direction: Direction, recorder: Arc<SessionRecorder>) -> Self {
+        Self {
+            inner,
+            direction,
+            recorder,
+            decoder: FrameDecoder::default(),
+        }
+    }
+
+    fn observe(&mut self, bytes: &[u8]) {
+        for message in self.decoder.push(bytes) {
+            self.recorder.record(self.direction, &message);
+        }
+    }
+}
+
+impl<T: AsyncRead + Unpin> AsyncRead for Recorded<T> {
+    fn poll_read(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+        buf: &mut ReadBuf<'_>,
+    ) -> Poll<io::Result<()>> {
+        let before = buf.filled().len();
+        let poll = Pin::new(&mut self.inner).poll_read(cx, buf);
+        if let Poll::Ready(Ok(())) = poll {
+            self.observe(&buf.filled()[before..]);
+        }
+        poll
+    }
+}
+
+impl<T: AsyncWrite + Unpin> AsyncWrite for Recorded<T> {
+    fn poll_write(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+        buf: &[u8],
+    ) -> Poll<io::Result<usize>> {
+        let poll = Pin::new(&mut self.inner).poll_write(cx, buf);
+        if let Poll::Ready(Ok(written)) = poll {
+            self.observe(&buf[..written]);
+        }
+        poll
+    }
+
+    fn poll_flush(mut self: Pin<&mut Self>, cx: &mut Context<'_>) -> Poll<io::Result<()>> {
+        Pin::new(&mut self.inner).poll_flush(cx)
+    }
+
+    fn poll_shutdown(mut self: Pin<&mut Self>, cx: &mut Context<'_>) -> Poll<io::Result<()>> {
+        Pin::new(&mut self.inner).poll_shutdown(cx)
+    }
+}
+
+/// A response that differs from the recorded one
+#[derive(Debug, Clone, PartialEq)]
+pub struct Divergence {
+    /// Method of the request
+    pub method: String,
+
+    /// Id of the request
+    pub id: Value,
+
+    /// Response in the recording (`result` or `error`)
+    pub recorded: Value,
+
+    /// Response of the replay, `None` if the server did not answer
+    pub replayed: Option<Value>,
+}
+
+/// Outcome of a replay
+#[derive(Debug, Default)]
+pub struct ReplayReport {
+    /// Number of requests sent
+    pub requests: usize,
+
+    /// Number of notifications sent
+    pub notifications: usize,
+
+    /// Responses that differ from the recording
+    pub divergences: Vec<Divergence>,
+
+    /// Panic message of the server, if it panicked
+    pub panic: Option<String>,
+}
+
+impl ReplayReport {
+    /// Whether the server behaved as recorded
+    pub fn is_success(&self) -> bool {
+        self.divergences.is_empty() && self.panic.is_none()
+    }
+}
+
+/// One line of a recording
+#[derive(Debug)]
+struct Entry {
+    direction: Direction,
+    message: Value,
+}
+
+/// Read a recording file
+fn read_entries(path: &Path) -> io::Result<Vec<Entry>> {
+    let reader = BufReader::new(File::open(path)?);
+    let mut entries = Vec::new();
+    for line in reader.lines() {
+        let line = line?;
+        if line.trim().is_empty() {
+            continue;
+        }
+        let mut entry: Value = serde_json::from_str(&line)
+            .map_err(|e| io::Error::new(io::ErrorKind::InvalidData, e))?;
+        let direction = match entry.get("direction").and_then(Value::as_str) {
+            Some("in") => Direction::In,
+            Some("out") => Direction::Out,
+            _ => continue,
+        };
+        entries.push(Entry {
+            direction,
+            message: entry["message"].take(),
+        });
+    }
+    Ok(entries)
+}
+
+/// Replay a recorded session against a new server
+///
+/// # Arguments
+///
+/// * `path` - A recording file; files continued after it are not read
+///
+/// # Returns
+///
+/// The report of the replay, or an error if the file cannot be read
+pub async fn replay(path: &Path) -> io::Result<ReplayReport> {
+    let entries = read_entries(path)?;
+
+    // Recorded responses, keyed by the id of their request
+    let responses = |direction: Direction| -> HashMap<String, Value> {
+        entries
+            .iter()
+            .filter(|entry| entry.direction == direction && entry.message.get("method").is_none())
+            .filter_map(|entry| {
+                let id = entry.message.get("id")?;
+                Some((id.to_string(), entry.message.clone()))
+            })
+            .collect()
+    };
+    let server_responses = responses(Direction::Out);
+    let client_answers = responses(Direction::In);
+
+    let mut report = ReplayReport::default();
+    let mut server = InProcessServer::start(None);
+    for entry in &entries {
+        let message = &entry.message;
+        let Some(method) = message.get("method").and_then(Value::as_str) else {
+            continue;
+        };
+        if entry.direction != Direction::In {
+            continue;
+        }
+        if server.send(message).await.is_err() {
+            break;
+        }
+
+        let Some(id) = message.get("id") else {
+            report.notifications += 1;
+            if method == "exit" {
+                break;
+            }
+            continue;
+        };
+        report.requests += 1;
+        let replayed = server.response(id, &client_answers).await;
+        if let Some(recorded) = server_responses.get(&id.to_string()) {
+            let outcome = |response: &Value| {
+                response
+                    .get("result")
+                    .or_else(|| response.get("error"))
+                    .cloned()
+                    .unwrap_or(Value::Null)
+            };
+            let replayed = replayed.as_ref().map(outcome);
+            if replayed.as_ref() != Some(&outcome(recorded)) {
+                report.divergences.push(Divergence {
+                    method: method.to_string(),
+                    id: id.clone(),
+                    recorded: outcome(recorded),
+                    replayed,
+                });
+            }
+        }
+        if server.task.is_finished() {
+            break;
+        }
+    }
+
+    report.panic = server.finish().await;
+    Ok(report)
+}
+
+/// Server running on the tasks of the current runtime, behind an in-memory
+/// pipe
+struct InProcessServer {
+    writer: WriteHalf<DuplexStream>,
+    incoming: mpsc::UnboundedReceiver<Value>,
+    task: JoinHandle<()>,
+}
+
+impl InProcessServer {
+    /// Start a server, recording its session if a recorder is given
+    fn start(recorder: Option<Arc<SessionRecorder>>) -> Self {
+        let (service, socket) = LspService::build(LspBackend::new)
+            .custom_method(STATS_METHOD, LspBackend::stats)
+            .custom_method(FOCUS_DOCUMENT_METHOD, LspBackend::focus_document)
+            .finish();
+        let (client, server) = tokio::io::duplex(PIPE_CAPACITY);
+        let (server_read, server_write) = tokio::io::split(server);
+        let task = match recorder {
+            Some(recorder) => tokio::spawn(
+                Server::new(
+                    Recorded::new(server_read, Direction::In, recorder.clone()),
+                    Recorded::new(server_write, Direction::Out, recorder),
+                    socket,
+                )
+                .serve(service),
+            ),
+            None => tokio::spawn(Server::new(server_read, server_write, socket).serve(service)),
+        };
+
+        let (client_read, writer) = tokio::io::split(client);
+        let (sender, incoming) = mpsc::unbounded_channel();
+        tokio::spawn(read_frames(client_read, sender));
+
+        Self {
+            writer,
+            incoming,
+            task,
+        }
+    }
+
+    /// Send a framed message
+    async fn send(&mut self, message: &Value) -> io::Result<()> {
+        let body = message.to_string();
+        self.writer
+            .write_all(format!("Content-Length: {}\r\n\r\n{}", body.len(), body).as_bytes())
+            .await?;
+        self.writer.flush().await
+    }
+
+    /// Wait for the response to a request
+    ///
+    /// Requests of the server are answered with `answers` (keyed by id), or
+    /// `null`; notifications are skipped.
+    ///
+    /// # Returns
+    ///
+    /// The response, or `None` if the server does not answer in time
+    async fn response(&mut self, id: &Value, answers: &HashMap<String, Value>) -> Option<Value> {
+        let deadline = tokio::time::Instant::now() + REPLAY_TIMEOUT;
+        loop {
+            let message = tokio::time::timeout_at(deadline, self.incoming.recv())
+                .await
+                .ok()??;
+            match (message.get("id"), message.get("method")) {
+                (Some(request), Some(_)) => {
+                    let answer = answers.get(&request.to_string()).cloned().unwrap_or_else(
+                        || json!({ "jsonrpc": "2.0", "id": request, "result": null }),
+                    );
+                    self.send(&answer).await.ok()?;
+                }
+                (Some(response), None) if response == id => return Some(message),
+                _ => {}
+            }
+        }
+    }
+
+    /// Close the connection and wait for the server to stop
+    ///
+    /// # Returns
+    ///
+    /// The panic message of the server, if it panicked
+    async fn finish(mut self) -> Option<String> {
+        let _ = self.writer.shutdown().await;
+        let result = match tokio::time::timeout(REPLAY_TIMEOUT, &mut self.task).await {
+            Ok(result) => result,
+            Err(_) => {
+                self.task.abort();
+                return None;
+            }
+        };
+        let panic = result.err()?.try_into_panic().ok()?;
+        Some(
+            panic
+                .downcast_ref::<String>()
+                .cloned()
+                .or_else(|| panic.downcast_ref::<&str>().map(|s| s.to_string()))
+                .unwrap_or_else(|| "server panicked".to_string()),
+        )
+    }
+}
+
+/// Read the messages of the server until the pipe closes
+async fn read_frames(mut reader: ReadHalf<DuplexStream>, sender: mpsc::UnboundedSender<Value>) {
+    let mut decoder = FrameDecoder::default();
+    let mut buffer = vec![0; 8192];
+    while let Ok(read) = reader.read(&mut buffer).await {
+        if read == 0 {
+            break;
+        }
+        for message in decoder.push(&buffer[..read]) {
+            if sender.send(message).is_err() {
+                return;
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn frame(message: &Value) -> Vec<u8> {
+        let body = message.to_string();
+        format!("Content-Length: {}\r\n\r\n{}", body.len(), body).into_bytes()
+    }
+
+    fn request(id: i64, method: &str, params: Value) -> Value {
+        let mut message = notification(method, params);
+        message["id"] = json!(id);
+        message
+    }
+
+    fn notification(method: &str, params: Value) -> Value {
+        json!({ "jsonrpc": "2.0", "method": method, "params": params })
+    }
+
+    fn temp_dir(name: &str) -> PathBuf {
+        let dir = std::env::temp_dir().join(format!(
+            "unified-sql-lsp-recording-{}-{}",
+            name,
+            std::process::id()
+        ));
+        let _ = std::fs::remove_dir_all(&dir);
+        dir
+    }
+
+    #[test]
+    fn test_frames_split_across_reads() {
+        let first = request(1, "shutdown", Value::Null);
+        let second = notification("exit", Value::Null);
+        let mut bytes = frame(&first);
+        bytes.extend(frame(&second));
+
+        let mut decoder = FrameDecoder::default();
+        let (head, tail) = bytes.split_at(10);
+        assert!(decoder.push(head).is_empty());
+        let (middle, tail) = tail.split_at(tail.len() - 3);
+        assert_eq!(decoder.push(middle), vec![first]);
+        assert_eq!(decoder.push(tail), vec![second]);
+    }
+
+    #[test]
+    fn test_redaction_and_rotation() {
+        let dir = temp_dir("rotation");
+        let recorder = SessionRecorder::create(RecordConfig {
+            max_file_bytes: 200,
+            redact_documents: true,
+            ..RecordConfig::new(&dir)
+        })
+        .unwrap();
+        let first = recorder.path();
+
+        let open = json!({
+            "method": "textDocument/didOpen",
+            "params": { "textDocument": { "uri": "file:///a.sql", "text": "SELECT 1\nFROM t" } }
+        });
+        let settings = json!({
+            "method": "workspace/didChangeConfiguration",
+            "params": { "settings": { "connectionString": "mysql://app:hunter2@db/shop" } }
+        });
+        recorder.record(Direction::In, &open);
+        recorder.record(Direction::In, &settings);
+
+        let recorded = std::fs::read_to_string(&first).unwrap();
+        assert!(
+            recorded.contains(r#""text":"xxxxxxxx\nxxxxxx""#),
+            "{}",
+            recorded
+        );
+        assert_ne!(recorder.path(), first);
+        let continued = std::fs::read_to_string(recorder.path()).unwrap();
+        assert!(!continued.contains("hunter2"), "{}", continued);
+
+        std::fs::remove_dir_all(&dir).unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_recorded_session_replays() {
+        let dir = temp_dir("replay");
+        let recorder = Arc::new(SessionRecorder::create(RecordConfig::new(&dir)).unwrap());
+        let uri = "file:///session/query.sql";
+
+        let mut server = InProcessServer::start(Some(recorder.clone()));
+        let no_answers = HashMap::new();
+
+        let messages = [
+            request(1, "initialize", json!({ "capabilities": {} })),
+            notification("initialized", json!({})),
+            notification(
+                "textDocument/didOpen",
+                json!({ "textDocument": {
+                    "uri": uri, "languageId": "mysql", "version": 1, "text": "SELECT 1 FROM"
+                } }),
+            ),
+            request(
+                2,
+                "textDocument/hover",
+                json!({
+                    "textDocument": { "uri": uri },
+                    "position": { "line": 0, "character": 1 }
+                }),
+            ),
+            request(3, "shutdown", Value::Null),
+            notification("exit", Value::Null),
+        ];
+        for message in &messages {
+            server.send(message).await.unwrap();
+            if let Some(id) = message.get("id") {
+                assert!(server.response(id, &no_answers).await.is_some());
+            }
+        }
+        assert_eq!(server.finish().await, None);
+
+        let report = replay(&recorder.path()).await.unwrap();
+        assert_eq!(report.requests, 3);
+        assert_eq!(report.notifications, 3);
+        assert!(report.is_success(), "{:?}", report);
+
+        std::fs::remove_dir_all(&dir).unwrap();
+    }
+}
@@ -0,0 +1,439 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Request logging
+//!
+//! This module provides per-request logging for LSP handlers.
+//!
+//! Every tracked request gets a monotonically increasing request ID and runs
+//! inside a `lsp_request` tracing span carrying the ID, method and document
+//! URI, so logs emitted by the completion engine, catalog, etc. during that
+//! request are correlated. When the request finishes, its duration is logged
+//! at debug level; requests exceeding the slow-request threshold are logged
+//! at warn level.
+//!
+//! Request budgets bound how long a handler may take before it answers with
+//! the results it has; see [`RequestBudgets`].
+//!
+//! The logger also keeps per-method statistics (call count, slow count and
+//! p50/p95/max latency over the most recent requests), served to clients by
+//! the `sql/stats` request; see [`RequestLogger::stats`].
+//!
+//! ## Example
+//!
+//! ```rust,ignore
+//! let logger = RequestLogger::new(Duration::from_millis(500));
+//! let items = logger
+//!     .track("textDocument/completion", &uri, async { engine.complete(&doc, pos).await })
+//!     .await;
+//! ```
+
+use serde::Serialize;
+use serde_json::Value;
+use std::collections::{BTreeMap, HashMap};
+use std::future::Future;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Arc, Mutex, RwLock};
+use std::time::{Duration, Instant};
+use tower_lsp::lsp_types::Url;
+use tracing::{Instrument, debug, info_span, warn};
+
+/// Default slow-request threshold in milliseconds
+pub const DEFAULT_SLOW_REQUEST_THRESHOLD_MS: u64 = 500;
+
+/// Default completion budget in milliseconds
+///
+/// Editors stop waiting for completions after about 300ms.
+pub const DEFAULT_COMPLETION_BUDGET_MS: u64 = 250;
+
+/// Number of recent request durations kept per method for percentiles
+const LATENCY_SAMPLES: usize = 256;
+
+/// Time budgets of request handlers
+///
+/// A handler exceeding its budget answers with the results that do not
+/// depend on the slow work, marked incomplete where the protocol allows it.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct RequestBudgets {
+    /// Budget of `textDocument/completion`
+    pub completion: Duration,
+}
+
+impl Default for RequestBudgets {
+    fn default() -> Self {
+        Self {
+            completion: Duration::from_millis(DEFAULT_COMPLETION_BUDGET_MS),
+        }
+    }
+}
+
+impl RequestBudgets {
+    /// Parse budgets from the `requestBudgetsMs` settings object, keyed by
+    /// method (e.g. `{ "completion": 250 }`)
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut budgets = Self::default();
+
+        if let Some(completion) = settings.get("completion").and_then(Value::as_u64) {
+            budgets.completion = Duration::from_millis(completion);
+        }
+
+        budgets
+    }
+}
+
+/// Per-request logger
+///
+/// Assigns request IDs and reports request durations.
+#[derive(Debug)]
+pub struct RequestLogger {
+    /// Next request ID to assign
+    next_id: AtomicU64,
+
+    /// Slow-request threshold in milliseconds
+    slow_threshold_ms: AtomicU64,
+
+    /// Statistics per LSP method
+    stats: RwLock<HashMap<&'static str, Arc<MethodStats>>>,
+}
+
+impl RequestLogger {
+    /// Create a new request logger
+    ///
+    /// # Arguments
+    ///
+    /// * `slow_threshold` - Requests taking at least this long are logged at warn level
+    pub fn new(slow_threshold: Duration) -> Self {
+        Self {
+            next_id: AtomicU64::new(1),
+            slow_threshold_ms: AtomicU64::new(slow_threshold.as_millis() as u64),
+            stats: RwLock::new(HashMap::new()),
+        }
+    }
+
+    /// Update the slow-request threshold
+    pub fn set_slow_threshold(&self, slow_threshold: Duration) {
+        self.slow_threshold_ms
+            .store(slow_threshold.as_millis() as u64, Ordering::Relaxed);
+    }
+
+    /// Get the current slow-request threshold
+    pub fn slow_threshold(&self) -> Duration {
+        Duration::from_millis(self.slow_threshold_ms.load(Ordering::Relaxed))
+    }
+
+    /// Run a request handler inside a correlated span and log its duration
+    ///
+    /// # Arguments
+    ///
+    /// * `method` - The LSP method name (e.g. `textDocument/completion`)
+    /// * `uri` - The document the request targets
+    /// * `handler` - The request handler future
+    ///
+    /// # Returns
+    ///
+    /// The output of `handler`
+    pub async fn track<F>(&self, method: &'static str, uri: &Url, handler: F) -> F::Output
+    where
+        F: Future,
+    {
+        let request_id = self.next_id.fetch_add(1, Ordering::Relaxed);
+        let span = info_span!("lsp_request", request_id, method, uri = %uri);
+
+        let start = Instant::now();
+        let output = handler.instrument(span.clone()).await;
+        let elapsed = start.elapsed();
+
+        let _entered = span.enter();
+        debug!(elapsed_ms = elapsed.as_millis() as u64, "Request completed");
+
+        let threshold = self.slow_threshold();
+        let slow = elapsed >= threshold;
+        if slow {
+            warn!(
+                elapsed_ms = elapsed.as_millis() as u64,
+                threshold_ms = threshold.as_millis() as u64,
+                "Slow request"
+            );
+        }
+        self.method_stats(method).record(elapsed, slow);
+
+        output
+    }
+
+    /// Get the statistics of every method tracked since the last reset
+    ///
+    /// # Returns
+    ///
+    /// The statistics keyed by method name
+    pub fn stats(&self) -> BTreeMap<&'static str, MethodStatsSnapshot> {
+        self.stats
+            .read()
+            .unwrap()
+            .iter()
+            .map(|(method, stats)| (*method, stats.snapshot()))
+            .collect()
+    }
+
+    /// Drop the statistics of every method
+    pub fn reset_stats(&self) {
+        self.stats.write().unwrap().clear();
+    }
+
+    /// Get the statistics of a method, creating them on first use
+    fn method_stats(&self, method: &'static str) -> Arc<MethodStats> {
+        if let Some(stats) = self.stats.read().unwrap().get(method) {
+            return stats.clone();
+        }
+        self.stats
+            .write()
+            .unwrap()
+            .entry(method)
+            .or_default()
+            .clone()
+    }
+}
+
+/// Statistics of one LSP method
+///
+/// Counters are atomic; durations go to a fixed-size ring buffer of the
+/// most recent requests, so recording never allocates.
+#[derive(Debug, Default)]
+struct MethodStats {
+    /// Number of requests
+    calls: AtomicU64,
+
+    /// Number of requests exceeding the slow-request threshold
+    slow: AtomicU64,
+
+    /// Durations of the most recent requests, in microseconds
+    samples: Mutex<LatencySamples>,
+}
+
+/// Ring buffer of request durations
+#[derive(Debug, Default)]
+struct LatencySamples {
+    values: Vec<u64>,
+    next: usize,
+}
+
+impl MethodStats {
+    fn record(&self, elapsed: Duration, slow: bool) {
+        self.calls.fetch_add(1, Ordering::Relaxed);
+        if slow {
+            self.slow.fetch_add(1, Ordering::Relaxed);
+        }
+
+        let micros = elapsed.as_micros() as u64;
+        let mut samples = self.samples.lock().unwrap();
+        if samples.values.len() < LATENCY_SAMPLES {
+            samples.values.push(micros);
+        } else {
+            let next = samples.next;
+            samples.values[next] = micros;
+        }
+        samples.next = (samples.next + 1) % LATENCY_SAMPLES;
+    }
+
+    fn snapshot(&self) -> MethodStatsSnapshot {
+        let mut values = self.samples.lock().unwrap().values.clone();
+        values.sort_unstable();
+
+        let percentile = |p: usize| {
+            values
+                .get((values.len() * p / 100).min(values.len().saturating_sub(1)))
+                .map_or(0.0, |&micros| micros as f64 / 1000.0)
+        };
+
+        MethodStatsSnapshot {
+            calls: self.calls.load(Ordering::Relaxed),
+            slow: self.slow.load(Ordering::Relaxed),
+            p50_ms: percentile(50),
+            p95_ms: percentile(95),
+            max_ms: values.last().map_or(0.0, |&micros| micros as f64 / 1000.0),
+        }
+    }
+}
+
+/// Statistics of one LSP method, as reported by `sql/stats`
+///
+/// Latencies are computed over the most recent requests only.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct MethodStatsSnapshot {
+    /// Number of requests since the last reset
+    pub calls: u64,
+
+    /// Number of requests exceeding the slow-request threshold
+    pub slow: u64,
+
+    /// Median duration in milliseconds
+    pub p50_ms: f64,
+
+    /// 95th percentile duration in milliseconds
+    pub p95_ms: f64,
+
+    /// Longest recent duration in milliseconds
+    pub max_ms: f64,
+}
+
+impl Default for RequestLogger {
+    fn default() -> Self {
+        Self::new(Duration::from_millis(DEFAULT_SLOW_REQUEST_THRESHOLD_MS))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::io::Write;
+    use tracing_subscriber::fmt::MakeWriter;
+
+    /// Writer capturing formatted log output
+    #[derive(Clone, Default)]
+    struct CapturedLogs(Arc<Mutex<Vec<u8>>>);
+
+    impl CapturedLogs {
+        fn contents(&self) -> String {
+            String::from_utf8(self.0.lock().unwrap().clone()).unwrap()
+        }
+    }
+
+    impl Write for CapturedLogs {
+        fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+            self.0.lock().unwrap().extend_from_slice(buf);
+            Ok(buf.len())
+        }
+
+        fn flush(&mut self) -> std::io::Result<()> {
+            Ok(())
+        }
+    }
+
+    impl<'a> MakeWriter<'a> for CapturedLogs {
+        type Writer = CapturedLogs;
+
+        fn make_writer(&'a self) -> Self::Writer {
+            self.clone()
+        }
+    }
+
+    async fn run_tracked(logger: &RequestLogger) -> String {
+        let logs = CapturedLogs::default();
+        let subscriber = tracing_subscriber::fmt()
+            .with_writer(logs.clone())
+            .with_max_level(tracing::Level::DEBUG)
+            .with_ansi(false)
+            .finish();
+        let _guard = tracing::subscriber::set_default(subscriber);
+
+        let uri = Url::parse("file:///test.sql").unwrap();
+        let value = logger
+            .track("textDocument/completion", &uri, async {
+                std::thread::sleep(Duration::from_millis(5));
+                42
+            })
+            .await;
+        assert_eq!(value, 42);
+
+        logs.contents()
+    }
+
+    #[tokio::test(flavor = "current_thread")]
+    async fn test_slow_request_logged() {
+        let logger = RequestLogger::new(Duration::from_millis(1));
+        let logs = run_tracked(&logger).await;
+
+        assert!(logs.contains("Slow request"), "logs: {}", logs);
+        assert!(logs.contains("textDocument/completion"), "logs: {}", logs);
+        assert!(logs.contains("request_id=1"), "logs: {}", logs);
+    }
+
+    #[tokio::test(flavor = "current_thread")]
+    async fn test_fast_request_not_logged_as_slow() {
+        let logger = RequestLogger::new(Duration::from_secs(60));
+        let logs = run_tracked(&logger).await;
+
+        assert!(logs.contains("Request completed"), "logs: {}", logs);
+        assert!(!logs.contains("Slow request"), "logs: {}", logs);
+    }
+
+    #[test]
+    fn test_set_slow_threshold() {
+        let logger = RequestLogger::default();
+        assert_eq!(
+            logger.slow_threshold(),
+            Duration::from_millis(DEFAULT_SLOW_REQUEST_THRESHOLD_MS)
+        );
+
+        logger.set_slow_threshold(Duration::from_millis(10));
+        assert_eq!(logger.slow_threshold(), Duration::from_millis(10));
+    }
+
+    #[tokio::test(flavor = "multi_thread", worker_threads = 4)]
+    async fn test_concurrent_recording() {
+        let logger = Arc::new(RequestLogger::new(Duration::from_secs(60)));
+        let uri = Url::parse("file:///test.sql").unwrap();
+
+        let tasks: Vec<_> = (0..8)
+            .map(|_| {
+                let logger = logger.clone();
+                let uri = uri.clone();
+                tokio::spawn(async move {
+                    for _ in 0..100 {
+                        logger.track("textDocument/hover", &uri, async {}).await;
+                    }
+                })
+            })
+            .collect();
+        for task in tasks {
+            task.await.unwrap();
+        }
+
+        let stats = logger.stats();
+        assert_eq!(stats["textDocument/hover"].calls, 800);
+        assert_eq!(stats["textDocument/hover"].slow, 0);
+    }
+
+    #[tokio::test(flavor = "current_thread")]
+    async fn test_stats_json_shape_and_reset() {
+        let logger = RequestLogger::new(Duration::from_millis(1));
+        run_tracked(&logger).await;
+
+        let json = serde_json::to_value(logger.stats()).unwrap();
+        let completion = &json["textDocument/completion"];
+        assert_eq!(completion["calls"], 1);
+        assert_eq!(completion["slow"], 1);
+        assert!(completion["p50Ms"].as_f64().unwrap() >= 5.0);
+        assert_eq!(completion["p95Ms"], completion["maxMs"]);
+
+        logger.reset_stats();
+        assert!(logger.stats().is_empty());
+    }
+
+    #[test]
+    fn test_latency_samples_wrap() {
+        let stats = MethodStats::default();
+        for millis in 0..(LATENCY_SAMPLES as u64 + 10) {
+            stats.record(Duration::from_millis(millis), false);
+        }
+
+        let snapshot = stats.snapshot();
+        assert_eq!(snapshot.calls, LATENCY_SAMPLES as u64 + 10);
+        assert_eq!(stats.samples.lock().unwrap().values.len(), LATENCY_SAMPLES);
+        assert_eq!(snapshot.max_ms, (LATENCY_SAMPLES + 9) as f64);
+    }
+
+    #[test]
+    fn test_request_budgets_from_settings() {
+        assert_eq!(
+            RequestBudgets::from_settings(&serde_json::json!({})),
+            RequestBudgets::default()
+        );
+        assert_eq!(
+            RequestBudgets::from_settings(&serde_json::json!({ "completion": 100 })).completion,
+            Duration::from_millis(100)
+        );
+    }
+}
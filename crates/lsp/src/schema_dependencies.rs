@@ -0,0 +1,356 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Schema dependencies
+//!
+//! This module extracts which tables and views each view and user-defined
+//! function of a schema reads, for the call hierarchy:
+//!
+//! ```text
+//! orders ◄── big_orders ◄── top_customers      (views read what they point to)
+//!    ▲
+//!    └────── order_count()                     (function body reads orders)
+//! ```
+//!
+//! View queries and function bodies come from introspection
+//! ([`TableMetadata::definition`] and [`FunctionMetadata::definition`]). They
+//! are parsed with the dialect's grammar and the tables they reference are
+//! collected like the references of a document; CTEs and subqueries are not
+//! dependencies. Definitions the grammar cannot read fully, such as
+//! procedural code or the schema-qualified queries MySQL reports for views,
+//! also yield the names following `FROM` and `JOIN`.
+//!
+//! Cached schemas keep their dependencies (see
+//! [`SchemaCache::dependencies`](crate::schema_cache::SchemaCache::dependencies)),
+//! so definitions are parsed once per loaded schema.
+
+use serde::{Deserialize, Serialize};
+use unified_sql_lsp_catalog::{FunctionMetadata, TableMetadata, TableType};
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_semantic::SchemaDiagnosticAnalyzer;
+
+use crate::migrations::{TokenKind, tokenize};
+use crate::parsing::{ParseResult, ParserManager};
+
+/// Kind of a schema object
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum SchemaObjectKind {
+    Table,
+    /// View or materialized view
+    View,
+    /// User-defined function
+    Function,
+}
+
+impl SchemaObjectKind {
+    /// Lowercase name of the kind
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            SchemaObjectKind::Table => "table",
+            SchemaObjectKind::View => "view",
+            SchemaObjectKind::Function => "function",
+        }
+    }
+}
+
+/// A table, view or user-defined function of a schema
+#[derive(Debug, Clone, PartialEq, Eq, Hash, Serialize, Deserialize)]
+pub struct SchemaObject {
+    pub kind: SchemaObjectKind,
+    /// Schema of tables and views; empty for functions, which are not
+    /// introspected with their schema
+    pub schema: String,
+    pub name: String,
+}
+
+impl SchemaObject {
+    /// Name qualified with the schema, if it has one
+    pub fn qualified_name(&self) -> String {
+        if self.schema.is_empty() {
+            self.name.clone()
+        } else {
+            format!("{}.{}", self.schema, self.name)
+        }
+    }
+}
+
+/// Objects of a schema and the tables and views each one reads
+#[derive(Debug, Clone, Default)]
+pub struct SchemaDependencies {
+    objects: Vec<SchemaObject>,
+    /// Indexes of the objects read by `objects[i]`, in definition order
+    reads: Vec<Vec<usize>>,
+}
+
+impl SchemaDependencies {
+    /// Extract the dependencies of a schema's views and functions
+    ///
+    /// # Arguments
+    ///
+    /// * `dialect` - Dialect the definitions are written in
+    /// * `tables` - Tables and views; views carry their query
+    /// * `functions` - Functions; user-defined functions carry their body
+    pub fn build(
+        dialect: Dialect,
+        tables: &[TableMetadata],
+        functions: &[FunctionMetadata],
+    ) -> Self {
+        let mut objects: Vec<SchemaObject> = tables
+            .iter()
+            .map(|table| SchemaObject {
+                kind: match table.table_type {
+                    TableType::View | TableType::MaterializedView => SchemaObjectKind::View,
+                    _ => SchemaObjectKind::Table,
+                },
+                schema: table.schema.clone(),
+                name: table.name.clone(),
+            })
+            .collect();
+        let mut definitions: Vec<Option<&str>> = tables
+            .iter()
+            .map(|table| table.definition.as_deref())
+            .collect();
+
+        // Built-in functions have no body and no dependencies
+        for function in functions {
+            let Some(definition) = function.definition.as_deref() else {
+                continue;
+            };
+            objects.push(SchemaObject {
+                kind: SchemaObjectKind::Function,
+                schema: String::new(),
+                name: function.name.clone(),
+            });
+            definitions.push(Some(definition));
+        }
+
+        let mut dependencies = Self {
+            reads: vec![Vec::new(); objects.len()],
+            objects,
+        };
+        let parser = ParserManager::new();
+        let analyzer = SchemaDiagnosticAnalyzer::new();
+        for (index, definition) in definitions.into_iter().enumerate() {
+            let Some(definition) = definition else {
+                continue;
+            };
+            let parsed = parser.parse_text(dialect, definition);
+            let mut tables: Vec<String> = parsed
+                .tree()
+                .map(|tree| {
+                    let references = analyzer.collect_references(tree, definition);
+                    references.tables().map(|table| table.name).collect()
+                })
+                .unwrap_or_default();
+            if !matches!(parsed, ParseResult::Success { .. }) {
+                tables.extend(lexical_tables(definition));
+            }
+
+            let mut reads = Vec::new();
+            for table in tables {
+                let schema = &dependencies.objects[index].schema;
+                if let Some(read) = dependencies.resolve(schema, &table)
+                    && read != index
+                    && !reads.contains(&read)
+                {
+                    reads.push(read);
+                }
+            }
+            dependencies.reads[index] = reads;
+        }
+        dependencies
+    }
+
+    /// Find a table, view or function by name
+    ///
+    /// # Arguments
+    ///
+    /// * `name` - Name, optionally qualified with the schema (`schema.name`)
+    ///
+    /// # Returns
+    ///
+    /// The matching object, tables and views taking precedence over functions
+    pub fn find(&self, name: &str) -> Option<&SchemaObject> {
+        self.resolve("", name)
+            .or_else(|| {
+                self.objects.iter().position(|object| {
+                    object.kind == SchemaObjectKind::Function
+                        && object.name.eq_ignore_ascii_case(name)
+                })
+            })
+            .map(|index| &self.objects[index])
+    }
+
+    /// Tables and views an object reads (outgoing calls)
+    pub fn reads(&self, object: &SchemaObject) -> Vec<&SchemaObject> {
+        self.index_of(object)
+            .map(|index| {
+                self.reads[index]
+                    .iter()
+                    .map(|&read| &self.objects[read])
+                    .collect()
+            })
+            .unwrap_or_default()
+    }
+
+    /// Views and functions that read an object (incoming calls)
+    pub fn readers(&self, object: &SchemaObject) -> Vec<&SchemaObject> {
+        let Some(index) = self.index_of(object) else {
+            return Vec::new();
+        };
+        self.reads
+            .iter()
+            .enumerate()
+            .filter(|(_, reads)| reads.contains(&index))
+            .map(|(reader, _)| &self.objects[reader])
+            .collect()
+    }
+
+    fn index_of(&self, object: &SchemaObject) -> Option<usize> {
+        self.objects.iter().position(|other| other == object)
+    }
+
+    /// Table or view named by a reference, preferring the schema of the
+    /// referencing object for unqualified names
+    fn resolve(&self, default_schema: &str, reference: &str) -> Option<usize> {
+        // `name`, `schema.name` or `database.schema.name`
+        let mut parts = reference.rsplit('.');
+        let name = parts.next()?;
+        let schema = parts.next();
+        let relations = || {
+            self.objects.iter().enumerate().filter(move |(_, object)| {
+                object.kind != SchemaObjectKind::Function && object.name.eq_ignore_ascii_case(name)
+            })
+        };
+
+        match schema {
+            Some(schema) => relations()
+                .find(|(_, object)| object.schema.eq_ignore_ascii_case(schema))
+                .map(|(index, _)| index),
+            None => relations()
+                .find(|(_, object)| object.schema.eq_ignore_ascii_case(default_schema))
+                .or_else(|| relations().next())
+                .map(|(index, _)| index),
+        }
+    }
+}
+
+/// Names following `FROM` and `JOIN`, qualified names joined with dots
+fn lexical_tables(definition: &str) -> Vec<String> {
+    let tokens = tokenize(definition);
+    let mut tables = Vec::new();
+    for (index, token) in tokens.iter().enumerate() {
+        if !token.is_keyword("FROM") && !token.is_keyword("JOIN") {
+            continue;
+        }
+
+        let mut parts = Vec::new();
+        let mut rest = tokens[index + 1..].iter();
+        while let Some(part) = rest.next().filter(|part| part.is_identifier()) {
+            parts.push(part.text.as_str());
+            if !rest
+                .next()
+                .is_some_and(|dot| dot.kind == TokenKind::Symbol('.'))
+            {
+                break;
+            }
+        }
+        if !parts.is_empty() {
+            tables.push(parts.join("."));
+        }
+    }
+    tables
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::DataType;
+
+    /// `orders` ◄ `big_orders` ◄ `top_customers`, and `order_count()` reading
+    /// `orders`
+    fn view_chain() -> SchemaDependencies {
+        let tables = vec![
+            TableMetadata::new("customers", "shop"),
+            TableMetadata::new("orders", "shop"),
+            TableMetadata::new("big_orders", "shop")
+                .with_type(TableType::View)
+                .with_definition("SELECT id, customer_id\n  FROM orders\n  WHERE (total > 100);"),
+            TableMetadata::new("top_customers", "shop")
+                .with_type(TableType::View)
+                .with_definition(
+                    "SELECT c.name FROM customers c JOIN big_orders b ON b.customer_id = c.id",
+                ),
+        ];
+        let functions = vec![
+            FunctionMetadata::new("count", DataType::BigInt),
+            FunctionMetadata::new("order_count", DataType::BigInt)
+                .with_definition("SELECT count(*) FROM orders"),
+        ];
+        SchemaDependencies::build(Dialect::PostgreSQL, &tables, &functions)
+    }
+
+    fn names(objects: Vec<&SchemaObject>) -> Vec<&str> {
+        objects.iter().map(|object| object.name.as_str()).collect()
+    }
+
+    #[test]
+    fn test_views_read_through_the_chain() {
+        let dependencies = view_chain();
+
+        let top = dependencies.find("top_customers").unwrap();
+        assert_eq!(top.kind, SchemaObjectKind::View);
+        assert_eq!(
+            names(dependencies.reads(top)),
+            vec!["customers", "big_orders"]
+        );
+
+        let big = dependencies.find("shop.big_orders").unwrap();
+        assert_eq!(names(dependencies.reads(big)), vec!["orders"]);
+        assert_eq!(names(dependencies.readers(big)), vec!["top_customers"]);
+    }
+
+    #[test]
+    fn test_table_readers_include_functions() {
+        let dependencies = view_chain();
+
+        let orders = dependencies.find("ORDERS").unwrap();
+        assert_eq!(orders.kind, SchemaObjectKind::Table);
+        assert_eq!(
+            names(dependencies.readers(orders)),
+            vec!["big_orders", "order_count"]
+        );
+        assert!(dependencies.reads(orders).is_empty());
+
+        let function = dependencies.find("order_count").unwrap();
+        assert_eq!(function.kind, SchemaObjectKind::Function);
+        assert_eq!(function.qualified_name(), "order_count");
+
+        // Built-in functions have no body to read
+        assert!(dependencies.find("count").is_none());
+    }
+
+    #[test]
+    fn test_qualified_definitions_outside_the_grammar() {
+        // MySQL reports view queries with every name qualified
+        let tables = vec![
+            TableMetadata::new("orders", "shop"),
+            TableMetadata::new("orders", "archive"),
+            TableMetadata::new("big_orders", "shop")
+                .with_type(TableType::View)
+                .with_definition(
+                    "select `shop`.`orders`.`id` AS `id` from `shop`.`orders` \
+                     where (`shop`.`orders`.`total` > 100)",
+                ),
+        ];
+        let dependencies = SchemaDependencies::build(Dialect::MySQL, &tables, &[]);
+
+        let big = dependencies.find("big_orders").unwrap();
+        let reads = dependencies.reads(big);
+        assert_eq!(reads.len(), 1);
+        assert_eq!(reads[0].qualified_name(), "shop.orders");
+    }
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Schema search path
+//!
+//! This module resolves unqualified table names the way the database does:
+//! by walking the schemas of the connection's search path in order.
+//!
+//! ```text,ignore
+//! search_path = app, public
+//! SELECT * FROM users     → app.users if it exists, otherwise public.users
+//! ```
+//!
+//! The path is the `searchPath` setting when configured. Otherwise it is the
+//! `search_path` of PostgreSQL connections (`SHOW search_path`, with
+//! `"$user"` expanded), and the current database of MySQL connections.
+//!
+//! [`SearchPathCatalog`] lists tables in path order, so a table listed after
+//! one of the same name is hidden from unqualified references: it is
+//! *shadowed*, and completion inserts it qualified.
+
+use async_trait::async_trait;
+use std::sync::Arc;
+use tower_lsp::lsp_types::Url;
+use unified_sql_lsp_catalog::{
+    Catalog, CatalogError, CatalogResult, ColumnMetadata, FunctionMetadata, TableMetadata,
+    TableVersion,
+};
+
+/// Placeholder of the session user in PostgreSQL search paths
+const USER_PLACEHOLDER: &str = "$user";
+
+/// Schemas searched for unqualified table names, in order
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct SearchPath {
+    schemas: Vec<String>,
+}
+
+impl SearchPath {
+    /// Create a search path from schema names
+    pub fn new<S: Into<String>>(schemas: impl IntoIterator<Item = S>) -> Self {
+        Self {
+            schemas: schemas
+                .into_iter()
+                .map(Into::into)
+                .filter(|schema| !schema.is_empty())
+                .collect(),
+        }
+    }
+
+    /// Parse the output of `SHOW search_path`
+    ///
+    /// # Arguments
+    ///
+    /// * `value` - Comma-separated schemas, possibly double-quoted
+    ///   (e.g. `"$user", public`)
+    /// * `user` - Session user replacing `"$user"`
+    pub fn parse(value: &str, user: &str) -> Self {
+        Self::new(
+            split_schemas(value)
+                .into_iter()
+                .map(|schema| match schema == USER_PLACEHOLDER {
+                    true => user.to_string(),
+                    false => schema,
+                }),
+        )
+    }
+
+    /// Search path of a MySQL connection: its current database
+    ///
+    /// # Returns
+    ///
+    /// `None` if the connection string names no database
+    pub fn from_mysql_connection(connection_string: &str) -> Option<Self> {
+        let url = Url::parse(connection_string).ok()?;
+        let database = url.path().trim_start_matches('/');
+        (!database.is_empty()).then(|| Self::new([database]))
+    }
+
+    /// Schemas of the path, in order
+    pub fn schemas(&self) -> &[String] {
+        &self.schemas
+    }
+
+    /// Check whether the path has no schema
+    pub fn is_empty(&self) -> bool {
+        self.schemas.is_empty()
+    }
+
+    /// Position of a schema on the path, ignoring ASCII case
+    pub fn position(&self, schema: &str) -> Option<usize> {
+        self.schemas
+            .iter()
+            .position(|candidate| candidate.eq_ignore_ascii_case(schema))
+    }
+}
+
+/// Split a comma-separated list of schemas, unquoting quoted ones
+fn split_schemas(value: &str) -> Vec<String> {
+    let mut schemas = Vec::new();
+    let mut current = String::new();
+    let mut quoted = false;
+    let mut chars = value.chars().peekable();
+
+    while let Some(c) = chars.next() {
+        match c {
+            // A doubled quote is an escaped quote
+            '"' if quoted && chars.peek() == Some(&'"') => {
+                current.push('"');
+                chars.next();
+            }
+            '"' => quoted = !quoted,
+            ',' if !quoted => schemas.push(std::mem::take(&mut current).trim().to_string()),
+            c => current.push(c),
+        }
+    }
+    schemas.push(current.trim().to_string());
+    schemas
+}
+
+/// Catalog resolving unqualified table names along a search path
+///
+/// Tables are listed in path order, followed by the tables of schemas off
+/// the path. Qualified names are passed to the base catalog unchanged.
+pub struct SearchPathCatalog {
+    base: Arc<dyn Catalog>,
+    path: SearchPath,
+}
+
+impl SearchPathCatalog {
+    /// Create a catalog resolving names of a base catalog along a path
+    pub fn new(base: Arc<dyn Catalog>, path: SearchPath) -> Self {
+        Self { base, path }
+    }
+
+    /// The search path
+    pub fn path(&self) -> &SearchPath {
+        &self.path
+    }
+}
+
+#[async_trait]
+impl Catalog for SearchPathCatalog {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        let mut tables = self.base.list_tables().await?;
+        // Stable: tables of one schema keep the order of the base catalog
+        tables.sort_by_key(|table| match &table.catalog {
+            Some(_) => usize::MAX,
+            None => self.path.position(&table.schema).unwrap_or(usize::MAX - 1),
+        });
+        Ok(tables)
+    }
+
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        if table.contains('.') {
+            return self.base.get_columns(table).await;
+        }
+
+        for schema in self.path.schemas() {
+            // Live catalogs return no columns for a table that does not exist
+            match self
+                .base
+                .get_columns(&format!("{}.{}", schema, table))
+                .await
+            {
+                Ok(columns) if !columns.is_empty() => return Ok(columns),
+                Ok(_) | Err(CatalogError::TableNotFound(..)) => {}
+                Err(e) => return Err(e),
+            }
+        }
+        self.base.get_columns(table).await
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        self.base.list_functions().await
+    }
+
+    async fn table_versions(&self) -> CatalogResult<Option<Vec<TableVersion>>> {
+        self.base.table_versions().await
+    }
+}
+
+/// Find the table shadowing another on the search path
+///
+/// # Arguments
+///
+/// * `tables` - Tables in search path order, as listed by [`SearchPathCatalog`]
+/// * `index` - Position of the table in `tables`
+///
+/// # Returns
+///
+/// The first table listed before it with the same name, which unqualified
+/// references resolve to instead
+pub fn shadowing_table(tables: &[TableMetadata], index: usize) -> Option<&TableMetadata> {
+    let table = &tables[index];
+    if table.catalog.is_some() {
+        return None;
+    }
+    tables[..index]
+        .iter()
+        .find(|other| other.catalog.is_none() && other.name.eq_ignore_ascii_case(&table.name))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{DataType, SchemaSnapshot, SnapshotCatalog};
+
+    /// Two schemas with a `users` table each
+    fn catalog(path: &[&str]) -> SearchPathCatalog {
+        let table = |schema: &str, column: &str| {
+            TableMetadata::new("users", schema)
+                .with_columns(vec![ColumnMetadata::new(column, DataType::Integer)])
+        };
+        let snapshot = SchemaSnapshot::new(
+            vec![
+                table("public", "id"),
+                TableMetadata::new("orders", "public"),
+                table("app", "app_id"),
+            ],
+            vec![],
+        );
+        SearchPathCatalog::new(
+            Arc::new(SnapshotCatalog::new(snapshot)),
+            SearchPath::new(path.iter().copied()),
+        )
+    }
+
+    #[test]
+    fn test_parse_show_output() {
+        assert_eq!(
+            SearchPath::parse("\"$user\", public", "alice").schemas(),
+            ["alice", "public"]
+        );
+        assert_eq!(
+            SearchPath::parse("app,\"My, \"\"Schema\"\"\",public", "alice").schemas(),
+            ["app", "My, \"Schema\"", "public"]
+        );
+        assert!(SearchPath::parse("", "alice").is_empty());
+    }
+
+    #[test]
+    fn test_mysql_current_database() {
+        assert_eq!(
+            SearchPath::from_mysql_connection("mysql://app:secret@db:3306/shop"),
+            Some(SearchPath::new(["shop"]))
+        );
+        assert_eq!(SearchPath::from_mysql_connection("mysql://db:3306"), None);
+    }
+
+    #[tokio::test]
+    async fn test_unqualified_name_walks_the_path() {
+        let columns = catalog(&["app", "public"])
+            .get_columns("users")
+            .await
+            .unwrap();
+        assert_eq!(columns[0].name, "app_id");
+
+        let columns = catalog(&["public", "app"])
+            .get_columns("users")
+            .await
+            .unwrap();
+        assert_eq!(columns[0].name, "id");
+
+        // Qualified names and tables off the path resolve as before
+        let columns = catalog(&["app"]).get_columns("public.users").await.unwrap();
+        assert_eq!(columns[0].name, "id");
+        assert!(catalog(&["app"]).get_columns("orders").await.is_ok());
+        assert!(matches!(
+            catalog(&["app"]).get_columns("missing").await,
+            Err(CatalogError::TableNotFound(..))
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_tables_are_listed_in_path_order() {
+        let tables = catalog(&["app", "public"]).list_tables().await.unwrap();
+        let names: Vec<String> = tables.iter().map(TableMetadata::qualified_name).collect();
+        assert_eq!(names, ["app.users", "public.users", "public.orders"]);
+
+        assert!(shadowing_table(&tables, 0).is_none());
+        assert_eq!(
+            shadowing_table(&tables, 1).map(TableMetadata::qualified_name),
+            Some("app.users".to_string())
+        );
+        assert!(shadowing_table(&tables, 2).is_none());
+    }
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Selection ranges
+//!
+//! This module answers `textDocument/selectionRange`, which editors use to
+//! expand the selection structurally:
+//!
+//! ```text
+//! SELECT id FROM (SELECT user_id FROM orders WHERE total > 10) AS o
+//!                                                  ^
+//! total → total > 10 → WHERE ... → subquery → (...) AS o → FROM ... → statement
+//!       → document
+//! ```
+//!
+//! Ranges come from the parse tree: the node at the position and its
+//! ancestors. Documents without a parse tree get a lexical chain instead:
+//! the token, the parentheses around it, the statement and the document.
+//!
+//! Each range of a chain strictly contains the previous one, as clients
+//! require; ancestors spanning the same text as their child are skipped.
+
+use tower_lsp::lsp_types::{Position, Range, SelectionRange};
+
+use crate::migrations::{Token, TokenKind, tokenize};
+
+/// Compute the selection range chain of each position
+///
+/// # Arguments
+///
+/// * `tree` - Parse tree of the document, if it was parsed
+/// * `source` - Document content
+/// * `positions` - Positions of the request
+///
+/// # Returns
+///
+/// One chain per position, in the order of the positions
+pub fn selection_ranges(
+    tree: Option<&tree_sitter::Tree>,
+    source: &str,
+    positions: &[Position],
+) -> Vec<SelectionRange> {
+    // Tokens are only needed without a tree, and once for every position
+    let tokens = match tree {
+        Some(_) => Vec::new(),
+        None => tokenize(source),
+    };
+
+    positions
+        .iter()
+        .map(|&position| {
+            let mut ranges = match tree {
+                Some(tree) => tree_ranges(tree, position),
+                None => lexical_ranges(&tokens, position),
+            };
+            ranges.push(document_range(source));
+            nest(position, ranges)
+        })
+        .collect()
+}
+
+/// Ranges of the node at a position and of its ancestors, innermost first
+fn tree_ranges(tree: &tree_sitter::Tree, position: Position) -> Vec<Range> {
+    let point = tree_sitter::Point {
+        row: position.line as usize,
+        column: position.character as usize,
+    };
+    let mut node = tree.root_node().descendant_for_point_range(point, point);
+
+    let mut ranges = Vec::new();
+    while let Some(current) = node {
+        let start = current.start_position();
+        let end = current.end_position();
+        ranges.push(Range::new(
+            Position::new(start.row as u32, start.column as u32),
+            Position::new(end.row as u32, end.column as u32),
+        ));
+        node = current.parent();
+    }
+    ranges
+}
+
+/// Ranges of the token at a position, the parentheses around it and its
+/// statement, innermost first
+fn lexical_ranges(tokens: &[Token], position: Position) -> Vec<Range> {
+    // Between two adjacent tokens, the cursor is at the start of the second
+    let mut ranges: Vec<Range> = tokens
+        .iter()
+        .rev()
+        .find(|token| contains(&token.range, position))
+        .map(|token| token.range)
+        .into_iter()
+        .collect();
+
+    // Parenthesized groups containing the position, closed inner ones first
+    let mut open = Vec::new();
+    for token in tokens {
+        match token.kind {
+            TokenKind::Symbol('(') => open.push(token.range.start),
+            TokenKind::Symbol(')') => {
+                if let Some(start) = open.pop() {
+                    let group = Range::new(start, token.range.end);
+                    if contains(&group, position) {
+                        ranges.push(group);
+                    }
+                }
+            }
+            _ => {}
+        }
+    }
+
+    let statement = tokens
+        .split(|token| token.kind == TokenKind::Symbol(';'))
+        .filter_map(|statement| {
+            Some(Range::new(
+                statement.first()?.range.start,
+                statement.last()?.range.end,
+            ))
+        })
+        .find(|range| contains(range, position));
+    ranges.extend(statement);
+
+    ranges
+}
+
+/// Range of the whole document
+fn document_range(source: &str) -> Range {
+    let line = source.matches('\n').count() as u32;
+    let last_line = source.rsplit('\n').next().unwrap_or_default();
+    Range::new(
+        Position::new(0, 0),
+        Position::new(line, last_line.encode_utf16().count() as u32),
+    )
+}
+
+/// Link ranges into a chain, keeping each range that strictly contains the
+/// previous kept one
+fn nest(position: Position, ranges: Vec<Range>) -> SelectionRange {
+    let mut kept: Vec<Range> = Vec::new();
+    for range in ranges {
+        let encloses = match kept.last() {
+            Some(last) => range != *last && contains_range(&range, last),
+            None => contains(&range, position),
+        };
+        if encloses {
+            kept.push(range);
+        }
+    }
+    if kept.is_empty() {
+        // A position past the end of the document still gets a selection
+        kept.push(Range::new(position, position));
+    }
+
+    let mut chain: Option<SelectionRange> = None;
+    for range in kept.into_iter().rev() {
+        chain = Some(SelectionRange {
+            range,
+            parent: chain.map(Box::new),
+        });
+    }
+    chain.expect("chain has at least one range")
+}
+
+fn contains(range: &Range, position: Position) -> bool {
+    range.start <= position && position <= range.end
+}
+
+fn contains_range(outer: &Range, inner: &Range) -> bool {
+    outer.start <= inner.start && inner.end <= outer.end
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::parsing::{ParseResult, ParserManager};
+    use unified_sql_lsp_ir::Dialect;
+
+    const SQL: &str =
+        "SELECT id FROM (SELECT user_id FROM orders WHERE total > 10) AS o;\nSELECT 1";
+
+    fn parse(sql: &str) -> tree_sitter::Tree {
+        match ParserManager::new().parse_text(Dialect::MySQL, sql) {
+            ParseResult::Success {
+                tree: Some(tree), ..
+            }
+            | ParseResult::Partial {
+                tree: Some(tree), ..
+            } => tree,
+            other => panic!("Failed to parse {}: {:?}", sql, other),
+        }
+    }
+
+    /// Ranges of a chain, innermost first
+    fn chain(selection: &SelectionRange) -> Vec<Range> {
+        let mut ranges = vec![selection.range];
+        let mut parent = selection.parent.as_deref();
+        while let Some(selection) = parent {
+            ranges.push(selection.range);
+            parent = selection.parent.as_deref();
+        }
+        ranges
+    }
+
+    fn assert_strictly_nested(ranges: &[Range]) {
+        for pair in ranges.windows(2) {
+            assert!(
+                pair[0] != pair[1] && contains_range(&pair[1], &pair[0]),
+                "{:?} does not strictly contain {:?}",
+                pair[1],
+                pair[0]
+            );
+        }
+    }
+
+    fn text(range: &Range) -> String {
+        let lines: Vec<&str> = SQL.split('\n').collect();
+        if range.start.line == range.end.line {
+            let line = lines[range.start.line as usize];
+            return line[range.start.character as usize..range.end.character as usize].to_string();
+        }
+        format!("<{}..{}>", range.start.line, range.end.line)
+    }
+
+    #[test]
+    fn test_chain_grows_through_nested_subquery() {
+        let tree = parse(SQL);
+        // On `total` inside the subquery
+        let position = Position::new(0, SQL.find("total").unwrap() as u32 + 2);
+
+        let selections = selection_ranges(Some(&tree), SQL, &[position]);
+        let ranges = chain(&selections[0]);
+        assert_strictly_nested(&ranges);
+
+        let texts: Vec<String> = ranges.iter().map(text).collect();
+        assert_eq!(texts[0], "total");
+        assert!(texts.iter().any(|t| t == "total > 10"), "{:?}", texts);
+        assert!(
+            texts
+                .iter()
+                .any(|t| t == "SELECT user_id FROM orders WHERE total > 10"),
+            "{:?}",
+            texts
+        );
+        assert_eq!(ranges.last(), Some(&document_range(SQL)));
+    }
+
+    #[test]
+    fn test_each_position_gets_its_own_chain() {
+        let tree = parse(SQL);
+        let positions = [Position::new(0, 8), Position::new(1, 7)];
+
+        let selections = selection_ranges(Some(&tree), SQL, &positions);
+        assert_eq!(selections.len(), 2);
+        assert_eq!(text(&selections[0].range), "id");
+        assert_eq!(text(&selections[1].range), "1");
+        for selection in &selections {
+            assert_strictly_nested(&chain(selection));
+        }
+    }
+
+    #[test]
+    fn test_lexical_chain_without_tree() {
+        let position = Position::new(0, SQL.find("orders").unwrap() as u32);
+
+        let selections = selection_ranges(None, SQL, &[position]);
+        let ranges = chain(&selections[0]);
+        assert_strictly_nested(&ranges);
+
+        let texts: Vec<String> = ranges.iter().map(text).collect();
+        assert_eq!(
+            texts,
+            vec![
+                "orders",
+                "(SELECT user_id FROM orders WHERE total > 10)",
+                "SELECT id FROM (SELECT user_id FROM orders WHERE total > 10) AS o",
+                "<0..1>",
+            ]
+        );
+    }
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Server version negotiation
+//!
+//! This module matches the version reported by the database server
+//! (`SELECT version()`) against the dialect versions the server supports:
+//!
+//! ```text,ignore
+//! "PostgreSQL 16.2 on x86_64-pc-linux-gnu, ..." → 16     → PostgreSQL16 (exact)
+//! "8.0.36-mysql"                                → 8.0.36 → MySQL80      (exact)
+//! "8.4.0"                                       → 8.4.0  → MySQL80      (major)
+//! "PostgreSQL 17.0"                             → 17.0   → PostgreSQL16 (closest)
+//! "8.0.11-TiDB-v7.5.0"                          → 7.5.0  → TiDB70       (major)
+//! ```
+//!
+//! An exact match shares every component of the supported version (`8.0`
+//! matches `8.0.36`), a major match shares the first component only. When
+//! no supported version shares the major version, the closest one is used
+//! and the user is warned, unless `strictVersion` keeps the configured
+//! version.
+
+use unified_sql_lsp_ir::Dialect;
+
+use crate::config::DialectVersion;
+
+/// Version reported by a database server
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ServerVersion {
+    /// Numeric components (e.g. `[16, 2]`)
+    pub components: Vec<u32>,
+}
+
+impl ServerVersion {
+    /// Parse the output of `SELECT version()`
+    ///
+    /// The first dotted number is the version, except for TiDB, whose
+    /// output starts with the MySQL version it emulates and carries its own
+    /// after `TiDB-v`.
+    ///
+    /// # Returns
+    ///
+    /// `None` if the text contains no version number
+    pub fn parse(text: &str) -> Option<Self> {
+        let text = match text.find("TiDB-v") {
+            Some(start) => &text[start + "TiDB-v".len()..],
+            None => text,
+        };
+
+        let start = text.find(|c: char| c.is_ascii_digit())?;
+        let components: Vec<u32> = text[start..]
+            .split(|c: char| !c.is_ascii_digit() && c != '.')
+            .next()?
+            .split('.')
+            .map_while(|component| component.parse().ok())
+            .collect();
+
+        (!components.is_empty()).then_some(Self { components })
+    }
+
+    /// Major version
+    pub fn major(&self) -> u32 {
+        self.components[0]
+    }
+}
+
+impl std::fmt::Display for ServerVersion {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        let components: Vec<String> = self.components.iter().map(u32::to_string).collect();
+        write!(f, "{}", components.join("."))
+    }
+}
+
+/// Supported version chosen for a server
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum VersionMatch {
+    /// The server runs a supported version
+    Exact(DialectVersion),
+
+    /// A supported version shares the server's major version
+    Major(DialectVersion),
+
+    /// No supported version shares the server's major version; this is the
+    /// nearest one
+    Closest(DialectVersion),
+}
+
+impl VersionMatch {
+    /// The chosen version
+    pub fn version(&self) -> DialectVersion {
+        match self {
+            Self::Exact(version) | Self::Major(version) | Self::Closest(version) => *version,
+        }
+    }
+}
+
+/// Choose the supported version of a dialect best matching a server
+///
+/// # Arguments
+///
+/// * `dialect` - The configured dialect
+/// * `server` - The version reported by the server
+///
+/// # Returns
+///
+/// `None` if the dialect has no versioned support (e.g. MariaDB)
+pub fn negotiate(dialect: Dialect, server: &ServerVersion) -> Option<VersionMatch> {
+    let supported: Vec<DialectVersion> = DialectVersion::ALL
+        .into_iter()
+        .filter(|version| version.dialect() == dialect)
+        .collect();
+
+    if let Some(version) = supported
+        .iter()
+        .find(|version| server.components.starts_with(version.number()))
+    {
+        return Some(VersionMatch::Exact(*version));
+    }
+
+    // Newest version of the same major release, or the nearest release
+    if let Some(version) = supported
+        .iter()
+        .rev()
+        .find(|version| version.number()[0] == server.major())
+    {
+        return Some(VersionMatch::Major(*version));
+    }
+    supported
+        .iter()
+        .rev()
+        .min_by_key(|version| version.number()[0].abs_diff(server.major()))
+        .map(|version| VersionMatch::Closest(*version))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn negotiate_text(dialect: Dialect, text: &str) -> Option<VersionMatch> {
+        negotiate(dialect, &ServerVersion::parse(text)?)
+    }
+
+    #[test]
+    fn test_parse() {
+        let version = ServerVersion::parse(
+            "PostgreSQL 16.2 on x86_64-pc-linux-gnu, compiled by gcc (GCC) 12.2.0, 64-bit",
+        )
+        .unwrap();
+        assert_eq!(version.components, vec![16, 2]);
+
+        let version = ServerVersion::parse("8.0.36-mysql").unwrap();
+        assert_eq!(version.components, vec![8, 0, 36]);
+        assert_eq!(version.to_string(), "8.0.36");
+
+        let version = ServerVersion::parse("8.0.11-TiDB-v7.5.0").unwrap();
+        assert_eq!(version.components, vec![7, 5, 0]);
+
+        assert_eq!(ServerVersion::parse("unknown"), None);
+    }
+
+    #[test]
+    fn test_exact_match() {
+        assert_eq!(
+            negotiate_text(
+                Dialect::PostgreSQL,
+                "PostgreSQL 16.2 on x86_64-pc-linux-gnu"
+            ),
+            Some(VersionMatch::Exact(DialectVersion::PostgreSQL16))
+        );
+        assert_eq!(
+            negotiate_text(Dialect::MySQL, "8.0.36-mysql"),
+            Some(VersionMatch::Exact(DialectVersion::MySQL80))
+        );
+        assert_eq!(
+            negotiate_text(Dialect::MySQL, "5.7.44-log"),
+            Some(VersionMatch::Exact(DialectVersion::MySQL57))
+        );
+    }
+
+    #[test]
+    fn test_major_and_closest_match() {
+        assert_eq!(
+            negotiate_text(Dialect::MySQL, "8.4.0"),
+            Some(VersionMatch::Major(DialectVersion::MySQL80))
+        );
+        assert_eq!(
+            negotiate_text(Dialect::TiDB, "8.0.11-TiDB-v7.5.0"),
+            Some(VersionMatch::Major(DialectVersion::TiDB70))
+        );
+        assert_eq!(
+            negotiate_text(Dialect::PostgreSQL, "PostgreSQL 17.0 on aarch64"),
+            Some(VersionMatch::Closest(DialectVersion::PostgreSQL16))
+        );
+        assert_eq!(
+            negotiate_text(Dialect::PostgreSQL, "PostgreSQL 13.4"),
+            Some(VersionMatch::Closest(DialectVersion::PostgreSQL14))
+        );
+        assert_eq!(negotiate_text(Dialect::MariaDB, "10.11.6-MariaDB"), None);
+    }
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Session objects
+//!
+//! This module tracks the temporary tables created by statements executed
+//! with `sql.executeStatement`, so completion knows about them:
+//!
+//! ```text,ignore
+//! sql.executeStatement   CREATE TEMP TABLE recent AS SELECT id, total FROM orders
+//! completion             SELECT | FROM recent     → id, total [TEMPORARY]
+//! ```
+//!
+//! Temporary tables exist in the session of one connection, so they are
+//! kept per connection and only served with that connection's schema (see
+//! [`SessionCatalog`]). They expire when the connection is reset: when it
+//! stops answering health checks, or when the configuration changes.
+//!
+//! Columns come from the column definitions of `CREATE TEMPORARY TABLE`, or
+//! from the select list of `CREATE TEMPORARY TABLE ... AS SELECT`.
+//! `DROP TABLE` of a temporary table removes it.
+
+use async_trait::async_trait;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use unified_sql_lsp_catalog::{
+    Catalog, CatalogResult, ColumnMetadata, FunctionMetadata, TableMetadata, TableType,
+    TableVersion,
+};
+
+use crate::migrations::{SchemaChange, parse_schema_changes};
+
+/// Temporary tables of the sessions of every connection
+#[derive(Debug, Default)]
+pub struct SessionObjects {
+    /// Tables keyed by connection string, in creation order
+    tables: Mutex<HashMap<String, Vec<TableMetadata>>>,
+}
+
+impl SessionObjects {
+    /// Create an empty store
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Record the temporary tables created or dropped by executed statements
+    ///
+    /// # Arguments
+    ///
+    /// * `connection` - Connection string of the session
+    /// * `schema` - Schema of temporary tables created without one
+    /// * `statements` - Statements that executed successfully, in order
+    ///
+    /// # Returns
+    ///
+    /// Whether the temporary tables of the connection changed
+    pub fn record(&self, connection: &str, schema: &str, statements: &[String]) -> bool {
+        let mut sessions = self.tables.lock().unwrap();
+        let mut changed = false;
+
+        for statement in statements {
+            let temporary = is_create_temporary(statement);
+            for change in parse_schema_changes(statement) {
+                match change {
+                    SchemaChange::CreateTable { table, columns, .. } if temporary => {
+                        let table = temporary_table(&table, schema, columns);
+                        let tables = sessions.entry(connection.to_string()).or_default();
+                        tables.retain(|existing| !same_table(existing, &table.name));
+                        tables.push(table);
+                        changed = true;
+                    }
+                    SchemaChange::DropTable { table, .. } => {
+                        if let Some(tables) = sessions.get_mut(connection) {
+                            let before = tables.len();
+                            tables.retain(|existing| !same_table(existing, &table));
+                            changed |= tables.len() != before;
+                        }
+                    }
+                    _ => {}
+                }
+            }
+        }
+
+        sessions.retain(|_, tables| !tables.is_empty());
+        changed
+    }
+
+    /// Temporary tables of a connection, in creation order
+    pub fn tables(&self, connection: &str) -> Vec<TableMetadata> {
+        self.tables
+            .lock()
+            .unwrap()
+            .get(connection)
+            .cloned()
+            .unwrap_or_default()
+    }
+
+    /// Forget the temporary tables of a connection whose session was reset
+    ///
+    /// # Returns
+    ///
+    /// The number of forgotten tables
+    pub fn reset(&self, connection: &str) -> usize {
+        self.tables
+            .lock()
+            .unwrap()
+            .remove(connection)
+            .map_or(0, |tables| tables.len())
+    }
+
+    /// Forget the temporary tables of every connection
+    ///
+    /// # Returns
+    ///
+    /// The number of forgotten tables
+    pub fn clear(&self) -> usize {
+        self.tables
+            .lock()
+            .unwrap()
+            .drain()
+            .map(|(_, tables)| tables.len())
+            .sum()
+    }
+}
+
+/// Catalog serving the temporary tables of a session on top of a schema
+///
+/// Temporary tables are listed first and shadow tables of the same name,
+/// as they do in the database.
+pub struct SessionCatalog {
+    base: Arc<dyn Catalog>,
+    tables: Vec<TableMetadata>,
+}
+
+impl SessionCatalog {
+    /// Create a catalog adding temporary tables to a base catalog
+    pub fn new(base: Arc<dyn Catalog>, tables: Vec<TableMetadata>) -> Self {
+        Self { base, tables }
+    }
+
+    fn find(&self, reference: &str) -> Option<&TableMetadata> {
+        let (schema, name) = match reference.rsplit_once('.') {
+            Some((schema, name)) => (Some(schema), name),
+            None => (None, reference),
+        };
+        self.tables.iter().find(|table| {
+            table.name.eq_ignore_ascii_case(name)
+                && schema.is_none_or(|schema| table.schema.eq_ignore_ascii_case(schema))
+        })
+    }
+}
+
+#[async_trait]
+impl Catalog for SessionCatalog {
+    async fn list_tables(&self) -> CatalogResult<Vec<TableMetadata>> {
+        let mut tables = self.tables.clone();
+        tables.extend(self.base.list_tables().await?);
+        Ok(tables)
+    }
+
+    async fn get_columns(&self, table: &str) -> CatalogResult<Vec<ColumnMetadata>> {
+        match self.find(table) {
+            Some(temporary) => Ok(temporary.columns.clone()),
+            None => self.base.get_columns(table).await,
+        }
+    }
+
+    async fn list_functions(&self) -> CatalogResult<Vec<FunctionMetadata>> {
+        self.base.list_functions().await
+    }
+
+    async fn table_versions(&self) -> CatalogResult<Option<Vec<TableVersion>>> {
+        self.base.table_versions().await
+    }
+}
+
+/// Check whether a statement is `CREATE [GLOBAL|LOCAL] TEMP[ORARY] TABLE`
+fn is_create_temporary(statement: &str) -> bool {
+    let mut words = statement
+        .split_whitespace()
+        .map(str::to_ascii_uppercase)
+        .filter(|word| word != "GLOBAL" && word != "LOCAL");
+    words.next().as_deref() == Some("CREATE")
+        && matches!(words.next().as_deref(), Some("TEMP" | "TEMPORARY"))
+}
+
+/// Metadata of a created temporary table
+fn temporary_table(reference: &str, schema: &str, columns: Vec<ColumnMetadata>) -> TableMetadata {
+    let (schema, name) = reference.rsplit_once('.').unwrap_or((schema, reference));
+    TableMetadata::new(name, schema)
+        .with_columns(columns)
+        .with_type(TableType::Temporary)
+}
+
+/// Check whether a table is the one a reference names, ignoring its schema
+fn same_table(table: &TableMetadata, reference: &str) -> bool {
+    let name = reference.rsplit('.').next().unwrap_or(reference);
+    table.name.eq_ignore_ascii_case(name)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{DataType, SchemaSnapshot, SnapshotCatalog};
+
+    const CONNECTION: &str = "postgres://app@db/shop";
+
+    fn statements(sql: &[&str]) -> Vec<String> {
+        sql.iter().map(|s| s.to_string()).collect()
+    }
+
+    #[test]
+    fn test_temporary_tables_are_recorded_per_connection() {
+        let objects = SessionObjects::new();
+
+        assert!(objects.record(
+            CONNECTION,
+            "pg_temp",
+            &statements(&[
+                "CREATE TEMP TABLE recent AS SELECT id, total AS amount FROM orders",
+                "create local temporary table scratch (note text)",
+                "CREATE TABLE permanent (id int)",
+                "SELECT 1",
+            ]),
+        ));
+
+        let tables = objects.tables(CONNECTION);
+        let names: Vec<String> = tables.iter().map(TableMetadata::qualified_name).collect();
+        assert_eq!(names, ["pg_temp.recent", "pg_temp.scratch"]);
+        assert_eq!(tables[0].table_type, TableType::Temporary);
+        assert_eq!(tables[0].columns[1].name, "amount");
+        assert_eq!(tables[1].columns[0].data_type, DataType::Text);
+        assert!(objects.tables("mysql://app@db/shop").is_empty());
+
+        assert!(objects.record(CONNECTION, "pg_temp", &statements(&["DROP TABLE recent"])));
+        assert_eq!(objects.tables(CONNECTION).len(), 1);
+        assert!(!objects.record(CONNECTION, "pg_temp", &statements(&["DROP TABLE orders"])));
+
+        assert_eq!(objects.reset(CONNECTION), 1);
+        assert!(objects.tables(CONNECTION).is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_session_catalog_shadows_base_tables() {
+        let base = SchemaSnapshot::new(
+            vec![
+                TableMetadata::new("orders", "public")
+                    .with_columns(vec![ColumnMetadata::new("id", DataType::Integer)]),
+            ],
+            vec![],
+        );
+        let objects = SessionObjects::new();
+        objects.record(
+            CONNECTION,
+            "pg_temp",
+            &statements(&["CREATE TEMPORARY TABLE orders (draft_id int)"]),
+        );
+        let catalog = SessionCatalog::new(
+            Arc::new(SnapshotCatalog::new(base)),
+            objects.tables(CONNECTION),
+        );
+
+        let tables = catalog.list_tables().await.unwrap();
+        assert_eq!(tables.len(), 2);
+        assert_eq!(tables[0].schema, "pg_temp");
+        let columns = catalog.get_columns("orders").await.unwrap();
+        assert_eq!(columns[0].name, "draft_id");
+        let columns = catalog.get_columns("public.orders").await.unwrap();
+        assert_eq!(columns[0].name, "id");
+    }
+}
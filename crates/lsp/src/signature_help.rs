@@ -0,0 +1,255 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Signature help
+//!
+//! This module finds the function call surrounding the cursor and renders
+//! the signature of the called function.
+//!
+//! Functions are looked up in the catalog first; when the schema has no
+//! function of that name, the dialect's built-in functions are used.
+//!
+//! ## Examples
+//!
+//! ```text,ignore
+//! SELECT COALESCE(a, |     → COALESCE, active parameter 1
+//! SELECT ROUND(SUM(x|      → SUM, active parameter 0
+//! SELECT CONCAT('a,(', |   → CONCAT, active parameter 1
+//! ```
+
+use tower_lsp::lsp_types::{
+    Documentation, ParameterInformation, ParameterLabel, Position, SignatureHelp,
+    SignatureInformation,
+};
+use unified_sql_lsp_catalog::FunctionMetadata;
+
+/// The function call surrounding the cursor
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CallSite {
+    /// Name of the called function, without any schema qualifier
+    pub name: String,
+
+    /// Index of the argument the cursor is in
+    pub active_parameter: u32,
+}
+
+/// Find the innermost function call surrounding `position`
+///
+/// String literals, quoted identifiers and `--` comments are skipped, so
+/// parentheses and commas inside them do not count. The name is the word
+/// before the parenthesis; whether it names a function is left to the caller.
+///
+/// # Arguments
+///
+/// * `source` - The SQL text
+/// * `position` - The cursor position (UTF-16 character offset)
+///
+/// # Returns
+///
+/// `None` when the cursor is not inside the argument list of a call
+pub fn call_at(source: &str, position: Position) -> Option<CallSite> {
+    let chars: Vec<char> = text_before(source, position).chars().collect();
+
+    // Open parentheses with the number of commas seen at their depth
+    let mut open: Vec<(usize, u32)> = Vec::new();
+    let mut i = 0;
+    while i < chars.len() {
+        match chars[i] {
+            quote @ ('\'' | '"' | '`') => {
+                i += 1;
+                while i < chars.len() && chars[i] != quote {
+                    i += 1;
+                }
+            }
+            '-' if chars.get(i + 1) == Some(&'-') => {
+                while i < chars.len() && chars[i] != '\n' {
+                    i += 1;
+                }
+            }
+            '(' => open.push((i, 0)),
+            ')' => {
+                open.pop();
+            }
+            ',' => {
+                if let Some((_, commas)) = open.last_mut() {
+                    *commas += 1;
+                }
+            }
+            // A statement boundary closes every call
+            ';' => open.clear(),
+            _ => {}
+        }
+        i += 1;
+    }
+
+    let &(paren, commas) = open.last()?;
+
+    // The function name directly precedes the parenthesis
+    let mut end = paren;
+    while end > 0 && chars[end - 1].is_whitespace() {
+        end -= 1;
+    }
+    let mut start = end;
+    while start > 0 && (chars[start - 1].is_alphanumeric() || chars[start - 1] == '_') {
+        start -= 1;
+    }
+    if start == end || chars[start].is_ascii_digit() {
+        return None;
+    }
+
+    Some(CallSite {
+        name: chars[start..end].iter().collect(),
+        active_parameter: commas,
+    })
+}
+
+/// Render the signature help of a function
+///
+/// # Arguments
+///
+/// * `function` - The called function
+/// * `active_parameter` - Index of the argument the cursor is in; arguments
+///   past a variadic last parameter activate that parameter
+pub fn signature_help(function: &FunctionMetadata, active_parameter: u32) -> SignatureHelp {
+    let mut label = format!("{}(", function.name);
+    let mut parameters = Vec::with_capacity(function.parameters.len());
+    for (i, parameter) in function.parameters.iter().enumerate() {
+        if i > 0 {
+            label.push_str(", ");
+        }
+        let start = label.encode_utf16().count() as u32;
+        label.push_str(&format!("{} {:?}", parameter.name, parameter.data_type));
+        if parameter.is_variadic {
+            label.push_str("...");
+        }
+        let end = label.encode_utf16().count() as u32;
+        parameters.push(ParameterInformation {
+            label: ParameterLabel::LabelOffsets([start, end]),
+            documentation: None,
+        });
+    }
+    label.push_str(&format!(") -> {:?}", function.return_type));
+
+    let variadic = function.parameters.last().is_some_and(|p| p.is_variadic);
+    let active_parameter = match function.parameters.len() as u32 {
+        0 => None,
+        count if variadic => Some(active_parameter.min(count - 1)),
+        count if active_parameter < count => Some(active_parameter),
+        _ => None,
+    };
+
+    SignatureHelp {
+        signatures: vec![SignatureInformation {
+            label,
+            documentation: function.description.clone().map(Documentation::String),
+            parameters: Some(parameters),
+            active_parameter,
+        }],
+        active_signature: Some(0),
+        active_parameter,
+    }
+}
+
+/// Text of `source` before `position`
+fn text_before(source: &str, position: Position) -> &str {
+    let mut offset = 0;
+    for (line, text) in source.split_inclusive('\n').enumerate() {
+        if line as u32 == position.line {
+            let mut utf16 = 0;
+            for (byte, c) in text.char_indices() {
+                if utf16 >= position.character || c == '\n' {
+                    return &source[..offset + byte];
+                }
+                utf16 += c.len_utf16() as u32;
+            }
+            return &source[..offset + text.len()];
+        }
+        offset += text.len();
+    }
+    source
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{DataType, FunctionParameter};
+
+    fn call(source: &str) -> Option<CallSite> {
+        call_at(
+            source,
+            Position::new(0, source.encode_utf16().count() as u32),
+        )
+    }
+
+    fn parameter(name: &str, is_variadic: bool) -> FunctionParameter {
+        FunctionParameter {
+            name: name.to_string(),
+            data_type: DataType::Text,
+            has_default: false,
+            is_variadic,
+        }
+    }
+
+    #[test]
+    fn test_call_at() {
+        assert_eq!(
+            call("SELECT COALESCE(a, "),
+            Some(CallSite {
+                name: "COALESCE".to_string(),
+                active_parameter: 1
+            })
+        );
+        assert_eq!(call("SELECT ROUND(SUM(x").unwrap().name, "SUM");
+        assert_eq!(call("SELECT ROUND(SUM(x), ").unwrap().name, "ROUND");
+        assert_eq!(call("SELECT pg_catalog.lower (").unwrap().name, "lower");
+    }
+
+    #[test]
+    fn test_call_at_skips_literals() {
+        let site = call("SELECT CONCAT('a,(', ").unwrap();
+        assert_eq!(site.name, "CONCAT");
+        assert_eq!(site.active_parameter, 1);
+    }
+
+    #[test]
+    fn test_call_at_outside_call() {
+        assert_eq!(call("SELECT a FROM t"), None);
+        assert_eq!(call("SELECT COUNT(*) FROM t"), None);
+        assert_eq!(call("SELECT COUNT(; SELECT "), None);
+    }
+
+    #[test]
+    fn test_call_at_multiline() {
+        let source = "SELECT\n  COALESCE(a,\n  b";
+        let site = call_at(source, Position::new(2, 3)).unwrap();
+        assert_eq!(site.name, "COALESCE");
+        assert_eq!(site.active_parameter, 1);
+    }
+
+    #[test]
+    fn test_signature_help_labels() {
+        let function = FunctionMetadata::new("CONCAT", DataType::Text)
+            .with_parameters(vec![parameter("str", false), parameter("strs", true)]);
+
+        let help = signature_help(&function, 3);
+        let signature = &help.signatures[0];
+        assert_eq!(signature.label, "CONCAT(str Text, strs Text...) -> Text");
+        assert_eq!(
+            signature.parameters.as_ref().unwrap()[1].label,
+            ParameterLabel::LabelOffsets([17, 29])
+        );
+        // Arguments past the variadic parameter keep it active
+        assert_eq!(help.active_parameter, Some(1));
+    }
+
+    #[test]
+    fn test_signature_help_past_last_parameter() {
+        let function = FunctionMetadata::new("LOWER", DataType::Text)
+            .with_parameters(vec![parameter("str", false)]);
+
+        assert_eq!(signature_help(&function, 0).active_parameter, Some(0));
+        assert_eq!(signature_help(&function, 1).active_parameter, None);
+    }
+}
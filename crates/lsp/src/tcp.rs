@@ -18,6 +18,34 @@
 //! LSP Backend (document store, completion engine, catalog)
 //! ```
 //!
+//! ## Transports
+//!
+//! Clients speak WebSocket by default, or the LSP base protocol over the raw
+//! socket (see [`crate::transport`]). WebSocket clients can be restricted to
+//! a path.
+//!
+//! ## Authentication
+//!
+//! With an auth token configured, a client must present it before anything
+//! else, either as `Authorization: Bearer <token>` in the WebSocket
+//! handshake or in its `initialize` request:
+//!
+//! ```json
+//! { "method": "initialize", "params": { "initializationOptions": { "token": "..." } } }
+//! ```
+//!
+//! Clients presenting no or a wrong token, or staying silent for 10 seconds,
+//! are answered with an error and disconnected. The stdio server is not
+//! authenticated.
+//!
+//! ## Sessions
+//!
+//! Each connection gets its own session with its own documents, client
+//! capabilities, settings and in-flight requests. The catalog is shared by
+//! all sessions of a server and outlives them. Sessions do not open live
+//! connections, so there is no connection pool or schema cache to share.
+//! The stdio server serves a single client and has no sessions.
+//!
 //! ## Usage
 //!
 //! ```rust,no_run
@@ -33,24 +61,37 @@
 //! }
 //! ```
 
-use futures_util::{SinkExt, StreamExt};
 use serde::{Deserialize, Serialize};
 use serde_json::Value as JsonValue;
 use std::sync::Arc;
-use tokio::net::TcpListener;
-use tokio_tungstenite::tungstenite::protocol::Message;
+use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
+use std::time::Duration;
+use tokio::net::{TcpListener, TcpStream};
+use tokio::sync::RwLock;
+use tokio_tungstenite::tungstenite::handshake::server::{ErrorResponse, Request, Response};
+use tokio_tungstenite::tungstenite::http::StatusCode;
+use tokio_tungstenite::tungstenite::http::header::AUTHORIZATION;
 use tracing::{debug, error, info, warn};
 
-use crate::catalog_manager::CatalogManager;
+use crate::client_capabilities::ClientFeatures;
 use crate::completion::CompletionEngine;
 use crate::config::EngineConfig;
 use crate::document::{DocumentStore, ParseMetadata};
 use crate::parsing::{ParseResult, ParserManager};
+use crate::transport::{
+    FramedTransport, KEEPALIVE_INTERVAL, Transport, TransportKind, WebSocketTransport, tokens_match,
+};
 use tower_lsp::jsonrpc::Result as JsonRpcResult;
 use tower_lsp::lsp_types::*;
 use unified_sql_lsp_catalog::Catalog;
 use unified_sql_lsp_ir::Dialect;
 
+/// Time a client has to authenticate after connecting
+const AUTH_DEADLINE: Duration = Duration::from_secs(10);
+
+/// JSON-RPC error code for clients that failed to authenticate
+const UNAUTHORIZED_ERROR_CODE: i32 = -32001;
+
 /// JSON-RPC request
 #[derive(Debug, Clone, Deserialize)]
 struct JsonRpcRequest {
@@ -124,26 +165,131 @@ struct JsonRpcError {
     data: Option<JsonValue>,
 }
 
-/// Per-client session state
-struct ClientSession {
-    documents: Arc<DocumentStore>,
-    #[allow(dead_code)]
-    catalog_manager: Arc<tokio::sync::RwLock<CatalogManager>>,
-    #[allow(dead_code)]
-    config: Arc<tokio::sync::RwLock<Option<EngineConfig>>>,
+/// Services shared by all sessions of a server
+///
+/// Sessions come and go with their connections; shared services outlive
+/// them, so closing a session never closes a shared resource.
+pub struct SharedServices {
     catalog: Arc<dyn Catalog>,
 }
 
+impl SharedServices {
+    /// Create the shared services of a server
+    pub fn new(catalog: Arc<dyn Catalog>) -> Self {
+        Self { catalog }
+    }
+
+    /// Catalog serving completion and hover
+    pub fn catalog(&self) -> Arc<dyn Catalog> {
+        self.catalog.clone()
+    }
+}
+
+/// State of one client connection
+///
+/// Documents, client capabilities and settings are isolated per session:
+/// two clients editing the same URI never see each other's content.
+struct ClientSession {
+    shared: Arc<SharedServices>,
+    documents: DocumentStore,
+    features: std::sync::RwLock<ClientFeatures>,
+    config: RwLock<Option<EngineConfig>>,
+    /// Requests being handled
+    in_flight: AtomicUsize,
+    /// Whether the client requested shutdown
+    shut_down: AtomicBool,
+}
+
+/// Marks a request as in flight until dropped
+struct InFlightRequest<'a>(&'a AtomicUsize);
+
+impl Drop for InFlightRequest<'_> {
+    fn drop(&mut self) {
+        self.0.fetch_sub(1, Ordering::SeqCst);
+    }
+}
+
 impl ClientSession {
-    fn new(catalog: Arc<dyn Catalog>) -> Self {
+    fn new(shared: Arc<SharedServices>) -> Self {
         Self {
-            documents: Arc::new(DocumentStore::new()),
-            catalog_manager: Arc::new(tokio::sync::RwLock::new(CatalogManager::new())),
-            config: Arc::new(tokio::sync::RwLock::new(None)),
-            catalog,
+            shared,
+            documents: DocumentStore::new(),
+            features: std::sync::RwLock::new(ClientFeatures::default()),
+            config: RwLock::new(None),
+            in_flight: AtomicUsize::new(0),
+            shut_down: AtomicBool::new(false),
+        }
+    }
+
+    /// Track a request until the returned guard is dropped
+    fn begin_request(&self) -> InFlightRequest<'_> {
+        self.in_flight.fetch_add(1, Ordering::SeqCst);
+        InFlightRequest(&self.in_flight)
+    }
+
+    /// Number of requests being handled
+    fn in_flight_requests(&self) -> usize {
+        self.in_flight.load(Ordering::SeqCst)
+    }
+
+    fn is_shut_down(&self) -> bool {
+        self.shut_down.load(Ordering::SeqCst)
+    }
+
+    /// Dialect of the session's settings, MySQL until configured
+    async fn dialect(&self) -> Dialect {
+        self.config
+            .read()
+            .await
+            .as_ref()
+            .map_or(Dialect::MySQL, |config| config.dialect)
+    }
+
+    fn handle_initialize(&self, params: InitializeParams) -> JsonValue {
+        let features = ClientFeatures::from_capabilities(&params.capabilities);
+        debug!("Client features: {:?}", features);
+        *self.features.write().unwrap() = features;
+
+        serde_json::json!({
+            "capabilities": {
+                "textDocumentSync": 1,
+                "completionProvider": {
+                    "triggerCharacters": [".", " ", "("]
+                },
+                "hoverProvider": true,
+                "diagnosticProvider": true
+            },
+            "serverInfo": {
+                "name": "unified-sql-lsp",
+                "version": env!("CARGO_PKG_VERSION")
+            }
+        })
+    }
+
+    async fn handle_did_change_configuration(&self, params: DidChangeConfigurationParams) {
+        match EngineConfig::from_lsp_settings(&params.settings) {
+            Some(config) => {
+                debug!("Session configured: dialect={:?}", config.dialect);
+                *self.config.write().await = Some(config);
+            }
+            None => warn!("Ignoring invalid session settings"),
         }
     }
 
+    /// Close the session's documents
+    ///
+    /// Shared services stay up for the other sessions.
+    async fn handle_shutdown(&self) {
+        self.shut_down.store(true, Ordering::SeqCst);
+        for uri in self.documents.list_uris().await {
+            self.documents.close_document(&uri).await;
+        }
+        debug!(
+            "Session shut down with {} requests in flight",
+            self.in_flight_requests().saturating_sub(1)
+        );
+    }
+
     async fn handle_did_open(&self, params: DidOpenTextDocumentParams) {
         let uri = params.text_document.uri.clone();
         let text = params.text_document.text;
@@ -164,7 +310,7 @@ impl ClientSession {
         // Parse document
         if let Some(document) = self.documents.get_document(&uri).await {
             let source = document.get_content();
-            let dialect = Dialect::MySQL; // Default for playground
+            let dialect = self.dialect().await;
 
             let parse_result = ParserManager::parse_text(&ParserManager::new(), dialect, &source);
 
@@ -237,7 +383,7 @@ impl ClientSession {
         // Re-parse document
         if let Some(document) = self.documents.get_document(&uri).await {
             let source = document.get_content();
-            let dialect = Dialect::MySQL;
+            let dialect = self.dialect().await;
 
             let parse_result = ParserManager::parse_text(&ParserManager::new(), dialect, &source);
 
@@ -305,11 +451,34 @@ impl ClientSession {
         };
 
         // Create completion engine
-        let engine = CompletionEngine::new(catalog);
+        let snippets = self.features.read().unwrap().snippets;
+        let (keyword_case, budgets, parameters) = self
+            .config
+            .read()
+            .await
+            .as_ref()
+            .map(|config| {
+                (
+                    config.keyword_case,
+                    config.request_budgets,
+                    Some(config.parameter_styles(uri.path())),
+                )
+            })
+            .unwrap_or_default();
+        let mut engine = CompletionEngine::new(catalog)
+            .with_snippets(snippets)
+            .with_dialect(self.dialect().await)
+            .with_keyword_case(keyword_case);
+        if let Some(styles) = parameters {
+            engine = engine.with_parameters(styles);
+        }
 
         // Execute completion
-        match engine.complete(&document, position).await {
-            Ok(Some(items)) => Ok(Some(CompletionResponse::Array(items))),
+        match engine
+            .complete_within(&document, position, budgets.completion)
+            .await
+        {
+            Ok(Some(list)) => Ok(Some(CompletionResponse::List(list))),
             Ok(None) => Ok(None),
             Err(e) => {
                 error!("Completion error: {}", e);
@@ -338,23 +507,59 @@ impl ClientSession {
     }
 }
 
+/// Options of the TCP server
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct TcpServerOptions {
+    /// Transport spoken by clients
+    pub transport: TransportKind,
+
+    /// WebSocket path clients connect to; any path when unset
+    pub path: Option<String>,
+
+    /// Token clients must present, either as `Authorization: Bearer <token>`
+    /// in the WebSocket handshake or as `initializationOptions.token` of
+    /// their `initialize` request
+    pub auth_token: Option<String>,
+}
+
 /// TCP/WebSocket server for LSP
 pub struct TcpServer {
     listener: TcpListener,
     port: u16,
-    catalog: Arc<dyn Catalog>,
+    shared: Arc<SharedServices>,
+    options: Arc<TcpServerOptions>,
 }
 
 impl TcpServer {
     /// Create a new TCP server listening on the specified port
     pub async fn new(port: u16, catalog: Arc<dyn Catalog>) -> std::io::Result<Self> {
+        Self::with_options(port, catalog, TcpServerOptions::default()).await
+    }
+
+    /// Create a new TCP server with the given options
+    ///
+    /// # Arguments
+    ///
+    /// * `port` - The port to listen on, or 0 for any free port
+    /// * `catalog` - The catalog serving completion and hover
+    /// * `options` - Transport, WebSocket path and authentication
+    pub async fn with_options(
+        port: u16,
+        catalog: Arc<dyn Catalog>,
+        options: TcpServerOptions,
+    ) -> std::io::Result<Self> {
         let listener = TcpListener::bind(format!("0.0.0.0:{}", port)).await?;
-        info!("TCP LSP server listening on port {}", port);
+        let port = listener.local_addr()?.port();
+        info!(
+            "TCP LSP server listening on port {} ({:?})",
+            port, options.transport
+        );
 
         Ok(Self {
             listener,
             port,
-            catalog,
+            shared: Arc::new(SharedServices::new(catalog)),
+            options: Arc::new(options),
         })
     }
 
@@ -373,11 +578,32 @@ impl TcpServer {
                     info!("New connection from {}", addr);
 
                     // Create a new session for this connection
-                    let session = ClientSession::new(self.catalog.clone());
+                    let session = ClientSession::new(self.shared.clone());
+                    let options = self.options.clone();
 
                     // Spawn a task to handle this connection
                     tokio::spawn(async move {
-                        if let Err(e) = handle_connection(stream, session).await {
+                        let token = options.auth_token.as_deref();
+                        let result = match options.transport {
+                            TransportKind::Tcp => {
+                                let (reader, writer) = stream.into_split();
+                                let transport = FramedTransport::new(reader, writer);
+                                handle_connection(transport, session, token).await
+                            }
+                            TransportKind::WebSocket => {
+                                match accept_websocket(stream, &options).await {
+                                    // A valid bearer token authenticates the connection
+                                    Ok((transport, true)) => {
+                                        handle_connection(transport, session, None).await
+                                    }
+                                    Ok((transport, false)) => {
+                                        handle_connection(transport, session, token).await
+                                    }
+                                    Err(e) => Err(e.into()),
+                                }
+                            }
+                        };
+                        if let Err(e) = result {
                             error!("Error handling connection: {}", e);
                         }
                     });
@@ -390,65 +616,181 @@ impl TcpServer {
     }
 }
 
-/// Handle a single WebSocket connection
+/// Perform the WebSocket handshake, checking the path and the token
+///
+/// # Returns
+///
+/// The transport, and whether the client presented a valid bearer token
+async fn accept_websocket(
+    stream: TcpStream,
+    options: &TcpServerOptions,
+) -> Result<(WebSocketTransport<TcpStream>, bool), tokio_tungstenite::tungstenite::Error> {
+    let mut authenticated = false;
+    let check = |request: &Request, response: Response| -> Result<Response, ErrorResponse> {
+        authenticated = check_handshake(request, options)?;
+        Ok(response)
+    };
+    let stream = tokio_tungstenite::accept_hdr_async(stream, check).await?;
+    info!("WebSocket connection established");
+    Ok((
+        WebSocketTransport::new(stream, KEEPALIVE_INTERVAL),
+        authenticated,
+    ))
+}
+
+/// Check the path and the bearer token of a WebSocket handshake
+///
+/// A handshake without `Authorization` header is accepted; the client must
+/// then authenticate with its `initialize` request.
+///
+/// # Returns
+///
+/// Whether the client presented a valid bearer token
+fn check_handshake(request: &Request, options: &TcpServerOptions) -> Result<bool, ErrorResponse> {
+    let reject = |status: StatusCode, reason: &str| {
+        let mut response = ErrorResponse::new(Some(reason.to_string()));
+        *response.status_mut() = status;
+        response
+    };
+
+    if let Some(path) = &options.path
+        && request.uri().path() != path.as_str()
+    {
+        return Err(reject(StatusCode::NOT_FOUND, "unknown path"));
+    }
+    let Some(token) = &options.auth_token else {
+        return Ok(false);
+    };
+    let Some(header) = request.headers().get(AUTHORIZATION) else {
+        return Ok(false);
+    };
+    let presented = header
+        .to_str()
+        .ok()
+        .and_then(|value| value.strip_prefix("Bearer "));
+    if !presented.is_some_and(|presented| tokens_match(presented, token)) {
+        return Err(reject(StatusCode::UNAUTHORIZED, "invalid token"));
+    }
+    Ok(true)
+}
+
+/// Wait for an `initialize` request carrying the token
+///
+/// Any other first message, a wrong token or silence past `deadline` fails
+/// authentication; the client is answered with an error where possible.
+///
+/// # Returns
+///
+/// The `initialize` request, or `None` if the client did not authenticate
+async fn authenticate(
+    transport: &mut impl Transport,
+    token: &str,
+    deadline: Duration,
+) -> std::io::Result<Option<String>> {
+    let text = match tokio::time::timeout(deadline, transport.read_message()).await {
+        Ok(message) => match message? {
+            Some(text) => text,
+            None => return Ok(None),
+        },
+        Err(_) => {
+            warn!("Client did not authenticate within {:?}", deadline);
+            return Ok(None);
+        }
+    };
+
+    let request = serde_json::from_str::<JsonRpcRequest>(&text).ok();
+    let presented = request
+        .as_ref()
+        .filter(|request| request.method() == "initialize")
+        .and_then(|request| request.params())
+        .and_then(|params| params.pointer("/initializationOptions/token"))
+        .and_then(JsonValue::as_str);
+    if presented.is_some_and(|presented| tokens_match(presented, token)) {
+        return Ok(Some(text));
+    }
+
+    warn!("Rejecting unauthenticated client");
+    let response = JsonRpcResponse {
+        jsonrpc: "2.0".to_string(),
+        id: request.and_then(|request| request.id()).unwrap_or_default(),
+        result: None,
+        error: Some(JsonRpcError {
+            code: UNAUTHORIZED_ERROR_CODE,
+            message: "Unauthorized: initialize with initializationOptions.token".to_string(),
+            data: None,
+        }),
+    };
+    transport
+        .write_message(&serde_json::to_string(&response)?)
+        .await?;
+    Ok(None)
+}
+
+/// Serve one session over a transport until the client disconnects
+///
+/// # Arguments
+///
+/// * `transport` - The client's transport
+/// * `session` - The client's session
+/// * `token` - The token the client must authenticate with, if it has not
+///   yet
 async fn handle_connection(
-    stream: tokio::net::TcpStream,
+    mut transport: impl Transport,
     session: ClientSession,
-) -> Result<(), Box<dyn std::error::Error>> {
-    // Perform WebSocket handshake
-    let ws_stream = tokio_tungstenite::accept_async(stream).await?;
-    let (mut ws_sender, mut ws_receiver) = ws_stream.split();
-
-    info!("WebSocket connection established");
+    token: Option<&str>,
+) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+    let mut pending = None;
+    if let Some(token) = token {
+        pending = authenticate(&mut transport, token, AUTH_DEADLINE).await?;
+        if pending.is_none() {
+            let _ = transport.close().await;
+            return Ok(());
+        }
+    }
 
     // Handle incoming messages
-    while let Some(msg_result) = ws_receiver.next().await {
-        match msg_result {
-            Ok(msg) => {
-                if msg.is_text() || msg.is_binary() {
-                    let text = msg.to_text()?;
-
-                    debug!("Received message: {}", text);
-
-                    // Parse JSON-RPC request
-                    let response = match handle_lsp_message(text, &session).await {
-                        Ok(resp) => resp,
-                        Err(e) => {
-                            error!("Error handling LSP message: {}", e);
-                            JsonRpcResponse {
-                                jsonrpc: "2.0".to_string(),
-                                id: JsonValue::Null,
-                                result: None,
-                                error: Some(JsonRpcError {
-                                    code: -32603,
-                                    message: format!("Internal error: {}", e),
-                                    data: None,
-                                }),
-                            }
-                        }
-                    };
+    loop {
+        let text = match pending.take() {
+            Some(text) => text,
+            None => match transport.read_message().await? {
+                Some(text) => text,
+                None => break,
+            },
+        };
+        debug!("Received message: {}", text);
 
-                    // Send response (skip for notifications with null id)
-                    if response.id != JsonValue::Null {
-                        let response_text = serde_json::to_string(&response)?;
-                        if let Err(e) = ws_sender.send(Message::Text(response_text)).await {
-                            error!("Error sending response: {}", e);
-                            break;
-                        }
-                    }
-                } else if msg.is_close() {
-                    info!("Client requested close");
-                    break;
+        // Parse JSON-RPC request
+        let response = match handle_lsp_message(&text, &session).await {
+            Ok(resp) => resp,
+            Err(e) => {
+                error!("Error handling LSP message: {}", e);
+                JsonRpcResponse {
+                    jsonrpc: "2.0".to_string(),
+                    id: JsonValue::Null,
+                    result: None,
+                    error: Some(JsonRpcError {
+                        code: -32603,
+                        message: format!("Internal error: {}", e),
+                        data: None,
+                    }),
                 }
             }
-            Err(e) => {
-                error!("Error receiving message: {}", e);
+        };
+
+        // Send response (skip for notifications with null id)
+        if response.id != JsonValue::Null {
+            let response_text = serde_json::to_string(&response)?;
+            if let Err(e) = transport.write_message(&response_text).await {
+                error!("Error sending response: {}", e);
                 break;
             }
         }
     }
 
-    info!("WebSocket connection closed");
+    if let Err(e) = transport.close().await {
+        debug!("Error closing connection: {}", e);
+    }
+    info!("Connection closed");
     Ok(())
 }
 
@@ -483,6 +825,11 @@ async fn handle_lsp_message(
                 let parsed: DidCloseTextDocumentParams = serde_json::from_value(params_value)?;
                 session.handle_did_close(parsed).await;
             }
+            "workspace/didChangeConfiguration" => {
+                let params_value = params.unwrap_or(JsonValue::Null);
+                let parsed: DidChangeConfigurationParams = serde_json::from_value(params_value)?;
+                session.handle_did_change_configuration(parsed).await;
+            }
             "exit" => {
                 debug!("Received exit notification");
             }
@@ -501,31 +848,35 @@ async fn handle_lsp_message(
     } else {
         debug!("LSP request: {}", method);
 
-        let catalog = session.catalog.clone();
+        if session.is_shut_down() && method != "shutdown" {
+            return Ok(JsonRpcResponse {
+                jsonrpc: "2.0".to_string(),
+                id,
+                result: None,
+                error: Some(JsonRpcError {
+                    code: -32600,
+                    message: format!("Session is shut down: {}", method),
+                    data: None,
+                }),
+            });
+        }
+
+        let _request = session.begin_request();
+        let catalog = session.shared.catalog();
 
         // Call actual backend methods
         let result = match method.as_str() {
             "initialize" => {
-                serde_json::json!({
-                    "capabilities": {
-                        "textDocumentSync": 1,
-                        "completionProvider": {
-                            "triggerCharacters": [".", " "]
-                        },
-                        "hoverProvider": true,
-                        "diagnosticProvider": true
-                    },
-                    "serverInfo": {
-                        "name": "unified-sql-lsp",
-                        "version": env!("CARGO_PKG_VERSION")
-                    }
-                })
+                let params_value = params.unwrap_or(JsonValue::Null);
+                let parsed: InitializeParams = serde_json::from_value(params_value)?;
+                session.handle_initialize(parsed)
             }
             "initialized" => {
                 serde_json::json!({})
             }
             "shutdown" => {
-                serde_json::json!({})
+                session.handle_shutdown().await;
+                serde_json::json!(null)
             }
             "textDocument/completion" => {
                 let params_value = params.unwrap_or(JsonValue::Null);
@@ -609,4 +960,319 @@ mod tests {
         assert_eq!(request.method(), "textDocument/didOpen");
         assert!(request.is_notification());
     }
+
+    fn shared() -> Arc<SharedServices> {
+        Arc::new(SharedServices::new(Arc::new(
+            unified_sql_lsp_catalog::StaticCatalog::new(),
+        )))
+    }
+
+    async fn notify(session: &ClientSession, method: &str, params: JsonValue) {
+        let message = serde_json::json!({"jsonrpc": "2.0", "method": method, "params": params});
+        handle_lsp_message(&message.to_string(), session)
+            .await
+            .unwrap();
+    }
+
+    async fn request(session: &ClientSession, method: &str, params: JsonValue) -> JsonRpcResponse {
+        let message =
+            serde_json::json!({"jsonrpc": "2.0", "id": 1, "method": method, "params": params});
+        handle_lsp_message(&message.to_string(), session)
+            .await
+            .unwrap()
+    }
+
+    /// Open `uri` in a session and type `edits` into it, one change at a time
+    async fn edit(session: &ClientSession, uri: &str, edits: &[&str]) {
+        notify(
+            session,
+            "textDocument/didOpen",
+            serde_json::json!({
+                "textDocument": {"uri": uri, "languageId": "sql", "version": 0, "text": ""}
+            }),
+        )
+        .await;
+        for (version, text) in edits.iter().enumerate() {
+            notify(
+                session,
+                "textDocument/didChange",
+                serde_json::json!({
+                    "textDocument": {"uri": uri, "version": version + 1},
+                    "contentChanges": [{"text": text}]
+                }),
+            )
+            .await;
+            tokio::task::yield_now().await;
+        }
+    }
+
+    async fn content(session: &ClientSession, uri: &str) -> Option<String> {
+        let uri = Url::parse(uri).unwrap();
+        session
+            .documents
+            .get_document(&uri)
+            .await
+            .map(|d| d.get_content())
+    }
+
+    #[tokio::test]
+    async fn test_sessions_editing_the_same_uri_are_isolated() {
+        let shared = shared();
+        let alice = Arc::new(ClientSession::new(shared.clone()));
+        let bob = Arc::new(ClientSession::new(shared.clone()));
+        let uri = "file:///shared/query.sql";
+
+        let (a, b) = (alice.clone(), bob.clone());
+        let (first, second) = tokio::join!(
+            tokio::spawn(async move {
+                edit(&a, uri, &["SELECT", "SELECT id", "SELECT id FROM users"]).await
+            }),
+            tokio::spawn(async move { edit(&b, uri, &["DELETE", "DELETE FROM orders"]).await }),
+        );
+        first.unwrap();
+        second.unwrap();
+
+        assert_eq!(
+            content(&alice, uri).await.as_deref(),
+            Some("SELECT id FROM users")
+        );
+        assert_eq!(
+            content(&bob, uri).await.as_deref(),
+            Some("DELETE FROM orders")
+        );
+    }
+
+    #[tokio::test]
+    async fn test_session_settings_are_isolated() {
+        let shared = shared();
+        let postgres = ClientSession::new(shared.clone());
+        let default = ClientSession::new(shared);
+
+        notify(
+            &postgres,
+            "workspace/didChangeConfiguration",
+            serde_json::json!({
+                "settings": {"unifiedSqlLsp": {"dialect": "postgresql", "connectionString": ""}}
+            }),
+        )
+        .await;
+
+        assert_eq!(postgres.dialect().await, Dialect::PostgreSQL);
+        assert_eq!(default.dialect().await, Dialect::MySQL);
+    }
+
+    #[tokio::test]
+    async fn test_shutdown_keeps_shared_services() {
+        let shared = shared();
+        let closing = ClientSession::new(shared.clone());
+        let staying = ClientSession::new(shared.clone());
+        let uri = "file:///query.sql";
+        edit(&closing, uri, &["SELECT 1"]).await;
+        edit(&staying, uri, &["SELECT 2"]).await;
+
+        let response = request(&closing, "shutdown", JsonValue::Null).await;
+        assert!(response.error.is_none());
+        assert_eq!(closing.in_flight_requests(), 0);
+        assert_eq!(content(&closing, uri).await, None);
+
+        // The closed session rejects further requests
+        let response = request(&closing, "textDocument/hover", JsonValue::Null).await;
+        assert_eq!(response.error.map(|e| e.code), Some(-32600));
+
+        // The other session and the shared services are unaffected
+        assert_eq!(content(&staying, uri).await.as_deref(), Some("SELECT 2"));
+        assert!(Arc::ptr_eq(
+            &closing.shared.catalog(),
+            &staying.shared.catalog()
+        ));
+        assert_eq!(Arc::strong_count(&shared), 3);
+    }
+
+    async fn start_server(options: TcpServerOptions) -> u16 {
+        let catalog = Arc::new(unified_sql_lsp_catalog::StaticCatalog::new());
+        let server = TcpServer::with_options(0, catalog, options).await.unwrap();
+        let port = server.port();
+        tokio::spawn(async move { server.serve().await });
+        port
+    }
+
+    fn initialize_message() -> String {
+        serde_json::json!({
+            "jsonrpc": "2.0",
+            "id": 1,
+            "method": "initialize",
+            "params": {"capabilities": {}}
+        })
+        .to_string()
+    }
+
+    fn initialize_with_token(token: &str) -> String {
+        serde_json::json!({
+            "jsonrpc": "2.0",
+            "id": 1,
+            "method": "initialize",
+            "params": {"capabilities": {}, "initializationOptions": {"token": token}}
+        })
+        .to_string()
+    }
+
+    fn assert_unauthorized(response: &str) {
+        let response: JsonRpcResponse = serde_json::from_str(response).unwrap();
+        assert_eq!(response.id, serde_json::json!(1));
+        assert_eq!(
+            response.error.map(|e| e.code),
+            Some(UNAUTHORIZED_ERROR_CODE)
+        );
+    }
+
+    async fn connect_tcp(port: u16) -> impl Transport {
+        let stream = TcpStream::connect(("127.0.0.1", port)).await.unwrap();
+        let (reader, writer) = stream.into_split();
+        FramedTransport::new(reader, writer)
+    }
+
+    fn assert_initialized(response: &str) {
+        let response: JsonRpcResponse = serde_json::from_str(response).unwrap();
+        assert_eq!(response.id, serde_json::json!(1));
+        let result = response.result.unwrap();
+        assert_eq!(result["serverInfo"]["name"], "unified-sql-lsp");
+        assert_eq!(result["capabilities"]["hoverProvider"], true);
+    }
+
+    #[tokio::test]
+    async fn test_initialize_over_websocket() {
+        use futures_util::{SinkExt, StreamExt};
+        use tokio_tungstenite::tungstenite::Message;
+        use tokio_tungstenite::tungstenite::client::IntoClientRequest;
+
+        let port = start_server(TcpServerOptions {
+            transport: TransportKind::WebSocket,
+            path: Some("/lsp".to_string()),
+            auth_token: Some("s3cret".to_string()),
+        })
+        .await;
+        let url = format!("ws://127.0.0.1:{}/lsp", port);
+
+        let mut request = url.as_str().into_client_request().unwrap();
+        request
+            .headers_mut()
+            .insert(AUTHORIZATION, "Bearer s3cret".parse().unwrap());
+        let (mut socket, _) = tokio_tungstenite::connect_async(request).await.unwrap();
+
+        socket
+            .send(Message::Text(initialize_message()))
+            .await
+            .unwrap();
+        let response = socket.next().await.unwrap().unwrap();
+        assert_initialized(response.to_text().unwrap());
+
+        // A wrong token or path is refused during the handshake
+        let mut request = url.as_str().into_client_request().unwrap();
+        request
+            .headers_mut()
+            .insert(AUTHORIZATION, "Bearer guess".parse().unwrap());
+        assert!(tokio_tungstenite::connect_async(request).await.is_err());
+        let url = format!("ws://127.0.0.1:{}/other", port);
+        let mut request = url.as_str().into_client_request().unwrap();
+        request
+            .headers_mut()
+            .insert(AUTHORIZATION, "Bearer s3cret".parse().unwrap());
+        assert!(tokio_tungstenite::connect_async(request).await.is_err());
+    }
+
+    #[tokio::test]
+    async fn test_websocket_without_header_authenticates_in_initialize() {
+        use futures_util::{SinkExt, StreamExt};
+        use tokio_tungstenite::tungstenite::Message;
+
+        let port = start_server(TcpServerOptions {
+            auth_token: Some("s3cret".to_string()),
+            ..Default::default()
+        })
+        .await;
+        let url = format!("ws://127.0.0.1:{}", port);
+
+        // Browsers cannot set headers on WebSocket connections
+        let (mut socket, _) = tokio_tungstenite::connect_async(url.as_str())
+            .await
+            .unwrap();
+        socket
+            .send(Message::Text(initialize_with_token("s3cret")))
+            .await
+            .unwrap();
+        let response = socket.next().await.unwrap().unwrap();
+        assert_initialized(response.to_text().unwrap());
+
+        // Without the token, the client is answered with an error and dropped
+        let (mut socket, _) = tokio_tungstenite::connect_async(url.as_str())
+            .await
+            .unwrap();
+        socket
+            .send(Message::Text(initialize_message()))
+            .await
+            .unwrap();
+        let response = socket.next().await.unwrap().unwrap();
+        assert_unauthorized(response.to_text().unwrap());
+        assert!(matches!(
+            socket.next().await,
+            None | Some(Ok(Message::Close(_))) | Some(Err(_))
+        ));
+    }
+
+    #[tokio::test]
+    async fn test_initialize_over_tcp() {
+        let port = start_server(TcpServerOptions {
+            transport: TransportKind::Tcp,
+            ..Default::default()
+        })
+        .await;
+
+        let mut client = connect_tcp(port).await;
+        client.write_message(&initialize_message()).await.unwrap();
+
+        let response = client.read_message().await.unwrap().unwrap();
+        assert_initialized(&response);
+    }
+
+    #[tokio::test]
+    async fn test_tcp_token_is_required() {
+        let port = start_server(TcpServerOptions {
+            transport: TransportKind::Tcp,
+            auth_token: Some("s3cret".to_string()),
+            ..Default::default()
+        })
+        .await;
+
+        let mut client = connect_tcp(port).await;
+        client
+            .write_message(&initialize_with_token("s3cret"))
+            .await
+            .unwrap();
+        assert_initialized(&client.read_message().await.unwrap().unwrap());
+
+        for first in [initialize_message(), initialize_with_token("guess")] {
+            let mut client = connect_tcp(port).await;
+            client.write_message(&first).await.unwrap();
+            assert_unauthorized(&client.read_message().await.unwrap().unwrap());
+            assert_eq!(client.read_message().await.unwrap(), None);
+        }
+
+        // Other requests cannot come first
+        let mut client = connect_tcp(port).await;
+        let hover = serde_json::json!({
+            "jsonrpc": "2.0", "id": 1, "method": "textDocument/hover", "params": null
+        });
+        client.write_message(&hover.to_string()).await.unwrap();
+        assert_unauthorized(&client.read_message().await.unwrap().unwrap());
+    }
+
+    #[tokio::test]
+    async fn test_silent_client_is_dropped() {
+        let (_client, server) = tokio::io::duplex(1024);
+        let (reader, writer) = tokio::io::split(server);
+        let mut transport = FramedTransport::new(reader, writer);
+
+        let authenticated = authenticate(&mut transport, "s3cret", Duration::from_millis(20)).await;
+        assert_eq!(authenticated.unwrap(), None);
+    }
 }
@@ -0,0 +1,322 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Transports for network sessions
+//!
+//! This module moves JSON-RPC messages between the TCP server and a client.
+//! Each transport carries one session:
+//!
+//! - **WebSocket** (`--transport ws`, the default): one text frame per
+//!   message, for browser-based editors. The server pings idle clients and
+//!   drops those that stop answering.
+//! - **TCP** (`--transport tcp`): the LSP base protocol (`Content-Length`
+//!   headers), as spoken over stdio, for editors connecting to a socket.
+
+use async_trait::async_trait;
+use futures_util::{SinkExt, StreamExt};
+use std::io;
+use std::str::FromStr;
+use std::time::Duration;
+use tokio::io::{AsyncBufReadExt, AsyncRead, AsyncReadExt, AsyncWrite, AsyncWriteExt, BufReader};
+use tokio::time::{Instant, Interval};
+use tokio_tungstenite::WebSocketStream;
+use tokio_tungstenite::tungstenite::protocol::Message;
+
+/// Interval between keepalive pings on idle WebSocket connections
+pub const KEEPALIVE_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Two-way channel of JSON-RPC messages with one client
+#[async_trait]
+pub trait Transport: Send {
+    /// Read the next message
+    ///
+    /// # Returns
+    ///
+    /// `None` when the client closed the connection
+    async fn read_message(&mut self) -> io::Result<Option<String>>;
+
+    /// Send a message
+    async fn write_message(&mut self, message: &str) -> io::Result<()>;
+
+    /// Close the connection
+    async fn close(&mut self) -> io::Result<()>;
+}
+
+/// Transport spoken by the TCP server
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum TransportKind {
+    /// LSP base protocol over a raw socket
+    Tcp,
+
+    /// One message per WebSocket text frame
+    #[default]
+    WebSocket,
+}
+
+impl FromStr for TransportKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "tcp" => Ok(Self::Tcp),
+            "ws" | "websocket" => Ok(Self::WebSocket),
+            other => Err(format!(
+                "unknown transport '{}', expected 'tcp' or 'ws'",
+                other
+            )),
+        }
+    }
+}
+
+/// Messages framed by the LSP base protocol
+///
+/// ```text,ignore
+/// Content-Length: 52\r\n
+/// \r\n
+/// {"jsonrpc":"2.0","id":1,"method":"initialize",...}
+/// ```
+pub struct FramedTransport<R, W> {
+    reader: BufReader<R>,
+    writer: W,
+}
+
+impl<R, W> FramedTransport<R, W>
+where
+    R: AsyncRead + Unpin + Send,
+    W: AsyncWrite + Unpin + Send,
+{
+    /// Create a transport over a byte stream
+    pub fn new(reader: R, writer: W) -> Self {
+        Self {
+            reader: BufReader::new(reader),
+            writer,
+        }
+    }
+}
+
+#[async_trait]
+impl<R, W> Transport for FramedTransport<R, W>
+where
+    R: AsyncRead + Unpin + Send,
+    W: AsyncWrite + Unpin + Send,
+{
+    async fn read_message(&mut self) -> io::Result<Option<String>> {
+        let mut content_length = None;
+        loop {
+            let mut line = String::new();
+            if self.reader.read_line(&mut line).await? == 0 {
+                return Ok(None);
+            }
+            let line = line.trim_end();
+            if line.is_empty() {
+                break;
+            }
+            if let Some((name, value)) = line.split_once(':')
+                && name.eq_ignore_ascii_case("Content-Length")
+            {
+                content_length = Some(value.trim().parse::<usize>().map_err(|e| {
+                    io::Error::new(io::ErrorKind::InvalidData, format!("{}: {}", line, e))
+                })?);
+            }
+        }
+
+        let content_length = content_length.ok_or_else(|| {
+            io::Error::new(io::ErrorKind::InvalidData, "missing Content-Length header")
+        })?;
+        let mut body = vec![0; content_length];
+        self.reader.read_exact(&mut body).await?;
+        String::from_utf8(body)
+            .map(Some)
+            .map_err(|e| io::Error::new(io::ErrorKind::InvalidData, e))
+    }
+
+    async fn write_message(&mut self, message: &str) -> io::Result<()> {
+        let header = format!("Content-Length: {}\r\n\r\n", message.len());
+        self.writer.write_all(header.as_bytes()).await?;
+        self.writer.write_all(message.as_bytes()).await?;
+        self.writer.flush().await
+    }
+
+    async fn close(&mut self) -> io::Result<()> {
+        self.writer.shutdown().await
+    }
+}
+
+/// Messages carried in WebSocket text frames
+///
+/// Pings from the client are answered automatically. The server pings the
+/// client every [`KEEPALIVE_INTERVAL`] and gives up when nothing was heard
+/// for two intervals.
+pub struct WebSocketTransport<S> {
+    stream: WebSocketStream<S>,
+    keepalive: Interval,
+    interval: Duration,
+    last_seen: Instant,
+}
+
+impl<S> WebSocketTransport<S>
+where
+    S: AsyncRead + AsyncWrite + Unpin + Send,
+{
+    /// Create a transport over an established WebSocket connection
+    ///
+    /// # Arguments
+    ///
+    /// * `stream` - The WebSocket connection
+    /// * `interval` - Interval between keepalive pings
+    pub fn new(stream: WebSocketStream<S>, interval: Duration) -> Self {
+        Self {
+            stream,
+            keepalive: tokio::time::interval_at(Instant::now() + interval, interval),
+            interval,
+            last_seen: Instant::now(),
+        }
+    }
+}
+
+#[async_trait]
+impl<S> Transport for WebSocketTransport<S>
+where
+    S: AsyncRead + AsyncWrite + Unpin + Send,
+{
+    async fn read_message(&mut self) -> io::Result<Option<String>> {
+        loop {
+            tokio::select! {
+                message = self.stream.next() => {
+                    self.last_seen = Instant::now();
+                    match message {
+                        None | Some(Ok(Message::Close(_))) => return Ok(None),
+                        Some(Ok(Message::Text(text))) => return Ok(Some(text)),
+                        Some(Ok(Message::Binary(bytes))) => {
+                            return String::from_utf8(bytes)
+                                .map(Some)
+                                .map_err(|e| io::Error::new(io::ErrorKind::InvalidData, e));
+                        }
+                        // Pings are answered by the protocol layer
+                        Some(Ok(_)) => {}
+                        Some(Err(e)) => return Err(io::Error::other(e)),
+                    }
+                }
+                _ = self.keepalive.tick() => {
+                    if self.last_seen.elapsed() > self.interval * 2 {
+                        return Err(io::Error::new(
+                            io::ErrorKind::TimedOut,
+                            "client stopped answering pings",
+                        ));
+                    }
+                    self.stream
+                        .send(Message::Ping(Vec::new()))
+                        .await
+                        .map_err(io::Error::other)?;
+                }
+            }
+        }
+    }
+
+    async fn write_message(&mut self, message: &str) -> io::Result<()> {
+        self.stream
+            .send(Message::Text(message.to_string()))
+            .await
+            .map_err(io::Error::other)
+    }
+
+    async fn close(&mut self) -> io::Result<()> {
+        self.stream.close(None).await.map_err(io::Error::other)
+    }
+}
+
+/// Generate a random authentication token
+///
+/// # Returns
+///
+/// 32 random bytes, hex-encoded
+pub fn generate_token() -> io::Result<String> {
+    let mut bytes = [0u8; 32];
+    getrandom::getrandom(&mut bytes).map_err(io::Error::other)?;
+    Ok(bytes.iter().map(|byte| format!("{:02x}", byte)).collect())
+}
+
+/// Compare a presented token with the expected one in constant time
+///
+/// The comparison takes the same time wherever the tokens differ, so the
+/// expected token cannot be guessed byte by byte from response times.
+pub fn tokens_match(presented: &str, expected: &str) -> bool {
+    let (presented, expected) = (presented.as_bytes(), expected.as_bytes());
+    let mut difference = presented.len() ^ expected.len();
+    for (i, byte) in expected.iter().enumerate() {
+        difference |= usize::from(byte ^ presented.get(i).copied().unwrap_or(!byte));
+    }
+    difference == 0
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_framed_round_trip() {
+        let (client, server) = tokio::io::duplex(1024);
+        let (client_read, client_write) = tokio::io::split(client);
+        let (server_read, server_write) = tokio::io::split(server);
+        let mut client = FramedTransport::new(client_read, client_write);
+        let mut server = FramedTransport::new(server_read, server_write);
+
+        client
+            .write_message(r#"{"jsonrpc":"2.0","method":"exit"}"#)
+            .await
+            .unwrap();
+        client.write_message("{\"text\":\"é\"}").await.unwrap();
+        client.close().await.unwrap();
+
+        assert_eq!(
+            server.read_message().await.unwrap().as_deref(),
+            Some(r#"{"jsonrpc":"2.0","method":"exit"}"#)
+        );
+        assert_eq!(
+            server.read_message().await.unwrap().as_deref(),
+            Some("{\"text\":\"é\"}")
+        );
+        assert_eq!(server.read_message().await.unwrap(), None);
+    }
+
+    #[tokio::test]
+    async fn test_framed_rejects_missing_length() {
+        let (mut client, server) = tokio::io::duplex(1024);
+        let (server_read, server_write) = tokio::io::split(server);
+        let mut server = FramedTransport::new(server_read, server_write);
+
+        client
+            .write_all(b"Content-Type: application/json\r\n\r\n{}")
+            .await
+            .unwrap();
+
+        let error = server.read_message().await.unwrap_err();
+        assert_eq!(error.kind(), io::ErrorKind::InvalidData);
+    }
+
+    #[test]
+    fn test_transport_kind() {
+        assert_eq!("tcp".parse(), Ok(TransportKind::Tcp));
+        assert_eq!("ws".parse(), Ok(TransportKind::WebSocket));
+        assert!("udp".parse::<TransportKind>().is_err());
+    }
+
+    #[test]
+    fn test_generate_token() {
+        let token = generate_token().unwrap();
+        assert_eq!(token.len(), 64);
+        assert!(token.chars().all(|c| c.is_ascii_hexdigit()));
+        assert_ne!(token, generate_token().unwrap());
+    }
+
+    #[test]
+    fn test_tokens_match() {
+        assert!(tokens_match("s3cret", "s3cret"));
+        assert!(!tokens_match("s3creT", "s3cret"));
+        assert!(!tokens_match("s3cre", "s3cret"));
+        assert!(!tokens_match("s3cret!", "s3cret"));
+        assert!(!tokens_match("", "s3cret"));
+    }
+}
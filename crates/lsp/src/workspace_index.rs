@@ -0,0 +1,664 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Workspace index
+//!
+//! This module indexes the `.sql` files of the workspace folders, including
+//! files that are not open, so references are found across the workspace.
+//!
+//! ## Overview
+//!
+//! - Files are discovered by walking the workspace folders, skipping paths
+//!   ignored by `.gitignore` files and files over the configured size, up to
+//!   the configured number of files (see [`WorkspaceIndexConfig`])
+//! - Each file is parsed and the tables and columns it mentions are recorded
+//! - The index follows `workspace/didChangeWatchedFiles` notifications for
+//!   files on disk and the content of open documents
+//!
+//! Indexing runs in the background with progress (see [`index_workspace`])
+//! and stops at the next file when its task is aborted.
+//!
+//! Identifiers are matched case-insensitively on their last component, so
+//! `public.users` and `USERS` are the same table.
+
+use serde_json::Value;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::RwLock;
+use tower_lsp::lsp_types::{Location, Position, Range, Url};
+use tracing::{debug, warn};
+use unified_sql_lsp_ir::Dialect;
+use unified_sql_lsp_semantic::{NamedReference, SchemaDiagnosticAnalyzer, SyntaxRange};
+
+use crate::commands::CommandProgress;
+use crate::lint::{glob_match, glob_match_anchored};
+use crate::parsing::{ParseResult, ParserManager};
+
+/// Default maximum number of indexed files
+pub const DEFAULT_MAX_INDEXED_FILES: usize = 5000;
+
+/// Default maximum size of an indexed file in KiB
+pub const DEFAULT_MAX_INDEXED_FILE_SIZE_KB: u64 = 1024;
+
+/// Title of the indexing progress
+pub const INDEX_PROGRESS_TITLE: &str = "Indexing SQL files";
+
+/// Workspace index configuration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct WorkspaceIndexConfig {
+    /// Index the workspace folders
+    pub enabled: bool,
+
+    /// Maximum number of indexed files
+    pub max_files: usize,
+
+    /// Maximum size of an indexed file in KiB
+    pub max_file_size_kb: u64,
+}
+
+impl Default for WorkspaceIndexConfig {
+    fn default() -> Self {
+        Self {
+            enabled: true,
+            max_files: DEFAULT_MAX_INDEXED_FILES,
+            max_file_size_kb: DEFAULT_MAX_INDEXED_FILE_SIZE_KB,
+        }
+    }
+}
+
+impl WorkspaceIndexConfig {
+    /// Parse index configuration from the `workspaceIndex` settings object
+    pub fn from_settings(settings: &Value) -> Self {
+        let mut config = Self::default();
+
+        if let Some(enabled) = settings.get("enabled").and_then(Value::as_bool) {
+            config.enabled = enabled;
+        }
+        if let Some(max_files) = settings.get("maxFiles").and_then(Value::as_u64) {
+            config.max_files = max_files as usize;
+        }
+        if let Some(max_size) = settings.get("maxFileSizeKb").and_then(Value::as_u64) {
+            config.max_file_size_kb = max_size;
+        }
+
+        config
+    }
+
+    /// Check whether a file is small enough to index
+    fn accepts_size(&self, bytes: u64) -> bool {
+        bytes <= self.max_file_size_kb * 1024
+    }
+}
+
+/// Kind of an indexed identifier
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum IdentifierKind {
+    Table,
+    Column,
+}
+
+/// An identifier occurrence in an indexed file
+#[derive(Debug, Clone)]
+struct Occurrence {
+    kind: IdentifierKind,
+    /// Normalized name (see [`identifier_key`])
+    key: String,
+    range: Range,
+}
+
+/// Identifiers mentioned by the files of the workspace
+#[derive(Debug, Default)]
+pub struct WorkspaceIndex {
+    files: HashMap<Url, Vec<Occurrence>>,
+}
+
+impl WorkspaceIndex {
+    /// Create an empty index
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Number of indexed files
+    pub fn len(&self) -> usize {
+        self.files.len()
+    }
+
+    /// Check whether no file is indexed
+    pub fn is_empty(&self) -> bool {
+        self.files.is_empty()
+    }
+
+    /// Index a file from its content, replacing its previous entry
+    ///
+    /// Files that cannot be parsed are removed from the index.
+    pub fn index_source(&mut self, uri: Url, source: &str, dialect: Dialect) {
+        self.insert(uri, source_occurrences(source, dialect));
+    }
+
+    /// Index a file from its parse tree, replacing its previous entry
+    pub fn index_tree(&mut self, uri: Url, tree: &tree_sitter::Tree, source: &str) {
+        self.files.insert(uri, tree_occurrences(tree, source));
+    }
+
+    /// Record the occurrences of a file, or remove it if it was not parsed
+    fn insert(&mut self, uri: Url, occurrences: Option<Vec<Occurrence>>) {
+        match occurrences {
+            Some(occurrences) => {
+                self.files.insert(uri, occurrences);
+            }
+            None => {
+                debug!("Failed to parse {}, removing it from the index", uri);
+                self.files.remove(&uri);
+            }
+        }
+    }
+
+    /// Remove a file from the index
+    ///
+    /// Returns whether the file was indexed.
+    pub fn remove(&mut self, uri: &Url) -> bool {
+        self.files.remove(uri).is_some()
+    }
+
+    /// Identifier at a position of an indexed file
+    pub fn identifier_at(&self, uri: &Url, position: Position) -> Option<(IdentifierKind, String)> {
+        self.files
+            .get(uri)?
+            .iter()
+            .find(|o| o.range.start <= position && position <= o.range.end)
+            .map(|o| (o.kind, o.key.clone()))
+    }
+
+    /// Locations of an identifier across the indexed files
+    ///
+    /// # Returns
+    ///
+    /// The locations ordered by file and position
+    pub fn references(&self, kind: IdentifierKind, name: &str) -> Vec<Location> {
+        let key = identifier_key(name);
+        let mut locations: Vec<Location> = self
+            .files
+            .iter()
+            .flat_map(|(uri, occurrences)| {
+                occurrences
+                    .iter()
+                    .filter(|o| o.kind == kind && o.key == key)
+                    .map(|o| Location::new(uri.clone(), o.range))
+            })
+            .collect();
+        locations
+            .sort_by(|a, b| (a.uri.as_str(), a.range.start).cmp(&(b.uri.as_str(), b.range.start)));
+        locations
+    }
+
+    /// Files mentioning an identifier, in URI order
+    pub fn files_mentioning(&self, kind: IdentifierKind, name: &str) -> Vec<Url> {
+        let mut files: Vec<Url> = self
+            .references(kind, name)
+            .into_iter()
+            .map(|location| location.uri)
+            .collect();
+        files.dedup();
+        files
+    }
+}
+
+/// Parse a file and collect its identifier occurrences
+///
+/// Returns `None` when the file cannot be parsed.
+fn source_occurrences(source: &str, dialect: Dialect) -> Option<Vec<Occurrence>> {
+    match ParserManager::new().parse_text(dialect, source) {
+        ParseResult::Success {
+            tree: Some(tree), ..
+        }
+        | ParseResult::Partial {
+            tree: Some(tree), ..
+        } => Some(tree_occurrences(&tree, source)),
+        _ => None,
+    }
+}
+
+/// Collect the identifier occurrences of a parse tree
+fn tree_occurrences(tree: &tree_sitter::Tree, source: &str) -> Vec<Occurrence> {
+    let references = SchemaDiagnosticAnalyzer::new().collect_references(tree, source);
+    let occurrence = |kind, reference: NamedReference| Occurrence {
+        kind,
+        key: identifier_key(&reference.name),
+        range: syntax_range_to_lsp(reference.range),
+    };
+
+    references
+        .tables()
+        .map(|r| occurrence(IdentifierKind::Table, r))
+        .chain(
+            references
+                .columns()
+                .map(|r| occurrence(IdentifierKind::Column, r)),
+        )
+        .collect()
+}
+
+/// Normalize an identifier: last component, lowercase
+fn identifier_key(name: &str) -> String {
+    name.rsplit('.').next().unwrap_or(name).to_lowercase()
+}
+
+/// Convert an analyzer range to an LSP Range
+fn syntax_range_to_lsp(range: SyntaxRange) -> Range {
+    Range::new(
+        Position::new(range.start_line, range.start_character),
+        Position::new(range.end_line, range.end_character),
+    )
+}
+
+/// A `.gitignore` pattern
+#[derive(Debug, Clone)]
+struct IgnoreRule {
+    /// Directory of the `.gitignore` file, relative to the workspace folder
+    base: String,
+    pattern: String,
+    /// Matches the whole path below `base` rather than any trailing part
+    anchored: bool,
+    /// Matches directories only
+    dir_only: bool,
+    /// Re-includes paths ignored by earlier rules
+    negated: bool,
+}
+
+/// `.gitignore` rules in effect in a directory
+#[derive(Debug, Clone, Default)]
+struct IgnoreRules {
+    rules: Vec<IgnoreRule>,
+}
+
+impl IgnoreRules {
+    /// Add the rules of a directory's `.gitignore` file, if any
+    fn load(&mut self, base: &str, dir: &Path) {
+        let Ok(content) = std::fs::read_to_string(dir.join(".gitignore")) else {
+            return;
+        };
+
+        for line in content.lines() {
+            let line = line.trim_end();
+            if line.is_empty() || line.starts_with('#') {
+                continue;
+            }
+            let (negated, line) = match line.strip_prefix('!') {
+                Some(rest) => (true, rest),
+                None => (false, line),
+            };
+            let (dir_only, line) = match line.strip_suffix('/') {
+                Some(rest) => (true, rest),
+                None => (false, line),
+            };
+            let anchored = line.contains('/');
+            self.rules.push(IgnoreRule {
+                base: base.to_string(),
+                pattern: line.trim_start_matches('/').to_string(),
+                anchored,
+                dir_only,
+                negated,
+            });
+        }
+    }
+
+    /// Check whether a path relative to the workspace folder is ignored
+    ///
+    /// The last matching rule wins, as in git.
+    fn is_ignored(&self, path: &str, is_dir: bool) -> bool {
+        let mut ignored = false;
+        for rule in &self.rules {
+            let relative = match rule.base.as_str() {
+                "" => path,
+                base => match path
+                    .strip_prefix(base)
+                    .and_then(|rest| rest.strip_prefix('/'))
+                {
+                    Some(rest) => rest,
+                    None => continue,
+                },
+            };
+            if rule.dir_only && !is_dir {
+                continue;
+            }
+            let matches = match rule.anchored {
+                true => glob_match_anchored(&rule.pattern, relative),
+                false => glob_match(&rule.pattern, relative),
+            };
+            if matches {
+                ignored = !rule.negated;
+            }
+        }
+        ignored
+    }
+}
+
+/// Check whether a path is a SQL file
+fn is_sql_file(path: &Path) -> bool {
+    path.extension()
+        .and_then(|ext| ext.to_str())
+        .is_some_and(|ext| ext.eq_ignore_ascii_case("sql"))
+}
+
+/// Path relative to a workspace folder, with `/` separators
+pub(crate) fn relative_path(root: &Path, path: &Path) -> Option<String> {
+    let relative = path.strip_prefix(root).ok()?;
+    let components: Vec<&str> = relative
+        .components()
+        .map(|c| c.as_os_str().to_str())
+        .collect::<Option<_>>()?;
+    Some(components.join("/"))
+}
+
+/// Find the SQL files of a workspace folder
+///
+/// Directories named `.git` and paths ignored by `.gitignore` files are
+/// skipped, as are files over the size limit.
+///
+/// # Arguments
+///
+/// * `root` - Workspace folder
+/// * `config` - Index limits
+///
+/// # Returns
+///
+/// At most `config.max_files` paths, in directory order
+pub fn discover_sql_files(root: &Path, config: &WorkspaceIndexConfig) -> Vec<PathBuf> {
+    let mut files = Vec::new();
+    let mut root_rules = IgnoreRules::default();
+    root_rules.load("", root);
+    let mut pending = vec![(root.to_path_buf(), root_rules)];
+
+    while let Some((dir, rules)) = pending.pop() {
+        let Ok(entries) = std::fs::read_dir(&dir) else {
+            warn!("Failed to read directory {}", dir.display());
+            continue;
+        };
+        let mut entries: Vec<PathBuf> = entries.filter_map(|e| e.ok().map(|e| e.path())).collect();
+        entries.sort();
+
+        let mut subdirs = Vec::new();
+        for path in entries {
+            let Some(relative) = relative_path(root, &path) else {
+                continue;
+            };
+            // Symbolic links to directories are not followed
+            let is_dir = path.symlink_metadata().is_ok_and(|m| m.is_dir());
+            if (is_dir && path.file_name().is_some_and(|name| name == ".git"))
+                || rules.is_ignored(&relative, is_dir)
+            {
+                continue;
+            }
+
+            if is_dir {
+                let mut dir_rules = rules.clone();
+                dir_rules.load(&relative, &path);
+                subdirs.push((path, dir_rules));
+            } else if is_sql_file(&path)
+                && std::fs::metadata(&path).is_ok_and(|m| config.accepts_size(m.len()))
+            {
+                if files.len() == config.max_files {
+                    warn!(
+                        "Workspace index limited to {} files, skipping the rest of {}",
+                        config.max_files,
+                        root.display()
+                    );
+                    return files;
+                }
+                files.push(path);
+            }
+        }
+        // Visit subdirectories in order
+        pending.extend(subdirs.into_iter().rev());
+    }
+
+    files
+}
+
+/// Check whether a file of a workspace folder is indexed by the workspace
+/// walk: a SQL file within the size limit and not ignored
+pub fn is_indexable(root: &Path, path: &Path, config: &WorkspaceIndexConfig) -> bool {
+    let Some(relative) = relative_path(root, path) else {
+        return false;
+    };
+    if !is_sql_file(path)
+        || !std::fs::metadata(path).is_ok_and(|m| m.is_file() && config.accepts_size(m.len()))
+    {
+        return false;
+    }
+
+    // Apply the `.gitignore` files of every directory on the way down
+    let mut rules = IgnoreRules::default();
+    rules.load("", root);
+    let components: Vec<&str> = relative.split('/').collect();
+    let mut current = String::new();
+    for dir in &components[..components.len() - 1] {
+        if !current.is_empty() {
+            current.push('/');
+        }
+        current.push_str(dir);
+        if *dir == ".git" || rules.is_ignored(&current, true) {
+            return false;
+        }
+        rules.load(&current, &root.join(&current));
+    }
+
+    !rules.is_ignored(&relative, false)
+}
+
+/// Index the SQL files of workspace folders
+///
+/// Files are discovered, read and parsed on the blocking thread pool, one
+/// file at a time; aborting the task stops indexing before the next file.
+/// The index is only locked to record each file. Progress is reported per
+/// file.
+///
+/// # Returns
+///
+/// The number of indexed files
+pub(crate) async fn index_workspace(
+    index: &RwLock<WorkspaceIndex>,
+    roots: Vec<PathBuf>,
+    config: WorkspaceIndexConfig,
+    dialect: Dialect,
+    progress: &dyn CommandProgress,
+) -> usize {
+    if !config.enabled || roots.is_empty() {
+        return 0;
+    }
+
+    let limits = config.clone();
+    let discovery = tokio::task::spawn_blocking(move || {
+        let mut files: Vec<PathBuf> = roots
+            .iter()
+            .flat_map(|root| discover_sql_files(root, &limits))
+            .collect();
+        files.truncate(limits.max_files);
+        files
+    });
+    let files = match discovery.await {
+        Ok(files) => files,
+        Err(e) => {
+            warn!("Workspace file discovery failed: {}", e);
+            return 0;
+        }
+    };
+
+    progress.begin(INDEX_PROGRESS_TITLE).await;
+    let mut indexed = 0;
+    for (position, path) in files.iter().enumerate() {
+        progress
+            .report(
+                format!("{}/{}", position + 1, files.len()),
+                (position * 100 / files.len()) as u32,
+            )
+            .await;
+
+        let file = path.clone();
+        let scan = tokio::task::spawn_blocking(move || {
+            let source = std::fs::read_to_string(&file).ok()?;
+            Some(source_occurrences(&source, dialect))
+        });
+        // Awaiting the scan is the cancellation point between files
+        let (Ok(uri), Ok(Some(occurrences))) = (Url::from_file_path(path), scan.await) else {
+            debug!("Skipping unreadable file {}", path.display());
+            continue;
+        };
+        index.write().unwrap().insert(uri, occurrences);
+        indexed += 1;
+    }
+
+    progress.end(format!("Indexed {} SQL files", indexed)).await;
+    indexed
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Workspace folder with ignored files, removed when dropped
+    struct Fixture(PathBuf);
+
+    impl Fixture {
+        fn new(name: &str) -> Self {
+            let root = std::env::temp_dir().join(format!(
+                "unified-sql-lsp-index-{}-{}",
+                name,
+                std::process::id()
+            ));
+            let _ = std::fs::remove_dir_all(&root);
+
+            let files = [
+                (".gitignore", "build/\n*.generated.sql\n/scratch.sql\n"),
+                ("queries/users.sql", "SELECT id, name FROM users;"),
+                (
+                    "queries/orders.sql",
+                    "SELECT o.id FROM orders o JOIN users u ON u.id = o.user_id;",
+                ),
+                ("queries/report.generated.sql", "SELECT 1 FROM users;"),
+                ("queries/.gitignore", "old/\n!keep.generated.sql\n"),
+                ("queries/keep.generated.sql", "SELECT 1 FROM users;"),
+                ("queries/old/users.sql", "SELECT 1 FROM users;"),
+                ("build/schema.sql", "CREATE TABLE users (id INT);"),
+                ("scratch.sql", "SELECT 1 FROM users;"),
+                ("nested/scratch.sql", "DELETE FROM users;"),
+                (".git/hooks/check.sql", "SELECT 1;"),
+                ("README.md", "users"),
+            ];
+            for (path, content) in files {
+                let path = root.join(path);
+                std::fs::create_dir_all(path.parent().unwrap()).unwrap();
+                std::fs::write(path, content).unwrap();
+            }
+
+            Self(root)
+        }
+
+        fn relative(&self, files: &[PathBuf]) -> Vec<String> {
+            files
+                .iter()
+                .map(|path| relative_path(&self.0, path).unwrap())
+                .collect()
+        }
+    }
+
+    impl Drop for Fixture {
+        fn drop(&mut self) {
+            let _ = std::fs::remove_dir_all(&self.0);
+        }
+    }
+
+    fn uri(path: &str) -> Url {
+        Url::parse(&format!("file:///workspace/{}", path)).unwrap()
+    }
+
+    #[test]
+    fn test_discovery_respects_gitignore() {
+        let fixture = Fixture::new("discovery");
+        let files = discover_sql_files(&fixture.0, &WorkspaceIndexConfig::default());
+
+        assert_eq!(
+            fixture.relative(&files),
+            vec![
+                "nested/scratch.sql",
+                "queries/keep.generated.sql",
+                "queries/orders.sql",
+                "queries/users.sql",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_discovery_limits() {
+        let fixture = Fixture::new("limits");
+
+        let config = WorkspaceIndexConfig {
+            max_files: 2,
+            ..Default::default()
+        };
+        assert_eq!(discover_sql_files(&fixture.0, &config).len(), 2);
+
+        let config = WorkspaceIndexConfig {
+            max_file_size_kb: 0,
+            ..Default::default()
+        };
+        assert!(discover_sql_files(&fixture.0, &config).is_empty());
+    }
+
+    #[test]
+    fn test_single_file_checks_match_discovery() {
+        let fixture = Fixture::new("single");
+        let config = WorkspaceIndexConfig::default();
+        let indexable = |path: &str| is_indexable(&fixture.0, &fixture.0.join(path), &config);
+
+        assert!(indexable("queries/users.sql"));
+        assert!(indexable("queries/keep.generated.sql"));
+        assert!(indexable("nested/scratch.sql"));
+        assert!(!indexable("queries/report.generated.sql"));
+        assert!(!indexable("queries/old/users.sql"));
+        assert!(!indexable("build/schema.sql"));
+        assert!(!indexable("scratch.sql"));
+        assert!(!indexable(".git/hooks/check.sql"));
+        assert!(!indexable("README.md"));
+    }
+
+    #[test]
+    fn test_references_across_files() {
+        let mut index = WorkspaceIndex::new();
+        index.index_source(uri("a.sql"), "SELECT id, name FROM users;", Dialect::MySQL);
+        index.index_source(
+            uri("b.sql"),
+            "SELECT o.id FROM orders o JOIN Users u ON u.id = o.user_id;",
+            Dialect::MySQL,
+        );
+        assert_eq!(index.len(), 2);
+
+        let (kind, name) = index
+            .identifier_at(&uri("a.sql"), Position::new(0, 23))
+            .unwrap();
+        assert_eq!((kind, name.as_str()), (IdentifierKind::Table, "users"));
+        assert_eq!(
+            index.files_mentioning(kind, &name),
+            vec![uri("a.sql"), uri("b.sql")]
+        );
+        assert_eq!(
+            index.references(IdentifierKind::Table, "orders"),
+            vec![Location::new(
+                uri("b.sql"),
+                Range::new(Position::new(0, 17), Position::new(0, 23))
+            )]
+        );
+        assert_eq!(
+            index.files_mentioning(IdentifierKind::Column, "name"),
+            vec![uri("a.sql")]
+        );
+
+        assert!(index.remove(&uri("a.sql")));
+        assert_eq!(
+            index.files_mentioning(IdentifierKind::Table, "users"),
+            vec![uri("b.sql")]
+        );
+    }
+}
@@ -9,6 +9,7 @@
 
 use std::sync::Arc;
 use tower_lsp::lsp_types::{Position, Url};
+use unified_sql_lsp_catalog::OfflineCatalog;
 use unified_sql_lsp_ir::Dialect;
 use unified_sql_lsp_lsp::completion::CompletionEngine;
 use unified_sql_lsp_lsp::document::{Document, ParseMetadata};
@@ -581,3 +582,64 @@ async fn test_keyword_completion_documentation() {
         );
     }
 }
+
+#[tokio::test]
+async fn test_completion_without_database_connection() {
+    // With no connection configured the server uses the offline catalog;
+    // keyword completion must still work and schema contexts must not fail.
+    let engine = CompletionEngine::new(Arc::new(OfflineCatalog::new()));
+
+    let document = create_test_document("", "mysql").await;
+    let items = engine
+        .complete(&document, Position::new(0, 0))
+        .await
+        .expect("Completion should not fail without a connection")
+        .expect("Should return statement keywords");
+    let labels: Vec<&str> = items.iter().map(|i| i.label.as_str()).collect();
+    assert!(labels.contains(&"SELECT"), "Missing SELECT keyword");
+
+    let sql = "SELECT * FROM users ";
+    let document = create_test_document(sql, "mysql").await;
+    let items = engine
+        .complete(&document, Position::new(0, sql.len() as u32))
+        .await
+        .expect("Completion should not fail without a connection")
+        .expect("Should return clause keywords");
+    let labels: Vec<&str> = items.iter().map(|i| i.label.as_str()).collect();
+    assert!(labels.contains(&"WHERE"), "Missing WHERE keyword");
+
+    let sql = "SELECT  FROM users";
+    let document = create_test_document(sql, "mysql").await;
+    let result = engine.complete(&document, Position::new(0, 7)).await;
+    assert!(
+        result.is_ok(),
+        "Projection completion should degrade, got {:?}",
+        result.err()
+    );
+}
+
+#[tokio::test]
+async fn test_join_condition_suggested_from_foreign_key() {
+    let catalog = MockCatalogBuilder::new().with_standard_schema().build();
+    let engine = CompletionEngine::new(Arc::new(catalog));
+
+    // orders.user_id references users.id in the standard schema
+    let sql = "SELECT * FROM users u JOIN orders o ON ";
+    let document = create_test_document(sql, "mysql").await;
+
+    let items = engine
+        .complete(&document, Position::new(0, sql.len() as u32))
+        .await
+        .expect("Completion should succeed")
+        .expect("Should return JOIN condition items");
+
+    let join = items
+        .iter()
+        .find(|i| i.label == "o.user_id = u.id")
+        .expect("Expected a JOIN condition derived from the foreign key");
+    assert_eq!(join.preselect, Some(true));
+    assert_eq!(
+        items[0].label, join.label,
+        "JOIN condition should rank first"
+    );
+}
@@ -449,3 +449,55 @@ async fn test_postgresql_syntax_error() {
         assert!(!diagnostics.is_empty());
     }
 }
+
+#[test]
+fn test_diagnostic_sources_per_trigger_from_settings() {
+    use unified_sql_lsp_lsp::EngineConfig;
+
+    let config = EngineConfig::from_lsp_settings(&serde_json::json!({
+        "unifiedSqlLsp": {
+            "dialect": "mysql",
+            "connectionString": "",
+            "diagnostics": { "onType": ["syntax", "lint"] },
+            "formatOnSave": true
+        }
+    }))
+    .unwrap();
+
+    let triggers = config.diagnostics;
+    assert!(triggers.on_type.syntax && triggers.on_type.lint);
+    assert!(!triggers.on_type.schema);
+    assert!(triggers.on_save.syntax && triggers.on_save.schema && triggers.on_save.lint);
+    assert!(config.format_on_save);
+
+    let defaults = EngineConfig::default();
+    assert!(defaults.diagnostics.on_type.schema);
+    assert!(!defaults.format_on_save);
+}
+
+#[test]
+fn test_identifier_rules_from_settings() {
+    use unified_sql_lsp_ir::{Dialect, IdentifierCase};
+    use unified_sql_lsp_lsp::EngineConfig;
+
+    let config = EngineConfig::from_lsp_settings(&serde_json::json!({
+        "unifiedSqlLsp": {
+            "dialect": "mysql",
+            "connectionString": "",
+            "identifiers": { "caseSensitiveTables": true, "fold": "lower" }
+        }
+    }))
+    .unwrap();
+
+    let rules = config.identifier_rules();
+    assert!(rules.case_sensitive_tables);
+    assert_eq!(rules.fold, IdentifierCase::Lower);
+    // Fields not set keep the rules of the dialect
+    assert_eq!(rules.quote, '`');
+
+    let defaults = EngineConfig {
+        dialect: Dialect::PostgreSQL,
+        ..Default::default()
+    };
+    assert!(defaults.identifier_rules().quoted_case_sensitive);
+}
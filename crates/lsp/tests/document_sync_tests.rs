@@ -80,6 +80,31 @@ fn test_document_sync_resolve_dialect_from_config() {
         log_queries: false,
         query_timeout_secs: 5,
         cache_enabled: false,
+        schema_cache_ttl_secs: 300,
+        slow_request_threshold_ms: 500,
+        request_budgets: Default::default(),
+        strict_dialect: false,
+        strict_version: false,
+        schema_diagnostics_severity: None,
+        lint: Default::default(),
+        execution: Default::default(),
+        health_check_interval_secs: 30,
+        tls: None,
+        ssh: None,
+        schema_file: None,
+        databases: None,
+        search_path: Vec::new(),
+        diagnostics: Default::default(),
+        diagnostic_severities: Default::default(),
+        format_on_save: false,
+        on_type_formatting: Default::default(),
+        workspace_index: Default::default(),
+        migrations: None,
+        embedded_sql: Default::default(),
+        keyword_case: Default::default(),
+        identifier_rules: None,
+        parameters: Default::default(),
+        log_level: None,
     };
 
     let config = Arc::new(RwLock::new(Some(engine_config)));
@@ -239,6 +264,31 @@ fn test_document_sync_config_overrides_language_id() {
         log_queries: false,
         query_timeout_secs: 30,
         cache_enabled: true,
+        schema_cache_ttl_secs: 300,
+        slow_request_threshold_ms: 500,
+        request_budgets: Default::default(),
+        strict_dialect: false,
+        strict_version: false,
+        schema_diagnostics_severity: None,
+        lint: Default::default(),
+        execution: Default::default(),
+        health_check_interval_secs: 30,
+        tls: None,
+        ssh: None,
+        schema_file: None,
+        databases: None,
+        search_path: Vec::new(),
+        diagnostics: Default::default(),
+        diagnostic_severities: Default::default(),
+        format_on_save: false,
+        on_type_formatting: Default::default(),
+        workspace_index: Default::default(),
+        migrations: None,
+        embedded_sql: Default::default(),
+        keyword_case: Default::default(),
+        identifier_rules: None,
+        parameters: Default::default(),
+        log_level: None,
     };
 
     let config = Arc::new(RwLock::new(Some(engine_config)));
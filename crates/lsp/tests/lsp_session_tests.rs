@@ -0,0 +1,257 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! End-to-end LSP session tests
+//!
+//! Tests the full pipeline, from framed JSON-RPC messages through the server
+//! to its responses and notifications, with the scripted client of
+//! `lsptest`.
+
+mod lsptest;
+
+use std::time::Duration;
+
+use lsptest::{TIMEOUT, TestClient};
+use tokio::time::Instant;
+use tower_lsp::lsp_types::{
+    Diagnostic, DiagnosticSeverity, InitializeParams, Position, Url, WorkspaceFolder,
+};
+use unified_sql_lsp_catalog::{ColumnMetadata, DataType, SchemaSnapshot, TableMetadata};
+
+/// Diagnostics of the parser
+const SYNTAX_SOURCE: &str = "unified-sql-lsp";
+
+/// Diagnostics of the migration files
+const MIGRATION_SOURCE: &str = "sql-migrations";
+
+#[tokio::test]
+async fn test_initialize_handshake() {
+    let mut client = TestClient::start();
+
+    let result = client.initialize().await;
+
+    assert_eq!(
+        result.server_info.map(|info| info.name).as_deref(),
+        Some("unified-sql-lsp")
+    );
+    let capabilities = result.capabilities;
+    assert!(capabilities.completion_provider.is_some());
+    assert!(capabilities.text_document_sync.is_some());
+
+    client.shutdown().await;
+}
+
+#[tokio::test]
+async fn test_completion_from_schema_file() {
+    let path = std::env::temp_dir().join(format!(
+        "unified-sql-lsp-session-schema-{}.json",
+        std::process::id()
+    ));
+    let users = TableMetadata::new("users", "shop")
+        .with_columns(vec![ColumnMetadata::new("id", DataType::Integer)]);
+    SchemaSnapshot::new(vec![users], vec![])
+        .save(&path)
+        .unwrap();
+
+    let mut client = TestClient::start();
+    client.initialize().await;
+    client
+        .configure(serde_json::json!({ "dialect": "mysql", "schemaFile": path }))
+        .await;
+    let uri = Url::parse("file:///session/query.sql").unwrap();
+    client.open_document(&uri, "mysql", "SELECT * FROM ").await;
+
+    // The schema loads in the background; keywords are offered meanwhile
+    let deadline = Instant::now() + TIMEOUT;
+    let labels = loop {
+        let items = client.request_completion(&uri, Position::new(0, 14)).await;
+        let labels: Vec<String> = items.into_iter().map(|item| item.label).collect();
+        if labels.iter().any(|label| label == "users") || Instant::now() > deadline {
+            break labels;
+        }
+        tokio::time::sleep(Duration::from_millis(50)).await;
+    };
+    assert!(labels.iter().any(|label| label == "users"), "{:?}", labels);
+
+    client.shutdown().await;
+    std::fs::remove_file(&path).unwrap();
+}
+
+#[tokio::test]
+async fn test_schema_refresh_drops_cached_completions() {
+    let path = std::env::temp_dir().join(format!(
+        "unified-sql-lsp-session-refresh-{}.json",
+        std::process::id()
+    ));
+    let save_tables = |names: &[&str]| {
+        let tables = names
+            .iter()
+            .map(|name| TableMetadata::new(*name, "shop"))
+            .collect();
+        SchemaSnapshot::new(tables, vec![]).save(&path).unwrap();
+    };
+    save_tables(&["users"]);
+
+    let mut client = TestClient::start();
+    client.initialize().await;
+    client
+        .configure(serde_json::json!({ "dialect": "mysql", "schemaFile": path }))
+        .await;
+    let uri = Url::parse("file:///session/refresh.sql").unwrap();
+    client.open_document(&uri, "mysql", "SELECT * FROM u").await;
+    let deadline = Instant::now() + TIMEOUT;
+    loop {
+        let items = client.request_completion(&uri, Position::new(0, 15)).await;
+        if items.iter().any(|item| item.label == "users") || Instant::now() > deadline {
+            break;
+        }
+        tokio::time::sleep(Duration::from_millis(50)).await;
+    }
+
+    // The narrowing query after the refresh sees the new table
+    save_tables(&["users", "user_roles"]);
+    client
+        .request(
+            "workspace/executeCommand",
+            serde_json::json!({ "command": "sql.refreshSchema", "arguments": [] }),
+        )
+        .await;
+    client.change_document(&uri, "SELECT * FROM us").await;
+    let items = client.request_completion(&uri, Position::new(0, 16)).await;
+    let labels: Vec<String> = items.into_iter().map(|item| item.label).collect();
+    assert!(
+        labels.iter().any(|label| label == "user_roles"),
+        "{:?}",
+        labels
+    );
+
+    client.shutdown().await;
+    std::fs::remove_file(&path).unwrap();
+}
+
+#[tokio::test]
+async fn test_diagnostics_publish_and_clear() {
+    let mut client = TestClient::start();
+    client.initialize().await;
+    let uri = Url::parse("file:///session/broken.sql").unwrap();
+    let has_syntax_error = |diagnostics: &[Diagnostic]| {
+        diagnostics
+            .iter()
+            .any(|diagnostic| diagnostic.source.as_deref() == Some(SYNTAX_SOURCE))
+    };
+
+    client
+        .open_document(&uri, "mysql", "SELECT id FROM users WHERE")
+        .await;
+    let diagnostics = client
+        .expect_diagnostics(&uri, TIMEOUT, has_syntax_error)
+        .await;
+    let error = diagnostics
+        .iter()
+        .find(|diagnostic| diagnostic.source.as_deref() == Some(SYNTAX_SOURCE))
+        .unwrap();
+    assert_eq!(error.severity, Some(DiagnosticSeverity::ERROR));
+
+    // Fixing the statement clears the syntax error
+    client
+        .change_document(&uri, "SELECT id FROM users WHERE id = 1")
+        .await;
+    client
+        .expect_diagnostics(&uri, TIMEOUT, |diagnostics| !has_syntax_error(diagnostics))
+        .await;
+
+    // Closing the document clears everything
+    client.close_document(&uri).await;
+    client
+        .expect_diagnostics(&uri, TIMEOUT, |diagnostics| diagnostics.is_empty())
+        .await;
+
+    client.shutdown().await;
+}
+
+#[tokio::test]
+async fn test_closed_input_ends_the_session() {
+    let mut client = TestClient::start();
+    client.initialize().await;
+    let uri = Url::parse("file:///session/orphan.sql").unwrap();
+    client.open_document(&uri, "mysql", "SELECT 1").await;
+
+    // The editor dies without sending shutdown and exit
+    client.close_input().await;
+
+    assert!(client.wait_for_exit(TIMEOUT).await);
+}
+
+#[tokio::test]
+async fn test_migration_reload_keeps_suppressions() {
+    let root = std::env::temp_dir().join(format!(
+        "unified-sql-lsp-session-migrations-{}",
+        std::process::id()
+    ));
+    let _ = std::fs::remove_dir_all(&root);
+    std::fs::create_dir_all(root.join("migrations")).unwrap();
+    let schema_file = root.join("schema.json");
+    SchemaSnapshot::new(vec![TableMetadata::new("users", "public")], vec![])
+        .save(&schema_file)
+        .unwrap();
+    let path = root.join("migrations/0001_drop.sql");
+    let source = "-- sql-lsp: disable-next-line MIGRATION-001\n\
+                  DROP TABLE orders;\n\
+                  DROP TABLE invoices;\n\
+                  DROP TABLE payments;\n";
+    // The last statement is not saved yet
+    let saved: Vec<&str> = source.lines().take(3).collect();
+    std::fs::write(&path, saved.join("\n")).unwrap();
+    let uri = Url::from_file_path(&path).unwrap();
+    let conflict_lines = |diagnostics: &[Diagnostic]| -> Vec<u32> {
+        diagnostics
+            .iter()
+            .filter(|diagnostic| diagnostic.source.as_deref() == Some(MIGRATION_SOURCE))
+            .map(|diagnostic| diagnostic.range.start.line)
+            .collect()
+    };
+
+    let mut client = TestClient::start();
+    client
+        .initialize_with(InitializeParams {
+            workspace_folders: Some(vec![WorkspaceFolder {
+                uri: Url::from_directory_path(&root).unwrap(),
+                name: "shop".to_string(),
+            }]),
+            ..Default::default()
+        })
+        .await;
+    client.open_document(&uri, "sql", source).await;
+    client
+        .configure(serde_json::json!({
+            "dialect": "postgresql",
+            "schemaFile": schema_file,
+            "migrations": "migrations/*.sql",
+        }))
+        .await;
+    client
+        .expect_diagnostics(&uri, TIMEOUT, |diagnostics| {
+            conflict_lines(diagnostics) == [2]
+        })
+        .await;
+
+    // Saving reloads the migrations; the suppressed conflict stays hidden
+    std::fs::write(&path, source).unwrap();
+    client
+        .notify(
+            "workspace/didChangeWatchedFiles",
+            serde_json::json!({ "changes": [{ "uri": uri, "type": 2 }] }),
+        )
+        .await;
+    let diagnostics = client
+        .expect_diagnostics(&uri, TIMEOUT, |diagnostics| {
+            conflict_lines(diagnostics).contains(&3)
+        })
+        .await;
+    assert_eq!(conflict_lines(&diagnostics), [2, 3]);
+
+    client.shutdown().await;
+    std::fs::remove_dir_all(&root).unwrap();
+}
@@ -0,0 +1,390 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Scripted LSP client
+//!
+//! This module drives the server the way an editor does: JSON-RPC messages
+//! framed with `Content-Length` headers, over an in-memory pipe, served by
+//! the same `tower_lsp::Server` the binary runs on stdio.
+//!
+//! ```rust,ignore
+//! let mut client = TestClient::start();
+//! client.initialize().await;
+//! client.open_document(&uri, "mysql", "SELEC 1").await;
+//! let diagnostics = client
+//!     .expect_diagnostics(&uri, TIMEOUT, |diagnostics| !diagnostics.is_empty())
+//!     .await;
+//! ```
+//!
+//! Requests the server sends to the client (`client/registerCapability`,
+//! `workspace/configuration`, ...) are answered as an editor accepting them
+//! would. Every message is recorded in a transcript, printed when a test
+//! fails.
+
+#![allow(dead_code)]
+
+use std::collections::{HashMap, VecDeque};
+use std::sync::{Arc, Mutex as StdMutex};
+use std::time::Duration;
+
+use serde_json::{Value, json};
+use tokio::io::{AsyncBufRead, AsyncBufReadExt, AsyncReadExt, AsyncWriteExt, BufReader};
+use tokio::io::{DuplexStream, ReadHalf, WriteHalf};
+use tokio::sync::{Mutex, mpsc};
+use tokio::task::JoinHandle;
+use tokio::time::Instant;
+use tower_lsp::lsp_types::{
+    CompletionItem, CompletionResponse, Diagnostic, InitializeParams, InitializeResult, Position,
+    PublishDiagnosticsParams, Url,
+};
+use tower_lsp::{LspService, Server};
+use unified_sql_lsp_lsp::backend::{LspBackend, STATS_METHOD};
+use unified_sql_lsp_lsp::diagnostic_scheduler::FOCUS_DOCUMENT_METHOD;
+
+/// Time a response may take before the test fails
+pub const TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Size of the in-memory pipe between the client and the server
+const PIPE_CAPACITY: usize = 64 * 1024;
+
+type Writer = Arc<Mutex<WriteHalf<DuplexStream>>>;
+type Transcript = Arc<StdMutex<Vec<String>>>;
+
+/// Fake editor connected to an in-process server
+pub struct TestClient {
+    writer: Writer,
+    /// Responses and notifications of the server, in order
+    incoming: mpsc::UnboundedReceiver<Value>,
+    /// Notifications received while waiting for a response
+    pending: VecDeque<Value>,
+    next_id: i64,
+    /// Versions of the open documents
+    versions: HashMap<Url, i32>,
+    transcript: Transcript,
+    /// Task serving the session
+    server: JoinHandle<()>,
+}
+
+impl TestClient {
+    /// Start a server and connect to it
+    ///
+    /// Must be called within a Tokio runtime; the server runs on a task of
+    /// that runtime until the client exits it or is dropped.
+    pub fn start() -> Self {
+        let (service, socket) = LspService::build(LspBackend::new)
+            .custom_method(STATS_METHOD, LspBackend::stats)
+            .custom_method(FOCUS_DOCUMENT_METHOD, LspBackend::focus_document)
+            .finish();
+        let (client, server) = tokio::io::duplex(PIPE_CAPACITY);
+        let (server_read, server_write) = tokio::io::split(server);
+        let server = tokio::spawn(Server::new(server_read, server_write, socket).serve(service));
+
+        let (client_read, client_write) = tokio::io::split(client);
+        let writer = Arc::new(Mutex::new(client_write));
+        let transcript = Arc::new(StdMutex::new(Vec::new()));
+        let (sender, incoming) = mpsc::unbounded_channel();
+        tokio::spawn(read_messages(
+            BufReader::new(client_read),
+            writer.clone(),
+            transcript.clone(),
+            sender,
+        ));
+
+        Self {
+            writer,
+            incoming,
+            pending: VecDeque::new(),
+            next_id: 1,
+            versions: HashMap::new(),
+            transcript,
+            server,
+        }
+    }
+
+    /// Send a request and wait for its result
+    ///
+    /// # Panics
+    ///
+    /// If the server answers with an error or does not answer within
+    /// [`TIMEOUT`]
+    pub async fn request(&mut self, method: &str, params: Value) -> Value {
+        let id = self.next_id;
+        self.next_id += 1;
+        let message = json!({ "jsonrpc": "2.0", "id": id, "method": method, "params": params });
+        write_message(&self.writer, &self.transcript, &message).await;
+
+        let deadline = Instant::now() + TIMEOUT;
+        loop {
+            let message = self
+                .next_message(deadline)
+                .await
+                .unwrap_or_else(|| panic!("No response to {} within {:?}", method, TIMEOUT));
+            if message.get("method").is_some() {
+                self.pending.push_back(message);
+                continue;
+            }
+            if message.get("id") != Some(&json!(id)) {
+                continue;
+            }
+            if let Some(error) = message.get("error") {
+                panic!("{} failed: {}", method, error);
+            }
+            return message.get("result").cloned().unwrap_or(Value::Null);
+        }
+    }
+
+    /// Send a notification
+    pub async fn notify(&mut self, method: &str, params: Value) {
+        let message = json!({ "jsonrpc": "2.0", "method": method, "params": params });
+        write_message(&self.writer, &self.transcript, &message).await;
+    }
+
+    /// Run the `initialize` handshake
+    ///
+    /// # Returns
+    ///
+    /// The capabilities and information of the server
+    pub async fn initialize(&mut self) -> InitializeResult {
+        self.initialize_with(InitializeParams::default()).await
+    }
+
+    /// Run the `initialize` handshake with parameters, e.g. workspace folders
+    pub async fn initialize_with(&mut self, params: InitializeParams) -> InitializeResult {
+        let params = serde_json::to_value(params).unwrap();
+        let result = self.request("initialize", params).await;
+        self.notify("initialized", json!({})).await;
+        serde_json::from_value(result).expect("Invalid initialize result")
+    }
+
+    /// Send the `unifiedSqlLsp` settings
+    pub async fn configure(&mut self, settings: Value) {
+        self.notify(
+            "workspace/didChangeConfiguration",
+            json!({ "settings": { "unifiedSqlLsp": settings } }),
+        )
+        .await;
+    }
+
+    /// Open a document
+    pub async fn open_document(&mut self, uri: &Url, language_id: &str, text: &str) {
+        self.versions.insert(uri.clone(), 1);
+        self.notify(
+            "textDocument/didOpen",
+            json!({
+                "textDocument": {
+                    "uri": uri,
+                    "languageId": language_id,
+                    "version": 1,
+                    "text": text,
+                }
+            }),
+        )
+        .await;
+    }
+
+    /// Replace the content of an open document
+    pub async fn change_document(&mut self, uri: &Url, text: &str) {
+        let version = self.versions.entry(uri.clone()).or_default();
+        *version += 1;
+        let version = *version;
+        self.notify(
+            "textDocument/didChange",
+            json!({
+                "textDocument": { "uri": uri, "version": version },
+                "contentChanges": [{ "text": text }],
+            }),
+        )
+        .await;
+    }
+
+    /// Close a document
+    pub async fn close_document(&mut self, uri: &Url) {
+        self.versions.remove(uri);
+        self.notify(
+            "textDocument/didClose",
+            json!({ "textDocument": { "uri": uri } }),
+        )
+        .await;
+    }
+
+    /// Request the completions at a position
+    ///
+    /// # Returns
+    ///
+    /// The items of the response, empty if the server has none
+    pub async fn request_completion(
+        &mut self,
+        uri: &Url,
+        position: Position,
+    ) -> Vec<CompletionItem> {
+        let result = self
+            .request(
+                "textDocument/completion",
+                json!({ "textDocument": { "uri": uri }, "position": position }),
+            )
+            .await;
+        match serde_json::from_value(result).expect("Invalid completion response") {
+            Some(CompletionResponse::Array(items)) => items,
+            Some(CompletionResponse::List(list)) => list.items,
+            None => Vec::new(),
+        }
+    }
+
+    /// Wait for the diagnostics of a document to satisfy a condition
+    ///
+    /// Publishes for other documents, and earlier publishes for this one,
+    /// are skipped.
+    ///
+    /// # Panics
+    ///
+    /// If no publish satisfies the condition within `timeout`
+    pub async fn expect_diagnostics(
+        &mut self,
+        uri: &Url,
+        timeout: Duration,
+        until: impl Fn(&[Diagnostic]) -> bool,
+    ) -> Vec<Diagnostic> {
+        let deadline = Instant::now() + timeout;
+        loop {
+            let message = match self.pending.pop_front() {
+                Some(message) => message,
+                None => self.next_message(deadline).await.unwrap_or_else(|| {
+                    panic!("No matching diagnostics for {} within {:?}", uri, timeout)
+                }),
+            };
+            if message.get("method").and_then(Value::as_str)
+                != Some("textDocument/publishDiagnostics")
+            {
+                continue;
+            }
+            let params: PublishDiagnosticsParams =
+                serde_json::from_value(message["params"].clone()).expect("Invalid diagnostics");
+            if params.uri == *uri && until(&params.diagnostics) {
+                return params.diagnostics;
+            }
+        }
+    }
+
+    /// Shut the server down and exit it
+    pub async fn shutdown(&mut self) {
+        self.request("shutdown", Value::Null).await;
+        self.notify("exit", Value::Null).await;
+    }
+
+    /// Close the input of the server, as an editor dying would
+    pub async fn close_input(&mut self) {
+        self.transcript
+            .lock()
+            .unwrap()
+            .push("--> (closed)".to_string());
+        self.writer.lock().await.shutdown().await.unwrap();
+    }
+
+    /// Wait for the server to end the session
+    ///
+    /// # Returns
+    ///
+    /// Whether it did within `timeout`
+    pub async fn wait_for_exit(&mut self, timeout: Duration) -> bool {
+        tokio::time::timeout(timeout, &mut self.server)
+            .await
+            .is_ok_and(|result| result.is_ok())
+    }
+
+    /// Messages exchanged so far, one per line (`-->` sent, `<--` received)
+    pub fn transcript(&self) -> String {
+        self.transcript.lock().unwrap().join("\n")
+    }
+
+    /// Next message of the server, or `None` past the deadline
+    async fn next_message(&mut self, deadline: Instant) -> Option<Value> {
+        tokio::time::timeout_at(deadline, self.incoming.recv())
+            .await
+            .ok()
+            .flatten()
+    }
+}
+
+impl Drop for TestClient {
+    fn drop(&mut self) {
+        if std::thread::panicking() {
+            eprintln!("LSP transcript:\n{}", self.transcript());
+        }
+    }
+}
+
+/// Read the messages of the server, answering its requests
+async fn read_messages(
+    mut reader: BufReader<ReadHalf<DuplexStream>>,
+    writer: Writer,
+    transcript: Transcript,
+    sender: mpsc::UnboundedSender<Value>,
+) {
+    while let Some(message) = read_message(&mut reader).await {
+        record(&transcript, "<--", &message);
+
+        let method = message.get("method").and_then(Value::as_str);
+        if let (Some(id), Some(method)) = (message.get("id"), method) {
+            let result = match method {
+                // One empty section per requested item
+                "workspace/configuration" => {
+                    let items = message["params"]["items"].as_array().map_or(0, Vec::len);
+                    Value::Array(vec![Value::Null; items])
+                }
+                _ => Value::Null,
+            };
+            let response = json!({ "jsonrpc": "2.0", "id": id, "result": result });
+            write_message(&writer, &transcript, &response).await;
+            continue;
+        }
+
+        if sender.send(message).is_err() {
+            break;
+        }
+    }
+}
+
+/// Read one framed message
+///
+/// Returns `None` when the pipe is closed or the message is malformed.
+async fn read_message(reader: &mut (impl AsyncBufRead + Unpin)) -> Option<Value> {
+    let mut length = None;
+    loop {
+        let mut line = String::new();
+        if reader.read_line(&mut line).await.ok()? == 0 {
+            return None;
+        }
+        let line = line.trim_end();
+        if line.is_empty() {
+            break;
+        }
+        if let Some(value) = line.strip_prefix("Content-Length:") {
+            length = value.trim().parse().ok();
+        }
+    }
+
+    let mut body = vec![0; length?];
+    reader.read_exact(&mut body).await.ok()?;
+    serde_json::from_slice(&body).ok()
+}
+
+/// Write one framed message
+async fn write_message(writer: &Writer, transcript: &Transcript, message: &Value) {
+    record(transcript, "-->", message);
+    let body = message.to_string();
+    let mut writer = writer.lock().await;
+    writer
+        .write_all(format!("Content-Length: {}\r\n\r\n{}", body.len(), body).as_bytes())
+        .await
+        .expect("Server closed the connection");
+    writer.flush().await.expect("Server closed the connection");
+}
+
+fn record(transcript: &Transcript, direction: &str, message: &Value) {
+    transcript
+        .lock()
+        .unwrap()
+        .push(format!("{} {}", direction, message));
+}
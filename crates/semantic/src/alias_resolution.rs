@@ -173,6 +173,8 @@ impl AliasResolver {
                             )
                             .with_primary_key_if(c.is_primary_key)
                             .with_foreign_key_if(c.is_foreign_key)
+                            .with_references(c.references.clone())
+                            .with_comment(c.comment.clone())
                         })
                         .collect(),
                 );
@@ -220,6 +222,8 @@ impl AliasResolver {
                                     )
                                     .with_primary_key_if(c.is_primary_key)
                                     .with_foreign_key_if(c.is_foreign_key)
+                                    .with_references(c.references.clone())
+                                    .with_comment(c.comment.clone())
                                 })
                                 .collect(),
                         );
@@ -271,6 +275,8 @@ impl AliasResolver {
                             )
                             .with_primary_key_if(c.is_primary_key)
                             .with_foreign_key_if(c.is_foreign_key)
+                            .with_references(c.references.clone())
+                            .with_comment(c.comment.clone())
                         })
                         .collect(),
                 );
@@ -314,6 +320,8 @@ impl AliasResolver {
                             )
                             .with_primary_key_if(c.is_primary_key)
                             .with_foreign_key_if(c.is_foreign_key)
+                            .with_references(c.references.clone())
+                            .with_comment(c.comment.clone())
                         })
                         .collect(),
                 );
@@ -370,6 +378,8 @@ impl AliasResolver {
                                     )
                                     .with_primary_key_if(c.is_primary_key)
                                     .with_foreign_key_if(c.is_foreign_key)
+                                    .with_references(c.references.clone())
+                                    .with_comment(c.comment.clone())
                                 })
                                 .collect(),
                         );
@@ -409,6 +419,8 @@ impl AliasResolver {
                                 )
                                 .with_primary_key_if(c.is_primary_key)
                                 .with_foreign_key_if(c.is_foreign_key)
+                                .with_references(c.references.clone())
+                                .with_comment(c.comment.clone())
                             })
                             .collect(),
                     );
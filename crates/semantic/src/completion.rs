@@ -21,6 +21,19 @@ pub struct ContextTableResolution {
     pub resolved_tables: Vec<TableSymbol>,
 }
 
+/// JOIN condition derived from a foreign key between two tables in scope.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ForeignKeyJoin {
+    /// Condition text, e.g. `o.user_id = u.id`.
+    pub condition: String,
+    /// Display name (alias or table name) of the referencing table.
+    pub from_table: String,
+    /// Display name (alias or table name) of the referenced table.
+    pub to_table: String,
+    /// Foreign key constraint name, if known.
+    pub constraint: Option<String>,
+}
+
 /// Semantic completion helper service.
 pub struct CompletionService {
     catalog: Arc<dyn Catalog>,
@@ -136,6 +149,86 @@ impl CompletionService {
         }))
     }
 
+    /// Derive JOIN conditions from foreign keys between the given tables.
+    ///
+    /// For every ordered pair of distinct table symbols where one side is the
+    /// table being joined (`joined`, an alias or table name) and a column of
+    /// the first references the table of the second, a condition is produced.
+    /// Columns sharing a constraint name form one composite condition joined
+    /// with `AND`; self-referencing tables work when both sides are aliased
+    /// differently. Tables are referred to by their display names.
+    pub fn foreign_key_joins(tables: &[TableSymbol], joined: &str) -> Vec<ForeignKeyJoin> {
+        let is_joined = |table: &TableSymbol| {
+            table.display_name().eq_ignore_ascii_case(joined)
+                || table.table_name.eq_ignore_ascii_case(joined)
+        };
+        let mut joins: Vec<ForeignKeyJoin> = Vec::new();
+
+        for (from_index, from) in tables.iter().enumerate() {
+            for (to_index, to) in tables.iter().enumerate() {
+                if from_index == to_index || from.display_name() == to.display_name() {
+                    continue;
+                }
+                // Conditions between two earlier tables belong to an earlier JOIN
+                if !is_joined(from) && !is_joined(to) {
+                    continue;
+                }
+
+                // Group referencing columns by constraint (unnamed ones stay separate)
+                let mut groups: Vec<(Option<&str>, Vec<(&str, &str)>)> = Vec::new();
+                for column in &from.columns {
+                    let Some(reference) = column.references.as_ref() else {
+                        continue;
+                    };
+                    if reference.column.is_empty()
+                        || !reference.table.eq_ignore_ascii_case(&to.table_name)
+                    {
+                        continue;
+                    }
+
+                    let pair = (column.name.as_str(), reference.column.as_str());
+                    let constraint = reference.constraint.as_deref();
+                    match groups
+                        .iter_mut()
+                        .find(|(name, _)| constraint.is_some() && *name == constraint)
+                    {
+                        Some((_, pairs)) => pairs.push(pair),
+                        None => groups.push((constraint, vec![pair])),
+                    }
+                }
+
+                for (constraint, pairs) in groups {
+                    let condition = pairs
+                        .iter()
+                        .map(|(column, referenced)| {
+                            format!(
+                                "{}.{} = {}.{}",
+                                from.display_name(),
+                                column,
+                                to.display_name(),
+                                referenced
+                            )
+                        })
+                        .collect::<Vec<_>>()
+                        .join(" AND ");
+
+                    if joins.iter().any(|j| j.condition == condition) {
+                        continue;
+                    }
+
+                    joins.push(ForeignKeyJoin {
+                        condition,
+                        from_table: from.display_name().to_string(),
+                        to_table: to.display_name().to_string(),
+                        constraint: constraint.map(str::to_string),
+                    });
+                }
+            }
+        }
+
+        joins
+    }
+
     /// Populate scope tables from catalog and resolve tables for rendering.
     ///
     /// Returns:
@@ -229,6 +322,9 @@ impl CompletionService {
         if meta.is_foreign_key {
             symbol = symbol.with_foreign_key();
         }
+        symbol = symbol
+            .with_references(meta.references.clone())
+            .with_comment(meta.comment.clone());
 
         symbol
     }
@@ -290,3 +386,113 @@ impl CompletionTextHeuristics {
         }
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_lsp_catalog::{DataType, TableReference};
+
+    fn fk(name: &str, table: &str, column: &str, constraint: Option<&str>) -> ColumnSymbol {
+        ColumnSymbol::new(name, DataType::Integer, "")
+            .with_foreign_key()
+            .with_references(Some(TableReference {
+                table: table.to_string(),
+                column: column.to_string(),
+                constraint: constraint.map(str::to_string),
+            }))
+    }
+
+    fn conditions(tables: &[TableSymbol], joined: &str) -> Vec<String> {
+        CompletionService::foreign_key_joins(tables, joined)
+            .into_iter()
+            .map(|j| j.condition)
+            .collect()
+    }
+
+    #[test]
+    fn test_foreign_key_joins_simple() {
+        let users = TableSymbol::new("users")
+            .with_alias("u")
+            .with_columns(vec![ColumnSymbol::new("id", DataType::Integer, "users")]);
+        let orders = TableSymbol::new("orders")
+            .with_alias("o")
+            .with_columns(vec![fk("user_id", "users", "id", None)]);
+
+        assert_eq!(conditions(&[users, orders], "o"), vec!["o.user_id = u.id"]);
+    }
+
+    #[test]
+    fn test_foreign_key_joins_multiple_paths() {
+        let users = TableSymbol::new("users");
+        let tickets = TableSymbol::new("tickets").with_columns(vec![
+            fk("created_by", "users", "id", Some("fk_created_by")),
+            fk("assigned_to", "users", "id", Some("fk_assigned_to")),
+        ]);
+
+        assert_eq!(
+            conditions(&[users, tickets], "tickets"),
+            vec![
+                "tickets.created_by = users.id",
+                "tickets.assigned_to = users.id"
+            ]
+        );
+    }
+
+    #[test]
+    fn test_foreign_key_joins_composite() {
+        let shipments = TableSymbol::new("shipments").with_columns(vec![
+            fk("order_id", "order_lines", "order_id", Some("fk_line")),
+            fk("line_no", "order_lines", "line_no", Some("fk_line")),
+        ]);
+        let lines = TableSymbol::new("order_lines").with_alias("l");
+
+        assert_eq!(
+            conditions(&[lines, shipments], "shipments"),
+            vec!["shipments.order_id = l.order_id AND shipments.line_no = l.line_no"]
+        );
+    }
+
+    #[test]
+    fn test_foreign_key_joins_self_reference() {
+        let employee = TableSymbol::new("employees")
+            .with_alias("e")
+            .with_columns(vec![fk("manager_id", "employees", "id", None)]);
+        let manager = employee.clone().with_alias("m");
+
+        assert_eq!(
+            conditions(&[employee, manager], "m"),
+            vec!["e.manager_id = m.id", "m.manager_id = e.id"]
+        );
+    }
+
+    #[test]
+    fn test_foreign_key_joins_ignores_unknown_references() {
+        // MySQL index-only "foreign keys" carry no referenced table
+        let users = TableSymbol::new("users");
+        let orders = TableSymbol::new("orders").with_columns(vec![fk("user_id", "", "", None)]);
+
+        assert!(conditions(&[users, orders], "orders").is_empty());
+    }
+
+    #[test]
+    fn test_foreign_key_joins_only_involve_joined_table() {
+        // FROM users a JOIN carts c ON ... JOIN orders b ON |
+        let users = TableSymbol::new("users")
+            .with_alias("a")
+            .with_columns(vec![ColumnSymbol::new("id", DataType::Integer, "users")]);
+        let carts = TableSymbol::new("carts")
+            .with_alias("c")
+            .with_columns(vec![fk("user_id", "users", "id", None)]);
+        let orders = TableSymbol::new("orders")
+            .with_alias("b")
+            .with_columns(vec![
+                fk("user_id", "users", "id", None),
+                fk("cart_id", "carts", "id", None),
+            ]);
+
+        assert_eq!(
+            conditions(&[users, carts, orders], "b"),
+            vec!["b.user_id = a.id", "b.cart_id = c.id"]
+        );
+    }
+}
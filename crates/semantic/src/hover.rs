@@ -7,7 +7,7 @@
 
 use std::sync::Arc;
 use tree_sitter::Node;
-use unified_sql_lsp_catalog::{Catalog, ColumnMetadata};
+use unified_sql_lsp_catalog::{Catalog, ColumnMetadata, TableMetadata};
 
 use crate::{AliasResolver, ResolutionResult};
 
@@ -87,15 +87,33 @@ impl HoverService {
     }
 
     /// Resolve a visible table by name.
+    ///
+    /// The name may be qualified as `schema.table` or `db.schema.table`; a
+    /// qualified name resolves to the qualified table name.
     pub async fn resolve_table_name(&self, word: &str) -> Option<String> {
+        let table = self.resolve_table(word).await?;
+        if word.contains('.') {
+            Some(table.qualified_name())
+        } else {
+            Some(table.name)
+        }
+    }
+
+    /// Resolve a visible table by name, returning its metadata.
+    pub async fn resolve_table(&self, word: &str) -> Option<TableMetadata> {
         let tables = self.catalog.list_tables().await.ok()?;
         let word_lower = word.to_lowercase();
-        for table in tables {
-            if table.name.to_lowercase() == word_lower {
-                return Some(table.name);
-            }
+
+        if word.contains('.') {
+            return tables
+                .into_iter()
+                .find(|table| table.qualified_name().to_lowercase() == word_lower);
         }
-        None
+
+        // Unqualified names refer to tables of the connected database
+        tables
+            .into_iter()
+            .find(|table| table.catalog.is_none() && table.name.to_lowercase() == word_lower)
     }
 
     /// Resolve alias to concrete table name.
@@ -162,19 +180,18 @@ impl HoverService {
     }
 
     /// Resolve table-qualified column reference (`table.column`).
+    ///
+    /// The table may itself be qualified (`db.schema.table.column`).
     pub async fn resolve_qualified_column(
         &self,
         node: &Node<'_>,
         source: &str,
     ) -> Option<ColumnMetadata> {
         let text = self.node_text(node, source);
-        let parts: Vec<&str> = text.split('.').collect();
-        if parts.len() != 2 {
-            return None;
-        }
+        let (table_part, column_part) = text.rsplit_once('.')?;
 
-        let table_part = parts[0].trim();
-        let column_part = parts[1].trim();
+        let table_part = table_part.trim();
+        let column_part = column_part.trim();
         if table_part.is_empty() || column_part.is_empty() {
             return None;
         }
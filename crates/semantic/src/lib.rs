@@ -117,7 +117,9 @@ pub mod analyzer;
 pub mod completion;
 pub mod error;
 pub mod hover;
+pub mod occurrences;
 pub mod resolution;
+pub mod schema_diagnostics;
 pub mod scope;
 pub mod symbol;
 pub mod syntax_diagnostics;
@@ -128,12 +130,21 @@ pub use alias_resolution::{
     AliasResolutionError, AliasResolver, ResolutionResult, ResolutionStrategy,
 };
 pub use analyzer::SemanticAnalyzer;
-pub use completion::{CompletionService, CompletionTextHeuristics, ContextTableResolution};
+pub use completion::{
+    CompletionService, CompletionTextHeuristics, ContextTableResolution, ForeignKeyJoin,
+};
 pub use error::{SemanticError, SemanticResult};
 pub use hover::HoverService;
+pub use occurrences::{
+    Occurrence, OccurrenceAccess, OccurrenceResolver, ResolvedSymbol, ResolvedSymbolKind,
+};
 pub use resolution::{
     ColumnCandidate, ColumnResolutionResult, ColumnResolver, MatchKind, ResolutionConfig,
 };
+pub use schema_diagnostics::{
+    NamedReference, SchemaDiagnostic, SchemaDiagnosticAnalyzer, SchemaDiagnosticKind,
+    SchemaReferences,
+};
 pub use scope::{Scope, ScopeManager, ScopeType};
 pub use symbol::{ColumnSymbol, TableSymbol};
 pub use syntax_diagnostics::{SyntaxDiagnostic, SyntaxDiagnosticAnalyzer, SyntaxRange};
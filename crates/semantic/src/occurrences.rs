@@ -0,0 +1,475 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Identifier occurrences
+//!
+//! This module resolves the identifier at a position to every occurrence of
+//! the same symbol in its statement, for document highlights and for
+//! references to aliases, which are local to their statement.
+//!
+//! Resolution follows the query scopes of [`SchemaReferences`]:
+//!
+//! ```text
+//! SELECT u.id FROM users u WHERE u.name = 'x'
+//!        ^                ^      ^            alias `u`: definition and qualifiers
+//! ```
+//!
+//! - A table alias resolves to its definition and the qualifiers naming it,
+//!   including in subqueries of the statement that do not shadow it
+//! - A table without an alias resolves to the tables of the same name in the
+//!   statement and the qualifiers naming them
+//! - A column resolves to the columns of the same name in its scope that
+//!   belong to the same table; unqualified columns belong to the only table
+//!   of their scope, or match any table when there are several
+//! - A SELECT output alias resolves to its definition and the unqualified
+//!   columns naming it (`ORDER BY total`)
+//!
+//! Occurrences that assign a column (UPDATE SET targets and INSERT column
+//! lists) are [`OccurrenceAccess::Write`]; every other occurrence is
+//! [`OccurrenceAccess::Read`].
+
+use crate::schema_diagnostics::SchemaReferences;
+use crate::syntax_diagnostics::SyntaxRange;
+
+/// How an occurrence uses its symbol
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum OccurrenceAccess {
+    Read,
+    Write,
+}
+
+/// Kind of a resolved symbol
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ResolvedSymbolKind {
+    Table,
+    TableAlias,
+    Column,
+    OutputAlias,
+}
+
+/// An occurrence of a symbol
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Occurrence {
+    pub range: SyntaxRange,
+    pub access: OccurrenceAccess,
+}
+
+/// The symbol at a position with its occurrences
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ResolvedSymbol {
+    pub kind: ResolvedSymbolKind,
+    /// Name as written at the position, unquoted
+    pub name: String,
+    /// Occurrences in document order, including the one at the position
+    pub occurrences: Vec<Occurrence>,
+}
+
+/// Identifier at a position, located in the reference scopes
+#[derive(Debug, Clone, Copy)]
+enum Hit {
+    /// Table name of `scopes[scope].tables[table]`
+    Table { scope: usize, table: usize },
+    /// Alias of `scopes[scope].tables[table]`
+    Alias { scope: usize, table: usize },
+    /// Qualifier of `scopes[scope].columns[column]`
+    Qualifier { scope: usize, column: usize },
+    /// Name of `scopes[scope].columns[column]`
+    Column { scope: usize, column: usize },
+    /// INSERT target column of `scopes[scope]`
+    InsertColumn { scope: usize, column: usize },
+    /// Output alias of `scopes[scope]`
+    OutputAlias { scope: usize, alias: usize },
+}
+
+/// A table of a scope, by scope and table index
+type TableId = (usize, usize);
+
+/// Resolver of the symbol at a position within its statement
+pub struct OccurrenceResolver<'a> {
+    references: &'a SchemaReferences,
+}
+
+impl<'a> OccurrenceResolver<'a> {
+    /// Create a resolver over the references of a document
+    pub fn new(references: &'a SchemaReferences) -> Self {
+        Self { references }
+    }
+
+    /// Resolve the identifier at a position
+    ///
+    /// # Arguments
+    ///
+    /// * `line` - Zero-based line of the position
+    /// * `character` - Character of the position; the end of an identifier
+    ///   counts as on it
+    ///
+    /// # Returns
+    ///
+    /// The symbol and its occurrences, or None when the position is not on
+    /// a table, alias or column (keywords, literals, whitespace)
+    pub fn resolve(&self, line: u32, character: u32) -> Option<ResolvedSymbol> {
+        let hit = self.hit_at(line, character)?;
+        let scopes = &self.references.scopes;
+
+        let symbol = match hit {
+            Hit::Alias { scope, table } => self.alias_symbol((scope, table)),
+            Hit::Table { scope, table } => {
+                let name = scopes[scope].tables[table].name.clone();
+                self.table_symbol(scope, name)
+            }
+            Hit::Qualifier { scope, column } => {
+                let qualifier = scopes[scope].columns[column].qualifier.clone()?;
+                match self.qualifier_target(scope, &qualifier) {
+                    Some(id) if scopes[id.0].tables[id.1].alias.is_some() => self.alias_symbol(id),
+                    _ => self.table_symbol(scope, qualifier),
+                }
+            }
+            Hit::Column { scope, column } => {
+                let column = &scopes[scope].columns[column];
+                let alias = scopes[scope]
+                    .output_aliases
+                    .iter()
+                    .position(|(alias, _)| alias.eq_ignore_ascii_case(&column.name));
+                match alias {
+                    Some(alias) if column.qualifier.is_none() => {
+                        self.output_alias_symbol(scope, alias)
+                    }
+                    _ => {
+                        let target = self.column_target(scope, column.qualifier.as_deref());
+                        self.column_symbol(scope, column.name.clone(), target)
+                    }
+                }
+            }
+            Hit::InsertColumn { scope, column } => {
+                let insert = scopes[scope].insert.as_ref()?;
+                let target = self.column_target(scope, None);
+                self.column_symbol(scope, insert.columns[column].0.clone(), target)
+            }
+            Hit::OutputAlias { scope, alias } => self.output_alias_symbol(scope, alias),
+        };
+
+        // Qualifiers naming no table in the statement resolve to nothing
+        Some(symbol).filter(|symbol| !symbol.occurrences.is_empty())
+    }
+
+    /// Innermost identifier containing a position
+    fn hit_at(&self, line: u32, character: u32) -> Option<Hit> {
+        let mut hits: Vec<(SyntaxRange, Hit)> = Vec::new();
+
+        for (scope, reference_scope) in self.references.scopes.iter().enumerate() {
+            for (table, scoped) in reference_scope.tables.iter().enumerate() {
+                // Subquery tables are named by their alias only
+                if let Some(range) = scoped.alias_range {
+                    hits.push((range, Hit::Alias { scope, table }));
+                }
+                if !scoped.name.is_empty() {
+                    hits.push((scoped.range, Hit::Table { scope, table }));
+                }
+            }
+            for (column, scoped) in reference_scope.columns.iter().enumerate() {
+                hits.push((scoped.range, Hit::Column { scope, column }));
+                if let Some(range) = scoped.qualifier_range {
+                    hits.push((range, Hit::Qualifier { scope, column }));
+                }
+            }
+            for (alias, (_, range)) in reference_scope.output_aliases.iter().enumerate() {
+                hits.push((*range, Hit::OutputAlias { scope, alias }));
+            }
+            let inserted = reference_scope
+                .insert
+                .iter()
+                .flat_map(|insert| &insert.columns);
+            for (column, (_, _, range)) in inserted.enumerate() {
+                hits.push((*range, Hit::InsertColumn { scope, column }));
+            }
+        }
+
+        // Unaliased subqueries span their whole FROM item, so prefer the innermost range
+        hits.into_iter()
+            .filter(|(range, _)| contains(range, line, character))
+            .min_by_key(|(range, _)| span(range))
+            .map(|(_, hit)| hit)
+    }
+
+    /// Alias of a table with its definition and the qualifiers naming it
+    fn alias_symbol(&self, id: TableId) -> ResolvedSymbol {
+        let scopes = &self.references.scopes;
+        let table = &scopes[id.0].tables[id.1];
+        let name = table.alias.clone().unwrap_or_default();
+
+        let mut occurrences: Vec<Occurrence> = table.alias_range.into_iter().map(read).collect();
+        for scope in self.statement_scopes(id.0) {
+            for column in &scopes[scope].columns {
+                let Some(qualifier) = &column.qualifier else {
+                    continue;
+                };
+                if self.qualifier_target(scope, qualifier) == Some(id) {
+                    occurrences.extend(column.qualifier_range.map(read));
+                }
+            }
+        }
+
+        symbol(ResolvedSymbolKind::TableAlias, name, occurrences)
+    }
+
+    /// Tables of a name in the statement of a scope, with the qualifiers naming them
+    fn table_symbol(&self, scope: usize, name: String) -> ResolvedSymbol {
+        let scopes = &self.references.scopes;
+        let mut occurrences = Vec::new();
+
+        for scope in self.statement_scopes(scope) {
+            for table in &scopes[scope].tables {
+                // Subquery tables are named by their alias, which is not a table name
+                if table.alias_range != Some(table.range) && table.name.eq_ignore_ascii_case(&name)
+                {
+                    occurrences.push(read(table.range));
+                }
+            }
+            for column in &scopes[scope].columns {
+                let Some(qualifier) = &column.qualifier else {
+                    continue;
+                };
+                let names_table = self
+                    .qualifier_target(scope, qualifier)
+                    .map(|(s, t)| &scopes[s].tables[t])
+                    .is_some_and(|table| {
+                        table.alias.is_none() && table.name.eq_ignore_ascii_case(&name)
+                    });
+                if names_table {
+                    occurrences.extend(column.qualifier_range.map(read));
+                }
+            }
+        }
+
+        symbol(ResolvedSymbolKind::Table, name, occurrences)
+    }
+
+    /// Columns of a name in a scope that belong to the same table
+    fn column_symbol(&self, scope: usize, name: String, target: Option<TableId>) -> ResolvedSymbol {
+        let reference_scope = &self.references.scopes[scope];
+        let same_table = |other: Option<TableId>| match (target, other) {
+            (Some(target), Some(other)) => target == other,
+            _ => true,
+        };
+
+        let mut occurrences = Vec::new();
+        for column in &reference_scope.columns {
+            if column.name.eq_ignore_ascii_case(&name)
+                && same_table(self.column_target(scope, column.qualifier.as_deref()))
+            {
+                occurrences.push(Occurrence {
+                    range: column.range,
+                    access: if column.write {
+                        OccurrenceAccess::Write
+                    } else {
+                        OccurrenceAccess::Read
+                    },
+                });
+            }
+        }
+        if let Some(insert) = &reference_scope.insert {
+            let target_table = self.column_target(scope, None);
+            for (column, _, range) in &insert.columns {
+                if column.eq_ignore_ascii_case(&name) && same_table(target_table) {
+                    occurrences.push(Occurrence {
+                        range: *range,
+                        access: OccurrenceAccess::Write,
+                    });
+                }
+            }
+        }
+
+        symbol(ResolvedSymbolKind::Column, name, occurrences)
+    }
+
+    /// Output alias with its definition and the unqualified columns naming it
+    fn output_alias_symbol(&self, scope: usize, alias: usize) -> ResolvedSymbol {
+        let reference_scope = &self.references.scopes[scope];
+        let (name, range) = &reference_scope.output_aliases[alias];
+
+        let mut occurrences = vec![read(*range)];
+        occurrences.extend(
+            reference_scope
+                .columns
+                .iter()
+                .filter(|column| {
+                    column.qualifier.is_none() && column.name.eq_ignore_ascii_case(name)
+                })
+                .map(|column| read(column.range)),
+        );
+
+        symbol(ResolvedSymbolKind::OutputAlias, name.clone(), occurrences)
+    }
+
+    /// Table a column belongs to, when it can be told from the query alone
+    fn column_target(&self, scope: usize, qualifier: Option<&str>) -> Option<TableId> {
+        match qualifier {
+            Some(qualifier) => self.qualifier_target(scope, qualifier),
+            None => {
+                let tables = &self.references.scopes[scope].tables;
+                (tables.len() == 1).then_some((scope, 0))
+            }
+        }
+    }
+
+    /// Table a qualifier names, in its scope or else in another scope of the statement
+    fn qualifier_target(&self, scope: usize, qualifier: &str) -> Option<TableId> {
+        let find = |scope: usize| {
+            self.references.scopes[scope]
+                .tables
+                .iter()
+                .position(|table| table.display_name().eq_ignore_ascii_case(qualifier))
+                .map(|table| (scope, table))
+        };
+
+        find(scope).or_else(|| {
+            self.statement_scopes(scope)
+                .filter(|other| *other != scope)
+                .find_map(find)
+        })
+    }
+
+    /// Scopes of the statement containing a scope
+    fn statement_scopes(&self, scope: usize) -> impl Iterator<Item = usize> + '_ {
+        let statement = self.references.scopes[scope].statement;
+        self.references
+            .scopes
+            .iter()
+            .enumerate()
+            .filter(move |(_, other)| other.statement == statement)
+            .map(|(index, _)| index)
+    }
+}
+
+/// Build a symbol with its occurrences sorted and deduplicated
+fn symbol(
+    kind: ResolvedSymbolKind,
+    name: String,
+    mut occurrences: Vec<Occurrence>,
+) -> ResolvedSymbol {
+    occurrences.sort_by_key(|occurrence| {
+        let range = occurrence.range;
+        (range.start_line, range.start_character)
+    });
+    occurrences.dedup_by_key(|occurrence| occurrence.range);
+
+    ResolvedSymbol {
+        kind,
+        name,
+        occurrences,
+    }
+}
+
+fn read(range: SyntaxRange) -> Occurrence {
+    Occurrence {
+        range,
+        access: OccurrenceAccess::Read,
+    }
+}
+
+fn contains(range: &SyntaxRange, line: u32, character: u32) -> bool {
+    (range.start_line, range.start_character) <= (line, character)
+        && (line, character) <= (range.end_line, range.end_character)
+}
+
+/// Size of a range, for picking the innermost of nested ranges
+fn span(range: &SyntaxRange) -> (u32, u32) {
+    (
+        range.end_line - range.start_line,
+        if range.start_line == range.end_line {
+            range.end_character - range.start_character
+        } else {
+            range.end_character
+        },
+    )
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::schema_diagnostics::SchemaDiagnosticAnalyzer;
+    use unified_sql_grammar::{language_for_dialect_with_version, DialectVersion};
+    use unified_sql_lsp_ir::Dialect;
+
+    /// Resolve the symbol at the `|` marker of a single-line query
+    fn resolve(sql: &str) -> Option<ResolvedSymbol> {
+        let character = sql.find('|').expect("cursor marker") as u32;
+        let sql = sql.replace('|', "");
+        let lang = language_for_dialect_with_version(Dialect::MySQL, Some(DialectVersion::MySQL80))
+            .expect("Failed to get MySQL 8.0 language");
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(lang).expect("Failed to set language");
+        let tree = parser.parse(&sql, None).expect("Failed to parse SQL");
+
+        let references = SchemaDiagnosticAnalyzer::new().collect_references(&tree, &sql);
+        OccurrenceResolver::new(&references).resolve(0, character)
+    }
+
+    /// Start characters and access of the occurrences
+    fn occurrences(symbol: &ResolvedSymbol) -> Vec<(u32, OccurrenceAccess)> {
+        symbol
+            .occurrences
+            .iter()
+            .map(|occurrence| (occurrence.range.start_character, occurrence.access))
+            .collect()
+    }
+
+    #[test]
+    fn test_alias_resolves_to_qualifiers() {
+        let symbol = resolve("SELECT u.id FROM users |u WHERE u.name = 'x'").unwrap();
+        assert_eq!(symbol.kind, ResolvedSymbolKind::TableAlias);
+        assert_eq!(symbol.name, "u");
+        assert_eq!(
+            occurrences(&symbol),
+            vec![
+                (7, OccurrenceAccess::Read),
+                (23, OccurrenceAccess::Read),
+                (31, OccurrenceAccess::Read)
+            ]
+        );
+
+        let symbol = resolve("SELECT |u.id FROM users u WHERE u.name = 'x'").unwrap();
+        assert_eq!(symbol.kind, ResolvedSymbolKind::TableAlias);
+        assert_eq!(symbol.occurrences.len(), 3);
+    }
+
+    #[test]
+    fn test_columns_of_the_same_table() {
+        let symbol =
+            resolve("SELECT u.|id FROM users u JOIN orders o ON o.id = u.id WHERE u.id > 1")
+                .unwrap();
+        assert_eq!(symbol.kind, ResolvedSymbolKind::Column);
+        assert_eq!(
+            occurrences(&symbol),
+            vec![
+                (9, OccurrenceAccess::Read),
+                (51, OccurrenceAccess::Read),
+                (62, OccurrenceAccess::Read)
+            ]
+        );
+    }
+
+    #[test]
+    fn test_assigned_columns_are_writes() {
+        let symbol = resolve("UPDATE users SET |name = 'a' WHERE name = 'b'").unwrap();
+        assert_eq!(
+            occurrences(&symbol),
+            vec![(17, OccurrenceAccess::Write), (34, OccurrenceAccess::Read)]
+        );
+
+        let symbol = resolve("INSERT INTO users (|id, name) VALUES (1, 'a')").unwrap();
+        assert_eq!(occurrences(&symbol), vec![(19, OccurrenceAccess::Write)]);
+    }
+
+    #[test]
+    fn test_output_alias_and_keyword() {
+        let symbol = resolve("SELECT name AS n FROM users ORDER BY |n").unwrap();
+        assert_eq!(symbol.kind, ResolvedSymbolKind::OutputAlias);
+        assert_eq!(symbol.occurrences.len(), 2);
+
+        assert!(resolve("SEL|ECT id FROM users").is_none());
+        assert!(resolve("SELECT id |FROM users").is_none());
+    }
+}
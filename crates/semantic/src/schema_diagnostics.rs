@@ -0,0 +1,1296 @@
+// Copyright (c) 2025 woxQAQ
+//
+// Licensed under the MIT License or Apache License 2.0
+// See LICENSE files for details
+
+//! # Schema diagnostics
+//!
+//! This module validates table and column references against the database
+//! schema exposed by the catalog.
+//!
+//! It reports:
+//! - Tables referenced in FROM/JOIN, INSERT, UPDATE and DELETE that do not exist
+//! - Columns that do not exist on any table of the enclosing query scope
+//! - INSERT column lists, VALUES rows and INSERT ... SELECT lists that do not
+//!   match the target table
+//! - Literals that cannot be stored in the column they are inserted into or
+//!   assigned to (e.g. `SET stock = 'many'` for an integer column)
+//!
+//! ## Architecture
+//!
+//! Validation runs in two phases so that no tree-sitter node is held across an
+//! await point:
+//!
+//! ```text
+//! Tree → collect_references() → SchemaReferences → check() → Vec<SchemaDiagnostic>
+//!        (sync, CST walk)                          (async, catalog lookups)
+//! ```
+//!
+//! Every SELECT (including CTE bodies and FROM subqueries) gets its own scope.
+//! CTE names are visible to later CTEs and to the main query. Tables that come
+//! from a CTE or a subquery have no catalog columns, so unqualified columns in
+//! a scope containing such a table are not checked.
+//!
+//! ## Literal types
+//!
+//! Only plain string, number and boolean literals are type-checked, and only
+//! when every supported database rejects them: a string that is not a number
+//! for a numeric column, a string that is not a boolean for a boolean column,
+//! and a boolean for a date/time column. Expressions, `NULL` and `DEFAULT`
+//! are never flagged.
+//!
+//! ## Identifier case
+//!
+//! Names are compared with the [`DialectRules`] of the analyzer: unquoted
+//! names match catalog names of any case, quoted names match with case where
+//! the dialect compares quoted identifiers with case (`SELECT "ID" FROM users`
+//! is an unknown column on PostgreSQL, not on MySQL), and table names match
+//! with case where the rules say so.
+
+use std::collections::HashMap;
+
+use tracing::debug;
+use unified_sql_lsp_catalog::{format_data_type, Catalog, CatalogResult, DataType};
+use unified_sql_lsp_ir::{identifier, DialectRules};
+
+use crate::syntax_diagnostics::SyntaxRange;
+
+/// Kind of schema diagnostic
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum SchemaDiagnosticKind {
+    /// Referenced table does not exist in the schema
+    UnknownTable,
+
+    /// Referenced column does not exist on any table in scope
+    UnknownColumn,
+
+    /// INSERT column list or value count does not match the target table
+    InsertColumnMismatch,
+
+    /// Literal cannot be stored in the column it is inserted into or assigned to
+    TypeMismatch,
+}
+
+/// Schema diagnostic result
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct SchemaDiagnostic {
+    pub kind: SchemaDiagnosticKind,
+    pub message: String,
+    pub range: SyntaxRange,
+}
+
+/// Table and column references of a document, grouped by query scope
+#[derive(Debug, Clone, Default)]
+pub struct SchemaReferences {
+    pub(crate) scopes: Vec<ReferenceScope>,
+}
+
+/// A table or column named in a document
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct NamedReference {
+    /// Name as written, unquoted (tables may be schema-qualified)
+    pub name: String,
+    /// Range of the name
+    pub range: SyntaxRange,
+}
+
+impl SchemaReferences {
+    /// Check whether the document references no tables at all
+    pub fn is_empty(&self) -> bool {
+        self.scopes.iter().all(|scope| scope.tables.is_empty())
+    }
+
+    /// Catalog tables referenced by the document
+    ///
+    /// CTE and subquery tables are not included.
+    pub fn tables(&self) -> impl Iterator<Item = NamedReference> + '_ {
+        self.scopes
+            .iter()
+            .flat_map(|scope| &scope.tables)
+            .filter(|table| !table.derived)
+            .map(|table| NamedReference {
+                name: table.name.clone(),
+                range: table.range,
+            })
+    }
+
+    /// Columns referenced by the document, including INSERT target columns
+    pub fn columns(&self) -> impl Iterator<Item = NamedReference> + '_ {
+        self.scopes.iter().flat_map(|scope| {
+            let columns = scope.columns.iter().map(|column| NamedReference {
+                name: column.name.clone(),
+                range: column.range,
+            });
+            let inserted =
+                scope
+                    .insert
+                    .iter()
+                    .flat_map(|insert| &insert.columns)
+                    .map(|(name, _, range)| NamedReference {
+                        name: name.clone(),
+                        range: *range,
+                    });
+            columns.chain(inserted)
+        })
+    }
+}
+
+/// A single query scope (one SELECT, INSERT, UPDATE or DELETE)
+#[derive(Debug, Clone, Default)]
+pub(crate) struct ReferenceScope {
+    /// Index of the first scope of the enclosing top-level statement
+    pub(crate) statement: usize,
+    pub(crate) tables: Vec<ScopedTable>,
+    pub(crate) columns: Vec<ScopedColumn>,
+    /// SELECT output aliases with the range of their name
+    pub(crate) output_aliases: Vec<(String, SyntaxRange)>,
+    pub(crate) insert: Option<ScopedInsert>,
+    /// UPDATE assignments of literals, by column name and whether it is quoted
+    assignments: Vec<(String, bool, ScopedLiteral)>,
+}
+
+/// A table visible in a scope
+#[derive(Debug, Clone)]
+pub(crate) struct ScopedTable {
+    pub(crate) name: String,
+    /// True when the name was quoted
+    pub(crate) quoted: bool,
+    pub(crate) alias: Option<String>,
+    /// Range of the table name (of the alias, or else the whole FROM item, for subqueries)
+    pub(crate) range: SyntaxRange,
+    pub(crate) alias_range: Option<SyntaxRange>,
+    /// True for CTE and subquery tables, whose columns are not in the catalog
+    pub(crate) derived: bool,
+}
+
+impl ScopedTable {
+    pub(crate) fn display_name(&self) -> &str {
+        self.alias.as_deref().unwrap_or(&self.name)
+    }
+}
+
+/// A column referenced in a scope
+#[derive(Debug, Clone)]
+pub(crate) struct ScopedColumn {
+    pub(crate) qualifier: Option<String>,
+    pub(crate) qualifier_range: Option<SyntaxRange>,
+    pub(crate) name: String,
+    /// True when the name was quoted
+    pub(crate) quoted: bool,
+    pub(crate) range: SyntaxRange,
+    /// True for UPDATE SET targets
+    pub(crate) write: bool,
+}
+
+/// INSERT target columns and value rows
+#[derive(Debug, Clone)]
+pub(crate) struct ScopedInsert {
+    /// Column list with whether each name is quoted
+    pub(crate) columns: Vec<(String, bool, SyntaxRange)>,
+    rows: Vec<ScopedRow>,
+    /// Select list width and range of INSERT ... SELECT (None for `SELECT *`)
+    query: Option<(usize, SyntaxRange)>,
+}
+
+/// A VALUES row
+#[derive(Debug, Clone)]
+struct ScopedRow {
+    /// One entry per value; None for expressions, NULL and DEFAULT
+    values: Vec<Option<ScopedLiteral>>,
+    range: SyntaxRange,
+}
+
+/// A literal value whose type is checked
+#[derive(Debug, Clone)]
+struct ScopedLiteral {
+    literal: Literal,
+    /// Literal as written
+    text: String,
+    range: SyntaxRange,
+}
+
+/// Type of a literal
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum Literal {
+    /// String literal with its unquoted content
+    String(String),
+    Number,
+    Boolean,
+}
+
+/// Columns of a table with their types
+type TableColumns = Vec<(String, DataType)>;
+
+/// Analyzer for schema diagnostics
+#[derive(Debug, Clone, Default)]
+pub struct SchemaDiagnosticAnalyzer {
+    /// Rules names are compared with
+    rules: DialectRules,
+}
+
+impl SchemaDiagnosticAnalyzer {
+    /// Create an analyzer comparing every name without case
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Create an analyzer comparing names with the rules of a dialect
+    ///
+    /// # Arguments
+    ///
+    /// * `rules` - Identifier rules, usually [`Dialect::rules`](unified_sql_lsp_ir::Dialect::rules)
+    pub fn with_rules(rules: DialectRules) -> Self {
+        Self { rules }
+    }
+
+    /// Collect table and column references from a parsed document
+    ///
+    /// # Arguments
+    ///
+    /// * `tree` - The parsed syntax tree
+    /// * `source` - The source code text
+    ///
+    /// # Returns
+    ///
+    /// The references grouped by query scope
+    pub fn collect_references(&self, tree: &tree_sitter::Tree, source: &str) -> SchemaReferences {
+        let mut references = SchemaReferences::default();
+        collect_statements(&tree.root_node(), source, &[], &mut references.scopes, 0);
+        references
+    }
+
+    /// Check collected references against the catalog
+    ///
+    /// # Arguments
+    ///
+    /// * `references` - References from [`Self::collect_references`]
+    /// * `catalog` - The catalog providing schema information
+    ///
+    /// # Returns
+    ///
+    /// Diagnostics for unknown tables, unknown columns, INSERT mismatches and
+    /// literal type mismatches.
+    /// An empty vector when the catalog exposes no tables, since nothing can
+    /// be validated without schema information.
+    ///
+    /// # Errors
+    ///
+    /// Returns the catalog error when the table list cannot be fetched, so
+    /// callers can suppress schema diagnostics while introspection is
+    /// unavailable.
+    pub async fn check(
+        &self,
+        references: &SchemaReferences,
+        catalog: &dyn Catalog,
+    ) -> CatalogResult<Vec<SchemaDiagnostic>> {
+        if references.is_empty() {
+            return Ok(Vec::new());
+        }
+
+        let tables = catalog.list_tables().await?;
+        if tables.is_empty() {
+            debug!("Catalog exposes no tables, skipping schema diagnostics");
+            return Ok(Vec::new());
+        }
+
+        let rules = &self.rules;
+        let known_tables: Vec<&str> = tables.iter().map(|t| t.name.as_str()).collect();
+
+        // Fetch columns once per referenced table (None = unknown table or unavailable)
+        let mut columns: HashMap<String, Option<TableColumns>> = HashMap::new();
+        for table in references
+            .scopes
+            .iter()
+            .flat_map(|scope| scope.tables.iter())
+            .filter(|table| !table.derived)
+        {
+            let key = rules.table_key(&table.name, table.quoted);
+            if columns.contains_key(&key) {
+                continue;
+            }
+
+            let table_columns = if let Some(name) = catalog_name(rules, &known_tables, table) {
+                match catalog.get_columns(name).await {
+                    Ok(cols) if !cols.is_empty() => {
+                        Some(cols.into_iter().map(|c| (c.name, c.data_type)).collect())
+                    }
+                    Ok(_) => None,
+                    Err(e) => {
+                        debug!("Columns unavailable for '{}': {}", table.name, e);
+                        None
+                    }
+                }
+            } else {
+                None
+            };
+            columns.insert(key, table_columns);
+        }
+
+        let mut diagnostics = Vec::new();
+        for scope in &references.scopes {
+            check_scope(scope, rules, &known_tables, &columns, &mut diagnostics);
+        }
+
+        Ok(diagnostics)
+    }
+}
+
+/// Validate a single scope
+fn check_scope(
+    scope: &ReferenceScope,
+    rules: &DialectRules,
+    known_tables: &[&str],
+    columns: &HashMap<String, Option<TableColumns>>,
+    diagnostics: &mut Vec<SchemaDiagnostic>,
+) {
+    for table in scope.tables.iter().filter(|t| !t.derived) {
+        if catalog_name(rules, known_tables, table).is_none() {
+            diagnostics.push(SchemaDiagnostic {
+                kind: SchemaDiagnosticKind::UnknownTable,
+                message: format!("Table '{}' does not exist", table.name),
+                range: table.range,
+            });
+        }
+    }
+
+    let columns_of = |table: &ScopedTable| -> Option<&TableColumns> {
+        if table.derived {
+            return None;
+        }
+        columns
+            .get(&rules.table_key(&table.name, table.quoted))
+            .and_then(Option::as_ref)
+    };
+    let has_column = |table_columns: &TableColumns, name: &str, quoted: bool| {
+        column_type(table_columns, rules, name, quoted).is_some()
+    };
+
+    for column in &scope.columns {
+        match &column.qualifier {
+            Some(qualifier) => {
+                // Unknown qualifiers are left to the syntax/completion layer
+                let Some(table) = scope
+                    .tables
+                    .iter()
+                    .find(|t| t.display_name().eq_ignore_ascii_case(qualifier))
+                else {
+                    continue;
+                };
+                let Some(table_columns) = columns_of(table) else {
+                    continue;
+                };
+                if !has_column(table_columns, &column.name, column.quoted) {
+                    diagnostics.push(SchemaDiagnostic {
+                        kind: SchemaDiagnosticKind::UnknownColumn,
+                        message: format!(
+                            "Column '{}' does not exist on table '{}'",
+                            column.name, table.name
+                        ),
+                        range: column.range,
+                    });
+                }
+            }
+            None => {
+                if scope.tables.is_empty()
+                    || scope
+                        .output_aliases
+                        .iter()
+                        .any(|(alias, _)| alias.eq_ignore_ascii_case(&column.name))
+                {
+                    continue;
+                }
+
+                // Every table's columns must be known to rule the column out
+                let Some(all_columns) = scope
+                    .tables
+                    .iter()
+                    .map(columns_of)
+                    .collect::<Option<Vec<_>>>()
+                else {
+                    continue;
+                };
+
+                if all_columns
+                    .iter()
+                    .all(|cols| !has_column(cols, &column.name, column.quoted))
+                {
+                    diagnostics.push(SchemaDiagnostic {
+                        kind: SchemaDiagnosticKind::UnknownColumn,
+                        message: format!(
+                            "Column '{}' does not exist on any table in scope",
+                            column.name
+                        ),
+                        range: column.range,
+                    });
+                }
+            }
+        }
+    }
+
+    let Some(target) = scope.tables.first() else {
+        return;
+    };
+    let Some(table_columns) = columns_of(target) else {
+        return;
+    };
+
+    for (column, quoted, literal) in &scope.assignments {
+        if let Some(data_type) = column_type(table_columns, rules, column, *quoted) {
+            check_literal(literal, column, data_type, diagnostics);
+        }
+    }
+
+    let Some(insert) = &scope.insert else {
+        return;
+    };
+
+    for (column, quoted, range) in &insert.columns {
+        if !has_column(table_columns, column, *quoted) {
+            diagnostics.push(SchemaDiagnostic {
+                kind: SchemaDiagnosticKind::InsertColumnMismatch,
+                message: format!(
+                    "Column '{}' does not exist on table '{}'",
+                    column, target.name
+                ),
+                range: *range,
+            });
+        }
+    }
+
+    // Target column of each value, in order, with whether it is quoted
+    let targets: Vec<(&str, bool)> = if insert.columns.is_empty() {
+        table_columns
+            .iter()
+            .map(|(name, _)| (name.as_str(), false))
+            .collect()
+    } else {
+        insert
+            .columns
+            .iter()
+            .map(|(name, quoted, _)| (name.as_str(), *quoted))
+            .collect()
+    };
+
+    for row in &insert.rows {
+        if row.values.len() != targets.len() {
+            diagnostics.push(SchemaDiagnostic {
+                kind: SchemaDiagnosticKind::InsertColumnMismatch,
+                message: format!(
+                    "INSERT into '{}' expects {} values, found {}",
+                    target.name,
+                    targets.len(),
+                    row.values.len()
+                ),
+                range: row.range,
+            });
+            continue;
+        }
+
+        for (&(column, quoted), value) in targets.iter().zip(&row.values) {
+            let Some(literal) = value else {
+                continue;
+            };
+            if let Some(data_type) = column_type(table_columns, rules, column, quoted) {
+                check_literal(literal, column, data_type, diagnostics);
+            }
+        }
+    }
+
+    if let Some((count, range)) = insert.query.filter(|(count, _)| *count != targets.len()) {
+        diagnostics.push(SchemaDiagnostic {
+            kind: SchemaDiagnosticKind::InsertColumnMismatch,
+            message: format!(
+                "INSERT into '{}' expects {} columns, SELECT returns {}",
+                target.name,
+                targets.len(),
+                count
+            ),
+            range,
+        });
+    }
+}
+
+/// Catalog name of the table a reference names
+fn catalog_name<'a>(
+    rules: &DialectRules,
+    known_tables: &[&'a str],
+    table: &ScopedTable,
+) -> Option<&'a str> {
+    known_tables
+        .iter()
+        .find(|known| rules.matches_table(&table.name, table.quoted, known))
+        .copied()
+}
+
+/// Type of a column, looked up by name as written
+fn column_type<'a>(
+    columns: &'a TableColumns,
+    rules: &DialectRules,
+    name: &str,
+    quoted: bool,
+) -> Option<&'a DataType> {
+    columns
+        .iter()
+        .find(|(column, _)| rules.matches(name, quoted, column))
+        .map(|(_, data_type)| data_type)
+}
+
+/// Report a literal that cannot be stored in a column
+fn check_literal(
+    literal: &ScopedLiteral,
+    column: &str,
+    data_type: &DataType,
+    diagnostics: &mut Vec<SchemaDiagnostic>,
+) {
+    if is_compatible(&literal.literal, data_type) {
+        return;
+    }
+    diagnostics.push(SchemaDiagnostic {
+        kind: SchemaDiagnosticKind::TypeMismatch,
+        message: format!(
+            "Value {} does not match column '{}' of type {}",
+            literal.text,
+            column,
+            format_data_type(data_type)
+        ),
+        range: literal.range,
+    });
+}
+
+/// Check whether a literal may be stored in a column of the given type
+///
+/// Deliberately lenient: databases convert most literals implicitly (e.g.
+/// `'42'` into an integer column, `1` into a MySQL boolean), so only
+/// conversions that always fail are rejected.
+fn is_compatible(literal: &Literal, data_type: &DataType) -> bool {
+    match (literal, data_type) {
+        (
+            Literal::String(text),
+            DataType::Integer
+            | DataType::BigInt
+            | DataType::SmallInt
+            | DataType::TinyInt
+            | DataType::Decimal
+            | DataType::Float
+            | DataType::Double,
+        ) => text.trim().parse::<f64>().is_ok(),
+        (Literal::String(text), DataType::Boolean) => {
+            // PostgreSQL accepts any prefix of these words
+            let text = text.trim().to_lowercase();
+            !text.is_empty()
+                && ["true", "false", "yes", "no", "on", "off", "1", "0"]
+                    .iter()
+                    .any(|word| word.starts_with(&text))
+        }
+        (
+            Literal::Boolean,
+            DataType::Date | DataType::Time | DataType::DateTime | DataType::Timestamp,
+        ) => false,
+        _ => true,
+    }
+}
+
+/// Find statements below `node` and collect their scopes
+fn collect_statements(
+    node: &tree_sitter::Node,
+    source: &str,
+    ctes: &[String],
+    scopes: &mut Vec<ReferenceScope>,
+    depth: usize,
+) {
+    if depth > 100 {
+        return;
+    }
+
+    let first = scopes.len();
+    match node.kind() {
+        "select_statement" => collect_select(node, source, ctes, scopes, depth),
+        "insert_statement" | "replace_statement" => collect_insert(node, source, scopes),
+        "update_statement" | "delete_statement" => collect_dml(node, source, scopes),
+        _ => {
+            for child in node.children(&mut node.walk()) {
+                collect_statements(&child, source, ctes, scopes, depth + 1);
+            }
+            return;
+        }
+    }
+
+    for scope in &mut scopes[first..] {
+        scope.statement = first;
+    }
+}
+
+/// Collect a SELECT scope, recursing into CTE bodies and FROM subqueries
+fn collect_select(
+    node: &tree_sitter::Node,
+    source: &str,
+    ctes: &[String],
+    scopes: &mut Vec<ReferenceScope>,
+    depth: usize,
+) {
+    let mut visible_ctes = ctes.to_vec();
+    let mut scope = ReferenceScope::default();
+
+    for child in node.children(&mut node.walk()) {
+        match child.kind() {
+            "cte_clause" => {
+                for definition in child
+                    .children(&mut child.walk())
+                    .filter(|c| c.kind() == "cte_definition")
+                {
+                    let Some(name) = child_text(&definition, "table_name", source) else {
+                        continue;
+                    };
+                    visible_ctes.push(name);
+                    for body in definition
+                        .children(&mut definition.walk())
+                        .filter(|c| c.kind() == "select_statement")
+                    {
+                        collect_select(&body, source, &visible_ctes, scopes, depth + 1);
+                    }
+                }
+            }
+            "from_clause" => {
+                for item in child.children(&mut child.walk()) {
+                    match item.kind() {
+                        "table_reference" => {
+                            collect_table_reference(
+                                &item,
+                                source,
+                                &visible_ctes,
+                                &mut scope,
+                                scopes,
+                                depth,
+                            );
+                        }
+                        "join_clause" => {
+                            if let Some(table) = table_from(&item, source, &visible_ctes) {
+                                scope.tables.push(table);
+                            }
+                            collect_columns(
+                                &item,
+                                source,
+                                &visible_ctes,
+                                &mut scope,
+                                scopes,
+                                depth,
+                            );
+                        }
+                        _ => {}
+                    }
+                }
+            }
+            "projection" => {
+                for alias in child
+                    .children(&mut child.walk())
+                    .filter(|c| c.kind() == "alias")
+                {
+                    scope
+                        .output_aliases
+                        .push((unquote(&node_text(&alias, source)), node_range(&alias)));
+                }
+                collect_columns(&child, source, &visible_ctes, &mut scope, scopes, depth);
+            }
+            _ => collect_columns(&child, source, &visible_ctes, &mut scope, scopes, depth),
+        }
+    }
+
+    scopes.push(scope);
+}
+
+/// Add a FROM item to a scope; subqueries become derived tables with their own scope
+fn collect_table_reference(
+    node: &tree_sitter::Node,
+    source: &str,
+    ctes: &[String],
+    scope: &mut ReferenceScope,
+    scopes: &mut Vec<ReferenceScope>,
+    depth: usize,
+) {
+    let subquery = node
+        .children(&mut node.walk())
+        .find(|c| c.kind() == "select_statement");
+
+    match subquery {
+        Some(select) => {
+            collect_select(&select, source, ctes, scopes, depth + 1);
+            let alias = child_text(node, "alias", source);
+            let alias_range = child_range(node, "alias");
+            scope.tables.push(ScopedTable {
+                name: alias.clone().unwrap_or_default(),
+                quoted: false,
+                alias,
+                range: alias_range.unwrap_or_else(|| node_range(node)),
+                alias_range,
+                derived: true,
+            });
+        }
+        None => {
+            if let Some(table) = table_from(node, source, ctes) {
+                scope.tables.push(table);
+            }
+        }
+    }
+}
+
+/// Collect an INSERT/REPLACE scope
+fn collect_insert(node: &tree_sitter::Node, source: &str, scopes: &mut Vec<ReferenceScope>) {
+    let mut scope = ReferenceScope::default();
+    if let Some(table) = table_from(node, source, &[]) {
+        scope.tables.push(table);
+    }
+
+    let mut insert = ScopedInsert {
+        columns: Vec::new(),
+        rows: Vec::new(),
+        query: None,
+    };
+    for child in node.children(&mut node.walk()) {
+        match child.kind() {
+            "column_list" => {
+                for column in child
+                    .children(&mut child.walk())
+                    .filter(|c| c.kind() == "column_name")
+                {
+                    let (name, quoted) = identifier_of(&column, source);
+                    insert.columns.push((name, quoted, node_range(&column)));
+                }
+            }
+            "value_list" => {
+                let values = child
+                    .children(&mut child.walk())
+                    .filter(|c| matches!(c.kind(), "expression" | "default_value"))
+                    .map(|value| literal_of(&value, source))
+                    .collect();
+                insert.rows.push(ScopedRow {
+                    values,
+                    range: node_range(&child),
+                });
+            }
+            "select_statement" => {
+                insert.query = select_list(&child, source);
+                collect_select(&child, source, &[], scopes, 1);
+            }
+            _ => {}
+        }
+    }
+
+    scope.insert = Some(insert);
+    scopes.push(scope);
+}
+
+/// Collect an UPDATE/DELETE scope
+fn collect_dml(node: &tree_sitter::Node, source: &str, scopes: &mut Vec<ReferenceScope>) {
+    let mut scope = ReferenceScope::default();
+    if let Some(table) = table_from(node, source, &[]) {
+        scope.tables.push(table);
+    }
+
+    for child in node.children(&mut node.walk()) {
+        match child.kind() {
+            "assignment" => {
+                if let Some(column) = child
+                    .children(&mut child.walk())
+                    .find(|c| c.kind() == "column_name")
+                {
+                    let (name, quoted) = identifier_of(&column, source);
+                    if let Some(literal) = child
+                        .children(&mut child.walk())
+                        .find(|c| c.kind() == "expression")
+                        .and_then(|value| literal_of(&value, source))
+                    {
+                        scope.assignments.push((name.clone(), quoted, literal));
+                    }
+                    scope.columns.push(ScopedColumn {
+                        qualifier: None,
+                        qualifier_range: None,
+                        name,
+                        quoted,
+                        range: node_range(&column),
+                        write: true,
+                    });
+                }
+                collect_columns(&child, source, &[], &mut scope, scopes, 0);
+            }
+            "where_clause" => collect_columns(&child, source, &[], &mut scope, scopes, 0),
+            _ => {}
+        }
+    }
+
+    scopes.push(scope);
+}
+
+/// Collect column references below `node` into `scope`
+fn collect_columns(
+    node: &tree_sitter::Node,
+    source: &str,
+    ctes: &[String],
+    scope: &mut ReferenceScope,
+    scopes: &mut Vec<ReferenceScope>,
+    depth: usize,
+) {
+    if depth > 100 {
+        return;
+    }
+
+    match node.kind() {
+        "column_reference" => {
+            let qualifier = child_text(node, "table_name", source);
+            if let Some(column) = node
+                .children(&mut node.walk())
+                .find(|c| c.kind() == "column_name")
+            {
+                let (name, quoted) = identifier_of(&column, source);
+                scope.columns.push(ScopedColumn {
+                    qualifier,
+                    qualifier_range: child_range(node, "table_name"),
+                    name,
+                    quoted,
+                    range: node_range(&column),
+                    write: false,
+                });
+            }
+        }
+        // Nested SELECTs get their own scope
+        "select_statement" => collect_select(node, source, ctes, scopes, depth + 1),
+        _ => {
+            for child in node.children(&mut node.walk()) {
+                collect_columns(&child, source, ctes, scope, scopes, depth + 1);
+            }
+        }
+    }
+}
+
+/// Number of columns returned by a SELECT and the range of its select list
+///
+/// None for `SELECT *`, whose width depends on the tables read.
+fn select_list(node: &tree_sitter::Node, source: &str) -> Option<(usize, SyntaxRange)> {
+    let projection = node
+        .children(&mut node.walk())
+        .find(|c| c.kind() == "projection")?;
+
+    let mut width = 1;
+    for item in projection.children(&mut projection.walk()) {
+        match node_text(&item, source).trim() {
+            "*" => return None,
+            "," => width += 1,
+            _ => {}
+        }
+    }
+    Some((width, node_range(&projection)))
+}
+
+/// Literal of a value, or None when the value is not a plain literal
+fn literal_of(node: &tree_sitter::Node, source: &str) -> Option<ScopedLiteral> {
+    let literal = match node.kind() {
+        // Unwrap expression and literal nodes holding a single literal (not NULL)
+        "expression" | "literal" if node.named_child_count() == 1 => {
+            return literal_of(&node.named_child(0)?, source);
+        }
+        "string_literal" => {
+            let text = node_text(node, source);
+            let content = text.strip_prefix('\'')?.strip_suffix('\'')?;
+            Literal::String(content.replace("''", "'"))
+        }
+        "number_literal" => Literal::Number,
+        "boolean_literal" => Literal::Boolean,
+        _ => return None,
+    };
+
+    Some(ScopedLiteral {
+        literal,
+        text: node_text(node, source),
+        range: node_range(node),
+    })
+}
+
+/// Build a table reference from a node with `table_name` and optional `alias` children
+fn table_from(node: &tree_sitter::Node, source: &str, ctes: &[String]) -> Option<ScopedTable> {
+    let name_node = node
+        .children(&mut node.walk())
+        .find(|c| c.kind() == "table_name")?;
+    let (name, quoted) = identifier_of(&name_node, source);
+    let derived = ctes.iter().any(|cte| cte.eq_ignore_ascii_case(&name));
+
+    Some(ScopedTable {
+        name,
+        quoted,
+        alias: child_text(node, "alias", source),
+        range: node_range(&name_node),
+        alias_range: child_range(node, "alias"),
+        derived,
+    })
+}
+
+/// Unquoted text of the first child of the given kind
+fn child_text(node: &tree_sitter::Node, kind: &str, source: &str) -> Option<String> {
+    node.children(&mut node.walk())
+        .find(|c| c.kind() == kind)
+        .map(|c| unquote(&node_text(&c, source)))
+}
+
+/// Range of the first child of the given kind
+fn child_range(node: &tree_sitter::Node, kind: &str) -> Option<SyntaxRange> {
+    node.children(&mut node.walk())
+        .find(|c| c.kind() == kind)
+        .map(|c| node_range(&c))
+}
+
+fn node_text(node: &tree_sitter::Node, source: &str) -> String {
+    source[node.byte_range()].to_string()
+}
+
+/// Strip identifier quotes (`"name"`, `` `name` ``, `[name]`)
+fn unquote(text: &str) -> String {
+    identifier::unquote(text).0.to_string()
+}
+
+/// Unquoted text of an identifier node, and whether it was quoted
+fn identifier_of(node: &tree_sitter::Node, source: &str) -> (String, bool) {
+    let (name, quoted) = identifier::unquote(&source[node.byte_range()]);
+    (name.to_string(), quoted)
+}
+
+fn node_range(node: &tree_sitter::Node) -> SyntaxRange {
+    let start = node.start_position();
+    let end = node.end_position();
+    SyntaxRange {
+        start_line: start.row as u32,
+        start_character: start.column as u32,
+        end_line: end.row as u32,
+        end_character: end.column as u32,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use unified_sql_grammar::{language_for_dialect_with_version, DialectVersion};
+    use unified_sql_lsp_catalog::OfflineCatalog;
+    use unified_sql_lsp_ir::Dialect;
+    use unified_sql_lsp_test_utils::MockCatalogBuilder;
+
+    /// Run schema diagnostics against the standard fixture schema
+    /// (users, orders, products)
+    async fn diagnose(sql: &str) -> Vec<SchemaDiagnostic> {
+        diagnose_with(
+            DialectVersion::MySQL80,
+            SchemaDiagnosticAnalyzer::new(),
+            sql,
+        )
+        .await
+    }
+
+    /// Run schema diagnostics with a grammar and an analyzer
+    async fn diagnose_with(
+        version: DialectVersion,
+        analyzer: SchemaDiagnosticAnalyzer,
+        sql: &str,
+    ) -> Vec<SchemaDiagnostic> {
+        let dialect = match version {
+            DialectVersion::PostgreSQL12 | DialectVersion::PostgreSQL14 => Dialect::PostgreSQL,
+            _ => Dialect::MySQL,
+        };
+        let lang = language_for_dialect_with_version(dialect, Some(version))
+            .expect("Failed to get language");
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(lang).expect("Failed to set language");
+        let tree = parser.parse(sql, None).expect("Failed to parse SQL");
+
+        let references = analyzer.collect_references(&tree, sql);
+        let catalog = MockCatalogBuilder::new().with_standard_schema().build();
+        analyzer.check(&references, &catalog).await.unwrap()
+    }
+
+    #[test]
+    fn test_named_references() {
+        let sql = "WITH recent AS (SELECT id FROM orders) \
+                   SELECT u.name FROM users u JOIN recent r ON r.id = u.id; \
+                   INSERT INTO products (sku) VALUES ('a')";
+        let lang = language_for_dialect_with_version(Dialect::MySQL, Some(DialectVersion::MySQL80))
+            .expect("Failed to get MySQL 8.0 language");
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(lang).expect("Failed to set language");
+        let tree = parser.parse(sql, None).expect("Failed to parse SQL");
+
+        let references = SchemaDiagnosticAnalyzer::new().collect_references(&tree, sql);
+
+        let mut tables: Vec<String> = references.tables().map(|t| t.name).collect();
+        tables.sort();
+        assert_eq!(tables, vec!["orders", "products", "users"]);
+
+        let columns: Vec<String> = references.columns().map(|c| c.name).collect();
+        for column in ["id", "name", "sku"] {
+            assert!(columns.iter().any(|c| c == column), "{:?}", columns);
+        }
+    }
+
+    fn messages(diagnostics: &[SchemaDiagnostic]) -> Vec<&str> {
+        diagnostics.iter().map(|d| d.message.as_str()).collect()
+    }
+
+    #[tokio::test]
+    async fn test_valid_query_has_no_diagnostics() {
+        let diagnostics = diagnose(
+            "SELECT u.name, o.total FROM users u JOIN orders o ON u.id = o.user_id WHERE status = 'paid'",
+        )
+        .await;
+        assert!(diagnostics.is_empty(), "{:?}", diagnostics);
+    }
+
+    #[tokio::test]
+    async fn test_unknown_table() {
+        let diagnostics = diagnose("SELECT id FROM customers").await;
+
+        assert_eq!(diagnostics.len(), 1, "{:?}", diagnostics);
+        assert_eq!(diagnostics[0].kind, SchemaDiagnosticKind::UnknownTable);
+        assert_eq!(diagnostics[0].message, "Table 'customers' does not exist");
+        assert_eq!(diagnostics[0].range.start_character, 15);
+        assert_eq!(diagnostics[0].range.end_character, 24);
+    }
+
+    #[tokio::test]
+    async fn test_unknown_column_unqualified_and_qualified() {
+        let diagnostics = diagnose("SELECT nickname, u.age FROM users u").await;
+
+        assert_eq!(
+            messages(&diagnostics),
+            vec![
+                "Column 'nickname' does not exist on any table in scope",
+                "Column 'age' does not exist on table 'users'",
+            ]
+        );
+        assert!(diagnostics
+            .iter()
+            .all(|d| d.kind == SchemaDiagnosticKind::UnknownColumn));
+    }
+
+    #[tokio::test]
+    async fn test_column_resolved_across_joined_tables() {
+        // "total" only exists on orders, "email" only on users
+        let diagnostics =
+            diagnose("SELECT email, total FROM users JOIN orders ON users.id = orders.user_id")
+                .await;
+        assert!(diagnostics.is_empty(), "{:?}", diagnostics);
+    }
+
+    #[tokio::test]
+    async fn test_projection_alias_in_order_by() {
+        let diagnostics = diagnose("SELECT name AS n FROM users ORDER BY n").await;
+        assert!(diagnostics.is_empty(), "{:?}", diagnostics);
+    }
+
+    #[tokio::test]
+    async fn test_cte_columns_are_not_checked_against_catalog() {
+        let diagnostics = diagnose(
+            "WITH recent AS (SELECT id, user_id FROM orders) SELECT r.id, anything FROM recent r",
+        )
+        .await;
+        assert!(diagnostics.is_empty(), "{:?}", diagnostics);
+    }
+
+    #[tokio::test]
+    async fn test_cte_body_is_validated() {
+        let diagnostics =
+            diagnose("WITH recent AS (SELECT id, bogus FROM orders) SELECT id FROM recent").await;
+
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["Column 'bogus' does not exist on any table in scope"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_subquery_scope() {
+        // Outer columns come from the derived table; the inner scope is checked on its own
+        let diagnostics =
+            diagnose("SELECT s.total, other FROM (SELECT total, missing FROM orders) AS s").await;
+
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["Column 'missing' does not exist on any table in scope"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_insert_column_list_mismatch() {
+        let diagnostics = diagnose("INSERT INTO users (id, nickname) VALUES (1, 'a')").await;
+
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["Column 'nickname' does not exist on table 'users'"]
+        );
+        assert_eq!(
+            diagnostics[0].kind,
+            SchemaDiagnosticKind::InsertColumnMismatch
+        );
+    }
+
+    #[tokio::test]
+    async fn test_insert_value_count_mismatch() {
+        let diagnostics = diagnose("INSERT INTO users (id, name) VALUES (1)").await;
+
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["INSERT into 'users' expects 2 values, found 1"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_insert_multi_row_values() {
+        let diagnostics = diagnose(
+            "INSERT INTO users (id, name) VALUES (1, 'a'), (2), (DEFAULT, DEFAULT), (4, 'd', 'x')",
+        )
+        .await;
+
+        assert_eq!(
+            messages(&diagnostics),
+            vec![
+                "INSERT into 'users' expects 2 values, found 1",
+                "INSERT into 'users' expects 2 values, found 3",
+            ]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_insert_select_column_count() {
+        let diagnostics =
+            diagnose("INSERT INTO users (id, name) SELECT id, total, status FROM orders").await;
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["INSERT into 'users' expects 2 columns, SELECT returns 3"]
+        );
+
+        let diagnostics =
+            diagnose("INSERT INTO users (id, name) SELECT user_id, status AS name FROM orders")
+                .await;
+        assert!(diagnostics.is_empty(), "{:?}", diagnostics);
+
+        // The width of SELECT * is not known; the SELECT itself is still checked
+        let diagnostics = diagnose("INSERT INTO users SELECT * FROM orders WHERE bogus = 1").await;
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["Column 'bogus' does not exist on any table in scope"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_literal_type_mismatch() {
+        let diagnostics =
+            diagnose("UPDATE products SET stock = 'many', price = '9.99', name = 42 WHERE id = 1")
+                .await;
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["Value 'many' does not match column 'stock' of type Integer"]
+        );
+        assert_eq!(diagnostics[0].kind, SchemaDiagnosticKind::TypeMismatch);
+
+        let diagnostics =
+            diagnose("INSERT INTO products (id, name, price) VALUES ('x', 1, 2), (3, 'b', 'NaN')")
+                .await;
+        assert_eq!(
+            messages(&diagnostics),
+            vec!["Value 'x' does not match column 'id' of type BigInt"]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_expressions_and_default_are_not_type_checked() {
+        let diagnostics = diagnose(
+            "UPDATE products SET stock = stock + 'x', price = DEFAULT, name = NULL WHERE id = 1",
+        )
+        .await;
+        assert!(diagnostics.is_empty(), "{:?}", diagnostics);
+    }
+
+    #[tokio::test]
+    async fn test_identifier_case_follows_dialect() {
+        let case_sensitive_tables = DialectRules {
+            case_sensitive_tables: true,
+            ..Dialect::MySQL.rules()
+        };
+
+        // Grammar, rules, query, expected diagnostics
+        let cases = [
+            (
+                DialectVersion::PostgreSQL14,
+                Dialect::PostgreSQL.rules(),
+                "SELECT ID FROM Users",
+                vec![],
+            ),
+            (
+                DialectVersion::PostgreSQL14,
+                Dialect::PostgreSQL.rules(),
+                "SELECT \"ID\", \"email\" FROM users",
+                vec!["Column 'ID' does not exist on any table in scope"],
+            ),
+            (
+                DialectVersion::PostgreSQL14,
+                Dialect::PostgreSQL.rules(),
+                "SELECT id FROM \"Users\"",
+                vec!["Table 'Users' does not exist"],
+            ),
+            (
+                DialectVersion::PostgreSQL14,
+                Dialect::PostgreSQL.rules(),
+                "INSERT INTO users (\"Name\") VALUES ('a')",
+                vec!["Column 'Name' does not exist on table 'users'"],
+            ),
+            (
+                DialectVersion::MySQL80,
+                Dialect::MySQL.rules(),
+                "SELECT `ID` FROM `Users`",
+                vec![],
+            ),
+            (
+                DialectVersion::MySQL80,
+                Dialect::MySQL.rules(),
+                "UPDATE USERS SET Name = 'a'",
+                vec![],
+            ),
+            (
+                DialectVersion::MySQL80,
+                case_sensitive_tables,
+                "SELECT ID FROM Users",
+                vec!["Table 'Users' does not exist"],
+            ),
+            (
+                DialectVersion::MySQL80,
+                case_sensitive_tables,
+                "SELECT ID FROM users",
+                vec![],
+            ),
+        ];
+        for (version, rules, sql, expected) in cases {
+            let analyzer = SchemaDiagnosticAnalyzer::with_rules(rules);
+            let diagnostics = diagnose_with(version, analyzer, sql).await;
+            assert_eq!(messages(&diagnostics), expected, "{}", sql);
+        }
+    }
+
+    #[tokio::test]
+    async fn test_suppressed_without_schema() {
+        let sql = "SELECT id FROM customers";
+        let lang = language_for_dialect_with_version(Dialect::MySQL, Some(DialectVersion::MySQL80))
+            .expect("Failed to get MySQL 8.0 language");
+        let mut parser = tree_sitter::Parser::new();
+        parser.set_language(lang).expect("Failed to set language");
+        let tree = parser.parse(sql, None).expect("Failed to parse SQL");
+
+        let analyzer = SchemaDiagnosticAnalyzer::new();
+        let references = analyzer.collect_references(&tree, sql);
+        let diagnostics = analyzer
+            .check(&references, &OfflineCatalog::new())
+            .await
+            .unwrap();
+        assert!(diagnostics.is_empty());
+    }
+
+    #[test]
+    fn test_unquote() {
+        assert_eq!(unquote("`users`"), "users");
+        assert_eq!(unquote("\"User Name\""), "User Name");
+        assert_eq!(unquote("[id]"), "id");
+        assert_eq!(unquote("plain"), "plain");
+    }
+}
@@ -8,7 +8,7 @@
 //! This module defines symbol types representing tables and columns in SQL queries.
 
 use serde::{Deserialize, Serialize};
-use unified_sql_lsp_catalog::DataType;
+use unified_sql_lsp_catalog::{DataType, TableReference};
 
 /// Represents a table symbol in a SQL query
 ///
@@ -167,6 +167,14 @@ pub struct ColumnSymbol {
     /// Whether this column is a foreign key
     #[serde(default)]
     pub is_foreign_key: bool,
+
+    /// Referenced table and column (if foreign key and known)
+    #[serde(default)]
+    pub references: Option<TableReference>,
+
+    /// Column comment from the database
+    #[serde(default)]
+    pub comment: Option<String>,
 }
 
 impl ColumnSymbol {
@@ -199,6 +207,8 @@ impl ColumnSymbol {
             table_name: table_name.into(),
             is_primary_key: false,
             is_foreign_key: false,
+            references: None,
+            comment: None,
         }
     }
 
@@ -247,4 +257,43 @@ impl ColumnSymbol {
         self.is_foreign_key = is_fk;
         self
     }
+
+    /// Set the foreign key reference of this column
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// use unified_sql_lsp_semantic::ColumnSymbol;
+    /// use unified_sql_lsp_catalog::{DataType, TableReference};
+    ///
+    /// let column = ColumnSymbol::new("user_id", DataType::Integer, "orders")
+    ///     .with_foreign_key()
+    ///     .with_references(Some(TableReference {
+    ///         table: "users".to_string(),
+    ///         column: "id".to_string(),
+    ///         constraint: None,
+    ///     }));
+    /// assert_eq!(column.references.unwrap().table, "users");
+    /// ```
+    pub fn with_references(mut self, references: Option<TableReference>) -> Self {
+        self.references = references;
+        self
+    }
+
+    /// Set the comment of this column
+    ///
+    /// # Examples
+    ///
+    /// ```
+    /// use unified_sql_lsp_semantic::ColumnSymbol;
+    /// use unified_sql_lsp_catalog::DataType;
+    ///
+    /// let column = ColumnSymbol::new("total", DataType::Decimal, "orders")
+    ///     .with_comment(Some("Order total, tax included".to_string()));
+    /// assert_eq!(column.comment.as_deref(), Some("Order total, tax included"));
+    /// ```
+    pub fn with_comment(mut self, comment: Option<String>) -> Self {
+        self.comment = comment;
+        self
+    }
 }
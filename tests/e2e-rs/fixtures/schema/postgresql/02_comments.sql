@@ -0,0 +1,12 @@
+-- ============================================================================
+-- Unified SQL LSP - E2E Test Fixtures
+-- PostgreSQL Object Comments
+-- ============================================================================
+-- Comments on tables, columns and functions of 01_create_tables.sql, shown in
+-- hover and completion documentation. Other objects are left uncommented.
+-- ============================================================================
+
+COMMENT ON TABLE orders IS 'Customer orders, one row per checkout';
+COMMENT ON COLUMN orders.total_amount IS 'Order total in USD, tax included';
+COMMENT ON COLUMN users.email IS 'Login email, unique per user';
+COMMENT ON FUNCTION log_order_creation() IS 'Writes an audit log entry for each new order';
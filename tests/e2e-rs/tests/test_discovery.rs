@@ -6,5 +6,5 @@
 
 pub const MYSQL_5_7: &[&str] = &["completion", "diagnostics", "hover"];
 pub const MYSQL_8_0: &[&str] = &["completion"];
-pub const POSTGRESQL_12: &[&str] = &["completion"];
+pub const POSTGRESQL_12: &[&str] = &["completion", "hover"];
 pub const POSTGRESQL_16: &[&str] = &["completion"];